@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"gt/pkg/plugin"
+)
+
+var (
+	addTTL      time.Duration
+	addFileName string
+)
+
+// parseDestination splits [user@]host[:port], the host in brackets when
+// it is an IPv6 address with a port.
+func parseDestination(dest string) (user, host, port string, err error) {
+	if i := strings.LastIndex(dest, "@"); i >= 0 {
+		if i == 0 {
+			return "", "", "", fmt.Errorf("%q has an empty user", dest)
+		}
+		user, dest = dest[:i], dest[i+1:]
+	}
+	host = dest
+	if strings.HasPrefix(dest, "[") || strings.Count(dest, ":") == 1 {
+		if host, port, err = net.SplitHostPort(dest); err != nil {
+			host = strings.TrimSuffix(strings.TrimPrefix(dest, "["), "]")
+			port, err = "", nil
+		}
+	}
+	if host == "" {
+		return "", "", "", errors.New("a destination needs a host")
+	}
+	if port != "" {
+		if n, perr := strconv.Atoi(port); perr != nil || n < 1 || n > 65535 {
+			return "", "", "", fmt.Errorf("invalid port %q", port)
+		}
+	}
+	return user, host, port, nil
+}
+
+// insertHostBlock adds block to a config above its first wildcard Host or
+// Match block, where every host of its own is, so a catch-all does not
+// take the first say over options the new host sets; else at the end.
+func insertHostBlock(data []byte, block string) []byte {
+	preamble, blocks := parseConfigBlocks(data)
+	var b strings.Builder
+	b.WriteString(preamble)
+	placed := false
+	for _, cb := range blocks {
+		if !placed && !cb.concrete() {
+			b.WriteString(block + "\n")
+			placed = true
+		}
+		b.WriteString(cb.text)
+	}
+	if !placed {
+		s := b.String()
+		if s != "" && !strings.HasSuffix(s, "\n\n") {
+			if !strings.HasSuffix(s, "\n") {
+				b.WriteString("\n")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(block)
+	}
+	return []byte(b.String())
+}
+
+// addHostBlock writes h's Host block into the config at path, placed by
+// insertHostBlock.
+func addHostBlock(path string, h plugin.Host) error {
+	err := editConfig(path, func(data []byte) ([]byte, error) {
+		return insertHostBlock(data, formatHostBlock(h)), nil
+	})
+	if err != nil {
+		return withCode(exitConfig, err)
+	}
+	return nil
+}
+
+// setHostExpiry records expires as the expiry of alias in gt's config.
+func setHostExpiry(alias string, expires time.Time) error {
+	return editGTHosts(func(hosts *yaml.Node) (bool, error) {
+		meta := mappingValue(hosts, alias)
+		if meta == nil || meta.Kind != yaml.MappingNode {
+			meta = &yaml.Node{Kind: yaml.MappingNode}
+			setMappingValue(hosts, alias, meta)
+		}
+		setMappingValue(meta, "expires", &yaml.Node{Kind: yaml.ScalarNode, Value: expires.UTC().Format(time.RFC3339)})
+		return true, nil
+	})
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add <alias> <[user@]host[:port]>",
+	Short: "Add a Host to the SSH config",
+	Long: `Add a Host block for alias to your SSH config, with the HostName and,
+when given, User and Port. It goes above the config's first wildcard
+Host or Match block, with your other hosts, or with --file NAME into
+config.d/NAME.conf, which gt config new-include creates and includes
+if need be.
+
+--ttl marks the host temporary, for review apps and CI machines: once
+it has passed, gt list greys the host out, completion leaves it out,
+and gt config prune removes it. The expiry is kept in gt's config, as
+the host's expires.`,
+	Example: `  gt add web-pr-42 deploy@10.0.4.17 --ttl 72h
+  gt add lab pi@lab.local:2222 --file home`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias := args[0]
+		u, host, port, err := parseDestination(args[1])
+		if err != nil {
+			return err
+		}
+		h := plugin.Host{Alias: alias, HostName: host, User: u, Port: port}
+		if err := validateImported(h); err != nil {
+			return err
+		}
+		if knownHost(alias) {
+			return fmt.Errorf("'%s' is already a Host in the SSH config", alias)
+		}
+		if addTTL < 0 || cmd.Flags().Changed("ttl") && addTTL == 0 {
+			return errors.New("--ttl must be positive")
+		}
+		cmd.SilenceUsage = true
+
+		path, err := sshConfigPath()
+		if err != nil {
+			return err
+		}
+		if addFileName != "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			if path, _, _, err = newInclude(path, home, addFileName); err != nil {
+				return err
+			}
+		}
+		if err := addHostBlock(path, h); err != nil {
+			return err
+		}
+		statusf(symbolColor, "Added %s to %s\n", alias, path)
+
+		if addTTL > 0 {
+			expires := time.Now().Add(addTTL)
+			if err := setHostExpiry(alias, expires); err != nil {
+				return fmt.Errorf("%s was added, but not its expiry: %w", alias, err)
+			}
+			statusf(symbolColor, "Expires %s; gt config prune removes it after\n", expires.Local().Format("2006-01-02 15:04"))
+		}
+		return nil
+	},
+}
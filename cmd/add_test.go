@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDestination(t *testing.T) {
+	for in, want := range map[string][3]string{
+		"host":                  {"", "host", ""},
+		"me@host":               {"me", "host", ""},
+		"me@host:2222":          {"me", "host", "2222"},
+		"a@b@host":              {"a@b", "host", ""},
+		"2001:db8::1":           {"", "2001:db8::1", ""},
+		"[2001:db8::1]:22":      {"", "2001:db8::1", "22"},
+		"root@[2001:db8::1]":    {"root", "2001:db8::1", ""},
+		"deploy@10.0.4.17:8022": {"deploy", "10.0.4.17", "8022"},
+	} {
+		u, h, p, err := parseDestination(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, [3]string{u, h, p}, in)
+	}
+	for _, in := range []string{"", "@host", "host:0", "host:http", "me@"} {
+		_, _, _, err := parseDestination(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestInsertHostBlock(t *testing.T) {
+	block := "Host new\n  HostName n\n"
+	assert.Equal(t, "Host a\n  User x\n\nHost new\n  HostName n\n\n# defaults\nHost *\n  User me\n",
+		string(insertHostBlock([]byte("Host a\n  User x\n\n# defaults\nHost *\n  User me\n"), block)),
+		"above the catch-all, with its comment")
+	assert.Equal(t, "Host a\n\nHost new\n  HostName n\n", string(insertHostBlock([]byte("Host a\n"), block)))
+	assert.Equal(t, block, string(insertHostBlock(nil, block)))
+}
+
+func TestAddWithTTL(t *testing.T) {
+	useMockExec(t)
+	useWebGroup(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	dir := t.TempDir()
+	gtPath := filepath.Join(dir, "config.yaml")
+	t.Setenv("GT_CONFIG", gtPath)
+	writeConfigFile(t, gtPath, "# mine\ntheme:\n  name: mono\nhosts:\n  web-1:\n    groups: [web] # front\n")
+	origCfgFile, origTTL := cfgFile, addTTL
+	t.Cleanup(func() { cfgFile, addTTL = origCfgFile, origTTL })
+	cfgFile = filepath.Join(dir, "ssh_config")
+	writeConfigFile(t, cfgFile, "Host web-1\n\nHost *\n  User me\n")
+
+	addTTL = 72 * time.Hour
+	require.NoError(t, addCmd.RunE(addCmd, []string{"pr-42", "deploy@10.0.4.17:8022"}))
+	data, _ := os.ReadFile(cfgFile)
+	assert.Equal(t, "Host web-1\n\nHost pr-42\n  HostName 10.0.4.17\n  User deploy\n  Port 8022\n\nHost *\n  User me\n", string(data))
+
+	gt, _ := os.ReadFile(gtPath)
+	assert.True(t, strings.HasPrefix(string(gt), "# mine\ntheme:\n  name: mono\nhosts:\n  web-1:\n    groups: [web] # front\n  pr-42:\n    expires: "), string(gt))
+	loaded, err := loadGTConfig(gtPath)
+	require.NoError(t, err)
+	expires, err := time.Parse(time.RFC3339, loaded.Hosts["pr-42"].Expires)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(72*time.Hour), expires, time.Minute)
+	assert.False(t, hostMetaFor("pr-42").expired(time.Now()), "in effect at once")
+	assert.True(t, hostMetaFor("pr-42").expired(time.Now().Add(73*time.Hour)))
+
+	assert.ErrorContains(t, addCmd.RunE(addCmd, []string{"web-1", "x"}), "already a Host")
+}
+
+func TestHostMetaExpired(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	assert.False(t, hostMeta{}.expired(now))
+	assert.False(t, hostMeta{Expires: "next week"}.expired(now), "a typo never expires")
+	assert.False(t, hostMeta{Expires: "2026-10-14T13:00:00Z"}.expired(now))
+	assert.True(t, hostMeta{Expires: "2026-10-14T12:00:00Z"}.expired(now))
+}
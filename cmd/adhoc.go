@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"gt/pkg/plugin"
+)
+
+// adHocHost is the host the root command connects to with no alias of
+// its own, with the user it connects as, once urlTarget has found it
+// in no Host block; gt offers to save it after the login.
+var adHocHost *sshURL
+
+// addressTarget reads arg, which is no alias, as an address to connect
+// to anyway: [user@]host[:port] with a user or a port, or a host that is
+// an IP address or a dotted name. Anything else, such as a mistyped
+// alias, is not one, and still fails as an unknown host.
+func addressTarget(arg string) (sshURL, bool) {
+	u, host, port, err := parseDestination(arg)
+	if err != nil || strings.HasPrefix(u, "-") || strings.HasPrefix(host, "-") {
+		return sshURL{}, false
+	}
+	if net.ParseIP(host) == nil {
+		if strings.Trim(strings.ToLower(host), "abcdefghijklmnopqrstuvwxyz0123456789.-") != "" {
+			return sshURL{}, false
+		}
+		if u == "" && port == "" && !strings.Contains(strings.Trim(host, "."), ".") {
+			return sshURL{}, false
+		}
+	}
+	return sshURL{user: u, host: host, port: port}, true
+}
+
+// suggestedAlias is the alias offered for saving host: its first label,
+// or an IP address as it is.
+func suggestedAlias(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	first, _, _ := strings.Cut(host, ".")
+	return first
+}
+
+// offerSaveHost asks, after a login to an ad-hoc host, whether to add it
+// to the SSH config as gt add would, under an alias p reads.
+func offerSaveHost(p *prompter, u sshURL) error {
+	if !p.confirm(fmt.Sprintf("Save %s as a Host in the SSH config?", u.host), false) {
+		return nil
+	}
+	alias := p.ask("Alias", suggestedAlias(u.host))
+	h := plugin.Host{Alias: alias, HostName: u.host, User: u.user, Port: u.port}
+	if err := validateImported(h); err != nil {
+		return err
+	}
+	if knownHost(alias) {
+		return fmt.Errorf("'%s' is already a Host in the SSH config; gt add %s <destination> under another name", alias, alias)
+	}
+	path, err := sshConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := addHostBlock(path, h); err != nil {
+		return err
+	}
+	statusf(symbolColor, "Added %s to %s\n", alias, path)
+	return nil
+}
+
+// maybeSaveAdHocHost offers to save the host of a login that was made
+// with no alias, when there is someone to ask.
+func maybeSaveAdHocHost() {
+	if adHocHost == nil || nonInteractive() {
+		return
+	}
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+	if err := offerSaveHost(p, *adHocHost); err != nil {
+		errorColor.Fprintf(os.Stderr, "Could not save %s: %v\n", adHocHost.host, err)
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressTarget(t *testing.T) {
+	for in, want := range map[string]sshURL{
+		"deploy@box":            {user: "deploy", host: "box"},
+		"box:2222":              {host: "box", port: "2222"},
+		"10.0.4.17":             {host: "10.0.4.17"},
+		"2001:db8::1":           {host: "2001:db8::1"},
+		"root@[2001:db8::1]:22": {user: "root", host: "2001:db8::1", port: "22"},
+		"app.example.com":       {host: "app.example.com"},
+	} {
+		got, ok := addressTarget(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"nope", "web-*", "a,b", "@web", "-oProxyCommand=x@h", "me@-h", "host.", "box/1", ""} {
+		_, ok := addressTarget(in)
+		assert.False(t, ok, in)
+	}
+}
+
+func TestSuggestedAlias(t *testing.T) {
+	assert.Equal(t, "app", suggestedAlias("app.example.com"))
+	assert.Equal(t, "10.0.4.17", suggestedAlias("10.0.4.17"))
+}
+
+func TestRootTargetsAddress(t *testing.T) {
+	useURLConfig(t)
+	aliases, rest, _, err := rootTargets([]string{"ops@10.0.4.17:2200", "uptime"}, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.4.17"}, aliases)
+	assert.Equal(t, []string{"uptime"}, rest)
+	assert.Equal(t, "ops", user)
+	assert.Equal(t, []string{"Port=2200"}, sshOverrides)
+	assert.Equal(t, &sshURL{user: "ops", host: "10.0.4.17", port: "2200"}, adHocHost)
+
+	user, sshOverrides, adHocHost = "", nil, nil
+	aliases, _, _, err = rootTargets([]string{"root@web1"}, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web1"}, aliases, "a user and an alias")
+	assert.Equal(t, "root", user)
+	assert.Nil(t, adHocHost)
+
+	aliases, _, _, err = rootTargets([]string{"web1.example.com"}, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web1"}, aliases, "the alias with that HostName")
+
+	_, _, _, err = rootTargets([]string{"nope"}, -1)
+	assert.ErrorContains(t, err, "host 'nope' not found")
+}
+
+func TestOfferSaveHost(t *testing.T) {
+	useURLConfig(t)
+	origCfgFile := cfgFile
+	t.Cleanup(func() { cfgFile = origCfgFile })
+	cfgFile = filepath.Join(t.TempDir(), "ssh_config")
+	writeConfigFile(t, cfgFile, "Host web1\n  HostName web1.example.com\n\nHost *\n  User me\n")
+	u := sshURL{user: "ops", host: "app.example.com", port: "2200"}
+
+	var out bytes.Buffer
+	ask := func(answers string) *prompter {
+		out.Reset()
+		return &prompter{in: bufio.NewReader(strings.NewReader(answers)), out: &out}
+	}
+	require.NoError(t, offerSaveHost(ask("\n"), u))
+	data, _ := os.ReadFile(cfgFile)
+	assert.NotContains(t, string(data), "app.example.com", "declined by default")
+
+	assert.ErrorContains(t, offerSaveHost(ask("y\nweb1\n"), u), "already a Host")
+
+	require.NoError(t, offerSaveHost(ask("y\n\n"), u))
+	assert.Contains(t, out.String(), "Alias [app]: ")
+	data, _ = os.ReadFile(cfgFile)
+	assert.Equal(t, "Host web1\n  HostName web1.example.com\n\nHost app\n  HostName app.example.com\n  User ops\n  Port 2200\n\nHost *\n  User me\n", string(data))
+}
@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
+)
+
+// shortcuts are gt's own short names for Host aliases, from gt alias
+// add, keyed by name. gt expands them wherever it takes a host; ssh never
+// sees them, so the config keeps its long descriptive names.
+var shortcuts map[string]string
+
+// shortcutsHeader opens the aliases file, which gt rewrites whole.
+const shortcutsHeader = "# Written by gt alias add and gt alias rm: name: Host alias.\n"
+
+// shortcutsPath is aliases.yaml next to gt's config file.
+func shortcutsPath() (string, error) {
+	p, err := gtConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(p), "aliases.yaml"), nil
+}
+
+// loadShortcuts reads the aliases file; a missing one is no shortcuts.
+// It gets the config's ownership and permission check, since it decides
+// which host a name connects to.
+func loadShortcuts(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	if err := sshconf.ValidateOpen(path, f); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return parseShortcuts(path, data)
+}
+
+func parseShortcuts(path string, data []byte) (map[string]string, error) {
+	m := map[string]string{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// expandShortcut is the Host alias name stands for: name itself when the
+// SSH config has a Host by that name, which always wins, else what a
+// shortcut expands to, else name unchanged.
+func expandShortcut(name string) string {
+	if knownHost(name) {
+		return name
+	}
+	if alias, ok := shortcuts[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// hostArg is a command's host argument as a Host alias: expanded if it
+// is a shortcut, and an error unless the SSH config has it.
+func hostArg(name string) (string, error) {
+	alias := expandShortcut(name)
+	if knownHost(alias) {
+		return alias, nil
+	}
+	if alias != name {
+		return "", withCode(exitHostNotFound, fmt.Errorf("'%s' is short for '%s', which is not in the SSH config (gt alias rm %s drops it)", name, alias, name))
+	}
+	return "", unknownHostError(name)
+}
+
+// expandJumpShortcuts expands shortcuts among --jump's hops, which ssh
+// would otherwise look up as Host aliases of their own.
+func expandJumpShortcuts(hops string) string {
+	if hops == "" || len(shortcuts) == 0 {
+		return hops
+	}
+	parts := strings.Split(hops, ",")
+	for i, hop := range parts {
+		u, h, p := transport.SplitJump(hop)
+		alias := expandShortcut(h)
+		if alias == h {
+			continue
+		}
+		if u != "" {
+			alias = u + "@" + alias
+		}
+		if p != "" {
+			alias += ":" + p
+		}
+		parts[i] = alias
+	}
+	return strings.Join(parts, ",")
+}
+
+// validateShortcut checks that name can stand for a host on gt's
+// command line without being read as something else.
+func validateShortcut(name string) error {
+	switch {
+	case name == "":
+		return errors.New("an alias needs a name")
+	case strings.HasPrefix(name, "-"):
+		return fmt.Errorf("alias '%s' would read as a flag", name)
+	case strings.ContainsAny(name, ",@*?[ \t"):
+		return fmt.Errorf("alias '%s' has a character gt reads as a list, group or pattern", name)
+	case strings.Trim(name, "0123456789") == "":
+		return fmt.Errorf("alias '%s' would read as a gt list --numbered index", name)
+	case knownHost(name):
+		return fmt.Errorf("'%s' is already a Host in the SSH config", name)
+	}
+	if c, _, _ := rootCmd.Find([]string{name}); c != rootCmd {
+		return fmt.Errorf("'%s' is a gt command", name)
+	}
+	return nil
+}
+
+// editShortcuts applies edit to the aliases file under the config lock,
+// starting over if someone else changed it meanwhile; edit returning
+// false leaves the file alone.
+func editShortcuts(edit func(m map[string]string) (bool, error)) error {
+	path, err := shortcutsPath()
+	if err != nil {
+		return err
+	}
+	err = editConfig(path, func(data []byte) ([]byte, error) {
+		m, err := parseShortcuts(path, data)
+		if err != nil {
+			return nil, err
+		}
+		if changed, err := edit(m); err != nil || !changed {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		buf.WriteString(shortcutsHeader)
+		if len(m) > 0 {
+			enc := yaml.NewEncoder(&buf)
+			enc.SetIndent(2)
+			if err := enc.Encode(m); err != nil {
+				return nil, err
+			}
+		}
+		shortcuts = m
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		return withCode(exitConfig, err)
+	}
+	return nil
+}
+
+// renderShortcuts writes gt alias list: name, then the Host alias.
+func renderShortcuts(w io.Writer, m map[string]string) {
+	if len(m) == 0 {
+		fmt.Fprintln(w, "No aliases; add one with gt alias add <name> <host>")
+		return
+	}
+	names := make([]string, 0, len(m))
+	width := 0
+	for name := range m {
+		names = append(names, name)
+		if n := displayWidth(name); n > width {
+			width = n
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		aliasColor.Fprint(w, name)
+		fmt.Fprint(w, strings.Repeat(" ", width-displayWidth(name)+2))
+		fmt.Fprint(w, m[name])
+		if !knownHost(m[name]) {
+			warningColor.Fprint(w, "  (not in the SSH config)")
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// shortcutDescriptions lists the shortcuts for completion, each
+// described by the Host alias it stands for.
+func shortcutDescriptions() []string {
+	var out []string
+	for name, alias := range shortcuts {
+		if !knownHost(name) {
+			out = append(out, name+"\t"+alias)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage short names for Host aliases",
+	Long: `Manage gt's own short names for the Host aliases in your SSH config,
+kept in aliases.yaml next to gt's config file. A short name works
+wherever gt takes a host, as gt w1, gt exec w1,db -- uptime or
+gt -s w1 app.conf :/etc/app/, and completes like a host; ssh itself
+only ever sees the Host alias. A Host of the same name always wins.`,
+}
+
+var aliasAddCmd = &cobra.Command{
+	Use:   "add <name> <host>",
+	Short: "Make name stand for a Host alias",
+	Example: `  gt alias add w1 web-prod-eu-1
+  gt w1`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 1 {
+			return describedHosts(), cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := validateShortcut(name); err != nil {
+			return err
+		}
+		alias, err := hostArg(args[1])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		var old string
+		err = editShortcuts(func(m map[string]string) (bool, error) {
+			old = m[name]
+			if old == alias {
+				return false, nil
+			}
+			m[name] = alias
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		switch old {
+		case "":
+			statusf(symbolColor, "%s now stands for %s\n", name, alias)
+		case alias:
+			statusf(symbolColor, "%s already stands for %s\n", name, alias)
+		default:
+			statusf(symbolColor, "%s now stands for %s (was %s)\n", name, alias, old)
+		}
+		return nil
+	},
+}
+
+var aliasRmCmd = &cobra.Command{
+	Use:     "rm <name>...",
+	Aliases: []string{"remove"},
+	Short:   "Remove short names",
+	Args:    cobra.MinimumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return shortcutDescriptions(), cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		var missing []string
+		err := editShortcuts(func(m map[string]string) (bool, error) {
+			missing = nil
+			for _, name := range args {
+				if _, ok := m[name]; !ok {
+					missing = append(missing, name)
+				}
+				delete(m, name)
+			}
+			return len(missing) < len(args), nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return withCode(exitHostNotFound, fmt.Errorf("no alias %s", strings.Join(missing, ", ")))
+		}
+		return nil
+	},
+}
+
+var aliasListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List short names and the Host aliases they stand for",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		renderShortcuts(cmd.OutOrStdout(), shortcuts)
+		return nil
+	},
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useShortcuts sets gt's aliases for one test, with the aliases file in
+// a fresh config directory.
+func useShortcuts(t *testing.T, m map[string]string) string {
+	t.Helper()
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	dir := t.TempDir()
+	t.Setenv("GT_CONFIG", filepath.Join(dir, "config.yaml"))
+	orig := shortcuts
+	t.Cleanup(func() { shortcuts = orig })
+	shortcuts = m
+	return filepath.Join(dir, "aliases.yaml")
+}
+
+func TestHostArgExpandsShortcuts(t *testing.T) {
+	useWebGroup(t)
+	useShortcuts(t, map[string]string{"w1": "web-1", "old": "web-9", "down": "web-2"})
+
+	alias, err := hostArg("w1")
+	assert.NoError(t, err)
+	assert.Equal(t, "web-1", alias)
+	alias, _ = hostArg("down")
+	assert.Equal(t, "down", alias, "a Host of the same name wins")
+	_, err = hostArg("old")
+	assert.EqualError(t, err, "'old' is short for 'web-9', which is not in the SSH config (gt alias rm old drops it)")
+	assert.Equal(t, exitHostNotFound, ExitCode(err))
+	_, err = hostArg("nope")
+	assert.ErrorContains(t, err, "host 'nope' not found")
+
+	aliases, err := expandTarget("w1,web-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-1", "web-2"}, aliases)
+
+	aliases, rest, batch, err := leadingTargets([]string{"w1", "uptime"}, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-1"}, aliases)
+	assert.Equal(t, []string{"uptime"}, rest)
+	assert.False(t, batch)
+	aliases, _, batch, err = leadingTargets([]string{"web-2", "w1", "uptime"}, -1)
+	assert.NoError(t, err)
+	assert.True(t, batch)
+	assert.ElementsMatch(t, []string{"web-1", "web-2"}, aliases)
+}
+
+func TestShortcutReachesSSH(t *testing.T) {
+	useMockExec(t)
+	useWebGroup(t)
+	useShortcuts(t, map[string]string{"w1": "web-1"})
+	require.NoError(t, rootCmd.RunE(rootCmd, []string{"w1", "uptime"}))
+	args := mockRun("ssh")
+	require.NotNil(t, args)
+	assert.Contains(t, args, "web-1")
+	assert.NotContains(t, args, "w1", "ssh only sees the Host alias")
+}
+
+func TestExpandJumpShortcuts(t *testing.T) {
+	useWebGroup(t)
+	useShortcuts(t, map[string]string{"bh": "web-1"})
+	assert.Equal(t, "web-1,admin@web-1:2222,other", expandJumpShortcuts("bh,admin@bh:2222,other"))
+	assert.Equal(t, "", expandJumpShortcuts(""))
+}
+
+func TestValidateShortcut(t *testing.T) {
+	useWebGroup(t)
+	assert.NoError(t, validateShortcut("w1"))
+	for name, want := range map[string]string{
+		"":      "needs a name",
+		"-w":    "would read as a flag",
+		"a,b":   "list, group or pattern",
+		"@web":  "list, group or pattern",
+		"w*":    "list, group or pattern",
+		"3":     "--numbered index",
+		"web-1": "already a Host",
+		"list":  "is a gt command",
+		"ls":    "",
+	} {
+		err := validateShortcut(name)
+		if want == "" {
+			assert.NoError(t, err, name)
+			continue
+		}
+		assert.ErrorContains(t, err, want, name)
+	}
+}
+
+func TestAliasAddListRm(t *testing.T) {
+	useWebGroup(t)
+	plainOutput(t)
+	path := useShortcuts(t, nil)
+
+	require.NoError(t, aliasAddCmd.RunE(aliasAddCmd, []string{"w1", "web-1"}))
+	require.NoError(t, aliasAddCmd.RunE(aliasAddCmd, []string{"w2", "w1"}), "a shortcut's host is expanded")
+	require.NoError(t, aliasAddCmd.RunE(aliasAddCmd, []string{"w1", "web-2"}))
+	assert.Error(t, aliasAddCmd.RunE(aliasAddCmd, []string{"x", "nope"}))
+	assert.Error(t, aliasAddCmd.RunE(aliasAddCmd, []string{"web-1", "web-2"}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, shortcutsHeader+"w1: web-2\nw2: web-1\n", string(data))
+	loaded, err := loadShortcuts(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"w1": "web-2", "w2": "web-1"}, loaded)
+	assert.Equal(t, loaded, shortcuts, "in effect at once")
+
+	var b bytes.Buffer
+	aliasListCmd.SetOut(&b)
+	defer aliasListCmd.SetOut(nil)
+	require.NoError(t, aliasListCmd.RunE(aliasListCmd, nil))
+	assert.Equal(t, "w1  web-2\nw2  web-1\n", b.String())
+	assert.Equal(t, []string{"w1\tweb-2", "w2\tweb-1"}, shortcutDescriptions())
+
+	err = aliasRmCmd.RunE(aliasRmCmd, []string{"w1", "zz"})
+	assert.EqualError(t, err, "no alias zz")
+	require.NoError(t, aliasRmCmd.RunE(aliasRmCmd, []string{"w2"}))
+	data, _ = os.ReadFile(path)
+	assert.Equal(t, shortcutsHeader, string(data))
+	b.Reset()
+	require.NoError(t, aliasListCmd.RunE(aliasListCmd, nil))
+	assert.Equal(t, "No aliases; add one with gt alias add <name> <host>\n", b.String())
+}
+
+func TestLoadShortcutsMissing(t *testing.T) {
+	m, err := loadShortcuts(filepath.Join(t.TempDir(), "aliases.yaml"))
+	assert.NoError(t, err)
+	assert.Empty(t, m)
+}
@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gt/pkg/transport"
+)
+
+// otpPromptRe matches the keyboard-interactive prompts of the common
+// one-time code setups: Google Authenticator's "Verification code:",
+// Duo's "Passcode", and the "One-time password", "OTP" and "Token code"
+// of the rest. "Password:" is not one of them.
+var otpPromptRe = regexp.MustCompile(`(?i)verification code|one[- ]time (password|code)|\botp\b|token code|passcode|authenticator code|two[- ]factor|\b2fa\b`)
+
+// otpPrompt reports whether an ssh prompt asks for a one-time code.
+func otpPrompt(prompt string) bool {
+	return otpPromptRe.MatchString(prompt)
+}
+
+// passwordPrompt reports whether an ssh prompt asks for the account's
+// password: ssh's own "user@host's password:", or a keyboard-interactive
+// "Password:". Key passphrases and one-time passwords are not that.
+func passwordPrompt(prompt string) bool {
+	return strings.Contains(strings.ToLower(prompt), "password") && !otpPrompt(prompt)
+}
+
+// passwordCmd is --password-cmd, the command that prints the password
+// for every host of the run, over each host's password_command.
+var passwordCmd string
+
+// passwordCommand is the command that prints the password for alias, or
+// "" for a host that authenticates some other way.
+func passwordCommand(alias string) string {
+	if passwordCmd != "" {
+		return passwordCmd
+	}
+	return hostMetaFor(alias).PasswordCommand
+}
+
+// passwordOptions adjusts o for a host whose password gt supplies: one
+// attempt, so a wrong password does not cost a legacy device's lockout
+// count three times over. A run that must not prompt keeps password
+// authentication, which BatchMode would turn off, and leaves out
+// keyboard-interactive, the one method that could still ask someone.
+func passwordOptions(o transport.Options, alias string) transport.Options {
+	if passwordCommand(alias) == "" {
+		return o
+	}
+	overrides := append([]string(nil), o.Overrides...)
+	if o.Batch {
+		o.Batch = false
+		overrides = append(overrides, "PreferredAuthentications=publickey,password")
+	}
+	o.Overrides = append(overrides, "NumberOfPasswordPrompts=1")
+	return o
+}
+
+// askpassScripts caches the askpass script written for each host, so a
+// command that runs ssh many times writes it once.
+var askpassScripts sync.Map
+
+// askpassTargets is the user@host forms ssh's prompts name alias by:
+// its resolved user with the HostName, a HostKeyAlias (which
+// keyboard-interactive prompts name instead; see fallbackOptions) and
+// each fallback address.
+func askpassTargets(alias string) ([]string, error) {
+	r, opts, err := resolveHostOptions(alias)
+	if err != nil {
+		return nil, err
+	}
+	fallbacks := hostMetaFor(alias).FallbackAddresses
+	hosts := []string{r.Hostname}
+	if a := opts["hostkeyalias"]; len(a) > 0 && a[0] != "" && a[0] != "none" {
+		hosts = append(hosts, a[0])
+	} else if name := knownHostsName(r.Hostname, r.Port); len(fallbacks) > 0 && name != r.Hostname {
+		// The HostKeyAlias a fallback connection is given.
+		hosts = append(hosts, name)
+	}
+	hosts = append(hosts, fallbacks...)
+	targets := make([]string, len(hosts))
+	for i, h := range hosts {
+		targets[i] = r.User + "@" + strings.ToLower(h)
+	}
+	return targets, nil
+}
+
+// promptFor reports whether an ssh prompt is for one of targets, a
+// GT_ASKPASS_TARGET list. ssh names the destination as "user@host's
+// password:" and, since OpenSSH 8.4, as "(user@host)" ahead of a
+// keyboard-interactive prompt; a prompt that names none, or names
+// another host, is not the target's. A ProxyJump's ssh inherits the
+// helper, and must not be handed the target's password or code.
+func promptFor(targets, prompt string) bool {
+	dest, ok := strings.CutSuffix(strings.TrimRight(prompt, " "), "'s password:")
+	if !ok {
+		rest, found := strings.CutPrefix(prompt, "(")
+		if dest, _, ok = strings.Cut(rest, ") "); !found || !ok {
+			return false
+		}
+	}
+	for _, t := range strings.Fields(targets) {
+		if strings.EqualFold(t, dest) {
+			return true
+		}
+	}
+	return false
+}
+
+// askpassScript writes the script ssh runs as SSH_ASKPASS for alias: gt
+// askpass, with what it needs to know about the host in its environment.
+// SSH_ASKPASS names a program without arguments, hence the script. ssh's
+// environment carries it to whatever ssh starts, the ssh -W of a
+// ProxyJump included, so the helper is told the host it answers for in
+// GT_ASKPASS_TARGET and leaves the prompts of any other to the user.
+func askpassScript(alias string, m hostMeta) (string, error) {
+	if p, ok := askpassScripts.Load(alias); ok {
+		return p.(string), nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	targets, err := askpassTargets(alias)
+	if err != nil {
+		return "", err
+	}
+	env := []string{"GT_OTP_HOST=" + alias, "GT_ASKPASS_TARGET=" + strings.Join(targets, " ")}
+	if m.OTPCommand != "" {
+		env = append(env, "GT_OTP_COMMAND="+m.OTPCommand)
+	}
+	if m.OTPAuto {
+		env = append(env, "GT_OTP_AUTO=1")
+	}
+	if pw := passwordCommand(alias); pw != "" {
+		// The command, never the password: that goes from the command
+		// to ssh through a pipe, and nowhere else.
+		env = append(env, "GT_PASSWORD_COMMAND="+pw)
+	}
+	if keys := keychainKeys(); len(keys) > 0 {
+		dir, err := ensureRuntimeDir()
+		if err != nil {
+			return "", err
+		}
+		env = append(env, "GT_KEYCHAIN_KEYS="+strings.Join(keys, string(os.PathListSeparator)), "GT_ASKPASS_DIR="+dir)
+	}
+	if nonInteractive() {
+		env = append(env, "GT_NONINTERACTIVE=1")
+	}
+	script := "#!/bin/sh\n" + quoteArgv(env) + " exec " + quoteArgv([]string{exe, "askpass", "--"}) + ` "$@"` + "\n"
+	path, err := writeRuntimeFile("askpass-*", []byte(script))
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chmod(path, 0o700); err != nil {
+		return "", err
+	}
+	askpassScripts.Store(alias, path)
+	if pw := passwordCommand(alias); pw != "" {
+		warningColor.Fprintf(os.Stderr, "%s: logging in with the password %s prints; a key is safer where the host takes one\n", alias, pw)
+	}
+	return path, nil
+}
+
+// withAskpass has ssh (or scp, through the ssh it starts) ask gt for what
+// it prompts for when alias has an otp_command or a password command, or
+// keys have their passphrase in the keychain, so gt can fill the one-time
+// code, the password or the passphrase.
+// SSH_ASKPASS_REQUIRE=force needs OpenSSH 8.4; older versions ignore it
+// and prompt on the terminal as they always have. Without either, or
+// where the helper cannot be set up, cmd is left alone.
+func withAskpass(cmd *exec.Cmd, alias string) *exec.Cmd {
+	m := hostMetaFor(alias)
+	if m.OTPCommand == "" && passwordCommand(alias) == "" && len(gtCfg.KeychainKeys) == 0 {
+		return cmd
+	}
+	if runtime.GOOS == "windows" {
+		debugf(1, "%s: otp_command and password_command are not supported on Windows", alias)
+		return cmd
+	}
+	script, err := askpassScript(alias, m)
+	if err != nil {
+		debugf(1, "%s: not answering ssh's prompts: %v", alias, err)
+		return cmd
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "SSH_ASKPASS="+script, "SSH_ASKPASS_REQUIRE=force")
+	return cmd
+}
+
+// fillOTP runs the host's otp_command for the code a prompt asks for,
+// once the user agrees to it on p; a host with otp_auto has agreed
+// already. ok is false when gt should not fill the prompt, and the user
+// answers it instead.
+func fillOTP(p *prompter, alias, command string, auto bool) (code string, ok bool, err error) {
+	if !auto {
+		if nonInteractive() {
+			return "", false, nil
+		}
+		if !p.confirm(fmt.Sprintf("Fill the one-time code for %s from %s?", alias, command), true) {
+			return "", false, nil
+		}
+	}
+	c := execCommand("sh", "-c", command)
+	c.Stderr = p.out
+	out, err := c.Output()
+	if err != nil {
+		return "", false, fmt.Errorf("otp_command %s: %w", command, err)
+	}
+	code, _, _ = strings.Cut(string(out), "\n")
+	code = strings.TrimSpace(code)
+	if code == "" || strings.ContainsAny(code, " \t") {
+		return "", false, fmt.Errorf("otp_command %s did not print a one-time code", command)
+	}
+	if auto {
+		fmt.Fprintf(p.out, "Filled the one-time code for %s from %s\n", alias, command)
+	}
+	return code, true, nil
+}
+
+// fetchPassword runs a password command for the password it prints on its
+// first line, trailing spaces and all; only the line break is dropped.
+func fetchPassword(command string, stderr io.Writer) (string, error) {
+	c := execCommand("sh", "-c", command)
+	c.Stderr = stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("password command %s: %w", command, err)
+	}
+	password, _, _ := strings.Cut(string(out), "\n")
+	password = strings.TrimSuffix(password, "\r")
+	if password == "" {
+		return "", fmt.Errorf("password command %s printed no password", command)
+	}
+	return password, nil
+}
+
+// listedKey reports whether key is among keys, a GT_KEYCHAIN_KEYS list.
+func listedKey(keys, key string) bool {
+	for _, k := range filepath.SplitList(keys) {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// openTTY opens the controlling terminal, where the askpass helper talks
+// to the user: its stdin and stdout are ssh's.
+func openTTY() (*os.File, error) {
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+// readAnswer asks prompt on tty as ssh would: hidden, unless it is a
+// yes/no question such as the one about an unknown host key.
+func readAnswer(tty *os.File, prompt string) (string, error) {
+	fmt.Fprint(tty, prompt)
+	if strings.Contains(prompt, "yes/no") {
+		line, err := bufio.NewReader(tty).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	secret, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	return string(secret), err
+}
+
+var askpassCmd = &cobra.Command{
+	Use:    "askpass <prompt>",
+	Short:  "Answer ssh's prompts from a host's otp_command or password command, or the keychain",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	// ssh runs this with nothing but the prompt; gt's config is not
+	// needed, and the host's settings come in the environment.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		prompt := args[0]
+		tty, err := openTTY()
+		if err == nil {
+			defer tty.Close()
+		}
+		if key, ok := passphraseKey(prompt); ok && listedKey(os.Getenv("GT_KEYCHAIN_KEYS"), key) {
+			passphrase, err := keychainPassphrase(key, os.Getenv("GT_ASKPASS_DIR"))
+			if err == nil {
+				fmt.Fprintln(cmd.OutOrStdout(), passphrase)
+				return nil
+			}
+			if tty != nil {
+				warningColor.Fprintf(tty, "%v\n", err)
+			}
+		}
+		forTarget := promptFor(os.Getenv("GT_ASKPASS_TARGET"), prompt)
+		if pw := os.Getenv("GT_PASSWORD_COMMAND"); pw != "" && forTarget && passwordPrompt(prompt) {
+			password, err := fetchPassword(pw, os.Stderr)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), password)
+			return nil
+		}
+		if os.Getenv("GT_OTP_COMMAND") != "" && forTarget && otpPrompt(prompt) {
+			auto := os.Getenv("GT_OTP_AUTO") != ""
+			p := &prompter{out: os.Stderr}
+			if tty != nil {
+				p = &prompter{in: bufio.NewReader(tty), out: tty}
+			} else if !auto {
+				return errors.New("no terminal to ask on")
+			}
+			code, ok, err := fillOTP(p, os.Getenv("GT_OTP_HOST"), os.Getenv("GT_OTP_COMMAND"), auto)
+			if err != nil {
+				errorColor.Fprintf(p.out, "%v\n", err)
+			}
+			if ok {
+				fmt.Fprintln(cmd.OutOrStdout(), code)
+				return nil
+			}
+		}
+		if tty == nil {
+			return errors.New("no terminal to ask on")
+		}
+		answer, err := readAnswer(tty, prompt)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), answer)
+		return nil
+	},
+}
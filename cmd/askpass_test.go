@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/transport"
+)
+
+func TestOTPPrompt(t *testing.T) {
+	for prompt, want := range map[string]bool{
+		"Verification code: ":                   true,
+		"Passcode or option (1-2): ":            true,
+		"One-time password (OATH) for `deploy'": true,
+		"Enter OTP: ":                           true,
+		"Password: ":                            false,
+		"deploy@web's password: ":               false,
+		"Enter passphrase for key '/home/me/.ssh/id_ed25519': ": false,
+	} {
+		assert.Equal(t, want, otpPrompt(prompt), prompt)
+	}
+}
+
+func TestWithAskpass(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("otp_command is not supported on Windows")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Cleanup(removeRuntimeDir)
+	t.Cleanup(func() { askpassScripts = sync.Map{} })
+	useMockExec(t)
+	useWebGroup(t)
+	gtCfg.Hosts["web-1"] = hostMeta{OTPCommand: "pass otp work", OTPAuto: true}
+
+	assert.Nil(t, withAskpass(exec.Command("ssh"), "web-2").Env, "a host without otp_command is left alone")
+
+	c := withAskpass(exec.Command("ssh"), "web-1")
+	assert.Contains(t, c.Env, "SSH_ASKPASS_REQUIRE=force")
+	var script string
+	for _, e := range c.Env {
+		if v, ok := strings.CutPrefix(e, "SSH_ASKPASS="); ok {
+			script = v
+		}
+	}
+	require.NotEmpty(t, script)
+	data, err := os.ReadFile(script)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "GT_OTP_HOST=web-1 GT_ASKPASS_TARGET=testuser@test.example.com 'GT_OTP_COMMAND=pass otp work' GT_OTP_AUTO=1 exec ")
+	assert.Contains(t, string(data), ` askpass -- "$@"`)
+	info, err := os.Stat(script)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	again := withAskpass(exec.Command("ssh"), "web-1")
+	assert.Contains(t, again.Env, "SSH_ASKPASS="+script, "the script is written once per host")
+
+	gtCfg.KeychainKeys = []string{"/k/id_locked"}
+	c = withAskpass(exec.Command("ssh"), "web-2")
+	require.NotNil(t, c.Env, "a key in the keychain is answered for on every host")
+	data, err = os.ReadFile(askpassScriptFor(t, "web-2"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "GT_KEYCHAIN_KEYS="+filepath.Clean("/k/id_locked")+" GT_ASKPASS_DIR=")
+}
+
+func TestPromptFor(t *testing.T) {
+	useMockExec(t)
+	origCfg, origGT := cfg, gtCfg
+	t.Cleanup(func() { cfg, gtCfg = origCfg, origGT })
+	decoded, err := ssh_config.Decode(strings.NewReader("Host app\n  ProxyJump deploy@bastion\n"))
+	require.NoError(t, err)
+	cfg = decoded
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"app": {FallbackAddresses: []string{"192.0.2.7"}}}}
+
+	// The bastion's ssh -W inherits the helper; only app's prompts are
+	// answered.
+	targets, err := askpassTargets("app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testuser@test.example.com", "testuser@[test.example.com]:2222", "testuser@192.0.2.7"}, targets)
+	list := strings.Join(targets, " ")
+	for prompt, want := range map[string]bool{
+		"testuser@test.example.com's password: ":          true,
+		"testuser@192.0.2.7's password: ":                 true,
+		"(testuser@TEST.example.com) Verification code: ": true,
+		"(testuser@[test.example.com]:2222) Password: ":   true,
+		"deploy@bastion's password: ":                     false,
+		"(deploy@bastion) Verification code: ":            false,
+		"Password: ":                                      false,
+		"Verification code: ":                             false,
+	} {
+		assert.Equal(t, want, promptFor(list, prompt), prompt)
+	}
+}
+
+func TestFillOTP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh")
+	}
+	t.Setenv("GT_NONINTERACTIVE", "")
+	plainOutput(t)
+	ask := func(answer string) (*prompter, *bytes.Buffer) {
+		var out bytes.Buffer
+		return &prompter{in: bufio.NewReader(strings.NewReader(answer)), out: &out}, &out
+	}
+
+	p, out := ask("\n")
+	code, ok, err := fillOTP(p, "web", "echo 123456", false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "123456", code)
+	assert.Contains(t, out.String(), "Fill the one-time code for web from echo 123456? [Y/n]")
+
+	p, _ = ask("n\n")
+	_, ok, err = fillOTP(p, "web", "echo 123456", false)
+	require.NoError(t, err)
+	assert.False(t, ok, "no consent, no code")
+
+	p, out = ask("")
+	code, ok, err = fillOTP(p, "web", "printf '654321\\nextra\\n'", true)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "654321", code)
+	assert.NotContains(t, out.String(), "[Y/n]", "otp_auto does not ask")
+
+	p, _ = ask("")
+	_, ok, err = fillOTP(p, "web", "true", true)
+	assert.ErrorContains(t, err, "did not print a one-time code")
+	assert.False(t, ok)
+
+	t.Setenv("GT_NONINTERACTIVE", "1")
+	p, out = ask("y\n")
+	_, ok, err = fillOTP(p, "web", "echo 123456", false)
+	require.NoError(t, err)
+	assert.False(t, ok, "without a way to ask, only otp_auto fills")
+	assert.Empty(t, out.String())
+}
+
+func TestPasswordPrompt(t *testing.T) {
+	for prompt, want := range map[string]bool{
+		"admin@switch-1's password: ":                           true,
+		"Password: ":                                            true,
+		"One-time password (OATH) for `deploy'":                 false,
+		"Enter passphrase for key '/home/me/.ssh/id_ed25519': ": false,
+	} {
+		assert.Equal(t, want, passwordPrompt(prompt), prompt)
+	}
+}
+
+func TestPasswordOptions(t *testing.T) {
+	useWebGroup(t)
+	t.Cleanup(func() { passwordCmd = "" })
+	gtCfg.Hosts["web-1"] = hostMeta{PasswordCommand: "op read op://infra/web-1/password"}
+
+	o := transport.Options{Batch: true, Overrides: []string{"ServerAliveInterval=30"}}
+	assert.Equal(t, o, passwordOptions(o, "web-2"), "a host that takes a key is left alone")
+
+	got := passwordOptions(o, "web-1")
+	assert.False(t, got.Batch, "BatchMode would turn password authentication off")
+	assert.Equal(t, []string{"ServerAliveInterval=30", "PreferredAuthentications=publickey,password", "NumberOfPasswordPrompts=1"}, got.Overrides)
+	assert.Equal(t, []string{"ServerAliveInterval=30"}, o.Overrides, "the caller's options are left alone")
+
+	got = passwordOptions(transport.Options{}, "web-1")
+	assert.Equal(t, []string{"NumberOfPasswordPrompts=1"}, got.Overrides)
+
+	passwordCmd = "pass show switch"
+	assert.Equal(t, "pass show switch", passwordCommand("web-1"), "--password-cmd wins")
+	assert.Equal(t, "pass show switch", passwordCommand("web-2"))
+	assert.False(t, remoteOpts{batch: true}.transport("web-2", 0).Batch, "gt exec keeps password authentication")
+}
+
+func TestFetchPassword(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh")
+	}
+	var stderr bytes.Buffer
+	pw, err := fetchPassword(`printf 'hunter2 \n'`, &stderr)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2 ", pw, "only the line break is dropped")
+
+	_, err = fetchPassword("true", &stderr)
+	assert.ErrorContains(t, err, "printed no password")
+	_, err = fetchPassword("echo locked >&2; exit 1", &stderr)
+	assert.ErrorContains(t, err, "password command echo locked >&2; exit 1")
+	assert.Contains(t, stderr.String(), "locked")
+}
+
+func TestRunSSHPasswordCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("password_command is not supported on Windows")
+	}
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Cleanup(removeRuntimeDir)
+	t.Cleanup(func() { askpassScripts = sync.Map{} })
+	useMockExec(t)
+	useWebGroup(t)
+	plainOutput(t)
+	gtCfg.Hosts["testserver"] = hostMeta{PasswordCommand: "op read op://infra/switch/password"}
+
+	require.NoError(t, runSSH("testserver", nil))
+	assert.Equal(t, []string{"-o", "NumberOfPasswordPrompts=1", "--", "testserver"}, mockRun("ssh"))
+
+	script := askpassScriptFor(t, "testserver")
+	data, err := os.ReadFile(script)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "'GT_PASSWORD_COMMAND=op read op://infra/switch/password'")
+	assert.NotContains(t, string(data), "GT_OTP_COMMAND")
+}
+
+// askpassScriptFor is the askpass script gt wrote for alias.
+func askpassScriptFor(t *testing.T, alias string) string {
+	t.Helper()
+	p, ok := askpassScripts.Load(alias)
+	require.True(t, ok, "no askpass script for %s", alias)
+	return p.(string)
+}
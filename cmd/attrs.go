@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"strings"
+)
+
+var (
+	followLinks   bool
+	preserveLinks bool
+	noPerms       bool
+	noTimes       bool
+	copyXattrs    bool
+)
+
+// xattrPrefix marks an extended attribute among a tar header's PAX
+// records, as GNU tar and bsdtar write them.
+const xattrPrefix = "SCHILY.xattr."
+
+// validateAttrs checks the symlink and attribute flags against what
+// --engine can do. tar, which gt drives itself, honors them all; scp and
+// sftp keep modes and times together (-p) or not at all, follow
+// symlinks, and know nothing of extended attributes.
+func validateAttrs() error {
+	if followLinks && preserveLinks {
+		return errors.New("--follow-links and --preserve-links contradict each other")
+	}
+	if transferEngine == "tar" {
+		if copyXattrs && runtime.GOOS != "linux" {
+			return errors.New("--xattrs needs Linux, where gt reads and writes them")
+		}
+		return nil
+	}
+	switch {
+	case preserveLinks:
+		return fmt.Errorf("--engine %s copies what symlinks point to; --preserve-links needs --engine tar", transferEngine)
+	case followLinks && transferEngine == "sftp":
+		return errors.New("--engine sftp has no symlink options; --follow-links needs --engine scp or tar")
+	case noPerms != noTimes:
+		return fmt.Errorf("--engine %s keeps modes and times together (-p): pass both --no-perms and --no-times, or use --engine tar", transferEngine)
+	case copyXattrs:
+		return fmt.Errorf("--engine %s cannot copy extended attributes; --xattrs needs --engine tar", transferEngine)
+	}
+	return nil
+}
+
+// plainMode is the mode a copy gets under --no-perms: 0644, or 0755 for
+// a directory or a file anyone may run, whatever else the original had.
+func plainMode(mode fs.FileMode) fs.FileMode {
+	if mode.IsDir() || mode&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
+
+// tarRecords adds path's extended attributes to records, for --xattrs.
+func tarRecords(records map[string]string, path string) (map[string]string, error) {
+	attrs, err := readXattrs(path)
+	if err != nil {
+		return records, fmt.Errorf("reading the extended attributes of %s: %w", path, err)
+	}
+	for name, value := range attrs {
+		if records == nil {
+			records = map[string]string{}
+		}
+		records[xattrPrefix+name] = value
+	}
+	return records, nil
+}
+
+// applyRecords sets the extended attributes among a tar header's PAX
+// records on path, for --xattrs.
+func applyRecords(path string, records map[string]string) error {
+	for key, value := range records {
+		if name, ok := strings.CutPrefix(key, xattrPrefix); ok {
+			if err := writeXattr(path, name, value); err != nil {
+				return fmt.Errorf("setting %s on %s: %w", name, path, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useAttrs sets the symlink and attribute flags for a test.
+func useAttrs(t *testing.T, follow, preserve, perms, times, xattrs bool) {
+	t.Helper()
+	t.Cleanup(func() { followLinks, preserveLinks, noPerms, noTimes, copyXattrs = false, false, false, false, false })
+	followLinks, preserveLinks, noPerms, noTimes, copyXattrs = follow, preserve, perms, times, xattrs
+}
+
+func TestValidateAttrs(t *testing.T) {
+	useEngine(t, "tar", 0, 0)
+	useAttrs(t, true, false, true, false, false)
+	assert.NoError(t, validateAttrs(), "tar takes them one by one")
+	useAttrs(t, true, true, false, false, false)
+	assert.ErrorContains(t, validateAttrs(), "contradict")
+
+	useEngine(t, "scp", 0, 0)
+	useAttrs(t, true, false, true, true, false)
+	assert.NoError(t, validateAttrs(), "scp follows links and can drop -p")
+	useAttrs(t, false, true, false, false, false)
+	assert.ErrorContains(t, validateAttrs(), "--preserve-links needs --engine tar")
+	useAttrs(t, false, false, false, true, false)
+	assert.ErrorContains(t, validateAttrs(), "keeps modes and times together")
+	useAttrs(t, false, false, false, false, true)
+	assert.ErrorContains(t, validateAttrs(), "--xattrs needs --engine tar")
+
+	useEngine(t, "sftp", 0, 0)
+	useAttrs(t, true, false, false, false, false)
+	assert.ErrorContains(t, validateAttrs(), "no symlink options")
+}
+
+func TestPlainMode(t *testing.T) {
+	assert.Equal(t, os.FileMode(0o644), plainMode(0o600))
+	assert.Equal(t, os.FileMode(0o755), plainMode(0o700))
+	assert.Equal(t, os.FileMode(0o755), plainMode(os.ModeDir|0o700))
+}
+
+func TestNoPreserveSCP(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useAttrs(t, false, false, true, true, false)
+	require.NoError(t, runSCP("testserver", []string{"local.txt", ":remote/path"}))
+	assert.Equal(t, []string{"--", "local.txt", "testserver:remote/path"}, mockRun("scp"))
+}
+
+func TestCollectUploadLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared")
+	require.NoError(t, os.MkdirAll(shared, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(shared, "lib.sh"), []byte("echo\n"), 0o644))
+	app := filepath.Join(dir, "app")
+	require.NoError(t, os.MkdirAll(app, 0o755))
+	require.NoError(t, os.Symlink(shared, filepath.Join(app, "lib")))
+	current := filepath.Join(dir, "current")
+	require.NoError(t, os.Symlink(app, current))
+
+	names := func(entries []tarEntry) []string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.name)
+		}
+		return out
+	}
+	entries, _, err := collectUpload([]string{current}, transferFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"current", "current/lib"}, names(entries), "the source is followed, the link inside kept")
+
+	useAttrs(t, true, false, false, false, false)
+	entries, total, err := collectUpload([]string{current}, transferFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"current", "current/lib", "current/lib/lib.sh"}, names(entries))
+	assert.Equal(t, int64(len("echo\n")), total)
+
+	require.NoError(t, os.Symlink(app, filepath.Join(shared, "loop")))
+	_, _, err = collectUpload([]string{app}, transferFilter{})
+	assert.ErrorContains(t, err, "symlink loop")
+
+	useAttrs(t, false, true, false, false, false)
+	entries, _, err = collectUpload([]string{current}, transferFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.NotZero(t, entries[0].info.Mode()&os.ModeSymlink, "--preserve-links sends the source as a link")
+}
+
+func TestTarNoPermsNoTimes(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "bin")
+	require.NoError(t, os.MkdirAll(src, 0o700))
+	run := filepath.Join(src, "run")
+	require.NoError(t, os.WriteFile(run, []byte("#!/bin/sh\n"), 0o700))
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(run, old, old))
+
+	useAttrs(t, false, false, true, true, false)
+	entries, _, err := collectUpload([]string{src}, transferFilter{})
+	require.NoError(t, err)
+	var archive bytes.Buffer
+	require.NoError(t, writeTar(&archive, entries, nil))
+	dest := t.TempDir()
+	require.NoError(t, extractTar(&archive, dest, transferFilter{}))
+	info, err := os.Stat(filepath.Join(dest, "bin", "run"))
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0o755)&^umask(t), info.Mode().Perm())
+	}
+	assert.NotEqual(t, old, info.ModTime().UTC(), "--no-times leaves the time of the copy")
+
+	assert.Contains(t, tarUploadScript(":/srv"), "tar -m -xzf -")
+	useAttrs(t, true, false, false, false, true)
+	assert.Contains(t, tarUploadScript(":/srv"), "tar --xattrs -xzf -")
+	assert.Contains(t, tarDownloadScript([]string{":/srv/app"}), "tar -h --xattrs -czf -")
+}
+
+// umask is the process's umask, which files created in tests get.
+func umask(t *testing.T) os.FileMode {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "probe")
+	require.NoError(t, os.WriteFile(p, nil, 0o777))
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+	return 0o777 &^ info.Mode().Perm()
+}
+
+func TestTarXattrs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("extended attributes are Linux-only")
+	}
+	src := filepath.Join(t.TempDir(), "tagged")
+	require.NoError(t, os.WriteFile(src, []byte("x"), 0o644))
+	if err := writeXattr(src, "user.gt.test", "blue"); err != nil {
+		t.Skipf("no user xattrs here: %v", err)
+	}
+	useEngine(t, "tar", 0, 0)
+	useAttrs(t, false, false, false, false, true)
+	entries, _, err := collectUpload([]string{src}, transferFilter{})
+	require.NoError(t, err)
+	var archive bytes.Buffer
+	require.NoError(t, writeTar(&archive, entries, nil))
+	dest := t.TempDir()
+	require.NoError(t, extractTar(&archive, dest, transferFilter{}))
+	attrs, err := readXattrs(filepath.Join(dest, "tagged"))
+	require.NoError(t, err)
+	assert.Equal(t, "blue", attrs["user.gt.test"])
+}
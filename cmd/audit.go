@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -24,35 +27,52 @@ type auditEntry struct {
 	DurationMS int64     `json:"duration_ms"`
 }
 
-// auditLogPath resolves the audit log location. GT_LOG_DIR wins (used by
-// tests); then XDG_STATE_HOME per the XDG spec; then the conventional
-// ~/.local/state fallback. Logs are state, not config or cache.
-func auditLogPath() (string, error) {
+// stateDir resolves gt's state directory. GT_LOG_DIR wins (used by
+// tests); then XDG_STATE_HOME per the XDG spec; then %LocalAppData% on
+// Windows and the conventional ~/.local/state fallback elsewhere. Logs
+// are state, not config or cache.
+func stateDir() (string, error) {
 	if dir := os.Getenv("GT_LOG_DIR"); dir != "" {
-		return filepath.Join(dir, "connections.jsonl"), nil
+		return dir, nil
 	}
 	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
-		return filepath.Join(dir, "gt", "connections.jsonl"), nil
+		return filepath.Join(dir, "gt"), nil
+	}
+	if runtime.GOOS == "windows" {
+		// UserCacheDir is %LocalAppData% there: machine-local, unlike
+		// the roaming %AppData%, which is right for a connection log.
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "gt"), nil
 	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".local", "state", "gt", "connections.jsonl"), nil
+	return filepath.Join(home, ".local", "state", "gt"), nil
+}
+
+// auditLogPath resolves the audit log location inside the state directory.
+func auditLogPath() (string, error) {
+	return statePath("connections.jsonl")
 }
 
 // appendAuditEntry serializes one entry as JSON and appends it as a single
-// line. O_APPEND keeps concurrent writes from different gt invocations from
-// interleaving as long as the line stays under PIPE_BUF (4 KiB on darwin
-// and linux), which a metadata-only entry comfortably does.
+// line. O_APPEND alone keeps concurrent writes from different gt
+// invocations from interleaving only while a line stays under PIPE_BUF, and
+// not on every filesystem, so the append also holds the log's lock.
 func appendAuditEntry(e auditEntry) error {
 	path, err := auditLogPath()
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+	release, err := lockState("connections.jsonl")
+	if err != nil {
 		return err
 	}
+	defer release()
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
 		return err
@@ -73,35 +93,55 @@ func appendAuditEntry(e auditEntry) error {
 // still identifies the connection.
 func auditAddress(alias string) string {
 	r, err := resolveHost(alias)
-	if err != nil || r.hostname == "" {
+	if err != nil || r.Hostname == "" {
 		return alias
 	}
-	if r.user == "" {
-		return r.hostname
+	if r.User == "" {
+		return r.Hostname
 	}
-	return r.user + "@" + r.hostname
+	return r.User + "@" + r.Hostname
 }
 
 // runCommandLogged wraps runCommand with timing and audit-log emission.
 // Auditing is best-effort: if the log write fails (disk full, perms,
 // missing parent) we surface a warning but do not fail the connection.
+// A run that failed on a changed host key comes back as a hostKeyError.
 func runCommandLogged(cmd *exec.Cmd, alias, mode string) error {
 	start := time.Now()
+	var tail tailBuffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &tail)
 	err := runCommand(cmd)
-	if noLog {
-		return err
+	logConnection(alias, mode, start, err)
+	err = classifyRun(alias, mode, err, tail.String())
+	if c, ok := parseHostKeyChange(tail.String()); ok && err != nil {
+		return &hostKeyError{change: c, err: err}
 	}
-	end := time.Now()
+	return err
+}
 
-	exitCode := 0
-	if err != nil {
-		var ee *exec.ExitError
-		if errors.As(err, &ee) {
-			exitCode = ee.ExitCode()
-		} else {
-			exitCode = -1 // command did not run cleanly (binary missing, etc.)
-		}
+// exitCodeOf is the exit status behind err from running a command: 0 for
+// success, -1 when the command did not run cleanly (binary missing,
+// killed by a signal).
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode()
+	}
+	return -1
+}
+
+// logConnection records a connection that started at start and ended
+// now with err, for commands that run ssh some other way than through
+// runCommand.
+func logConnection(alias, mode string, start time.Time, err error) {
+	if noLog {
+		return
 	}
+	end := time.Now()
+	exitCode := exitCodeOf(err)
 
 	if logErr := appendAuditEntry(auditEntry{
 		Start:      start,
@@ -114,7 +154,6 @@ func runCommandLogged(cmd *exec.Cmd, alias, mode string) error {
 	}); logErr != nil {
 		warningColor.Fprintf(os.Stderr, "Could not write audit log: %v\n", logErr)
 	}
-	return err
 }
 
 var logLimit int
@@ -124,7 +163,8 @@ var logCmd = &cobra.Command{
 	Short: "Show recent connections from the audit log",
 	Long: `Show recent connections from the local audit log at
 $XDG_STATE_HOME/gt/connections.jsonl (or ~/.local/state/gt/connections.jsonl).
-Each line is one connection: timestamp, alias, address, mode, duration, exit code.`,
+Each line is one connection: timestamp, alias, address, mode, duration, exit code.
+GT_REDACT=1 masks the host part of each address.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		path, err := auditLogPath()
 		if err != nil {
@@ -140,36 +180,69 @@ Each line is one connection: timestamp, alias, address, mode, duration, exit cod
 		}
 		defer f.Close()
 
-		var entries []auditEntry
-		dec := json.NewDecoder(f)
-		for dec.More() {
-			var e auditEntry
-			if err := dec.Decode(&e); err != nil {
-				continue // skip malformed lines so a partial write does not poison the view
-			}
-			entries = append(entries, e)
-		}
+		entries := decodeAuditEntries(f)
 		if logLimit > 0 && len(entries) > logLimit {
 			entries = entries[len(entries)-logLimit:]
 		}
+		var out bytes.Buffer
 		for _, e := range entries {
-			renderAuditEntry(e)
+			renderAuditEntry(&out, e)
 		}
-		return nil
+		return pageOutput(out.Bytes())
 	},
 }
 
-func renderAuditEntry(e auditEntry) {
-	symbolColor.Printf("%s  ", e.Start.Local().Format("2006-01-02 15:04:05"))
-	aliasColor.Printf("%-16s ", e.Alias)
-	userColor.Print(e.Address)
-	symbolColor.Printf("  %s  %s  ", e.Mode, formatDuration(e.DurationMS))
+// decodeAuditEntries reads the log's entries in order.
+func decodeAuditEntries(r io.Reader) []auditEntry {
+	var entries []auditEntry
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var e auditEntry
+		if err := dec.Decode(&e); err != nil {
+			continue // skip malformed lines so a partial write does not poison the view
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// lastConnections maps each alias in the audit log to when its latest
+// connection started. Best-effort, like the log: without one it is
+// empty.
+func lastConnections() map[string]time.Time {
+	last := map[string]time.Time{}
+	path, err := auditLogPath()
+	if err != nil {
+		return last
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return last
+	}
+	defer f.Close()
+	for _, e := range decodeAuditEntries(f) {
+		if e.Start.After(last[e.Alias]) {
+			last[e.Alias] = e.Start
+		}
+	}
+	return last
+}
+
+func renderAuditEntry(w io.Writer, e auditEntry) {
+	symbolColor.Fprintf(w, "%s  ", e.Start.Local().Format("2006-01-02 15:04:05"))
+	aliasColor.Fprintf(w, "%-16s ", e.Alias)
+	if redactEnabled() {
+		userColor.Fprint(w, redactAddress(e.Address))
+	} else {
+		userColor.Fprint(w, e.Address)
+	}
+	symbolColor.Fprintf(w, "  %s  %s  ", e.Mode, formatDuration(e.DurationMS))
 	if e.ExitCode == 0 {
-		userColor.Print("ok")
+		userColor.Fprint(w, "ok")
 	} else {
-		errorColor.Printf("exit %d", e.ExitCode)
+		errorColor.Fprintf(w, "exit %d", e.ExitCode)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 }
 
 func formatDuration(ms int64) string {
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/fatih/color"
+)
+
+var (
+	quiet   bool // --quiet: print only data and errors
+	noInput bool // --no-input; see nonInteractive
+)
+
+// nonInteractive reports whether gt must never wait on a person, as
+// under cron or CI: --no-input, or GT_NONINTERACTIVE set to anything but
+// a false value. Connections then run in BatchMode, prompts take their
+// defaults, and nothing pages or colors its output.
+func nonInteractive() bool {
+	if noInput {
+		return true
+	}
+	v := os.Getenv("GT_NONINTERACTIVE")
+	if v == "" {
+		return false
+	}
+	on, err := strconv.ParseBool(v)
+	return on || err != nil
+}
+
+// statusf prints a progress or confirmation message, which --quiet
+// suppresses. What a command exists to print, and warnings and errors,
+// are not status and go through regardless.
+func statusf(c *color.Color, format string, a ...any) {
+	if quiet {
+		return
+	}
+	c.Printf(format, a...)
+}
+
+// batchEnv keeps a tool gt runs from prompting on the terminal behind
+// its back when gt is non-interactive: git's credential prompt, and the
+// password and host-key prompts of the ssh that git runs.
+func batchEnv(c *exec.Cmd) *exec.Cmd {
+	if !nonInteractive() {
+		return c
+	}
+	if c.Env == nil {
+		c.Env = os.Environ()
+	}
+	c.Env = append(c.Env, "GIT_TERMINAL_PROMPT=0")
+	if _, ok := os.LookupEnv("GIT_SSH_COMMAND"); !ok {
+		c.Env = append(c.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	return c
+}
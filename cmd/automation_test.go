@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+// useNoInput turns on --no-input for one test.
+func useNoInput(t *testing.T) {
+	t.Helper()
+	orig := noInput
+	t.Cleanup(func() { noInput = orig })
+	noInput = true
+}
+
+func TestNonInteractiveEnv(t *testing.T) {
+	for v, want := range map[string]bool{"": false, "0": false, "false": false, "1": true, "true": true, "yes": true} {
+		t.Setenv("GT_NONINTERACTIVE", v)
+		assert.Equal(t, want, nonInteractive(), "GT_NONINTERACTIVE=%q", v)
+	}
+	t.Setenv("GT_NONINTERACTIVE", "")
+	useNoInput(t)
+	assert.True(t, nonInteractive())
+}
+
+func TestNoInputRunsSSHInBatchMode(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useNoInput(t)
+	if err := runSSH("test-server", []string{"uptime"}); err != nil {
+		t.Fatalf("runSSH: %v", err)
+	}
+	assert.Contains(t, mockCmd.argLists[0], "BatchMode=yes")
+}
+
+func TestQuietPassesQToSSHAndSilencesStatus(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	origQuiet, origOut := quiet, color.Output
+	t.Cleanup(func() { quiet, color.Output = origQuiet, origOut })
+	var out bytes.Buffer
+	color.Output = &out
+
+	quiet = true
+	if err := runSSH("test-server", nil); err != nil {
+		t.Fatalf("runSSH: %v", err)
+	}
+	assert.Contains(t, mockCmd.argLists[0], "-q")
+	statusf(symbolColor, "Wrote %d files\n", 3)
+	assert.Empty(t, out.String())
+
+	quiet = false
+	statusf(symbolColor, "Wrote %d files\n", 3)
+	assert.Contains(t, out.String(), "Wrote 3 files")
+}
+
+func TestBatchEnvStopsGitPrompts(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GIT_SSH_COMMAND", "")
+	os.Unsetenv("GIT_SSH_COMMAND")
+	assert.NotContains(t, batchEnv(execCommand("git", "push")).Env, "GIT_TERMINAL_PROMPT=0")
+
+	useNoInput(t)
+	env := batchEnv(execCommand("git", "push")).Env
+	assert.Contains(t, env, "GIT_TERMINAL_PROMPT=0")
+	assert.Contains(t, env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+}
+
+func TestNoInputInitSkipsKeyGeneration(t *testing.T) {
+	useMockExec(t)
+	useNoInput(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("SHELL", "/usr/bin/fish")
+	origCfgFile := cfgFile
+	t.Cleanup(func() { cfgFile = origCfgFile })
+	cfgFile = ""
+
+	var out bytes.Buffer
+	if err := runInit(rootCmd, &prompter{out: &out, yes: true}); err != nil {
+		t.Fatalf("runInit: %v", err)
+	}
+	assert.FileExists(t, filepath.Join(home, ".ssh", "config"))
+	assert.NotContains(t, mockCmd.commands, "ssh-keygen", "ssh-keygen would ask for a passphrase")
+}
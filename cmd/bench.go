@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchCount   int
+	benchControl bool
+)
+
+// benchSample is one timed connection. Each phase is measured from the
+// previous one's end; a phase ssh never reported (e.g. over a reused
+// ControlMaster connection) stays zero and is left out of the stats.
+type benchSample struct {
+	tcp, handshake, auth, total time.Duration
+}
+
+// benchMarkers are the ssh -v lines that end each phase.
+var benchMarkers = []struct {
+	prefix string
+	phase  func(*benchSample) *time.Duration
+}{
+	{"debug1: Connection established", func(s *benchSample) *time.Duration { return &s.tcp }},
+	{"debug1: SSH2_MSG_NEWKEYS received", func(s *benchSample) *time.Duration { return &s.handshake }},
+	{"Authenticated to ", func(s *benchSample) *time.Duration { return &s.auth }},
+}
+
+// phaseTimes reads ssh -v output as it is written and timestamps each
+// phase marker against start.
+func phaseTimes(r io.Reader, start time.Time, now func() time.Time) benchSample {
+	var s benchSample
+	last := start
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		for _, m := range benchMarkers {
+			if p := m.phase(&s); *p == 0 && strings.HasPrefix(line, m.prefix) {
+				t := now()
+				*p = t.Sub(last)
+				last = t
+			}
+		}
+	}
+	return s
+}
+
+// benchOnce runs "ssh -v alias true" in batch mode, so a prompt fails
+// the run instead of being timed.
+func benchOnce(alias string, extra []string) (benchSample, error) {
+	args := hostOptions(baseOptions(), alias).BaseArgs()
+	args = append(args, "-v", "-o", "BatchMode=yes")
+	args = append(args, extra...)
+	args = append(args, "--", alias, "true")
+	cmd := sshCommand(args...)
+	debugf(1, "exec: %s", quoteArgv(cmd.Args))
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return benchSample{}, err
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return benchSample{}, err
+	}
+	defer track(cmd.Process, false)()
+	s := phaseTimes(stderr, start, time.Now)
+	if err := cmd.Wait(); err != nil {
+		return s, fmt.Errorf("ssh %s: %w", alias, err)
+	}
+	s.total = time.Since(start)
+	return s, nil
+}
+
+// benchStats is min/avg/p95 over the samples that have the phase.
+type benchStats struct {
+	n             int
+	min, avg, p95 time.Duration
+}
+
+func summarize(ds []time.Duration) benchStats {
+	var kept []time.Duration
+	for _, d := range ds {
+		if d > 0 {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) == 0 {
+		return benchStats{}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i] < kept[j] })
+	var sum time.Duration
+	for _, d := range kept {
+		sum += d
+	}
+	// Nearest-rank percentile: the smallest sample at or above 95%.
+	rank := int(math.Ceil(0.95*float64(len(kept)))) - 1
+	return benchStats{
+		n:   len(kept),
+		min: kept[0],
+		avg: sum / time.Duration(len(kept)),
+		p95: kept[rank],
+	}
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+func renderBench(w io.Writer, title string, samples []benchSample) {
+	aliasColor.Fprintln(w, title)
+	fmt.Fprintf(w, "  %-11s %9s %9s %9s\n", "", "min", "avg", "p95")
+	phases := []struct {
+		name string
+		get  func(benchSample) time.Duration
+	}{
+		{"tcp connect", func(s benchSample) time.Duration { return s.tcp }},
+		{"handshake", func(s benchSample) time.Duration { return s.handshake }},
+		{"auth", func(s benchSample) time.Duration { return s.auth }},
+		{"total", func(s benchSample) time.Duration { return s.total }},
+	}
+	for _, p := range phases {
+		ds := make([]time.Duration, len(samples))
+		for i, s := range samples {
+			ds[i] = p.get(s)
+		}
+		st := summarize(ds)
+		if st.n == 0 {
+			continue
+		}
+		symbolColor.Fprintf(w, "  %-11s ", p.name)
+		portColor.Fprintf(w, "%9s %9s %9s\n", ms(st.min), ms(st.avg), ms(st.p95))
+	}
+}
+
+func benchSeries(alias string, n int, extra []string) ([]benchSample, error) {
+	samples := make([]benchSample, 0, n)
+	for i := 0; i < n; i++ {
+		s, err := benchOnce(alias, extra)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+var benchCmd = &cobra.Command{
+	Use:   "bench <alias>",
+	Short: "Time connecting and logging in to a host",
+	Long: `Connect to the host N times, running "true", and report min/avg/p95 for
+TCP connect, SSH handshake (key exchange), authentication, and the whole
+run. Phases are timed from ssh -v output as it arrives, so they include
+any ProxyJump hops on the way. BatchMode is on: hosts that need a
+password or passphrase prompt cannot be benchmarked.
+
+--control adds a second series over a ControlMaster connection (set up
+once beforehand and not counted), showing how much multiplexing saves.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHosts,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		if usePuTTY() {
+			return errors.New("gt bench needs the OpenSSH backend")
+		}
+		if benchCount < 1 {
+			return errors.New("--count must be at least 1")
+		}
+
+		samples, err := benchSeries(alias, benchCount, []string{"-o", "ControlMaster=no", "-o", "ControlPath=none"})
+		if err != nil {
+			return err
+		}
+		renderBench(cmd.OutOrStdout(), fmt.Sprintf("%s: %d connections", alias, benchCount), samples)
+		if !benchControl {
+			return nil
+		}
+
+		dir, err := ensureRuntimeDir()
+		if err != nil {
+			return err
+		}
+		mux := []string{"-o", "ControlMaster=auto", "-o", "ControlPath=" + filepath.Join(dir, "b-%C"), "-o", "ControlPersist=60"}
+		if _, err := benchOnce(alias, mux); err != nil {
+			return err
+		}
+		defer func() {
+			exit := append(sshBaseArgs(), append(mux, "-O", "exit", "--", alias)...)
+			runQuiet(sshCommand(exit...))
+		}()
+		samples, err = benchSeries(alias, benchCount, mux)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout())
+		renderBench(cmd.OutOrStdout(), "with ControlMaster", samples)
+		return nil
+	},
+}
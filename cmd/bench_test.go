@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaseTimes(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := start
+	now := func() time.Time {
+		clock = clock.Add(10 * time.Millisecond)
+		return clock
+	}
+	out := strings.Join([]string{
+		"debug1: Connecting to web [192.0.2.1] port 22.",
+		"debug1: Connection established.",
+		"debug1: SSH2_MSG_NEWKEYS received",
+		"debug1: SSH2_MSG_NEWKEYS received",
+		`Authenticated to web ([192.0.2.1]:22) using "publickey".`,
+	}, "\n")
+
+	s := phaseTimes(strings.NewReader(out), start, now)
+	assert.Equal(t, benchSample{tcp: 10 * time.Millisecond, handshake: 10 * time.Millisecond, auth: 10 * time.Millisecond}, s,
+		"each marker counts once, measured from the previous one")
+
+	s = phaseTimes(strings.NewReader("debug1: mux_client_request_session: master session id: 2\n"), start, now)
+	assert.Equal(t, benchSample{}, s, "a multiplexed session reports no phases")
+}
+
+func TestSummarize(t *testing.T) {
+	var ds []time.Duration
+	for i := 1; i <= 20; i++ {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	ds = append(ds, 0) // a sample missing this phase
+
+	st := summarize(ds)
+	assert.Equal(t, 20, st.n)
+	assert.Equal(t, time.Millisecond, st.min)
+	assert.Equal(t, 10500*time.Microsecond, st.avg)
+	assert.Equal(t, 19*time.Millisecond, st.p95)
+
+	assert.Equal(t, benchStats{}, summarize([]time.Duration{0, 0}))
+}
+
+func TestBenchCommand(t *testing.T) {
+	plainOutput(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	decoded, err := ssh_config.Decode(strings.NewReader("Host test\n  HostName test.example.com\n"))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	orig := cfg
+	defer func() { cfg = orig }()
+	cfg = decoded
+	defer func(n int, c bool) { benchCount, benchControl = n, c }(benchCount, benchControl)
+	defer removeRuntimeDir()
+	benchCount, benchControl = 3, true
+
+	var out bytes.Buffer
+	benchCmd.SetOut(&out)
+	defer benchCmd.SetOut(nil)
+	assert.NoError(t, benchCmd.RunE(benchCmd, []string{"test"}))
+
+	// 3 plain runs, 1 master setup, 3 muxed runs, 1 -O exit.
+	assert.Len(t, mockCmd.argLists, 8)
+	assert.Contains(t, mockCmd.argLists[0], "ControlPath=none")
+	assert.Contains(t, mockCmd.argLists[7], "exit")
+	for _, want := range []string{"test: 3 connections", "tcp connect", "handshake", "auth", "total", "with ControlMaster"} {
+		assert.Contains(t, out.String(), want)
+	}
+}
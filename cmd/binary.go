@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
+)
+
+// toolCommand builds the exec.Cmd for an OpenSSH tool ("ssh", "scp" or
+// "sftp"). The program comes from GT_SSH/GT_SCP/GT_SFTP, then
+// ssh_binary/scp_binary in gt's config, then PATH; the env form is read
+// by toolArgv, so a wrapper with its own subcommand ("gcloud compute
+// ssh") fits in one variable. The configured default args go first,
+// ahead of everything gt adds, so a wrapper sees them where it expects
+// its own options.
+func toolCommand(tool string, args ...string) *exec.Cmd {
+	var binary string
+	var extra []string
+	switch tool {
+	case "ssh":
+		binary, extra = gtCfg.SSHBinary, gtCfg.SSHArgs
+	case "scp":
+		binary, extra = gtCfg.SCPBinary, gtCfg.SCPArgs
+	}
+
+	t := transport.Tool{Argv: []string{tool}, Args: extra}
+	if env := toolArgv(os.Getenv("GT_" + strings.ToUpper(tool))); len(env) > 0 {
+		t.Argv = env
+	} else if binary != "" {
+		t.Argv = []string{binary}
+	}
+	argv := t.Command(args...)
+	return execCommand(argv[0], argv[1:]...)
+}
+
+// toolArgv is the program and arguments a GT_SSH-style variable names.
+// A value that is an existing file is that one program, spaces and all,
+// so C:\Program Files\OpenSSH\ssh.exe needs no quoting; anything else
+// is split as an ssh config line is, at spaces outside quotes and with
+// backslashes kept, so a quoted Windows path can still take arguments.
+func toolArgv(v string) []string {
+	if v = strings.TrimSpace(v); v == "" {
+		return nil
+	}
+	if fi, err := os.Stat(v); err == nil && !fi.IsDir() {
+		return []string{v}
+	}
+	return sshconf.Fields(v)
+}
+
+func sshCommand(args ...string) *exec.Cmd { return toolCommand("ssh", args...) }
+
+func scpCommand(args ...string) *exec.Cmd { return toolCommand("scp", args...) }
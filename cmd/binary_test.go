@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolCommand(t *testing.T) {
+	useMockExec(t)
+	orig := gtCfg
+	defer func() { gtCfg = orig }()
+	t.Setenv("GT_SSH", "")
+	t.Setenv("GT_SCP", "")
+
+	gtCfg = gtConfig{}
+	sshCommand("--", "host")
+	assert.Equal(t, "ssh", mockCmd.commands[0])
+	assert.Equal(t, []string{"--", "host"}, mockCmd.argLists[0])
+
+	mockCmd.reset()
+	gtCfg = gtConfig{
+		SSHBinary: "/opt/openssh/bin/ssh",
+		SSHArgs:   []string{"-o", "ConnectTimeout=5"},
+		SCPBinary: "/opt/openssh/bin/scp",
+	}
+	sshCommand("--", "host")
+	scpCommand("-p", "--", "a", "host:b")
+	assert.Equal(t, []string{"/opt/openssh/bin/ssh", "/opt/openssh/bin/scp"}, mockCmd.commands)
+	assert.Equal(t, []string{"-o", "ConnectTimeout=5", "--", "host"}, mockCmd.argLists[0])
+	assert.Equal(t, []string{"-p", "--", "a", "host:b"}, mockCmd.argLists[1], "ssh_args do not leak into scp")
+
+	mockCmd.reset()
+	t.Setenv("GT_SSH", "gcloud compute ssh")
+	sshCommand("--", "host")
+	assert.Equal(t, "gcloud", mockCmd.commands[0], "env beats config")
+	assert.Equal(t, []string{"compute", "ssh", "-o", "ConnectTimeout=5", "--", "host"}, mockCmd.argLists[0])
+
+	mockCmd.reset()
+	t.Setenv("GT_SSH", `"C:\Program Files\OpenSSH\ssh.exe" -v`)
+	sshCommand("--", "host")
+	assert.Equal(t, `C:\Program Files\OpenSSH\ssh.exe`, mockCmd.commands[0], "a quoted path keeps its spaces and backslashes")
+	assert.Equal(t, []string{"-v", "-o", "ConnectTimeout=5", "--", "host"}, mockCmd.argLists[0])
+}
+
+func TestToolArgv(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Open SSH")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	bin := filepath.Join(dir, "ssh")
+	require.NoError(t, os.WriteFile(bin, nil, 0o755))
+
+	assert.Equal(t, []string{bin}, toolArgv(bin), "an existing file is one program, unquoted spaces and all")
+	assert.Equal(t, []string{"gcloud", "compute", "ssh"}, toolArgv("gcloud compute ssh"))
+	assert.Equal(t, []string{`C:\Program Files\ssh.exe`, "-v"}, toolArgv(`'C:\Program Files\ssh.exe' -v`))
+	assert.Equal(t, []string{dir, "x"}, toolArgv(`"`+dir+`" x`), "a directory is not a program")
+	assert.Nil(t, toolArgv("  "))
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+// broadcastSession is one host's shell in gt broadcast.
+type broadcastSession struct {
+	alias string
+	stdin io.WriteCloser
+	// done is closed when the shell has ended, err being how.
+	done     chan struct{}
+	err      error
+	duration time.Duration
+}
+
+func (s *broadcastSession) ended() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// startBroadcastSession starts a shell on alias that reads its commands
+// from the session's stdin, without a terminal and in BatchMode, with
+// its output prefixed by the alias. The connect hooks run around it and
+// it is audit-logged as "broadcast".
+func startBroadcastSession(alias string, stdout, stderr io.Writer) (*broadcastSession, error) {
+	cmd, err := remoteCommand(alias, remoteOpts{batch: true})
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out := &prefixWriter{w: stdout, prefix: alias}
+	errOut := &prefixWriter{w: stderr, prefix: alias}
+	var tail tailBuffer
+	cmd.Stdout = out
+	cmd.Stderr = io.MultiWriter(errOut, &tail)
+	s := &broadcastSession{alias: alias, stdin: stdin, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		start := time.Now()
+		s.err = withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias}, func() error {
+			debugf(1, "exec: %s", quoteArgv(cmd.Args))
+			err := runTracked(cmd, false)
+			out.Flush()
+			errOut.Flush()
+			logConnection(alias, "broadcast", start, err)
+			return classifyRun(alias, "ssh", err, tail.String())
+		})
+		s.duration = time.Since(start)
+		stdin.Close() // a pre-connect hook that failed left it open
+	}()
+	return s, nil
+}
+
+// broadcastHelp lists the lines gt broadcast keeps for itself.
+const broadcastHelp = `:hosts          list the hosts, and which have ended
+:focus <alias>  send lines to one host only
+:all            send lines to every host again
+:quit           end every shell (as does end of input)
+::...           send a line that starts with ':'`
+
+// broadcast reads lines from in and sends each to every session still
+// running, or only to the focused one. Lines starting with ':' are gt's
+// (see broadcastHelp); prompt, when not nil, gets a prompt before each
+// line and gt's answers. The loop ends with in, with :quit, or as soon
+// as every shell has ended; the sessions' stdin is then closed, and
+// broadcast waits for them to finish.
+func broadcast(sessions []*broadcastSession, in io.Reader, prompt io.Writer) {
+	if prompt == nil {
+		prompt = io.Discard
+	}
+	byAlias := make(map[string]*broadcastSession, len(sessions))
+	for _, s := range sessions {
+		byAlias[s.alias] = s
+	}
+	allEnded := make(chan struct{})
+	go func() {
+		for _, s := range sessions {
+			<-s.done
+		}
+		close(allEnded)
+	}()
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var focus *broadcastSession
+loop:
+	for {
+		var live []*broadcastSession
+		for _, s := range sessions {
+			if !s.ended() {
+				live = append(live, s)
+			}
+		}
+		if len(live) == 0 {
+			break
+		}
+		if focus != nil && focus.ended() {
+			warningColor.Fprintf(prompt, "%s has ended; back to all hosts\n", focus.alias)
+			focus = nil
+		}
+		if focus != nil {
+			symbolColor.Fprintf(prompt, "%s> ", focus.alias)
+		} else {
+			symbolColor.Fprintf(prompt, "all(%d)> ", len(live))
+		}
+		var line string
+		var ok bool
+		select {
+		case line, ok = <-lines:
+		case <-allEnded:
+		}
+		if !ok {
+			fmt.Fprintln(prompt)
+			break
+		}
+		if strings.HasPrefix(line, ":") && !strings.HasPrefix(line, "::") {
+			word, arg, _ := strings.Cut(strings.TrimSpace(line[1:]), " ")
+			switch word {
+			case "quit":
+				break loop
+			case "all":
+				focus = nil
+			case "focus":
+				s := byAlias[strings.TrimSpace(arg)]
+				switch {
+				case s == nil:
+					errorColor.Fprintf(prompt, "no host %q in this broadcast\n", strings.TrimSpace(arg))
+				case s.ended():
+					errorColor.Fprintf(prompt, "%s has ended\n", s.alias)
+				default:
+					focus = s
+				}
+			case "hosts":
+				for _, s := range sessions {
+					aliasColor.Fprint(prompt, s.alias)
+					switch {
+					case s.ended():
+						fmt.Fprintln(prompt, "  ended")
+					case s == focus:
+						fmt.Fprintln(prompt, "  focused")
+					default:
+						fmt.Fprintln(prompt)
+					}
+				}
+			default:
+				fmt.Fprintln(prompt, broadcastHelp)
+			}
+			continue
+		}
+		line = strings.TrimPrefix(line, ":")
+		targets := live
+		if focus != nil {
+			targets = []*broadcastSession{focus}
+		}
+		for _, s := range targets {
+			// A shell that ended meanwhile fails the write; its end is
+			// reported once it is done.
+			io.WriteString(s.stdin, line+"\n")
+		}
+	}
+	for _, s := range sessions {
+		s.stdin.Close()
+	}
+	for _, s := range sessions {
+		<-s.done
+	}
+}
+
+var broadcastCmd = &cobra.Command{
+	Use:   "broadcast <alias|@group...>",
+	Short: "Type commands into a shell on every host at once",
+	Long: `Start a shell on each host and send every line you type to all of them
+at once, cluster-ssh style but with no tmux or X11: each host's output
+comes back line by line, led by its alias. The shells have no terminal,
+so there are no remote prompts and full-screen programs (vi, top) do not
+work, but cd, variables and the like carry over from line to line. Hosts
+run in BatchMode, so keys or an agent must log in without prompts.
+
+Lines starting with ':' are gt's:
+
+` + broadcastHelp + `
+
+A host whose shell ends, with exit or a lost connection, drops out; gt
+exits when every shell has ended, and exits 1 if any failed.`,
+	Example: `  gt broadcast @web
+  gt broadcast web1 web2 db1`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		aliases, err := expandTargets(args)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+		sessions := make([]*broadcastSession, 0, len(aliases))
+		for _, alias := range aliases {
+			s, err := startBroadcastSession(alias, stdout, stderr)
+			if err != nil {
+				for _, s := range sessions {
+					s.stdin.Close()
+					<-s.done
+				}
+				return err
+			}
+			sessions = append(sessions, s)
+		}
+		var prompt io.Writer
+		if f, ok := cmd.InOrStdin().(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+			prompt = stderr
+		}
+		broadcast(sessions, cmd.InOrStdin(), prompt)
+
+		results := make([]hostResult, len(sessions))
+		for i, s := range sessions {
+			results[i] = hostResult{alias: s.alias, err: s.err, duration: s.duration}
+		}
+		renderResults(stdout, results)
+		return failedHosts(results, 1)
+	},
+}
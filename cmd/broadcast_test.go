@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeShell is a broadcast session's stdin that records the lines sent
+// and ends the session when closed.
+type fakeShell struct {
+	lines bytes.Buffer
+	s     *broadcastSession
+	once  sync.Once
+}
+
+func (f *fakeShell) Write(b []byte) (int, error) { return f.lines.Write(b) }
+
+func (f *fakeShell) Close() error {
+	f.once.Do(func() { close(f.s.done) })
+	return nil
+}
+
+func fakeSessions(aliases ...string) ([]*broadcastSession, map[string]*fakeShell) {
+	var sessions []*broadcastSession
+	shells := make(map[string]*fakeShell)
+	for _, alias := range aliases {
+		s := &broadcastSession{alias: alias, done: make(chan struct{})}
+		f := &fakeShell{s: s}
+		s.stdin = f
+		sessions = append(sessions, s)
+		shells[alias] = f
+	}
+	return sessions, shells
+}
+
+func TestBroadcast(t *testing.T) {
+	plainOutput(t)
+	sessions, shells := fakeSessions("web1", "web2", "db1")
+	var prompt bytes.Buffer
+	broadcast(sessions, strings.NewReader(`uptime
+:focus db1
+systemctl restart postgresql
+:hosts
+:all
+::colon
+:focus nope
+:what
+df -h
+`), &prompt)
+
+	assert.Equal(t, "uptime\n:colon\ndf -h\n", shells["web1"].lines.String())
+	assert.Equal(t, shells["web1"].lines.String(), shells["web2"].lines.String())
+	assert.Equal(t, "uptime\nsystemctl restart postgresql\n:colon\ndf -h\n", shells["db1"].lines.String())
+	for _, s := range sessions {
+		assert.True(t, s.ended(), "end of input closes %s's shell", s.alias)
+	}
+
+	p := prompt.String()
+	assert.True(t, strings.HasPrefix(p, "all(3)> all(3)> db1> db1> web1\nweb2\ndb1  focused\n"), p)
+	assert.Contains(t, p, `no host "nope" in this broadcast`)
+	assert.Contains(t, p, broadcastHelp, "help for what gt does not know")
+}
+
+func TestBroadcastEnded(t *testing.T) {
+	plainOutput(t)
+	sessions, shells := fakeSessions("web1", "web2")
+	shells["web1"].Close()
+	var prompt bytes.Buffer
+	broadcast(sessions, strings.NewReader(":focus web1\nuptime\n:quit\nnot sent\n"), &prompt)
+
+	assert.Empty(t, shells["web1"].lines.String(), "an ended shell gets nothing")
+	assert.Equal(t, "uptime\n", shells["web2"].lines.String(), ":quit stops reading")
+	assert.Contains(t, prompt.String(), "web1 has ended\n")
+	assert.True(t, strings.HasPrefix(prompt.String(), "all(1)> "), prompt.String())
+}
+
+func TestBroadcastCmd(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useMockExec(t)
+	useWebGroup(t)
+	var out, errOut bytes.Buffer
+	broadcastCmd.SetOut(&out)
+	broadcastCmd.SetErr(&errOut)
+	broadcastCmd.SetIn(strings.NewReader("uptime\n:focus web-1\nexit 3\n"))
+	t.Cleanup(func() {
+		broadcastCmd.SetOut(nil)
+		broadcastCmd.SetErr(nil)
+		broadcastCmd.SetIn(nil)
+	})
+
+	err := broadcastCmd.RunE(broadcastCmd, []string{"web-1", "web-2"})
+	require.Error(t, err)
+	assert.Equal(t, 1, ExitCode(err))
+	assert.Contains(t, out.String(), "web-1 | uptime\n")
+	assert.Contains(t, out.String(), "web-2 | uptime\n")
+	assert.NotContains(t, out.String(), "exit 3", "gt's own lines and the exit are not echoed")
+	assert.Regexp(t, `web-1\s+failed\s+\S+\s+exit status 3`, out.String())
+	for _, args := range mockCmd.argLists {
+		if args[0] != "-G" {
+			assert.Contains(t, strings.Join(args, " "), "BatchMode=yes")
+		}
+	}
+}
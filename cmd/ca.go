@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var (
+	caKeyFile    string
+	caPrincipals []string
+	caParallel   int
+)
+
+// caPublicKey reads the CA public key, from --key or ca_key in gt's
+// config, and returns its line and its base64 blob, which is what the
+// remote side looks for to tell whether it has the key already.
+func caPublicKey() (line, blob string, err error) {
+	path := caKeyFile
+	if path == "" {
+		path = gtCfg.CAKey
+	}
+	if path == "" {
+		return "", "", errors.New("no CA key: pass --key or set ca_key in gt's config")
+	}
+	path = sshconf.ExpandTilde(path)
+	if isPrivateKey(path) {
+		return "", "", fmt.Errorf("%s is a private key; give the CA's .pub", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	line = strings.TrimSpace(string(data))
+	f := strings.Fields(line)
+	if strings.Contains(line, "\n") || len(f) < 2 {
+		return "", "", fmt.Errorf("%s is not a single public key line", path)
+	}
+	if _, err := base64.StdEncoding.DecodeString(f[1]); err != nil {
+		return "", "", fmt.Errorf("%s is not a public key: %v", path, err)
+	}
+	return line, f[1], nil
+}
+
+// validatePrincipal checks a principal for the principals file, which
+// takes one per line.
+func validatePrincipal(p string) error {
+	if p == "" || strings.IndexFunc(p, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("invalid principal %q", p)
+	}
+	return nil
+}
+
+// sshdSetting is the start of a sh script that reads sshd's effective
+// config, as root, and defines setting to look a keyword up in it. sshd
+// may live outside a non-root PATH.
+const sshdSetting = `PATH=$PATH:/usr/sbin:/sbin
+conf=$($sudo sshd -T 2>/dev/null) || { echo "sshd -T failed; reading sshd's config needs root or sudo" >&2; exit 1; }
+setting() { printf '%s\n' "$conf" | awk -v k="$1" '$1 == k { print $2; exit }'; }
+`
+
+// caInstallScript trusts the CA key ($1, blob $2) for user certificates:
+// it appends the key to the file TrustedUserCAKeys names, setting one at
+// the top of sshd_config (where it wins) if none is, and the principals
+// after them to the login user's AuthorizedPrincipalsFile. sshd is
+// reloaded after sshd -t passes, and only if anything changed.
+const caInstallScript = `key=$1; blob=$2; shift 2
+prepend() { $sudo sed -i "1i $1" /etc/ssh/sshd_config || exit 1; }
+changed=
+ca=$(setting trustedusercakeys)
+if [ -z "$ca" ] || [ "$ca" = none ]; then
+	ca=/etc/ssh/gt_user_ca.pub
+	prepend "TrustedUserCAKeys $ca"
+	changed=1
+fi
+if ! $sudo grep -qF -- "$blob" "$ca" 2>/dev/null; then
+	printf '%s\n' "$key" | $sudo tee -a "$ca" >/dev/null || exit 1
+	changed=1
+fi
+if [ $# -gt 0 ]; then
+	pf=$(setting authorizedprincipalsfile)
+	if [ -z "$pf" ] || [ "$pf" = none ]; then
+		pf=/etc/ssh/auth_principals/%u
+		prepend "AuthorizedPrincipalsFile $pf"
+		changed=1
+	fi
+	user=$(id -un)
+	f=$(printf '%s' "$pf" | sed "s|%u|$user|g; s|%h|$HOME|g")
+	case $f in /*) ;; *) f=$HOME/$f ;; esac
+	$sudo mkdir -p "$(dirname "$f")" || exit 1
+	for p; do
+		$sudo grep -qxF -- "$p" "$f" 2>/dev/null && continue
+		printf '%s\n' "$p" | $sudo tee -a "$f" >/dev/null || exit 1
+		changed=1
+	done
+fi
+if [ -z "$changed" ]; then echo "the CA is trusted already ($ca)"; exit 0; fi
+$sudo sshd -t || exit 1
+$sudo systemctl reload ssh 2>/dev/null || $sudo systemctl reload sshd 2>/dev/null ||
+	$sudo service ssh reload 2>/dev/null || $sudo service sshd reload || exit 1
+echo "the CA is trusted ($ca); sshd reloaded"
+`
+
+// caStatusScript prints "yes <file>" when the TrustedUserCAKeys file has
+// the CA key (blob $1), "no <file>" when it does not, and "none" when
+// sshd trusts no CA at all.
+const caStatusScript = `ca=$(setting trustedusercakeys)
+if [ -z "$ca" ] || [ "$ca" = none ]; then echo none
+elif $sudo grep -qF -- "$1" "$ca" 2>/dev/null; then echo "yes $ca"
+else echo "no $ca"; fi
+`
+
+// caCommand is the remote command for script, as root.
+func caCommand(script string, batch bool, args ...string) string {
+	return quoteArgv(append([]string{"sh", "-c", sudoPrefix(batch) + sshdSetting + script, "sh"}, args...))
+}
+
+// caTrust is one host's answer to gt ca status.
+type caTrust struct {
+	alias   string
+	trusted bool
+	// file is the TrustedUserCAKeys file, "" when sshd has none.
+	file string
+	err  error
+}
+
+// parseCATrust reads caStatusScript's output.
+func parseCATrust(alias string, out []byte) caTrust {
+	state, file, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+	return caTrust{alias: alias, trusted: state == "yes", file: file}
+}
+
+// renderCATrust writes whether each host trusts the CA, and where.
+func renderCATrust(w io.Writer, hosts []caTrust) {
+	hostWidth := len("HOST")
+	for _, h := range hosts {
+		if n := displayWidth(h.alias); n > hostWidth {
+			hostWidth = n
+		}
+	}
+	symbolColor.Fprintf(w, "%-*s  %-7s  %s\n", hostWidth, "HOST", "TRUSTED", "DETAIL")
+	trusted := 0
+	for _, h := range hosts {
+		aliasColor.Fprint(w, h.alias+strings.Repeat(" ", hostWidth-displayWidth(h.alias)+2))
+		switch {
+		case h.err != nil:
+			errorColor.Fprintf(w, "%-7s  %s\n", "-", firstLine(h.err.Error()))
+		case h.trusted:
+			trusted++
+			userColor.Fprintf(w, "%-7s  ", "yes")
+			fmt.Fprintln(w, h.file)
+		case h.file == "":
+			warningColor.Fprintf(w, "%-7s  ", "no")
+			fmt.Fprintln(w, "no TrustedUserCAKeys")
+		default:
+			warningColor.Fprintf(w, "%-7s  ", "no")
+			fmt.Fprintf(w, "not in %s\n", h.file)
+		}
+	}
+	if len(hosts) > 1 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%d of %d host(s) trust the CA\n", trusted, len(hosts))
+	}
+}
+
+var caCmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Have hosts trust a certificate authority for user logins",
+	Long: `Distribute the public key of the SSH certificate authority that signs
+your users' certificates, so hosts accept them in place of authorized
+keys. The key is --key, or ca_key in gt's config.`,
+}
+
+var caInstallCmd = &cobra.Command{
+	Use:   "install <alias|@group>",
+	Short: "Add the CA key to the hosts' TrustedUserCAKeys",
+	Long: `Append the CA public key, as root, to the file sshd's TrustedUserCAKeys
+names, and when none does, set TrustedUserCAKeys /etc/ssh/gt_user_ca.pub
+at the top of sshd_config. A host that has the key already is left
+alone. With --principal, the names are added to the login user's
+AuthorizedPrincipalsFile, set to /etc/ssh/auth_principals/%u if unset;
+a certificate then logs in as that user when it carries one of them.
+Note that with AuthorizedPrincipalsFile set, a user without a principals
+file no longer logs in with a certificate at all.
+
+sshd's config is checked with sshd -t before sshd reloads. On a single
+host the command gets a terminal so sudo can ask for a password; a group
+runs in BatchMode with sudo -n, up to --parallel hosts at a time. gt
+exits 1 if any host failed.`,
+	Example: `  gt ca install @web --key ~/ca/user_ca.pub
+  gt ca install db1 --principal alice --principal dba`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := args[0]
+		key, blob, err := caPublicKey()
+		if err != nil {
+			return err
+		}
+		for _, p := range caPrincipals {
+			if err := validatePrincipal(p); err != nil {
+				return err
+			}
+		}
+		aliases, err := expandTarget(target)
+		if err != nil {
+			return err
+		}
+		if err := refuseUntrusted(aliases, "install a CA key"); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		scriptArgs := append([]string{key, blob}, caPrincipals...)
+
+		if !isGroupTarget(target) {
+			remoteCmd := []string{caCommand(caInstallScript, nonInteractive(), scriptArgs...)}
+			return withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: target, Command: remoteCmd}, func() error {
+				if nonInteractive() {
+					return runSSH(target, remoteCmd)
+				}
+				return runWithTerminal(target, remoteCmd)
+			})
+		}
+		results := fanOut(aliases, caParallel, func(alias string) error {
+			return execOne(alias, []string{caCommand(caInstallScript, true, scriptArgs...)}, "")
+		}, reportProgress(len(aliases)))
+		renderResults(cmd.OutOrStdout(), results)
+		return failedHosts(results, 1)
+	},
+}
+
+var caStatusCmd = &cobra.Command{
+	Use:   "status <alias|@group>",
+	Short: "Show which hosts trust the CA key",
+	Long: `Print, per host, whether the file sshd's TrustedUserCAKeys names has
+the CA key. Reading sshd's effective config (sshd -T) takes root or
+passwordless sudo, since the output is read rather than shown. gt exits
+1 if any host could not be checked.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, blob, err := caPublicKey()
+		if err != nil {
+			return err
+		}
+		aliases, err := expandTarget(args[0])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		var mu sync.Mutex
+		hosts := make(map[string]caTrust, len(aliases))
+		results := fanOut(aliases, caParallel, func(alias string) error {
+			out, err := remoteOutput(alias, "ca", remoteOpts{batch: isGroupTarget(args[0])}, caCommand(caStatusScript, true, blob))
+			h := parseCATrust(alias, out)
+			h.err = err
+			mu.Lock()
+			hosts[alias] = h
+			mu.Unlock()
+			return err
+		}, nil)
+		ordered := make([]caTrust, len(aliases))
+		for i, alias := range aliases {
+			ordered[i] = hosts[alias]
+		}
+		var out bytes.Buffer
+		renderCATrust(&out, ordered)
+		if _, err := cmd.OutOrStdout().Write(out.Bytes()); err != nil {
+			return err
+		}
+		return failedHosts(results, 1)
+	},
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"gt/pkg/sshconf"
+)
+
+var (
+	// resolvedNames caches nameResolves's answers for the run, since gt
+	// list asks about the same names for host after host.
+	resolvedNames   = map[string]bool{}
+	resolvedNamesMu sync.Mutex
+)
+
+// nameResolves reports whether name has an address in DNS.
+func nameResolves(name string) bool {
+	resolvedNamesMu.Lock()
+	ok, seen := resolvedNames[name]
+	resolvedNamesMu.Unlock()
+	if seen {
+		return ok
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	ips, err := lookupIPs(ctx, name)
+	ok = err == nil && len(ips) > 0
+	debugf(2, "canonicalizing: %s resolves: %v", name, ok)
+	resolvedNamesMu.Lock()
+	resolvedNames[name] = ok
+	resolvedNamesMu.Unlock()
+	return ok
+}
+
+// canonicalHostname is host as ssh will canonicalize it under opts, the
+// host's resolved options; see sshconf.Canonicalization.
+func canonicalHostname(host string, opts map[string][]string) string {
+	c := sshconf.ParseCanonicalization(opts)
+	name := c.Canonicalize(host, nameResolves)
+	if name != host {
+		debugf(1, "%s canonicalizes to %s", host, name)
+	}
+	return name
+}
+
+// configCanonicalization is the canonicalization options of alias as gt
+// parses them from the config, for the PuTTY backend, which has no ssh
+// -G; via is the ProxyJump or ProxyCommand the host is reached through.
+func configCanonicalization(alias, via string) map[string][]string {
+	opts := map[string][]string{}
+	for _, key := range []string{"CanonicalizeHostname", "CanonicalDomains", "CanonicalizeMaxDots"} {
+		if v, _ := cfg.Get(alias, key); v != "" {
+			opts[strings.ToLower(key)] = []string{v}
+		}
+	}
+	if via != "" {
+		opts["proxyjump"] = []string{via}
+	}
+	return opts
+}
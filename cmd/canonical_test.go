@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func useCanonicalDNS(t *testing.T, names ...string) *int {
+	t.Helper()
+	origLookup, origNames := lookupIPs, resolvedNames
+	t.Cleanup(func() { lookupIPs, resolvedNames = origLookup, origNames })
+	resolvedNames = map[string]bool{}
+	lookups := 0
+	lookupIPs = func(ctx context.Context, host string) ([]net.IP, error) {
+		lookups++
+		for _, n := range names {
+			if n == host {
+				return []net.IP{net.ParseIP("192.0.2.3")}, nil
+			}
+		}
+		return nil, errors.New("no such host")
+	}
+	return &lookups
+}
+
+func TestCanonicalHostname(t *testing.T) {
+	lookups := useCanonicalDNS(t, "db3.corp.example.com")
+	opts := map[string][]string{
+		"canonicalizehostname": {"true"},
+		"canonicaldomains":     {"example.com corp.example.com"},
+	}
+	assert.Equal(t, "db3.corp.example.com", canonicalHostname("db3", opts))
+	assert.Equal(t, 2, *lookups)
+	assert.Equal(t, "db3.corp.example.com", canonicalHostname("db3", opts))
+	assert.Equal(t, 2, *lookups, "answers are cached")
+
+	assert.Equal(t, "db3", canonicalHostname("db3", map[string][]string{"canonicalizehostname": {"false"}}))
+	assert.Equal(t, 2, *lookups, "nothing is looked up when canonicalization is off")
+}
+
+func TestPuTTYResolvedCanonicalizes(t *testing.T) {
+	origCfg := cfg
+	t.Cleanup(func() { cfg = origCfg })
+	useCanonicalDNS(t, "db3.corp.example.com", "bastion.corp.example.com")
+	usePuTTYBackend(t, `Host db3
+  User me
+Host behind
+  HostName db3
+  ProxyJump bastion
+Host *
+  CanonicalizeHostname yes
+  CanonicalDomains corp.example.com
+`)
+	assert.Equal(t, "db3.corp.example.com", puttyResolved("db3").Hostname, "plink gets the name ssh would connect to")
+	assert.Equal(t, "db3", puttyResolved("behind").Hostname, "yes leaves hosts behind a jump alone")
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var copyStdin bool
+
+// remoteClipScript copies its stdin to the remote clipboard with the
+// first tool the host has. xclip and xsel stay behind to own the
+// selection, so their output goes to /dev/null: ssh would otherwise wait
+// for them to close it.
+const remoteClipScript = `if command -v pbcopy >/dev/null 2>&1; then exec pbcopy
+elif [ -n "$WAYLAND_DISPLAY" ] && command -v wl-copy >/dev/null 2>&1; then exec wl-copy
+elif [ -z "$DISPLAY" ]; then echo "no clipboard on the remote: pbcopy is missing and DISPLAY is not set" >&2; exit 127
+elif command -v xclip >/dev/null 2>&1; then exec xclip -selection clipboard >/dev/null 2>&1
+elif command -v xsel >/dev/null 2>&1; then exec xsel --clipboard --input >/dev/null 2>&1
+else echo "no clipboard tool on the remote (pbcopy, wl-copy, xclip or xsel)" >&2; exit 127; fi
+`
+
+// localClipboard returns the command that copies its stdin to this
+// machine's clipboard: pbcopy on macOS, clip on Windows, and wl-copy,
+// xclip or xsel elsewhere, whichever is installed.
+func localClipboard(goos string, getenv func(string) string) ([]string, error) {
+	var candidates [][]string
+	switch goos {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		if getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-copy"})
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"})
+	}
+	var names []string
+	for _, c := range candidates {
+		if _, err := lookPath(c[0]); err == nil {
+			return c, nil
+		}
+		names = append(names, c[0])
+	}
+	return nil, fmt.Errorf("no clipboard tool found (%s)", strings.Join(names, ", "))
+}
+
+// connectionString is how to reach a resolved host by hand, as
+// user@hostname -p port.
+func connectionString(r sshconf.Resolved) string {
+	s := r.Hostname
+	if r.User != "" {
+		s = r.User + "@" + s
+	}
+	port := r.Port
+	if port == "" {
+		port = "22"
+	}
+	return s + " -p " + port
+}
+
+// copyLocal puts text on this machine's clipboard.
+func copyLocal(text string) error {
+	argv, err := localClipboard(runtime.GOOS, os.Getenv)
+	if err != nil {
+		return err
+	}
+	c := execCommand(argv[0], argv[1:]...)
+	c.Stdin = strings.NewReader(text)
+	// Not a pipe: xclip and xsel outlive the copy, holding it open.
+	c.Stderr = os.Stderr
+	debugf(1, "exec: %s", quoteArgv(c.Args))
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s: %w", argv[0], err)
+	}
+	return nil
+}
+
+// runCopy is gt <alias> --copy: the remote clipboard gets gt's stdin.
+func runCopy(alias string, args []string) error {
+	if len(args) > 0 {
+		return errors.New("--copy takes no command: it reads what to copy from stdin")
+	}
+	if useScp {
+		return errors.New("--copy cannot be combined with --scp")
+	}
+	remoteCmd := []string{shellScript(remoteClipScript)}
+	return withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: remoteCmd}, func() error {
+		return runSSH(alias, remoteCmd)
+	})
+}
+
+var clipCmd = &cobra.Command{
+	Use:   "clip <alias>",
+	Short: "Copy a host's user@hostname -p port to the clipboard",
+	Long: `Copy how to reach a host, as user@hostname -p port after ssh -G has
+resolved it, to this machine's clipboard, using pbcopy, clip, wl-copy,
+xclip or xsel.
+
+The other way round, gt <alias> --copy pipes stdin into the host's
+clipboard:
+
+  gt clip web1                    # "deploy@web1.example.com -p 22"
+  gt web1 --copy < snippet.txt`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHosts,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		r, err := resolveHost(alias)
+		if err != nil {
+			return err
+		}
+		s := connectionString(r)
+		if err := copyLocal(s); err != nil {
+			return err
+		}
+		if redactEnabled() {
+			s = redactAddress(s)
+		}
+		statusf(symbolColor, "Copied %s\n", s)
+		return nil
+	},
+}
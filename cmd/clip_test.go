@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gt/pkg/sshconf"
+)
+
+// useLookPath makes only the named tools installed.
+func useLookPath(t *testing.T, installed ...string) {
+	t.Helper()
+	orig := lookPath
+	t.Cleanup(func() { lookPath = orig })
+	lookPath = func(file string) (string, error) {
+		if contains(installed, file) {
+			return "/usr/bin/" + file, nil
+		}
+		return "", exec.ErrNotFound
+	}
+}
+
+func TestLocalClipboard(t *testing.T) {
+	noEnv := func(string) string { return "" }
+	wayland := func(k string) string {
+		if k == "WAYLAND_DISPLAY" {
+			return "wayland-0"
+		}
+		return ""
+	}
+
+	useLookPath(t, "pbcopy", "clip", "wl-copy", "xclip", "xsel")
+	argv, err := localClipboard("darwin", noEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pbcopy"}, argv)
+	argv, _ = localClipboard("windows", noEnv)
+	assert.Equal(t, []string{"clip"}, argv)
+	argv, _ = localClipboard("linux", wayland)
+	assert.Equal(t, []string{"wl-copy"}, argv)
+	argv, _ = localClipboard("linux", noEnv)
+	assert.Equal(t, []string{"xclip", "-selection", "clipboard"}, argv, "wl-copy needs a Wayland session")
+
+	useLookPath(t, "xsel")
+	argv, _ = localClipboard("freebsd", noEnv)
+	assert.Equal(t, []string{"xsel", "--clipboard", "--input"}, argv)
+
+	useLookPath(t)
+	_, err = localClipboard("linux", wayland)
+	assert.EqualError(t, err, "no clipboard tool found (wl-copy, xclip, xsel)")
+}
+
+func TestConnectionString(t *testing.T) {
+	assert.Equal(t, "deploy@web1.example.com -p 2222", connectionString(sshconf.Resolved{User: "deploy", Hostname: "web1.example.com", Port: "2222"}))
+	assert.Equal(t, "web1.example.com -p 22", connectionString(sshconf.Resolved{Hostname: "web1.example.com"}))
+}
+
+func TestClipCopiesConnectionString(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	useLookPath(t, "pbcopy", "clip", "xclip")
+
+	assert.NoError(t, clipCmd.RunE(clipCmd, []string{"web-1"}))
+	last := mockCmd.commands[len(mockCmd.commands)-1]
+	assert.True(t, contains([]string{"pbcopy", "clip", "xclip"}, last), "ran %v", mockCmd.commands)
+
+	useLookPath(t)
+	assert.ErrorContains(t, clipCmd.RunE(clipCmd, []string{"web-1"}), "no clipboard tool found")
+	assert.Equal(t, exitHostNotFound, ExitCode(clipCmd.RunE(clipCmd, []string{"nope"})))
+}
+
+func TestCopyPipesStdinToRemoteClipboard(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+
+	assert.NoError(t, runCopy("web-1", nil))
+	argv := mockRun("ssh")
+	assert.NotNil(t, argv)
+	remote := argv[len(argv)-1]
+	for _, tool := range []string{"pbcopy", "wl-copy", "xclip -selection clipboard", "xsel --clipboard --input"} {
+		assert.True(t, strings.Contains(remote, tool), "missing %s in %s", tool, remote)
+	}
+
+	assert.ErrorContains(t, runCopy("web-1", []string{"uptime"}), "takes no command")
+	useScp = true
+	t.Cleanup(func() { useScp = false })
+	assert.ErrorContains(t, runCopy("web-1", nil), "--scp")
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var completionNoRC bool
+
+// completionShells are the shells gt completion install supports.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// detectShell names the user's shell: $SHELL's basename, or PowerShell
+// on Windows, where $SHELL is normally unset.
+func detectShell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return strings.TrimSuffix(filepath.Base(sh), ".exe")
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return ""
+}
+
+// completionTarget says where a shell's completion script goes and, for
+// shells that do not load it by themselves, which startup file must
+// source it.
+type completionTarget struct {
+	script string
+	rc     string // "" when the shell finds the script on its own
+	source string // the line in rc that loads the script
+}
+
+// completionTargetFor picks the install location for shell. fish loads
+// anything in its completions directory. bash-completion does the same
+// for its per-user directory, but only where bash-completion is
+// installed, so ~/.bashrc sources the script too. zsh has no per-user
+// directory on its fpath by default, so ~/.zshrc sources it (after
+// compinit, which is where an appended line lands).
+func completionTargetFor(shell, home string) (completionTarget, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	zdot := os.Getenv("ZDOTDIR")
+	if zdot == "" {
+		zdot = home
+	}
+	shSource := func(path string) string {
+		q := quoteArgv([]string{path})
+		return fmt.Sprintf("[ -f %s ] && . %s", q, q)
+	}
+
+	switch shell {
+	case "bash":
+		script := filepath.Join(dataHome, "bash-completion", "completions", "gt")
+		return completionTarget{script, filepath.Join(home, ".bashrc"), shSource(script)}, nil
+	case "zsh":
+		script := filepath.Join(zdot, ".zfunc", "_gt")
+		return completionTarget{script, filepath.Join(zdot, ".zshrc"), shSource(script)}, nil
+	case "fish":
+		return completionTarget{script: filepath.Join(configHome, "fish", "completions", "gt.fish")}, nil
+	case "powershell", "pwsh":
+		cfgPath, err := gtConfigPath()
+		if err != nil {
+			return completionTarget{}, err
+		}
+		script := filepath.Join(filepath.Dir(cfgPath), "completion.ps1")
+		q := "'" + strings.ReplaceAll(script, "'", "''") + "'"
+		return completionTarget{script, powershellProfile(home, configHome), fmt.Sprintf("if (Test-Path %s) { . %s }", q, q)}, nil
+	}
+	return completionTarget{}, fmt.Errorf("no completion install for shell %q (want %s)", shell, strings.Join(completionShells, ", "))
+}
+
+// powershellProfile is PowerShell 7's $PROFILE for the current user and
+// host.
+func powershellProfile(home, configHome string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	}
+	return filepath.Join(configHome, "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
+// completionScript generates root's completion script for shell, with
+// descriptions where the shell can show them.
+func completionScript(root *cobra.Command, shell string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch shell {
+	case "bash":
+		err = root.GenBashCompletionV2(&buf, true)
+	case "zsh":
+		err = root.GenZshCompletion(&buf)
+	case "fish":
+		err = root.GenFishCompletion(&buf, true)
+	case "powershell", "pwsh":
+		err = root.GenPowerShellCompletionWithDesc(&buf)
+	default:
+		return nil, fmt.Errorf("no completion script for shell %q", shell)
+	}
+	return buf.Bytes(), err
+}
+
+// installCompletion writes the completion script for shell to t.script,
+// replacing an older one, and with editRC makes sure t.rc sources it. It
+// reports whether the rc file changed.
+func installCompletion(root *cobra.Command, shell string, t completionTarget, editRC bool) (bool, error) {
+	script, err := completionScript(root, shell)
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.script), 0o755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(t.script, script, 0o644); err != nil {
+		return false, err
+	}
+	if t.rc == "" || !editRC {
+		return false, nil
+	}
+	return appendOnce(t.rc, t.source)
+}
+
+// appendOnce adds line to the end of path, creating it if needed, unless
+// a line just like it is already there.
+func appendOnce(path, line string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(l) == line {
+			return false, nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	prefix := "\n"
+	if len(data) == 0 || bytes.HasSuffix(data, []byte("\n\n")) {
+		prefix = ""
+	} else if !bytes.HasSuffix(data, []byte("\n")) {
+		prefix = "\n\n"
+	}
+	if _, err := fmt.Fprintf(f, "%s# gt shell completion\n%s\n", prefix, line); err != nil {
+		f.Close()
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// runCompletionInstall installs completion for shell and says where.
+func runCompletionInstall(root *cobra.Command, shell string, editRC bool) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	t, err := completionTargetFor(shell, home)
+	if err != nil {
+		return err
+	}
+	changed, err := installCompletion(root, shell, t, editRC)
+	if err != nil {
+		return err
+	}
+	statusf(symbolColor, "Installed %s completion to %s\n", shell, t.script)
+	switch {
+	case changed:
+		statusf(symbolColor, "Added a line to %s to load it; open a new shell to use it\n", t.rc)
+	case t.rc != "" && !editRC:
+		statusf(symbolColor, "Load it from %s with:\n  %s\n", t.rc, t.source)
+	default:
+		statusf(symbolColor, "Open a new shell to use it\n")
+	}
+	return nil
+}
+
+var completionInstallCmd = &cobra.Command{
+	Use:   "install [bash|zsh|fish|powershell]",
+	Short: "Install the autocompletion script for your shell",
+	Long: `Write gt's completion script where the shell will find it and, for
+shells that do not load completions on their own, add a line to the
+startup file that sources it:
+
+  bash        ~/.local/share/bash-completion/completions/gt, sourced from ~/.bashrc
+  zsh         ~/.zfunc/_gt, sourced from ~/.zshrc
+  fish        ~/.config/fish/completions/gt.fish
+  powershell  completion.ps1 in gt's config directory, sourced from $PROFILE
+
+The shell defaults to the one in $SHELL (PowerShell on Windows). Running
+it again refreshes the script and never adds the line twice; --no-rc
+leaves startup files alone and prints the line instead. Host aliases
+complete with their hostname and groups as descriptions.`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: completionShells,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shell := detectShell()
+		if len(args) == 1 {
+			shell = args[0]
+		}
+		if shell == "" {
+			return fmt.Errorf("could not detect your shell; name it: gt completion install <%s>", strings.Join(completionShells, "|"))
+		}
+		return runCompletionInstall(cmd.Root(), shell, !completionNoRC)
+	},
+}
+
+// addCompletionInstall hangs install off cobra's default completion
+// command, which otherwise only prints scripts.
+func addCompletionInstall(root *cobra.Command) {
+	root.InitDefaultCompletionCmd()
+	for _, c := range root.Commands() {
+		if c.Name() == "completion" {
+			c.AddCommand(completionInstallCmd)
+			return
+		}
+	}
+}
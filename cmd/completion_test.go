@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionTargetFor(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("ZDOTDIR", "")
+	t.Setenv("GT_CONFIG", filepath.Join(home, "gt", "config.yaml"))
+
+	bash, err := completionTargetFor("bash", home)
+	assert.NoError(t, err)
+	script := filepath.Join(home, ".local", "share", "bash-completion", "completions", "gt")
+	assert.Equal(t, completionTarget{script, filepath.Join(home, ".bashrc"), "[ -f " + script + " ] && . " + script}, bash)
+
+	zsh, _ := completionTargetFor("zsh", home)
+	assert.Equal(t, filepath.Join(home, ".zfunc", "_gt"), zsh.script)
+	assert.Equal(t, filepath.Join(home, ".zshrc"), zsh.rc)
+
+	fish, _ := completionTargetFor("fish", home)
+	assert.Equal(t, filepath.Join(home, ".config", "fish", "completions", "gt.fish"), fish.script)
+	assert.Empty(t, fish.rc, "fish loads its completions directory by itself")
+
+	ps, _ := completionTargetFor("powershell", home)
+	assert.Equal(t, filepath.Join(home, "gt", "completion.ps1"), ps.script)
+	assert.Contains(t, ps.source, "Test-Path")
+
+	t.Setenv("ZDOTDIR", filepath.Join(home, "zdot"))
+	zsh, _ = completionTargetFor("zsh", home)
+	assert.Equal(t, filepath.Join(home, "zdot", ".zshrc"), zsh.rc)
+
+	_, err = completionTargetFor("tcsh", home)
+	assert.Error(t, err)
+}
+
+func TestInstallCompletion(t *testing.T) {
+	dir := t.TempDir()
+	target := completionTarget{
+		script: filepath.Join(dir, "completions", "_gt"),
+		rc:     filepath.Join(dir, ".zshrc"),
+		source: "source " + filepath.Join(dir, "completions", "_gt"),
+	}
+	writeConfigFile(t, target.rc, "autoload -U compinit; compinit")
+
+	changed, err := installCompletion(rootCmd, "zsh", target, true)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.FileExists(t, target.script)
+
+	changed, err = installCompletion(rootCmd, "zsh", target, true)
+	assert.NoError(t, err)
+	assert.False(t, changed, "the source line is only added once")
+	rc, _ := os.ReadFile(target.rc)
+	assert.Equal(t, "autoload -U compinit; compinit\n\n# gt shell completion\n"+target.source+"\n", string(rc))
+
+	_, err = installCompletion(rootCmd, "tcsh", target, true)
+	assert.Error(t, err)
+}
+
+func TestCompletionInstallRegistered(t *testing.T) {
+	c, _, err := rootCmd.Find([]string{"completion", "install"})
+	assert.NoError(t, err)
+	assert.Equal(t, completionInstallCmd, c)
+}
+
+func TestDescribedHosts(t *testing.T) {
+	origCfg, origGT := cfg, gtCfg
+	defer func() { cfg, gtCfg = origCfg, origGT }()
+	var err error
+	cfg, err = ssh_config.Decode(strings.NewReader("Host web1\n  HostName %h.example.com\nHost bare\n"))
+	assert.NoError(t, err)
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"web1": {Groups: []string{"web", "prod"}}}}
+
+	assert.Equal(t, []string{"bare", "web1\tweb1.example.com @web @prod"}, describedHosts())
+
+	t.Setenv("GT_REDACT", "1")
+	assert.Equal(t, "@web @prod", hostDescription("web1"), "redaction hides hostnames here too")
+
+	targets, _ := completeTargets(nil, nil, "")
+	assert.Contains(t, targets, "@prod\t1 host")
+}
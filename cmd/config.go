@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+// includeHeader opens a file made by gt config new-include.
+const includeHeader = `# %s hosts, included from %s.
+# Each Host block defines an alias, as in the main config.
+`
+
+// includeName checks a gt config new-include name and returns its file
+// name in config.d: the name with .conf, which may already be there.
+func includeName(name string) (string, error) {
+	name = strings.TrimSuffix(name, ".conf")
+	switch {
+	case name == "":
+		return "", errors.New("an include needs a name")
+	case strings.ContainsAny(name, `/\`) || name == "." || name == "..":
+		return "", fmt.Errorf("include name %q must be a plain file name, without directories", name)
+	case strings.HasPrefix(name, "."):
+		return "", fmt.Errorf("include name %q must not start with a dot", name)
+	}
+	return name + ".conf", nil
+}
+
+// topLevelIncludes are the patterns of the Include lines above the
+// config's first Host or Match, the ones that apply everywhere. Each is
+// resolved as ssh resolves it.
+func topLevelIncludes(data []byte) []string {
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		switch sshconf.Keyword(line) {
+		case "host", "match":
+			return patterns
+		case "include":
+			patterns = append(patterns, includePatterns(line)...)
+		}
+	}
+	return patterns
+}
+
+// includePatterns are the patterns an Include line names, each resolved
+// as ssh resolves it.
+func includePatterns(line string) []string {
+	line = strings.TrimSpace(line)
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	args := strings.TrimLeft(line[len("include"):], " \t")
+	args = strings.TrimPrefix(args, "=")
+	var patterns []string
+	for _, p := range sshconf.Fields(args) {
+		patterns = append(patterns, sshconf.IncludePath(p))
+	}
+	return patterns
+}
+
+// includedBy reports whether one of patterns matches file.
+func includedBy(patterns []string, file string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newInclude creates config.d/<name>.conf beside the config at path and
+// makes sure the config includes it, adding an Include line for the one
+// file unless an Include already covers it (as gt init's config.d/*
+// does). Both steps are skipped when already done.
+func newInclude(path, home, name string) (file string, created, added bool, err error) {
+	base, err := includeName(name)
+	if err != nil {
+		return "", false, false, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", false, false, withCode(exitConfig, fmt.Errorf("no SSH config at %s yet; run 'gt init' to create one", path))
+	} else if err != nil {
+		return "", false, false, err
+	}
+	dir, dirInclude := configDInclude(path, home)
+	file = filepath.Join(dir, base)
+	line := strings.TrimSuffix(dirInclude, "*") + base
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", false, false, err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	switch {
+	case errors.Is(err, fs.ErrExist):
+	case err != nil:
+		return "", false, false, err
+	default:
+		_, err = fmt.Fprintf(f, includeHeader, strings.TrimSuffix(base, ".conf"), path)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", false, false, err
+		}
+		created = true
+	}
+
+	err = editConfig(path, func(data []byte) ([]byte, error) {
+		added = false
+		if includedBy(topLevelIncludes(data), file) {
+			return nil, nil
+		}
+		added = true
+		return append([]byte(line+"\n\n"), data...), nil
+	})
+	return file, created, added, err
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the layout of your SSH config",
+}
+
+var configNewIncludeCmd = &cobra.Command{
+	Use:   "new-include <name>",
+	Short: "Create an include file in config.d and include it",
+	Long: `Create config.d/<name>.conf next to your SSH config, for hosts kept
+apart from the rest (work, home, a client), and add an Include line for it
+to the top of the config. An Include that already covers the file, such
+as gt init's "Include config.d/*", is left to do the job. Running it again
+changes nothing.`,
+	Example: `  gt config new-include work`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		path, err := sshConfigPath()
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		file, created, added, err := newInclude(path, home, args[0])
+		if err != nil {
+			return err
+		}
+		if created {
+			statusf(symbolColor, "Created %s\n", file)
+		} else {
+			statusf(symbolColor, "%s already exists\n", file)
+		}
+		if added {
+			statusf(symbolColor, "Added an Include for it to %s\n", path)
+		} else {
+			statusf(symbolColor, "%s already includes it\n", path)
+		}
+		return nil
+	},
+}
+
+var configOpenDirCmd = &cobra.Command{
+	Use:   "open-dir",
+	Short: "Open the SSH config's directory in the file manager",
+	Long: `Open the directory of your SSH config (~/.ssh, or that of --config) in
+the platform's file manager: open on macOS, Explorer on Windows, xdg-open
+elsewhere.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := sshConfigPath()
+		if err != nil {
+			return err
+		}
+		dir := filepath.Dir(path)
+		if _, err := os.Stat(dir); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		// Not $BROWSER: it is for URLs, and is often a terminal browser.
+		argv := browserCommand(runtime.GOOS, func(string) string { return "" }, dir)
+		c := execCommand(argv[0], argv[1:]...)
+		c.Stdout, c.Stderr = os.Stderr, os.Stderr
+		debugf(1, "exec: %s", quoteArgv(c.Args))
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("opening %s with %s: %w", dir, argv[0], err)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludeName(t *testing.T) {
+	for in, want := range map[string]string{"work": "work.conf", "work.conf": "work.conf", "a.b": "a.b.conf"} {
+		got, err := includeName(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got)
+	}
+	for _, in := range []string{"", ".conf", "../x", "a/b", `a\b`, ".hidden", ".."} {
+		_, err := includeName(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestTopLevelIncludes(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	data := []byte("# Include commented\nInclude config.d/* /etc/ssh/extra # two\ninclude=~/work.conf\n\nHost a\n  Include only-for-a\n")
+	assert.Equal(t, []string{
+		filepath.Join(home, ".ssh", "config.d", "*"),
+		"/etc/ssh/extra",
+		filepath.Join(home, "work.conf"),
+	}, topLevelIncludes(data), "an Include under Host applies to that host only")
+}
+
+func TestNewInclude(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".ssh", "config")
+
+	_, _, _, err := newInclude(path, home, "work")
+	assert.ErrorContains(t, err, "run 'gt init'")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte("Host a\n  User me\n"), 0o600))
+	file, created, added, err := newInclude(path, home, "work")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".ssh", "config.d", "work.conf"), file)
+	assert.True(t, created)
+	assert.True(t, added)
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "Include config.d/work.conf\n\nHost a\n  User me\n", string(data))
+	body, _ := os.ReadFile(file)
+	assert.True(t, strings.HasPrefix(string(body), "# work hosts, included from "+path), string(body))
+	if os.PathSeparator == '/' {
+		info, _ := os.Stat(file)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// Again: nothing to do.
+	require.NoError(t, os.WriteFile(file, []byte("Host w\n"), 0o600))
+	_, created, added, err = newInclude(path, home, "work.conf")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.False(t, added)
+	body, _ = os.ReadFile(file)
+	assert.Equal(t, "Host w\n", string(body), "an existing file is kept")
+	data2, _ := os.ReadFile(path)
+	assert.Equal(t, string(data), string(data2))
+}
+
+func TestNewIncludeCoveredByGlob(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".ssh", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	config := "Include config.d/*\n\nHost a\n"
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+
+	_, created, added, err := newInclude(path, home, "home")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.False(t, added, "gt init's Include already covers it")
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, config, string(data))
+}
+
+func TestConfigOpenDir(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("BROWSER", "lynx")
+	dir := t.TempDir()
+	origCfgFile := cfgFile
+	t.Cleanup(func() { cfgFile = origCfgFile })
+	cfgFile = filepath.Join(dir, "config")
+
+	require.NoError(t, configOpenDirCmd.RunE(configOpenDirCmd, nil))
+	require.Len(t, mockCmd.commands, 1)
+	assert.NotEqual(t, "lynx", mockCmd.commands[0], "not the web browser")
+	args := mockCmd.argLists[0]
+	assert.Equal(t, dir, args[len(args)-1])
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var configDiffExitCode bool
+
+// configOptions is every Host alias of one config with the options ssh -G
+// resolves for it from that config alone.
+type configOptions map[string]map[string][]string
+
+// resolveConfigFile asks ssh -G what each alias in the config at path
+// resolves to, reading that file instead of gt's. Includes are followed as
+// ssh follows them, so relative ones still land in ~/.ssh.
+func resolveConfigFile(path string) (configOptions, error) {
+	loaded, err := sshconf.Load(path, sshconf.Options{
+		Logf: func(format string, args ...any) { debugf(2, format, args...) },
+		Warnf: func(format string, args ...any) {
+			warningColor.Fprintf(os.Stderr, format+"\n", args...)
+		},
+	})
+	if err != nil {
+		return nil, withCode(exitConfig, err)
+	}
+	aliases := sshconf.Aliases(loaded.Config)
+	opts := make([]map[string][]string, len(aliases))
+	errs := make([]error, len(aliases))
+	sem := make(chan struct{}, 8)
+	var wg sync.WaitGroup
+	for i, alias := range aliases {
+		wg.Add(1)
+		go func(i int, alias string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			out, err := sshCommand("-F", path, "-G", "--", alias).Output()
+			opts[i], errs[i] = sshconf.ParseOptions(out), err
+		}(i, alias)
+	}
+	wg.Wait()
+
+	var failed []string
+	c := configOptions{}
+	for i, alias := range aliases {
+		if errs[i] != nil {
+			failed = append(failed, alias)
+			continue
+		}
+		c[alias] = opts[i]
+	}
+	if len(failed) > 0 {
+		return nil, withCode(exitConfig, fmt.Errorf("ssh -G -F %s failed for %s", path, strings.Join(failed, ", ")))
+	}
+	return c, nil
+}
+
+// optionChange is one option of a host that differs between two configs;
+// from or to is nil where the option is not set.
+type optionChange struct {
+	key      string
+	from, to []string
+}
+
+// hostChange is a host added, removed or changed between two configs.
+type hostChange struct {
+	alias   string
+	added   bool
+	removed bool
+	options []optionChange
+}
+
+// diffConfigOptions compares two resolved configs host by host, in alias
+// order, and each changed host's options in key order. Hosts that
+// resolve the same on both sides are left out.
+func diffConfigOptions(a, b configOptions) []hostChange {
+	aliases := make([]string, 0, len(a)+len(b))
+	for alias := range a {
+		aliases = append(aliases, alias)
+	}
+	for alias := range b {
+		if _, ok := a[alias]; !ok {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+
+	var changes []hostChange
+	for _, alias := range aliases {
+		from, inA := a[alias]
+		to, inB := b[alias]
+		switch {
+		case !inA:
+			changes = append(changes, hostChange{alias: alias, added: true})
+			continue
+		case !inB:
+			changes = append(changes, hostChange{alias: alias, removed: true})
+			continue
+		}
+		keys := make([]string, 0, len(from)+len(to))
+		for key := range from {
+			keys = append(keys, key)
+		}
+		for key := range to {
+			if _, ok := from[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		var options []optionChange
+		for _, key := range keys {
+			if !equalValues(from[key], to[key]) {
+				options = append(options, optionChange{key, from[key], to[key]})
+			}
+		}
+		if len(options) > 0 {
+			changes = append(changes, hostChange{alias: alias, options: options})
+		}
+	}
+	return changes
+}
+
+// renderConfigDiff writes changes as + for hosts only in the second config,
+// - for hosts only in the first, and ~ with the options that changed for
+// the rest.
+func renderConfigDiff(w io.Writer, changes []hostChange) {
+	for _, c := range changes {
+		switch {
+		case c.added:
+			userColor.Fprintf(w, "+ %s\n", c.alias)
+		case c.removed:
+			errorColor.Fprintf(w, "- %s\n", c.alias)
+		default:
+			aliasColor.Fprintf(w, "~ %s\n", c.alias)
+			for _, o := range c.options {
+				switch {
+				case o.from == nil:
+					userColor.Fprintf(w, "    + %s %s\n", o.key, strings.Join(o.to, ", "))
+				case o.to == nil:
+					errorColor.Fprintf(w, "    - %s %s\n", o.key, strings.Join(o.from, ", "))
+				default:
+					fmt.Fprintf(w, "    %s %s -> %s\n", o.key, strings.Join(o.from, ", "), strings.Join(o.to, ", "))
+				}
+			}
+		}
+	}
+}
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff <config> <config>",
+	Short: "Compare two SSH configs host by host",
+	Long: `Compare two SSH configs by what they mean rather than how they are
+written: which Host aliases the second adds (+) or drops (-), and for the
+hosts in both, every option that resolves differently (~), as ssh -G
+resolves it from each file. Reordering blocks, moving hosts into
+includes or renaming a wildcard block shows up only where a host's
+settings change; for the lines themselves, use gt diff.
+
+For reviewing a teammate's change to a shared config, or a config before
+and after gt config split. Relative Includes are read from ~/.ssh for
+both files, as ssh reads them. gt exits 0 whether or not the configs
+differ, unless --exit-code.`,
+	Example: `  gt config diff ~/.ssh/config team/ssh_config
+  gt config diff config.orig ~/.ssh/config --exit-code`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if usePuTTY() {
+			return errors.New("gt config diff asks OpenSSH's ssh -G for every value; the PuTTY backend has no equivalent")
+		}
+		cmd.SilenceUsage = true
+		var sides [2]configOptions
+		for i, path := range args {
+			c, err := resolveConfigFile(path)
+			if err != nil {
+				return err
+			}
+			sides[i] = c
+		}
+		changes := diffConfigOptions(sides[0], sides[1])
+		if len(changes) == 0 {
+			statusf(userColor, "%s and %s resolve the same for every host\n", args[0], args[1])
+			return nil
+		}
+		var out bytes.Buffer
+		renderConfigDiff(&out, changes)
+		if err := pageOutput(out.Bytes()); err != nil {
+			return err
+		}
+		if configDiffExitCode {
+			return errFilesDiffer
+		}
+		return nil
+	},
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfigOptions(t *testing.T) {
+	a := configOptions{
+		"same": {"port": {"22"}},
+		"gone": {"port": {"22"}},
+		"web":  {"port": {"22"}, "user": {"deploy"}, "identityfile": {"~/.ssh/a"}, "proxyjump": {"bastion"}},
+	}
+	b := configOptions{
+		"same": {"port": {"22"}},
+		"new":  {"port": {"22"}},
+		"web":  {"port": {"2222"}, "user": {"deploy"}, "identityfile": {"~/.ssh/a", "~/.ssh/b"}, "forwardagent": {"yes"}},
+	}
+	changes := diffConfigOptions(a, b)
+	require.Len(t, changes, 3)
+	assert.Equal(t, hostChange{alias: "gone", removed: true}, changes[0])
+	assert.Equal(t, hostChange{alias: "new", added: true}, changes[1])
+	assert.Equal(t, []optionChange{
+		{"forwardagent", nil, []string{"yes"}},
+		{"identityfile", []string{"~/.ssh/a"}, []string{"~/.ssh/a", "~/.ssh/b"}},
+		{"port", []string{"22"}, []string{"2222"}},
+		{"proxyjump", []string{"bastion"}, nil},
+	}, changes[2].options)
+
+	plainOutput(t)
+	var out bytes.Buffer
+	renderConfigDiff(&out, changes)
+	assert.Equal(t, `- gone
++ new
+~ web
+    + forwardagent yes
+    identityfile ~/.ssh/a -> ~/.ssh/a, ~/.ssh/b
+    port 22 -> 2222
+    - proxyjump bastion
+`, out.String())
+
+	assert.Empty(t, diffConfigOptions(a, a))
+}
+
+func TestConfigDiff(t *testing.T) {
+	useMockExec(t)
+	plainOutput(t)
+	orig := color.Output
+	t.Cleanup(func() { color.Output = orig })
+	var out bytes.Buffer
+	color.Output = &out
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.conf"), filepath.Join(dir, "b.conf")
+	require.NoError(t, os.WriteFile(a, []byte("Host web db\n  User deploy\n\nHost *\n  Port 22\n"), 0o600))
+	require.NoError(t, os.WriteFile(b, []byte("Host db\n  User deploy\nHost cache\n"), 0o600))
+
+	require.NoError(t, configDiffCmd.RunE(configDiffCmd, []string{a, b}))
+	assert.Equal(t, "+ cache\n- web\n", out.String())
+	require.NotEmpty(t, mockCmd.argLists)
+	assert.Contains(t, mockCmd.argLists[0], a, "ssh -G reads the file given, not gt's config")
+
+	configDiffExitCode = true
+	t.Cleanup(func() { configDiffExitCode = false })
+	assert.Equal(t, 1, ExitCode(configDiffCmd.RunE(configDiffCmd, []string{a, b})))
+	assert.NoError(t, configDiffCmd.RunE(configDiffCmd, []string{a, a}), "identical configs are no failure")
+
+	_, err := resolveConfigFile(filepath.Join(dir, "missing"))
+	assert.Equal(t, exitConfig, ExitCode(err))
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// configEditRetries is how many times editConfig starts over on a config
+// that changed under it before giving up.
+const configEditRetries = 3
+
+// errConfigChanged is behind the error of a write that found the config
+// no longer as gt read it.
+var errConfigChanged = errors.New("changed since gt read it")
+
+// configEdit is an SSH config file as gt read it, to be changed and
+// written back. Between the two another gt, or an editor, may save the
+// file, and writing over that would lose their change: commit checks the
+// file still hashes as read, holding a lock other gt runs honor.
+type configEdit struct {
+	path string
+	data []byte
+	// hash is the file's as read, "" for a file that did not exist (see
+	// hashFile).
+	hash string
+	// plain is set for an encrypted include: the plaintext copy gt
+	// decrypted, which data is, the file holding its ciphertext.
+	plain string
+}
+
+// readConfigEdit reads path for an edit; a missing file reads as empty,
+// and an encrypted include gt decrypted as its plaintext.
+func readConfigEdit(path string) (*configEdit, error) {
+	if plain, ok := decrypted[path]; ok {
+		return readEncryptedEdit(path, plain)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	e := &configEdit{path: path, data: data}
+	if err == nil {
+		sum := sha256.Sum256(data)
+		e.hash = hex.EncodeToString(sum[:])
+	}
+	return e, nil
+}
+
+// lockConfig takes the lock for edits of path. It lives in gt's state
+// directory: next to the config, a config.d/* Include would read it.
+func lockConfig(path string) (func(), error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return lockState("config-" + hex.EncodeToString(sum[:6]))
+}
+
+// commit writes data over the file, in one rename that keeps its mode
+// (0600, in a 0700 directory, for a new one) and any symlink to it, as
+// long as it has not changed since it was read. An encrypted include is
+// written encrypted, and its plaintext copy updated.
+func (e *configEdit) commit(data []byte) error {
+	release, err := lockConfig(e.path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	cur, err := hashFile(e.path)
+	if err != nil {
+		return err
+	}
+	if cur != e.hash {
+		return fmt.Errorf("%s %w, by another gt or an editor; nothing was written, run the command again", e.path, errConfigChanged)
+	}
+	target, mode := e.path, os.FileMode(0o600)
+	if e.hash != "" {
+		if target, err = filepath.EvalSymlinks(e.path); err != nil {
+			return err
+		}
+		info, err := os.Stat(target)
+		if err != nil {
+			return err
+		}
+		mode = info.Mode().Perm()
+	} else if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return err
+	}
+	out := data
+	if e.plain != "" {
+		if out, err = encryptInclude(e.path, data); err != nil {
+			return err
+		}
+	}
+	if err := replaceFile(target, out, mode); err != nil {
+		return err
+	}
+	if e.plain != "" {
+		if err := os.WriteFile(e.plain, data, 0o600); err != nil {
+			return err
+		}
+	}
+	sum := sha256.Sum256(out)
+	e.data, e.hash = data, hex.EncodeToString(sum[:])
+	if e.plain != "" {
+		decryptedHashes[e.path] = e.hash
+	}
+	return nil
+}
+
+// editConfig applies edit to the config at path and commits the result.
+// On a file that changed in between, it reads it again and reapplies
+// edit, so edit must derive the new contents from the old alone. edit
+// returning nil leaves the file as it is.
+func editConfig(path string, edit func(data []byte) ([]byte, error)) error {
+	for attempt := 1; ; attempt++ {
+		e, err := readConfigEdit(path)
+		if err != nil {
+			return err
+		}
+		data, err := edit(e.data)
+		if err != nil || data == nil {
+			return err
+		}
+		err = e.commit(data)
+		if !errors.Is(err, errConfigChanged) || attempt == configEditRetries {
+			return err
+		}
+		debugf(1, "config: %s changed while editing, starting over", path)
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigEditCommit(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte("Host a\n"), 0o640))
+
+	e, err := readConfigEdit(path)
+	require.NoError(t, err)
+	require.NoError(t, e.commit([]byte("Host a\nHost b\n")))
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "Host a\nHost b\n", string(data))
+	if os.PathSeparator == '/' {
+		info, _ := os.Stat(path)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm(), "the mode is kept")
+	}
+	require.NoError(t, e.commit([]byte("Host c\n")), "a commit updates what was read")
+
+	// Someone saves the file after gt read it.
+	e, err = readConfigEdit(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("Host edited\n"), 0o640))
+	err = e.commit([]byte("Host gt\n"))
+	assert.ErrorIs(t, err, errConfigChanged)
+	assert.ErrorContains(t, err, path+" changed since gt read it")
+	data, _ = os.ReadFile(path)
+	assert.Equal(t, "Host edited\n", string(data), "the edit is not lost")
+}
+
+func TestConfigEditNewFile(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	path := filepath.Join(t.TempDir(), ".ssh", "config")
+	e, err := readConfigEdit(path)
+	require.NoError(t, err)
+	assert.Empty(t, e.data)
+	require.NoError(t, e.commit([]byte("Host a\n")))
+	if os.PathSeparator == '/' {
+		info, _ := os.Stat(path)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		info, _ = os.Stat(filepath.Dir(path))
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	}
+
+	// Created by someone else in between.
+	other := filepath.Join(filepath.Dir(path), "other")
+	e, _ = readConfigEdit(other)
+	require.NoError(t, os.WriteFile(other, []byte("x"), 0o600))
+	assert.ErrorIs(t, e.commit([]byte("y")), errConfigChanged)
+}
+
+func TestConfigEditKeepsSymlink(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "ssh_config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(target), 0o700))
+	require.NoError(t, os.WriteFile(target, []byte("Host a\n"), 0o600))
+	link := filepath.Join(dir, "config")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("no symlinks:", err)
+	}
+
+	require.NoError(t, editConfig(link, func(data []byte) ([]byte, error) {
+		return append(data, "Host b\n"...), nil
+	}))
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink, "still a symlink")
+	data, _ := os.ReadFile(target)
+	assert.Equal(t, "Host a\nHost b\n", string(data))
+}
+
+func TestEditConfigStartsOver(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte("Host a\n"), 0o600))
+
+	calls := 0
+	err := editConfig(path, func(data []byte) ([]byte, error) {
+		calls++
+		if calls == 1 {
+			// Another gt gets its write in first.
+			require.NoError(t, os.WriteFile(path, []byte("Host a\nHost other\n"), 0o600))
+		}
+		return append(data, "Host b\n"...), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "Host a\nHost other\nHost b\n", string(data), "both changes kept")
+
+	calls = 0
+	err = editConfig(path, func(data []byte) ([]byte, error) {
+		calls++
+		require.NoError(t, os.WriteFile(path, []byte{byte('0' + calls)}, 0o600))
+		return []byte("mine"), nil
+	})
+	assert.ErrorIs(t, err, errConfigChanged)
+	assert.Equal(t, configEditRetries, calls)
+
+	assert.NoError(t, editConfig(path, func([]byte) ([]byte, error) { return nil, nil }), "nil leaves it alone")
+}
@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gt/pkg/sshconf"
+)
+
+// encryptedIncludeMarker introduces an encrypted include in the main SSH
+// config. It is a comment on purpose: plain ssh skips it (and so simply
+// does not see those hosts), while gt decrypts the file and substitutes a
+// real Include at the same position, so ordering and conditional-include
+// semantics are exactly those of an ordinary Include line.
+const encryptedIncludeMarker = "gt:include-encrypted"
+
+var (
+	// runtimeDir holds every plaintext file gt writes during one run:
+	// decrypted includes and the rewritten main config that points at
+	// them. It is created 0700 on first use and removed by Execute.
+	runtimeDir string
+	// effectiveConfig is the rewritten main config handed to ssh/scp via
+	// -F when the real one contains encrypted includes; "" otherwise.
+	effectiveConfig string
+	// decrypted caches plaintext paths by source so a file referenced
+	// twice is only decrypted (and prompted for) once.
+	decrypted = map[string]string{}
+	// decryptedHashes is each decrypted source's hashFile as gt read it,
+	// so an edit is encrypted back only over the file it was made from.
+	decryptedHashes = map[string]string{}
+)
+
+// configQuote is path as an argument in an SSH config line, double
+// quoted, as ssh reads it, when it holds a space or a quote.
+func configQuote(path string) string {
+	if !strings.ContainsAny(path, " \t\"'") {
+		return path
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path) + `"`
+}
+
+// readEncryptedEdit is an edit of the encrypted include source, which
+// gt decrypted to plain: its data is the plaintext, and commit encrypts
+// the new plaintext to the file's recipients again.
+func readEncryptedEdit(source, plain string) (*configEdit, error) {
+	data, err := os.ReadFile(plain)
+	if err != nil {
+		return nil, err
+	}
+	return &configEdit{path: source, data: data, hash: decryptedHashes[source], plain: plain}, nil
+}
+
+// encryptInclude encrypts plaintext, the new contents of the encrypted
+// include at path, as path is encrypted now, with the terminal attached
+// for a passphrase or a pinentry.
+func encryptInclude(path string, plaintext []byte) ([]byte, error) {
+	var tool string
+	var args []string
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".age":
+		tool = "age"
+		args, err = ageEncryptArgs(path)
+	case ".gpg", ".asc":
+		tool = "gpg"
+		args, err = gpgEncryptArgs(path)
+	default:
+		err = fmt.Errorf("encrypted include %s: unknown format (want .age, .gpg or .asc)", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	debugf(3, "encrypting %s: %s %s", path, tool, quoteArgv(args))
+	c := execCommand(tool, args...)
+	c.Stdin = bytes.NewReader(plaintext)
+	c.Stderr = os.Stderr
+	out, err := outputTracked(c)
+	if err != nil {
+		return nil, fmt.Errorf("%s --encrypt %s: %w", tool, path, err)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s --encrypt %s: no output", tool, path)
+	}
+	return out, nil
+}
+
+// ageEncryptArgs are age's arguments for encrypting path again. An age
+// file does not name its recipients, so they come from path.recipients
+// beside it, in age -R's format, or else are GT_AGE_IDENTITY's own; a
+// file encrypted with a passphrase gets one again, asked on the terminal.
+func ageEncryptArgs(path string) ([]string, error) {
+	args := []string{"--encrypt"}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("-----BEGIN AGE ENCRYPTED FILE-----")) {
+		args = append(args, "--armor")
+	}
+	header, _, _ := bytes.Cut(data, []byte("\n---"))
+	recipients := path + ".recipients"
+	_, statErr := os.Stat(recipients)
+	switch {
+	case statErr == nil:
+		args = append(args, "-R", recipients)
+	case bytes.Contains(header, []byte("\n-> scrypt ")):
+		if nonInteractive() {
+			return nil, fmt.Errorf("encrypted include %s takes a passphrase, and there is no one to ask", path)
+		}
+		args = append(args, "--passphrase")
+	case os.Getenv("GT_AGE_IDENTITY") != "":
+		args = append(args, "-i", os.Getenv("GT_AGE_IDENTITY"))
+	default:
+		return nil, fmt.Errorf("encrypted include %s: no recipients to encrypt it to; list them in %s or set GT_AGE_IDENTITY", path, recipients)
+	}
+	return args, nil
+}
+
+// gpgEncryptArgs are gpg's arguments for encrypting path again: to the
+// keys it is encrypted to now, as gpg --list-packets reports them, or
+// with a passphrase for a file that has none. Those keys are trusted as
+// they are; they were the file's already.
+func gpgEncryptArgs(path string) ([]string, error) {
+	c := execCommand("gpg", "--batch", "--list-only", "--list-packets", "--", path)
+	out, _ := c.Output()
+	var keys []string
+	symmetric := false
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, ":pubkey enc packet:"):
+			_, id, _ := strings.Cut(line, "keyid ")
+			if id = strings.TrimSpace(id); id == "" || strings.Trim(id, "0") == "" {
+				return nil, fmt.Errorf("encrypted include %s has a hidden recipient gt cannot encrypt to", path)
+			}
+			keys = append(keys, id)
+		case strings.HasPrefix(line, ":symkey enc packet:"):
+			symmetric = true
+		}
+	}
+	args := []string{"--quiet", "--yes"}
+	if strings.EqualFold(filepath.Ext(path), ".asc") {
+		args = append(args, "--armor")
+	}
+	switch {
+	case len(keys) > 0:
+		args = append(args, "--batch", "--trust-model", "always", "--encrypt")
+		for _, k := range keys {
+			args = append(args, "--recipient", k)
+		}
+	case symmetric && !nonInteractive():
+		args = append(args, "--symmetric")
+	case symmetric:
+		return nil, fmt.Errorf("encrypted include %s takes a passphrase, and there is no one to ask", path)
+	default:
+		return nil, fmt.Errorf("encrypted include %s: gpg names no recipients for it", path)
+	}
+	return append(args, "--output", "-"), nil
+}
+
+// markerPath returns the path argument of an encrypted-include marker
+// line, or "" if the line is not one.
+func markerPath(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "#") {
+		return ""
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+	if !strings.HasPrefix(rest, encryptedIncludeMarker) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(rest, encryptedIncludeMarker))
+}
+
+// expandEncryptedIncludes rewrites every marker line in a config body into
+// an Include of the decrypted plaintext, preserving indentation so a
+// marker inside a Host block stays conditional. sources lists the
+// encrypted files it read; none means the body had no markers and
+// callers leave the config untouched.
+func expandEncryptedIncludes(body []byte) (out []byte, sources []string, err error) {
+	var buf bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		path := markerPath(line)
+		if path == "" {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+			continue
+		}
+		source := sshconf.IncludePath(path)
+		plain, err := decryptInclude(source)
+		if err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, source)
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		fmt.Fprintf(&buf, "%sInclude %s\n", indent, configQuote(plain))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), sources, nil
+}
+
+// decryptInclude decrypts an age- or GPG-encrypted file into the runtime
+// directory and returns the plaintext path. The tool is chosen by suffix
+// and run with the terminal attached, so age can prompt for a passphrase
+// and gpg can reach its agent's pinentry. GT_AGE_IDENTITY names an age
+// identity file for key-based decryption.
+func decryptInclude(path string) (string, error) {
+	if plain, ok := decrypted[path]; ok {
+		return plain, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("encrypted include: %w", err)
+	}
+	err = sshconf.ValidateOpen(path, f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	var tool string
+	var args []string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".age":
+		tool = "age"
+		args = []string{"--decrypt"}
+		if id := os.Getenv("GT_AGE_IDENTITY"); id != "" {
+			args = append(args, "-i", id)
+		} else if nonInteractive() {
+			// Without an identity age asks for a passphrase on the tty.
+			return "", fmt.Errorf("encrypted include %s: set GT_AGE_IDENTITY to decrypt without a passphrase prompt", path)
+		}
+		args = append(args, "--", path)
+	case ".gpg", ".asc":
+		tool = "gpg"
+		args = []string{"--quiet", "--decrypt", "--", path}
+		if nonInteractive() {
+			// Fail instead of asking; a passphrase cached by the agent still works.
+			args = append([]string{"--batch"}, args...)
+		}
+	default:
+		return "", fmt.Errorf("encrypted include %s: unknown format (want .age, .gpg or .asc)", path)
+	}
+
+	debugf(3, "decrypting %s: %s %s", path, tool, quoteArgv(args))
+	c := execCommand(tool, args...)
+	c.Stdin = os.Stdin
+	c.Stderr = os.Stderr
+	plaintext, err := outputTracked(c)
+	if err != nil {
+		return "", fmt.Errorf("%s --decrypt %s: %w", tool, path, err)
+	}
+
+	plain, err := writeRuntimeFile("include-*.conf", plaintext)
+	if err != nil {
+		return "", err
+	}
+	decrypted[path], decryptedHashes[path] = plain, hash
+	return plain, nil
+}
+
+// isRuntimeFile reports whether path is one of gt's own plaintext
+// copies, which stand in for an encrypted source and must never be
+// treated as part of the user's config (synced, edited, reported).
+func isRuntimeFile(path string) bool {
+	return runtimeDir != "" && strings.HasPrefix(path, runtimeDir+string(filepath.Separator))
+}
+
+// writeRuntimeFile stores data in a fresh 0600 file inside the private
+// runtime directory, preferring XDG_RUNTIME_DIR (usually tmpfs) so
+// plaintext never touches persistent storage where that is avoidable.
+func writeRuntimeFile(pattern string, data []byte) (string, error) {
+	dir, err := ensureRuntimeDir()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// ensureRuntimeDir creates the private runtime directory on first use.
+// Without XDG_RUNTIME_DIR it goes in /tmp/gt-<uid>, made 0700 and
+// checked, rather than the system temp directory: macOS's is so deep
+// that a ControlPath socket in it would pass the 104 bytes a unix socket
+// path may have, once ssh adds its 17-byte suffix.
+func ensureRuntimeDir() (string, error) {
+	if runtimeDir == "" {
+		base, prefix := os.Getenv("XDG_RUNTIME_DIR"), "gt-"
+		if base == "" && runtime.GOOS != "windows" {
+			base, prefix = filepath.Join("/tmp", fmt.Sprintf("gt-%d", os.Getuid())), ""
+			if err := privateDir(base); err != nil {
+				return "", err
+			}
+		}
+		dir, err := os.MkdirTemp(base, prefix)
+		if err != nil {
+			return "", err
+		}
+		runtimeDir = dir
+	}
+	return runtimeDir, nil
+}
+
+// systemSSHConfig is where OpenSSH keeps the system-wide client config:
+// %ProgramData%\ssh on Win32-OpenSSH, /etc/ssh everywhere else.
+func systemSSHConfig() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "ssh", "ssh_config")
+	}
+	return "/etc/ssh/ssh_config"
+}
+
+// removeRuntimeDir deletes every plaintext file written during this run.
+func removeRuntimeDir() {
+	if runtimeDir == "" {
+		return
+	}
+	os.RemoveAll(runtimeDir)
+	runtimeDir = ""
+	decrypted, decryptedHashes = map[string]string{}, map[string]string{}
+}
+
+// writeEffectiveConfig stores the rewritten main config for ssh/scp,
+// followed by the team hosts in the file team, if any. Passing -F makes
+// ssh skip the system-wide config, so it is re-included behind
+// "Match all", which keeps the Include unconditional and, being read
+// last, lets it supply defaults just as it normally would.
+func writeEffectiveConfig(body []byte, team string) (string, error) {
+	var buf bytes.Buffer
+	buf.Write(body)
+	if team != "" {
+		fmt.Fprintf(&buf, "\nMatch all\n  Include %s\n", configQuote(team))
+	}
+	if cfgFile == "" {
+		fmt.Fprintf(&buf, "\nMatch all\n  Include %s\n", configQuote(systemSSHConfig()))
+	}
+	return writeRuntimeFile("config-*", buf.Bytes())
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkerPath(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"# gt:include-encrypted ~/.ssh/secret.age", "~/.ssh/secret.age"},
+		{"  #gt:include-encrypted secret.gpg", "secret.gpg"},
+		{"# an ordinary comment", ""},
+		{"Include secret.age", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, markerPath(tt.line), "line=%q", tt.line)
+	}
+}
+
+func TestLoadConfigDecryptsMarkedIncludes(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	useMockExec(t)
+	t.Cleanup(removeRuntimeDir)
+
+	origCfgFile := cfgFile
+	defer func() { cfgFile = origCfgFile }()
+	cfgFile = ""
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config")
+	secret := filepath.Join(dir, "secret.conf.gpg")
+	writeConfigFile(t, secret, "ciphertext")
+	writeConfigFile(t, main, "# gt:include-encrypted "+secret+"\n\nHost alpha\n  Hostname alpha.example.com\n")
+
+	if err := loadConfig(main); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	assert.Equal(t, []string{"alpha", "secret"}, getHosts())
+	assert.Equal(t, "gpg", mockCmd.commands[0])
+	assert.Equal(t, []string{"--quiet", "--decrypt", "--", secret}, mockCmd.argLists[0])
+
+	// ssh must be pointed at the rewritten config, which includes the
+	// plaintext where the marker was and keeps the system config.
+	args := sshBaseArgs()
+	assert.Equal(t, "-F", args[0])
+	data, err := os.ReadFile(args[1])
+	if err != nil {
+		t.Fatalf("read effective config: %v", err)
+	}
+	body := string(data)
+	assert.True(t, strings.HasPrefix(body, "Include "+filepath.Join(runtimeDir, "include-")), body)
+	assert.Contains(t, body, "Match all\n  Include /etc/ssh/ssh_config\n")
+}
+
+func TestLoadConfigWithoutMarkersKeepsConfig(t *testing.T) {
+	useMockExec(t)
+
+	origCfgFile := cfgFile
+	defer func() { cfgFile = origCfgFile }()
+	dir := t.TempDir()
+	cfgFile = filepath.Join(dir, "config")
+	writeConfigFile(t, cfgFile, "Host alpha\n  Hostname alpha.example.com\n")
+
+	if err := loadConfig(cfgFile); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	assert.Empty(t, mockCmd.commands, "nothing to decrypt, nothing to exec")
+	assert.Equal(t, []string{"-F", cfgFile}, sshBaseArgs())
+}
+
+func TestDecryptIncludeRejectsUnknownFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.conf")
+	writeConfigFile(t, path, "Host x\n")
+	_, err := decryptInclude(path)
+	assert.ErrorContains(t, err, "unknown format")
+}
+
+func TestConfigQuote(t *testing.T) {
+	assert.Equal(t, "/run/user/1000/gt-1/include-2.conf", configQuote("/run/user/1000/gt-1/include-2.conf"))
+	assert.Equal(t, `"C:\\Users\\Jo Doe\\Temp\\include-2.conf"`, configQuote(`C:\Users\Jo Doe\Temp\include-2.conf`))
+}
+
+func TestEncryptedIncludeEditsAreEncrypted(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(t.TempDir(), "with space"))
+	if err := os.Mkdir(os.Getenv("XDG_RUNTIME_DIR"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GT_STATE_DIR", t.TempDir())
+	useMockExec(t)
+	t.Cleanup(removeRuntimeDir)
+	origCfgFile, origCfg, origFiles, origEffective := cfgFile, cfg, loadedFiles, effectiveConfig
+	defer func() {
+		cfgFile, cfg, loadedFiles, effectiveConfig = origCfgFile, origCfg, origFiles, origEffective
+	}()
+	cfgFile = ""
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config")
+	secret := filepath.Join(dir, "secret.conf.gpg")
+	writeConfigFile(t, secret, "ciphertext")
+	writeConfigFile(t, main, "# gt:include-encrypted "+secret+"\n\nHost alpha\n  Hostname alpha.example.com\n")
+	if err := loadConfig(main); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	assert.Equal(t, []string{"alpha", "secret"}, getHosts(), "the quoted plaintext path is included")
+	assert.Contains(t, loadedFiles, secret)
+
+	plan, err := planPrune([]string{"secret"})
+	require.NoError(t, err)
+	require.Len(t, plan.files, 1)
+	assert.Equal(t, secret, plan.files[0].edit.path)
+	mockCmd.reset()
+	require.NoError(t, plan.write())
+
+	data, err := os.ReadFile(secret)
+	require.NoError(t, err)
+	assert.Equal(t, "ENC:", string(data), "the edited plaintext is encrypted back over the file")
+	last := mockCmd.argLists[len(mockCmd.argLists)-1]
+	assert.Equal(t, "gpg", mockCmd.commands[len(mockCmd.commands)-1])
+	assert.Subset(t, last, []string{"--encrypt", "--recipient", "0123456789ABCDEF"}, "to the keys it was encrypted to")
+	plain, err := os.ReadFile(decrypted[secret])
+	require.NoError(t, err)
+	assert.NotContains(t, string(plain), "Host secret", "the plaintext copy follows")
+
+	e, err := readConfigEdit(secret)
+	require.NoError(t, err)
+	require.NoError(t, e.commit([]byte("Host again\n")), "a second edit in the same run starts from the first")
+	writeConfigFile(t, secret, "changed elsewhere")
+	e, err = readConfigEdit(secret)
+	require.NoError(t, err)
+	assert.ErrorIs(t, e.commit([]byte("Host x\n")), errConfigChanged, "nothing is written over a file changed since it was decrypted")
+}
+
+func TestAgeEncryptArgs(t *testing.T) {
+	t.Setenv("GT_NONINTERACTIVE", "")
+	t.Setenv("GT_AGE_IDENTITY", "")
+	dir := t.TempDir()
+	keyed := filepath.Join(dir, "hosts.age")
+	writeConfigFile(t, keyed, "age-encryption.org/v1\n-> X25519 abc\nxyz\n--- mac\nbody")
+	_, err := ageEncryptArgs(keyed)
+	assert.ErrorContains(t, err, "no recipients")
+
+	t.Setenv("GT_AGE_IDENTITY", "/k/age.key")
+	args, err := ageEncryptArgs(keyed)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--encrypt", "-i", "/k/age.key"}, args)
+
+	writeConfigFile(t, keyed+".recipients", "age1xyz\n")
+	args, err = ageEncryptArgs(keyed)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--encrypt", "-R", keyed + ".recipients"}, args, "a recipients file wins")
+
+	pass := filepath.Join(dir, "pass.age")
+	writeConfigFile(t, pass, "age-encryption.org/v1\n-> scrypt salt 18\nxyz\n--- mac\nbody")
+	args, err = ageEncryptArgs(pass)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--encrypt", "--passphrase"}, args)
+	t.Setenv("GT_NONINTERACTIVE", "1")
+	_, err = ageEncryptArgs(pass)
+	assert.ErrorContains(t, err, "takes a passphrase")
+}
+
+func TestRuntimeDirIsShortWithoutXDG(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no /tmp")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Cleanup(removeRuntimeDir)
+	removeRuntimeDir()
+
+	dir, err := ensureRuntimeDir()
+	require.NoError(t, err)
+	base := filepath.Join("/tmp", fmt.Sprintf("gt-%d", os.Getuid()))
+	assert.Equal(t, base, filepath.Dir(dir))
+	info, err := os.Stat(base)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	// A ControlPath there, with ssh's temporary suffix, fits a unix
+	// socket path's 104 bytes.
+	assert.Less(t, len(filepath.Join(dir, "s-"+strings.Repeat("0", 40)))+17, 104)
+}
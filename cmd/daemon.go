@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var daemonSocket string
+
+// daemonSocketPath picks where gt daemon listens: --socket, then
+// GT_DAEMON_SOCKET, then gt's directory under XDG_RUNTIME_DIR, then the
+// state directory.
+func daemonSocketPath() (string, error) {
+	if daemonSocket != "" {
+		return daemonSocket, nil
+	}
+	if p := os.Getenv("GT_DAEMON_SOCKET"); p != "" {
+		return p, nil
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gt", "daemon.sock"), nil
+	}
+	return statePath("daemon.sock")
+}
+
+// listenSocket listens on a unix socket only its owner can use: the
+// directory is created 0700, or must already be yours and closed to
+// everyone else, and the socket is chmodded 0600, which is the API's
+// whole access control. A socket left behind by a daemon that died is
+// replaced; one that still answers is refused.
+func listenSocket(path string) (net.Listener, error) {
+	if err := privateDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a gt daemon is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// apiHost is one entry of GET /v1/hosts.
+type apiHost struct {
+	Alias    string   `json:"alias"`
+	User     string   `json:"user,omitempty"`
+	Hostname string   `json:"hostname,omitempty"`
+	Port     string   `json:"port,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// apiRun is the body of POST /v1/exec and /v1/transfer.
+type apiRun struct {
+	Alias   string   `json:"alias"`
+	Command []string `json:"command,omitempty"`
+	Files   []string `json:"files,omitempty"`
+}
+
+// apiEvent is one line of a streamed run: output chunks as they arrive,
+// then a final event carrying the exit code.
+type apiEvent struct {
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+	Exit   *int   `json:"exit,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// sshConfigDump returns every option ssh -G resolves for alias.
+func sshConfigDump(alias string) (map[string][]string, error) {
+	args := append(sshBaseArgs(), "-G", "--", alias)
+	out, err := sshCommand(args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ssh -G %s: %w", alias, err)
+	}
+	return sshconf.ParseOptions(out), nil
+}
+
+// streamRun runs cmd and relays its output to w as NDJSON events,
+// flushing each so clients see progress live, and returns how the run
+// ended. The run is audit-logged under mode like any other connection.
+func streamRun(w http.ResponseWriter, cmd *exec.Cmd, alias, mode string) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	var mu sync.Mutex
+	emit := func(e apiEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(e)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	err := relayRun(cmd, alias, mode, emit)
+	code := 0
+	final := apiEvent{Exit: &code}
+	var ee *exec.ExitError
+	switch {
+	case errors.As(err, &ee):
+		code = ee.ExitCode()
+	case err != nil:
+		code = -1
+		final.Error = err.Error()
+	}
+	emit(final)
+	return err
+}
+
+// relayRun starts cmd and emits each line of its output as it arrives.
+func relayRun(cmd *exec.Cmd, alias, mode string, emit func(apiEvent)) error {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	debugf(1, "exec: %s", quoteArgv(cmd.Args))
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer track(cmd.Process, false)()
+	var wg sync.WaitGroup
+	relay := func(name string, r io.Reader) {
+		defer wg.Done()
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				emit(apiEvent{Stream: name, Data: line})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go relay("stdout", stdout)
+	go relay("stderr", stderr)
+	wg.Wait()
+	err = cmd.Wait()
+	logConnection(alias, mode, start, err)
+	return err
+}
+
+// newDaemonHandler builds the API. Routes:
+//
+//	GET  /v1/hosts           every alias with its resolved user, hostname, port
+//	GET  /v1/hosts/<alias>   every option ssh -G resolves for the alias
+//	POST /v1/exec            {"alias", "command": [...]}: streamed NDJSON events
+//	POST /v1/transfer        {"alias", "files": [...]} using gt -s's colon
+//	                         shorthand: streamed NDJSON events
+//
+// Runs are non-interactive (BatchMode): nobody is there to answer a prompt.
+// They run the hooks gt exec and gt -s do.
+func newDaemonHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/hosts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, errors.New("use GET"))
+			return
+		}
+		hosts := []apiHost{}
+		for _, row := range resolveListRows(getHosts()) {
+			h := apiHost{Alias: row.alias, User: row.User, Hostname: row.Hostname, Port: row.Port, Groups: hostMetaFor(row.alias).Groups}
+			if row.err != nil {
+				h.Error = row.err.Error()
+			}
+			hosts = append(hosts, h)
+		}
+		writeJSON(w, http.StatusOK, hosts)
+	})
+	mux.HandleFunc("/v1/hosts/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, errors.New("use GET"))
+			return
+		}
+		alias, err := hostArg(strings.TrimPrefix(r.URL.Path, "/v1/hosts/"))
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, err)
+			return
+		}
+		opts, err := sshConfigDump(alias)
+		if err != nil {
+			writeAPIError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"alias": alias, "options": opts})
+	})
+	run := func(build func(apiRun) (*exec.Cmd, error), mode, pre, post string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				writeAPIError(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+				return
+			}
+			var req apiRun
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeAPIError(w, http.StatusBadRequest, err)
+				return
+			}
+			alias, err := hostArg(req.Alias)
+			if err != nil {
+				writeAPIError(w, http.StatusNotFound, err)
+				return
+			}
+			req.Alias = alias
+			// The hooks gate the whole run, as they do gt exec's: a
+			// refusing pre hook is a 403, and nothing is resolved or
+			// started for it.
+			ran, started := false, false
+			err = withHooks(pre, post, hookEvent{Alias: alias, Command: req.Command, Files: req.Files}, func() error {
+				ran = true
+				cmd, err := build(req)
+				if err != nil {
+					return err
+				}
+				started = true
+				return streamRun(w, cmd, alias, mode)
+			})
+			switch {
+			case !ran:
+				writeAPIError(w, http.StatusForbidden, err)
+			case !started:
+				writeAPIError(w, http.StatusBadRequest, err)
+			}
+		}
+	}
+	mux.HandleFunc("/v1/exec", run(func(req apiRun) (*exec.Cmd, error) {
+		if len(req.Command) == 0 {
+			return nil, errors.New("command is required")
+		}
+		return remoteCommand(req.Alias, remoteOpts{batch: true}.withFallback(req.Alias), req.Command...)
+	}, "exec", hookPreConnect, hookPostConnect))
+	mux.HandleFunc("/v1/transfer", run(func(req apiRun) (*exec.Cmd, error) {
+		return transferCommand(req.Alias, req.Files, remoteOpts{batch: true}.withFallback(req.Alias))
+	}, "scp", hookPreTransfer, hookPostTransfer))
+	return mux
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Serve gt's inventory and actions over a local HTTP API",
+	Long: `Serve an HTTP/JSON API on a unix socket, for editors, launchers and
+dashboards that want to drive gt. The socket is created 0600 in a 0700
+directory, so only your user can connect; there is no other
+authentication. It lives at $XDG_RUNTIME_DIR/gt/daemon.sock (or in gt's
+state directory); --socket or GT_DAEMON_SOCKET moves it.
+
+  GET  /v1/hosts           aliases with resolved user, hostname and port
+  GET  /v1/hosts/ALIAS     every option ssh -G resolves for ALIAS
+  POST /v1/exec            {"alias": "web", "command": ["uptime"]}
+  POST /v1/transfer        {"alias": "web", "files": ["a.txt", ":/tmp/"]}
+
+exec and transfer stream newline-delimited JSON events as output arrives
+({"stream": "stdout", "data": "..."}) and end with {"exit": CODE}. They
+run in BatchMode, run the connect and transfer hooks (a refusing pre
+hook answers 403), and are audit-logged like any other connection.
+
+  curl --unix-socket $XDG_RUNTIME_DIR/gt/daemon.sock http://gt/v1/hosts`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := daemonSocketPath()
+		if err != nil {
+			return err
+		}
+		l, err := listenSocket(path)
+		if err != nil {
+			return err
+		}
+		defer onCleanup(func() { os.Remove(path) })()
+		statusf(symbolColor, "Listening on %s\n", path)
+		return http.Serve(l, newDaemonHandler())
+	},
+}
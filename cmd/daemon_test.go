@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useDaemonConfig(t *testing.T) {
+	t.Helper()
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	origCfg, origGT := cfg, gtCfg
+	t.Cleanup(func() { cfg, gtCfg = origCfg, origGT })
+	decoded, err := ssh_config.Decode(strings.NewReader("Host web\n  HostName web.example.com\n"))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	cfg = decoded
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"web": {Groups: []string{"prod"}}}}
+}
+
+func TestDaemonHosts(t *testing.T) {
+	useDaemonConfig(t)
+	h := newDaemonHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/hosts", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var hosts []apiHost
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hosts))
+	assert.Equal(t, []apiHost{{Alias: "web", User: "testuser", Hostname: "test.example.com", Port: "2222", Groups: []string{"prod"}}}, hosts)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/hosts/web", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var detail struct {
+		Alias   string
+		Options map[string][]string
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	assert.Equal(t, []string{"2222"}, detail.Options["port"])
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/hosts/nope", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/hosts", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestDaemonRuns(t *testing.T) {
+	useDaemonConfig(t)
+	h := newDaemonHandler()
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("/v1/exec", `{"alias": "web", "command": ["echo", "up", "3", "days"]}`)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `{"stream":"stdout","data":"up 3 days\n"}`+"\n"+`{"exit":0}`+"\n", rec.Body.String())
+	assert.Equal(t, "ssh", mockCmd.commands[0])
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-T", "--", "web", "echo", "up", "3", "days"}, mockCmd.argLists[0])
+
+	mockCmd.reset()
+	rec = post("/v1/transfer", `{"alias": "web", "files": ["a.txt", ":/tmp/"]}`)
+	assert.Equal(t, `{"exit":0}`+"\n", rec.Body.String())
+	assert.Equal(t, "scp", mockCmd.commands[0])
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-p", "--", "a.txt", "web:/tmp/"}, mockCmd.argLists[0])
+
+	assert.Equal(t, http.StatusBadRequest, post("/v1/exec", `{"alias": "web"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/v1/transfer", `{"alias": "web", "files": ["a"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/v1/exec", `not json`).Code)
+	assert.Equal(t, http.StatusNotFound, post("/v1/exec", `{"alias": "nope", "command": ["true"]}`).Code)
+}
+
+func TestDaemonRunsHooks(t *testing.T) {
+	useDaemonConfig(t)
+	dir := useHooks(t, map[string]int{hookPreConnect: 0, hookPostConnect: 0, hookPreTransfer: 0, hookPostTransfer: 0})
+	h := newDaemonHandler()
+	post := func(path, body string) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	}
+
+	// The mock stands in for the hooks too, so only their order shows.
+	post("/v1/exec", `{"alias": "web", "command": ["true"]}`)
+	cmds := mockCmd.commands
+	require.NotEmpty(t, cmds)
+	assert.Equal(t, filepath.Join(dir, hookPreConnect), cmds[0], "a socket client gets the same guardrails")
+	assert.Equal(t, filepath.Join(dir, hookPostConnect), cmds[len(cmds)-1])
+
+	mockCmd.reset()
+	post("/v1/transfer", `{"alias": "web", "files": ["a.txt", ":/tmp/"]}`)
+	cmds = mockCmd.commands
+	require.NotEmpty(t, cmds)
+	assert.Equal(t, filepath.Join(dir, hookPreTransfer), cmds[0])
+	assert.Equal(t, filepath.Join(dir, hookPostTransfer), cmds[len(cmds)-1])
+}
+
+func TestDaemonExecUsesFallback(t *testing.T) {
+	useDaemonConfig(t)
+	origDial := dialTimeout
+	defer func() { dialTimeout = origDial }()
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		if addr == "10.0.0.5:2222" {
+			return stubConn{}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+	gtCfg.Hosts["web"] = hostMeta{FallbackAddresses: []string{"10.0.0.5"}}
+
+	rec := httptest.NewRecorder()
+	newDaemonHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/exec", strings.NewReader(`{"alias": "web", "command": ["true"]}`)))
+	args := mockRun("ssh")
+	assert.True(t, contains(args, "HostName=10.0.0.5"), "as /v1/transfer does: %v", args)
+	assert.True(t, contains(args, "HostKeyAlias=[test.example.com]:2222"), "%v", args)
+}
+
+func TestListenSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gt", "d.sock")
+	l, err := listenSocket(path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	info, err := os.Stat(filepath.Dir(path))
+	assert.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+		info, _ = os.Stat(path)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	_, err = listenSocket(path)
+	assert.ErrorContains(t, err, "already listening", "a live daemon is not replaced")
+
+	// Closing a unix listener removes its socket; leave a stale one behind
+	// the way a crashed daemon would.
+	l.Close()
+	assert.NoError(t, os.WriteFile(path, nil, 0o600))
+	l, err = listenSocket(path)
+	assert.NoError(t, err, "a stale socket is replaced")
+	l.Close()
+}
+
+func TestListenSocketRefusesOpenDirectory(t *testing.T) {
+	if os.PathSeparator != '/' {
+		t.Skip("directory modes are ACLs here")
+	}
+	dir := filepath.Join(t.TempDir(), "shared")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	require.NoError(t, os.Chmod(dir, 0o755))
+	_, err := listenSocket(filepath.Join(dir, "d.sock"))
+	assert.ErrorContains(t, err, "open to other users")
+	assert.NoFileExists(t, filepath.Join(dir, "d.sock"))
+
+	link := filepath.Join(t.TempDir(), "link")
+	require.NoError(t, os.Symlink(t.TempDir(), link))
+	_, err = listenSocket(filepath.Join(link, "d.sock"))
+	assert.ErrorContains(t, err, "not a directory", "a symlink is not followed")
+}
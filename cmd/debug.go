@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// verbosity is the -v count. Each level is passed on to ssh/scp as an
+// extra -v and also turns on gt's own debug lines at that level:
+//
+//	1  the final argv of every ssh/scp gt runs
+//	2  config files loaded, includes resolved or skipped
+//	3  every helper query (ssh -G, decryption)
+var verbosity int
+
+var (
+	debugMu  sync.Mutex
+	debugOut io.Writer
+)
+
+// debugWriter returns where debug lines go: GT_DEBUG_LOG names a file to
+// append to, so ssh's own stderr chatter and gt's stay separable.
+// Otherwise stderr. A log file that cannot be opened falls back to
+// stderr with a warning rather than hiding the output.
+func debugWriter() io.Writer {
+	if debugOut != nil {
+		return debugOut
+	}
+	debugOut = os.Stderr
+	if p := os.Getenv("GT_DEBUG_LOG"); p != "" {
+		f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			warningColor.Fprintf(os.Stderr, "Could not open GT_DEBUG_LOG: %v\n", err)
+		} else {
+			debugOut = f
+		}
+	}
+	return debugOut
+}
+
+// debugf prints a debug line when verbosity is at least level, in the
+// same "debugN:" shape ssh uses so the two interleave readably.
+func debugf(level int, format string, args ...interface{}) {
+	if verbosity < level {
+		return
+	}
+	debugMu.Lock()
+	defer debugMu.Unlock()
+	fmt.Fprintf(debugWriter(), "gt: debug%d: %s\n", level, fmt.Sprintf(format, args...))
+}
+
+// verboseArgs repeats -v once per verbosity level for ssh/scp.
+func verboseArgs() []string {
+	args := make([]string, verbosity)
+	for i := range args {
+		args[i] = "-v"
+	}
+	return args
+}
+
+// quoteArgv renders an argv for debug output, quoting anything a shell
+// would split or expand so the line can be copied and rerun.
+func quoteArgv(argv []string) string {
+	out := make([]string, len(argv))
+	for i, a := range argv {
+		if a != "" && !strings.ContainsAny(a, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+			out[i] = a
+			continue
+		}
+		out[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+	}
+	return strings.Join(out, " ")
+}
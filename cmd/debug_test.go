@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setVerbosity sets -v for one test and captures debug output.
+func setVerbosity(t *testing.T, level int) *bytes.Buffer {
+	t.Helper()
+	origLevel, origOut := verbosity, debugOut
+	t.Cleanup(func() { verbosity, debugOut = origLevel, origOut })
+	var buf bytes.Buffer
+	verbosity, debugOut = level, &buf
+	return &buf
+}
+
+func TestDebugfLevels(t *testing.T) {
+	buf := setVerbosity(t, 2)
+	debugf(1, "one %d", 1)
+	debugf(2, "two")
+	debugf(3, "three")
+	assert.Equal(t, "gt: debug1: one 1\ngt: debug2: two\n", buf.String())
+}
+
+func TestDebugWriterUsesLogFile(t *testing.T) {
+	setVerbosity(t, 1)
+	debugOut = nil
+	path := filepath.Join(t.TempDir(), "debug.log")
+	t.Setenv("GT_DEBUG_LOG", path)
+
+	debugf(1, "hello")
+	if f, ok := debugOut.(*os.File); ok {
+		f.Close()
+	}
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "gt: debug1: hello\n", string(data))
+}
+
+func TestQuoteArgv(t *testing.T) {
+	assert.Equal(t, "ssh -v -- host", quoteArgv([]string{"ssh", "-v", "--", "host"}))
+	assert.Equal(t, `ssh -- host 'ls -l' '' 'it'\''s'`, quoteArgv([]string{"ssh", "--", "host", "ls -l", "", "it's"}))
+}
+
+func TestRunSSHPassesVerbosity(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	buf := setVerbosity(t, 2)
+
+	err := runSSH("testserver", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-v", "-v", "--", "testserver"}, mockCmd.argLists[0])
+	// -G resolution for the audit log stays quiet: -v would only add noise.
+	assert.Equal(t, []string{"-G", "--", "testserver"}, mockCmd.argLists[1])
+	assert.Contains(t, buf.String(), "gt: debug1: exec: ")
+}
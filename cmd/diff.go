@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffContext  int
+	diffExitCode bool
+)
+
+// errFilesDiffer is the answer of gt diff and gt config diff --exit-code
+// when the two sides differ.
+var errFilesDiffer = errors.New("files differ")
+
+// diffSide is one side of a comparison: path on alias, or a local path
+// when alias is empty.
+type diffSide struct {
+	alias string
+	path  string
+}
+
+func (s diffSide) String() string {
+	if s.alias == "" {
+		return s.path
+	}
+	return s.alias + ":" + s.path
+}
+
+// parseDiffSide reads alias:path or a local path. Anything before the
+// first colon that could be a host name is taken as one, so a mistyped
+// alias fails as an unknown host rather than as a missing local file; a
+// single letter is a Windows drive.
+func parseDiffSide(arg string) (diffSide, error) {
+	i := strings.Index(arg, ":")
+	if i <= 1 || strings.ContainsAny(arg[:i], `/\`) {
+		return diffSide{path: arg}, nil
+	}
+	alias, err := hostArg(arg[:i])
+	if err != nil {
+		return diffSide{}, err
+	}
+	path := arg[i+1:]
+	if path == "" {
+		return diffSide{}, fmt.Errorf("%s: no remote path", arg)
+	}
+	return diffSide{alias: alias, path: path}, nil
+}
+
+// read fetches the side's content. Remote paths may use host templates
+// such as {{.Vars.app_dir}}.
+func (s diffSide) read(mode string) ([]byte, error) {
+	if s.alias == "" {
+		return os.ReadFile(s.path)
+	}
+	return fetchRemoteFile(s.alias, mode, s.path)
+}
+
+// fetchRemoteFile returns the content of path on alias, audit-logged
+// under mode.
+func fetchRemoteFile(alias, mode, path string) ([]byte, error) {
+	expanded, err := expandForHost(alias, []string{path})
+	if err != nil {
+		return nil, err
+	}
+	return remoteOutput(alias, mode, remoteOpts{}, quoteArgv([]string{"cat", "--", expanded[0]}))
+}
+
+// isBinary guesses as diff and git do: a NUL byte in the first 8000.
+func isBinary(b []byte) bool {
+	if len(b) > 8000 {
+		b = b[:8000]
+	}
+	return bytes.IndexByte(b, 0) >= 0
+}
+
+// unifiedDiff is the unified diff of a and b with context lines around
+// each change, or "" when they are equal.
+func unifiedDiff(a, b []byte, aName, bName string, context int) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(a),
+		B:        diffLines(b),
+		FromFile: aName,
+		ToFile:   bName,
+		Context:  context,
+	})
+}
+
+// diffLines splits b into lines that each end in a newline, as the diff
+// wants them. difflib.SplitLines would add an empty last line to text
+// that ends properly.
+func diffLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+	return lines
+}
+
+// writeDiff colors a unified diff in the theme's roles: file headers as
+// aliases, hunk headers as ports, removals as errors and additions as
+// users (red and green by default, as git has them).
+func writeDiff(w io.Writer, diff string) {
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			aliasColor.Fprint(w, line)
+		case strings.HasPrefix(line, "@@"):
+			portColor.Fprint(w, line)
+		case strings.HasPrefix(line, "-"):
+			errorColor.Fprint(w, line)
+		case strings.HasPrefix(line, "+"):
+			userColor.Fprint(w, line)
+		default:
+			fmt.Fprint(w, line)
+		}
+	}
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <alias:path|path> <alias:path|path>",
+	Short: "Compare a file between hosts, or a host and this machine",
+	Long: `Fetch each remote file and show a colored unified diff of the two, such
+as the same config on two hosts or a host's copy against a local one:
+
+  gt diff web1:/etc/nginx/nginx.conf web2:/etc/nginx/nginx.conf
+  gt diff nginx.conf web1:/etc/nginx/nginx.conf
+
+Remote paths are alias:path and may use host templates such as
+{{.Vars.app_dir}}; anything else is local. Long diffs go through the
+pager. gt exits 0 whether or not the files differ, unless --exit-code.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var sides [2]diffSide
+		for i, arg := range args {
+			s, err := parseDiffSide(arg)
+			if err != nil {
+				return err
+			}
+			sides[i] = s
+		}
+		cmd.SilenceUsage = true
+
+		var content [2][]byte
+		for i, s := range sides {
+			b, err := s.read("diff")
+			if err != nil {
+				return err
+			}
+			content[i] = b
+		}
+		if bytes.Equal(content[0], content[1]) {
+			statusf(userColor, "%s and %s are identical\n", sides[0], sides[1])
+			return nil
+		}
+		if isBinary(content[0]) || isBinary(content[1]) {
+			fmt.Fprintf(cmd.OutOrStdout(), "Binary files %s and %s differ\n", sides[0], sides[1])
+		} else {
+			diff, err := unifiedDiff(content[0], content[1], sides[0].String(), sides[1].String(), diffContext)
+			if err != nil {
+				return err
+			}
+			if diff == "" {
+				diff = fmt.Sprintf("%s and %s differ only in a trailing newline\n", sides[0], sides[1])
+			}
+			var out bytes.Buffer
+			writeDiff(&out, diff)
+			if err := pageOutput(out.Bytes()); err != nil {
+				return err
+			}
+		}
+		if diffExitCode {
+			return errFilesDiffer
+		}
+		return nil
+	},
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiffSide(t *testing.T) {
+	orig := cfg
+	t.Cleanup(func() { cfg = orig })
+	decoded, err := ssh_config.Decode(strings.NewReader("Host web1\n  User deploy\n"))
+	assert.NoError(t, err)
+	cfg = decoded
+
+	s, err := parseDiffSide("web1:/etc/hosts")
+	assert.NoError(t, err)
+	assert.Equal(t, diffSide{alias: "web1", path: "/etc/hosts"}, s)
+	assert.Equal(t, "web1:/etc/hosts", s.String())
+
+	for _, local := range []string{"nginx.conf", "./a:b", `C:\Users\me\hosts`, "/tmp/x:y"} {
+		s, err := parseDiffSide(local)
+		assert.NoError(t, err, local)
+		assert.Equal(t, diffSide{path: local}, s, local)
+	}
+
+	_, err = parseDiffSide("web9:/etc/hosts")
+	assert.Equal(t, exitHostNotFound, ExitCode(err), "a mistyped alias is an unknown host, not a local file")
+	_, err = parseDiffSide("web1:")
+	assert.Error(t, err)
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	diff, err := unifiedDiff([]byte("a\nb\nc\n"), []byte("a\nB\nc\n"), "web1:/f", "web2:/f", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "--- web1:/f\n+++ web2:/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n", diff)
+
+	plainOutput(t)
+	var out bytes.Buffer
+	writeDiff(&out, diff)
+	assert.Equal(t, diff, out.String(), "coloring keeps the text")
+}
+
+func TestIsBinary(t *testing.T) {
+	assert.False(t, isBinary([]byte("plain text\n")))
+	assert.True(t, isBinary([]byte("ELF\x00\x01")))
+}
+
+func TestDiffLocalFiles(t *testing.T) {
+	plainOutput(t)
+	orig := color.Output
+	t.Cleanup(func() { color.Output = orig })
+	var out bytes.Buffer
+	color.Output = &out
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.conf"), filepath.Join(dir, "b.conf")
+	assert.NoError(t, os.WriteFile(a, []byte("port 80\n"), 0o644))
+	assert.NoError(t, os.WriteFile(b, []byte("port 8080\n"), 0o644))
+
+	assert.NoError(t, diffCmd.RunE(diffCmd, []string{a, b}))
+	assert.Contains(t, out.String(), "-port 80\n+port 8080\n")
+
+	diffExitCode = true
+	t.Cleanup(func() { diffExitCode = false })
+	assert.Equal(t, 1, ExitCode(diffCmd.RunE(diffCmd, []string{a, b})))
+	assert.NoError(t, diffCmd.RunE(diffCmd, []string{a, a}), "identical files are no failure")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+var docsOut string
+
+// docFormats maps each gt docs format to its cobra generator.
+var docFormats = map[string]func(root *cobra.Command, dir string) error{
+	"man": func(root *cobra.Command, dir string) error {
+		header := &doc.GenManHeader{Title: "GT", Section: "1", Source: "gt " + currentBuild().Version}
+		return doc.GenManTree(root, header, dir)
+	},
+	"markdown": doc.GenMarkdownTree,
+}
+
+// disableAutoGenTag drops cobra's "Auto generated" footer from every
+// command, so regenerating docs only changes them when the CLI did.
+func disableAutoGenTag(c *cobra.Command) {
+	c.DisableAutoGenTag = true
+	for _, sub := range c.Commands() {
+		disableAutoGenTag(sub)
+	}
+}
+
+var docsCmd = &cobra.Command{
+	Use:   "docs <man|markdown>",
+	Short: "Generate reference docs for every gt command",
+	Long: `Write one page per command into --out: man pages (section 1) for
+packaging, or markdown for a wiki. Pages are generated from the commands
+themselves, so they cover every subcommand and flag of this build. Man
+page dates honor SOURCE_DATE_EPOCH for reproducible builds.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"man", "markdown"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		gen, ok := docFormats[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown format %q (want man or markdown)", args[0])
+		}
+		if err := os.MkdirAll(docsOut, 0o755); err != nil {
+			return err
+		}
+		root := cmd.Root()
+		disableAutoGenTag(root)
+		if err := gen(root, docsOut); err != nil {
+			return err
+		}
+		statusf(symbolColor, "Wrote %s docs to %s\n", args[0], docsOut)
+		return nil
+	},
+}
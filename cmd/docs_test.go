@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocsGeneratesEveryCommand(t *testing.T) {
+	orig := docsOut
+	t.Cleanup(func() { docsOut = orig })
+
+	for format, page := range map[string]string{"man": "gt-list.1", "markdown": "gt_list.md"} {
+		docsOut = filepath.Join(t.TempDir(), "docs")
+		if !assert.NoError(t, docsCmd.RunE(docsCmd, []string{format}), format) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(docsOut, page))
+		if assert.NoError(t, err, format) {
+			assert.Contains(t, string(data), "List all hosts")
+			assert.NotContains(t, string(data), "Auto generated", "footer dates would churn regenerated docs")
+		}
+		for _, sub := range []string{"completion", "version", "docs"} {
+			_, err := os.Stat(filepath.Join(docsOut, map[string]string{"man": "gt-" + sub + ".1", "markdown": "gt_" + sub + ".md"}[format]))
+			assert.NoError(t, err, "%s page for %s", format, sub)
+		}
+	}
+}
+
+func TestDocsRejectsUnknownFormat(t *testing.T) {
+	assert.ErrorContains(t, docsCmd.RunE(docsCmd, []string{"html"}), "unknown format")
+}
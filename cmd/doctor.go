@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// doctorResult is the outcome of one gt doctor check: ok, failed or
+// skipped, and a line saying what was found or what to do.
+type doctorResult struct {
+	check, result, detail string
+}
+
+// doctorChecks are what gt doctor looks at, in order.
+var doctorChecks = []struct {
+	name string
+	run  func() doctorResult
+}{
+	{"ssh", checkSSH},
+	{"permissions", checkPermissions},
+	{"security keys", checkSecurityKeys},
+	{"kerberos", checkKerberos},
+}
+
+// checkSSH runs ssh -V, which every check after it and gt itself rely on.
+func checkSSH() doctorResult {
+	if usePuTTY() {
+		return doctorResult{result: "skipped", detail: "the PuTTY backend is in use"}
+	}
+	out, err := sshCommand("-V").CombinedOutput()
+	if err != nil {
+		return doctorResult{result: "failed", detail: fmt.Sprintf("ssh -V: %v", err)}
+	}
+	return doctorResult{result: "ok", detail: firstLine(strings.TrimSpace(string(out)))}
+}
+
+// checkPermissions looks for the modes ssh refuses, as gt keys
+// fix-perms --check does.
+func checkPermissions() doctorResult {
+	if runtime.GOOS == "windows" {
+		return doctorResult{result: "skipped", detail: "OpenSSH checks ACLs on Windows"}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return doctorResult{result: "failed", detail: err.Error()}
+	}
+	fixes, notOwned, err := planPermFixes(filepath.Join(home, ".ssh"), loadedFiles)
+	switch {
+	case err != nil:
+		return doctorResult{result: "failed", detail: err.Error()}
+	case len(fixes) > 0:
+		return doctorResult{result: "failed", detail: fmt.Sprintf("%d file(s) too open; run gt keys fix-perms", len(fixes))}
+	case len(notOwned) > 0:
+		return doctorResult{result: "failed", detail: fmt.Sprintf("%d file(s) owned by another user", len(notOwned))}
+	}
+	return doctorResult{result: "ok", detail: "~/.ssh is in order"}
+}
+
+// checkSecurityKeys finds the FIDO2 security keys in ~/.ssh by their
+// .pub files, and for those checks the middleware ssh reaches them by.
+func checkSecurityKeys() doctorResult {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return doctorResult{result: "failed", detail: err.Error()}
+	}
+	pubs, _ := filepath.Glob(filepath.Join(home, ".ssh", "*.pub"))
+	var keys []string
+	for _, pub := range pubs {
+		key := strings.TrimSuffix(pub, ".pub")
+		if _, sk := skKeyTypes[keyFileType(key)]; sk {
+			keys = append(keys, filepath.Base(key))
+		}
+	}
+	if len(keys) == 0 {
+		return doctorResult{result: "skipped", detail: "no security keys in ~/.ssh"}
+	}
+	if err := checkSKProvider(""); err != nil {
+		return doctorResult{result: "failed", detail: err.Error()}
+	}
+	provider := os.Getenv("SSH_SK_PROVIDER")
+	if provider == "" {
+		provider = "internal"
+	}
+	return doctorResult{result: "ok", detail: fmt.Sprintf("%s, through the %s provider", strings.Join(keys, ", "), provider)}
+}
+
+// checkKerberos looks for a valid ticket when --gssapi is given or a host
+// sets gssapi; without either nothing of gt's needs one.
+func checkKerberos() doctorResult {
+	used := useGSSAPI
+	for _, m := range allHostMeta() {
+		used = used || m.GSSAPI
+	}
+	if !used {
+		return doctorResult{result: "skipped", detail: "no host uses GSSAPI"}
+	}
+	principal, err := kerberosTicket()
+	if err != nil {
+		return doctorResult{result: "failed", detail: err.Error()}
+	}
+	return doctorResult{result: "ok", detail: "ticket for " + principal}
+}
+
+// runDoctor runs every check and writes the table to w, returning how
+// many failed.
+func runDoctor(w io.Writer) int {
+	results := make([]doctorResult, len(doctorChecks))
+	width := len("CHECK")
+	for i, c := range doctorChecks {
+		results[i] = c.run()
+		results[i].check = c.name
+		if n := displayWidth(c.name); n > width {
+			width = n
+		}
+	}
+	pad := func(s string, n int) string { return s + strings.Repeat(" ", n-displayWidth(s)+2) }
+
+	symbolColor.Fprintf(w, "%s%s%s\n", pad("CHECK", width), pad("RESULT", 7), "DETAIL")
+	failed := 0
+	for _, r := range results {
+		fmt.Fprint(w, pad(r.check, width))
+		switch r.result {
+		case "ok":
+			userColor.Fprint(w, pad(r.result, 7))
+		case "skipped":
+			warningColor.Fprint(w, pad(r.result, 7))
+		default:
+			failed++
+			errorColor.Fprint(w, pad(r.result, 7))
+		}
+		fmt.Fprintln(w, r.detail)
+	}
+	return failed
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that ssh, ~/.ssh, security keys and the Kerberos ticket are in order",
+	Long: `Check what connections depend on: that ssh runs, that ~/.ssh and the
+files in it have modes ssh accepts, that the middleware for any FIDO2
+security keys in ~/.ssh is there, and, when --gssapi is given or a host
+sets gssapi, that there is a Kerberos ticket that has not expired. Each
+check is ok, failed or skipped; gt doctor exits 1 if any failed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if failed := runDoctor(cmd.OutOrStdout()); failed > 0 {
+			return withCode(1, fmt.Errorf("%d check(s) failed", failed))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDoctor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on Windows")
+	}
+	useMockExec(t)
+	useWebGroup(t)
+	plainOutput(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { klistBinary = "klist" })
+	origLoaded := loadedFiles
+	t.Cleanup(func() { loadedFiles = origLoaded })
+	loadedFiles = nil
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, runDoctor(&out))
+	assert.Equal(t, "CHECK          RESULT   DETAIL\n"+
+		"ssh            ok       OpenSSH_9.6p1, OpenSSL 3.0.13 30 Jan 2024\n"+
+		"permissions    ok       ~/.ssh is in order\n"+
+		"security keys  skipped  no security keys in ~/.ssh\n"+
+		"kerberos       skipped  no host uses GSSAPI\n", out.String())
+
+	gtCfg.Hosts["web-1"] = hostMeta{GSSAPI: true}
+	out.Reset()
+	assert.Equal(t, 0, runDoctor(&out))
+	assert.Contains(t, out.String(), "kerberos       ok       ticket for alice@EXAMPLE.COM\n")
+
+	klistBinary = "klist-expired"
+	out.Reset()
+	assert.Equal(t, 1, runDoctor(&out))
+	assert.Contains(t, out.String(), "kerberos       failed   no valid Kerberos ticket; run kinit\n")
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"gt/pkg/sshconf"
+)
+
+var shipDotfiles bool
+
+// dotfilesMax caps the payload: it travels inside the remote command,
+// and Linux limits a single argument to 128 KiB.
+const dotfilesMax = 96 << 10
+
+// Startup files gt writes next to the shipped dotfiles under ~/.gt/rc.
+// Each first runs what the shell would have run anyway, then the
+// shipped .aliases and the shell's own file, so the remote's setup
+// stays and its real dotfiles are never touched.
+var dotfilesRC = map[string]string{
+	// bash ignores --rcfile in a login shell, so this stands in for the
+	// login sequence.
+	"bashrc": `[ -r /etc/profile ] && . /etc/profile
+if [ -r ~/.bash_profile ]; then . ~/.bash_profile
+elif [ -r ~/.bash_login ]; then . ~/.bash_login
+elif [ -r ~/.profile ]; then . ~/.profile; fi
+for f in ~/.gt/dotfiles/.aliases ~/.gt/dotfiles/.bashrc; do [ -r "$f" ] && . "$f"; done
+`,
+	// zsh reads these from ZDOTDIR; .zshrc hands it back to $HOME so
+	// .zlogin and later sessions are the remote's own.
+	".zshenv":   "[ -r ~/.zshenv ] && . ~/.zshenv\n",
+	".zprofile": "[ -r ~/.zprofile ] && . ~/.zprofile\n",
+	".zshrc": `ZDOTDIR=$HOME
+[ -r ~/.zshrc ] && . ~/.zshrc
+for f in ~/.gt/dotfiles/.aliases ~/.gt/dotfiles/.zshrc; do [ -r "$f" ] && . "$f"; done
+`,
+	// Other POSIX shells read $ENV once interactive.
+	"shrc": `[ -n "$GT_ORIG_ENV" ] && [ -r "$GT_ORIG_ENV" ] && . "$GT_ORIG_ENV"
+for f in ~/.gt/dotfiles/.aliases ~/.gt/dotfiles/.shrc; do [ -r "$f" ] && . "$f"; done
+`,
+}
+
+// dotfilesBootstrap unpacks the payload into ~/.gt, replacing what an
+// earlier session left, points vim and readline at the shipped files,
+// and sets "$@" to the shell that sources the rest.
+const dotfilesBootstrap = `if command -v base64 >/dev/null 2>&1; then dec='base64 -d'; else dec='openssl base64 -d -A'; fi
+mkdir -p ~/.gt && rm -rf ~/.gt/dotfiles ~/.gt/rc &&
+printf %%s '%s' | $dec | tar -xzf - -C ~/.gt || exit
+[ -r ~/.gt/dotfiles/.vimrc ] && export VIMINIT='source ~/.gt/dotfiles/.vimrc'
+[ -r ~/.gt/dotfiles/.inputrc ] && export INPUTRC=~/.gt/dotfiles/.inputrc
+case "${SHELL##*/}" in
+bash) set -- "$SHELL" --rcfile ~/.gt/rc/bashrc -i ;;
+zsh) export ZDOTDIR=~/.gt/rc; set -- "$SHELL" -l ;;
+*) export GT_ORIG_ENV="$ENV" ENV=~/.gt/rc/shrc; set -- "${SHELL:-sh}" -l ;;
+esac
+`
+
+// dotfilesEnabled reports whether a login to alias ships dotfiles:
+// with --dotfiles, or when the host opts in.
+func dotfilesEnabled(alias string) bool {
+	return shipDotfiles || hostMetaFor(alias).Dotfiles
+}
+
+// dotfilesPayload packs the configured dotfiles directory as
+// dotfiles/..., plus gt's startup files as rc/..., into a base64 gzipped
+// tar.
+func dotfilesPayload(dir string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("no dotfiles directory: set dotfiles in gt's config")
+	}
+	dir = sshconf.ExpandTilde(dir)
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("dotfiles: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	add := func(name string, mode int64, body []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: int64(len(body))}); err != nil {
+			return err
+		}
+		_, err := tw.Write(body)
+		return err
+	}
+	for _, p := range files {
+		// Stat rather than the walk's Lstat: dotfiles are often
+		// symlinks into a repository.
+		fi, err := os.Stat(p)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		body, err := os.ReadFile(p)
+		if err != nil {
+			return "", fmt.Errorf("dotfiles: %w", err)
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if err := add(path.Join("dotfiles", filepath.ToSlash(rel)), int64(fi.Mode().Perm()), body); err != nil {
+			return "", err
+		}
+	}
+	names := make([]string, 0, len(dotfilesRC))
+	for name := range dotfilesRC {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := add("rc/"+name, 0o644, []byte(dotfilesRC[name])); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if len(payload) > dotfilesMax {
+		return "", fmt.Errorf("dotfiles in %s are too big to ship: %d KiB encoded, at most %d", dir, len(payload)>>10, dotfilesMax>>10)
+	}
+	return payload, nil
+}
+
+// dotfilesScript is the part of a login script that bootstraps the
+// dotfiles; see dotfilesBootstrap.
+func dotfilesScript() (string, error) {
+	payload, err := dotfilesPayload(gtCfg.Dotfiles)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(dotfilesBootstrap, payload), nil
+}
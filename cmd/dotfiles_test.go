@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDotfiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		".vimrc":      "set number\n",
+		".aliases":    "alias ll='ls -l'\n",
+		"bin/helper":  "#!/bin/sh\n",
+		".git/config": "[core]\n",
+	} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(body), 0o644))
+	}
+	return dir
+}
+
+func payloadEntries(t *testing.T, payload string) map[string]string {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(payload)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	entries := map[string]string{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		body, _ := io.ReadAll(tr)
+		entries[h.Name] = string(body)
+	}
+}
+
+func TestDotfilesPayload(t *testing.T) {
+	payload, err := dotfilesPayload(writeDotfiles(t))
+	require.NoError(t, err)
+	entries := payloadEntries(t, payload)
+	assert.Equal(t, "set number\n", entries["dotfiles/.vimrc"])
+	assert.Contains(t, entries, "dotfiles/bin/helper")
+	assert.NotContains(t, entries, "dotfiles/.git/config")
+	for name := range dotfilesRC {
+		assert.Contains(t, entries, "rc/"+name)
+	}
+
+	_, err = dotfilesPayload("")
+	assert.ErrorContains(t, err, "set dotfiles")
+
+	big := t.TempDir()
+	noise := make([]byte, 100<<10)
+	rand.Read(noise)
+	require.NoError(t, os.WriteFile(filepath.Join(big, ".bigrc"), noise, 0o644))
+	_, err = dotfilesPayload(big)
+	assert.ErrorContains(t, err, "too big")
+}
+
+func TestDotfilesBootstrapUnpacks(t *testing.T) {
+	for _, tool := range []string{"sh", "tar", "base64"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("no %s", tool)
+		}
+	}
+	origCfg := gtCfg
+	t.Cleanup(func() { gtCfg = origCfg })
+	gtCfg = gtConfig{Dotfiles: writeDotfiles(t)}
+	script, err := dotfilesScript()
+	require.NoError(t, err)
+
+	home := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".gt", "dotfiles"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gt", "dotfiles", ".stale"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".vimrc"), []byte("real\n"), 0o644))
+	// The login shell is stood in for by a command that just exits.
+	c := exec.Command("sh", "-c", script+`exec "$@"`)
+	c.Env = append(os.Environ(), "HOME="+home, "SHELL=true")
+	out, err := c.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	got, err := os.ReadFile(filepath.Join(home, ".gt", "dotfiles", ".vimrc"))
+	require.NoError(t, err)
+	assert.Equal(t, "set number\n", string(got))
+	assert.FileExists(t, filepath.Join(home, ".gt", "rc", "bashrc"))
+	assert.NoFileExists(t, filepath.Join(home, ".gt", "dotfiles", ".stale"), "an earlier session's files are replaced")
+	real, _ := os.ReadFile(filepath.Join(home, ".vimrc"))
+	assert.Equal(t, "real\n", string(real), "the remote's own dotfiles stay untouched")
+}
+
+func TestLoginShipsDotfiles(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useHostVars(t)
+	gtCfg.Dotfiles = writeDotfiles(t)
+
+	login, err := loginCommand("web")
+	require.NoError(t, err)
+	assert.Equal(t, `cd '/srv/my app/current' && exec "$SHELL" -l`, login, "without opting in, only the directory")
+
+	shipDotfiles = true
+	t.Cleanup(func() { shipDotfiles = false })
+	assert.NoError(t, runSSH("web", nil))
+	args := mockRun("ssh")
+	assert.True(t, contains(args, "-t"))
+	remote := args[len(args)-1]
+	assert.True(t, strings.HasPrefix(remote, "sh -c "), remote)
+	assert.Contains(t, remote, `cd '\''/srv/my app/current'\'' || exit`)
+	assert.Contains(t, remote, "tar -xzf - -C ~/.gt")
+
+	assert.NoError(t, runSSH("web", []string{"uptime"}))
+	assert.NotContains(t, mockCmd.argLists[len(mockCmd.argLists)-1], "-t", "a remote command runs as given")
+}
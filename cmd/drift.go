@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	driftParallel int
+	driftDiff     bool
+)
+
+// hashScript prints the SHA-256 of path with whichever tool the host has:
+// sha256sum (Linux), shasum (macOS) or sha256 (the BSDs).
+func hashScript(path string) string {
+	p := quoteArgv([]string{path})
+	return shellScript(`if command -v sha256sum >/dev/null 2>&1; then sha256sum -- ` + p +
+		`; elif command -v shasum >/dev/null 2>&1; then shasum -a 256 ` + p +
+		`; else sha256 -q ` + p + `; fi`)
+}
+
+// remoteHash is the SHA-256 of path on alias, in hex.
+func remoteHash(alias, path string) (string, error) {
+	expanded, err := expandForHost(alias, []string{path})
+	if err != nil {
+		return "", err
+	}
+	out, err := remoteOutput(alias, "drift", remoteOpts{batch: true}, hashScript(expanded[0]))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%s: no hash in output", alias)
+	}
+	return fields[0], nil
+}
+
+// driftCluster is the hosts sharing one copy of the file.
+type driftCluster struct {
+	hash    string
+	aliases []string
+}
+
+// clusterHashes groups hosts by hash, largest group first; ties keep the
+// order of first appearance, so the majority is stable between runs.
+func clusterHashes(aliases []string, hashes map[string]string) []driftCluster {
+	var clusters []driftCluster
+	index := map[string]int{}
+	for _, alias := range aliases {
+		h, ok := hashes[alias]
+		if !ok {
+			continue
+		}
+		i, seen := index[h]
+		if !seen {
+			i = len(clusters)
+			index[h] = i
+			clusters = append(clusters, driftCluster{hash: h})
+		}
+		clusters[i].aliases = append(clusters[i].aliases, alias)
+	}
+	// Stable insertion sort by size: clusters are few.
+	for i := 1; i < len(clusters); i++ {
+		for j := i; j > 0 && len(clusters[j].aliases) > len(clusters[j-1].aliases); j-- {
+			clusters[j], clusters[j-1] = clusters[j-1], clusters[j]
+		}
+	}
+	return clusters
+}
+
+// renderDrift writes one line per copy of the file, the majority first,
+// then the hosts it could not be read from.
+func renderDrift(w io.Writer, clusters []driftCluster, failed []hostResult) {
+	for i, c := range clusters {
+		hash := c.hash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		portColor.Fprintf(w, "%s  ", hash)
+		noun := "hosts"
+		if len(c.aliases) == 1 {
+			noun = "host"
+		}
+		fmt.Fprintf(w, "%3d %-5s  ", len(c.aliases), noun)
+		for j, alias := range c.aliases {
+			if j > 0 {
+				symbolColor.Fprint(w, ", ")
+			}
+			aliasColor.Fprint(w, alias)
+		}
+		switch {
+		case i == 0 && len(clusters) > 1:
+			userColor.Fprint(w, "  (majority)")
+		case i > 0:
+			warningColor.Fprint(w, "  (deviates)")
+		}
+		fmt.Fprintln(w)
+	}
+	for _, r := range failed {
+		errorColor.Fprintf(w, "%-12s  ", "unreadable")
+		aliasColor.Fprint(w, r.alias)
+		errorColor.Fprintf(w, ": %s\n", firstLine(r.err.Error()))
+	}
+}
+
+var driftCmd = &cobra.Command{
+	Use:   "drift <@group> <path>",
+	Short: "Find the hosts in a group whose copy of a file differs",
+	Long: `Hash a file on every host in a group, group the hosts with identical
+copies, and report which deviate from the majority:
+
+  gt drift @web /etc/app/config.yml
+  gt drift --diff @web /etc/nginx/nginx.conf
+
+Hosts are hashed in BatchMode, up to --parallel at a time, with
+sha256sum, shasum or sha256, whichever the host has. --diff also fetches
+one copy from each deviating group and shows how it differs from the
+majority's. gt exits 1 if any host deviates or could not be read.`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeTargets(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		aliases, err := expandTarget(args[0])
+		if err != nil {
+			return err
+		}
+		path := args[1]
+		cmd.SilenceUsage = true
+
+		var mu sync.Mutex
+		hashes := map[string]string{}
+		results := fanOut(aliases, driftParallel, func(alias string) error {
+			h, err := remoteHash(alias, path)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			hashes[alias] = h
+			mu.Unlock()
+			return nil
+		}, reportProgress(len(aliases)))
+
+		var failed []hostResult
+		for _, r := range results {
+			if r.err != nil {
+				failed = append(failed, r)
+			}
+		}
+		clusters := clusterHashes(aliases, hashes)
+		renderDrift(cmd.OutOrStdout(), clusters, failed)
+
+		if driftDiff && len(clusters) > 1 {
+			base := clusters[0].aliases[0]
+			want, err := fetchRemoteFile(base, "drift", path)
+			if err != nil {
+				return err
+			}
+			var out bytes.Buffer
+			for _, c := range clusters[1:] {
+				other := c.aliases[0]
+				got, err := fetchRemoteFile(other, "drift", path)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(&out)
+				if isBinary(want) || isBinary(got) {
+					fmt.Fprintf(&out, "Binary files %s:%s and %s:%s differ\n", base, path, other, path)
+					continue
+				}
+				diff, err := unifiedDiff(want, got, base+":"+path, other+":"+path, 3)
+				if err != nil {
+					return err
+				}
+				writeDiff(&out, diff)
+			}
+			if err := pageOutput(out.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		deviating := len(hashes)
+		if len(clusters) > 0 {
+			deviating -= len(clusters[0].aliases)
+		}
+		switch {
+		case deviating > 0 && len(failed) > 0:
+			return withCode(1, fmt.Errorf("%d of %d hosts deviate, %d could not be read", deviating, len(aliases), len(failed)))
+		case deviating > 0:
+			return withCode(1, fmt.Errorf("%d of %d hosts deviate", deviating, len(aliases)))
+		case len(failed) > 0:
+			return failedHosts(results, 1)
+		}
+		statusf(userColor, "All %d hosts have the same copy\n", len(aliases))
+		return nil
+	},
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterHashes(t *testing.T) {
+	aliases := []string{"a", "b", "c", "d", "e"}
+	hashes := map[string]string{"a": "111", "b": "222", "c": "222", "d": "111", "e": "222"}
+	assert.Equal(t, []driftCluster{
+		{hash: "222", aliases: []string{"b", "c", "e"}},
+		{hash: "111", aliases: []string{"a", "d"}},
+	}, clusterHashes(aliases, hashes))
+
+	tie := clusterHashes([]string{"a", "b"}, map[string]string{"a": "111", "b": "222"})
+	assert.Equal(t, "111", tie[0].hash, "a tie goes to the copy seen first")
+
+	missing := clusterHashes([]string{"a", "b"}, map[string]string{"b": "222"})
+	assert.Equal(t, []driftCluster{{hash: "222", aliases: []string{"b"}}}, missing, "unread hosts are in no cluster")
+}
+
+func TestRenderDrift(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	renderDrift(&out, []driftCluster{
+		{hash: strings.Repeat("ab", 32), aliases: []string{"web-1", "web-2"}},
+		{hash: strings.Repeat("cd", 32), aliases: []string{"web-3"}},
+	}, []hostResult{{alias: "down", err: errors.New("connection to down failed")}})
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Regexp(t, `^abababababab\s+2 hosts\s+web-1, web-2\s+\(majority\)$`, lines[0])
+	assert.Regexp(t, `^cdcdcdcdcdcd\s+1 host\s+web-3\s+\(deviates\)$`, lines[1])
+	assert.Regexp(t, `^unreadable\s+down: connection to down failed$`, lines[2])
+}
+
+func TestHashScriptQuotesPath(t *testing.T) {
+	s := hashScript("/etc/my app.conf")
+	assert.Contains(t, s, "sha256sum")
+	assert.Contains(t, s, "shasum -a 256")
+	assert.Contains(t, s, `'\''/etc/my app.conf'\''`, "the path survives both shells as one word")
+}
+
+func TestDriftUnreadable(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+
+	var out bytes.Buffer
+	driftCmd.SetOut(&out)
+	t.Cleanup(func() { driftCmd.SetOut(nil) })
+	err := driftCmd.RunE(driftCmd, []string{"@web", "/etc/app.conf"})
+	assert.Equal(t, 1, ExitCode(err))
+	assert.Contains(t, out.String(), "down: ")
+	for _, c := range mockCmd.argLists {
+		if contains(c, "--") && contains(c, "BatchMode=yes") {
+			return
+		}
+	}
+	t.Error("hosts were not hashed in BatchMode")
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gt/pkg/transport"
+)
+
+var transferDryRun bool
+
+// manifestEntry is one file a transfer would copy.
+type manifestEntry struct {
+	from, to string
+	size     int64
+}
+
+// uploadManifest lists what uploading files to alias would send, from
+// the local files alone: the remote side is not contacted. A lone file
+// going to a destination without a trailing slash is shown as written to
+// it, though scp puts it inside if it is a directory there.
+func uploadManifest(alias string, files []string) ([]manifestEntry, error) {
+	files, err := expandRemotePaths(alias, files)
+	if err != nil {
+		return nil, err
+	}
+	dest := files[len(files)-1]
+	sources, dirs, err := localContents(files[:len(files)-1])
+	if err != nil {
+		return nil, err
+	}
+	entries, _, err := collectUpload(sources, currentFilter())
+	if err != nil {
+		return nil, err
+	}
+	into := dirs || len(sources) > 1 || strings.HasSuffix(dest, "/") || transferEngine == "tar"
+	var m []manifestEntry
+	for _, e := range entries {
+		if e.info.IsDir() {
+			continue
+		}
+		to := colonPath(dest)
+		if into {
+			to = path.Join(to, e.name)
+		}
+		m = append(m, manifestEntry{from: e.local, to: alias + ":" + to, size: e.info.Size()})
+	}
+	return m, nil
+}
+
+// manifestScript prints every file under each pattern's matches on the
+// host as "N\tSIZE\tpath\0", N being the pattern's index.
+func manifestScript(patterns []string) string {
+	const list = `for f; do printf '%s\t%s\t%s\0' "$0" "$(wc -c <"$f" 2>/dev/null)" "$f"; done`
+	var b strings.Builder
+	for i, p := range patterns {
+		fmt.Fprintf(&b, "for m in %s; do [ -e \"$m\" ] || [ -L \"$m\" ] || continue; find -H \"$m\" ! -type d -exec sh -c %s %d {} +; done\n",
+			globQuote(p), quoteArgv([]string{list}), i)
+	}
+	return shellScript(b.String())
+}
+
+// remoteFile is a file under a download's source, with the path its
+// copy takes below the destination.
+type remoteFile struct {
+	path, rel string
+	size      int64
+}
+
+// relTo is p below the directory base, both cleaned.
+func relTo(base, p string) string {
+	switch base {
+	case ".":
+		return p
+	case "/":
+		return strings.TrimPrefix(p, "/")
+	}
+	return strings.TrimPrefix(p, base+"/")
+}
+
+// parseManifest splits manifestScript's output into the files of each of
+// n sources. A contents source's files are named from inside it, any
+// other from its last element.
+func parseManifest(out []byte, sources []string) [][]remoteFile {
+	files := make([][]remoteFile, len(sources))
+	for _, rec := range bytes.Split(out, []byte{0}) {
+		f := strings.SplitN(string(rec), "\t", 3)
+		if len(f) != 3 {
+			continue
+		}
+		i, err := strconv.Atoi(f[0])
+		if err != nil || i < 0 || i >= len(sources) {
+			continue
+		}
+		size, _ := strconv.ParseInt(strings.TrimSpace(f[1]), 10, 64)
+		p := path.Clean(f[2])
+		base := path.Clean(colonPath(sources[i]))
+		if !transport.CopiesContents(sources[i]) {
+			base = path.Dir(base)
+		}
+		files[i] = append(files[i], remoteFile{path: p, rel: relTo(base, p), size: size})
+	}
+	return files
+}
+
+// downloadManifest lists what downloading files from alias would copy.
+// Unlike an upload's, it needs one read-only look at the host, where the
+// files are; nothing is copied or created on either side.
+func downloadManifest(alias string, files []string) ([]manifestEntry, error) {
+	files, err := expandRemotePaths(alias, files)
+	if err != nil {
+		return nil, err
+	}
+	dest := files[len(files)-1]
+	sources := files[:len(files)-1]
+	patterns := make([]string, len(sources))
+	for i, src := range sources {
+		patterns[i] = colonPath(src)
+	}
+	out, err := remoteOutput(alias, "dry-run", remoteOpts{}, manifestScript(patterns))
+	if err != nil {
+		return nil, fmt.Errorf("listing %s on %s: %w", strings.Join(patterns, " "), alias, err)
+	}
+	found := parseManifest(out, sources)
+
+	into := len(sources) > 1 || strings.HasSuffix(dest, "/") || transferEngine == "tar"
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		into = true
+	}
+	f := currentFilter()
+	var m []manifestEntry
+	for i, src := range sources {
+		if len(found[i]) == 0 {
+			return nil, withCode(exitTransfer, fmt.Errorf("no files on %s match %s", alias, patterns[i]))
+		}
+		for _, rf := range found[i] {
+			if f.skip(rf.rel) {
+				continue
+			}
+			to := dest
+			if into || transport.CopiesContents(src) || hasGlob(patterns[i]) || rf.path != path.Clean(patterns[i]) {
+				to = filepath.Join(dest, filepath.FromSlash(rf.rel))
+			}
+			m = append(m, manifestEntry{from: alias + ":" + rf.path, to: to, size: rf.size})
+		}
+	}
+	return m, nil
+}
+
+// renderManifest prints a dry run's files, with their sizes and where
+// they would land, and the total.
+func renderManifest(w io.Writer, m []manifestEntry) {
+	var total int64
+	for _, e := range m {
+		fmt.Fprintf(w, "%8s  %s -> %s\n", formatBytes(uint64(e.size)), e.from, e.to)
+		total += e.size
+	}
+	symbolColor.Fprintf(w, "%d file(s), %s (dry run: nothing copied)\n", len(m), formatBytes(uint64(total)))
+}
+
+// dryRun is --dry-run for a transfer with alias: the manifest instead of
+// the copy.
+func dryRun(w io.Writer, alias string, files []string) error {
+	if err := transport.ValidateSCPPaths(files); err != nil {
+		return err
+	}
+	manifest := uploadManifest
+	if !strings.HasPrefix(files[len(files)-1], ":") {
+		manifest = downloadManifest
+	}
+	m, err := manifest(alias, files)
+	if err != nil {
+		return err
+	}
+	renderManifest(w, m)
+	return nil
+}
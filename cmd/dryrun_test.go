@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadManifest(t *testing.T) {
+	useMockExec(t)
+	useWebGroup(t)
+	useEngine(t, "scp", 0, 0)
+	dir := t.TempDir()
+	site := filepath.Join(dir, "site")
+	require.NoError(t, os.MkdirAll(filepath.Join(site, "css"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(site, "index.html"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(site, "css", "app.css"), make([]byte, 2048), 0o644))
+	conf := filepath.Join(dir, "app.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("x"), 0o644))
+
+	m, err := uploadManifest("web-1", []string{site, conf, ":/var/www/"})
+	require.NoError(t, err)
+	assert.Equal(t, []manifestEntry{
+		{from: filepath.Join(site, "css", "app.css"), to: "web-1:/var/www/site/css/app.css", size: 2048},
+		{from: filepath.Join(site, "index.html"), to: "web-1:/var/www/site/index.html", size: 5},
+		{from: conf, to: "web-1:/var/www/app.conf", size: 1},
+	}, m)
+
+	m, err = uploadManifest("web-1", []string{site + "/", ":/var/www"})
+	require.NoError(t, err)
+	require.Len(t, m, 2)
+	assert.Equal(t, "web-1:/var/www/css/app.css", m[0].to, "a trailing slash copies the contents")
+
+	m, err = uploadManifest("web-1", []string{conf, ":/etc/app.conf"})
+	require.NoError(t, err)
+	assert.Equal(t, []manifestEntry{{from: conf, to: "web-1:/etc/app.conf", size: 1}}, m)
+
+	assert.Nil(t, mockRun("ssh"), "an upload's manifest is local")
+	assert.Nil(t, mockRun("scp"))
+}
+
+func TestManifestScriptRuns(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "logs", "old"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "logs", "a.log"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "logs", "old", "b c.log"), nil, 0o644))
+
+	sources := []string{":" + dir + "/logs", ":" + dir + "/logs/", ":" + dir + "/*.none"}
+	out, err := exec.Command("sh", "-c", manifestScript([]string{dir + "/logs", dir + "/logs/", dir + "/*.none"})).Output()
+	require.NoError(t, err)
+	files := parseManifest(out, sources)
+	assert.ElementsMatch(t, []remoteFile{
+		{path: dir + "/logs/a.log", rel: "logs/a.log", size: 5},
+		{path: dir + "/logs/old/b c.log", rel: "logs/old/b c.log"},
+	}, files[0])
+	assert.ElementsMatch(t, []remoteFile{
+		{path: dir + "/logs/a.log", rel: "a.log", size: 5},
+		{path: dir + "/logs/old/b c.log", rel: "old/b c.log"},
+	}, files[1])
+	assert.Empty(t, files[2])
+}
+
+func TestDownloadManifest(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	useEngine(t, "scp", 0, 0)
+	out := filepath.Join(t.TempDir(), "out")
+
+	m, err := downloadManifest("web-1", []string{":logs", out})
+	require.NoError(t, err)
+	assert.Equal(t, []manifestEntry{
+		{from: "web-1:logs/a.log", to: filepath.Join(out, "logs", "a.log"), size: 12},
+		{from: "web-1:logs/b c.log", to: filepath.Join(out, "logs", "b c.log"), size: 2048},
+	}, m)
+	assert.NoDirExists(t, out, "a dry run creates nothing")
+
+	m, err = downloadManifest("web-1", []string{":logs/", out})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(out, "a.log"), m[0].to)
+	assert.Nil(t, mockRun("scp"))
+}
+
+func TestDryRunCommands(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	useEngine(t, "scp", 0, 0)
+	defer func() { transferDryRun = false }()
+	transferDryRun = true
+	conf := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("x"), 0o644))
+
+	var b bytes.Buffer
+	pushCmd.SetOut(&b)
+	defer pushCmd.SetOut(nil)
+	require.NoError(t, pushCmd.RunE(pushCmd, []string{"@web", conf, ":/etc/app/"}))
+	assert.Contains(t, b.String(), "web-1:/etc/app/app.conf")
+	assert.Contains(t, b.String(), "web-2:/etc/app/app.conf")
+	assert.Contains(t, b.String(), "1 file(s), 1B (dry run: nothing copied)")
+
+	b.Reset()
+	pullCmd.SetOut(&b)
+	defer pullCmd.SetOut(nil)
+	require.NoError(t, pullCmd.RunE(pullCmd, []string{"web-1", ":logs/", "./out"}))
+	assert.Contains(t, b.String(), "2 file(s), 2.0K")
+	assert.Nil(t, mockRun("scp"), "nothing is copied")
+}
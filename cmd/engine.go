@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"gt/pkg/transport"
+)
+
+var (
+	transferEngine string
+	sftpRequests   int
+	sftpBufferSize int
+)
+
+// validateEngine checks --engine and the tuning that goes with it.
+func validateEngine() error {
+	switch transferEngine {
+	case "scp", "sftp", "tar":
+	default:
+		return fmt.Errorf("unknown --engine %q: use scp, sftp or tar", transferEngine)
+	}
+	if sftpRequests < 0 || sftpBufferSize < 0 {
+		return errors.New("--streams and --chunk-size must not be negative")
+	}
+	if err := validateAttrs(); err != nil {
+		return err
+	}
+	if err := loadExcludeFrom(); err != nil {
+		return err
+	}
+	return currentFilter().validate()
+}
+
+// sftpTransferCommand is transferCommand for --engine sftp: sftp with
+// its commands on stdin.
+func sftpTransferCommand(alias string, files []string, opts remoteOpts) (*exec.Cmd, error) {
+	if pluginTransport() != nil || usePuTTY() {
+		return nil, errors.New("--engine sftp needs the OpenSSH backend")
+	}
+	s := transport.SFTP{Requests: sftpRequests, BufferSize: sftpBufferSize, Progress: !quiet && !opts.batch, NoPreserve: noPerms && noTimes}
+	var batch string
+	if strings.HasPrefix(files[len(files)-1], ":") && !currentFilter().empty() {
+		puts, err := sftpPuts(files)
+		if err != nil {
+			return nil, err
+		}
+		batch = transport.SFTPPutBatch(s, puts)
+	} else {
+		var err error
+		if batch, err = transport.SFTPBatch(s, files); err != nil {
+			return nil, err
+		}
+	}
+	cmd := withAskpass(toolCommand("sftp", transport.SFTPArgs(opts.transport(alias, verbosity), s, alias)...), alias)
+	cmd.Stdin = strings.NewReader(batch)
+	return cmd, nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useEngine(t *testing.T, engine string, requests, bufferSize int) {
+	t.Helper()
+	t.Cleanup(func() { transferEngine, sftpRequests, sftpBufferSize = "scp", 0, 0 })
+	transferEngine, sftpRequests, sftpBufferSize = engine, requests, bufferSize
+}
+
+func TestValidateEngine(t *testing.T) {
+	useEngine(t, "sftp", 128, 0)
+	assert.NoError(t, validateEngine())
+	useEngine(t, "rsync", 0, 0)
+	assert.ErrorContains(t, validateEngine(), `unknown --engine "rsync"`)
+	useEngine(t, "sftp", -1, 0)
+	assert.Error(t, validateEngine())
+
+	transferExclude = []string{".git"}
+	t.Cleanup(func() { transferExclude = nil })
+	useEngine(t, "scp", 0, 0)
+	assert.NoError(t, validateEngine(), "scp filters too")
+	transferExclude = []string{"[z-a"}
+	assert.ErrorContains(t, validateEngine(), "bad pattern")
+}
+
+func TestTransferFlagsOnlyOnCopyCommands(t *testing.T) {
+	for _, c := range []*cobra.Command{rootCmd, pushCmd, pullCmd, shellCmd, queueRunCmd, daemonCmd} {
+		assert.NotNil(t, c.Flags().Lookup("engine"), c.Name())
+		assert.NotNil(t, c.Flags().Lookup("exclude"), c.Name())
+	}
+	for _, c := range []*cobra.Command{execCmd, listCmd, statusCmd, addCmd} {
+		assert.Nil(t, c.Flags().Lookup("engine"), c.Name())
+		assert.Nil(t, c.Flags().Lookup("include"), c.Name())
+		assert.Nil(t, c.Flags().Lookup("dry-run"), c.Name())
+	}
+}
+
+func TestSFTPEngine(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	useEngine(t, "sftp", 256, 262144)
+
+	cmd, err := transferCommand("web-1", []string{"app.conf", ":/etc/app/"}, remoteOpts{batch: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-R", "256", "-B", "262144", "-b", "-", "--", "web-1"}, cmd.Args[len(cmd.Args)-10:])
+	batch, err := io.ReadAll(cmd.Stdin)
+	require.NoError(t, err)
+	assert.Equal(t, "put -pR app.conf /etc/app/\n", string(batch), "no progress meter in a batch run")
+
+	mockCmd.reset()
+	require.NoError(t, pushOne("web-1", []string{"app.conf", ":/etc/app/"}))
+	assert.Contains(t, mockCmd.commands, "sftp")
+	assert.NotContains(t, mockCmd.commands, "scp")
+	err = pushOne("down", []string{"app.conf", ":/etc/app/"})
+	assert.Equal(t, exitConnection, ExitCode(err))
+}
+
+func TestSFTPEngineFiltered(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	useEngine(t, "sftp", 0, 0)
+	useFilter(t, []string{".git", "build", "*.log", "web"}, nil)
+	src := projectTree(t)
+
+	cmd, err := transferCommand("web-1", []string{src, ":/srv"}, remoteOpts{batch: true})
+	require.NoError(t, err)
+	batch, err := io.ReadAll(cmd.Stdin)
+	require.NoError(t, err)
+	assert.Equal(t, "-mkdir /srv/app\nput -p "+filepath.Join(src, "main.go")+" /srv/app/main.go\n", string(batch))
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/transport"
+)
+
+var (
+	escapeLocal   []string
+	escapeRemote  []string
+	escapeDynamic []string
+	escapeCancel  bool
+)
+
+// escapeSequences are OpenSSH's escapes, each typed as the escape
+// character at the start of a line followed by the key.
+var escapeSequences = []struct{ key, what string }{
+	{".", "disconnect, even from a hung session"},
+	{"^Z", "suspend ssh; fg brings it back"},
+	{"&", "background ssh at logout while forwards are still open"},
+	{"#", "list forwarded connections"},
+	{"C", "open the ssh> command line: -L, -R or -D adds a forward, -KL, -KR or -KD cancels one"},
+	{"R", "rekey the connection"},
+	{"V", "raise ssh's verbosity (v lowers it)"},
+	{"B", "send a BREAK to the remote"},
+	{"?", "list the escapes"},
+}
+
+// renderEscapes writes the escapes as typed with esc, ssh's resolved
+// EscapeChar. cmdline is its EnableEscapeCommandline, "" where ssh is too
+// old to have one.
+func renderEscapes(w io.Writer, esc, cmdline string) {
+	if esc == "none" {
+		warningColor.Fprintln(w, "Escapes are off for this host (EscapeChar none).")
+		return
+	}
+	symbolColor.Fprintln(w, "At the start of a line, type:")
+	for _, e := range escapeSequences {
+		aliasColor.Fprintf(w, "  %-4s", esc+e.key)
+		fmt.Fprintf(w, "%s\n", e.what)
+	}
+	aliasColor.Fprintf(w, "  %-4s", esc+esc)
+	fmt.Fprintf(w, "send %s itself\n", esc)
+	if cmdline == "no" {
+		warningColor.Fprintf(w, "\n%sC is off (EnableEscapeCommandline no, the default since OpenSSH 9.2):\nset EnableEscapeCommandline yes, or add forwards with gt escape <alias> -L ...\n", esc)
+	}
+}
+
+// muxForward adds forwards to alias's live ControlMaster connection, or
+// cancels them, without reconnecting: ssh -O forward and ssh -O cancel.
+func muxForward(alias string, cancel bool, flags []string) error {
+	if pluginTransport() != nil || usePuTTY() {
+		return errors.New("changing forwards on a live connection needs the OpenSSH backend")
+	}
+	check := sshCommand(append(sshBaseArgs(), "-O", "check", "--", alias)...)
+	debugf(1, "exec: %s", quoteArgv(check.Args))
+	if err := check.Run(); err != nil {
+		return withCode(exitConnection, fmt.Errorf("no live multiplexed connection to %s: set ControlMaster auto and a ControlPath for it in ssh_config, then connect", alias))
+	}
+	op := "forward"
+	if cancel {
+		op = "cancel"
+	}
+	args := append(append(sshBaseArgs(), "-O", op), flags...)
+	return runCommand(sshCommand(append(args, "--", alias)...))
+}
+
+var escapeCmd = &cobra.Command{
+	Use:   "escape [alias]",
+	Short: "List ssh's ~ escapes, or change forwards on a live connection",
+	Long: `List the escape sequences OpenSSH understands inside a session (~. to
+disconnect, ~^Z to suspend, ~C for the forwarding command line, ...). With
+an alias, they are shown with that host's EscapeChar, along with whether
+~C is enabled for it.
+
+With -L, -R or -D, gt adds those forwards to a live connection to the host
+instead, without reconnecting; --cancel removes them. This goes through
+the connection's ControlMaster, so the host needs ControlMaster and
+ControlPath set in ssh_config and a session already open:
+
+  gt escape web1 -L 8080:localhost:80
+  gt escape web1 --cancel -L 8080:localhost:80`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeHosts,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var flags []string
+		for _, f := range []struct {
+			flag  string
+			specs []string
+		}{{"-L", escapeLocal}, {"-R", escapeRemote}, {"-D", escapeDynamic}} {
+			for _, spec := range f.specs {
+				if err := transport.ValidateNoFlagPrefix("forward", spec); err != nil {
+					return err
+				}
+				flags = append(flags, f.flag, spec)
+			}
+		}
+		if len(args) == 0 {
+			if len(flags) > 0 || escapeCancel {
+				return errors.New("changing forwards needs the alias of the live connection")
+			}
+			renderEscapes(cmd.OutOrStdout(), "~", "")
+			return nil
+		}
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		if len(flags) > 0 {
+			return muxForward(alias, escapeCancel, flags)
+		}
+		if escapeCancel {
+			return errors.New("--cancel needs the -L, -R or -D forwards to remove")
+		}
+		opts, err := sshConfigDump(alias)
+		if err != nil {
+			return err
+		}
+		first := func(key string) string {
+			if v := opts[key]; len(v) > 0 {
+				return v[0]
+			}
+			return ""
+		}
+		esc := first("escapechar")
+		if esc == "" {
+			esc = "~"
+		}
+		renderEscapes(cmd.OutOrStdout(), esc, first("enableescapecommandline"))
+		return nil
+	},
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderEscapes(t *testing.T) {
+	plainOutput(t)
+	var b bytes.Buffer
+	renderEscapes(&b, "~", "")
+	assert.Contains(t, b.String(), "  ~.  disconnect")
+	assert.Contains(t, b.String(), "  ~^Z suspend")
+	assert.Contains(t, b.String(), "  ~~  send ~ itself")
+	assert.NotContains(t, b.String(), "EnableEscapeCommandline")
+
+	b.Reset()
+	renderEscapes(&b, "%", "no")
+	assert.Contains(t, b.String(), "  %C  open the ssh> command line")
+	assert.Contains(t, b.String(), "%C is off (EnableEscapeCommandline no")
+
+	b.Reset()
+	renderEscapes(&b, "none", "yes")
+	assert.Equal(t, "Escapes are off for this host (EscapeChar none).\n", b.String())
+}
+
+func TestMuxForward(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+
+	require.NoError(t, muxForward("web-1", false, []string{"-L", "8080:localhost:80", "-D", "1080"}))
+	require.Len(t, mockCmd.argLists, 2)
+	check, fwd := mockCmd.argLists[0], mockCmd.argLists[1]
+	assert.Equal(t, "check", check[indexOf(check, "-O")+1])
+	assert.Equal(t, []string{"-O", "forward", "-L", "8080:localhost:80", "-D", "1080", "--", "web-1"}, fwd[indexOf(fwd, "-O"):])
+
+	mockCmd.reset()
+	require.NoError(t, muxForward("web-1", true, []string{"-R", "9000:localhost:9000"}))
+	fwd = mockCmd.argLists[1]
+	assert.Equal(t, []string{"-O", "cancel", "-R", "9000:localhost:9000", "--", "web-1"}, fwd[indexOf(fwd, "-O"):])
+
+	mockCmd.reset()
+	err := muxForward("down", false, []string{"-L", "8080:localhost:80"})
+	assert.ErrorContains(t, err, "no live multiplexed connection to down")
+	assert.Equal(t, exitConnection, ExitCode(err))
+	assert.Len(t, mockCmd.argLists, 1, "nothing to forward without a master")
+}
+
+func TestEscapeRejectsForwardsWithoutAlias(t *testing.T) {
+	escapeLocal = []string{"8080:localhost:80"}
+	t.Cleanup(func() { escapeLocal = nil })
+	assert.ErrorContains(t, escapeCmd.RunE(escapeCmd, nil), "needs the alias")
+
+	escapeLocal = []string{"-oProxyCommand=x"}
+	assert.ErrorContains(t, escapeCmd.RunE(escapeCmd, []string{"web-1"}), "must not start with '-'")
+}
@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	execParallel    int
+	execRolling     int
+	execCanary      int
+	execMaxFailures int
+	execYes         bool
+	execOutputDir   string
+	execSudo        bool
+)
+
+// sudoCommand runs remoteCmd, a command line as ssh joins it, as root:
+// through sudo -n unless the login is root already.
+func sudoCommand(remoteCmd []string) []string {
+	return []string{shellScript(sudoPrefix(true) + "$sudo " + strings.Join(remoteCmd, " "))}
+}
+
+// outputMu serializes the prefixed output of hosts running at once, so
+// lines from different hosts never interleave mid-line.
+var outputMu sync.Mutex
+
+// prefixWriter writes whole lines to w, each led by the host's alias.
+// A partial line waits for its newline or for Flush.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.writeLine(p.buf[:i+1])
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes a last line that did not end in a newline.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	aliasColor.Fprint(p.w, p.prefix)
+	symbolColor.Fprint(p.w, " | ")
+	p.w.Write(line)
+}
+
+// execOne runs remoteCmd on alias in BatchMode with its output prefixed
+// by the alias, and also written unprefixed to logFile when set. It runs
+// the connect hooks and is audit-logged as "exec".
+func execOne(alias string, remoteCmd []string, logFile string) error {
+	return withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: remoteCmd}, func() error {
+		cmd, err := remoteCommand(alias, remoteOpts{batch: true}, remoteCmd...)
+		if err != nil {
+			return err
+		}
+		stdout := &prefixWriter{w: os.Stdout, prefix: alias}
+		stderr := &prefixWriter{w: os.Stderr, prefix: alias}
+		var tail tailBuffer
+		cmd.Stdout = stdout
+		cmd.Stderr = io.MultiWriter(stderr, &tail)
+		if logFile != "" {
+			f, err := os.OpenFile(logFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			cmd.Stdout = io.MultiWriter(stdout, f)
+			cmd.Stderr = io.MultiWriter(stderr, &tail, f)
+		}
+		debugf(1, "exec: %s", quoteArgv(cmd.Args))
+		start := time.Now()
+		err = runTracked(cmd, false)
+		stdout.Flush()
+		stderr.Flush()
+		logConnection(alias, "exec", start, err)
+		return classifyRun(alias, "ssh", err, tail.String())
+	})
+}
+
+// rolloutBatches splits aliases into the batches of a rolling run: the
+// canaries on their own, then batches of rolling hosts (all the rest at
+// once when rolling is 0).
+func rolloutBatches(aliases []string, canary, rolling int) [][]string {
+	var batches [][]string
+	if canary > 0 {
+		if canary > len(aliases) {
+			canary = len(aliases)
+		}
+		batches = append(batches, aliases[:canary])
+		aliases = aliases[canary:]
+	}
+	if rolling <= 0 {
+		rolling = len(aliases)
+	}
+	for len(aliases) > 0 {
+		n := rolling
+		if n > len(aliases) {
+			n = len(aliases)
+		}
+		batches = append(batches, aliases[:n])
+		aliases = aliases[n:]
+	}
+	return batches
+}
+
+// rollout is how gt exec works through a group.
+type rollout struct {
+	parallel int
+	rolling  int
+	canary   int
+	// maxFailures is how many failed hosts to tolerate before starting no
+	// more; negative means no limit.
+	maxFailures int
+	// confirm is asked once the canaries succeeded; false stops the run.
+	confirm func(remaining int) bool
+}
+
+// run works through the batches in order, each finishing before the next
+// starts. A failed canary, a declined confirmation or more than
+// maxFailures failures stop the run; hosts not yet started are reported
+// as skipped and the reason returned.
+func (r rollout) run(aliases []string, fn func(alias string) error, done func(hostResult)) ([]hostResult, error) {
+	var results []hostResult
+	var stopped error
+	failed := 0
+	batches := rolloutBatches(aliases, r.canary, r.rolling)
+	for i, batch := range batches {
+		if stopped != nil {
+			for _, alias := range batch {
+				results = append(results, hostResult{alias: alias, skipped: true})
+			}
+			continue
+		}
+		batchResults := fanOut(batch, r.parallel, fn, done)
+		results = append(results, batchResults...)
+		for _, res := range batchResults {
+			if res.err != nil {
+				failed++
+			}
+		}
+		remaining := len(aliases) - len(results)
+		switch {
+		case remaining == 0:
+		case r.maxFailures >= 0 && failed > r.maxFailures:
+			stopped = fmt.Errorf("aborted after %d failed host(s) (--max-failures %d)", failed, r.maxFailures)
+		case i == 0 && r.canary > 0 && failed > 0:
+			stopped = errors.New("aborted: a canary host failed")
+		case i == 0 && r.canary > 0 && r.confirm != nil && !r.confirm(remaining):
+			stopped = errors.New("aborted after the canaries")
+		}
+	}
+	return results, stopped
+}
+
+// reportProgress prints a line to stderr as each of total hosts
+// finishes, unless --quiet.
+func reportProgress(total int) func(hostResult) {
+	n := 0
+	return func(r hostResult) {
+		n++
+		if quiet {
+			return
+		}
+		symbolColor.Fprintf(os.Stderr, "[%d/%d] ", n, total)
+		aliasColor.Fprint(os.Stderr, r.alias)
+		if r.err != nil {
+			errorColor.Fprintf(os.Stderr, " failed: %s\n", firstLine(r.err.Error()))
+			return
+		}
+		userColor.Fprintf(os.Stderr, " ok (%s)\n", formatDuration(r.duration.Milliseconds()))
+	}
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec <@group|alias> <command>...",
+	Short: "Run a command on every host in a group",
+	Long: `Run a command on every host in a group, each line of output led by
+the host it came from, then print a table of every host's result. Hosts
+run in BatchMode, up to --parallel at a time; gt's flags go before the
+target, everything after it is the command.
+
+  gt exec @web uptime
+  gt exec --canary 1 --rolling 5 --max-failures 2 @web sudo systemctl restart app
+
+For fleet-wide changes, roll out in stages:
+
+  --canary N        run on the first N hosts alone, then ask before going on
+                    (--yes continues without asking); a failed canary stops
+  --rolling N       then work through the rest N hosts at a time, each batch
+                    finishing before the next starts
+  --max-failures M  start no more hosts once more than M have failed
+
+With --output-dir DIR, each host's stdout and stderr also go to
+DIR/<alias>.log, unprefixed, and DIR/summary.json records the run:
+
+  {"target": "@web", "command": ["uptime"], "started": "...", "finished": "...",
+   "hosts": [{"alias": "web-1", "result": "ok", "exit_code": 0,
+              "duration_ms": 412, "log": "web-1.log"}, ...]}
+
+Each argument of the command may be a Go template, expanded per host
+with {{.Alias}}, {{.Hostname}}, {{.User}}, {{.Port}}, {{.Groups}} (the
+host's groups) and {{.Vars.name}} (its vars in gt's config); join and
+quote are there for lists and the remote shell:
+
+  gt exec @web -- 'curl -H "Host: {{.Alias}}" http://{{.Hostname}}/health'
+  gt exec @web -- echo {{join .Groups ","}}
+
+--sudo runs the command as root, through sudo -n unless the login is root
+already, so hosts need passwordless sudo for it. Hosts marked trust:
+untrusted in gt's config are refused unless --allow-untrusted is given.
+
+Hosts never started are reported as skipped. Each run goes through the
+connect hooks and the audit log. gt exits 1 if any host failed or was
+skipped.`,
+	Args: cobra.MinimumNArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeTargets(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remoteCmd := args[1:]
+		if remoteCmd[0] == "--" {
+			remoteCmd = remoteCmd[1:]
+		}
+		if len(remoteCmd) == 0 {
+			return errors.New("no command to run")
+		}
+		tmpl, err := parseCommandTemplate(remoteCmd)
+		if err != nil {
+			return err
+		}
+		aliases, err := expandTarget(args[0])
+		if err != nil {
+			return err
+		}
+		if execSudo {
+			if err := refuseUntrusted(aliases, "run commands with --sudo"); err != nil {
+				return err
+			}
+		}
+		if execCanary < 0 || execRolling < 0 {
+			return errors.New("--canary and --rolling must not be negative")
+		}
+		r := rollout{parallel: execParallel, rolling: execRolling, canary: execCanary, maxFailures: -1}
+		if cmd.Flags().Changed("max-failures") {
+			if execMaxFailures < 0 {
+				return errors.New("--max-failures must not be negative")
+			}
+			r.maxFailures = execMaxFailures
+		}
+		if r.canary > 0 && !execYes {
+			if nonInteractive() {
+				return errors.New("--canary asks before going on; pass --yes to continue without asking")
+			}
+			p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+			r.confirm = func(remaining int) bool {
+				return p.confirm(fmt.Sprintf("Canaries succeeded. Continue with the other %d host(s)?", remaining), false)
+			}
+		}
+		cmd.SilenceUsage = true
+
+		logFile := func(string) string { return "" }
+		if execOutputDir != "" {
+			if err := os.MkdirAll(execOutputDir, 0o700); err != nil {
+				return err
+			}
+			logFile = func(alias string) string { return filepath.Join(execOutputDir, hostLogName(alias)) }
+		}
+
+		started := time.Now()
+		results, stopped := r.run(aliases, func(alias string) error {
+			argv := remoteCmd
+			if tmpl.templated() {
+				v, err := hostVarsFor(alias)
+				if err != nil {
+					return err
+				}
+				if argv, err = tmpl.expand(v); err != nil {
+					return err
+				}
+			}
+			if execSudo {
+				argv = sudoCommand(argv)
+			}
+			return execOne(alias, argv, logFile(alias))
+		}, reportProgress(len(aliases)))
+		renderResults(cmd.OutOrStdout(), results)
+		if execOutputDir != "" {
+			m := newExecManifest(args[0], remoteCmd, started, results, stopped)
+			if err := m.write(filepath.Join(execOutputDir, execManifestName)); err != nil {
+				return err
+			}
+			statusf(symbolColor, "Output and %s written to %s\n", execManifestName, execOutputDir)
+		}
+		if stopped != nil {
+			return withCode(1, stopped)
+		}
+		return failedHosts(results, 1)
+	},
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolloutBatches(t *testing.T) {
+	hosts := []string{"a", "b", "c", "d", "e"}
+	assert.Equal(t, [][]string{hosts}, rolloutBatches(hosts, 0, 0))
+	assert.Equal(t, [][]string{{"a"}, {"b", "c", "d", "e"}}, rolloutBatches(hosts, 1, 0))
+	assert.Equal(t, [][]string{{"a"}, {"b", "c"}, {"d", "e"}}, rolloutBatches(hosts, 1, 2))
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, rolloutBatches(hosts, 0, 2))
+	assert.Equal(t, [][]string{hosts}, rolloutBatches(hosts, 9, 0), "more canaries than hosts")
+}
+
+// fakeRun returns a host function that fails the given hosts and records
+// which ran.
+func fakeRun(fail ...string) (func(string) error, func() []string) {
+	var mu sync.Mutex
+	var ran []string
+	return func(alias string) error {
+			mu.Lock()
+			ran = append(ran, alias)
+			mu.Unlock()
+			for _, f := range fail {
+				if f == alias {
+					return errors.New("exit status 1")
+				}
+			}
+			return nil
+		}, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), ran...)
+		}
+}
+
+func skippedHosts(results []hostResult) []string {
+	var s []string
+	for _, r := range results {
+		if r.skipped {
+			s = append(s, r.alias)
+		}
+	}
+	return s
+}
+
+func TestRolloutStopsOnFailedCanary(t *testing.T) {
+	hosts := []string{"a", "b", "c"}
+	fn, ran := fakeRun("a")
+	asked := false
+	r := rollout{parallel: 2, canary: 1, maxFailures: -1, confirm: func(int) bool { asked = true; return true }}
+	results, err := r.run(hosts, fn, nil)
+	assert.ErrorContains(t, err, "canary")
+	assert.False(t, asked, "no point asking after a failed canary")
+	assert.Equal(t, []string{"a"}, ran())
+	assert.Equal(t, []string{"b", "c"}, skippedHosts(results))
+}
+
+func TestRolloutAsksAfterCanaries(t *testing.T) {
+	hosts := []string{"a", "b", "c"}
+	fn, ran := fakeRun()
+	var remaining int
+	r := rollout{parallel: 2, canary: 1, maxFailures: -1, confirm: func(n int) bool { remaining = n; return false }}
+	results, err := r.run(hosts, fn, nil)
+	assert.ErrorContains(t, err, "after the canaries")
+	assert.Equal(t, 2, remaining)
+	assert.Equal(t, []string{"a"}, ran())
+	assert.Len(t, results, 3)
+
+	fn, ran = fakeRun()
+	r.confirm = func(int) bool { return true }
+	_, err = r.run(hosts, fn, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, hosts, ran())
+}
+
+func TestRolloutMaxFailures(t *testing.T) {
+	hosts := []string{"a", "b", "c", "d", "e", "f"}
+	fn, ran := fakeRun("a", "c", "d")
+	r := rollout{parallel: 2, rolling: 2, maxFailures: 1}
+	results, err := r.run(hosts, fn, nil)
+	assert.ErrorContains(t, err, "aborted after 3 failed host(s) (--max-failures 1)")
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, ran(), "the batch that crossed the limit finishes")
+	assert.Equal(t, []string{"e", "f"}, skippedHosts(results))
+
+	fn, _ = fakeRun("a")
+	r.maxFailures = -1
+	results, err = r.run(hosts, fn, nil)
+	assert.NoError(t, err, "no limit: failures are only reported")
+	assert.Error(t, failedHosts(results, 1))
+}
+
+func TestPrefixWriter(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	p := &prefixWriter{w: &out, prefix: "web"}
+	p.Write([]byte("one\ntw"))
+	p.Write([]byte("o\nthree"))
+	assert.Equal(t, "web | one\nweb | two\n", out.String())
+	p.Flush()
+	assert.Equal(t, "web | one\nweb | two\nweb | three\n", out.String())
+}
+
+func TestExecOnGroup(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+
+	var out bytes.Buffer
+	execCmd.SetOut(&out)
+	t.Cleanup(func() { execCmd.SetOut(nil) })
+	err := execCmd.RunE(execCmd, []string{"@web", "uptime"})
+	assert.Equal(t, 1, ExitCode(err))
+
+	ran := 0
+	for i, c := range mockCmd.commands {
+		if c == "ssh" && contains(mockCmd.argLists[i], "uptime") {
+			ran++
+			assert.Contains(t, mockCmd.argLists[i], "BatchMode=yes")
+		}
+	}
+	assert.Equal(t, 3, ran)
+	assert.Regexp(t, `down\s+failed\s+\S+\s+connection to down failed`, out.String())
+	assert.Contains(t, out.String(), "2 ok, 1 failed")
+}
+
+func TestExecOutputDir(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+	dir := filepath.Join(t.TempDir(), "run")
+	execOutputDir = dir
+	t.Cleanup(func() { execOutputDir = "" })
+
+	execCmd.SetOut(io.Discard)
+	t.Cleanup(func() { execCmd.SetOut(nil) })
+	err := execCmd.RunE(execCmd, []string{"@web", "uptime"})
+	assert.Equal(t, 1, ExitCode(err))
+
+	for _, alias := range []string{"web-1", "web-2", "down"} {
+		assert.FileExists(t, filepath.Join(dir, alias+".log"))
+	}
+	logged, err := os.ReadFile(filepath.Join(dir, "down.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(logged), "Connection refused")
+	assert.NotContains(t, string(logged), "down |", "logs are unprefixed")
+
+	data, err := os.ReadFile(filepath.Join(dir, execManifestName))
+	assert.NoError(t, err)
+	var m execManifest
+	assert.NoError(t, json.Unmarshal(data, &m))
+	assert.Equal(t, "@web", m.Target)
+	assert.Equal(t, []string{"uptime"}, m.Command)
+	assert.Len(t, m.Hosts, 3)
+	results := map[string]manifestHost{}
+	for _, h := range m.Hosts {
+		results[h.Alias] = h
+	}
+	assert.Equal(t, "ok", results["web-1"].Result)
+	assert.Equal(t, 0, *results["web-1"].ExitCode)
+	assert.Equal(t, "failed", results["down"].Result)
+	assert.Equal(t, 255, *results["down"].ExitCode)
+	assert.Equal(t, "down.log", results["down"].Log)
+}
+
+func TestExecManifestSkipped(t *testing.T) {
+	m := newExecManifest("@web", []string{"true"}, time.Now(), []hostResult{
+		{alias: "a", err: errors.New("veto")},
+		{alias: "b", skipped: true},
+	}, errors.New("aborted: a canary host failed"))
+	assert.Equal(t, "aborted: a canary host failed", m.Aborted)
+	assert.Nil(t, m.Hosts[0].ExitCode, "no exit code when the command never ran")
+	assert.Equal(t, "skipped", m.Hosts[1].Result)
+	assert.Empty(t, m.Hosts[1].Log)
+}
+
+func TestHostLogName(t *testing.T) {
+	assert.Equal(t, "web-1.log", hostLogName("web-1"))
+	assert.Equal(t, ".._etc_passwd.log", hostLogName("../etc/passwd"))
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+// execManifestName is the summary gt exec --output-dir writes next to
+// the per-host logs.
+const execManifestName = "summary.json"
+
+// execManifest is summary.json: the run as a whole, then one entry per
+// host in target order. New fields go at the end, like the audit log's.
+type execManifest struct {
+	Target   string         `json:"target"`
+	Command  []string       `json:"command"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Aborted  string         `json:"aborted,omitempty"` // why the run stopped early
+	Hosts    []manifestHost `json:"hosts"`
+}
+
+type manifestHost struct {
+	Alias  string `json:"alias"`
+	Result string `json:"result"` // ok, failed or skipped
+	// ExitCode is the remote command's (or ssh's) status; left out when
+	// the command never ran.
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Log        string `json:"log,omitempty"` // relative to the manifest
+}
+
+// hostLogName is the file an alias's output goes to. Aliases are plain
+// names in practice, but a path separator must not escape the directory.
+func hostLogName(alias string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(alias) + ".log"
+}
+
+func newExecManifest(target string, command []string, started time.Time, results []hostResult, stopped error) execManifest {
+	m := execManifest{Target: target, Command: command, Started: started, Finished: time.Now()}
+	if stopped != nil {
+		m.Aborted = stopped.Error()
+	}
+	for _, r := range results {
+		h := manifestHost{Alias: r.alias, Result: "ok", DurationMS: r.duration.Milliseconds()}
+		switch {
+		case r.skipped:
+			h.Result = "skipped"
+			m.Hosts = append(m.Hosts, h)
+			continue
+		case r.err != nil:
+			h.Result = "failed"
+			h.Error = r.err.Error()
+		}
+		if code := exitCodeOf(r.err); code >= 0 {
+			h.ExitCode = &code
+		}
+		h.Log = hostLogName(r.alias)
+		m.Hosts = append(m.Hosts, h)
+	}
+	return m
+}
+
+func (m execManifest) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Exit codes for gt's own failures, from sysexits(3) so they stay clear of
+// the small numbers remote commands tend to use. Anything else exits 1,
+// and a remote command that ran exits with its own status, as with ssh.
+const (
+	exitHostNotFound = 68 // EX_NOHOST: no Host block or group matches
+	exitConnection   = 69 // EX_UNAVAILABLE: could not reach the host
+	exitTransfer     = 74 // EX_IOERR: the connection worked, the copy did not
+	exitAuth         = 77 // EX_NOPERM: the host refused every credential
+	exitConfig       = 78 // EX_CONFIG: gt's or the SSH config is broken
+)
+
+// codedError is a failure gt exits with a specific code for. The message
+// is the wrapped error's.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// withCode tags err with an exit code; nil stays nil.
+func withCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// ExitCode is the status gt should exit with after Execute returned err.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ce *codedError
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		if ee.ExitCode() > 0 {
+			return ee.ExitCode()
+		}
+	}
+	return 1
+}
+
+// passthrough reports whether err is only the exit status of a command
+// that ran and has already said why on stderr, such as a failing remote
+// command or plugin; gt adds nothing to it. An exit status gt wrapped in
+// its own message, say of a helper tool, is gt's failure rather than a
+// status to pass on.
+func passthrough(err error) bool {
+	_, ok := err.(*exec.ExitError)
+	return ok
+}
+
+// authFailures and connectFailures are how ssh, scp and plink report
+// failing to authenticate or to reach the host, matched against the end
+// of their stderr.
+var (
+	authFailures = []string{
+		"Permission denied (",
+		"Too many authentication failures",
+		"No more authentication methods to try",
+		"No supported authentication methods available",
+		"Access denied",
+	}
+	connectFailures = []string{
+		"Could not resolve hostname",
+		"Connection refused",
+		"Connection timed out",
+		"Operation timed out",
+		"No route to host",
+		"Network is unreachable",
+		"Connection closed by",
+		"Connection reset by",
+		"kex_exchange_identification",
+		"Host key verification failed",
+		"Network error:",
+	}
+)
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyRun turns the failure of an ssh (mode "ssh") or scp ("scp")
+// run into the matching exit code, judged from the tail of its stderr.
+// ssh exits 255 for its own errors, so a 255 that is not an
+// authentication failure is taken as a failed connection. A remote
+// command's own status is returned unchanged.
+func classifyRun(alias, mode string, err error, stderr string) error {
+	var ee *exec.ExitError
+	if err == nil || !errors.As(err, &ee) {
+		return err
+	}
+	switch {
+	case containsAny(stderr, skFailures):
+		return withCode(exitAuth, securityKeyError(alias, stderr, err))
+	case containsAny(stderr, authFailures):
+		return withCode(exitAuth, fmt.Errorf("authentication to %s failed: %w", alias, err))
+	case containsAny(stderr, connectFailures), mode == "ssh" && ee.ExitCode() == 255:
+		return withCode(exitConnection, fmt.Errorf("connection to %s failed: %w", alias, err))
+	case mode == "scp":
+		return withCode(exitTransfer, fmt.Errorf("transfer with %s failed: %w", alias, err))
+	}
+	return err
+}
+
+// tailBuffer keeps the last tailSize bytes written to it: enough for the
+// message a failing ssh ends with, without holding a whole session's
+// stderr.
+type tailBuffer struct{ buf []byte }
+
+const tailSize = 4096
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > tailSize {
+		t.buf = t.buf[len(t.buf)-tailSize:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string { return string(t.buf) }
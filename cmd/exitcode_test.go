@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// exitStatus returns the error of a real process that exited with code.
+func exitStatus(t *testing.T, code int) error {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh for exit statuses")
+	}
+	err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	if err == nil {
+		t.Fatal("expected an exit status")
+	}
+	return err
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, ExitCode(nil))
+	assert.Equal(t, 1, ExitCode(errors.New("boom")))
+	assert.Equal(t, exitHostNotFound, ExitCode(unknownHostError("nope")))
+	assert.Equal(t, exitHostNotFound, ExitCode(fmt.Errorf("context: %w", unknownHostError("nope"))))
+	assert.Equal(t, 3, ExitCode(exitStatus(t, 3)), "a remote command's status passes through")
+	assert.Equal(t, 1, ExitCode(fmt.Errorf("git push: %w", exitStatus(t, 128))), "a helper tool's status is gt's failure")
+}
+
+func TestClassifyRun(t *testing.T) {
+	tests := []struct {
+		mode, stderr string
+		code         int
+		want         int
+	}{
+		{"ssh", "me@web: Permission denied (publickey).\n", 255, exitAuth},
+		{"ssh", "ssh: Could not resolve hostname web: Name or service not known\n", 255, exitConnection},
+		{"ssh", "", 255, exitConnection},
+		{"ssh", "", 3, 3},
+		{"scp", "ssh: connect to host web port 22: Connection refused\n", 1, exitConnection},
+		{"scp", "scp: /etc/shadow: Permission denied\n", 1, exitTransfer},
+		{"scp", "Too many authentication failures\n", 1, exitAuth},
+		{"ssh", "FATAL ERROR: Network error: Connection timed out\n", 1, exitConnection},
+	}
+	for _, tt := range tests {
+		err := classifyRun("web", tt.mode, exitStatus(t, tt.code), tt.stderr)
+		assert.Equal(t, tt.want, ExitCode(err), "%s exit %d: %q", tt.mode, tt.code, tt.stderr)
+	}
+	assert.NoError(t, classifyRun("web", "ssh", nil, ""))
+}
+
+func TestConfigErrorsExitWithConfigCode(t *testing.T) {
+	useBrokenConfig(t)
+	err := setup(&cobra.Command{Use: "list"}, nil)
+	assert.Equal(t, exitConfig, ExitCode(err))
+}
+
+func TestTailBufferKeepsTheEnd(t *testing.T) {
+	var tail tailBuffer
+	for i := 0; i < tailSize; i++ {
+		tail.Write([]byte("x"))
+	}
+	tail.Write([]byte("Permission denied (publickey)."))
+	assert.Len(t, tail.String(), tailSize)
+	assert.Contains(t, tail.String(), "Permission denied (publickey).")
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gt/pkg/transport"
+)
+
+var (
+	transferInclude     []string
+	transferExclude     []string
+	transferExcludeFrom []string
+)
+
+// transferFilter is --exclude and --include: a path matching an exclude
+// pattern is left out with everything under it, unless it also matches
+// an include pattern. A pattern without a slash matches a path's last
+// element at any depth; one with a slash, the whole path from the
+// transferred name down.
+type transferFilter struct {
+	include, exclude []string
+}
+
+func currentFilter() transferFilter {
+	return transferFilter{include: transferInclude, exclude: transferExclude}
+}
+
+func (f transferFilter) empty() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// validate checks the patterns' syntax.
+func (f transferFilter) validate() error {
+	for _, p := range append(append([]string(nil), f.include...), f.exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		name := rel
+		if !strings.Contains(p, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// skip reports whether rel, a slash-separated path starting with the
+// transferred name, is left out: it or a directory above it is.
+func (f transferFilter) skip(rel string) bool {
+	for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+		if matchAny(f.exclude, p) && !matchAny(f.include, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// readPatterns reads --exclude-from's file: a pattern per line, blank
+// lines and lines starting with # skipped, as rsync reads one.
+func readPatterns(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, sc.Err()
+}
+
+// loadExcludeFrom adds the patterns of every --exclude-from file to
+// --exclude.
+func loadExcludeFrom() error {
+	for _, file := range transferExcludeFrom {
+		patterns, err := readPatterns(file)
+		if err != nil {
+			return fmt.Errorf("--exclude-from: %w", err)
+		}
+		transferExclude = append(transferExclude, patterns...)
+	}
+	transferExcludeFrom = nil
+	return nil
+}
+
+// prefilter applies --exclude and --include to an upload by scp, which
+// copies whole directories: each directory source is replaced with a
+// copy of its tree, made of symlinks to the files kept, which scp -r
+// follows. Sources left out entirely are dropped. tar and sftp filter as
+// they go, so their files pass through; a download can only be filtered
+// by tar, which unpacks it itself. cleanup removes the copy.
+func prefilter(files []string) (_ []string, cleanup func(), err error) {
+	cleanup = func() {}
+	f := currentFilter()
+	dest := files[len(files)-1]
+	switch {
+	case f.empty():
+		return files, cleanup, nil
+	case !strings.HasPrefix(dest, ":"):
+		if transferEngine != "tar" {
+			return nil, cleanup, errors.New("--exclude and --include on a download need --engine tar")
+		}
+		return files, cleanup, nil
+	case transferEngine != "scp":
+		return files, cleanup, nil
+	}
+	sources, _, err := localContents(files[:len(files)-1])
+	if err != nil {
+		return nil, cleanup, err
+	}
+	var stage string
+	var kept []string
+	for i, src := range sources {
+		name := filepath.Base(filepath.Clean(src))
+		if f.skip(name) {
+			continue
+		}
+		if info, err := os.Stat(src); err != nil || !info.IsDir() {
+			kept = append(kept, src)
+			continue
+		}
+		if stage == "" {
+			if stage, err = os.MkdirTemp("", "gt-upload-"); err != nil {
+				return nil, cleanup, err
+			}
+			cleanup = func() { removeStage(stage) }
+		}
+		staged := filepath.Join(stage, strconv.Itoa(i), name)
+		if err := stageTree(src, staged, name, f); err != nil {
+			cleanup()
+			return nil, func() {}, err
+		}
+		kept = append(kept, staged)
+	}
+	if len(kept) == 0 {
+		cleanup()
+		return nil, func() {}, errors.New("nothing to copy: --exclude leaves out every source")
+	}
+	debugf(1, "filter: staged %s as %s", strings.Join(sources, " "), quoteArgv(kept))
+	return append(kept, dest), cleanup, nil
+}
+
+// stageTree mirrors the tree at src, as the transferred name, at dst:
+// directories with their modes and times, files as symlinks to them,
+// symlinks as they are, and nothing f leaves out.
+func stageTree(src, dst, name string, f transferFilter) error {
+	type dir struct {
+		path string
+		info fs.FileInfo
+	}
+	var dirs []dir
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		if f.skip(path.Join(name, filepath.ToSlash(rel))) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		info, err := os.Stat(p)
+		if p != src {
+			info, err = d.Info()
+		}
+		if err != nil {
+			return err
+		}
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			dirs = append(dirs, dir{target, info})
+			return os.MkdirAll(target, 0o700)
+		case mode&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case mode.IsRegular():
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(abs, target)
+		}
+		return nil // sockets, devices and pipes have nothing to copy
+	})
+	if err != nil {
+		return err
+	}
+	// Deepest first, so setting a directory's time is not undone by
+	// touching what is in it.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		os.Chmod(d.path, d.info.Mode().Perm())
+		os.Chtimes(d.path, d.info.ModTime(), d.info.ModTime())
+	}
+	return nil
+}
+
+// removeStage removes prefilter's copy, making its directories writable
+// first: they carry the modes of the originals.
+func removeStage(stage string) {
+	filepath.WalkDir(stage, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(p, 0o700)
+		}
+		return nil
+	})
+	os.RemoveAll(stage)
+}
+
+// sftpPuts is --exclude and --include for an sftp upload: the planned
+// files listed path by path, so sftp creates the directories kept and
+// copies the files kept, rather than whole trees with put -R.
+func sftpPuts(files []string) ([]transport.SFTPPut, error) {
+	entries, _, err := collectUpload(files[:len(files)-1], currentFilter())
+	if err != nil {
+		return nil, err
+	}
+	dest := colonPath(files[len(files)-1])
+	into := len(files) > 2 || strings.HasSuffix(dest, "/")
+	for _, e := range entries {
+		into = into || e.info.IsDir()
+	}
+	var puts []transport.SFTPPut
+	for _, e := range entries {
+		remote := dest
+		if into {
+			remote = path.Join(dest, e.name)
+		}
+		switch {
+		case e.info.IsDir():
+			puts = append(puts, transport.SFTPPut{Local: e.local, Remote: remote, Dir: true})
+		case e.info.Mode()&fs.ModeSymlink != 0:
+			// put copies what a link points to, which must be a file.
+			if info, err := os.Stat(e.local); err == nil && info.Mode().IsRegular() {
+				puts = append(puts, transport.SFTPPut{Local: e.local, Remote: remote})
+			}
+		case e.info.Mode().IsRegular():
+			puts = append(puts, transport.SFTPPut{Local: e.local, Remote: remote})
+		}
+	}
+	if len(puts) == 0 {
+		return nil, errors.New("nothing to copy: --exclude leaves out every source")
+	}
+	return puts, nil
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/transport"
+)
+
+func TestTransferFilter(t *testing.T) {
+	f := transferFilter{exclude: []string{"node_modules", "*.log", "app/build"}, include: []string{"keep.log"}}
+	assert.True(t, f.skip("app/node_modules"))
+	assert.True(t, f.skip("app/web/node_modules/react/index.js"), "everything under an excluded directory")
+	assert.True(t, f.skip("app/debug.log"))
+	assert.False(t, f.skip("app/keep.log"), "include wins over exclude")
+	assert.True(t, f.skip("app/build/out.js"))
+	assert.False(t, f.skip("app/web/build/out.js"), "a pattern with a slash matches from the top")
+	assert.False(t, f.skip("app/src/main.go"))
+	assert.False(t, transferFilter{}.skip("anything"))
+
+	assert.ErrorContains(t, transferFilter{exclude: []string{"[z-a"}}.validate(), "bad pattern")
+}
+
+// useFilter sets --exclude and --include for a test.
+func useFilter(t *testing.T, exclude, include []string) {
+	t.Helper()
+	t.Cleanup(func() { transferExclude, transferInclude = nil, nil })
+	transferExclude, transferInclude = exclude, include
+}
+
+// projectTree is a source tree with the kind of clutter --exclude is for.
+func projectTree(t *testing.T) string {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "app")
+	for _, name := range []string{"main.go", "web/index.html", ".git/HEAD", "build/app.bin", "debug.log"} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(name), 0o644))
+	}
+	return src
+}
+
+func TestLoadExcludeFrom(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ignore")
+	require.NoError(t, os.WriteFile(file, []byte("# build output\nbuild\n\n  *.log  \n.git\n"), 0o644))
+	useFilter(t, []string{"tmp"}, nil)
+	transferExcludeFrom = []string{file}
+	require.NoError(t, loadExcludeFrom())
+	assert.Equal(t, []string{"tmp", "build", "*.log", ".git"}, transferExclude)
+
+	transferExcludeFrom = []string{filepath.Join(t.TempDir(), "missing")}
+	assert.ErrorContains(t, loadExcludeFrom(), "--exclude-from")
+	transferExcludeFrom = nil
+}
+
+func TestPrefilter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	useEngine(t, "scp", 0, 0)
+	src := projectTree(t)
+	conf := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(conf, nil, 0o644))
+
+	files := []string{src, conf, ":/srv/"}
+	got, cleanup, err := prefilter(files)
+	require.NoError(t, err)
+	assert.Equal(t, files, got, "no filter, nothing staged")
+	cleanup()
+
+	useFilter(t, []string{".git", "build", "*.log"}, []string{"keep.log"})
+	got, cleanup, err = prefilter(files)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	staged := got[0]
+	assert.Equal(t, "app", filepath.Base(staged), "the copied name is kept")
+	assert.Equal(t, []string{conf, ":/srv/"}, got[1:])
+	var kept []string
+	require.NoError(t, filepath.WalkDir(staged, func(p string, d fs.DirEntry, err error) error {
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(staged, p)
+			kept = append(kept, filepath.ToSlash(rel))
+		}
+		return err
+	}))
+	assert.Equal(t, []string{"main.go", "web/index.html"}, kept)
+	body, err := os.ReadFile(filepath.Join(staged, "web", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "web/index.html", string(body), "files are links to the originals")
+	cleanup()
+	assert.NoDirExists(t, staged)
+
+	got, cleanup, err = prefilter([]string{src + "/", ":/srv/app"})
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Equal(t, []string{filepath.Join(src, "main.go"), ":/srv/app"}, []string{got[0], got[len(got)-1]})
+	assert.Len(t, got, 3, "main.go and a staged web; .git, build and debug.log dropped")
+
+	_, _, err = prefilter([]string{filepath.Join(src, "debug.log"), ":/tmp/"})
+	assert.EqualError(t, err, "nothing to copy: --exclude leaves out every source")
+
+	_, _, err = prefilter([]string{":/srv/app", "./app"})
+	assert.ErrorContains(t, err, "need --engine tar")
+	useEngine(t, "tar", 0, 0)
+	got, _, err = prefilter([]string{src, ":/srv/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{src, ":/srv/"}, got, "tar filters as it packs")
+}
+
+func TestSFTPPuts(t *testing.T) {
+	src := projectTree(t)
+	useFilter(t, []string{".git", "build", "*.log"}, nil)
+	puts, err := sftpPuts([]string{src, ":/srv"})
+	require.NoError(t, err)
+	assert.Equal(t, []transport.SFTPPut{
+		{Local: src, Remote: "/srv/app", Dir: true},
+		{Local: filepath.Join(src, "main.go"), Remote: "/srv/app/main.go"},
+		{Local: filepath.Join(src, "web"), Remote: "/srv/app/web", Dir: true},
+		{Local: filepath.Join(src, "web", "index.html"), Remote: "/srv/app/web/index.html"},
+	}, puts)
+
+	puts, err = sftpPuts([]string{filepath.Join(src, "main.go"), ":/srv/main.go"})
+	require.NoError(t, err)
+	assert.Equal(t, []transport.SFTPPut{{Local: filepath.Join(src, "main.go"), Remote: "/srv/main.go"}}, puts)
+}
+
+func TestSCPUploadFiltered(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useEngine(t, "scp", 0, 0)
+	useFilter(t, []string{".git"}, nil)
+	src := projectTree(t)
+
+	require.NoError(t, runSCP("testserver", []string{src, ":/srv/"}))
+	args := mockRun("scp")
+	require.NotNil(t, args)
+	assert.Equal(t, []string{"-r", "-p", "--"}, args[:3])
+	staged := args[3]
+	assert.NotEqual(t, src, staged)
+	assert.Equal(t, "app", filepath.Base(staged))
+	assert.NoDirExists(t, staged, "the staged tree is removed after the copy")
+	assert.Equal(t, "testserver:/srv/", args[4])
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var flattenOutput string
+
+// flattenSentinel is a host name no config matches, for asking ssh -G
+// what it would use with no config at all.
+const flattenSentinel = "gt-flatten-defaults.invalid"
+
+// flattenFirst are written at the top of each Host block, in this order;
+// the rest follow sorted by name.
+var flattenFirst = []string{"hostname", "user", "port"}
+
+// sshDefaults is what ssh -G resolves with an empty config: the options
+// a flattened Host block can leave out.
+func sshDefaults() (map[string][]string, error) {
+	out, err := sshCommand("-F", os.DevNull, "-G", "--", flattenSentinel).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ssh -G with an empty config: %w", err)
+	}
+	return sshconf.ParseOptions(out), nil
+}
+
+// writeFlattened writes a Host block per row with every option ssh -G
+// resolved for it that differs from defaults, plus HostName, User and
+// Port always.
+func writeFlattened(w io.Writer, rows []listRow, defaults map[string][]string) {
+	for i, r := range rows {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Host %s\n", r.alias)
+		written := map[string]bool{}
+		for _, key := range flattenFirst {
+			for _, v := range r.opts[key] {
+				fmt.Fprintf(w, "  %s %s\n", key, v)
+			}
+			written[key] = true
+		}
+		keys := make([]string, 0, len(r.opts))
+		for key := range r.opts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if written[key] || equalValues(r.opts[key], defaults[key]) {
+				continue
+			}
+			for _, v := range r.opts[key] {
+				fmt.Fprintf(w, "  %s %s\n", key, v)
+			}
+		}
+	}
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// flattenConfig resolves every host and renders the flattened config,
+// headed by where it came from.
+func flattenConfig(hosts []string) ([]byte, error) {
+	if usePuTTY() {
+		return nil, errors.New("gt config flatten asks OpenSSH's ssh -G for every value; the PuTTY backend has no equivalent")
+	}
+	defaults, err := sshDefaults()
+	if err != nil {
+		return nil, err
+	}
+	rows := resolveListRows(hosts)
+	var failed []string
+	for _, r := range rows {
+		if r.err != nil {
+			failed = append(failed, r.alias)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, withCode(exitConfig, fmt.Errorf("ssh -G failed for %s", strings.Join(failed, ", ")))
+	}
+	path, _ := sshConfigPath()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s flattened by gt config flatten on %s: every Host with\n", path, time.Now().Format("2006-01-02"))
+	fmt.Fprintf(&buf, "# the values ssh -G resolved for it, includes expanded and Match applied.\n\n")
+	writeFlattened(&buf, rows, defaults)
+	return buf.Bytes(), nil
+}
+
+var configFlattenCmd = &cobra.Command{
+	Use:   "flatten",
+	Short: "Write one config with every Host fully resolved",
+	Long: `Write a single ssh_config with a Host block for every alias, holding
+the values ssh -G resolves for it: includes expanded, Match blocks and
+Host * defaults applied, tokens as ssh expands them. Options at
+OpenSSH's defaults are left out; HostName, User and Port are always
+there. For a machine or appliance that cannot follow Include, or to see
+a whole inventory at once.
+
+Match blocks are evaluated here and now: one on exec, localnetwork or
+the local user bakes in what it found on this machine.`,
+	Example: `  gt config flatten --output combined.conf
+  gt config flatten | ssh appliance 'cat > .ssh/config'`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hosts := getHosts()
+		if len(hosts) == 0 {
+			return withCode(exitHostNotFound, errors.New("no SSH hosts to flatten"))
+		}
+		cmd.SilenceUsage = true
+		data, err := flattenConfig(hosts)
+		if err != nil {
+			return err
+		}
+		if flattenOutput == "" || flattenOutput == "-" {
+			_, err := cmd.OutOrStdout().Write(data)
+			return err
+		}
+		if err := replaceFile(flattenOutput, data, 0o600); err != nil {
+			return err
+		}
+		statusf(symbolColor, "Wrote %d hosts to %s\n", len(hosts), flattenOutput)
+		return nil
+	},
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFlattened(t *testing.T) {
+	rows := []listRow{
+		{alias: "web", opts: map[string][]string{
+			"hostname": {"web.example.com"}, "user": {"deploy"}, "port": {"22"},
+			"identityfile":        {"~/.ssh/web", "~/.ssh/id_ed25519"},
+			"forwardagent":        {"no"},
+			"serveralivecountmax": {"3"},
+		}},
+		{alias: "db", opts: map[string][]string{"hostname": {"db"}, "user": {"me"}, "port": {"2222"}, "identityfile": {"~/.ssh/id_ed25519"}}},
+	}
+	defaults := map[string][]string{"port": {"22"}, "identityfile": {"~/.ssh/id_ed25519"}, "forwardagent": {"no"}, "serveralivecountmax": {"4"}}
+
+	var buf bytes.Buffer
+	writeFlattened(&buf, rows, defaults)
+	assert.Equal(t, `Host web
+  hostname web.example.com
+  user deploy
+  port 22
+  identityfile ~/.ssh/web
+  identityfile ~/.ssh/id_ed25519
+  serveralivecountmax 3
+
+Host db
+  hostname db
+  user me
+  port 2222
+`, buf.String())
+}
+
+func TestConfigFlatten(t *testing.T) {
+	useMockExec(t)
+	useWebGroup(t)
+	out := filepath.Join(t.TempDir(), "combined.conf")
+	orig := flattenOutput
+	defer func() { flattenOutput = orig }()
+	flattenOutput = out
+
+	err := configFlattenCmd.RunE(configFlattenCmd, nil)
+	assert.EqualError(t, err, "ssh -G failed for down")
+	assert.NoFileExists(t, out, "nothing half-written")
+
+	data, err := flattenConfig([]string{"web-1", "web-2"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# "), "headed by where it came from")
+	assert.Contains(t, string(data), "\nHost web-1\n  hostname test.example.com\n  user testuser\n  port 2222\n  identityfile ~/.ssh/test_key\n")
+	assert.Equal(t, 2, strings.Count(string(data), "\nHost "))
+	assert.NotContains(t, string(data), "forwardagent", "defaults are left out")
+}
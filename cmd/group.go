@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// allGroup is the implicit group of every concrete alias.
+const allGroup = "all"
+
+// isGroupTarget reports whether target names a group rather than a host.
+func isGroupTarget(target string) bool {
+	return strings.HasPrefix(target, "@")
+}
+
+// groupMembers lists the aliases in group, sorted. Membership comes from
+// the groups field of each host's metadata; @all is every alias in the
+// SSH config. A member that is no longer in the SSH config is an error
+// rather than a silent skip, so a stale group is noticed.
+func groupMembers(group string) ([]string, error) {
+	if group == allGroup {
+		return getHosts(), nil
+	}
+	var members []string
+	for alias, meta := range allHostMeta() {
+		for _, g := range meta.Groups {
+			if g == group {
+				members = append(members, alias)
+				break
+			}
+		}
+	}
+	if len(members) == 0 {
+		return nil, withCode(exitHostNotFound, fmt.Errorf("group '@%s' has no hosts", group))
+	}
+	sort.Strings(members)
+	for _, alias := range members {
+		if !knownHost(alias) {
+			return nil, withCode(exitHostNotFound, fmt.Errorf("group '@%s': host '%s' not found in SSH config", group, alias))
+		}
+	}
+	return members, nil
+}
+
+var (
+	targetExclude []string // --exclude-hosts: aliases, globs or @groups to leave out
+	targetLimit   int      // --limit: at most this many hosts, the first ones
+	targetRandom  int      // --random: this many hosts, picked at random
+)
+
+// shuffleHosts is rand.Shuffle, swappable in tests.
+var shuffleHosts = rand.Shuffle
+
+// expandTarget turns a command-line target into aliases: @group expands
+// to its members, a glob ("web-*") to the aliases it matches, anything
+// else must be a known alias. A comma-separated list of any of them,
+// "web1,web2,@db", stands for all of them. --exclude-hosts, --random and
+// --limit then carve out the hosts a command runs on.
+func expandTarget(target string) ([]string, error) {
+	aliases, err := resolveTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	return selectHosts(aliases)
+}
+
+// expandTargets is expandTarget for several targets: the union of their
+// aliases, in the order given, each alias once.
+func expandTargets(targets []string) ([]string, error) {
+	aliases, err := unionTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	return selectHosts(aliases)
+}
+
+func resolveTarget(target string) ([]string, error) {
+	if strings.Contains(target, ",") {
+		return unionTargets(strings.Split(target, ","))
+	}
+	if isGroupTarget(target) {
+		return groupMembers(strings.TrimPrefix(target, "@"))
+	}
+	if hasGlob(target) {
+		var matches []string
+		for _, alias := range getHosts() {
+			if ok, _ := path.Match(target, alias); ok {
+				matches = append(matches, alias)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, withCode(exitHostNotFound, fmt.Errorf("no hosts match '%s'", target))
+		}
+		return matches, nil
+	}
+	alias, err := hostArg(target)
+	if err != nil {
+		return nil, err
+	}
+	return []string{alias}, nil
+}
+
+func unionTargets(targets []string) ([]string, error) {
+	seen := map[string]bool{}
+	var aliases []string
+	for _, t := range targets {
+		if t == "" {
+			continue
+		}
+		members, err := resolveTarget(t)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range members {
+			if !seen[a] {
+				seen[a] = true
+				aliases = append(aliases, a)
+			}
+		}
+	}
+	if len(aliases) == 0 {
+		return nil, errors.New("no hosts given")
+	}
+	return aliases, nil
+}
+
+// excludedHost reports whether --exclude-hosts names alias, itself, by a
+// glob or through a @group.
+func excludedHost(alias string) bool {
+	for _, pattern := range targetExclude {
+		if isGroupTarget(pattern) {
+			members, _ := groupMembers(strings.TrimPrefix(pattern, "@"))
+			for _, m := range members {
+				if m == alias {
+					return true
+				}
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, alias); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectHosts applies --exclude-hosts, then --random or --limit, to the
+// aliases a target expanded to, as Ansible's --limit carves a subset out
+// of an inventory group. The hosts keep their order; --random only
+// decides which are kept.
+func selectHosts(aliases []string) ([]string, error) {
+	if targetLimit < 0 || targetRandom < 0 {
+		return nil, errors.New("--limit and --random must not be negative")
+	}
+	if targetLimit > 0 && targetRandom > 0 {
+		return nil, errors.New("--limit and --random exclude each other")
+	}
+	var kept []string
+	for _, alias := range aliases {
+		if !excludedHost(alias) {
+			kept = append(kept, alias)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, withCode(exitHostNotFound, errors.New("no hosts left after --exclude-hosts"))
+	}
+	if n := targetRandom; n > 0 && n < len(kept) {
+		order := make([]int, len(kept))
+		for i := range order {
+			order[i] = i
+		}
+		shuffleHosts(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		picked := order[:n]
+		sort.Ints(picked)
+		random := make([]string, n)
+		for i, k := range picked {
+			random[i] = kept[k]
+		}
+		kept = random
+	}
+	if n := targetLimit; n > 0 && n < len(kept) {
+		kept = kept[:n]
+	}
+	return kept, nil
+}
+
+// groupNames lists every group defined in gt's config, plus @all.
+func groupNames() []string {
+	seen := map[string]bool{allGroup: true}
+	names := []string{allGroup}
+	for _, meta := range allHostMeta() {
+		for _, g := range meta.Groups {
+			if !seen[g] {
+				seen[g] = true
+				names = append(names, g)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completeTargets completes the first argument with aliases and @groups.
+func completeTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	targets := append(describedHosts(), shortcutDescriptions()...)
+	for _, g := range groupNames() {
+		members, _ := groupMembers(g)
+		desc := fmt.Sprintf("%d hosts", len(members))
+		if len(members) == 1 {
+			desc = "1 host"
+		}
+		targets = append(targets, "@"+g+"\t"+desc)
+	}
+	return targets, cobra.ShellCompDirectiveNoFileComp
+}
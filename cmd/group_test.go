@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTarget(t *testing.T) {
+	origCfg, origGT := cfg, gtCfg
+	defer func() { cfg, gtCfg = origCfg, origGT }()
+	decoded, err := ssh_config.Decode(strings.NewReader("Host web-1 web-2 db\n  User deploy\n"))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	cfg = decoded
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{
+		"web-2": {Groups: []string{"web", "prod"}},
+		"web-1": {Groups: []string{"web"}},
+		"db":    {Groups: []string{"prod"}},
+		"gone":  {Groups: []string{"stale"}},
+	}}
+
+	tests := []struct {
+		target  string
+		want    []string
+		wantErr string
+	}{
+		{"db", []string{"db"}, ""},
+		{"@web", []string{"web-1", "web-2"}, ""},
+		{"@prod", []string{"db", "web-2"}, ""},
+		{"@all", []string{"db", "web-1", "web-2"}, ""},
+		{"nope", nil, "host 'nope' not found"},
+		{"@nope", nil, "group '@nope' has no hosts"},
+		{"@stale", nil, "host 'gone' not found"},
+		{"db,web-1", []string{"db", "web-1"}, ""},
+		{"web-2,@web,db", []string{"web-2", "web-1", "db"}, ""},
+		{"db,,", []string{"db"}, ""},
+		{",", nil, "no hosts given"},
+		{"db,nope", nil, "host 'nope' not found"},
+		{"web-*", []string{"web-1", "web-2"}, ""},
+		{"db,w?b-2", []string{"db", "web-2"}, ""},
+		{"mail-*", nil, "no hosts match 'mail-*'"},
+	}
+	for _, tt := range tests {
+		got, err := expandTarget(tt.target)
+		if tt.wantErr != "" {
+			assert.ErrorContains(t, err, tt.wantErr, tt.target)
+			continue
+		}
+		assert.NoError(t, err, tt.target)
+		assert.Equal(t, tt.want, got, tt.target)
+	}
+
+	assert.Equal(t, []string{"all", "prod", "stale", "web"}, groupNames())
+}
+
+// useSelection sets --exclude-hosts, --limit and --random for a test.
+func useSelection(t *testing.T, exclude []string, limit, random int) {
+	t.Helper()
+	origExclude, origLimit, origRandom, origShuffle := targetExclude, targetLimit, targetRandom, shuffleHosts
+	t.Cleanup(func() {
+		targetExclude, targetLimit, targetRandom, shuffleHosts = origExclude, origLimit, origRandom, origShuffle
+	})
+	targetExclude, targetLimit, targetRandom = exclude, limit, random
+}
+
+func TestSelectHosts(t *testing.T) {
+	useWebGroup(t)
+	gtCfg.Hosts["down"] = hostMeta{Groups: []string{"web", "flaky"}}
+	hosts := []string{"web-1", "web-2", "down"}
+
+	useSelection(t, []string{"web-2"}, 0, 0)
+	got, err := selectHosts(hosts)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-1", "down"}, got)
+
+	useSelection(t, []string{"@flaky", "nosuch"}, 0, 0)
+	got, _ = expandTarget("@web")
+	assert.Equal(t, []string{"web-1", "web-2"}, got, "a group excludes its members")
+
+	useSelection(t, []string{"web-*"}, 0, 0)
+	_, err = selectHosts([]string{"web-1", "web-2"})
+	assert.EqualError(t, err, "no hosts left after --exclude-hosts")
+	assert.Equal(t, exitHostNotFound, ExitCode(err))
+
+	useSelection(t, nil, 2, 0)
+	got, _ = selectHosts(hosts)
+	assert.Equal(t, []string{"web-1", "web-2"}, got)
+	got, _ = selectHosts(hosts[:1])
+	assert.Equal(t, []string{"web-1"}, got, "fewer hosts than the limit")
+
+	useSelection(t, []string{"web-1"}, 1, 0)
+	got, _ = selectHosts(hosts)
+	assert.Equal(t, []string{"web-2"}, got, "exclusion comes first")
+
+	useSelection(t, nil, 0, 2)
+	shuffleHosts = func(n int, swap func(i, j int)) { swap(0, 2) }
+	got, _ = selectHosts(hosts)
+	assert.Equal(t, []string{"web-2", "down"}, got, "picked at random, kept in order")
+
+	useSelection(t, nil, 1, 1)
+	_, err = selectHosts(hosts)
+	assert.EqualError(t, err, "--limit and --random exclude each other")
+	useSelection(t, nil, -1, 0)
+	_, err = selectHosts(hosts)
+	assert.Error(t, err)
+}
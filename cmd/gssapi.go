@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+
+	"gt/pkg/transport"
+)
+
+// useGSSAPI is --gssapi, Kerberos authentication for every host of the
+// run, as a host's gssapi setting is for that host.
+var useGSSAPI bool
+
+// gssapiOverrides log in with the Kerberos ticket and hand it on, so the
+// session can reach further Kerberized services as its user.
+var gssapiOverrides = []string{"GSSAPIAuthentication=yes", "GSSAPIDelegateCredentials=yes"}
+
+// gssapiEnabled reports whether alias authenticates with Kerberos.
+func gssapiEnabled(alias string) bool {
+	return useGSSAPI || hostMetaFor(alias).GSSAPI
+}
+
+// hostOptions applies what gt's config says about alias to o: GSSAPI,
+// then the trust level, which has the last word on what the host gets.
+func hostOptions(o transport.Options, alias string) transport.Options {
+	if gssapiEnabled(alias) {
+		o.Overrides = append(append([]string(nil), o.Overrides...), gssapiOverrides...)
+	}
+	return trustOptions(o, alias)
+}
+
+// klistBinary lists the Kerberos ticket cache; swappable for tests.
+var klistBinary = "klist"
+
+var (
+	principalRe = regexp.MustCompile(`(?m)^\s*(?:Default principal|Principal|Client):\s*(\S+)`)
+	noTicketsRe = regexp.MustCompile(`Cached Tickets: \(0\)`)
+)
+
+// kerberosTicket checks for a Kerberos ticket that has not expired, and
+// names its principal. klist -s says so on MIT and Heimdal alike; the
+// Windows klist has no -s, and lists no tickets when there are none.
+func kerberosTicket() (string, error) {
+	if runtime.GOOS != "windows" {
+		if err := execCommand(klistBinary, "-s").Run(); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return "", errors.New("klist not found; install the Kerberos client tools")
+			}
+			return "", errors.New("no valid Kerberos ticket; run kinit")
+		}
+	}
+	out, err := execCommand(klistBinary).Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", errors.New("klist not found; install the Kerberos client tools")
+		}
+		return "", fmt.Errorf("klist: %w", err)
+	}
+	if runtime.GOOS == "windows" && noTicketsRe.Match(out) {
+		return "", errors.New("no Kerberos ticket; log on to the domain, or run kinit")
+	}
+	if m := principalRe.FindSubmatch(out); m != nil {
+		return string(m[1]), nil
+	}
+	return "a ticket", nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/transport"
+)
+
+func TestHostOptionsGSSAPI(t *testing.T) {
+	useWebGroup(t)
+	plainOutput(t)
+	t.Cleanup(func() { useGSSAPI = false })
+	gtCfg.Hosts["web-1"] = hostMeta{GSSAPI: true}
+	gtCfg.Hosts["down"] = hostMeta{GSSAPI: true, Trust: trustUntrusted}
+	o := transport.Options{Overrides: []string{"ServerAliveInterval=30"}}
+
+	assert.Equal(t, o, hostOptions(o, "web-2"))
+	assert.Equal(t, []string{"ServerAliveInterval=30", "GSSAPIAuthentication=yes", "GSSAPIDelegateCredentials=yes"}, hostOptions(o, "web-1").Overrides)
+	assert.Equal(t, []string{"ServerAliveInterval=30"}, o.Overrides, "the caller's options are left alone")
+	assert.Equal(t, []string{"ForwardAgent=no", "ForwardX11=no", "ForwardX11Trusted=no", "GSSAPIDelegateCredentials=no", "ServerAliveInterval=30", "GSSAPIAuthentication=yes"},
+		hostOptions(o, "down").Overrides, "an untrusted host does not get the ticket")
+
+	useGSSAPI = true
+	assert.True(t, gssapiEnabled("web-2"), "--gssapi is for every host")
+}
+
+func TestRunSSHGSSAPI(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	t.Cleanup(func() { useGSSAPI = false })
+	useGSSAPI = true
+
+	require.NoError(t, runSSH("testserver", nil))
+	assert.Equal(t, []string{"-o", "GSSAPIAuthentication=yes", "-o", "GSSAPIDelegateCredentials=yes", "--", "testserver"}, mockRun("ssh"))
+}
+
+func TestKerberosTicket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the Windows klist has no -s")
+	}
+	useMockExec(t)
+	t.Cleanup(func() { klistBinary = "klist" })
+
+	principal, err := kerberosTicket()
+	require.NoError(t, err)
+	assert.Equal(t, "alice@EXAMPLE.COM", principal)
+
+	klistBinary = "klist-expired"
+	_, err = kerberosTicket()
+	assert.ErrorContains(t, err, "run kinit")
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/yaml.v3"
+
+	"gt/pkg/sshconf"
+)
+
+// gtConfig is gt's own configuration. Connection options belong in
+// ssh_config, where OpenSSH reads them; this covers how gt presents
+// itself and which tools it runs.
+type gtConfig struct {
+	Theme themeConfig `yaml:"theme"`
+
+	// SSHBinary and SCPBinary replace the ssh/scp found on PATH, e.g. a
+	// vendored OpenSSH build or a compatible wrapper such as assh.
+	// SSHArgs and SCPArgs are prepended to every invocation of each.
+	SSHBinary string   `yaml:"ssh_binary"`
+	SSHArgs   []string `yaml:"ssh_args"`
+	SCPBinary string   `yaml:"scp_binary"`
+	SCPArgs   []string `yaml:"scp_args"`
+
+	// Backend selects what runs connections: "auto" (default), "openssh",
+	// or "putty" for plink/pscp.
+	Backend string `yaml:"backend"`
+
+	// Dotfiles is a directory of dotfiles (.vimrc, .aliases, .bashrc,
+	// ...) to ship to ~/.gt on hosts that opt in, and source there for
+	// the session.
+	Dotfiles string `yaml:"dotfiles"`
+
+	// Hosts holds gt's per-host metadata, keyed by alias.
+	Hosts map[string]hostMeta `yaml:"hosts"`
+
+	// TeamInventory is a URL or path to a hosts file the team maintains,
+	// fetched by gt team refresh and read beneath the SSH config.
+	TeamInventory string `yaml:"team_inventory"`
+	// TeamTokenFile holds the bearer token for an inventory served by
+	// gt serve --inventory.
+	TeamTokenFile string `yaml:"team_token_file"`
+
+	// SyncPasswordCommand prints the WebDAV password for gt sync-config,
+	// so it need not be in the remote URL.
+	SyncPasswordCommand string `yaml:"sync_password_command"`
+
+	// KeychainKeys are the private keys whose passphrase gt looks up in
+	// the system keychain when ssh asks for it; gt keys keychain add
+	// stores one there and lists it here.
+	KeychainKeys []string `yaml:"keychain_keys"`
+
+	// CAKey is the public key of the certificate authority gt ca installs
+	// on hosts, for logins with user certificates it signed.
+	CAKey string `yaml:"ca_key"`
+
+	// MCP is what gt mcp lets an AI assistant see and run.
+	MCP mcpConfig `yaml:"mcp"`
+}
+
+// gtCfg is the loaded gt config; the zero value means "all defaults".
+var gtCfg gtConfig
+
+// gtConfigPath resolves gt's config file. GT_CONFIG wins; then
+// XDG_CONFIG_HOME per the XDG spec; then %AppData% on Windows and
+// ~/.config elsewhere.
+func gtConfigPath() (string, error) {
+	if p := os.Getenv("GT_CONFIG"); p != "" {
+		return p, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gt", "config.yaml"), nil
+	}
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "gt", "config.yaml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gt", "config.yaml"), nil
+}
+
+// loadGTConfig reads gt's config file. A missing file is not an error:
+// gt works without one. The file gets the same ownership and permission
+// check as the SSH config, since it steers what gt runs.
+func loadGTConfig(path string) (gtConfig, error) {
+	var c gtConfig
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return c, err
+	}
+	defer f.Close()
+	if err := sshconf.ValidateOpen(path, f); err != nil {
+		return c, err
+	}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && err != io.EOF {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	for alias, m := range c.Hosts {
+		if err := validateTrust(alias, m.Trust); err != nil {
+			return c, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// editGTHosts changes the hosts section of gt's config in place through
+// edit, which gets the section's mapping node, created if need be, and
+// reports whether it changed anything.
+func editGTHosts(edit func(hosts *yaml.Node) (bool, error)) error {
+	return editGTConfig(func(root *yaml.Node) (bool, error) {
+		hosts := mappingValue(root, "hosts")
+		if hosts == nil || hosts.Kind != yaml.MappingNode {
+			hosts = &yaml.Node{Kind: yaml.MappingNode}
+			setMappingValue(root, "hosts", hosts)
+		}
+		return edit(hosts)
+	})
+}
+
+// editGTConfig changes gt's config in place through edit, which gets the
+// top-level mapping node and reports whether it changed anything. The
+// file goes through a yaml.Node rather than gtConfig so its comments and
+// layout survive; it is checked to still load afterwards, and the hosts
+// and keychain keys in effect are reloaded.
+func editGTConfig(edit func(root *yaml.Node) (bool, error)) error {
+	path, err := gtConfigPath()
+	if err != nil {
+		return err
+	}
+	err = editConfig(path, func(data []byte) ([]byte, error) {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if doc.Kind == 0 {
+			doc.Kind = yaml.DocumentNode
+		}
+		if len(doc.Content) == 0 {
+			doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+		}
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: not a mapping", path)
+		}
+		if changed, err := edit(root); err != nil || !changed {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return nil, err
+		}
+		var c gtConfig
+		dec := yaml.NewDecoder(bytes.NewReader(buf.Bytes()))
+		dec.KnownFields(true)
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		gtCfg.Hosts = c.Hosts
+		gtCfg.KeychainKeys = c.KeychainKeys
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		return withCode(exitConfig, err)
+	}
+	return nil
+}
+
+// mappingValue is the value under key in the mapping node m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue puts v under key in the mapping node m, replacing what
+// is there or adding it at the end.
+func setMappingValue(m *yaml.Node, key string, v *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = v
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, v)
+}
+
+// deleteMappingKey removes key from the mapping node m, reporting whether
+// it was there.
+func deleteMappingKey(m *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Hook events. pre- hooks run before gt connects and can veto it by
+// exiting non-zero; post- hooks run after, and their failures are only
+// reported.
+const (
+	hookPreConnect   = "pre-connect"
+	hookPostConnect  = "post-connect"
+	hookPreTransfer  = "pre-transfer"
+	hookPostTransfer = "post-transfer"
+)
+
+// hookTimeout bounds a hook, so a hung one cannot wedge every
+// connection.
+var hookTimeout = 30 * time.Second
+
+// hookEvent is the JSON a hook reads on stdin. New fields go at the end,
+// like the audit log's.
+type hookEvent struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Alias   string    `json:"alias"`
+	Groups  []string  `json:"groups,omitempty"`
+	User    string    `json:"user,omitempty"` // the -u override, if any
+	Command []string  `json:"command,omitempty"`
+	Files   []string  `json:"files,omitempty"`
+	// Set for post- events only.
+	ExitCode   *int  `json:"exit_code,omitempty"`
+	DurationMS int64 `json:"duration_ms,omitempty"`
+}
+
+// hooksDir is where hook executables live: GT_HOOKS_DIR, or hooks/ next
+// to gt's config file.
+func hooksDir() (string, error) {
+	if dir := os.Getenv("GT_HOOKS_DIR"); dir != "" {
+		return dir, nil
+	}
+	p, err := gtConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(p), "hooks"), nil
+}
+
+// findHook returns the executable for event, or "" when there is none.
+// On Windows the name may carry any extension the shell can run.
+func findHook(dir, event string) string {
+	path := filepath.Join(dir, event)
+	if runtime.GOOS == "windows" {
+		for _, ext := range []string{".exe", ".bat", ".cmd"} {
+			if _, err := os.Stat(path + ext); err == nil {
+				return path + ext
+			}
+		}
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+		return ""
+	}
+	return path
+}
+
+// runHook runs the hook for e.Event, if installed, with e on stdin. Its
+// output goes to stderr so it never mixes with a remote command's
+// output.
+func runHook(e hookEvent) error {
+	dir, err := hooksDir()
+	if err != nil {
+		return err
+	}
+	path := findHook(dir, e.Event)
+	if path == "" {
+		return nil
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	cmd := execCommand(path)
+	cmd.Stdin = bytes.NewReader(append(payload, '\n'))
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GT_HOOK_EVENT="+e.Event)
+	debugf(1, "hook: %s", path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer track(cmd.Process, false)()
+	timer := time.AfterFunc(hookTimeout, func() { cmd.Process.Kill() })
+	defer timer.Stop()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s hook %s: %w", e.Event, path, err)
+	}
+	return nil
+}
+
+// withHooks runs fn between the pre and post hooks. A failing pre hook
+// stops fn from running at all.
+func withHooks(pre, post string, e hookEvent, fn func() error) error {
+	e.Groups = hostMetaFor(e.Alias).Groups
+	e.User = user
+	e.Event = pre
+	e.Time = time.Now()
+	if err := runHook(e); err != nil {
+		return fmt.Errorf("stopped by %v", err)
+	}
+
+	start := time.Now()
+	err := fn()
+	code := exitCodeOf(err)
+	e.Event = post
+	e.Time = time.Now()
+	e.ExitCode = &code
+	e.DurationMS = time.Since(start).Milliseconds()
+	if hookErr := runHook(e); hookErr != nil {
+		warningColor.Fprintf(os.Stderr, "%v\n", hookErr)
+	}
+	return err
+}
+
+// withEachHooks runs fn once between the pre and post hooks of each of
+// aliases, for a command that keeps one session open to them all. A pre
+// hook that fails for any of them stops fn from running at all.
+func withEachHooks(pre, post string, aliases []string, fn func() error) error {
+	if len(aliases) == 0 {
+		return fn()
+	}
+	return withHooks(pre, post, hookEvent{Alias: aliases[0]}, func() error {
+		return withEachHooks(pre, post, aliases[1:], fn)
+	})
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useHooks installs shell-script hooks that append their stdin to
+// events.jsonl in the hooks directory and exit with the given status.
+func useHooks(t *testing.T, exits map[string]int) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks here are shell scripts")
+	}
+	dir := t.TempDir()
+	t.Setenv("GT_HOOKS_DIR", dir)
+	for event, code := range exits {
+		script := "#!/bin/sh\ncat >> " + filepath.Join(dir, "events.jsonl") + "\nexit " + string(rune('0'+code)) + "\n"
+		if err := os.WriteFile(filepath.Join(dir, event), []byte(script), 0o755); err != nil {
+			t.Fatalf("write hook: %v", err)
+		}
+	}
+	return dir
+}
+
+func readHookEvents(t *testing.T, dir string) []hookEvent {
+	t.Helper()
+	data, _ := os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	var events []hookEvent
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var e hookEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestWithHooksPassesEvents(t *testing.T) {
+	dir := useHooks(t, map[string]int{hookPreConnect: 0, hookPostConnect: 0})
+	origGT := gtCfg
+	t.Cleanup(func() { gtCfg = origGT })
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"web": {Groups: []string{"prod"}}}}
+
+	ran := false
+	err := withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: "web", Command: []string{"uptime"}}, func() error {
+		ran = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, ran)
+
+	events := readHookEvents(t, dir)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, hookPreConnect, events[0].Event)
+		assert.Equal(t, "web", events[0].Alias)
+		assert.Equal(t, []string{"prod"}, events[0].Groups)
+		assert.Equal(t, []string{"uptime"}, events[0].Command)
+		assert.Nil(t, events[0].ExitCode, "pre events have no result")
+		assert.Equal(t, hookPostConnect, events[1].Event)
+		if assert.NotNil(t, events[1].ExitCode) {
+			assert.Equal(t, 0, *events[1].ExitCode)
+		}
+	}
+}
+
+func TestFailingPreHookVetoes(t *testing.T) {
+	dir := useHooks(t, map[string]int{hookPreTransfer: 1, hookPostTransfer: 0})
+
+	ran := false
+	err := withHooks(hookPreTransfer, hookPostTransfer, hookEvent{Alias: "web", Files: []string{"a", ":/tmp/"}}, func() error {
+		ran = true
+		return nil
+	})
+	assert.ErrorContains(t, err, "stopped by pre-transfer hook")
+	assert.False(t, ran)
+	assert.Len(t, readHookEvents(t, dir), 1, "no post hook for a transfer that never ran")
+}
+
+func TestFailingPostHookOnlyWarns(t *testing.T) {
+	useHooks(t, map[string]int{hookPostConnect: 1})
+	want := errors.New("ssh failed")
+	err := withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: "web"}, func() error { return want })
+	assert.Equal(t, want, err, "the connection's own result is kept")
+}
+
+func TestFindHookSkipsNonExecutables(t *testing.T) {
+	dir := useHooks(t, nil)
+	writeConfigFile(t, filepath.Join(dir, hookPreConnect), "#!/bin/sh\n")
+	assert.Empty(t, findHook(dir, hookPreConnect))
+	assert.Empty(t, findHook(dir, hookPostConnect))
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hostKeyChange is what ssh reports when it refuses a host whose key is
+// not the one known_hosts has for it.
+type hostKeyChange struct {
+	// fingerprint is that of the key the host sent, SHA256:...
+	fingerprint string
+	// file and line are the known_hosts entry the key conflicts with.
+	file string
+	line int
+}
+
+// hostKeyError is a failed run that ended in a changed host key, for the
+// interactive callers to offer the fix; the message and exit code stay
+// those of the wrapped error.
+type hostKeyError struct {
+	change hostKeyChange
+	err    error
+}
+
+func (e *hostKeyError) Error() string { return e.err.Error() }
+func (e *hostKeyError) Unwrap() error { return e.err }
+
+var (
+	sentKeyRe   = regexp.MustCompile(`key sent by the remote host is\s+(SHA256:[A-Za-z0-9+/=]+)`)
+	offendingRe = regexp.MustCompile(`Offending \S+ key in (.+):(\d+)`)
+)
+
+// parseHostKeyChange reads ssh's "REMOTE HOST IDENTIFICATION HAS CHANGED"
+// warning from the tail of its stderr.
+func parseHostKeyChange(stderr string) (hostKeyChange, bool) {
+	if !strings.Contains(stderr, "REMOTE HOST IDENTIFICATION HAS CHANGED") {
+		return hostKeyChange{}, false
+	}
+	sent := sentKeyRe.FindStringSubmatch(stderr)
+	off := offendingRe.FindStringSubmatch(stderr)
+	if sent == nil || off == nil {
+		return hostKeyChange{}, false
+	}
+	line, err := strconv.Atoi(off[2])
+	if err != nil {
+		return hostKeyChange{}, false
+	}
+	return hostKeyChange{fingerprint: sent[1], file: strings.TrimSpace(off[1]), line: line}, true
+}
+
+// keyFingerprint is the SHA256 fingerprint of a base64 public key, as
+// ssh-keygen -l prints it.
+func keyFingerprint(key string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("malformed key: %w", err)
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// knownHostsEntry is one line of a known_hosts file: its host patterns,
+// hashed or not, key and the comment after it, if any.
+type knownHostsEntry struct {
+	hosts, keyType, key, comment string
+}
+
+// String is e as a known_hosts line.
+func (e knownHostsEntry) String() string {
+	line := e.hosts + " " + e.keyType + " " + e.key
+	if e.comment != "" {
+		line += " " + e.comment
+	}
+	return line
+}
+
+// readKnownHostsLine reads line n, from 1, of a known_hosts file. Lines
+// with a @cert-authority or @revoked marker are not plain host keys and
+// are refused.
+func readKnownHostsLine(file string, n int) (knownHostsEntry, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return knownHostsEntry{}, err
+	}
+	lines := strings.Split(string(data), "\n")
+	if n < 1 || n > len(lines) {
+		return knownHostsEntry{}, fmt.Errorf("%s has no line %d", file, n)
+	}
+	f := strings.Fields(lines[n-1])
+	if len(f) < 3 || strings.HasPrefix(f[0], "@") {
+		return knownHostsEntry{}, fmt.Errorf("%s:%d is not a plain host key", file, n)
+	}
+	return knownHostsEntry{hosts: f[0], keyType: f[1], key: f[2], comment: strings.Join(f[3:], " ")}, nil
+}
+
+// replaceKnownHostsLine gives name the key keyType key in place of line n
+// of a known_hosts file, keeping the file's mode and every other line.
+// A line for name alone, hashed or not, is re-keyed where it is, its
+// comment kept. A line that also covers other hosts, as "a,b" or a
+// pattern does, keeps their key: name is taken off its list, when it is
+// there by name, and given a line of its own after it.
+func replaceKnownHostsLine(file string, n int, name, keyType, key string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	if n < 1 || n > len(lines) {
+		return fmt.Errorf("%s has no line %d", file, n)
+	}
+	old, err := readKnownHostsLine(file, n)
+	if err != nil {
+		return err
+	}
+	if !strings.ContainsAny(old.hosts, ",*?!") {
+		old.keyType, old.key = keyType, key
+		lines[n-1] = old.String()
+	} else {
+		var rest []string
+		for _, h := range strings.Split(old.hosts, ",") {
+			if !strings.EqualFold(h, name) {
+				rest = append(rest, h)
+			}
+		}
+		replaced := []string{knownHostsEntry{hosts: name, keyType: keyType, key: key}.String()}
+		if len(rest) > 0 {
+			old.hosts = strings.Join(rest, ",")
+			replaced = append([]string{old.String()}, replaced...)
+		}
+		lines = append(lines[:n-1], append(replaced, lines[n:]...)...)
+	}
+	return replaceFile(file, []byte(strings.Join(lines, "\n")), info.Mode().Perm())
+}
+
+// scanHostKey asks host for its keys with ssh-keyscan and returns the one
+// whose fingerprint is fingerprint, so the key written is the key ssh was
+// sent; ok is false when the scan does not turn it up.
+func scanHostKey(host, port, fingerprint string) (keyType, key string, ok bool) {
+	cmd := execCommand("ssh-keyscan", "-p", port, "--", host)
+	debugf(3, "exec: %s", quoteArgv(cmd.Args))
+	out, err := cmd.Output()
+	if err != nil {
+		debugf(1, "ssh-keyscan %s: %v", host, err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 3 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if fp, err := keyFingerprint(f[2]); err == nil && fp == fingerprint {
+			return f[1], f[2], true
+		}
+	}
+	return "", "", false
+}
+
+// offerHostKeyFix explains a changed host key behind err, if that is why
+// the run failed: which known_hosts line conflicts, and the fingerprints of
+// the key it holds, the key the host sent and what a fresh scan sees.
+// When the scan agrees with ssh, it offers to put the new key in the old
+// one's place. err is returned as it was either way; the next connection
+// is the user's to make.
+func offerHostKeyFix(alias string, err error) error {
+	var hk *hostKeyError
+	if !errors.As(err, &hk) {
+		return err
+	}
+	c := hk.change
+	known, kerr := readKnownHostsLine(c.file, c.line)
+	if kerr != nil {
+		warningColor.Fprintf(os.Stderr, "The host key of %s has changed, but gt cannot read the entry it conflicts with: %v\n", alias, kerr)
+		return err
+	}
+	oldFP, _ := keyFingerprint(known.key)
+	fmt.Fprintln(os.Stderr)
+	warningColor.Fprintf(os.Stderr, "The host key of %s is not the one %s has for it on line %d.\n", alias, c.file, c.line)
+	fmt.Fprintf(os.Stderr, "  known    %s (%s)\n", oldFP, known.keyType)
+	fmt.Fprintf(os.Stderr, "  sent     %s\n", c.fingerprint)
+
+	host, port := alias, "22"
+	name := alias
+	if r, opts, rerr := resolveHostOptions(alias); rerr == nil {
+		host = r.Hostname
+		if r.Port != "" {
+			port = r.Port
+		}
+		// The name ssh looked the key up by, and the one to give it.
+		name = knownHostsName(strings.ToLower(host), port)
+		if a := opts["hostkeyalias"]; len(a) > 0 && a[0] != "" && a[0] != "none" {
+			name = a[0]
+		}
+	}
+	keyType, key, ok := scanHostKey(host, port, c.fingerprint)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "  scanned  (not the key sent)\n")
+		warningColor.Fprintf(os.Stderr, "A fresh scan of %s does not see the key ssh was sent; leaving %s alone.\n", host, c.file)
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "  scanned  %s (%s)\n", c.fingerprint, keyType)
+	fmt.Fprintln(os.Stderr, "A reinstall or key rotation explains this; so does someone in the middle. Check the new fingerprint with whoever runs the host before trusting it.")
+	if nonInteractive() {
+		return err
+	}
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+	if !p.confirm(fmt.Sprintf("Give %s the scanned key in place of line %d of %s?", name, c.line, c.file), false) {
+		return err
+	}
+	if werr := replaceKnownHostsLine(c.file, c.line, name, keyType, key); werr != nil {
+		errorColor.Fprintf(os.Stderr, "Could not update %s: %v\n", c.file, werr)
+		return err
+	}
+	statusf(symbolColor, "Replaced the key of %s; connect again to use it\n", alias)
+	return err
+}
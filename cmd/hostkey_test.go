@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changedKeyStderr is how OpenSSH refuses a host whose key has changed.
+const changedKeyStderr = `@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
+@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @
+@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
+IT IS POSSIBLE THAT SOMEONE IS DOING SOMETHING NASTY!
+Someone could be eavesdropping on you right now (man-in-the-middle attack)!
+It is also possible that a host key has just been changed.
+The fingerprint for the ED25519 key sent by the remote host is
+SHA256:5/gdC8tsZ+1R7UnoiM4pMal9U3M82idPVzt95oIw3hQ.
+Please contact your system administrator.
+Add correct host key in %s to get rid of this message.
+Offending ED25519 key in %s:2
+  remove with:
+  ssh-keygen -f '%s' -R '[test.example.com]:2222'
+Host key for [test.example.com]:2222 has changed and you have requested strict checking.
+Host key verification failed.
+`
+
+func TestParseHostKeyChange(t *testing.T) {
+	c, ok := parseHostKeyChange(changedKeyStderr)
+	require.True(t, ok)
+	assert.Equal(t, hostKeyChange{fingerprint: "SHA256:5/gdC8tsZ+1R7UnoiM4pMal9U3M82idPVzt95oIw3hQ", file: "%s", line: 2}, c)
+
+	_, ok = parseHostKeyChange("ssh: connect to host down port 22: Connection refused\n")
+	assert.False(t, ok)
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	fp, err := keyFingerprint("AAAAC3NzaC1lZDI1NTE5")
+	require.NoError(t, err)
+	assert.Equal(t, "SHA256:5/gdC8tsZ+1R7UnoiM4pMal9U3M82idPVzt95oIw3hQ", fp)
+	_, err = keyFingerprint("not base64!")
+	assert.Error(t, err)
+}
+
+func TestOfferHostKeyFix(t *testing.T) {
+	useMockExec(t)
+	plainOutput(t)
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	const other = "other.example.com ssh-rsa AAAAB3NzaC1yc2E\n"
+	writeConfigFile(t, file, other+"|1|c2FsdA==|aGFzaA== ssh-ed25519 AAAAC3NzaC1lZDI1NTE5b2xk\n")
+	wrapped := &hostKeyError{change: hostKeyChange{fingerprint: "SHA256:5/gdC8tsZ+1R7UnoiM4pMal9U3M82idPVzt95oIw3hQ", file: file, line: 2},
+		err: withCode(exitConnection, errors.New("connection to test failed"))}
+
+	t.Setenv("GT_NONINTERACTIVE", "1")
+	err := offerHostKeyFix("test", wrapped)
+	assert.Equal(t, exitConnection, ExitCode(err))
+	assert.Contains(t, mockCmd.argLists[len(mockCmd.argLists)-1], "test.example.com", "scans the resolved host")
+	data, _ := os.ReadFile(file)
+	assert.Contains(t, string(data), "b2xk", "nothing is replaced without asking")
+
+	t.Setenv("GT_NONINTERACTIVE", "")
+	stdin := filepath.Join(t.TempDir(), "stdin")
+	writeConfigFile(t, stdin, "y\n")
+	in, err := os.Open(stdin)
+	require.NoError(t, err)
+	defer in.Close()
+	origStdin := os.Stdin
+	t.Cleanup(func() { os.Stdin = origStdin })
+	os.Stdin = in
+	assert.Equal(t, exitConnection, ExitCode(offerHostKeyFix("test", wrapped)))
+	data, _ = os.ReadFile(file)
+	assert.Equal(t, other+"|1|c2FsdA==|aGFzaA== ssh-ed25519 AAAAC3NzaC1lZDI1NTE5\n", string(data), "the hashed host stays, the key is the scanned one")
+
+	// A scan that does not see the key ssh was sent changes nothing.
+	wrapped.change.fingerprint = "SHA256:somethingelse"
+	writeConfigFile(t, stdin, "y\n")
+	in.Seek(0, 0)
+	offerHostKeyFix("test", wrapped)
+	data, _ = os.ReadFile(file)
+	assert.Equal(t, other+"|1|c2FsdA==|aGFzaA== ssh-ed25519 AAAAC3NzaC1lZDI1NTE5\n", string(data))
+
+	plain := errors.New("other failure")
+	assert.Equal(t, plain, offerHostKeyFix("test", plain))
+}
+
+func TestReplaceKnownHostsLine(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	const first = "other.example.com ssh-rsa AAAAB3NzaC1yc2E\n"
+
+	writeConfigFile(t, file, first+"[test.example.com]:2222 ssh-ed25519 b2xk added 2024-01-02\n")
+	require.NoError(t, replaceKnownHostsLine(file, 2, "[test.example.com]:2222", "ssh-ed25519", "bmV3"))
+	data, _ := os.ReadFile(file)
+	assert.Equal(t, first+"[test.example.com]:2222 ssh-ed25519 bmV3 added 2024-01-02\n", string(data), "the comment is kept")
+
+	writeConfigFile(t, file, first+"web1,test.example.com,192.0.2.1 ssh-ed25519 b2xk shared\n")
+	require.NoError(t, replaceKnownHostsLine(file, 2, "test.example.com", "ssh-ed25519", "bmV3"))
+	data, _ = os.ReadFile(file)
+	assert.Equal(t, first+"web1,192.0.2.1 ssh-ed25519 b2xk shared\ntest.example.com ssh-ed25519 bmV3\n", string(data), "the other hosts keep their key")
+
+	writeConfigFile(t, file, first+"*.example.com ssh-ed25519 b2xk\n")
+	require.NoError(t, replaceKnownHostsLine(file, 2, "test.example.com", "ssh-ed25519", "bmV3"))
+	data, _ = os.ReadFile(file)
+	assert.Equal(t, first+"*.example.com ssh-ed25519 b2xk\ntest.example.com ssh-ed25519 bmV3\n", string(data), "a pattern stays as it is")
+
+	writeConfigFile(t, file, first+"@revoked test.example.com ssh-ed25519 b2xk\n")
+	assert.Error(t, replaceKnownHostsLine(file, 2, "test.example.com", "ssh-ed25519", "bmV3"))
+}
+
+func TestRunSSHHostKeyChanged(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Setenv("GT_NONINTERACTIVE", "1")
+	useMockExec(t)
+	err := runSSH("rekeyed", nil)
+	var hk *hostKeyError
+	require.ErrorAs(t, err, &hk)
+	assert.Equal(t, hostKeyChange{fingerprint: "SHA256:5/gdC8tsZ+1R7UnoiM4pMal9U3M82idPVzt95oIw3hQ", file: "/nonexistent/known_hosts", line: 2}, hk.change)
+	assert.Equal(t, exitConnection, ExitCode(err))
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import "time"
+
+// hostMeta is gt's own per-host metadata, keyed by alias in the hosts
+// section of gt's config. It describes how gt treats a host; how to
+// connect to it stays in ssh_config.
+type hostMeta struct {
+	// Groups names the groups the host belongs to, addressed as @name.
+	Groups []string `yaml:"groups"`
+	// Description is a line about the host for people, shown by
+	// gt list --long.
+	Description string `yaml:"description"`
+	// FallbackAddresses are tried in order when the configured HostName
+	// does not accept a TCP connection within ConnectTimeout.
+	FallbackAddresses []string `yaml:"fallback_addresses"`
+	// ConnectTimeout bounds each reachability probe, e.g. "3s".
+	ConnectTimeout string `yaml:"connect_timeout"`
+	// Vars are free-form values for templates, as {{.Vars.name}} in exec
+	// commands, remote transfer paths and Dir.
+	Vars map[string]string `yaml:"vars"`
+	// Dir is the remote directory interactive sessions start in.
+	Dir string `yaml:"dir"`
+	// Shell is the login shell for interactive sessions, e.g. fish,
+	// instead of the account's.
+	Shell string `yaml:"shell"`
+	// TmuxSession makes interactive sessions attach to the tmux session
+	// of that name, creating it if need be.
+	TmuxSession string `yaml:"tmux_session"`
+	// Dotfiles ships gt's dotfiles directory with every interactive
+	// login, as --dotfiles does for one.
+	Dotfiles bool `yaml:"dotfiles"`
+	// Logs names log files for gt logs to tail, for services that do not
+	// log to the journal, e.g. app: /srv/app/log/production.log.
+	Logs map[string]string `yaml:"logs"`
+	// Expires is when a temporary host, such as a review or CI machine,
+	// stops being listed as live, in RFC 3339; gt add --ttl sets it and
+	// gt config prune removes the host after.
+	Expires string `yaml:"expires"`
+	// Trust is trusted, normal (the default) or untrusted. An untrusted
+	// host never gets agent or X11 forwarding or a connection that skips
+	// its host key check, and gt runs nothing there as root without
+	// --allow-untrusted.
+	Trust string `yaml:"trust"`
+	// OTPCommand prints the host's current one-time code, e.g. pass otp
+	// work, for gt to fill the server's verification code prompt with.
+	// gt asks first, unless OTPAuto says to go ahead.
+	OTPCommand string `yaml:"otp_command"`
+	OTPAuto    bool   `yaml:"otp_auto"`
+	// PasswordCommand prints the password for a host that takes no key,
+	// such as an old switch, e.g. op read op://infra/switch/password.
+	// gt hands it to ssh as it asks; it is never written anywhere.
+	PasswordCommand string `yaml:"password_command"`
+	// GSSAPI logs in with the Kerberos ticket and delegates it, as
+	// --gssapi does for one run.
+	GSSAPI bool `yaml:"gssapi"`
+	// QuietMOTD leaves the server's login banner out of commands and
+	// transfers, as --quiet-motd does for one run.
+	QuietMOTD bool `yaml:"quiet_motd"`
+	// CheckTZ notes on login where the host's timezone or locale is not
+	// the local one, as --check-tz does for one run.
+	CheckTZ bool `yaml:"check_tz"`
+	// CheckSessions warns on login when anyone is logged into the host
+	// already, as --check-sessions does for one run.
+	CheckSessions bool `yaml:"check_sessions"`
+}
+
+// defaultConnectTimeout applies when a host does not set its own.
+const defaultConnectTimeout = 5 * time.Second
+
+// hostMetaFor returns the metadata for alias, or the zero value.
+func hostMetaFor(alias string) hostMeta {
+	if m, ok := gtCfg.Hosts[alias]; ok {
+		return m
+	}
+	return teamMeta[alias]
+}
+
+// allHostMeta is the metadata of every host that has some: gt's config,
+// over the team inventory's.
+func allHostMeta() map[string]hostMeta {
+	if len(teamMeta) == 0 {
+		return gtCfg.Hosts
+	}
+	all := make(map[string]hostMeta, len(gtCfg.Hosts)+len(teamMeta))
+	for alias, m := range teamMeta {
+		all[alias] = m
+	}
+	for alias, m := range gtCfg.Hosts {
+		all[alias] = m
+	}
+	return all
+}
+
+// expired reports whether the host's Expires has passed at now. A
+// malformed Expires never expires, so a typo does not get a host pruned.
+func (m hostMeta) expired(now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, m.Expires)
+	return err == nil && !now.Before(t)
+}
+
+// connectTimeout parses the host's ConnectTimeout, falling back to the
+// default for an unset or malformed value.
+func (m hostMeta) connectTimeout() time.Duration {
+	if d, err := time.ParseDuration(m.ConnectTimeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultConnectTimeout
+}
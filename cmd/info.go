@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// infoScript gathers everything in one connection as key=value lines.
+// /proc covers Linux; the uname and sysctl fallbacks keep BSDs and macOS
+// from coming back empty.
+const infoScript = `echo "kernel=$(uname -sr)"
+echo "cpus=$(getconf _NPROCESSORS_ONLN 2>/dev/null || sysctl -n hw.ncpu 2>/dev/null)"
+if [ -r /etc/os-release ]; then . /etc/os-release; echo "distro=$PRETTY_NAME"
+elif command -v sw_vers >/dev/null; then echo "distro=$(sw_vers -productName) $(sw_vers -productVersion)"
+else echo "distro=$(uname -s)"; fi
+if [ -r /proc/uptime ]; then echo "uptime=$(cut -d' ' -f1 /proc/uptime)"
+else b=$(sysctl -n kern.boottime 2>/dev/null | sed 's/^{ sec = \([0-9]*\).*/\1/'); [ -n "$b" ] && echo "uptime=$(( $(date +%s) - b ))"; fi
+if [ -r /proc/loadavg ]; then echo "load=$(cut -d' ' -f1-3 /proc/loadavg)"
+else echo "load=$(sysctl -n vm.loadavg 2>/dev/null | tr -d '{}')"; fi
+[ -r /proc/meminfo ] && awk '/^MemTotal:/{t=$2} /^MemAvailable:/{a=$2} END{printf "mem=%.0f %.0f\n", (t-a)*1024, t*1024}' /proc/meminfo
+df -Pk / | awk 'NR==2{printf "disk=%.0f %.0f\n", $3*1024, $2*1024}'
+`
+
+// hostInfo is one host's parsed stats. Zero values mean "not reported".
+type hostInfo struct {
+	alias                string
+	kernel, distro, load string
+	cpus                 int
+	uptime               time.Duration
+	memUsed, memTotal    uint64
+	diskUsed, diskTotal  uint64
+	err                  error
+}
+
+// parseInfo reads infoScript's output. Unknown or malformed lines are
+// ignored: a missing figure is better than no summary at all.
+func parseInfo(alias string, out []byte) hostInfo {
+	info := hostInfo{alias: alias}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "kernel":
+			info.kernel = value
+		case "distro":
+			info.distro = value
+		case "cpus":
+			info.cpus, _ = strconv.Atoi(value)
+		case "load":
+			info.load = strings.Join(strings.Fields(value), " ")
+		case "uptime":
+			if secs, err := strconv.ParseFloat(value, 64); err == nil {
+				info.uptime = time.Duration(secs) * time.Second
+			}
+		case "mem":
+			info.memUsed, info.memTotal = parsePair(value)
+		case "disk":
+			info.diskUsed, info.diskTotal = parsePair(value)
+		}
+	}
+	return info
+}
+
+func parsePair(s string) (uint64, uint64) {
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		return 0, 0
+	}
+	a, err1 := strconv.ParseUint(f[0], 10, 64)
+	b, err2 := strconv.ParseUint(f[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0
+	}
+	return a, b
+}
+
+// formatBytes renders a size in binary units, one decimal: 3.8G.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	v, exp := float64(n)/unit, 0
+	for v >= unit && exp < 4 {
+		v /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", v, "KMGTP"[exp])
+}
+
+// formatUsage renders used / total (pct%), or "" when unknown.
+func formatUsage(used, total uint64) string {
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s / %s (%d%%)", formatBytes(used), formatBytes(total), used*100/total)
+}
+
+// formatUptime renders an uptime coarsely: 12d 3h, 5h 20m, 7m.
+func formatUptime(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	mins := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, mins)
+	}
+	return fmt.Sprintf("%dm", mins)
+}
+
+func renderInfo(w io.Writer, info hostInfo) {
+	aliasColor.Fprint(w, info.alias)
+	if info.err != nil {
+		fmt.Fprintln(w)
+		errorColor.Fprintf(w, "  %v\n", info.err)
+		return
+	}
+	if info.distro != "" {
+		fmt.Fprint(w, "  ")
+		domainColor.Fprint(w, info.distro)
+	}
+	if info.kernel != "" {
+		symbolColor.Fprint(w, " · ")
+		subdomainColor.Fprint(w, info.kernel)
+	}
+	fmt.Fprintln(w)
+	fields := []struct{ label, value string }{
+		{"uptime", formatUptime(info.uptime)},
+		{"load", info.load},
+		{"memory", formatUsage(info.memUsed, info.memTotal)},
+		{"disk /", formatUsage(info.diskUsed, info.diskTotal)},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		symbolColor.Fprintf(w, "  %-7s ", f.label)
+		portColor.Fprintln(w, f.value)
+	}
+}
+
+// renderInfoTable lays several hosts out one per row. Columns are padded
+// to the widest cell, measured in display columns.
+func renderInfoTable(w io.Writer, infos []hostInfo) {
+	header := []string{"HOST", "DISTRO", "KERNEL", "UPTIME", "LOAD", "MEMORY", "DISK /"}
+	rows := make([][]string, len(infos))
+	for i, info := range infos {
+		if info.err != nil {
+			rows[i] = []string{info.alias, info.err.Error()}
+			continue
+		}
+		rows[i] = []string{
+			info.alias, info.distro, info.kernel, formatUptime(info.uptime), info.load,
+			formatUsage(info.memUsed, info.memTotal), formatUsage(info.diskUsed, info.diskTotal),
+		}
+	}
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = displayWidth(h)
+	}
+	for _, row := range rows {
+		if n := displayWidth(row[0]); n > widths[0] {
+			widths[0] = n
+		}
+		if len(row) != len(header) {
+			continue // errors span the rest of the row and do not size columns
+		}
+		for i, cell := range row {
+			if n := displayWidth(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	pad := func(s string, i int) string {
+		if i == len(widths)-1 {
+			return s
+		}
+		return s + strings.Repeat(" ", widths[i]-displayWidth(s)+2)
+	}
+
+	for i, h := range header {
+		symbolColor.Fprint(w, pad(h, i))
+	}
+	fmt.Fprintln(w)
+	for _, row := range rows {
+		aliasColor.Fprint(w, pad(row[0], 0))
+		if len(row) != len(header) {
+			errorColor.Fprintln(w, row[1])
+			continue
+		}
+		last := len(row) - 1
+		for last > 0 && row[last] == "" {
+			last--
+		}
+		for i := 1; i <= last; i++ {
+			c := portColor
+			if i <= 2 {
+				c = domainColor
+			}
+			cell := row[i]
+			if i < last {
+				cell = pad(cell, i)
+			}
+			c.Fprint(w, cell)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// gatherInfo collects stats from every alias, a handful of connections
+// at a time, logging each under mode (see remoteOutput).
+func gatherInfo(aliases []string, mode string, opts remoteOpts) []hostInfo {
+	infos := make([]hostInfo, len(aliases))
+	sem := make(chan struct{}, 8)
+	var wg sync.WaitGroup
+	for i, alias := range aliases {
+		wg.Add(1)
+		go func(i int, alias string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			out, err := remoteOutput(alias, mode, opts, shellScript(infoScript))
+			if err != nil {
+				infos[i] = hostInfo{alias: alias, err: err}
+				return
+			}
+			infos[i] = parseInfo(alias, out)
+		}(i, alias)
+	}
+	wg.Wait()
+	return infos
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info <alias|@group>",
+	Short: "Show uptime, load, memory, disk, kernel and distro of a host",
+	Long: `Connect once and print a short summary of the remote system: distro,
+kernel, uptime, load averages, memory and root filesystem usage.
+With @group, every member is queried in parallel (in BatchMode, so hosts
+that would prompt fail instead) and shown as one table.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		aliases, err := expandTarget(args[0])
+		if err != nil {
+			return err
+		}
+		// Several hosts run in batch mode so none stalls on a prompt.
+		infos := gatherInfo(aliases, "info", remoteOpts{batch: len(aliases) > 1})
+		var out bytes.Buffer
+		if isGroupTarget(args[0]) {
+			renderInfoTable(&out, infos)
+		} else {
+			if infos[0].err != nil {
+				return infos[0].err
+			}
+			renderInfo(&out, infos[0])
+		}
+		return pageOutput(out.Bytes())
+	},
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInfo(t *testing.T) {
+	out := []byte(`kernel=Linux 6.1.0-18-amd64
+distro=Debian GNU/Linux 12 (bookworm)
+uptime=1049271.53
+load=0.08 0.03 0.01
+mem=1288490188 4081491968
+disk=12884901888 42949672960
+garbage
+mem2=1
+`)
+	info := parseInfo("web", out)
+	assert.Equal(t, hostInfo{
+		alias:     "web",
+		kernel:    "Linux 6.1.0-18-amd64",
+		distro:    "Debian GNU/Linux 12 (bookworm)",
+		load:      "0.08 0.03 0.01",
+		uptime:    1049271 * time.Second,
+		memUsed:   1288490188,
+		memTotal:  4081491968,
+		diskUsed:  12884901888,
+		diskTotal: 42949672960,
+	}, info)
+
+	assert.Equal(t, hostInfo{alias: "bsd", load: "0.10 0.20 0.30"}, parseInfo("bsd", []byte("load= 0.10 0.20  0.30 \nmem=lots\n")))
+}
+
+func TestFormatters(t *testing.T) {
+	assert.Equal(t, "512B", formatBytes(512))
+	assert.Equal(t, "1.5K", formatBytes(1536))
+	assert.Equal(t, "3.8G", formatBytes(4081491968))
+	assert.Equal(t, "1.2G / 3.8G (31%)", formatUsage(1288490188, 4081491968))
+	assert.Equal(t, "", formatUsage(0, 0))
+
+	assert.Equal(t, "", formatUptime(0))
+	assert.Equal(t, "7m", formatUptime(7*time.Minute))
+	assert.Equal(t, "5h 20m", formatUptime(5*time.Hour+20*time.Minute))
+	assert.Equal(t, "12d 3h", formatUptime(291*time.Hour+40*time.Minute))
+}
+
+func TestRenderInfo(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	renderInfo(&out, hostInfo{alias: "web", distro: "Debian 12", kernel: "Linux 6.1", load: "0.08 0.03 0.01", memUsed: 1 << 30, memTotal: 4 << 30})
+	assert.Equal(t, `web  Debian 12 · Linux 6.1
+  load    0.08 0.03 0.01
+  memory  1.0G / 4.0G (25%)
+`, out.String())
+}
+
+func TestRenderInfoTable(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	renderInfoTable(&out, []hostInfo{
+		{alias: "db", distro: "Debian 12", kernel: "Linux 6.1", uptime: 49 * time.Hour, load: "1.00 0.50 0.25"},
+		{alias: "web-01", err: assert.AnError},
+	})
+	assert.Equal(t, strings.Join([]string{
+		"HOST    DISTRO     KERNEL     UPTIME  LOAD            MEMORY  DISK /",
+		"db      Debian 12  Linux 6.1  2d 1h   1.00 0.50 0.25",
+		"web-01  " + assert.AnError.Error(),
+		"",
+	}, "\n"), out.String())
+}
+
+// TestInfoScript runs the script through a local sh exactly as ssh would
+// hand it to the remote shell, so quoting mistakes show up here.
+func TestInfoScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	out, err := exec.Command("sh", "-c", shellScript(infoScript)).Output()
+	assert.NoError(t, err)
+	info := parseInfo("local", out)
+	assert.NotEmpty(t, info.kernel)
+	assert.NotEmpty(t, info.distro)
+	assert.NotZero(t, info.diskTotal)
+}
+
+func TestGatherInfo(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+
+	infos := gatherInfo([]string{"a", "b"}, "info", remoteOpts{batch: true})
+	assert.Len(t, infos, 2)
+	for _, args := range mockCmd.argLists {
+		if args[0] == "-G" {
+			continue // the audit log resolving the address
+		}
+		assert.Contains(t, args, "BatchMode=yes")
+		assert.Contains(t, args, "-T")
+	}
+
+	mockCmd.reset()
+	gatherInfo([]string{"a"}, "info", remoteOpts{})
+	assert.Contains(t, mockCmd.argLists[0], "-T")
+	assert.NotContains(t, mockCmd.argLists[0], "BatchMode=yes", "a single host may prompt")
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var initYes bool
+
+// starterConfig is what gt init writes, after the config.d Include if
+// one was asked for. Defaults go in a trailing "Host *" block: ssh takes
+// the first value it sees for an option, so a catch-all at the top would
+// override every host defined below it.
+const starterConfig = `# SSH client configuration, created by gt init.
+# Each Host block defines an alias: "gt web" (or "ssh web") connects with
+# its settings. See ssh_config(5) for every option.
+
+# Host web
+#   HostName web.example.com
+#   User deploy
+#   Port 22
+
+# Defaults for every host. Keep this block last: the first value ssh
+# finds for an option wins.
+Host *
+  ServerAliveInterval 60
+  ServerAliveCountMax 3
+  AddKeysToAgent yes
+`
+
+// writeStarterConfig creates path and its directory with the permissions
+// OpenSSH insists on, beginning with include when it is set. An existing
+// config is never touched.
+func writeStarterConfig(path, include string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists; gt init only creates a config that is missing", path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	body := starterConfig
+	if include != "" {
+		body = include + "\n\n" + body
+	}
+	if _, err := f.WriteString(body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// configDInclude is the Include line for a config.d directory next to
+// the config. It is written relative when the config lives in ~/.ssh,
+// which is what ssh resolves relative includes against.
+func configDInclude(configPath, home string) (dir, line string) {
+	dir = filepath.Join(filepath.Dir(configPath), "config.d")
+	if filepath.Dir(configPath) == filepath.Join(home, ".ssh") {
+		return dir, "Include config.d/*"
+	}
+	return dir, "Include " + filepath.Join(dir, "*")
+}
+
+// addInclude puts line at the top of an existing config: an Include
+// below a Host line would only apply to that block. A config that
+// already has the line is left as is.
+func addInclude(path, line string) (added bool, err error) {
+	if _, err := os.Stat(path); err != nil {
+		return false, err
+	}
+	err = editConfig(path, func(data []byte) ([]byte, error) {
+		for _, l := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(l) == line {
+				added = false
+				return nil, nil
+			}
+		}
+		added = true
+		return append([]byte(line+"\n\n"), data...), nil
+	})
+	return added, err
+}
+
+// prompter asks the yes/no questions of gt init. Answers are read a line
+// at a time, so they can be piped in; end of input takes the default.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	yes bool // --yes: take every default without asking
+}
+
+func (p *prompter) confirm(question string, def bool) bool {
+	hint := "[Y/n]"
+	if !def {
+		hint = "[y/N]"
+	}
+	if p.yes {
+		fmt.Fprintf(p.out, "%s %s\n", question, hint)
+		return def
+	}
+	for {
+		fmt.Fprintf(p.out, "%s %s ", question, hint)
+		line, err := p.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		case "":
+			if err != nil {
+				fmt.Fprintln(p.out)
+			}
+			return def
+		}
+		if err != nil {
+			return def
+		}
+	}
+}
+
+// ask asks for a value, def being what an empty answer (or --yes, or
+// end of input) takes.
+func (p *prompter) ask(question, def string) string {
+	if p.yes {
+		fmt.Fprintf(p.out, "%s [%s]\n", question, def)
+		return def
+	}
+	fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	line, err := p.in.ReadString('\n')
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	if err != nil {
+		fmt.Fprintln(p.out)
+	}
+	return def
+}
+
+// hasKey reports whether ~/.ssh already holds a private key of one of
+// the default names ssh tries.
+func hasKey(sshDir string) bool {
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa", "id_ed25519_sk", "id_ecdsa_sk"} {
+		if _, err := os.Stat(filepath.Join(sshDir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runInit walks through first-run setup: the config, a config.d include
+// directory, a default key, and shell completion. Every step is skipped
+// when already done, so running it again is harmless.
+func runInit(root *cobra.Command, p *prompter) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	path, err := sshConfigPath()
+	if err != nil {
+		return err
+	}
+
+	dir, include := configDInclude(path, home)
+	_, statErr := os.Stat(path)
+	switch {
+	case errors.Is(statErr, fs.ErrNotExist):
+		if !p.confirm(fmt.Sprintf("Create %s?", path), true) {
+			return nil
+		}
+		if !p.confirm(fmt.Sprintf("Include host files from %s?", dir), true) {
+			include = ""
+		}
+		if err := writeStarterConfig(path, include); err != nil {
+			return err
+		}
+		symbolColor.Fprintf(p.out, "Created %s\n", path)
+	case statErr != nil:
+		return statErr
+	default:
+		symbolColor.Fprintf(p.out, "Using existing %s\n", path)
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) &&
+			p.confirm(fmt.Sprintf("Include host files from %s? (adds %q to the top of the config)", dir, include), false) {
+			if _, err := addInclude(path, include); err != nil {
+				return err
+			}
+		} else {
+			include = ""
+		}
+	}
+	if include != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+		symbolColor.Fprintf(p.out, "Hosts in %s are now included\n", dir)
+	}
+
+	sshDir := filepath.Join(home, ".ssh")
+	if !hasKey(sshDir) {
+		key := filepath.Join(sshDir, "id_ed25519")
+		if nonInteractive() {
+			// Not something to do silently: ssh-keygen asks for a passphrase.
+			warningColor.Fprintf(os.Stderr, "Skipping key generation at %s: it needs a passphrase prompt\n", key)
+		} else if p.confirm(fmt.Sprintf("Generate an ed25519 key at %s?", key), true) {
+			if err := os.MkdirAll(sshDir, 0o700); err != nil {
+				return err
+			}
+			// ssh-keygen asks for the passphrase itself.
+			if err := runCommand(execCommand("ssh-keygen", "-t", "ed25519", "-f", key)); err != nil {
+				return fmt.Errorf("ssh-keygen: %w", err)
+			}
+		}
+	}
+
+	shell := detectShell()
+	if target, err := completionTargetFor(shell, home); err != nil {
+		debugf(1, "completion: %v", err)
+	} else if _, err := os.Stat(target.script); errors.Is(err, fs.ErrNotExist) &&
+		p.confirm(fmt.Sprintf("Install %s completion to %s?", shell, target.script), true) {
+		changed, err := installCompletion(root, shell, target, true)
+		if err != nil {
+			return err
+		}
+		symbolColor.Fprintf(p.out, "Installed %s\n", target.script)
+		if changed {
+			symbolColor.Fprintf(p.out, "Added a line to %s to load it\n", target.rc)
+		}
+	}
+	return nil
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up SSH and gt on a new machine",
+	Long: `Walk through first-run setup, asking before each step:
+
+  - create ~/.ssh (mode 0700) and a starter ~/.ssh/config (mode 0600) with a
+    commented example host and a few conservative defaults
+  - include host files from ~/.ssh/config.d, so hosts can be kept one
+    file per project
+  - generate an ed25519 key if there is no default key yet
+  - install shell completion for your shell (see gt completion install)
+
+Steps already done are skipped, and an existing config is only changed
+to add the config.d Include when you agree to it. --yes accepts every
+default without asking, as does --no-input, which also skips generating
+a key since ssh-keygen would ask for its passphrase. With --config, that file is set up instead.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout, yes: initYes || nonInteractive()}
+		if quiet && p.yes {
+			p.out = io.Discard
+		}
+		return runInit(cmd.Root(), p)
+	},
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gt/pkg/sshconf"
+)
+
+func TestWriteStarterConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ssh", "config")
+	if err := writeStarterConfig(path, ""); err != nil {
+		t.Fatalf("writeStarterConfig: %v", err)
+	}
+
+	if runtime.GOOS != "windows" {
+		dir, _ := os.Stat(filepath.Dir(path))
+		assert.Equal(t, os.FileMode(0o700), dir.Mode().Perm())
+		file, _ := os.Stat(path)
+		assert.Equal(t, os.FileMode(0o600), file.Mode().Perm())
+	}
+
+	// The starter config must load cleanly and define no aliases: the
+	// example host is commented out and Host * is not an alias.
+	loaded, err := sshconf.Load(path, sshconf.Options{})
+	if err != nil {
+		t.Fatalf("load starter config: %v", err)
+	}
+	assert.Empty(t, sshconf.Aliases(loaded.Config))
+	interval, _ := loaded.Get("anyhost", "ServerAliveInterval")
+	assert.Equal(t, "60", interval)
+}
+
+func TestWriteStarterConfigKeepsExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	writeConfigFile(t, path, "Host mine\n")
+
+	err := writeStarterConfig(path, "")
+	assert.ErrorContains(t, err, "already exists")
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "Host mine\n", string(data))
+}
+
+func TestConfigDInclude(t *testing.T) {
+	home := filepath.FromSlash("/home/me")
+	dir, line := configDInclude(filepath.Join(home, ".ssh", "config"), home)
+	assert.Equal(t, filepath.Join(home, ".ssh", "config.d"), dir)
+	assert.Equal(t, "Include config.d/*", line, "relative includes resolve against ~/.ssh")
+
+	dir, line = configDInclude(filepath.FromSlash("/etc/gt/ssh_config"), home)
+	assert.Equal(t, filepath.FromSlash("/etc/gt/config.d"), dir)
+	assert.Equal(t, "Include "+filepath.Join(dir, "*"), line)
+}
+
+func TestAddInclude(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	writeConfigFile(t, path, "Host web\n")
+
+	added, err := addInclude(path, "Include config.d/*")
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, err = addInclude(path, "Include config.d/*")
+	assert.NoError(t, err)
+	assert.False(t, added, "an Include already present is not added twice")
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "Include config.d/*\n\nHost web\n", string(data))
+}
+
+func TestPrompterConfirm(t *testing.T) {
+	var out bytes.Buffer
+	p := &prompter{in: bufio.NewReader(strings.NewReader("maybe\ny\n\nNO\n")), out: &out}
+	assert.True(t, p.confirm("a?", false), "re-asks until the answer is understood")
+	assert.True(t, p.confirm("b?", true), "empty takes the default")
+	assert.False(t, p.confirm("c?", true))
+	assert.False(t, p.confirm("d?", false), "end of input takes the default")
+	assert.Equal(t, 2, strings.Count(out.String(), "a? [y/N]"))
+
+	out.Reset()
+	yes := &prompter{in: bufio.NewReader(strings.NewReader("")), out: &out, yes: true}
+	assert.True(t, yes.confirm("e?", true))
+	assert.False(t, yes.confirm("f?", false), "--yes takes the default, not yes")
+}
+
+func TestRunInit(t *testing.T) {
+	useMockExec(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("SHELL", "/usr/bin/fish")
+	origCfgFile := cfgFile
+	t.Cleanup(func() { cfgFile = origCfgFile })
+	cfgFile = ""
+
+	var out bytes.Buffer
+	if err := runInit(rootCmd, &prompter{out: &out, yes: true}); err != nil {
+		t.Fatalf("runInit: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".ssh", "config"))
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	assert.True(t, strings.HasPrefix(string(data), "Include config.d/*\n"), string(data))
+	assert.DirExists(t, filepath.Join(home, ".ssh", "config.d"))
+	assert.Equal(t, []string{"ssh-keygen"}, mockCmd.commands)
+	assert.Equal(t, []string{"-t", "ed25519", "-f", filepath.Join(home, ".ssh", "id_ed25519")}, mockCmd.argLists[0])
+	script, err := os.ReadFile(filepath.Join(home, ".config", "fish", "completions", "gt.fish"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(script), "complete -c gt")
+
+	// A second run finds everything done and changes nothing.
+	mockCmd.reset()
+	writeConfigFile(t, filepath.Join(home, ".ssh", "id_ed25519"), "key")
+	out.Reset()
+	if err := runInit(rootCmd, &prompter{out: &out, yes: true}); err != nil {
+		t.Fatalf("second runInit: %v", err)
+	}
+	assert.Empty(t, mockCmd.commands)
+	assert.Contains(t, out.String(), "Using existing")
+	again, _ := os.ReadFile(filepath.Join(home, ".ssh", "config"))
+	assert.Equal(t, string(data), string(again))
+}
+
+func TestMissingConfigIsEmpty(t *testing.T) {
+	t.Cleanup(func() { missingConfig = "" })
+	path := filepath.Join(t.TempDir(), "config")
+
+	if err := loadConfig(path); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	assert.Empty(t, getHosts())
+	assert.Equal(t, path, missingConfig)
+	err := unknownHostError("web")
+	assert.True(t, strings.Contains(err.Error(), "gt init"), err.Error())
+
+	writeConfigFile(t, path, "Host web\n")
+	if err := loadConfig(path); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	assert.Empty(t, missingConfig)
+	assert.Equal(t, "host 'web' not found in SSH config", unknownHostError("web").Error())
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	serveInventory  string
+	serveTokenFile  string
+	inventoryRedact []string
+)
+
+// inventoryFields are what --redact can leave out of a served host; its
+// hostname always stays, being what the inventory is for.
+var inventoryFields = []string{"user", "port", "proxyjump", "groups", "description", "fallback_addresses", "connect_timeout", "vars", "logs"}
+
+// servedHost is a host as gt serve --inventory publishes it, in the
+// schema of a team inventory. How someone likes their sessions (dir,
+// shell, tmux_session, dotfiles) and which keys they use are theirs, and
+// not served.
+type servedHost struct {
+	HostName          string            `yaml:"hostname"`
+	User              string            `yaml:"user,omitempty"`
+	Port              string            `yaml:"port,omitempty"`
+	Options           map[string]string `yaml:"options,omitempty"`
+	Groups            []string          `yaml:"groups,omitempty"`
+	Description       string            `yaml:"description,omitempty"`
+	FallbackAddresses []string          `yaml:"fallback_addresses,omitempty"`
+	ConnectTimeout    string            `yaml:"connect_timeout,omitempty"`
+	Vars              map[string]string `yaml:"vars,omitempty"`
+	Logs              map[string]string `yaml:"logs,omitempty"`
+}
+
+// validateRedact checks --redact's field names.
+func validateRedact(fields []string) (map[string]bool, error) {
+	redact := map[string]bool{}
+	for _, f := range fields {
+		known := false
+		for _, k := range inventoryFields {
+			known = known || f == k
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown --redact field %q: want some of %s", f, strings.Join(inventoryFields, ", "))
+		}
+		redact[f] = true
+	}
+	return redact, nil
+}
+
+// servedHostFor is what the inventory says about a resolved host: User
+// and Port only where they differ from OpenSSH's defaults, which differ
+// from one teammate to the next, and nothing in redact.
+func servedHostFor(r listRow, defaults map[string][]string, redact map[string]bool) servedHost {
+	h := servedHost{HostName: r.Hostname}
+	if !redact["user"] && !equalValues(r.opts["user"], defaults["user"]) {
+		h.User = r.User
+	}
+	if !redact["port"] && !equalValues(r.opts["port"], defaults["port"]) {
+		h.Port = r.Port
+	}
+	if j := r.opts["proxyjump"]; !redact["proxyjump"] && len(j) > 0 && j[0] != "none" {
+		h.Options = map[string]string{"ProxyJump": j[0]}
+	}
+	m := hostMetaFor(r.alias)
+	if !redact["groups"] {
+		h.Groups = m.Groups
+	}
+	if !redact["description"] {
+		h.Description = m.Description
+	}
+	if !redact["fallback_addresses"] {
+		h.FallbackAddresses = m.FallbackAddresses
+	}
+	if !redact["connect_timeout"] {
+		h.ConnectTimeout = m.ConnectTimeout
+	}
+	if !redact["vars"] {
+		h.Vars = m.Vars
+	}
+	if !redact["logs"] {
+		h.Logs = m.Logs
+	}
+	return h
+}
+
+// buildInventory resolves aliases and renders them as a team inventory.
+// Hosts that came from a team inventory themselves are not passed on,
+// nor are hosts ssh -G could not resolve, which are reported.
+func buildInventory(aliases []string, redact map[string]bool) ([]byte, error) {
+	defaults, err := sshDefaults()
+	if err != nil {
+		return nil, err
+	}
+	var own []string
+	for _, alias := range aliases {
+		if _, team := teamMeta[alias]; !team {
+			own = append(own, alias)
+		}
+	}
+	hosts := map[string]servedHost{}
+	for _, r := range resolveListRows(own) {
+		if r.err != nil {
+			warningColor.Fprintf(os.Stderr, "Leaving %s out of the inventory: %v\n", r.alias, r.err)
+			continue
+		}
+		hosts[r.alias] = servedHostFor(r, defaults, redact)
+	}
+	return yaml.Marshal(struct {
+		Hosts map[string]servedHost `yaml:"hosts"`
+	}{hosts})
+}
+
+// serveToken is the token clients of --inventory must present:
+// GT_SERVE_TOKEN, then the contents of --token-file, else a fresh random
+// one; generated reports the last, which gt serve prints.
+func serveToken() (token string, generated bool, err error) {
+	if t := os.Getenv("GT_SERVE_TOKEN"); t != "" {
+		return t, false, nil
+	}
+	if serveTokenFile != "" {
+		data, err := os.ReadFile(serveTokenFile)
+		if err != nil {
+			return "", false, err
+		}
+		if t := strings.TrimSpace(string(data)); t != "" {
+			return t, false, nil
+		}
+		return "", false, fmt.Errorf("%s is empty", serveTokenFile)
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, err
+	}
+	return hex.EncodeToString(b), true, nil
+}
+
+// inventoryServer serves the latest inventory to bearers of its token.
+type inventoryServer struct {
+	token string
+
+	mu   sync.Mutex
+	data []byte
+}
+
+func (s *inventoryServer) set(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = data
+}
+
+func (s *inventoryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gt inventory"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	data := s.data
+	s.mu.Unlock()
+	if data == nil {
+		http.Error(w, "inventory not built yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
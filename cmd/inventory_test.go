@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRedact(t *testing.T) {
+	redact, err := validateRedact([]string{"vars", "user"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"vars": true, "user": true}, redact)
+	_, err = validateRedact([]string{"hostname"})
+	assert.ErrorContains(t, err, `unknown --redact field "hostname"`)
+}
+
+func TestBuildInventory(t *testing.T) {
+	useMockExec(t)
+	useWebGroup(t)
+	gtCfg.Hosts["web-1"] = hostMeta{Groups: []string{"web"}, Description: "Front", Vars: map[string]string{"app": "/srv"}, Shell: "fish"}
+	t.Cleanup(func() { teamMeta = nil })
+	teamMeta = map[string]hostMeta{"web-2": {}}
+
+	data, err := buildInventory([]string{"web-1", "web-2"}, nil)
+	require.NoError(t, err)
+	inv, err := parseTeamInventory(data)
+	require.NoError(t, err, "what gt serves, gt team refresh reads")
+	require.Len(t, inv.Hosts, 1, "a team host is not passed on")
+	h := inv.Hosts["web-1"]
+	assert.Equal(t, "test.example.com", h.HostName)
+	assert.Equal(t, "", h.User, "the default user is each reader's own")
+	assert.Equal(t, "2222", h.Port)
+	assert.Equal(t, []string{"web"}, h.Groups)
+	assert.Equal(t, "Front", h.Description)
+	assert.Equal(t, map[string]string{"app": "/srv"}, h.Vars)
+	assert.NotContains(t, string(data), "shell", "session preferences are not served")
+
+	data, err = buildInventory([]string{"web-1"}, map[string]bool{"port": true, "vars": true})
+	require.NoError(t, err)
+	inv, _ = parseTeamInventory(data)
+	assert.Empty(t, inv.Hosts["web-1"].Port)
+	assert.Empty(t, inv.Hosts["web-1"].Vars)
+	assert.Equal(t, "Front", inv.Hosts["web-1"].Description)
+}
+
+func TestInventoryServer(t *testing.T) {
+	s := &inventoryServer{token: "sekrit"}
+	get := func(method, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/inventory", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, get(http.MethodGet, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(http.MethodGet, "Bearer wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(http.MethodGet, "sekrit").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get(http.MethodGet, "Bearer sekrit").Code)
+
+	s.set([]byte("hosts: {}\n"))
+	rec := get(http.MethodGet, "Bearer sekrit")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hosts: {}\n", rec.Body.String())
+	assert.Equal(t, http.StatusMethodNotAllowed, get(http.MethodPost, "Bearer sekrit").Code)
+}
+
+func TestServeToken(t *testing.T) {
+	origFile := serveTokenFile
+	t.Cleanup(func() { serveTokenFile = origFile })
+	serveTokenFile = ""
+	t.Setenv("GT_SERVE_TOKEN", "")
+	a, generated, err := serveToken()
+	require.NoError(t, err)
+	assert.True(t, generated)
+	b, _, _ := serveToken()
+	assert.NotEqual(t, a, b)
+
+	t.Setenv("GT_SERVE_TOKEN", "from-env")
+	tok, generated, _ := serveToken()
+	assert.Equal(t, "from-env", tok)
+	assert.False(t, generated)
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"gt/pkg/sshconf"
+)
+
+// keychainService names gt's entries in the system keychain; each is
+// keyed by the absolute path of the private key it unlocks.
+const keychainService = "gt"
+
+// keychainArgv is the command that looks up (action "lookup"), stores
+// ("store") or removes ("remove") the passphrase of key in the system
+// keychain: security on macOS, and secret-tool, for GNOME Keyring, KWallet
+// and the rest of the Secret Service, elsewhere. Storing prompts for the
+// passphrase on the terminal, so it never is on a command line.
+func keychainArgv(action, key string) ([]string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return nil, errors.New("gt cannot answer ssh's passphrase prompts on Windows; the OpenSSH agent service keeps keys unlocked there")
+	case "darwin":
+		switch action {
+		case "lookup":
+			return []string{"security", "find-generic-password", "-s", keychainService, "-a", key, "-w"}, nil
+		case "store":
+			return []string{"security", "add-generic-password", "-U", "-s", keychainService, "-a", key, "-l", "gt: " + key, "-w"}, nil
+		case "remove":
+			return []string{"security", "delete-generic-password", "-s", keychainService, "-a", key}, nil
+		}
+	default:
+		attrs := []string{"application", keychainService, "ssh-key", key}
+		switch action {
+		case "lookup":
+			return append([]string{"secret-tool", "lookup"}, attrs...), nil
+		case "store":
+			return append([]string{"secret-tool", "store", "--label=gt: " + key}, attrs...), nil
+		case "remove":
+			return append([]string{"secret-tool", "clear"}, attrs...), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown keychain action %q", action)
+}
+
+// keychainKeyPath is the form keys take in keychain_keys and the
+// keychain: absolute, tilde expanded, as ssh names them in its prompt.
+func keychainKeyPath(key string) (string, error) {
+	return filepath.Abs(sshconf.ExpandTilde(key))
+}
+
+// keychainKeys are the keys of keychain_keys, in keychainKeyPath form.
+func keychainKeys() []string {
+	var keys []string
+	for _, k := range gtCfg.KeychainKeys {
+		if p, err := keychainKeyPath(k); err == nil {
+			keys = append(keys, p)
+		}
+	}
+	return keys
+}
+
+var passphrasePromptRe = regexp.MustCompile(`passphrase for key '([^']+)'`)
+
+// passphraseKey is the key a passphrase prompt of ssh's is for.
+func passphraseKey(prompt string) (string, bool) {
+	m := passphrasePromptRe.FindStringSubmatch(prompt)
+	if m == nil {
+		return "", false
+	}
+	return filepath.Clean(m[1]), true
+}
+
+// keychainPassphrase looks up the passphrase of key in the system
+// keychain, for the askpass helper. It answers once per ssh process and
+// key: ssh asking again means the stored passphrase was wrong, and the
+// question goes to the user. marks is where the helper remembers asking.
+func keychainPassphrase(key, marks string) (string, error) {
+	sum := sha256.Sum256([]byte(key))
+	mark := filepath.Join(marks, "keychain-"+strconv.Itoa(os.Getppid())+"-"+hex.EncodeToString(sum[:8]))
+	f, err := os.OpenFile(mark, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("the passphrase the keychain has for %s did not work", key)
+		}
+		return "", err
+	}
+	f.Close()
+	argv, err := keychainArgv("lookup", key)
+	if err != nil {
+		return "", err
+	}
+	out, err := execCommand(argv[0], argv[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("the keychain has no passphrase for %s: %w", key, err)
+	}
+	passphrase := strings.TrimSuffix(string(out), "\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("the keychain has no passphrase for %s", key)
+	}
+	return passphrase, nil
+}
+
+// setKeychainKey adds key to keychain_keys in gt's config, or with add
+// false removes it, reporting whether that changed anything.
+func setKeychainKey(key string, add bool) (changed bool, err error) {
+	err = editGTConfig(func(root *yaml.Node) (bool, error) {
+		list := mappingValue(root, "keychain_keys")
+		if list == nil || list.Kind != yaml.SequenceNode {
+			list = &yaml.Node{Kind: yaml.SequenceNode}
+		}
+		var kept []*yaml.Node
+		for _, n := range list.Content {
+			if p, err := keychainKeyPath(n.Value); err == nil && p == key {
+				changed = !add
+				if add {
+					return false, nil
+				}
+				continue
+			}
+			kept = append(kept, n)
+		}
+		if add {
+			kept = append(kept, &yaml.Node{Kind: yaml.ScalarNode, Value: key})
+			changed = true
+		}
+		if !changed {
+			return false, nil
+		}
+		if len(kept) == 0 {
+			deleteMappingKey(root, "keychain_keys")
+			return true, nil
+		}
+		list.Content = kept
+		setMappingValue(root, "keychain_keys", list)
+		return true, nil
+	})
+	return changed, err
+}
+
+var keysKeychainCmd = &cobra.Command{
+	Use:   "keychain",
+	Short: "Keep key passphrases in the system keychain",
+	Long: `Keep the passphrases of private keys in the system keychain, for gt to
+answer ssh with when it asks for one: when no agent runs, or the agent
+does not have the key. Only the keys you add are looked up.
+
+On macOS gt uses the login keychain through security; elsewhere, the
+Secret Service (GNOME Keyring, KWallet) through secret-tool. Not on
+Windows, where the OpenSSH agent service keeps keys unlocked.`,
+}
+
+var keysKeychainAddCmd = &cobra.Command{
+	Use:     "add <key>",
+	Short:   "Store a key's passphrase in the keychain and use it",
+	Example: `  gt keys keychain add ~/.ssh/id_ed25519`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := keychainKeyPath(args[0])
+		if err != nil {
+			return err
+		}
+		if !isPrivateKey(key) {
+			return fmt.Errorf("%s is not a private key", key)
+		}
+		argv, err := keychainArgv("store", key)
+		if err != nil {
+			return err
+		}
+		if nonInteractive() {
+			return errors.New("gt keys keychain add asks for the passphrase; it cannot run with --no-input")
+		}
+		cmd.SilenceUsage = true
+		fmt.Fprintf(os.Stderr, "Enter the passphrase of %s when asked.\n", key)
+		if err := runCommand(execCommand(argv[0], argv[1:]...)); err != nil {
+			return fmt.Errorf("could not store the passphrase: %w", err)
+		}
+		if _, err := setKeychainKey(key, true); err != nil {
+			return fmt.Errorf("the passphrase is stored, but %s is not in keychain_keys: %w", key, err)
+		}
+		statusf(symbolColor, "Stored the passphrase of %s; gt answers ssh with it\n", key)
+		return nil
+	},
+}
+
+var keysKeychainRmCmd = &cobra.Command{
+	Use:   "rm <key>",
+	Short: "Remove a key's passphrase from the keychain",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := keychainKeyPath(args[0])
+		if err != nil {
+			return err
+		}
+		argv, err := keychainArgv("remove", key)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		changed, err := setKeychainKey(key, false)
+		if err != nil {
+			return err
+		}
+		c := execCommand(argv[0], argv[1:]...)
+		debugf(3, "exec: %s", quoteArgv(c.Args))
+		if out, err := c.CombinedOutput(); err != nil {
+			if !changed {
+				return fmt.Errorf("%s has no passphrase in the keychain", key)
+			}
+			debugf(1, "%s: %v: %s", argv[0], err, strings.TrimSpace(string(out)))
+		}
+		statusf(symbolColor, "Removed the passphrase of %s\n", key)
+		return nil
+	},
+}
+
+var keysKeychainListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the keys whose passphrase gt looks up",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, key := range keychainKeys() {
+			fmt.Fprintln(cmd.OutOrStdout(), key)
+		}
+		return nil
+	},
+}
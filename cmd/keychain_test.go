@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeychainArgv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		_, err := keychainArgv("lookup", `C:\key`)
+		assert.ErrorContains(t, err, "on Windows")
+		return
+	}
+	argv, err := keychainArgv("lookup", "/home/me/.ssh/id_ed25519")
+	require.NoError(t, err)
+	if runtime.GOOS == "darwin" {
+		assert.Equal(t, []string{"security", "find-generic-password", "-s", "gt", "-a", "/home/me/.ssh/id_ed25519", "-w"}, argv)
+	} else {
+		assert.Equal(t, []string{"secret-tool", "lookup", "application", "gt", "ssh-key", "/home/me/.ssh/id_ed25519"}, argv)
+	}
+	store, err := keychainArgv("store", "/home/me/.ssh/id_ed25519")
+	require.NoError(t, err)
+	assert.NotContains(t, store, "s3cret", "the passphrase is asked for, not passed")
+	_, err = keychainArgv("copy", "/k")
+	assert.ErrorContains(t, err, "unknown keychain action")
+}
+
+func TestPassphraseKey(t *testing.T) {
+	key, ok := passphraseKey("Enter passphrase for key '/home/me/.ssh/id_ed25519': ")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Clean("/home/me/.ssh/id_ed25519"), key)
+	_, ok = passphraseKey("me@web's password: ")
+	assert.False(t, ok)
+
+	list := "/a/id_rsa" + string(os.PathListSeparator) + "/a/id_ed25519"
+	assert.True(t, listedKey(list, "/a/id_ed25519"))
+	assert.False(t, listedKey(list, "/a/id_ecdsa"))
+	assert.False(t, listedKey("", "/a/id_ed25519"))
+}
+
+func TestKeychainPassphrase(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no keychain support on Windows")
+	}
+	useMockExec(t)
+	marks := t.TempDir()
+
+	pp, err := keychainPassphrase("/home/me/.ssh/id_locked", marks)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", pp)
+
+	_, err = keychainPassphrase("/home/me/.ssh/id_locked", marks)
+	assert.ErrorContains(t, err, "did not work", "ssh asking again goes to the user")
+
+	_, err = keychainPassphrase("/home/me/.ssh/id_other", marks)
+	assert.ErrorContains(t, err, "has no passphrase for /home/me/.ssh/id_other")
+}
+
+func TestSetKeychainKey(t *testing.T) {
+	useWebGroup(t)
+	gtPath := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("GT_CONFIG", gtPath)
+	writeConfigFile(t, gtPath, "# mine\ntheme:\n  name: mono\n")
+	key, err := keychainKeyPath("~/.ssh/id_ed25519")
+	require.NoError(t, err)
+
+	changed, err := setKeychainKey(key, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = setKeychainKey(key, true)
+	require.NoError(t, err)
+	assert.False(t, changed, "listed once")
+	data, _ := os.ReadFile(gtPath)
+	assert.Equal(t, "# mine\ntheme:\n  name: mono\nkeychain_keys:\n  - "+key+"\n", string(data))
+	assert.Equal(t, []string{key}, keychainKeys(), "in effect at once")
+
+	changed, err = setKeychainKey(key, false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	data, _ = os.ReadFile(gtPath)
+	assert.Equal(t, "# mine\ntheme:\n  name: mono\n", string(data))
+	assert.Empty(t, keychainKeys())
+}
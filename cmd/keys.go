@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var fixPermsCheck bool
+
+// permFix is a file whose mode gt keys fix-perms tightens, from the mode
+// it has to the one it should.
+type permFix struct {
+	path     string
+	kind     string
+	from, to fs.FileMode
+}
+
+// isPrivateKey reports whether the file at path starts like a private
+// key: PEM, OpenSSH's own format, or a PuTTY .ppk.
+func isPrivateKey(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 64)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	if bytes.HasPrefix(head, []byte("PuTTY-User-Key-File-")) {
+		return true
+	}
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	return bytes.HasPrefix(line, []byte("-----BEGIN ")) && bytes.Contains(line, []byte("PRIVATE KEY-----"))
+}
+
+// sshFileKind names what a file under ~/.ssh is and the loosest mode it
+// may have: what ssh refuses to use otherwise, private keys and the
+// config, at 0600, and what only ever needs reading by others at 0644.
+// A file gt does not recognise gets "", and is left alone.
+func sshFileKind(path string, configs map[string]bool) (string, fs.FileMode) {
+	name := filepath.Base(path)
+	switch {
+	case configs[path] || name == "config":
+		return "config", 0o600
+	case strings.HasSuffix(name, ".pub"):
+		return "public key", 0o644
+	case strings.HasPrefix(name, "known_hosts"):
+		return "known hosts", 0o644
+	case name == "authorized_keys" || name == "authorized_keys2":
+		return "authorized keys", 0o600
+	case isPrivateKey(path):
+		return "private key", 0o600
+	}
+	return "", 0
+}
+
+// planPermFixes walks sshDir and the config files beyond it for modes
+// looser than they should be; no mode is ever loosened. Symlinks are
+// not followed, and files other than regular ones and directories
+// (ControlPath sockets) are skipped. notOwned lists what the running
+// user does not own, which chmod cannot fix.
+func planPermFixes(sshDir string, configFiles []string) (fixes []permFix, notOwned []string, err error) {
+	configs := map[string]bool{}
+	for _, f := range configFiles {
+		configs[f] = true
+	}
+	uid := uint32(os.Getuid())
+	check := func(path string, info fs.FileInfo, kind string, max fs.FileMode) {
+		if owner, ok := sshconf.FileOwner(info); ok && owner != uid {
+			notOwned = append(notOwned, path)
+			return
+		}
+		if mode := info.Mode().Perm(); mode&^max != 0 {
+			fixes = append(fixes, permFix{path: path, kind: kind, from: mode, to: mode & max})
+		}
+	}
+	err = filepath.WalkDir(sshDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == sshDir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case path == sshDir:
+			check(path, info, "ssh directory", 0o700)
+		case d.IsDir():
+			check(path, info, "directory", 0o755)
+		case d.Type().IsRegular():
+			if kind, max := sshFileKind(path, configs); kind != "" {
+				check(path, info, kind, max)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range configFiles {
+		if rel, err := filepath.Rel(sshDir, f); err == nil && !strings.HasPrefix(rel, "..") {
+			continue // walked already
+		}
+		info, err := os.Lstat(f)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if owner, ok := sshconf.FileOwner(info); ok && owner != uid {
+			continue // a system-wide file, someone else's to look after
+		}
+		check(f, info, "config", 0o600)
+	}
+	return fixes, notOwned, nil
+}
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Look after SSH keys and the files around them",
+}
+
+var keysFixPermsCmd = &cobra.Command{
+	Use:   "fix-perms",
+	Short: "Tighten the permissions of ~/.ssh and the files in it",
+	Long: `Correct the permissions ssh is strict about, the cause of "UNPROTECTED
+PRIVATE KEY FILE" and "Bad owner or permissions" failures: ~/.ssh to 0700,
+the SSH config and its include files, private keys and authorized_keys to
+0600, and public keys and known_hosts to no more than 0644. Modes are only
+ever tightened, and files gt does not recognise are left alone.
+
+Each change is reported as it is made. --check reports what would change,
+changes nothing, and exits 1 if anything would. Files owned by another
+user are reported too; chmod cannot fix those, a sudo chown can.`,
+	Args: cobra.NoArgs,
+	// An SSH config gt refuses for its permissions is what fix-perms is
+	// for, so one that does not load is no reason to stop.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setup(cmd, args); err != nil {
+			debugf(1, "fixing permissions without the SSH config: %v", err)
+			cfg = &ssh_config.Config{}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if runtime.GOOS == "windows" {
+			return errors.New("gt keys fix-perms sets Unix permissions; on Windows, OpenSSH checks ACLs, which icacls sets")
+		}
+		cmd.SilenceUsage = true
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("could not find home directory: %w", err)
+		}
+		fixes, notOwned, err := planPermFixes(filepath.Join(home, ".ssh"), loadedFiles)
+		if err != nil {
+			return err
+		}
+		w := cmd.OutOrStdout()
+		changed := 0
+		for _, f := range fixes {
+			if !fixPermsCheck {
+				if err := os.Chmod(f.path, f.to); err != nil {
+					errorColor.Fprintf(os.Stderr, "%v\n", err)
+					continue
+				}
+			}
+			changed++
+			symbolColor.Fprintf(w, "%04o -> %04o  ", f.from, f.to)
+			fmt.Fprintf(w, "%s ", f.path)
+			userColor.Fprintf(w, "(%s)\n", f.kind)
+		}
+		for _, p := range notOwned {
+			warningColor.Fprintf(os.Stderr, "%s is not yours; chown it back with sudo\n", p)
+		}
+		switch {
+		case changed < len(fixes):
+			return errors.New("some permissions could not be fixed")
+		case len(notOwned) > 0:
+			return withCode(exitConfig, fmt.Errorf("%d file(s) owned by another user", len(notOwned)))
+		case fixPermsCheck && changed > 0:
+			return withCode(1, fmt.Errorf("%d file(s) need their permissions fixed", changed))
+		case changed == 0:
+			statusf(symbolColor, "Permissions are in order\n")
+		}
+		return nil
+	},
+}
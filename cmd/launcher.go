@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// listLauncher is gt list --launcher.
+var listLauncher string
+
+// launcherFormats are what --launcher writes for.
+var launcherFormats = []string{"alfred", "raycast", "rofi"}
+
+// launcherItem is a host as a launcher shows it.
+type launcherItem struct {
+	alias    string
+	subtitle string
+	// keywords are what else the launcher matches on: the hostname and
+	// the @groups.
+	keywords []string
+	// command connects to the host.
+	command string
+	valid   bool
+}
+
+// validateLauncher checks --launcher names a format gt writes.
+func validateLauncher() error {
+	for _, f := range launcherFormats {
+		if f == listLauncher {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --launcher %q (want %s)", listLauncher, strings.Join(launcherFormats, ", "))
+}
+
+// gtExecutable is the path of the running gt, since launchers run
+// commands with a PATH of their own that may lack it.
+func gtExecutable() string {
+	if exe, err := os.Executable(); err == nil {
+		return exe
+	}
+	return "gt"
+}
+
+// launcherItems turns listed hosts into launcher items. The subtitle is
+// the address as gt list shows it, --redact included, then the host's
+// description and groups.
+func launcherItems(rows []listRow) []launcherItem {
+	exe := gtExecutable()
+	items := make([]launcherItem, len(rows))
+	for i, r := range rows {
+		var addr strings.Builder
+		for _, sg := range addressSegments(r) {
+			addr.WriteString(sg.s)
+		}
+		parts := []string{addr.String()}
+		if r.err != nil {
+			parts = []string{firstLine(r.err.Error())}
+		}
+		m := hostMetaFor(r.alias)
+		if m.Description != "" {
+			parts = append(parts, m.Description)
+		}
+		var keywords, groups []string
+		if r.err == nil && !redactEnabled() {
+			keywords = append(keywords, r.Hostname)
+		}
+		for _, g := range m.Groups {
+			groups = append(groups, "@"+g)
+		}
+		if len(groups) > 0 {
+			parts = append(parts, strings.Join(groups, " "))
+		}
+		keywords = append(keywords, groups...)
+		if r.expired {
+			parts = append(parts, "(expired)")
+		}
+		items[i] = launcherItem{
+			alias:    r.alias,
+			subtitle: strings.Join(parts, " · "),
+			keywords: keywords,
+			command:  quoteArgv([]string{exe, r.alias}),
+			valid:    r.err == nil,
+		}
+	}
+	return items
+}
+
+// renderLauncher writes items as format expects them:
+//
+//   - alfred: a Script Filter's JSON, each item's arg the command that
+//     connects, for a Terminal Command action's {query};
+//   - raycast: a JSON array of List.Item props (id, title, subtitle,
+//     keywords) with the command, for a script-backed extension;
+//   - rofi: dmenu rows "alias<TAB>subtitle", with the hostname and groups
+//     as meta for matching; the alias is the part before the tab.
+func renderLauncher(w io.Writer, format string, items []launcherItem) error {
+	switch format {
+	case "alfred":
+		type alfredItem struct {
+			UID          string            `json:"uid"`
+			Title        string            `json:"title"`
+			Subtitle     string            `json:"subtitle"`
+			Arg          string            `json:"arg"`
+			Autocomplete string            `json:"autocomplete"`
+			Match        string            `json:"match"`
+			Valid        bool              `json:"valid"`
+			Variables    map[string]string `json:"variables"`
+		}
+		out := struct {
+			Items []alfredItem `json:"items"`
+		}{Items: []alfredItem{}}
+		for _, it := range items {
+			out.Items = append(out.Items, alfredItem{
+				UID: it.alias, Title: it.alias, Subtitle: it.subtitle, Arg: it.command,
+				Autocomplete: it.alias, Match: strings.Join(append([]string{it.alias}, it.keywords...), " "),
+				Valid: it.valid, Variables: map[string]string{"alias": it.alias},
+			})
+		}
+		return json.NewEncoder(w).Encode(out)
+	case "raycast":
+		type raycastItem struct {
+			ID       string   `json:"id"`
+			Title    string   `json:"title"`
+			Subtitle string   `json:"subtitle"`
+			Keywords []string `json:"keywords"`
+			Command  string   `json:"command"`
+		}
+		out := []raycastItem{}
+		for _, it := range items {
+			keywords := it.keywords
+			if keywords == nil {
+				keywords = []string{}
+			}
+			out = append(out, raycastItem{ID: it.alias, Title: it.alias, Subtitle: it.subtitle, Keywords: keywords, Command: it.command})
+		}
+		return json.NewEncoder(w).Encode(out)
+	case "rofi":
+		for _, it := range items {
+			line := it.alias + "\t" + it.subtitle
+			if len(it.keywords) > 0 {
+				line += "\x00meta\x1f" + strings.Join(it.keywords, " ")
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid --launcher %q (want %s)", format, strings.Join(launcherFormats, ", "))
+}
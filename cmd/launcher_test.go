@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/sshconf"
+)
+
+func launcherRows(t *testing.T) []listRow {
+	t.Helper()
+	useWebGroup(t)
+	gtCfg.Hosts["web-1"] = hostMeta{Groups: []string{"web", "prod"}, Description: "Frontend"}
+	return []listRow{
+		{alias: "web-1", Resolved: sshconf.Resolved{User: "deploy", Hostname: "web1.example.com", Port: "2222"}},
+		{alias: "down", err: errors.New("ssh -G down: exit status 255\nmore")},
+	}
+}
+
+func TestLauncherItems(t *testing.T) {
+	items := launcherItems(launcherRows(t))
+	exe := gtExecutable()
+	assert.Equal(t, []launcherItem{
+		{alias: "web-1", subtitle: "deploy@web1.example.com:2222 · Frontend · @web @prod",
+			keywords: []string{"web1.example.com", "@web", "@prod"}, command: quoteArgv([]string{exe, "web-1"}), valid: true},
+		{alias: "down", subtitle: "ssh -G down: exit status 255 · @web",
+			keywords: []string{"@web"}, command: quoteArgv([]string{exe, "down"})},
+	}, items)
+
+	listRedact = true
+	t.Cleanup(func() { listRedact = false })
+	items = launcherItems(launcherRows(t))
+	assert.Equal(t, "deploy@***:*** · Frontend · @web @prod", items[0].subtitle)
+	assert.Equal(t, []string{"@web", "@prod"}, items[0].keywords, "no hostname to match on either")
+}
+
+func TestRenderLauncher(t *testing.T) {
+	items := []launcherItem{{alias: "web-1", subtitle: "deploy@web1.example.com", keywords: []string{"web1.example.com", "@web"}, command: "/usr/local/bin/gt web-1", valid: true}}
+
+	var out bytes.Buffer
+	require.NoError(t, renderLauncher(&out, "alfred", items))
+	assert.JSONEq(t, `{"items": [{"uid": "web-1", "title": "web-1", "subtitle": "deploy@web1.example.com",
+		"arg": "/usr/local/bin/gt web-1", "autocomplete": "web-1", "match": "web-1 web1.example.com @web",
+		"valid": true, "variables": {"alias": "web-1"}}]}`, out.String())
+
+	out.Reset()
+	require.NoError(t, renderLauncher(&out, "raycast", items))
+	assert.JSONEq(t, `[{"id": "web-1", "title": "web-1", "subtitle": "deploy@web1.example.com",
+		"keywords": ["web1.example.com", "@web"], "command": "/usr/local/bin/gt web-1"}]`, out.String())
+
+	out.Reset()
+	require.NoError(t, renderLauncher(&out, "rofi", append(items, launcherItem{alias: "db", subtitle: "db.example.com"})))
+	assert.Equal(t, "web-1\tdeploy@web1.example.com\x00meta\x1fweb1.example.com @web\ndb\tdb.example.com\n", out.String())
+
+	out.Reset()
+	require.NoError(t, renderLauncher(&out, "alfred", nil))
+	var empty map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &empty))
+	assert.Equal(t, []any{}, empty["items"], "no hosts is an empty list, not null")
+}
+
+func TestListLauncherFlags(t *testing.T) {
+	useWebGroup(t)
+	t.Cleanup(func() { listLauncher, listLong = "", false })
+	listLauncher = "dmenu"
+	assert.EqualError(t, listCmd.RunE(listCmd, nil), `invalid --launcher "dmenu" (want alfred, raycast, rofi)`)
+
+	listLauncher, listLong = "rofi", true
+	assert.EqualError(t, listCmd.RunE(listCmd, nil), "--launcher takes neither --long nor --numbered")
+}
@@ -0,0 +1,605 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
+)
+
+var (
+	listNoTruncate bool
+	listLong       bool
+	listSort       = "alias"
+	listReverse    bool
+	listNumbered   bool
+)
+
+// listSorts are the orders --sort takes.
+var listSorts = []string{"alias", "hostname", "user", "port", "last-used"}
+
+type listRow struct {
+	alias string
+	sshconf.Resolved
+	// opts is everything else ssh -G resolved, for --long.
+	opts map[string][]string
+	err  error
+	// index is the row's number under --numbered, from 1; 0 is none.
+	index int
+	// expired is set for a host whose gt add --ttl has passed.
+	expired bool
+}
+
+// expiredColor greys out expired hosts, whatever the theme.
+var expiredColor = color.New(color.Faint)
+
+// resolveListRows queries ssh -G for every alias. Each query is a
+// subprocess, so run a handful at a time rather than either one ssh per
+// host all at once or a serial crawl through a large config.
+func resolveListRows(hosts []string) []listRow {
+	rows := make([]listRow, len(hosts))
+	sem := make(chan struct{}, 8)
+	var wg sync.WaitGroup
+	for i, alias := range hosts {
+		wg.Add(1)
+		go func(i int, alias string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			resolved, opts, err := resolveHostOptions(alias)
+			rows[i] = listRow{alias: alias, Resolved: resolved, opts: opts, err: err,
+				expired: hostMetaFor(alias).expired(time.Now())}
+		}(i, alias)
+	}
+	wg.Wait()
+	return rows
+}
+
+// sortListRows orders rows by --sort, which validateListSort has
+// checked. Ties keep the alias order rows come in, and hosts ssh -G
+// could not resolve go last either way. last-used puts the latest
+// connection first and hosts never connected to at the end; --reverse
+// flips any order.
+func sortListRows(rows []listRow, by string, reverse bool, last map[string]time.Time) {
+	less := func(a, b listRow) bool {
+		switch by {
+		case "hostname":
+			return hostnameLess(a.Hostname, b.Hostname)
+		case "user":
+			return a.User < b.User
+		case "port":
+			return portNumber(a.Port) < portNumber(b.Port)
+		case "last-used":
+			return last[a.alias].After(last[b.alias])
+		}
+		return a.alias < b.alias
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if (a.err != nil) != (b.err != nil) {
+			return b.err != nil
+		}
+		if reverse {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
+
+func validateListSort() error {
+	for _, s := range listSorts {
+		if listSort == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown --sort %q: want one of %s", listSort, strings.Join(listSorts, ", "))
+}
+
+// hostnameLess orders hostnames by domain, comparing labels from the
+// right, so db.example.com and web.example.com sit together ahead of
+// example.org. IP addresses come first, in numeric order.
+func hostnameLess(a, b string) bool {
+	ipA, ipB := transport.HostIP(a), transport.HostIP(b)
+	if ipA != nil || ipB != nil {
+		if ipA == nil || ipB == nil {
+			return ipB == nil
+		}
+		return bytes.Compare(ipA.To16(), ipB.To16()) < 0
+	}
+	la := strings.Split(strings.ToLower(a), ".")
+	lb := strings.Split(strings.ToLower(b), ".")
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if la[i] != lb[j] {
+			return la[i] < lb[j]
+		}
+	}
+	return len(la) < len(lb)
+}
+
+// portNumber is a resolved port as a number; unset is ssh's 22.
+func portNumber(p string) int {
+	if n, err := strconv.Atoi(p); err == nil {
+		return n
+	}
+	return 22
+}
+
+// segment is a run of text in one color, or none for a nil c. Lines are
+// built as segments so they can be measured and truncated before
+// anything is printed.
+type segment struct {
+	c *color.Color
+	s string
+}
+
+func (sg segment) print(w io.Writer, s string) {
+	if sg.c == nil {
+		fmt.Fprint(w, s)
+		return
+	}
+	sg.c.Fprint(w, s)
+}
+
+// addressSegments renders user@host.subdomain.domain:port with each part
+// in its role color.
+func addressSegments(r listRow) []segment {
+	if r.err != nil {
+		return []segment{{warningColor, "(could not resolve)"}}
+	}
+	segs := []segment{{userColor, r.User}, {symbolColor, "@"}}
+	nonDefaultPort := r.Port != "" && r.Port != "22"
+
+	if redactEnabled() {
+		segs = append(segs, segment{subdomainColor, redactMask})
+		if nonDefaultPort {
+			segs = append(segs, segment{symbolColor, ":"}, segment{portColor, redactMask})
+		}
+		return segs
+	}
+
+	// An IP literal has no domain structure to highlight; IPv6 gets
+	// brackets when a port follows, or the colons would run together.
+	if transport.HostIP(r.Hostname) != nil {
+		host := r.Hostname
+		if nonDefaultPort {
+			host = transport.BracketHost(host)
+		}
+		segs = append(segs, segment{domainColor, host})
+		if nonDefaultPort {
+			segs = append(segs, segment{symbolColor, ":"}, segment{portColor, r.Port})
+		}
+		return segs
+	}
+
+	// Split hostname into parts and color each differently
+	parts := strings.Split(r.Hostname, ".")
+	for i, part := range parts {
+		if i > 0 {
+			segs = append(segs, segment{symbolColor, "."})
+		}
+		if i == len(parts)-1 {
+			// Last part is the top-level domain
+			segs = append(segs, segment{domainColor, part})
+		} else if i == len(parts)-2 && len(parts) > 2 {
+			// Second to last is usually the domain name
+			segs = append(segs, segment{domainColor, part})
+		} else {
+			// Earlier parts are subdomains
+			segs = append(segs, segment{subdomainColor, part})
+		}
+	}
+
+	// Add port if specified and not default
+	if nonDefaultPort {
+		segs = append(segs, segment{symbolColor, ":"}, segment{portColor, r.Port})
+	}
+	return segs
+}
+
+// rowSegments is the address part of a row, greyed out with a note when
+// the host has expired.
+func rowSegments(r listRow) []segment {
+	segs := addressSegments(r)
+	if !r.expired {
+		return segs
+	}
+	for i := range segs {
+		segs[i].c = expiredColor
+	}
+	return append(segs, segment{expiredColor, " (expired)"})
+}
+
+// rowAliasColor is aliasColor, or expiredColor for an expired host.
+func rowAliasColor(r listRow) *color.Color {
+	if r.expired {
+		return expiredColor
+	}
+	return aliasColor
+}
+
+// writeSegments prints segments within width display columns, cutting
+// the last visible one short and ending in "…" when they do not fit.
+// width <= 0 means unlimited.
+func writeSegments(w io.Writer, segs []segment, width int) {
+	total := 0
+	for _, sg := range segs {
+		total += displayWidth(sg.s)
+	}
+	if width <= 0 || total <= width {
+		for _, sg := range segs {
+			sg.print(w, sg.s)
+		}
+		return
+	}
+	room := width - 1 // keep a column for the ellipsis
+	for _, sg := range segs {
+		if room <= 0 {
+			break
+		}
+		text := truncateWidth(sg.s, room)
+		sg.print(w, text)
+		room -= displayWidth(text)
+		if text != sg.s {
+			break
+		}
+	}
+	symbolColor.Fprint(w, "…")
+}
+
+// minAliasColumn keeps the alias column readable on narrow terminals even
+// when that means cutting the address short.
+const minAliasColumn = 12
+
+// renderList writes the list table. Column widths come from the data,
+// measured in display columns so wide characters line up. With a width
+// limit (the terminal's), the alias column is capped at half of it and
+// both columns are truncated with an ellipsis rather than wrapping.
+func renderList(w io.Writer, rows []listRow, width int) {
+	numWidth := numberColumn(rows)
+	if width > 0 {
+		width -= numWidth
+	}
+	aliasWidth := 0
+	for _, r := range rows {
+		if n := displayWidth(r.alias); n > aliasWidth {
+			aliasWidth = n
+		}
+	}
+	aliasWidth++ // single-space gutter after the longest alias
+	if width > 0 {
+		if limit := width / 2; aliasWidth > limit {
+			aliasWidth = limit
+			if aliasWidth < minAliasColumn {
+				aliasWidth = minAliasColumn
+			}
+		}
+	}
+
+	for _, r := range rows {
+		// Format: alias    user@host.subdomain.domain:port
+		writeNumber(w, r, numWidth)
+		alias := r.alias
+		if displayWidth(alias) >= aliasWidth && width > 0 {
+			alias = truncateWidth(alias, aliasWidth-2) + "…"
+		}
+		rowAliasColor(r).Fprint(w, alias)
+		fmt.Fprint(w, strings.Repeat(" ", aliasWidth-displayWidth(alias)))
+
+		addrWidth := 0
+		if width > 0 {
+			addrWidth = width - aliasWidth
+		}
+		writeSegments(w, rowSegments(r), addrWidth)
+		fmt.Fprintln(w)
+	}
+}
+
+// longField is one labeled line under a host in gt list --long.
+type longField struct {
+	label string
+	segs  []segment
+}
+
+// longFields are the settings that decide how a connection to r goes,
+// and what gt knows of the host. Unset jump hosts, groups and
+// descriptions are left out; the rest always show, since "no" there is
+// worth knowing too.
+func longFields(r listRow, last time.Time) []longField {
+	var fields []longField
+	if jump := r.longOption("proxyjump", r.ProxyJump); jump != "" && jump != "none" {
+		if redactEnabled() {
+			jump = redactMask
+		}
+		fields = append(fields, longField{"proxy jump", []segment{{domainColor, jump}}})
+	}
+	keys := r.IdentityFiles
+	if len(keys) == 0 {
+		keys = []string{"(ssh's defaults)"}
+	}
+	fields = append(fields,
+		longField{"identity files", []segment{{nil, strings.Join(keys, " ")}}},
+		longField{"forward agent", []segment{{nil, r.longOption("forwardagent", "no")}}},
+		longField{"control master", []segment{{nil, r.longOption("controlmaster", "no")}}})
+
+	meta := hostMetaFor(r.alias)
+	if len(meta.Groups) > 0 {
+		var segs []segment
+		for i, g := range meta.Groups {
+			if i > 0 {
+				segs = append(segs, segment{nil, " "})
+			}
+			segs = append(segs, segment{symbolColor, "@"}, segment{aliasColor, g})
+		}
+		fields = append(fields, longField{"groups", segs})
+	}
+	if meta.Description != "" {
+		fields = append(fields, longField{"description", []segment{{nil, meta.Description}}})
+	}
+	if meta.Trust != "" {
+		var c *color.Color
+		if meta.Trust == trustUntrusted {
+			c = warningColor
+		}
+		fields = append(fields, longField{"trust", []segment{{c, meta.Trust}}})
+	}
+	if t, err := time.Parse(time.RFC3339, meta.Expires); err == nil {
+		fields = append(fields, longField{"expires", []segment{{nil, t.Local().Format("2006-01-02 15:04")}}})
+	}
+	connected := "never"
+	if !last.IsZero() {
+		connected = last.Local().Format("2006-01-02 15:04:05")
+	}
+	return append(fields, longField{"last connected", []segment{{nil, connected}}})
+}
+
+// longOption is the first value ssh -G gave key, or def. ssh -G prints
+// "false" and "true" for some yes/no options, e.g. ControlMaster; they
+// read as the config's no and yes.
+func (r listRow) longOption(key, def string) string {
+	v := def
+	if vals := r.opts[key]; len(vals) > 0 && vals[0] != "" {
+		v = vals[0]
+	}
+	switch v {
+	case "false":
+		return "no"
+	case "true":
+		return "yes"
+	}
+	return v
+}
+
+// renderLongList writes gt list --long: each host's line as in the
+// table, then its details indented under it, a blank line between hosts.
+// width truncates as in renderList.
+func renderLongList(w io.Writer, rows []listRow, last map[string]time.Time, width int) {
+	labelWidth := 0
+	for _, r := range rows {
+		for _, f := range longFields(r, last[r.alias]) {
+			if n := len(f.label); n > labelWidth {
+				labelWidth = n
+			}
+		}
+	}
+	numWidth := numberColumn(rows)
+	for i, r := range rows {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		segs := append([]segment{{rowAliasColor(r), r.alias}, {nil, " "}}, rowSegments(r)...)
+		writeNumber(w, r, numWidth)
+		if width > 0 {
+			writeSegments(w, segs, width-numWidth)
+		} else {
+			writeSegments(w, segs, 0)
+		}
+		fmt.Fprintln(w)
+		if r.err != nil {
+			continue
+		}
+		for _, f := range longFields(r, last[r.alias]) {
+			label := fmt.Sprintf("  %-*s  ", labelWidth, f.label)
+			writeSegments(w, append([]segment{{symbolColor, label}}, f.segs...), width)
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// numberColumn is the width of the --numbered column, the largest index
+// and a space, or 0 when rows are not numbered.
+func numberColumn(rows []listRow) int {
+	most := 0
+	for _, r := range rows {
+		if r.index > most {
+			most = r.index
+		}
+	}
+	if most == 0 {
+		return 0
+	}
+	return len(strconv.Itoa(most)) + 1
+}
+
+func writeNumber(w io.Writer, r listRow, width int) {
+	if width > 0 {
+		symbolColor.Fprintf(w, "%*d ", width-1, r.index)
+	}
+}
+
+// numberedListing is what gt list --numbered last printed, so gt 3 means
+// the host it showed as 3 whatever the sort was. It stays until the next
+// numbered listing: a host added in between does not shift the rest.
+type numberedListing struct {
+	Hosts []string `json:"hosts"`
+}
+
+func numberedPath() (string, error) {
+	return statePath("numbered.json")
+}
+
+// saveNumbered numbers rows in their order and records the numbering.
+func saveNumbered(rows []listRow) error {
+	var l numberedListing
+	for i := range rows {
+		rows[i].index = i + 1
+		l.Hosts = append(l.Hosts, rows[i].alias)
+	}
+	p, err := numberedPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return writeStateFile(p, data)
+}
+
+// numberedHost is the alias arg stands for when it is an index from gt
+// list --numbered; ok is false for anything but a number. Without a
+// numbered listing yet, numbers follow the default one, by alias.
+func numberedHost(arg string) (alias string, ok bool, err error) {
+	if arg == "" || strings.Trim(arg, "0123456789") != "" {
+		return "", false, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n == 0 {
+		return "", false, nil
+	}
+	hosts, err := numberedHosts()
+	if err != nil {
+		return "", true, err
+	}
+	if n > len(hosts) {
+		return "", true, withCode(exitHostNotFound, fmt.Errorf("no host %d: gt list --numbered lists %d", n, len(hosts)))
+	}
+	alias = hosts[n-1]
+	if !knownHost(alias) {
+		return "", true, withCode(exitHostNotFound, fmt.Errorf("host %d was %s, which is no longer in the SSH config; run gt list --numbered again", n, alias))
+	}
+	return alias, true, nil
+}
+
+func numberedHosts() ([]string, error) {
+	p, err := numberedPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return getHosts(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var l numberedListing
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return l.Hosts, nil
+}
+
+// listWidth is the width limit for the table: the terminal's, unless
+// output is piped (where truncating would corrupt data for the next
+// program) or --no-truncate is set.
+func listWidth() int {
+	if listNoTruncate || !stdoutIsTerminal() {
+		return 0
+	}
+	return terminalWidth()
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all hosts from SSH config",
+	Long: `List all hosts defined in your SSH config file.
+Includes entries from included config files.
+Resolved values (user, hostname, port) come from ssh -G.
+With --redact (or GT_REDACT=1) hostnames and ports are masked, for
+screen-sharing; aliases and users stay visible.
+On a terminal, lines longer than its width are truncated with an
+ellipsis; --no-truncate prints them in full. A listing taller than the
+terminal goes through $PAGER (less by default); --no-pager disables it.
+
+--long (-l) adds, under each host, what decides how a connection goes:
+its ProxyJump, IdentityFiles, ForwardAgent and ControlMaster as ssh -G
+resolves them, then its groups and description from gt's config and when
+the audit log last saw a connection to it.
+
+Hosts are listed by alias. --sort hostname groups them by domain, --sort
+user and --sort port by those, and --sort last-used puts the most recent
+connection in the audit log first; --reverse flips the order.
+
+--numbered (-n) puts a number before each host, and gt <number> then
+connects to the host shown as that number, as gt 3, in the order of that
+listing, until the next --numbered. A Host named 3 still wins.
+
+A host added with gt add --ttl whose expiry has passed is greyed out and
+marked (expired) until gt config prune removes it.
+
+--launcher alfred, raycast or rofi prints the hosts for that launcher's
+host picker instead, each with its address, description and groups and
+the command that connects to it: an Alfred Script Filter's JSON, a JSON
+array of Raycast List.Item props, or rofi -dmenu rows.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hosts := getHosts()
+		if listLauncher != "" {
+			if listLong || listNumbered {
+				return errors.New("--launcher takes neither --long nor --numbered")
+			}
+			if err := validateLauncher(); err != nil {
+				return err
+			}
+			if err := validateListSort(); err != nil {
+				return err
+			}
+			rows := resolveListRows(hosts)
+			sortListRows(rows, listSort, listReverse, lastConnections())
+			return renderLauncher(cmd.OutOrStdout(), listLauncher, launcherItems(rows))
+		}
+		if len(hosts) == 0 {
+			if missingConfig != "" {
+				warningColor.Printf("No SSH config at %s yet; run 'gt init' to create one\n", missingConfig)
+				return nil
+			}
+			warningColor.Println("No SSH hosts found")
+			return nil
+		}
+
+		if err := validateListSort(); err != nil {
+			return err
+		}
+		var last map[string]time.Time
+		if listLong || listSort == "last-used" {
+			last = lastConnections()
+		}
+		rows := resolveListRows(hosts)
+		sortListRows(rows, listSort, listReverse, last)
+		if listNumbered {
+			if err := saveNumbered(rows); err != nil {
+				warningColor.Fprintf(os.Stderr, "Could not save the numbering for gt <number>: %v\n", err)
+			}
+		}
+
+		var out bytes.Buffer
+		if listLong {
+			renderLongList(&out, rows, last, listWidth())
+		} else {
+			renderList(&out, rows, listWidth())
+		}
+		return pageOutput(out.Bytes())
+	},
+}
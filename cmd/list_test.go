@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+
+	"gt/pkg/sshconf"
+)
+
+// plainOutput disables color for one test so rendered text can be compared.
+func plainOutput(t *testing.T) {
+	t.Helper()
+	orig := color.NoColor
+	t.Cleanup(func() { color.NoColor = orig })
+	color.NoColor = true
+}
+
+func TestDisplayWidth(t *testing.T) {
+	assert.Equal(t, 5, displayWidth("alpha"))
+	assert.Equal(t, 4, displayWidth("東京"), "CJK is two columns per rune")
+	assert.Equal(t, 4, displayWidth("café"), "precomposed é is one column")
+	assert.Equal(t, 4, displayWidth("café"), "combining accent takes no column")
+}
+
+func TestTruncateWidth(t *testing.T) {
+	assert.Equal(t, "alp", truncateWidth("alpha", 3))
+	assert.Equal(t, "alpha", truncateWidth("alpha", 10))
+	assert.Equal(t, "東", truncateWidth("東京", 3), "never split a wide rune across the limit")
+	assert.Equal(t, "", truncateWidth("東京", 1))
+}
+
+func TestRenderListAlignsByDisplayWidth(t *testing.T) {
+	plainOutput(t)
+	rows := []listRow{
+		{alias: "東京", Resolved: sshconf.Resolved{User: "u", Hostname: "tokyo.example.com", Port: "22"}},
+		{alias: "web", Resolved: sshconf.Resolved{User: "u", Hostname: "web.example.com", Port: "2222"}},
+		{alias: "gone", err: errors.New("boom")},
+	}
+
+	var buf bytes.Buffer
+	renderList(&buf, rows, 0)
+	assert.Equal(t, strings.Join([]string{
+		"東京 u@tokyo.example.com",
+		"web  u@web.example.com:2222",
+		"gone (could not resolve)",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestRenderListTruncatesToWidth(t *testing.T) {
+	plainOutput(t)
+	rows := []listRow{
+		{alias: "a-very-long-alias-name-indeed", Resolved: sshconf.Resolved{User: "deploy", Hostname: "host.internal.example.com"}},
+		{alias: "db", Resolved: sshconf.Resolved{User: "pg", Hostname: "db.example.com"}},
+	}
+
+	var buf bytes.Buffer
+	renderList(&buf, rows, 30)
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	assert.Equal(t, []string{
+		"a-very-long-a… deploy@host.in…",
+		"db             pg@db.example.…",
+	}, lines)
+	for _, l := range lines {
+		assert.Equal(t, 30, displayWidth(l))
+	}
+}
+
+func TestListWidthHonorsNoTruncate(t *testing.T) {
+	orig := listNoTruncate
+	defer func() { listNoTruncate = orig }()
+	t.Setenv("COLUMNS", "80")
+
+	listNoTruncate = true
+	assert.Equal(t, 0, listWidth())
+	listNoTruncate = false
+	assert.Equal(t, 0, listWidth(), "stdout under go test is not a terminal")
+}
+
+func TestRenderListIPLiterals(t *testing.T) {
+	plainOutput(t)
+	rows := []listRow{
+		{alias: "v6", Resolved: sshconf.Resolved{User: "u", Hostname: "2001:db8::1", Port: "2222"}},
+		{alias: "v6d", Resolved: sshconf.Resolved{User: "u", Hostname: "2001:db8::2", Port: "22"}},
+		{alias: "v4", Resolved: sshconf.Resolved{User: "u", Hostname: "192.0.2.10", Port: "2222"}},
+	}
+	var buf bytes.Buffer
+	renderList(&buf, rows, 0)
+	assert.Equal(t, "v6  u@[2001:db8::1]:2222\nv6d u@2001:db8::2\nv4  u@192.0.2.10:2222\n", buf.String())
+}
+
+func TestRenderLongList(t *testing.T) {
+	plainOutput(t)
+	orig := gtCfg
+	defer func() { gtCfg = orig }()
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{
+		"web": {Groups: []string{"web", "prod"}, Description: "Frontend, behind the LB"},
+	}}
+	rows := []listRow{
+		{alias: "web", Resolved: sshconf.Resolved{User: "u", Hostname: "web.example.com", Port: "22",
+			IdentityFiles: []string{"~/.ssh/web", "~/.ssh/id_ed25519"}},
+			opts: map[string][]string{"proxyjump": {"bastion"}, "forwardagent": {"yes"}, "controlmaster": {"auto"}}},
+		{alias: "db", Resolved: sshconf.Resolved{User: "pg", Hostname: "db.example.com", Port: "22"},
+			opts: map[string][]string{"proxyjump": {"none"}, "controlmaster": {"false"}}},
+		{alias: "gone", err: errors.New("boom")},
+	}
+	when := time.Date(2026, 10, 12, 14, 3, 0, 0, time.Local)
+
+	var buf bytes.Buffer
+	renderLongList(&buf, rows, map[string]time.Time{"web": when}, 0)
+	assert.Equal(t, strings.Join([]string{
+		"web u@web.example.com",
+		"  proxy jump      bastion",
+		"  identity files  ~/.ssh/web ~/.ssh/id_ed25519",
+		"  forward agent   yes",
+		"  control master  auto",
+		"  groups          @web @prod",
+		"  description     Frontend, behind the LB",
+		"  last connected  2026-10-12 14:03:00",
+		"",
+		"db pg@db.example.com",
+		"  identity files  (ssh's defaults)",
+		"  forward agent   no",
+		"  control master  no",
+		"  last connected  never",
+		"",
+		"gone (could not resolve)",
+		"",
+	}, "\n"), buf.String())
+
+	t.Setenv("GT_REDACT", "1")
+	buf.Reset()
+	renderLongList(&buf, rows[:1], nil, 0)
+	assert.Contains(t, buf.String(), "  proxy jump      "+redactMask+"\n")
+	assert.NotContains(t, buf.String(), "bastion")
+}
+
+func TestLastConnections(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	assert.Empty(t, lastConnections(), "no log yet")
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	for _, e := range []auditEntry{
+		{Start: start.Add(time.Hour), Alias: "web"},
+		{Start: start, Alias: "web"},
+		{Start: start, Alias: "db"},
+	} {
+		assert.NoError(t, appendAuditEntry(e))
+	}
+	last := lastConnections()
+	assert.True(t, last["web"].Equal(start.Add(time.Hour)), "the latest, not the last line")
+	assert.True(t, last["db"].Equal(start))
+}
+
+func TestSortListRows(t *testing.T) {
+	rows := func() []listRow {
+		return []listRow{
+			{alias: "a", Resolved: sshconf.Resolved{User: "root", Hostname: "web.example.org", Port: "22"}},
+			{alias: "b", Resolved: sshconf.Resolved{User: "deploy", Hostname: "10.0.0.10", Port: "2222"}},
+			{alias: "c", err: errors.New("boom")},
+			{alias: "d", Resolved: sshconf.Resolved{User: "deploy", Hostname: "db.example.com", Port: ""}},
+			{alias: "e", Resolved: sshconf.Resolved{User: "admin", Hostname: "10.0.0.9", Port: "80"}},
+			{alias: "f", Resolved: sshconf.Resolved{User: "root", Hostname: "web.example.com", Port: "22"}},
+		}
+	}
+	aliases := func(rs []listRow) string {
+		var b strings.Builder
+		for _, r := range rs {
+			b.WriteString(r.alias)
+		}
+		return b.String()
+	}
+	now := time.Now()
+	last := map[string]time.Time{"f": now, "b": now.Add(-time.Hour)}
+
+	for _, tc := range []struct {
+		by      string
+		reverse bool
+		want    string
+	}{
+		{"alias", false, "abdefc"},
+		{"alias", true, "fedbac"},
+		{"hostname", false, "ebdfac"},
+		{"user", false, "ebdafc"},
+		{"port", false, "adfebc"},
+		{"last-used", false, "fbadec"},
+		{"last-used", true, "adebfc"},
+	} {
+		rs := rows()
+		sortListRows(rs, tc.by, tc.reverse, last)
+		assert.Equal(t, tc.want, aliases(rs), "%s reverse=%v", tc.by, tc.reverse)
+	}
+}
+
+func TestValidateListSort(t *testing.T) {
+	orig := listSort
+	defer func() { listSort = orig }()
+	listSort = "last-used"
+	assert.NoError(t, validateListSort())
+	listSort = "age"
+	assert.EqualError(t, validateListSort(), `unknown --sort "age": want one of alias, hostname, user, port, last-used`)
+}
+
+func TestNumberedHost(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useWebGroup(t)
+
+	alias, ok, err := numberedHost("1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "down", alias, "without a numbered listing, by alias")
+
+	rows := []listRow{{alias: "web-2"}, {alias: "gone"}, {alias: "web-1"}}
+	assert.NoError(t, saveNumbered(rows))
+	assert.Equal(t, 3, rows[2].index)
+	alias, _, err = numberedHost("01")
+	assert.NoError(t, err)
+	assert.Equal(t, "web-2", alias, "the listing's order")
+	_, _, err = numberedHost("2")
+	assert.ErrorContains(t, err, "host 2 was gone, which is no longer in the SSH config")
+	_, _, err = numberedHost("4")
+	assert.EqualError(t, err, "no host 4: gt list --numbered lists 3")
+	assert.Equal(t, exitHostNotFound, ExitCode(err))
+
+	for _, arg := range []string{"web", "0", "+1", "-1", "1e2"} {
+		_, ok, err := numberedHost(arg)
+		assert.False(t, ok, arg)
+		assert.NoError(t, err, arg)
+	}
+}
+
+func TestRenderListNumbered(t *testing.T) {
+	plainOutput(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	var rows []listRow
+	for i := 0; i < 10; i++ {
+		rows = append(rows, listRow{alias: string(rune('a' + i)), Resolved: sshconf.Resolved{User: "u", Hostname: "h"}})
+	}
+	assert.NoError(t, saveNumbered(rows))
+
+	var buf bytes.Buffer
+	renderList(&buf, rows, 0)
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, " 1 a u@h", lines[0])
+	assert.Equal(t, "10 j u@h", lines[9])
+
+	buf.Reset()
+	renderLongList(&buf, rows[:1], nil, 0)
+	assert.True(t, strings.HasPrefix(buf.String(), "1 a u@h\n"), buf.String())
+}
+
+func TestRenderListExpired(t *testing.T) {
+	plainOutput(t)
+	rows := []listRow{
+		{alias: "pr-42", Resolved: sshconf.Resolved{User: "u", Hostname: "10.0.4.17"}, expired: true},
+		{alias: "web", Resolved: sshconf.Resolved{User: "u", Hostname: "web"}},
+	}
+	var buf bytes.Buffer
+	renderList(&buf, rows, 0)
+	assert.Equal(t, "pr-42 u@10.0.4.17 (expired)\nweb   u@web\n", buf.String())
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	logsLines    int
+	logsNoFollow bool
+)
+
+// logsKeepalive makes ssh notice a dead connection within a minute, so a
+// follow reconnects instead of hanging on a silent socket.
+var logsKeepalive = []string{"-o", "ServerAliveInterval=15", "-o", "ServerAliveCountMax=4"}
+
+// logsSleep is time.Sleep, swappable in tests.
+var logsSleep = time.Sleep
+
+// logsMaxDelay caps the wait between reconnects.
+const logsMaxDelay = 30 * time.Second
+
+// logSource is what gt logs tails: a systemd unit through journalctl
+// (all of the journal when unit is empty), or a file.
+type logSource struct {
+	unit string
+	file string
+}
+
+// logSourceFor picks the source for name on alias: a file the host's
+// logs config names, otherwise a unit.
+func logSourceFor(alias, name string) (logSource, error) {
+	if path, ok := hostMetaFor(alias).Logs[name]; ok {
+		expanded, err := expandForHost(alias, []string{path})
+		if err != nil {
+			return logSource{}, err
+		}
+		return logSource{file: expanded[0]}, nil
+	}
+	return logSource{unit: name}, nil
+}
+
+// command is the remote command for the source. A reconnect passes the
+// time the previous session ended: the journal resumes from then, a
+// file from its current end, since tail cannot seek by time.
+func (s logSource) command(lines int, follow bool, resume time.Time) string {
+	var argv []string
+	if s.file != "" {
+		n := strconv.Itoa(lines)
+		if !resume.IsZero() {
+			n = "0"
+		}
+		argv = []string{"tail", "-n", n}
+		if follow {
+			argv = append(argv, "-F")
+		}
+		argv = append(argv, "--", s.file)
+		return quoteArgv(argv)
+	}
+	argv = []string{"journalctl", "--no-pager"}
+	if s.unit != "" {
+		argv = append(argv, "-u", s.unit)
+	}
+	if resume.IsZero() {
+		argv = append(argv, "-n", strconv.Itoa(lines))
+	} else {
+		argv = append(argv, "--since", fmt.Sprintf("@%d", resume.Unix()))
+	}
+	if follow {
+		argv = append(argv, "-f")
+	}
+	return quoteArgv(argv)
+}
+
+// Log levels as they commonly appear in a line, by severity.
+var (
+	logErrorLevel = regexp.MustCompile(`(?i)\b(emerg|alert|crit(ical)?|fatal|panic|err(or)?|fail(ed|ure)?)\b`)
+	logWarnLevel  = regexp.MustCompile(`(?i)\b(warn(ing)?)\b`)
+)
+
+// levelWriter passes log lines through, colored by the level they
+// mention: errors in the error color, warnings in the warning color.
+type levelWriter struct {
+	w   io.Writer
+	buf []byte
+	// seen is set once anything arrived.
+	seen bool
+}
+
+func (l *levelWriter) Write(b []byte) (int, error) {
+	l.seen = l.seen || len(b) > 0
+	l.buf = append(l.buf, b...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		l.writeLine(l.buf[:i+1])
+		l.buf = l.buf[i+1:]
+	}
+}
+
+// Flush writes a last line that did not end in a newline.
+func (l *levelWriter) Flush() {
+	if len(l.buf) > 0 {
+		l.writeLine(l.buf)
+		l.buf = nil
+	}
+}
+
+func (l *levelWriter) writeLine(line []byte) {
+	switch {
+	case logErrorLevel.Match(line):
+		errorColor.Fprint(l.w, string(line))
+	case logWarnLevel.Match(line):
+		warningColor.Fprint(l.w, string(line))
+	default:
+		l.w.Write(line)
+	}
+}
+
+// followLogs runs session until it ends for good. session reports
+// whether any log lines arrived. When following, a lost connection is
+// retried with growing delays, each new session resuming from when the
+// last live one dropped. A host that never answered, a refused login,
+// the remote command ending by itself, or Ctrl-C ends it.
+func followLogs(follow bool, session func(resume time.Time) (live bool, err error)) error {
+	var resume time.Time
+	delay := time.Second
+	for {
+		live, err := session(resume)
+		if err == nil || !follow || stopRequested() || ExitCode(err) != exitConnection {
+			return err
+		}
+		if live {
+			resume = time.Now()
+			delay = time.Second
+		} else if resume.IsZero() {
+			return err
+		}
+		warningColor.Fprintf(os.Stderr, "Connection lost; reconnecting in %s\n", delay)
+		logsSleep(delay)
+		if delay *= 2; delay > logsMaxDelay {
+			delay = logsMaxDelay
+		}
+	}
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <alias> [unit|name]",
+	Short: "Tail a host's service logs",
+	Long: `Follow a host's logs: a systemd unit's through journalctl, or the whole
+journal without a unit. A name listed under the host's logs in gt's
+config tails that file instead, for services that do not log to the
+journal:
+
+  gt logs web1 nginx          # journalctl -u nginx -n 50 -f
+  gt logs web1 app            # tail -F /srv/app/log/production.log
+
+Lines mentioning an error or warning level are colored. If the
+connection drops, gt reconnects with growing delays (up to 30s) and
+carries on where the journal left off; a file carries on from its end.
+Ctrl-C stops.`,
+	Args: cobra.RangeArgs(1, 2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeHosts(cmd, args, toComplete)
+		}
+		var names []string
+		for name := range hostMetaFor(args[0]).Logs {
+			names = append(names, name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		if logsLines < 0 {
+			return errors.New("--lines must not be negative")
+		}
+		name := ""
+		if len(args) == 2 {
+			name = args[1]
+		}
+		src, err := logSourceFor(alias, name)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		follow := !logsNoFollow
+		return followLogs(follow, func(resume time.Time) (bool, error) {
+			remoteCmd := src.command(logsLines, follow, resume)
+			out := &levelWriter{w: cmd.OutOrStdout()}
+			err := withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: []string{remoteCmd}}, func() error {
+				c, err := remoteCommand(alias, remoteOpts{sshOptions: logsKeepalive}, remoteCmd)
+				if err != nil {
+					return err
+				}
+				var tail tailBuffer
+				c.Stdout = out
+				c.Stderr = io.MultiWriter(os.Stderr, &tail)
+				debugf(1, "exec: %s", quoteArgv(c.Args))
+				start := time.Now()
+				err = runTracked(c, true)
+				out.Flush()
+				logConnection(alias, "logs", start, err)
+				return classifyRun(alias, "ssh", err, tail.String())
+			})
+			return out.seen, err
+		})
+	},
+}
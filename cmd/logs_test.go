@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogSourceCommand(t *testing.T) {
+	resume := time.Unix(1700000000, 0)
+
+	unit := logSource{unit: "nginx"}
+	assert.Equal(t, "journalctl --no-pager -u nginx -n 50 -f", unit.command(50, true, time.Time{}))
+	assert.Equal(t, "journalctl --no-pager -u nginx --since @1700000000 -f", unit.command(50, true, resume))
+	assert.Equal(t, "journalctl --no-pager -n 10", logSource{}.command(10, false, time.Time{}))
+
+	file := logSource{file: "/var/log/my app.log"}
+	assert.Equal(t, "tail -n 50 -F -- '/var/log/my app.log'", file.command(50, true, time.Time{}))
+	assert.Equal(t, "tail -n 0 -F -- '/var/log/my app.log'", file.command(50, true, resume), "a reconnect carries on from the end")
+}
+
+func TestLogSourceFor(t *testing.T) {
+	orig := gtCfg
+	t.Cleanup(func() { gtCfg = orig })
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"web": {Logs: map[string]string{"app": "/srv/app/app.log"}}}}
+
+	src, err := logSourceFor("web", "app")
+	assert.NoError(t, err)
+	assert.Equal(t, logSource{file: "/srv/app/app.log"}, src)
+	src, err = logSourceFor("web", "nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, logSource{unit: "nginx"}, src)
+}
+
+func TestLevelWriter(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	w := &levelWriter{w: &out}
+	fmt.Fprint(w, "info: started\nERROR: boom\npartial")
+	assert.Equal(t, "info: started\nERROR: boom\n", out.String())
+	w.Flush()
+	assert.Equal(t, "info: started\nERROR: boom\npartial", out.String())
+	assert.True(t, w.seen)
+
+	assert.True(t, logErrorLevel.MatchString("Oct 14 nginx[1]: connect() failed (111)"))
+	assert.True(t, logWarnLevel.MatchString("[WARNING] disk almost full"))
+	assert.False(t, logErrorLevel.MatchString("terrors and warnings-free line"))
+}
+
+func TestFollowLogsReconnects(t *testing.T) {
+	origSleep := logsSleep
+	t.Cleanup(func() { logsSleep = origSleep })
+	var slept []time.Duration
+	logsSleep = func(d time.Duration) { slept = append(slept, d) }
+
+	dropped := withCode(exitConnection, &exec.ExitError{})
+	type run struct {
+		live bool
+		err  error
+	}
+	script := []run{{true, dropped}, {false, dropped}, {false, dropped}, {true, dropped}, {true, nil}}
+	var resumes []time.Time
+	err := followLogs(true, func(resume time.Time) (bool, error) {
+		resumes = append(resumes, resume)
+		r := script[0]
+		script = script[1:]
+		return r.live, r.err
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, script)
+	assert.True(t, resumes[0].IsZero())
+	assert.False(t, resumes[1].IsZero())
+	assert.Equal(t, resumes[1], resumes[3], "failed attempts keep resuming from the last live session")
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, time.Second}, slept)
+}
+
+func TestFollowLogsGivesUp(t *testing.T) {
+	origSleep := logsSleep
+	t.Cleanup(func() { logsSleep = origSleep })
+	logsSleep = func(time.Duration) { t.Fatal("must not retry") }
+
+	dropped := withCode(exitConnection, &exec.ExitError{})
+	for name, tc := range map[string]struct {
+		follow bool
+		live   bool
+		err    error
+	}{
+		"host never answered":  {true, false, dropped},
+		"not following":        {false, true, dropped},
+		"login refused":        {true, true, withCode(exitAuth, errors.New("authentication failed"))},
+		"remote command ended": {true, true, errors.New("exit status 1")},
+	} {
+		err := followLogs(tc.follow, func(time.Time) (bool, error) { return tc.live, tc.err })
+		assert.Equal(t, tc.err, err, name)
+	}
+}
+
+func TestLogsRunsJournalctl(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useWebGroup(t)
+	logsNoFollow = true
+	t.Cleanup(func() { logsNoFollow = false })
+
+	assert.NoError(t, logsCmd.RunE(logsCmd, []string{"web-1", "nginx"}))
+	args := mockRun("ssh")
+	assert.Equal(t, "journalctl --no-pager -u nginx -n 50", args[len(args)-1])
+	assert.Contains(t, args, "ServerAliveInterval=15")
+}
@@ -0,0 +1,520 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var mcpTimeout time.Duration
+
+// mcpConfig is the mcp section of gt's config: what gt mcp lets a
+// client see and run.
+type mcpConfig struct {
+	// Hosts limits the tools to these targets (aliases, globs, @groups);
+	// empty is every host.
+	Hosts []string `yaml:"hosts"`
+	// Commands are the command lines run_command may run, word by word;
+	// a word may be a glob, such as "systemctl status *". Unset is
+	// defaultMCPCommands.
+	Commands []string `yaml:"commands"`
+}
+
+// defaultMCPCommands are run_command's commands when the config names
+// none: ones that only read.
+var defaultMCPCommands = []string{
+	"uptime",
+	"uname -a",
+	"hostname",
+	"who",
+	"df -h",
+	"free -m",
+	"systemctl --failed",
+	"systemctl status *",
+	"systemctl is-active *",
+	"journalctl -u * -n *",
+}
+
+// mcpOutputLimit caps each stream of a run_command result.
+const mcpOutputLimit = 64 << 10
+
+// mcpProtocolVersions are the MCP revisions gt speaks, newest first.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// rpcRequest is a JSON-RPC 2.0 request, or a notification when it has
+// no id.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// JSON-RPC's error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// mcpTool is one tool of gt mcp: what tools/list says of it, and what
+// tools/call runs. call's result goes back as JSON text.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	call        func(args json.RawMessage) (any, error)
+}
+
+// mcpServer answers MCP requests under the policy in gt's config.
+type mcpServer struct {
+	// allowed are the hosts the tools may touch, nil for every one.
+	allowed  map[string]bool
+	commands []string
+	tools    []mcpTool
+}
+
+// policyError is a request the policy refuses.
+func policyError(format string, a ...any) error {
+	return fmt.Errorf("not allowed by gt's mcp policy: "+format, a...)
+}
+
+// newMCPServer reads the policy from gt's config.
+func newMCPServer() (*mcpServer, error) {
+	s := &mcpServer{commands: gtCfg.MCP.Commands}
+	if s.commands == nil {
+		s.commands = defaultMCPCommands
+	}
+	if len(gtCfg.MCP.Hosts) > 0 {
+		aliases, err := expandTargets(gtCfg.MCP.Hosts)
+		if err != nil {
+			return nil, fmt.Errorf("mcp hosts: %w", err)
+		}
+		s.allowed = make(map[string]bool, len(aliases))
+		for _, a := range aliases {
+			s.allowed[a] = true
+		}
+	}
+	s.tools = s.newTools()
+	return s, nil
+}
+
+// host resolves a tool's alias argument and checks the policy allows it.
+func (s *mcpServer) host(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("alias is required")
+	}
+	alias, err := hostArg(name)
+	if err != nil {
+		return "", err
+	}
+	if s.allowed != nil && !s.allowed[alias] {
+		return "", policyError("host %s", alias)
+	}
+	return alias, nil
+}
+
+// commandAllowed reports whether argv matches one of the policy's
+// command lines: as many words, each equal or matching the glob. A glob
+// word only matches an argument starting with "-" if it starts with "-"
+// itself, so "systemctl status *" cannot smuggle in --host=, or
+// "journalctl -u * -n *" --vacuum-time=.
+func commandAllowed(patterns, argv []string) bool {
+	for _, p := range patterns {
+		words := strings.Fields(p)
+		if len(words) != len(argv) {
+			continue
+		}
+		ok := true
+		for i, w := range words {
+			if strings.HasPrefix(argv[i], "-") && w != argv[i] && !strings.HasPrefix(w, "-") {
+				ok = false
+				break
+			}
+			if m, err := path.Match(w, argv[i]); err != nil || !m {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cappedBuffer keeps the first mcpOutputLimit bytes written to it.
+type cappedBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := mcpOutputLimit - c.Len(); len(p) > room {
+		c.truncated = true
+		c.Buffer.Write(p[:room])
+		return len(p), nil
+	}
+	return c.Buffer.Write(p)
+}
+
+// mcpRun is run_command's result.
+type mcpRun struct {
+	Alias     string `json:"alias"`
+	Exit      int    `json:"exit"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// runAllowed runs argv on alias in BatchMode, each word quoted so the
+// remote shell takes it literally, for at most --timeout. A command
+// that ran is a result whatever its exit status; a run that could not
+// connect is an error. It runs the connect hooks, which may refuse it.
+func runAllowed(alias string, argv []string) (mcpRun, error) {
+	var r mcpRun
+	ran := false
+	err := withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: argv}, func() error {
+		cmd, err := remoteCommand(alias, remoteOpts{batch: true}, quoteArgv(argv))
+		if err != nil {
+			return err
+		}
+		var stdout, stderr cappedBuffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		debugf(1, "exec: %s", quoteArgv(cmd.Args))
+		start := time.Now()
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		untrack := track(cmd.Process, false)
+		timer := time.AfterFunc(mcpTimeout, func() { cmd.Process.Kill() })
+		err = cmd.Wait()
+		timedOut := !timer.Stop()
+		untrack()
+		logConnection(alias, "mcp", start, err)
+		if timedOut {
+			return fmt.Errorf("%s: %s did not finish within %s", alias, argv[0], mcpTimeout)
+		}
+		r = mcpRun{Alias: alias, Exit: exitCodeOf(err), Stdout: stdout.String(), Stderr: stderr.String(),
+			Truncated: stdout.truncated || stderr.truncated}
+		if r.Exit == 255 || r.Exit < 0 {
+			return classifyRun(alias, "ssh", err, stderr.String())
+		}
+		// The post hook sees the command's own exit status.
+		ran = true
+		return err
+	})
+	if !ran {
+		return mcpRun{}, err
+	}
+	return r, nil
+}
+
+// newTools builds the tools. None of them can change a host: run_command
+// only runs the policy's commands.
+func (s *mcpServer) newTools() []mcpTool {
+	str := map[string]any{"type": "string"}
+	return []mcpTool{
+		{
+			Name:        "list_hosts",
+			Description: "List the SSH hosts gt knows, with their resolved user, hostname, port and gt groups. Pass group to list one group's members.",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"group": map[string]any{"type": "string", "description": "a gt group, without the @"}},
+			},
+			call: func(raw json.RawMessage) (any, error) {
+				var args struct{ Group string }
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return nil, err
+				}
+				aliases := getHosts()
+				if args.Group != "" {
+					var err error
+					if aliases, err = groupMembers(strings.TrimPrefix(args.Group, "@")); err != nil {
+						return nil, err
+					}
+				}
+				var visible []string
+				for _, a := range aliases {
+					if s.allowed == nil || s.allowed[a] {
+						visible = append(visible, a)
+					}
+				}
+				hosts := []apiHost{}
+				for _, row := range resolveListRows(visible) {
+					h := apiHost{Alias: row.alias, User: row.User, Hostname: row.Hostname, Port: row.Port, Groups: hostMetaFor(row.alias).Groups}
+					if row.err != nil {
+						h.Error = row.err.Error()
+					}
+					hosts = append(hosts, h)
+				}
+				return hosts, nil
+			},
+		},
+		{
+			Name:        "resolve_host",
+			Description: "Show every SSH option ssh -G resolves for a host alias from the user's SSH config.",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"alias": str},
+				"required":   []string{"alias"},
+			},
+			call: func(raw json.RawMessage) (any, error) {
+				var args struct{ Alias string }
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return nil, err
+				}
+				alias, err := s.host(args.Alias)
+				if err != nil {
+					return nil, err
+				}
+				opts, err := sshConfigDump(alias)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"alias": alias, "options": opts}, nil
+			},
+		},
+		{
+			Name:        "check_reachability",
+			Description: "Probe hosts without logging in: TCP connect latency, the SSH banner, and whether the host key matches known_hosts.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{"hosts": map[string]any{
+					"type": "array", "items": str,
+					"description": "aliases, globs or @groups",
+				}},
+				"required": []string{"hosts"},
+			},
+			call: func(raw json.RawMessage) (any, error) {
+				var args struct{ Hosts []string }
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return nil, err
+				}
+				if len(args.Hosts) == 0 {
+					return nil, errors.New("hosts is required")
+				}
+				aliases, err := expandTargets(args.Hosts)
+				if err != nil {
+					return nil, err
+				}
+				for _, a := range aliases {
+					if s.allowed != nil && !s.allowed[a] {
+						return nil, policyError("host %s", a)
+					}
+				}
+				hosts := make([]hostStatus, len(aliases))
+				index := make(map[string]int, len(aliases))
+				for i, a := range aliases {
+					index[a] = i
+				}
+				fanOut(aliases, 16, func(alias string) error {
+					hosts[index[alias]] = checkStatus(alias, 0)
+					return nil
+				}, nil)
+				return hosts, nil
+			},
+		},
+		{
+			Name: "run_command",
+			Description: "Run one of the allowed read-only commands on a host, in BatchMode, and return its exit status and output. " +
+				"The command is a list of words, each passed literally (no shell). Allowed, where * matches any one word: " +
+				strings.Join(s.commands, "; "),
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"alias":   str,
+					"command": map[string]any{"type": "array", "items": str},
+				},
+				"required": []string{"alias", "command"},
+			},
+			call: func(raw json.RawMessage) (any, error) {
+				var args struct {
+					Alias   string
+					Command []string
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return nil, err
+				}
+				alias, err := s.host(args.Alias)
+				if err != nil {
+					return nil, err
+				}
+				if len(args.Command) == 0 {
+					return nil, errors.New("command is required")
+				}
+				if !commandAllowed(s.commands, args.Command) {
+					return nil, policyError("command %s", quoteArgv(args.Command))
+				}
+				return runAllowed(alias, args.Command)
+			},
+		},
+	}
+}
+
+// textContent is an MCP tool result of one text item.
+func textContent(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// handle answers one request; nil for a notification.
+func (s *mcpServer) handle(req rpcRequest) *rpcResponse {
+	if len(req.ID) == 0 {
+		return nil // notifications/initialized, cancellations and the like
+	}
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	fail := func(code int, msg string) *rpcResponse {
+		resp.Error = &rpcError{Code: code, Message: msg}
+		return resp
+	}
+	if req.JSONRPC != "2.0" {
+		return fail(rpcInvalidRequest, `jsonrpc must be "2.0"`)
+	}
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := mcpProtocolVersions[0]
+		for _, v := range mcpProtocolVersions {
+			if v == params.ProtocolVersion {
+				version = v
+			}
+		}
+		resp.Result = map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "gt", "version": currentBuild().Version},
+			"instructions":    "gt's SSH hosts. Nothing here changes a host; run_command runs only the commands its description lists.",
+		}
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		resp.Result = map[string]any{"tools": s.tools}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return fail(rpcInvalidParams, err.Error())
+		}
+		if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+			params.Arguments = json.RawMessage("{}")
+		}
+		for _, t := range s.tools {
+			if t.Name != params.Name {
+				continue
+			}
+			result, err := t.call(params.Arguments)
+			if err != nil {
+				resp.Result = textContent(err.Error(), true)
+				return resp
+			}
+			out, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return fail(rpcInternalError, err.Error())
+			}
+			resp.Result = textContent(string(out), false)
+			return resp
+		}
+		return fail(rpcInvalidParams, fmt.Sprintf("unknown tool %q", params.Name))
+	default:
+		return fail(rpcMethodNotFound, fmt.Sprintf("method %q not found", req.Method))
+	}
+	return resp
+}
+
+// serve reads newline-delimited JSON-RPC requests from in, MCP's stdio
+// transport, and writes each answer to out on a line of its own, until
+// in ends.
+func (s *mcpServer) serve(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	enc := json.NewEncoder(out)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		var resp *rpcResponse
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp = &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}}
+		} else {
+			resp = s.handle(req)
+		}
+		if resp == nil {
+			continue
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve gt's hosts to AI assistants over the Model Context Protocol",
+	Long: `Speak the Model Context Protocol on stdin and stdout, for an AI assistant
+or editor agent to start as a tool server. The tools only read:
+
+  list_hosts          aliases with resolved user, hostname, port and groups
+  resolve_host        every option ssh -G resolves for an alias
+  check_reachability  latency, SSH banner and host key, as gt status probes
+  run_command         one of the allowed commands, in BatchMode
+
+run_command takes the command as words, each passed literally, and runs
+it only if it matches a line of the policy word for word, where a word
+may be a glob ("systemctl status *"). Without a policy that is uptime,
+uname -a, hostname, who, df -h, free -m, systemctl --failed, systemctl
+status/is-active UNIT and journalctl -u UNIT -n N. A run gets --timeout
+and 64 KiB of output per stream, and is audit-logged as "mcp".
+
+The policy is the mcp section of gt's config:
+
+  mcp:
+    hosts: ["@staging", "db-*"]   # what the tools see; default every host
+    commands:                     # replaces the default list
+      - uptime
+      - systemctl status *`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if mcpTimeout <= 0 {
+			return errors.New("--timeout must be positive")
+		}
+		s, err := newMCPServer()
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		return s.serve(cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandAllowed(t *testing.T) {
+	for argv, want := range map[string]bool{
+		"uptime":                           true,
+		"uptime -p":                        false,
+		"systemctl status nginx":           true,
+		"systemctl status nginx sshd":      false,
+		"systemctl restart nginx":          false,
+		"journalctl -u app -n 50":          true,
+		"journalctl -u app -n 50 -f":       false,
+		"rm -rf /":                         false,
+		"systemctl status nginx;reboot":    true, // one word, passed literally
+		"tail -n 20 /var/log/syslog":       false,
+		"/usr/bin/uptime":                  false,
+		"df -h":                            true,
+		"systemctl --failed":               true,
+		"systemctl is-active postgresql":   true,
+		"systemctl is-active postgresql x": false,
+	} {
+		assert.Equal(t, want, commandAllowed(defaultMCPCommands, strings.Fields(argv)), argv)
+	}
+	assert.True(t, commandAllowed([]string{"tail -n * /var/log/*"}, []string{"tail", "-n", "20", "/var/log/syslog"}))
+	assert.False(t, commandAllowed([]string{"tail -n * /var/log/*"}, []string{"tail", "-n", "20", "/var/log/../../etc/shadow"}), "* does not match /")
+	assert.False(t, commandAllowed(defaultMCPCommands, []string{"journalctl", "-u", "app", "-n", "--vacuum-time=1s"}), "* matches no option")
+	assert.False(t, commandAllowed(defaultMCPCommands, []string{"systemctl", "status", "-Hroot@db"}), "* matches no option")
+	assert.False(t, commandAllowed(defaultMCPCommands, []string{"systemctl", "is-active", "--host=db"}), "* matches no option")
+	assert.True(t, commandAllowed([]string{"ls -* /tmp"}, []string{"ls", "-la", "/tmp"}), "a glob starting with - may match options")
+}
+
+func TestCappedBuffer(t *testing.T) {
+	var c cappedBuffer
+	c.Write(bytes.Repeat([]byte("x"), mcpOutputLimit-1))
+	assert.False(t, c.truncated)
+	n, err := c.Write([]byte("yz"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n, "the writer takes everything, keeping what fits")
+	assert.True(t, c.truncated)
+	assert.Equal(t, mcpOutputLimit, c.Len())
+}
+
+// mcpSession sends requests, one per line, to a gt mcp server under the
+// given policy and returns its responses by id.
+func mcpSession(t *testing.T, policy mcpConfig, requests ...string) map[string]rpcResponse {
+	t.Helper()
+	orig := gtCfg.MCP
+	t.Cleanup(func() { gtCfg.MCP = orig })
+	gtCfg.MCP = policy
+	s, err := newMCPServer()
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, s.serve(strings.NewReader(strings.Join(requests, "\n")+"\n"), &out))
+
+	responses := map[string]rpcResponse{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var r rpcResponse
+		require.NoError(t, json.Unmarshal([]byte(line), &r), line)
+		assert.Equal(t, "2.0", r.JSONRPC)
+		responses[string(r.ID)] = r
+	}
+	return responses
+}
+
+// toolText is a tools/call result's text, and whether it is an error.
+func toolText(t *testing.T, r rpcResponse) (string, bool) {
+	t.Helper()
+	require.Nil(t, r.Error)
+	var result struct {
+		Content []struct{ Type, Text string }
+		IsError bool
+	}
+	b, _ := json.Marshal(r.Result)
+	require.NoError(t, json.Unmarshal(b, &result))
+	require.Len(t, result.Content, 1)
+	assert.Equal(t, "text", result.Content[0].Type)
+	return result.Content[0].Text, result.IsError
+}
+
+func TestMCPProtocol(t *testing.T) {
+	useWebGroup(t)
+	responses := mcpSession(t, mcpConfig{},
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":"p","method":"ping"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"reboot","arguments":{}}}`,
+		`not json`,
+	)
+	require.Len(t, responses, 6, "no answer to the notification")
+
+	init, _ := json.Marshal(responses["1"].Result)
+	assert.Contains(t, string(init), `"protocolVersion":"2025-03-26"`, "the client's version, which gt speaks")
+	assert.Contains(t, string(init), `"tools":{}`)
+
+	var list struct{ Tools []mcpTool }
+	b, _ := json.Marshal(responses["2"].Result)
+	require.NoError(t, json.Unmarshal(b, &list))
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+		assert.Equal(t, "object", tool.InputSchema["type"], tool.Name)
+	}
+	assert.Equal(t, []string{"list_hosts", "resolve_host", "check_reachability", "run_command"}, names)
+	assert.Contains(t, list.Tools[3].Description, "systemctl status *", "the allowed commands are in the description")
+
+	assert.Equal(t, "{}", mustJSON(t, responses[`"p"`].Result))
+	assert.Equal(t, rpcMethodNotFound, responses["3"].Error.Code)
+	assert.Equal(t, rpcInvalidParams, responses["4"].Error.Code)
+	assert.Equal(t, rpcParseError, responses["null"].Error.Code)
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestMCPTools(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	responses := mcpSession(t, mcpConfig{Hosts: []string{"web-1", "down"}, Commands: []string{"echo *"}},
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"list_hosts","arguments":{"group":"web"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"run_command","arguments":{"alias":"web-1","command":["echo","hello"]}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"run_command","arguments":{"alias":"web-1","command":["uptime"]}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"run_command","arguments":{"alias":"web-2","command":["echo","hi"]}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"resolve_host","arguments":{"alias":"web-1"}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"run_command","arguments":{"alias":"down","command":["echo","hi"]}}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"check_reachability","arguments":{"hosts":["@web"]}}}`,
+	)
+
+	text, isErr := toolText(t, responses["1"])
+	assert.False(t, isErr)
+	var hosts []apiHost
+	require.NoError(t, json.Unmarshal([]byte(text), &hosts))
+	var aliases []string
+	for _, h := range hosts {
+		aliases = append(aliases, h.Alias)
+	}
+	assert.ElementsMatch(t, []string{"web-1", "down"}, aliases, "only the hosts the policy exposes")
+
+	text, isErr = toolText(t, responses["2"])
+	assert.False(t, isErr)
+	var run mcpRun
+	require.NoError(t, json.Unmarshal([]byte(text), &run))
+	assert.Equal(t, mcpRun{Alias: "web-1", Exit: 0, Stdout: "hello\n"}, run)
+	assert.Contains(t, strings.Join(mockRun("ssh"), " "), "BatchMode=yes")
+
+	text, isErr = toolText(t, responses["3"])
+	assert.True(t, isErr)
+	assert.Equal(t, "not allowed by gt's mcp policy: command uptime", text)
+	text, isErr = toolText(t, responses["4"])
+	assert.True(t, isErr)
+	assert.Equal(t, "not allowed by gt's mcp policy: host web-2", text)
+
+	text, isErr = toolText(t, responses["5"])
+	assert.False(t, isErr)
+	assert.Contains(t, text, `"test.example.com"`)
+
+	_, isErr = toolText(t, responses["6"])
+	assert.True(t, isErr, "a host that cannot be reached is an error, not an exit status")
+
+	text, isErr = toolText(t, responses["7"])
+	assert.True(t, isErr)
+	assert.Equal(t, "not allowed by gt's mcp policy: host web-2", text)
+}
+
+func TestRunAllowedRunsHooks(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	dir := useHooks(t, map[string]int{hookPreConnect: 0, hookPostConnect: 0})
+
+	run, err := runAllowed("web-1", []string{"echo", "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", run.Stdout)
+	// The mock stands in for the hooks too, so only their order shows.
+	cmds := mockCmd.commands
+	require.NotEmpty(t, cmds)
+	assert.Equal(t, filepath.Join(dir, hookPreConnect), cmds[0])
+	assert.Equal(t, filepath.Join(dir, hookPostConnect), cmds[len(cmds)-1])
+	assert.Contains(t, cmds, "ssh")
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/transport"
+)
+
+var (
+	// quietMOTD is --quiet-motd, as a host's quiet_motd setting is for
+	// that host.
+	quietMOTD bool
+
+	motdHush   bool
+	motdUnhush bool
+)
+
+// quietMOTDEnabled reports whether runs on alias leave out its banner.
+func quietMOTDEnabled(alias string) bool {
+	return quietMOTD || hostMetaFor(alias).QuietMOTD
+}
+
+// motdOptions quiets o for a command or transfer on alias when
+// --quiet-motd or quiet_motd says so. ssh prints the server's Banner at
+// LogLevel INFO and up, so ERROR leaves it out and keeps real errors, and
+// transfers their progress meter, which -q would hide too. -v asks for
+// more rather than less, and wins.
+func motdOptions(o transport.Options, alias string) transport.Options {
+	if !quietMOTDEnabled(alias) || o.Verbosity > 0 {
+		return o
+	}
+	o.Overrides = append(append([]string(nil), o.Overrides...), "LogLevel=ERROR")
+	return o
+}
+
+// motdScript prints whether ~/.hushlogin is there, then the message of
+// the day as a login would show it: what pam_motd generated last, then
+// /etc/motd.
+const motdScript = `if [ -e "$HOME/.hushlogin" ]; then echo hushlogin=yes; else echo hushlogin=no; fi
+for f in /run/motd.dynamic /etc/motd; do [ -r "$f" ] && cat "$f"; done
+exit 0
+`
+
+// cleanBanner drops the lines ssh adds to a connection's stderr from
+// the server's Banner.
+func cleanBanner(stderr string) string {
+	var lines []string
+	for _, line := range strings.Split(stderr, "\n") {
+		if strings.HasPrefix(line, "Warning: Permanently added") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// fetchMOTD connects to alias once and returns the Banner it showed
+// before authentication, its message of the day, and whether a
+// ~/.hushlogin hides the latter at login. It runs the connect hooks.
+func fetchMOTD(alias string) (banner, motd string, hushed bool, err error) {
+	remoteCmd := shellScript(motdScript)
+	var stdout, stderr bytes.Buffer
+	err = withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: []string{remoteCmd}}, func() error {
+		cmd, err := remoteCommand(alias, remoteOpts{showBanner: true}, remoteCmd)
+		if err != nil {
+			return err
+		}
+		debugf(1, "exec: %s", quoteArgv(cmd.Args))
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		start := time.Now()
+		err = runTracked(cmd, false)
+		logConnection(alias, "motd", start, err)
+		if err != nil {
+			return classifyRun(alias, "ssh", err, stderr.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", false, err
+	}
+	first, rest, _ := strings.Cut(stdout.String(), "\n")
+	return cleanBanner(stderr.String()), strings.Trim(rest, "\n"), first == "hushlogin=yes", nil
+}
+
+var motdCmd = &cobra.Command{
+	Use:   "motd <alias>",
+	Short: "Show a host's login banner and message of the day",
+	Long: `Connect to the host and print what a login shows: the Banner sshd sends
+before authentication, then the message of the day (/run/motd.dynamic,
+as pam_motd last generated it, and /etc/motd). Nothing else runs.
+
+--hush creates ~/.hushlogin on the host, so logins there no longer show
+the message of the day; --unhush removes it. For commands and transfers,
+which show no message of the day, --quiet-motd (or quiet_motd on the
+host) leaves out the banner instead.`,
+	Example: `  gt motd web1
+  gt motd web1 --hush`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHosts,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		if motdHush && motdUnhush {
+			return errors.New("--hush and --unhush are mutually exclusive")
+		}
+		cmd.SilenceUsage = true
+		if motdHush || motdUnhush {
+			script, done := `touch -- "$HOME/.hushlogin"`, "Logins to %s no longer show the message of the day\n"
+			if motdUnhush {
+				script, done = `rm -f -- "$HOME/.hushlogin"`, "Logins to %s show the message of the day again\n"
+			}
+			err := withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: []string{shellScript(script)}}, func() error {
+				_, err := remoteOutput(alias, "motd", remoteOpts{}, shellScript(script))
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			statusf(symbolColor, done, alias)
+			return nil
+		}
+
+		banner, motd, hushed, err := fetchMOTD(alias)
+		if err != nil {
+			return err
+		}
+		w := cmd.OutOrStdout()
+		if banner == "" && motd == "" {
+			statusf(symbolColor, "%s shows no banner or message of the day\n", alias)
+			return nil
+		}
+		if banner != "" {
+			fmt.Fprintln(w, banner)
+		}
+		if banner != "" && motd != "" {
+			fmt.Fprintln(w)
+		}
+		if motd != "" {
+			fmt.Fprintln(w, motd)
+		}
+		if hushed && motd != "" {
+			warningColor.Fprintf(cmd.ErrOrStderr(), "~/.hushlogin on %s hides the message of the day at login\n", alias)
+		}
+		return nil
+	},
+}
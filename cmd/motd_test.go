@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/transport"
+)
+
+func TestMOTDOptions(t *testing.T) {
+	useWebGroup(t)
+	t.Cleanup(func() { quietMOTD = false })
+	gtCfg.Hosts["web-1"] = hostMeta{QuietMOTD: true}
+	o := transport.Options{Overrides: []string{"ServerAliveInterval=30"}}
+
+	assert.Equal(t, o, motdOptions(o, "web-2"))
+	assert.Equal(t, []string{"ServerAliveInterval=30", "LogLevel=ERROR"}, motdOptions(o, "web-1").Overrides)
+	assert.Equal(t, []string{"ServerAliveInterval=30"}, o.Overrides, "the caller's options are left alone")
+	o.Verbosity = 1
+	assert.Equal(t, o, motdOptions(o, "web-1"), "-v wins")
+
+	quietMOTD = true
+	assert.True(t, quietMOTDEnabled("web-2"), "--quiet-motd is for every host")
+	assert.Contains(t, remoteOpts{}.transport("web-2", 0).Overrides, "LogLevel=ERROR")
+	shown := remoteOpts{showBanner: true}.transport("web-2", 0)
+	assert.NotContains(t, shown.Overrides, "LogLevel=ERROR")
+	assert.Contains(t, shown.Overrides, "LogLevel=INFO")
+}
+
+func TestRunSSHQuietMOTD(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	t.Cleanup(func() { quietMOTD = false })
+	quietMOTD = true
+
+	require.NoError(t, runSSH("web-1", []string{"uptime"}))
+	assert.Equal(t, []string{"-o", "LogLevel=ERROR", "--", "web-1", "uptime"}, mockRun("ssh"))
+
+	mockCmd.reset()
+	require.NoError(t, runSSH("web-1", nil))
+	assert.Equal(t, []string{"--", "web-1"}, mockRun("ssh"), "a login shows its banner and message of the day")
+}
+
+func TestCleanBanner(t *testing.T) {
+	assert.Equal(t, "Authorized use only.", cleanBanner("Warning: Permanently added 'x' (ED25519) to the list of known hosts.\nAuthorized use only.\n"))
+	assert.Equal(t, "", cleanBanner(""))
+}
+
+func TestMOTDCommand(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	plainOutput(t)
+
+	var out, errOut bytes.Buffer
+	motdCmd.SetOut(&out)
+	motdCmd.SetErr(&errOut)
+	t.Cleanup(func() {
+		motdCmd.SetOut(nil)
+		motdCmd.SetErr(nil)
+	})
+	require.NoError(t, motdCmd.RunE(motdCmd, []string{"web-1"}))
+	assert.Equal(t, "Authorized use only.\n\nWelcome to web-1\n", out.String())
+	assert.Contains(t, errOut.String(), "~/.hushlogin on web-1 hides the message of the day")
+	assert.Contains(t, mockRun("ssh"), "LogLevel=INFO")
+
+	t.Cleanup(func() { motdHush, motdUnhush = false, false })
+	motdHush, motdUnhush = true, true
+	assert.ErrorContains(t, motdCmd.RunE(motdCmd, []string{"web-1"}), "mutually exclusive")
+	motdUnhush = false
+	mockCmd.reset()
+	require.NoError(t, motdCmd.RunE(motdCmd, []string{"web-1"}))
+	assert.Contains(t, mockRun("ssh")[len(mockRun("ssh"))-1], ".hushlogin")
+}
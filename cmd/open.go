@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/transport"
+)
+
+var (
+	openTunnel bool
+	openHTTPS  bool
+)
+
+// tunnelPoll is how often gt open checks whether the forward is up.
+const tunnelPoll = 100 * time.Millisecond
+
+// webURL is the address of a web UI at host, on port unless it is empty.
+func webURL(scheme, host, port string) string {
+	h := host
+	if port != "" {
+		h = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		h = "[" + host + "]"
+	}
+	return (&url.URL{Scheme: scheme, Host: h, Path: "/"}).String()
+}
+
+// browserCommand is the command that opens u locally: $BROWSER when set
+// (its first entry, with %s standing for the URL), otherwise the
+// platform's opener.
+func browserCommand(goos string, getenv func(string) string, u string) []string {
+	if b, _, _ := strings.Cut(getenv("BROWSER"), string(os.PathListSeparator)); strings.TrimSpace(b) != "" {
+		argv := strings.Fields(b)
+		for i, a := range argv {
+			if strings.Contains(a, "%s") {
+				argv[i] = strings.ReplaceAll(a, "%s", u)
+				return argv
+			}
+		}
+		return append(argv, u)
+	}
+	switch goos {
+	case "darwin":
+		return []string{"open", u}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler", u}
+	}
+	return []string{"xdg-open", u}
+}
+
+// openBrowser opens u in the local browser.
+func openBrowser(u string) error {
+	argv := browserCommand(runtime.GOOS, os.Getenv, u)
+	c := execCommand(argv[0], argv[1:]...)
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	debugf(1, "exec: %s", quoteArgv(c.Args))
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("opening %s with %s: %w", u, argv[0], err)
+	}
+	return nil
+}
+
+// freeLocalPort asks the kernel for a loopback port nothing listens on.
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// tunnel is a running ssh -L forward.
+type tunnel struct {
+	local int
+	done  chan error
+}
+
+// startTunnel forwards a free loopback port to port on alias's own
+// localhost, and returns once the forward accepts connections or ssh
+// has given up. ssh keeps the terminal, so it can ask for a password.
+func startTunnel(alias string, port int) (*tunnel, error) {
+	if pluginTransport() != nil || usePuTTY() {
+		return nil, errors.New("--tunnel needs the OpenSSH backend")
+	}
+	local, err := freeLocalPort()
+	if err != nil {
+		return nil, err
+	}
+	opts := passwordOptions(hostOptions(baseOptions(), alias), alias)
+	opts.Verbosity = verbosity
+	opts.Extra = []string{"-N", "-o", "ExitOnForwardFailure=yes", "-L", fmt.Sprintf("127.0.0.1:%d:localhost:%d", local, port)}
+	c := withAskpass(sshCommand(transport.SSHArgs(opts, alias, nil)...), alias)
+	var tail tailBuffer
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = io.MultiWriter(os.Stderr, &tail)
+	debugf(1, "exec: %s", quoteArgv(c.Args))
+	start := time.Now()
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	untrack := track(c.Process, true)
+	t := &tunnel{local: local, done: make(chan error, 1)}
+	go func() {
+		err := c.Wait()
+		untrack()
+		logConnection(alias, "tunnel", start, err)
+		if err != nil && !stopRequested() {
+			err = classifyRun(alias, "ssh", err, tail.String())
+		} else {
+			err = nil
+		}
+		t.done <- err
+	}()
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(local))
+	for {
+		select {
+		case err := <-t.done:
+			if err == nil {
+				err = errors.New("ssh exited before the forward was up")
+			}
+			return nil, err
+		default:
+		}
+		if portOpen(addr, tunnelPoll) {
+			return t, nil
+		}
+		time.Sleep(tunnelPoll)
+	}
+}
+
+var openCmd = &cobra.Command{
+	Use:   "open <alias> [port]",
+	Short: "Open a host's web UI in the local browser",
+	Long: `Open http://<hostname>[:port]/ in the local browser, with the hostname
+ssh -G resolves the alias to; port 443, or --https, makes it https.
+
+A service that only listens on the host's localhost is out of reach that
+way; --tunnel forwards a free local port to it over ssh first, opens
+http://localhost:<that port>/, and keeps the forward up until Ctrl-C:
+
+  gt open web1                  # http://web1.example.com/
+  gt open grafana 3000 --tunnel
+
+The browser is $BROWSER if set, otherwise open (macOS), xdg-open, or the
+Windows URL handler.`,
+	Args: cobra.RangeArgs(1, 2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeHosts(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		port := ""
+		if len(args) == 2 {
+			ports, err := parsePorts(args[1:])
+			if err != nil {
+				return err
+			}
+			port = strconv.Itoa(ports[0])
+		}
+		if openTunnel && port == "" {
+			return errors.New("--tunnel needs the port of the service to forward")
+		}
+		scheme := "http"
+		if openHTTPS || port == "443" {
+			scheme = "https"
+		}
+		cmd.SilenceUsage = true
+
+		if !openTunnel {
+			r, err := resolveHost(alias)
+			if err != nil {
+				return err
+			}
+			return openBrowser(webURL(scheme, r.Hostname, port))
+		}
+
+		n, _ := strconv.Atoi(port)
+		return withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias}, func() error {
+			t, err := startTunnel(alias, n)
+			if err != nil {
+				return err
+			}
+			statusf(symbolColor, "Forwarding localhost:%d to %s's localhost:%s; Ctrl-C closes it\n", t.local, alias, port)
+			if err := openBrowser(webURL(scheme, "localhost", strconv.Itoa(t.local))); err != nil {
+				warningColor.Fprintf(os.Stderr, "%v; the forward stays up for opening it by hand\n", err)
+			}
+			return <-t.done
+		})
+	},
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebURL(t *testing.T) {
+	assert.Equal(t, "http://web1.example.com/", webURL("http", "web1.example.com", ""))
+	assert.Equal(t, "https://web1.example.com:8443/", webURL("https", "web1.example.com", "8443"))
+	assert.Equal(t, "http://[2001:db8::1]:3000/", webURL("http", "2001:db8::1", "3000"))
+	assert.Equal(t, "http://[2001:db8::1]/", webURL("http", "2001:db8::1", ""))
+}
+
+func TestBrowserCommand(t *testing.T) {
+	env := func(v string) func(string) string {
+		return func(k string) string {
+			if k == "BROWSER" {
+				return v
+			}
+			return ""
+		}
+	}
+	u := "http://web1/"
+	assert.Equal(t, []string{"open", u}, browserCommand("darwin", env(""), u))
+	assert.Equal(t, []string{"rundll32", "url.dll,FileProtocolHandler", u}, browserCommand("windows", env(""), u))
+	assert.Equal(t, []string{"xdg-open", u}, browserCommand("linux", env(""), u))
+	assert.Equal(t, []string{"firefox", "--new-tab", u}, browserCommand("linux", env("firefox --new-tab"), u))
+	assert.Equal(t, []string{"lynx", "-dump", u}, browserCommand("linux", env("lynx -dump %s"), u))
+}
+
+func TestOpenResolvesHostname(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Setenv("BROWSER", "")
+	useMockExec(t)
+	useWebGroup(t)
+
+	assert.NoError(t, openCmd.RunE(openCmd, []string{"web-1", "443"}))
+	last := mockCmd.argLists[len(mockCmd.argLists)-1]
+	assert.Equal(t, "https://test.example.com:443/", last[len(last)-1])
+
+	assert.ErrorContains(t, openCmd.RunE(openCmd, []string{"web-1", "http"}), "invalid port")
+	openTunnel = true
+	t.Cleanup(func() { openTunnel = false })
+	assert.ErrorContains(t, openCmd.RunE(openCmd, []string{"web-1"}), "needs the port")
+}
+
+func TestTunnelFailsWhenSSHExits(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+
+	_, err := startTunnel("web-1", 3000)
+	assert.ErrorContains(t, err, "before the forward was up")
+	args := mockRun("ssh")
+	assert.True(t, contains(args, "-N"))
+	assert.True(t, contains(args, "ExitOnForwardFailure=yes"))
+	assert.Regexp(t, `^127\.0\.0\.1:\d+:localhost:3000$`, args[indexOf(args, "-L")+1])
+
+	_, err = startTunnel("down", 3000)
+	assert.Equal(t, exitConnection, ExitCode(err))
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
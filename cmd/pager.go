@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var noPager bool
+
+// pagerCommand picks the pager the way git does: GT_PAGER, then PAGER,
+// then less. An empty or "cat" value means no pager.
+func pagerCommand() string {
+	for _, name := range []string{"GT_PAGER", "PAGER"} {
+		if v, ok := os.LookupEnv(name); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return "less"
+}
+
+// needsPager reports whether output of the given line count should be
+// paged: only on a terminal, only when it would scroll off the screen,
+// and never with --no-pager or --no-input.
+func needsPager(lines int) bool {
+	if noPager || nonInteractive() || !stdoutIsTerminal() {
+		return false
+	}
+	height := terminalHeight()
+	return height > 0 && lines >= height
+}
+
+// pageOutput writes buffered output to stdout, through the pager when it
+// would not fit on one screen. The pager runs via sh -c so a $PAGER with
+// arguments works, with LESS=FRX unless set, as git does: -R passes colors
+// through, -F exits immediately if the text fits after all, and -X leaves
+// it on screen when less quits. If the pager cannot start, the output goes
+// straight to stdout instead of being lost; once it has started, its exit
+// status is its own business (quitting less early is not an error).
+func pageOutput(out []byte) error {
+	pager := pagerCommand()
+	if pager == "" || pager == "cat" || !needsPager(bytes.Count(out, []byte("\n"))) {
+		_, err := color.Output.Write(out)
+		return err
+	}
+	c := execCommand("sh", "-c", pager)
+	c.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		c.Env = append(c.Env, "LESS=FRX")
+	}
+	c.Stdin = bytes.NewReader(out)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Start(); err != nil {
+		_, err := color.Output.Write(out)
+		return err
+	}
+	// The pager owns the terminal until it quits; a Ctrl-C is its to
+	// handle, not a reason for gt to exit under it.
+	defer track(c.Process, true)()
+	c.Wait()
+	return nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPagerCommand(t *testing.T) {
+	unset := func(name string) {
+		if v, ok := os.LookupEnv(name); ok {
+			t.Cleanup(func() { os.Setenv(name, v) })
+		}
+		os.Unsetenv(name)
+	}
+
+	unset("GT_PAGER")
+	unset("PAGER")
+	assert.Equal(t, "less", pagerCommand())
+
+	t.Setenv("PAGER", "more")
+	assert.Equal(t, "more", pagerCommand())
+
+	t.Setenv("GT_PAGER", "less -S")
+	assert.Equal(t, "less -S", pagerCommand(), "GT_PAGER beats PAGER")
+
+	t.Setenv("GT_PAGER", "")
+	assert.Equal(t, "", pagerCommand(), "set-but-empty disables paging")
+}
+
+func TestPageOutputWritesDirectlyWhenNotATerminal(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("LINES", "2")
+	var buf bytes.Buffer
+	orig := color.Output
+	defer func() { color.Output = orig }()
+	color.Output = &buf
+
+	err := pageOutput([]byte("a\nb\nc\nd\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\nd\n", buf.String())
+	assert.Empty(t, mockCmd.commands, "no pager without a terminal")
+}
+
+func TestNeedsPagerRespectsNoPager(t *testing.T) {
+	orig := noPager
+	defer func() { noPager = orig }()
+	noPager = true
+	assert.False(t, needsPager(1000))
+}
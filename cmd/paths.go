@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gt/pkg/transport"
+)
+
+// contentsPrefix is what a source that copies a directory's contents
+// ("dir/", "dir/.", ":") puts before the names inside it.
+func contentsPrefix(p string) string {
+	p = strings.TrimSuffix(p, ".")
+	if p != "" && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// nothingToCopy is the error for contents sources that hold nothing.
+func nothingToCopy(sources []string) error {
+	if len(sources) == 1 {
+		return fmt.Errorf("nothing to copy: %s is empty", strings.TrimPrefix(sources[0], ":"))
+	}
+	return errors.New("nothing to copy: the sources are empty")
+}
+
+// planTransfer settles what a transfer in the colon shorthand copies, as
+// rsync reads a trailing slash: "dir" copies the directory and lands as
+// DEST/dir, "dir/" copies what is in it into DEST. Contents sources are
+// replaced with the entries they hold, dotfiles included, remote
+// wildcards with their matches on alias, and DEST is created if a
+// directory is copied into it. recursive reports whether the tool needs
+// -r.
+func planTransfer(alias string, files []string, opts remoteOpts) ([]string, bool, error) {
+	if err := transport.ValidateSCPPaths(files); err != nil {
+		return nil, false, err
+	}
+	dest := files[len(files)-1]
+	if strings.HasPrefix(dest, ":") {
+		return planUpload(alias, files[:len(files)-1], dest, opts)
+	}
+	return planDownload(alias, files[:len(files)-1], dest, opts)
+}
+
+// localContents replaces the contents sources of an upload with the
+// entries in them. dirs reports whether a directory is copied, itself or
+// its contents.
+func localContents(sources []string) (planned []string, dirs bool, err error) {
+	for _, src := range sources {
+		if !transport.CopiesContents(src) {
+			if info, err := os.Stat(src); err == nil && info.IsDir() {
+				dirs = true
+			}
+			planned = append(planned, src)
+			continue
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, e := range entries {
+			planned = append(planned, filepath.Join(src, e.Name()))
+		}
+		dirs = true
+	}
+	if len(planned) == 0 {
+		return nil, false, nothingToCopy(sources)
+	}
+	return planned, dirs, nil
+}
+
+func planUpload(alias string, sources []string, dest string, opts remoteOpts) ([]string, bool, error) {
+	planned, dirs, err := localContents(sources)
+	if err != nil {
+		return nil, false, err
+	}
+	if dirs && transferEngine != "tar" {
+		// scp copies a directory into DEST only if DEST exists; otherwise
+		// it would become DEST.
+		d := colonPath(dest)
+		if _, err := remoteOutput(alias, "mkdir", opts, shellScript(quoteArgv([]string{"mkdir", "-p", "--", d}))); err != nil {
+			return nil, false, withCode(exitTransfer, fmt.Errorf("creating %s on %s: %w", d, alias, err))
+		}
+	}
+	return append(planned, dest), dirs, nil
+}
+
+// downloadSource is one source of a download and the patterns that stand
+// for it on the host.
+type downloadSource struct {
+	src      string
+	patterns []string
+	// required fails the download if the patterns match nothing.
+	required bool
+	// contents is true for "dir/": the first pattern is dir itself,
+	// which must be a directory, the rest its entries.
+	contents bool
+}
+
+func planDownload(alias string, sources []string, dest string, opts remoteOpts) ([]string, bool, error) {
+	var plan []downloadSource
+	var patterns []string
+	probe := len(sources) == 1 && !strings.HasSuffix(dest, "/") && transferEngine != "tar"
+	if probe {
+		if _, err := os.Stat(dest); err == nil {
+			probe = false
+		}
+	}
+	for _, src := range sources {
+		p := colonPath(src)
+		s := downloadSource{src: src}
+		switch {
+		case transport.CopiesContents(src):
+			pre := contentsPrefix(strings.TrimPrefix(src, ":"))
+			dir := pre
+			if dir == "" {
+				dir = "."
+			}
+			s.contents = true
+			s.patterns = []string{dir, pre + "*", pre + ".[!.]*", pre + "..?*"}
+		case hasGlob(p):
+			s.patterns, s.required = []string{p}, true
+		case probe:
+			s.patterns = []string{p}
+		}
+		patterns = append(patterns, s.patterns...)
+		plan = append(plan, s)
+	}
+	var matches [][]remoteMatch
+	if len(patterns) > 0 {
+		var err error
+		if matches, err = matchRemote(alias, patterns, opts); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var planned []string
+	recursive, mkdir := false, strings.HasSuffix(dest, "/") || len(sources) > 1
+	for _, s := range plan {
+		if len(s.patterns) == 0 {
+			// Not looked up: either a file or a directory, so -r covers both.
+			planned = append(planned, s.src)
+			recursive = true
+			continue
+		}
+		found := matches[:len(s.patterns)]
+		matches = matches[len(s.patterns):]
+		if s.contents {
+			if len(found[0]) == 0 || !found[0][0].dir {
+				return nil, false, withCode(exitTransfer, fmt.Errorf("no directory on %s at %s", alias, s.patterns[0]))
+			}
+			found = found[1:]
+			mkdir = true
+		}
+		n := 0
+		for _, f := range found {
+			for _, m := range f {
+				planned = append(planned, ":"+m.path)
+				recursive = recursive || m.dir
+				n++
+			}
+		}
+		switch {
+		case n > 0:
+			mkdir = mkdir || s.required || recursive
+		case s.required:
+			return nil, false, withCode(exitTransfer, fmt.Errorf("no files on %s match %s", alias, colonPath(s.src)))
+		case s.contents:
+			// An empty directory adds nothing.
+		default:
+			// Missing: left for the tool to report.
+			planned = append(planned, s.src)
+		}
+	}
+	if len(planned) == 0 {
+		return nil, false, nothingToCopy(sources)
+	}
+	if len(patterns) > 0 {
+		debugf(1, "plan: %s copies %s", strings.Join(sources, " "), quoteArgv(planned))
+	}
+	if mkdir {
+		if err := os.MkdirAll(dest, 0o755); err != nil {
+			return nil, false, err
+		}
+	}
+	return append(planned, dest), recursive, nil
+}
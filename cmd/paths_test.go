@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentsPrefix(t *testing.T) {
+	assert.Equal(t, "logs/", contentsPrefix("logs/"))
+	assert.Equal(t, "logs/", contentsPrefix("logs/."))
+	assert.Equal(t, "/", contentsPrefix("/"))
+	assert.Equal(t, "", contentsPrefix("."))
+	assert.Equal(t, "", contentsPrefix(""))
+}
+
+// remoteScripts are the mocked ssh runs' remote commands.
+func remoteScripts() []string {
+	var scripts []string
+	for i, c := range mockCmd.commands {
+		if args := mockCmd.argLists[i]; c == "ssh" && !contains(args, "-G") {
+			scripts = append(scripts, args[len(args)-1])
+		}
+	}
+	return scripts
+}
+
+func TestPlanUpload(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "site")
+	require.NoError(t, os.Mkdir(src, 0o755))
+	for _, name := range []string{"index.html", ".htaccess"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), nil, 0o644))
+	}
+	file := filepath.Join(dir, "app.conf")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "empty"), 0o755))
+
+	files, recursive, err := planTransfer("web-1", []string{file, ":/etc/app/"}, remoteOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{file, ":/etc/app/"}, files)
+	assert.False(t, recursive)
+	assert.Empty(t, mockCmd.commands, "files only need no connection")
+
+	files, recursive, err = planTransfer("web-1", []string{src + "/", ":/var/www"}, remoteOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(src, ".htaccess"), filepath.Join(src, "index.html"), ":/var/www"}, files)
+	assert.True(t, recursive)
+	require.Len(t, remoteScripts(), 1)
+	assert.Contains(t, remoteScripts()[0], "mkdir -p -- /var/www")
+
+	mockCmd.reset()
+	files, recursive, err = planTransfer("web-1", []string{src, ":/var/www"}, remoteOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{src, ":/var/www"}, files, "the directory itself")
+	assert.True(t, recursive)
+	assert.Len(t, remoteScripts(), 1)
+
+	_, _, err = planTransfer("web-1", []string{filepath.Join(dir, "empty") + "/", ":x"}, remoteOpts{})
+	assert.EqualError(t, err, "nothing to copy: "+filepath.Join(dir, "empty")+"/ is empty")
+
+	_, _, err = planTransfer("web-1", []string{src, filepath.Join(dir, "other", "site"), ":x"}, remoteOpts{})
+	assert.ErrorContains(t, err, "would both be copied as site")
+}
+
+func TestPlanDownload(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	dir := t.TempDir()
+
+	out := filepath.Join(dir, "out")
+	files, recursive, err := planTransfer("web-1", []string{":logs/", out}, remoteOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{":logs/a.log", ":logs/b c.log", ":logs/.env", out}, files)
+	assert.False(t, recursive)
+	assert.DirExists(t, out)
+	require.Len(t, remoteScripts(), 1, "one lookup for the directory and its entries")
+
+	mockCmd.reset()
+	fresh := filepath.Join(dir, "fresh")
+	files, recursive, err = planTransfer("web-1", []string{":logs", fresh}, remoteOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{":logs", fresh}, files)
+	assert.True(t, recursive)
+	assert.DirExists(t, fresh, "created, so logs lands inside it")
+
+	conf := filepath.Join(dir, "app.conf")
+	files, recursive, err = planTransfer("web-1", []string{":app.conf", conf}, remoteOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{":app.conf", conf}, files)
+	assert.False(t, recursive)
+	assert.NoFileExists(t, conf)
+
+	mockCmd.reset()
+	files, recursive, err = planTransfer("web-1", []string{":app.conf", out}, remoteOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{":app.conf", out}, files)
+	assert.True(t, recursive, "not looked up, so it may be a directory")
+	assert.Empty(t, remoteScripts(), "an existing destination needs no lookup")
+
+	files, _, err = planTransfer("web-1", []string{":app.conf", ":logs/*.log", out}, remoteOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{":app.conf", ":logs/a.log", ":logs/b c.log", out}, files)
+
+	_, _, err = planTransfer("web-1", []string{":empty/", out}, remoteOpts{})
+	assert.EqualError(t, err, "nothing to copy: empty/ is empty")
+
+	_, _, err = planTransfer("web-1", []string{":missing/", out}, remoteOpts{})
+	assert.EqualError(t, err, "no directory on web-1 at missing/")
+	assert.Equal(t, exitTransfer, ExitCode(err))
+
+	_, _, err = planTransfer("web-1", []string{":logs/*.log", ":*.gz", out}, remoteOpts{})
+	assert.EqualError(t, err, "no files on web-1 match *.gz")
+	assert.Equal(t, exitTransfer, ExitCode(err))
+
+	for _, s := range remoteScripts() {
+		assert.False(t, strings.Contains(s, "mkdir"), "downloads create the destination locally")
+	}
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/transport"
+)
+
+var pkgParallel int
+
+// pkgActions are what gt pkg does: update refreshes the package index
+// and lists pending upgrades, upgrade applies them, install adds
+// packages.
+var pkgActions = []string{"update", "upgrade", "install"}
+
+// detectPackageManager sets $pm to the first package manager the host
+// has, and fails with 127 if it has none gt knows.
+const detectPackageManager = `if command -v apt-get >/dev/null 2>&1; then pm=apt
+elif command -v dnf >/dev/null 2>&1; then pm=dnf
+elif command -v yum >/dev/null 2>&1; then pm=yum
+elif command -v pacman >/dev/null 2>&1; then pm=pacman
+elif command -v apk >/dev/null 2>&1; then pm=apk
+else echo "no supported package manager (apt, dnf, yum, pacman, apk)" >&2; exit 127; fi
+`
+
+// pkgScripts are each action's commands per package manager; "$@" is
+// the packages to install. update prints "# <manager>" and then one
+// pending upgrade per line.
+var pkgScripts = map[string]string{
+	"update": `echo "# $pm"
+case $pm in
+apt) $sudo apt-get update -qq >/dev/null && apt list --upgradable 2>/dev/null | sed -n 's,/.*,,p' ;;
+dnf|yum) $sudo $pm -q check-update --refresh 2>/dev/null | awk 'NF==3 && $1 !~ /^Obsoleting/ {print $1}' ;;
+pacman) $sudo pacman -Sy >/dev/null && pacman -Qu | awk '{print $1}' ;;
+apk) $sudo apk update -q >/dev/null && apk version -l '<' | awk 'NR>1 {print $1}' ;;
+esac
+`,
+	"upgrade": `case $pm in
+apt) $sudo env DEBIAN_FRONTEND=noninteractive apt-get -y upgrade ;;
+dnf|yum) $sudo $pm -y upgrade ;;
+pacman) $sudo pacman -Syu --noconfirm ;;
+apk) $sudo apk upgrade ;;
+esac
+`,
+	"install": `case $pm in
+apt) $sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y -- "$@" ;;
+dnf|yum) $sudo $pm install -y "$@" ;;
+pacman) $sudo pacman -S --noconfirm --needed "$@" ;;
+apk) $sudo apk add "$@" ;;
+esac
+`,
+}
+
+// pkgCommand is the remote command for action, as root.
+func pkgCommand(action string, pkgs []string, batch bool) string {
+	script := sudoPrefix(batch) + detectPackageManager + pkgScripts[action]
+	return quoteArgv(append([]string{"sh", "-c", script, "sh"}, pkgs...))
+}
+
+// pendingUpgrades is one host's answer to update.
+type pendingUpgrades struct {
+	alias    string
+	manager  string
+	packages []string
+	err      error
+}
+
+// parsePending reads update's output.
+func parsePending(alias string, out []byte) pendingUpgrades {
+	p := pendingUpgrades{alias: alias}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "# "):
+			p.manager = strings.TrimPrefix(line, "# ")
+		default:
+			p.packages = append(p.packages, line)
+		}
+	}
+	return p
+}
+
+// renderPending writes each host's count of pending upgrades, with the
+// packages themselves when listed.
+func renderPending(w io.Writer, hosts []pendingUpgrades, list bool) {
+	hostWidth := len("HOST")
+	for _, h := range hosts {
+		if n := displayWidth(h.alias); n > hostWidth {
+			hostWidth = n
+		}
+	}
+	symbolColor.Fprintf(w, "%-*s  %-7s  %s\n", hostWidth, "HOST", "MANAGER", "PENDING")
+	total := 0
+	for _, h := range hosts {
+		aliasColor.Fprint(w, h.alias+strings.Repeat(" ", hostWidth-displayWidth(h.alias)+2))
+		if h.err != nil {
+			errorColor.Fprintf(w, "%-7s  %s\n", "-", firstLine(h.err.Error()))
+			continue
+		}
+		fmt.Fprintf(w, "%-7s  ", h.manager)
+		total += len(h.packages)
+		c := userColor
+		if len(h.packages) > 0 {
+			c = warningColor
+		}
+		c.Fprintln(w, len(h.packages))
+		if list {
+			for _, p := range h.packages {
+				fmt.Fprintf(w, "  %s\n", p)
+			}
+		}
+	}
+	if len(hosts) > 1 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%d pending upgrade(s) across %d host(s)\n", total, len(hosts))
+	}
+}
+
+var pkgCmd = &cobra.Command{
+	Use:   "pkg <alias|@group> <update|upgrade|install> [package]...",
+	Short: "Update, upgrade or install packages, whatever the distro",
+	Long: `Run the host's own package manager (apt, dnf, yum, pacman or apk,
+detected on each host) as root, through sudo unless you log in as root:
+
+  gt pkg @web update            # refresh the index, count pending upgrades
+  gt pkg web1 upgrade
+  gt pkg @web install htop jq
+
+update prints a table of pending upgrades per host, listing the
+packages for a single host; refreshing the index takes root or
+passwordless sudo, since its output is read rather than shown. On a
+single host, upgrade and install get a terminal so sudo can ask for a
+password; a group runs in BatchMode with sudo -n, up to --parallel hosts
+at a time, with each line led by the host's alias. gt exits 1 if any
+host failed.`,
+	Args: cobra.MinimumNArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeTargets(cmd, args, toComplete)
+		case 1:
+			return pkgActions, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, action, pkgs := args[0], args[1], args[2:]
+		if _, ok := pkgScripts[action]; !ok {
+			return fmt.Errorf("unknown action %q: want one of %s", action, strings.Join(pkgActions, ", "))
+		}
+		if action == "install" && len(pkgs) == 0 {
+			return fmt.Errorf("install needs at least one package")
+		}
+		if action != "install" && len(pkgs) > 0 {
+			return fmt.Errorf("%s takes no packages", action)
+		}
+		for _, p := range pkgs {
+			if err := transport.ValidateNoFlagPrefix("package", p); err != nil {
+				return err
+			}
+		}
+		aliases, err := expandTarget(target)
+		if err != nil {
+			return err
+		}
+		if err := refuseUntrusted(aliases, "run the package manager"); err != nil {
+			return err
+		}
+		group := isGroupTarget(target)
+		cmd.SilenceUsage = true
+
+		if action == "update" {
+			var mu sync.Mutex
+			hosts := make(map[string]pendingUpgrades, len(aliases))
+			results := fanOut(aliases, pkgParallel, func(alias string) error {
+				// The output is parsed, so there is no terminal for a
+				// password prompt: refreshing needs root or sudo -n.
+				out, err := remoteOutput(alias, "pkg", remoteOpts{batch: group}, pkgCommand(action, nil, true))
+				p := parsePending(alias, out)
+				p.err = err
+				mu.Lock()
+				hosts[alias] = p
+				mu.Unlock()
+				return err
+			}, nil)
+			ordered := make([]pendingUpgrades, len(aliases))
+			for i, alias := range aliases {
+				ordered[i] = hosts[alias]
+			}
+			var out bytes.Buffer
+			renderPending(&out, ordered, !group)
+			if _, err := cmd.OutOrStdout().Write(out.Bytes()); err != nil {
+				return err
+			}
+			return failedHosts(results, 1)
+		}
+
+		if !group {
+			remoteCmd := []string{pkgCommand(action, pkgs, nonInteractive())}
+			return withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: target, Command: remoteCmd}, func() error {
+				if nonInteractive() {
+					return runSSH(target, remoteCmd)
+				}
+				return runWithTerminal(target, remoteCmd)
+			})
+		}
+		results := fanOut(aliases, pkgParallel, func(alias string) error {
+			return execOne(alias, []string{pkgCommand(action, pkgs, true)}, "")
+		}, reportProgress(len(aliases)))
+		renderResults(cmd.OutOrStdout(), results)
+		return failedHosts(results, 1)
+	},
+}
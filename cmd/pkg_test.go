@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPkgCommand(t *testing.T) {
+	install := pkgCommand("install", []string{"htop", "jq"}, true)
+	assert.True(t, strings.HasPrefix(install, "sh -c "))
+	assert.True(t, strings.HasSuffix(install, " sh htop jq"), "packages are passed as arguments, not spliced into the script")
+	assert.Contains(t, install, "apt-get install -y -- \"$@\"")
+	assert.Contains(t, install, "sudo -n")
+	for _, pm := range []string{"apt-get", "dnf", "yum", "pacman", "apk"} {
+		assert.Contains(t, pkgCommand("update", nil, true), pm)
+	}
+	assert.NotContains(t, pkgCommand("upgrade", nil, false), "sudo -n")
+}
+
+func TestParsePending(t *testing.T) {
+	p := parsePending("web-1", []byte("# apt\nopenssl\nlibssl3\n\n"))
+	assert.Equal(t, pendingUpgrades{alias: "web-1", manager: "apt", packages: []string{"openssl", "libssl3"}}, p)
+	assert.Empty(t, parsePending("web-2", []byte("# dnf\n")).packages)
+}
+
+func TestRenderPending(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	renderPending(&out, []pendingUpgrades{
+		{alias: "web-1", manager: "apt", packages: []string{"openssl", "libssl3"}},
+		{alias: "web-2", manager: "dnf"},
+		{alias: "down", err: errors.New("connection to down failed")},
+	}, false)
+	lines := strings.Split(out.String(), "\n")
+	assert.Regexp(t, `^HOST\s+MANAGER\s+PENDING$`, lines[0])
+	assert.Regexp(t, `^web-1\s+apt\s+2$`, lines[1])
+	assert.Regexp(t, `^web-2\s+dnf\s+0$`, lines[2])
+	assert.Regexp(t, `^down\s+-\s+connection to down failed$`, lines[3])
+	assert.Contains(t, out.String(), "2 pending upgrade(s) across 3 host(s)")
+
+	out.Reset()
+	renderPending(&out, []pendingUpgrades{{alias: "web-1", manager: "apt", packages: []string{"openssl"}}}, true)
+	assert.Contains(t, out.String(), "\n  openssl\n")
+}
+
+func TestPkgRejectsBadArgs(t *testing.T) {
+	useWebGroup(t)
+	assert.ErrorContains(t, pkgCmd.RunE(pkgCmd, []string{"web-1", "remove", "vim"}), "unknown action")
+	assert.ErrorContains(t, pkgCmd.RunE(pkgCmd, []string{"web-1", "install"}), "at least one package")
+	assert.ErrorContains(t, pkgCmd.RunE(pkgCmd, []string{"web-1", "update", "vim"}), "takes no packages")
+	assert.Error(t, pkgCmd.RunE(pkgCmd, []string{"web-1", "install", "--force"}))
+}
+
+func TestPkgInstallOnGroup(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+
+	var out bytes.Buffer
+	pkgCmd.SetOut(&out)
+	t.Cleanup(func() { pkgCmd.SetOut(nil) })
+	err := pkgCmd.RunE(pkgCmd, []string{"@web", "install", "htop"})
+	assert.Equal(t, 1, ExitCode(err))
+	assert.Contains(t, out.String(), "2 ok, 1 failed")
+}
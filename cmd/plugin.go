@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/plugin"
+	"gt/pkg/transport"
+)
+
+// pluginPrefix names external commands: "gt jira" runs gt-jira from PATH,
+// the way git finds git-* commands.
+const pluginPrefix = "gt-"
+
+// builtinCommand reports whether name is one of root's own commands,
+// which a plugin can never shadow.
+func builtinCommand(root *cobra.Command, name string) bool {
+	switch name {
+	case "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	for _, c := range root.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// pluginInvocation decides whether args ask for an external command:
+// the first argument is not a flag, not a built-in command, and not a
+// host alias, and gt-<name> is on PATH. Hosts win over plugins so that
+// installing one never changes what "gt web" connects to.
+func pluginInvocation(root *cobra.Command, args []string) (path string, rest []string, ok bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") || builtinCommand(root, args[0]) {
+		return "", nil, false
+	}
+	path, err := lookPath(pluginPrefix + args[0])
+	if err != nil {
+		return "", nil, false
+	}
+	if err := initConfig(); err == nil && knownHost(expandShortcut(args[0])) {
+		return "", nil, false
+	}
+	return path, args[1:], true
+}
+
+// runPlugin runs an external command with gt's stdio. It learns where gt
+// and its SSH config are from the environment.
+func runPlugin(path string, args []string) error {
+	cmd := execCommand(path, args...)
+	cmd.Env = os.Environ()
+	if self, err := os.Executable(); err == nil {
+		cmd.Env = append(cmd.Env, "GT_EXECUTABLE="+self)
+	}
+	if p, err := sshConfigPath(); err == nil {
+		cmd.Env = append(cmd.Env, "GT_SSH_CONFIG="+p)
+	}
+	return runCommand(cmd)
+}
+
+// externalPlugins finds the gt-* executables on PATH, by command name.
+// An earlier PATH entry shadows a later one, as it does for the shell.
+func externalPlugins() map[string]string {
+	found := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasPrefix(name, pluginPrefix) || e.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				ext := strings.ToLower(filepath.Ext(name))
+				if ext != ".exe" && ext != ".bat" && ext != ".cmd" {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			} else if info, err := e.Info(); err != nil || info.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+			name = strings.TrimPrefix(name, pluginPrefix)
+			if _, ok := found[name]; !ok && name != "" {
+				found[name] = filepath.Join(dir, e.Name())
+			}
+		}
+	}
+	return found
+}
+
+// pluginTransport is the registered transport the backend setting
+// selects, or nil for OpenSSH and PuTTY.
+func pluginTransport() plugin.Transport {
+	b := os.Getenv("GT_BACKEND")
+	if b == "" {
+		b = gtCfg.Backend
+	}
+	t, _ := plugin.LookupTransport(b)
+	return t
+}
+
+// transportCommand builds a plugin transport's connection command.
+func transportCommand(t plugin.Transport, alias string, o transport.Options, remoteCmd []string) (*exec.Cmd, error) {
+	argv, err := t.Command(alias, o, remoteCmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("transport %s returned no command", t.Name())
+	}
+	return execCommand(argv[0], argv[1:]...), nil
+}
+
+// transportTransfer builds a plugin transport's copy command, for
+// transports that can copy at all.
+func transportTransfer(t plugin.Transport, alias string, o transport.Options, files []string) (*exec.Cmd, error) {
+	tr, ok := t.(plugin.Transferer)
+	if !ok {
+		return nil, fmt.Errorf("transport %s does not support file transfer", t.Name())
+	}
+	argv, err := tr.TransferCommand(alias, o, files)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("transport %s returned no command", t.Name())
+	}
+	return execCommand(argv[0], argv[1:]...), nil
+}
+
+// validateImported rejects a host that would not survive the round trip
+// through ssh_config: an alias that is not a single plain word, or a
+// value that would start a new line of config.
+func validateImported(h plugin.Host) error {
+	if h.Alias == "" || strings.ContainsAny(h.Alias, " \t\r\n*?!") {
+		return fmt.Errorf("invalid alias %q", h.Alias)
+	}
+	if err := transport.ValidateNoFlagPrefix("alias", h.Alias); err != nil {
+		return err
+	}
+	values := []string{h.HostName, h.User, h.Port}
+	for k, v := range h.Options {
+		values = append(values, k, v)
+	}
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("host %s: value %q spans lines", h.Alias, v)
+		}
+	}
+	return nil
+}
+
+// formatHostBlock renders an imported host as an ssh_config Host block.
+func formatHostBlock(h plugin.Host) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Host %s\n", h.Alias)
+	for _, kv := range [][2]string{{"HostName", h.HostName}, {"User", h.User}, {"Port", h.Port}} {
+		if kv[1] != "" {
+			fmt.Fprintf(&b, "  %s %s\n", kv[0], kv[1])
+		}
+	}
+	keys := make([]string, 0, len(h.Options))
+	for k := range h.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "  %s %s\n", k, h.Options[k])
+	}
+	return b.String()
+}
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List external commands, transports and importers",
+	Long: `List what extends this gt: gt-<name> executables on PATH, run as
+"gt <name>", and the transports and importers compiled into this build
+(see the gt/pkg/plugin package).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		external := externalPlugins()
+		names := make([]string, 0, len(external))
+		for name := range external {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		sections := []struct {
+			title string
+			lines []string
+		}{
+			{"Commands", nil},
+			{"Transports", plugin.Transports()},
+			{"Importers", plugin.Importers()},
+		}
+		for _, name := range names {
+			line := name + "  " + external[name]
+			if builtinCommand(cmd.Root(), name) {
+				line += "  (shadowed by the built-in command)"
+			}
+			sections[0].lines = append(sections[0].lines, line)
+		}
+		empty := true
+		for _, s := range sections {
+			if len(s.lines) == 0 {
+				continue
+			}
+			empty = false
+			symbolColor.Printf("%s:\n", s.title)
+			for _, l := range s.lines {
+				fmt.Printf("  %s\n", l)
+			}
+		}
+		if empty {
+			warningColor.Println("No plugins found")
+		}
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <importer> [args...]",
+	Short: "Print hosts from an importer as ssh_config Host blocks",
+	Long: `Run an importer compiled into this build (see gt plugins) and print
+the hosts it returns as ssh_config Host blocks, ready to review and save
+as an include:
+
+  gt import cmdb --env prod > ~/.ssh/config.d/prod`,
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return plugin.Importers(), cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if args[0] == "-h" || args[0] == "--help" {
+			return cmd.Help()
+		}
+		imp, ok := plugin.LookupImporter(args[0])
+		if !ok {
+			if names := plugin.Importers(); len(names) > 0 {
+				return fmt.Errorf("no importer %q (have %s)", args[0], strings.Join(names, ", "))
+			}
+			return errors.New("this build of gt has no importers")
+		}
+		hosts, err := imp.Import(args[1:])
+		if err != nil {
+			return fmt.Errorf("import %s: %w", args[0], err)
+		}
+		for i, h := range hosts {
+			if err := validateImported(h); err != nil {
+				return fmt.Errorf("import %s: %w", args[0], err)
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Print(formatHostBlock(h))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+
+	"gt/pkg/plugin"
+	"gt/pkg/transport"
+)
+
+// testTransport is a plugin transport that connects through "ssh" so
+// the mock exec can run it.
+type testTransport struct{}
+
+func (testTransport) Name() string { return "test-bastion" }
+func (testTransport) Command(alias string, o transport.Options, remoteCmd []string) ([]string, error) {
+	return append([]string{"ssh", "bastion-for-" + alias}, remoteCmd...), nil
+}
+
+type testImporter struct{}
+
+func (testImporter) Name() string { return "test-cmdb" }
+func (testImporter) Import(args []string) ([]plugin.Host, error) {
+	if len(args) > 0 && args[0] == "--fail" {
+		return nil, errors.New("cmdb down")
+	}
+	return []plugin.Host{
+		{Alias: "web", HostName: "web.example.com", User: "deploy", Options: map[string]string{"ProxyJump": "bastion", "Compression": "yes"}},
+		{Alias: "db", HostName: "db.example.com", Port: "2200"},
+	}, nil
+}
+
+func init() {
+	plugin.RegisterTransport(testTransport{})
+	plugin.RegisterImporter(testImporter{})
+}
+
+// usePluginPath puts a directory holding a gt-<name> script first on
+// PATH.
+func usePluginPath(t *testing.T, names ...string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts are shell scripts")
+	}
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, pluginPrefix+name), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+			t.Fatalf("write plugin: %v", err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestPluginInvocation(t *testing.T) {
+	dir := usePluginPath(t, "jira", "list", "web")
+	t.Setenv("GT_CONFIG", filepath.Join(t.TempDir(), "none.yaml"))
+	origCfgFile := cfgFile
+	t.Cleanup(func() { cfgFile = origCfgFile })
+	cfgFile = filepath.Join(t.TempDir(), "config")
+	writeConfigFile(t, cfgFile, "Host web\n")
+
+	path, rest, ok := pluginInvocation(rootCmd, []string{"jira", "open", "-x"})
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "gt-jira"), path)
+	assert.Equal(t, []string{"open", "-x"}, rest, "the plugin parses its own flags")
+
+	for _, args := range [][]string{
+		{"list"},    // built-in command
+		{"web"},     // host alias
+		{"nothere"}, // no gt-nothere
+		{"--help", "jira"},
+		{},
+	} {
+		_, _, ok := pluginInvocation(rootCmd, args)
+		assert.False(t, ok, "%v", args)
+	}
+}
+
+func TestExternalPlugins(t *testing.T) {
+	dir := usePluginPath(t, "jira")
+	if err := os.WriteFile(filepath.Join(dir, "gt-notexec"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	found := externalPlugins()
+	assert.Equal(t, filepath.Join(dir, "gt-jira"), found["jira"])
+	assert.NotContains(t, found, "notexec")
+}
+
+func TestPluginTransport(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Setenv("GT_BACKEND", "test-bastion")
+	useMockExec(t)
+
+	b, err := activeBackend()
+	assert.NoError(t, err)
+	assert.Equal(t, "test-bastion", b)
+
+	assert.NoError(t, runSSH("web", []string{"uptime"}))
+	assert.Equal(t, "ssh", mockCmd.commands[0])
+	assert.Equal(t, []string{"bastion-for-web", "uptime"}, mockCmd.argLists[0])
+
+	_, err = transferCommand("web", []string{"a", ":/tmp/"}, remoteOpts{})
+	assert.ErrorContains(t, err, "does not support file transfer")
+}
+
+func TestFormatHostBlock(t *testing.T) {
+	hosts, _ := testImporter{}.Import(nil)
+	assert.Equal(t, "Host web\n  HostName web.example.com\n  User deploy\n  Compression yes\n  ProxyJump bastion\n", formatHostBlock(hosts[0]))
+
+	// What gt import prints must parse back to the same hosts.
+	var all strings.Builder
+	for _, h := range hosts {
+		all.WriteString(formatHostBlock(h))
+	}
+	decoded, err := ssh_config.Decode(strings.NewReader(all.String()))
+	assert.NoError(t, err)
+	port, _ := decoded.Get("db", "Port")
+	assert.Equal(t, "2200", port)
+}
+
+func TestValidateImported(t *testing.T) {
+	assert.NoError(t, validateImported(plugin.Host{Alias: "web", HostName: "w"}))
+	for _, h := range []plugin.Host{
+		{Alias: ""},
+		{Alias: "two words"},
+		{Alias: "web-*"},
+		{Alias: "-oProxyCommand=x"},
+		{Alias: "web", HostName: "w\n  ProxyCommand evil"},
+		{Alias: "web", Options: map[string]string{"LocalCommand": "x\ny"}},
+	} {
+		assert.Error(t, validateImported(h), "%+v", h)
+	}
+}
+
+func TestImportCommand(t *testing.T) {
+	assert.NoError(t, importCmd.RunE(importCmd, []string{"test-cmdb"}))
+	assert.ErrorContains(t, importCmd.RunE(importCmd, []string{"test-cmdb", "--fail"}), "cmdb down")
+	assert.ErrorContains(t, importCmd.RunE(importCmd, []string{"nope"}), "have test-cmdb")
+}
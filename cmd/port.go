@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var portLocal bool
+
+// portScript lists the host's listening TCP sockets with the first tool
+// it has, after a "# tool" line saying which: ss (Linux), lsof (macOS and
+// most others) or netstat, which names no processes. Processes of other
+// users only show up for root.
+const portScript = `if command -v ss >/dev/null 2>&1; then echo '# ss'; ss -tlnp 2>/dev/null || ss -tln
+elif command -v lsof >/dev/null 2>&1; then echo '# lsof'; lsof -nP -iTCP -sTCP:LISTEN
+else echo '# netstat'; netstat -an | grep -i listen; fi
+`
+
+// listener is one listening socket.
+type listener struct {
+	port    int
+	address string
+	process string // "name (pid)", or "" when not visible
+}
+
+// splitHostPort cuts the port off a socket address in any of the tools'
+// spellings: 0.0.0.0:22, [::]:22, *:22, 127.0.0.1%lo:53, or netstat's
+// BSD-style *.22 and ::1.631.
+func splitHostPort(addr string) (string, int, bool) {
+	i := strings.LastIndexAny(addr, ":.")
+	if i < 0 {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(addr[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return addr[:i], port, true
+}
+
+// ssProcess reads ss's users:(("nginx",pid=812,fd=6),...) column.
+func ssProcess(field string) string {
+	rest, ok := strings.CutPrefix(field, `users:(("`)
+	if !ok {
+		return ""
+	}
+	name, rest, _ := strings.Cut(rest, `"`)
+	if _, pid, ok := strings.Cut(rest, "pid="); ok {
+		pid, _, _ = strings.Cut(pid, ",")
+		return fmt.Sprintf("%s (%s)", name, pid)
+	}
+	return name
+}
+
+// parseListeners reads portScript's output.
+func parseListeners(out []byte) []listener {
+	var ls []listener
+	tool := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		if t, ok := strings.CutPrefix(line, "# "); ok {
+			tool = t
+			continue
+		}
+		f := strings.Fields(line)
+		var l listener
+		var ok bool
+		switch {
+		case tool == "ss" && len(f) >= 4 && f[0] == "LISTEN":
+			l.address, l.port, ok = splitHostPort(f[3])
+			for _, extra := range f[5:] {
+				if p := ssProcess(extra); p != "" {
+					l.process = p
+				}
+			}
+		case tool == "lsof" && len(f) >= 9 && f[0] != "COMMAND":
+			l.process = fmt.Sprintf("%s (%s)", f[0], f[1])
+			l.address, l.port, ok = splitHostPort(f[8])
+		case tool == "netstat" && len(f) >= 4:
+			l.address, l.port, ok = splitHostPort(f[3])
+		}
+		if ok {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// parsePorts validates the port arguments.
+func parsePorts(args []string) ([]int, error) {
+	ports := make([]int, len(args))
+	for i, a := range args {
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid port %q", a)
+		}
+		ports[i] = n
+	}
+	return ports, nil
+}
+
+// renderPorts writes the remote view: each requested port as listening
+// or closed, with where and by whom. It returns how many were closed.
+func renderPorts(w io.Writer, ports []int, ls []listener) int {
+	symbolColor.Fprintf(w, "%-6s %-10s %-24s %s\n", "PORT", "STATE", "ADDRESS", "PROCESS")
+	closed := 0
+	for _, p := range ports {
+		var addrs, procs []string
+		for _, l := range ls {
+			if l.port != p {
+				continue
+			}
+			addrs = appendUnique(addrs, l.address)
+			if l.process != "" {
+				procs = appendUnique(procs, l.process)
+			}
+		}
+		portColor.Fprintf(w, "%-6d ", p)
+		if len(addrs) == 0 {
+			closed++
+			errorColor.Fprintln(w, "closed")
+			continue
+		}
+		userColor.Fprintf(w, "%-10s ", "listening")
+		fmt.Fprintf(w, "%-24s ", strings.Join(addrs, ", "))
+		if len(procs) == 0 {
+			symbolColor.Fprintln(w, "(not visible)")
+			continue
+		}
+		aliasColor.Fprintln(w, strings.Join(procs, ", "))
+	}
+	return closed
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// probePorts dials each port on alias's HostName from this machine and
+// writes whether it answered. It returns how many did not.
+func probePorts(w io.Writer, alias string, ports []int) (int, error) {
+	r, err := resolveHost(alias)
+	if err != nil {
+		return 0, err
+	}
+	timeout := hostMetaFor(alias).connectTimeout()
+	symbolColor.Fprintf(w, "%-6s %-10s %s\n", "PORT", "STATE", "DETAIL")
+	failed := 0
+	for _, p := range ports {
+		start := time.Now()
+		conn, err := dialTimeout("tcp", net.JoinHostPort(r.Hostname, strconv.Itoa(p)), timeout)
+		portColor.Fprintf(w, "%-6d ", p)
+		if err != nil {
+			failed++
+			state := "closed"
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				state = "filtered"
+			}
+			errorColor.Fprintf(w, "%-10s ", state)
+			fmt.Fprintln(w, firstLine(err.Error()))
+			continue
+		}
+		conn.Close()
+		userColor.Fprintf(w, "%-10s ", "open")
+		fmt.Fprintln(w, formatDuration(time.Since(start).Milliseconds()))
+	}
+	return failed, nil
+}
+
+var portCmd = &cobra.Command{
+	Use:   "port <alias> <port>...",
+	Short: "Check which ports a host is listening on, and what owns them",
+	Long: `Check from the host itself whether it is listening on the given TCP
+ports, and which process owns each, using ss, lsof or netstat, whichever
+it has. Processes of other users are only visible to root.
+
+  gt port web1 80 443 5432
+
+With --local, the ports are instead dialed from this machine, to tell a
+service that is down from one a firewall hides: an open port answers, a
+closed one refuses, a filtered one times out (connect_timeout, default
+5s). gt exits 1 if any port is not listening (or reachable).`,
+	Args: cobra.MinimumNArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeHosts(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		ports, err := parsePorts(args[1:])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		var out bytes.Buffer
+		var down int
+		if portLocal {
+			if down, err = probePorts(&out, alias, ports); err != nil {
+				return err
+			}
+		} else {
+			raw, err := remoteOutput(alias, "port", remoteOpts{}, shellScript(portScript))
+			if err != nil {
+				return err
+			}
+			down = renderPorts(&out, ports, parseListeners(raw))
+		}
+		if _, err := cmd.OutOrStdout().Write(out.Bytes()); err != nil {
+			return err
+		}
+		if down > 0 {
+			return withCode(1, fmt.Errorf("%d of %d ports not listening", down, len(ports)))
+		}
+		return nil
+	},
+}
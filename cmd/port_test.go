@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListenersSS(t *testing.T) {
+	out := `# ss
+State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process
+LISTEN 0      511          0.0.0.0:80        0.0.0.0:*     users:(("nginx",pid=812,fd=6),("nginx",pid=813,fd=6))
+LISTEN 0      128             [::]:22           [::]:*     users:(("sshd",pid=501,fd=4))
+LISTEN 0      4096   127.0.0.53%lo:53        0.0.0.0:*
+`
+	assert.Equal(t, []listener{
+		{port: 80, address: "0.0.0.0", process: "nginx (812)"},
+		{port: 22, address: "[::]", process: "sshd (501)"},
+		{port: 53, address: "127.0.0.53%lo"},
+	}, parseListeners([]byte(out)))
+}
+
+func TestParseListenersLsofAndNetstat(t *testing.T) {
+	lsof := `# lsof
+COMMAND   PID USER   FD   TYPE             DEVICE SIZE/OFF NODE NAME
+postgres 4321 me     7u  IPv6 0x1234567890abcdef      0t0  TCP [::1]:5432 (LISTEN)
+postgres 4321 me     8u  IPv4 0x1234567890abcdee      0t0  TCP 127.0.0.1:5432 (LISTEN)
+`
+	assert.Equal(t, []listener{
+		{port: 5432, address: "[::1]", process: "postgres (4321)"},
+		{port: 5432, address: "127.0.0.1", process: "postgres (4321)"},
+	}, parseListeners([]byte(lsof)))
+
+	netstat := `# netstat
+tcp4       0      0  *.22                   *.*                    LISTEN
+tcp6       0      0  ::1.631                *.*                    LISTEN
+`
+	assert.Equal(t, []listener{{port: 22, address: "*"}, {port: 631, address: "::1"}}, parseListeners([]byte(netstat)))
+}
+
+func TestRenderPorts(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	closed := renderPorts(&out, []int{80, 22, 8080}, []listener{
+		{port: 80, address: "0.0.0.0", process: "nginx (812)"},
+		{port: 80, address: "[::]", process: "nginx (812)"},
+		{port: 22, address: "0.0.0.0"},
+	})
+	assert.Equal(t, 1, closed)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Regexp(t, `^80\s+listening\s+0\.0\.0\.0, \[::\]\s+nginx \(812\)$`, lines[1])
+	assert.Regexp(t, `^22\s+listening\s+0\.0\.0\.0\s+\(not visible\)$`, lines[2])
+	assert.Regexp(t, `^8080\s+closed$`, lines[3])
+}
+
+func TestParsePorts(t *testing.T) {
+	ports, err := parsePorts([]string{"22", "443"})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{22, 443}, ports)
+	for _, bad := range []string{"0", "65536", "http"} {
+		_, err := parsePorts([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestProbePorts(t *testing.T) {
+	useMockExec(t)
+	plainOutput(t)
+	origDial := dialTimeout
+	t.Cleanup(func() { dialTimeout = origDial })
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		switch addr {
+		case "test.example.com:22":
+			return stubConn{}, nil
+		case "test.example.com:5432":
+			return nil, timeoutError{}
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	var out bytes.Buffer
+	failed, err := probePorts(&out, "web", []int{22, 80, 5432})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, failed)
+	assert.Regexp(t, `(?m)^22\s+open\s`, out.String())
+	assert.Regexp(t, `(?m)^80\s+closed\s+connection refused$`, out.String())
+	assert.Regexp(t, `(?m)^5432\s+filtered\s+i/o timeout$`, out.String())
+}
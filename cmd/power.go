@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var (
+	powerYes     bool
+	powerWait    bool
+	powerTimeout time.Duration
+)
+
+// powerPoll is how often --wait probes the SSH port.
+const powerPoll = 2 * time.Second
+
+// powerSleep is time.Sleep, swappable in tests.
+var powerSleep = time.Sleep
+
+// powerCommand is the remote command for reboot or shutdown: shutdown(8),
+// which Linux, the BSDs and macOS share, as root.
+func powerCommand(action string) string {
+	flag := "-h"
+	if action == "reboot" {
+		flag = "-r"
+	}
+	return shellScript(sudoPrefix(false) + "$sudo shutdown " + flag + " now")
+}
+
+// portOpen reports whether addr accepts a TCP connection.
+func portOpen(addr string, timeout time.Duration) bool {
+	conn, err := dialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// waitForReboot polls alias's SSH port at addr until it has gone away
+// and come back, and returns how long it was down. It gives up after
+// timeout.
+func waitForReboot(alias, addr string, timeout time.Duration) (time.Duration, error) {
+	deadline := time.Now().Add(timeout)
+	var down time.Time
+	for time.Now().Before(deadline) {
+		open := portOpen(addr, powerPoll)
+		switch {
+		case !open && down.IsZero():
+			down = time.Now()
+			statusf(warningColor, "%s is down; waiting for it to come back\n", alias)
+		case open && !down.IsZero():
+			return time.Since(down), nil
+		}
+		powerSleep(powerPoll)
+	}
+	if down.IsZero() {
+		return 0, fmt.Errorf("%s never went down within %s", alias, timeout)
+	}
+	return 0, fmt.Errorf("%s not back within %s", alias, timeout)
+}
+
+// runPower is gt reboot and gt shutdown.
+func runPower(cmd *cobra.Command, action, alias string) error {
+	alias, err := hostArg(alias)
+	if err != nil {
+		return err
+	}
+	if powerWait && action != "reboot" {
+		return errors.New("--wait only applies to reboot")
+	}
+	if err := refuseUntrusted([]string{alias}, action); err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+	var r sshconf.Resolved
+	if !powerYes || powerWait {
+		var err error
+		if r, err = resolveHost(alias); err != nil {
+			return err
+		}
+	}
+	if !powerYes {
+		if nonInteractive() {
+			return fmt.Errorf("%s asks first; pass --yes to go ahead without asking", action)
+		}
+		p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+		address := r.User + "@" + r.Hostname
+		if redactEnabled() {
+			address = redactAddress(address)
+		}
+		if !p.confirm(fmt.Sprintf("%s %s (%s)?", action, alias, address), false) {
+			return withCode(1, errors.New("aborted"))
+		}
+	}
+
+	remoteCmd := []string{powerCommand(action)}
+	err = withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: remoteCmd}, func() error {
+		return runWithTerminal(alias, remoteCmd)
+	})
+	// The host going away under ssh is the command working.
+	if err != nil && ExitCode(err) != exitConnection {
+		return err
+	}
+	statusf(symbolColor, "%s: %s issued\n", alias, action)
+	if !powerWait {
+		return nil
+	}
+
+	port := r.Port
+	if port == "" {
+		port = "22"
+	}
+	downtime, err := waitForReboot(alias, net.JoinHostPort(r.Hostname, port), powerTimeout)
+	if err != nil {
+		return withCode(exitConnection, err)
+	}
+	userColor.Fprintf(cmd.OutOrStdout(), "%s is back after %s\n", alias, formatDuration(downtime.Milliseconds()))
+	return nil
+}
+
+var rebootCmd = &cobra.Command{
+	Use:   "reboot <alias>",
+	Short: "Reboot a host, optionally waiting for it to come back",
+	Long: `Reboot a host with shutdown -r now, through sudo unless you log in as
+root. gt asks first; --yes skips the question (and is required with
+--no-input).
+
+With --wait, gt then polls the host's SSH port until it has gone down and
+come back, and reports how long it was down; it gives up after --timeout.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHosts,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPower(cmd, "reboot", args[0])
+	},
+}
+
+var shutdownCmd = &cobra.Command{
+	Use:   "shutdown <alias>",
+	Short: "Shut a host down",
+	Long: `Shut a host down with shutdown -h now, through sudo unless you log in
+as root. gt asks first; --yes skips the question (and is required with
+--no-input).`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHosts,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPower(cmd, "shutdown", args[0])
+	},
+}
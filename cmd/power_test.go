@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPowerCommand(t *testing.T) {
+	assert.Contains(t, powerCommand("reboot"), "$sudo shutdown -r now")
+	assert.Contains(t, powerCommand("shutdown"), "$sudo shutdown -h now")
+}
+
+// useDialSequence answers each dial with the next of states (true for
+// open), repeating the last.
+func useDialSequence(t *testing.T, states ...bool) {
+	t.Helper()
+	origDial, origSleep := dialTimeout, powerSleep
+	t.Cleanup(func() { dialTimeout, powerSleep = origDial, origSleep })
+	powerSleep = func(time.Duration) {}
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		open := states[0]
+		if len(states) > 1 {
+			states = states[1:]
+		}
+		if open {
+			return stubConn{}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+}
+
+func TestWaitForReboot(t *testing.T) {
+	useDialSequence(t, true, false, false, true)
+	_, err := waitForReboot("web", "web:22", time.Minute)
+	assert.NoError(t, err, "up, then down, then back")
+
+	useDialSequence(t, true)
+	_, err = waitForReboot("web", "web:22", 10*time.Millisecond)
+	assert.ErrorContains(t, err, "never went down")
+
+	useDialSequence(t, false)
+	_, err = waitForReboot("web", "web:22", 10*time.Millisecond)
+	assert.ErrorContains(t, err, "not back")
+}
+
+func TestRebootNeedsYesWithoutInput(t *testing.T) {
+	useMockExec(t)
+	useWebGroup(t)
+	useNoInput(t)
+	err := rebootCmd.RunE(rebootCmd, []string{"web-1"})
+	assert.ErrorContains(t, err, "--yes")
+	assert.Empty(t, mockRun("ssh"), "nothing was run")
+}
+
+func TestRebootAndWait(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+	useDialSequence(t, false, true)
+	powerYes, powerWait = true, true
+	t.Cleanup(func() { powerYes, powerWait = false, false })
+
+	var out bytes.Buffer
+	rebootCmd.SetOut(&out)
+	t.Cleanup(func() { rebootCmd.SetOut(nil) })
+	assert.NoError(t, rebootCmd.RunE(rebootCmd, []string{"web-1"}))
+	args := mockRun("ssh")
+	assert.Contains(t, args, "-t", "sudo may ask for a password")
+	assert.Contains(t, args[len(args)-1], "shutdown -r now")
+	assert.Contains(t, out.String(), "web-1 is back after")
+
+	assert.ErrorContains(t, shutdownCmd.RunE(shutdownCmd, []string{"web-1"}), "--wait only applies to reboot")
+}
+
+func TestRebootDroppedConnectionIsSuccess(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useWebGroup(t)
+	powerYes = true
+	t.Cleanup(func() { powerYes = false })
+	assert.NoError(t, rebootCmd.RunE(rebootCmd, []string{"down"}), "ssh losing the host is the reboot working")
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gt/pkg/transport"
+)
+
+// preLoginChecks runs the checks --check-tz and --check-sessions (or the
+// host's settings) enable ahead of an interactive login to alias. The
+// first check starts a ControlMaster that outlives it by a few seconds;
+// the other checks, and the login, ride that connection through the
+// options returned, so checking costs no second handshake or password
+// prompt. When ssh fails to connect at all, that error is returned and
+// there is no login.
+func preLoginChecks(alias string, opts transport.Options) ([]string, error) {
+	tz, sessions := checkTZEnabled(alias), checkSessionsEnabled(alias)
+	if !tz && !sessions || runtime.GOOS == "windows" {
+		return nil, nil // no ControlMaster in Windows' OpenSSH
+	}
+	dir, err := ensureRuntimeDir()
+	if err != nil {
+		debugf(1, "checking %s ahead of the login: %v", alias, err)
+		return nil, nil
+	}
+	mux := []string{"-o", "ControlMaster=auto", "-o", "ControlPath=" + filepath.Join(dir, "c-%C"), "-o", "ControlPersist=10"}
+	if tz {
+		if err := checkClockLocale(alias, opts, mux); err != nil {
+			return nil, err
+		}
+	}
+	if sessions {
+		if err := checkLoggedIn(alias, opts, mux); err != nil {
+			return nil, err
+		}
+	}
+	return mux, nil
+}
+
+// preLoginRun runs script on alias, without a terminal, over the
+// ControlMaster mux sets up, and returns its output. A check that cannot
+// run returns nil and no error, for the login to go ahead without it;
+// only ssh's own failure to connect, exit 255, is an error.
+func preLoginRun(alias string, opts transport.Options, mux []string, script string) ([]byte, error) {
+	o := opts
+	o.Extra = append(append(append([]string(nil), opts.Extra...), mux...), "-T")
+	cmd := withAskpass(sshCommand(transport.SSHArgs(o, alias, []string{shellScript(script)})...), alias)
+	var out strings.Builder
+	cmd.Stdout = &out
+	cmd.Stdin = strings.NewReader("")
+	if err := runCommandLogged(cmd, alias, "ssh"); err != nil {
+		if exitCodeOf(err) == 255 {
+			return nil, err
+		}
+		debugf(1, "checking %s ahead of the login: %v", alias, err)
+		return nil, nil
+	}
+	return []byte(out.String()), nil
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// urlHandlerBundleID is the bundle identifier of the app gt registers
+// for ssh:// on macOS.
+const urlHandlerBundleID = "dev.gt.ssh-handler"
+
+// handlerFile is a file urlHandler writes.
+type handlerFile struct {
+	path, content string
+}
+
+// urlHandler is how a system comes to open ssh:// links with gt: the
+// files to write, then the commands to run. note, when set, is what is
+// left to the user.
+type urlHandler struct {
+	files    []handlerFile
+	commands [][]string
+	note     string
+}
+
+// desktopQuote quotes an argument of a desktop entry's Exec key, as the
+// freedesktop spec reads one: in double quotes, with '"', '`', '$' and
+// '\' escaped.
+func desktopQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace(s) + `"`
+}
+
+// appleScriptString is s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// urlHandlerFor is the handler for goos that runs exe on a link, in a
+// terminal since gt logs in. The link comes after a "--", so one made to
+// look like a flag is still read as the URI:
+//
+//   - windows: the ssh URL protocol under HKEY_CURRENT_USER\Software\Classes,
+//     which Windows opens in a console;
+//   - darwin: a small AppleScript app in ~/Applications that claims ssh://
+//     and runs gt in Terminal, registered with Launch Services. macOS has
+//     no command to make it the default; with duti installed it does, and
+//     otherwise the note says how;
+//   - elsewhere: a desktop entry under $XDG_DATA_HOME/applications, set as
+//     the x-scheme-handler/ssh default with xdg-mime.
+func urlHandlerFor(goos string, getenv func(string) string, home, exe string) urlHandler {
+	switch goos {
+	case "windows":
+		key := `HKCU\Software\Classes\ssh`
+		return urlHandler{commands: [][]string{
+			{"reg", "add", key, "/ve", "/d", "URL:SSH Protocol", "/f"},
+			{"reg", "add", key, "/v", "URL Protocol", "/d", "", "/f"},
+			{"reg", "add", key + `\shell\open\command`, "/ve", "/d", `"` + exe + `" -- "%1"`, "/f"},
+		}}
+	case "darwin":
+		app := filepath.Join(home, "Applications", "gt SSH.app")
+		plist := filepath.Join(app, "Contents", "Info.plist")
+		h := urlHandler{
+			commands: [][]string{
+				{"osacompile", "-o", app,
+					"-e", "on open location u",
+					"-e", `tell application "Terminal"`,
+					"-e", "activate",
+					"-e", "do script quoted form of " + appleScriptString(exe) + ` & " -- " & quoted form of u`,
+					"-e", "end tell",
+					"-e", "end open location"},
+				{"plutil", "-replace", "CFBundleIdentifier", "-string", urlHandlerBundleID, plist},
+				{"plutil", "-replace", "CFBundleURLTypes", "-json", `[{"CFBundleURLName":"SSH","CFBundleURLSchemes":["ssh"]}]`, plist},
+				{"/System/Library/Frameworks/CoreServices.framework/Frameworks/LaunchServices.framework/Support/lsregister", "-f", app},
+			},
+		}
+		if _, err := lookPath("duti"); err == nil {
+			h.commands = append(h.commands, []string{"duti", "-s", urlHandlerBundleID, "ssh"})
+		} else {
+			h.note = "If ssh:// links still open elsewhere, install duti (brew install duti) and run: duti -s " + urlHandlerBundleID + " ssh"
+		}
+		return h
+	}
+	data := getenv("XDG_DATA_HOME")
+	if data == "" {
+		data = filepath.Join(home, ".local", "share")
+	}
+	return urlHandler{
+		files: []handlerFile{{filepath.Join(data, "applications", "gt-ssh.desktop"), `[Desktop Entry]
+Type=Application
+Name=gt
+Comment=Open ssh:// links with gt
+Exec=` + desktopQuote(exe) + ` -- %u
+Terminal=true
+NoDisplay=true
+MimeType=x-scheme-handler/ssh;
+`}},
+		commands: [][]string{{"xdg-mime", "default", "gt-ssh.desktop", "x-scheme-handler/ssh"}},
+	}
+}
+
+// registerURLHandler writes h's files and runs its commands.
+func registerURLHandler(h urlHandler) error {
+	for _, f := range h.files {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(f.path, []byte(f.content), 0o644); err != nil {
+			return err
+		}
+		debugf(1, "wrote %s", f.path)
+	}
+	for _, argv := range h.commands {
+		c := execCommand(argv[0], argv[1:]...)
+		c.Stdout = os.Stderr
+		c.Stderr = os.Stderr
+		debugf(1, "exec: %s", quoteArgv(c.Args))
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(argv[0]), err)
+		}
+	}
+	return nil
+}
+
+var protocolCmd = &cobra.Command{
+	Use:   "protocol",
+	Short: "Open ssh:// links with gt",
+	Long: `gt connects to ssh://[user@]host[:port] URIs as it does to aliases:
+
+  gt ssh://deploy@web1.example.com:2222
+
+A URI whose host and port are a Host block's HostName and Port (22 when
+unset) connects to that alias, with all its settings and gt's; the URI's
+user, when not the block's, is used as -u would be. Any other host is
+connected to as ssh would from the command line, once confirmed: a link
+is a page's to write, so gt shows where it leads and asks first. A URI
+opens a login, and takes no command or files after it.
+
+gt protocol register makes gt what the system opens ssh:// links with,
+so links in wikis and dashboards open a gt session.`,
+}
+
+var protocolRegisterCmd = &cobra.Command{
+	Use:   "register",
+	Short: "Make gt the system's handler for ssh:// links",
+	Long: `Register gt to open ssh:// links, in a terminal, for the current user:
+
+  Linux and BSD  a desktop entry, gt-ssh.desktop, set as the default for
+                 x-scheme-handler/ssh with xdg-mime
+  macOS          an app, ~/Applications/gt SSH.app, that runs gt in
+                 Terminal (made the default with duti when installed)
+  Windows        the ssh URL protocol in the user's registry
+
+The path of this gt is what the handler runs; register again after moving
+it. Running it again is harmless.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		h := urlHandlerFor(runtime.GOOS, os.Getenv, home, gtExecutable())
+		if err := registerURLHandler(h); err != nil {
+			return err
+		}
+		statusf(symbolColor, "gt now opens ssh:// links\n")
+		if h.note != "" {
+			statusf(hintColor, "%s\n", h.note)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDesktopQuote(t *testing.T) {
+	assert.Equal(t, `"/usr/local/bin/gt"`, desktopQuote("/usr/local/bin/gt"))
+	assert.Equal(t, "\"/opt/my \\\"gt\\\"/\\$bin\\\\\\`x\\`\"", desktopQuote("/opt/my \"gt\"/$bin\\`x`"))
+}
+
+func TestURLHandlerFor(t *testing.T) {
+	noEnv := func(string) string { return "" }
+
+	h := urlHandlerFor("linux", noEnv, "/home/me", "/usr/local/bin/gt")
+	require.Len(t, h.files, 1)
+	assert.Equal(t, filepath.Join("/home/me", ".local", "share", "applications", "gt-ssh.desktop"), h.files[0].path)
+	assert.Contains(t, h.files[0].content, "Exec=\"/usr/local/bin/gt\" -- %u\n")
+	assert.Contains(t, h.files[0].content, "Terminal=true\n")
+	assert.Contains(t, h.files[0].content, "MimeType=x-scheme-handler/ssh;\n")
+	assert.Equal(t, [][]string{{"xdg-mime", "default", "gt-ssh.desktop", "x-scheme-handler/ssh"}}, h.commands)
+
+	h = urlHandlerFor("freebsd", func(k string) string {
+		if k == "XDG_DATA_HOME" {
+			return "/data"
+		}
+		return ""
+	}, "/home/me", "/usr/local/bin/gt")
+	assert.Equal(t, filepath.Join("/data", "applications", "gt-ssh.desktop"), h.files[0].path)
+
+	h = urlHandlerFor("windows", noEnv, `C:\Users\me`, `C:\Tools\gt.exe`)
+	assert.Empty(t, h.files)
+	require.Len(t, h.commands, 3)
+	assert.Equal(t, []string{"reg", "add", `HKCU\Software\Classes\ssh\shell\open\command`, "/ve", "/d", `"C:\Tools\gt.exe" -- "%1"`, "/f"}, h.commands[2])
+
+	orig := lookPath
+	t.Cleanup(func() { lookPath = orig })
+	lookPath = func(string) (string, error) { return "", os.ErrNotExist }
+	h = urlHandlerFor("darwin", noEnv, "/Users/me", "/opt/homebrew/bin/gt")
+	assert.Equal(t, "osacompile", h.commands[0][0])
+	assert.Contains(t, h.commands[0], `do script quoted form of "/opt/homebrew/bin/gt" & " -- " & quoted form of u`)
+	assert.Contains(t, h.commands[2], filepath.Join("/Users/me", "Applications", "gt SSH.app", "Contents", "Info.plist"))
+	assert.Contains(t, h.note, "duti -s "+urlHandlerBundleID+" ssh")
+
+	lookPath = func(string) (string, error) { return "/opt/homebrew/bin/duti", nil }
+	h = urlHandlerFor("darwin", noEnv, "/Users/me", "/opt/homebrew/bin/gt")
+	assert.Equal(t, []string{"duti", "-s", urlHandlerBundleID, "ssh"}, h.commands[len(h.commands)-1])
+	assert.Empty(t, h.note)
+}
+
+func TestRegisterURLHandler(t *testing.T) {
+	useMockExec(t)
+	dir := t.TempDir()
+	desktop := filepath.Join(dir, "applications", "gt-ssh.desktop")
+	h := urlHandlerFor("linux", func(string) string { return dir }, "/home/me", "/usr/local/bin/gt")
+	require.NoError(t, registerURLHandler(h))
+
+	data, err := os.ReadFile(desktop)
+	require.NoError(t, err)
+	assert.Equal(t, h.files[0].content, string(data))
+	require.Len(t, mockCmd.commands, 1)
+	assert.Equal(t, "xdg-mime", mockCmd.commands[0])
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"gt/pkg/sshconf"
+)
+
+var pruneYes bool
+
+// expiredHosts are the hosts of gt's config whose expiry has passed at
+// now, sorted. Team hosts are the team's to remove.
+func expiredHosts(now time.Time) []string {
+	var expired []string
+	for alias, m := range gtCfg.Hosts {
+		if m.expired(now) {
+			expired = append(expired, alias)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// withoutHosts is a config with aliases taken out of its Host lines, and
+// a block left naming nothing dropped along with the comments above it.
+// found lists the aliases it had.
+func withoutHosts(data []byte, aliases map[string]bool) (out []byte, found []string) {
+	preamble, blocks := parseConfigBlocks(data)
+	var b strings.Builder
+	b.WriteString(preamble)
+	for _, cb := range blocks {
+		var keep []string
+		for _, p := range cb.patterns {
+			if aliases[p] {
+				found = append(found, p)
+			} else {
+				keep = append(keep, p)
+			}
+		}
+		switch {
+		case len(keep) == len(cb.patterns):
+			b.WriteString(cb.text)
+		case len(keep) > 0:
+			b.WriteString(rewriteHostLine(cb.text, keep))
+		}
+	}
+	return []byte(b.String()), found
+}
+
+// rewriteHostLine replaces the patterns of the Host line in a block's
+// text, keeping its indentation.
+func rewriteHostLine(text string, patterns []string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if sshconf.Keyword(line) == "host" {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			lines[i] = indent + "Host " + strings.Join(patterns, " ") + "\n"
+			break
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// planPrune works out the edits that take aliases out of every file of
+// the loaded config, encrypted includes too, which are written encrypted
+// again (see readConfigEdit). kept lists the aliases no file has a Host line for.
+func planPrune(aliases []string) (*configSplit, error) {
+	want := map[string]bool{}
+	for _, a := range aliases {
+		want[a] = true
+	}
+	seen := map[string]bool{}
+	plan := &configSplit{}
+	for _, file := range loadedFiles {
+		e, err := readConfigEdit(file)
+		if err != nil {
+			return nil, err
+		}
+		out, found := withoutHosts(e.data, want)
+		if len(found) == 0 {
+			continue
+		}
+		for _, a := range found {
+			seen[a] = true
+		}
+		plan.files = append(plan.files, splitFile{e, out})
+	}
+	for _, a := range aliases {
+		if !seen[a] {
+			plan.kept = append(plan.kept, a)
+		}
+	}
+	return plan, nil
+}
+
+var configPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove expired, unused or unreachable hosts",
+	Long: `Remove every host whose expiry in gt's config has passed, as gt add
+--ttl sets it: its alias goes from the Host line of each file of the SSH
+config that names it, the block with it when that leaves the line empty,
+and its entry goes from gt's config.
+
+--unused AGE also offers the hosts gt's connection log has no connection
+to in AGE, such as 180d or 720h, a host never connected to included.
+--unreachable offers those whose HostName and fallback addresses all fail
+to resolve in DNS, or refuse or time out a TCP connection in each of
+--probes rounds of probes; hosts behind a ProxyJump or ProxyCommand are
+not probed. Each of these is asked about one at a time.
+
+The change is shown as a diff first and written once you agree to it;
+--yes removes every host found without asking, for a cron job.`,
+	Example: `  gt config prune
+  gt config prune --unreachable --unused 180d`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pruneUnused != "" {
+			if _, err := parseAge(pruneUnused); err != nil {
+				return fmt.Errorf("--unused: %w", err)
+			}
+		}
+		if pruneProbes < 1 {
+			return errors.New("--probes must be at least 1")
+		}
+		cmd.SilenceUsage = true
+		stale, err := staleHosts(time.Now())
+		if err != nil {
+			return err
+		}
+		if len(stale) == 0 {
+			statusf(symbolColor, "No hosts to prune\n")
+			return nil
+		}
+		if !pruneYes && nonInteractive() {
+			return errors.New("gt config prune asks before writing; pass --yes to write without asking")
+		}
+		p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+		var remove []string
+		for _, h := range stale {
+			if h.expired || pruneYes || p.confirm(fmt.Sprintf("Remove %s (%s)?", h.alias, h.reason), false) {
+				remove = append(remove, h.alias)
+			}
+		}
+		if len(remove) == 0 {
+			return nil
+		}
+		plan, err := planPrune(remove)
+		if err != nil {
+			return err
+		}
+		for _, a := range plan.kept {
+			warningColor.Fprintf(os.Stderr, "%s has no Host line in the SSH config; dropping its entry in gt's config only\n", a)
+		}
+		if err := plan.preview(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+		if !pruneYes && !p.confirm(fmt.Sprintf("Remove %s?", strings.Join(remove, ", ")), false) {
+			return withCode(1, errors.New("aborted"))
+		}
+		if err := plan.write(); err != nil {
+			return err
+		}
+		err = editGTHosts(func(hosts *yaml.Node) (bool, error) {
+			changed := false
+			for _, a := range remove {
+				changed = deleteMappingKey(hosts, a) || changed
+			}
+			return changed, nil
+		})
+		if err != nil {
+			return fmt.Errorf("removed from the SSH config, but not from gt's config: %w", err)
+		}
+		statusf(symbolColor, "Removed %d host(s)\n", len(remove))
+		return nil
+	},
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithoutHosts(t *testing.T) {
+	data := []byte("Host keep\n  User a\n\n# the review app\nHost pr-1\n  HostName 10.0.0.1\n\n  Host pr-2 shared\n  User b\n")
+	out, found := withoutHosts(data, map[string]bool{"pr-1": true, "pr-2": true})
+	assert.Equal(t, []string{"pr-1", "pr-2"}, found)
+	assert.Equal(t, "Host keep\n  User a\n\n  Host shared\n  User b\n", string(out))
+
+	out, found = withoutHosts(data, map[string]bool{"other": true})
+	assert.Empty(t, found)
+	assert.Equal(t, string(data), string(out))
+}
+
+func TestConfigPrune(t *testing.T) {
+	useWebGroup(t)
+	plainOutput(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	dir := t.TempDir()
+	gtPath := filepath.Join(dir, "config.yaml")
+	t.Setenv("GT_CONFIG", gtPath)
+	writeConfigFile(t, gtPath, "hosts:\n  old:\n    expires: 2020-01-01T00:00:00Z\n  gone:\n    expires: 2020-01-01T00:00:00Z\n  new:\n    expires: 2999-01-01T00:00:00Z\n  web-1:\n    groups: [web]\n")
+	var err error
+	gtCfg, err = loadGTConfig(gtPath)
+	require.NoError(t, err)
+	main, include := filepath.Join(dir, "ssh_config"), filepath.Join(dir, "ci.conf")
+	writeConfigFile(t, main, "Host web-1\n\nHost new\n")
+	writeConfigFile(t, include, "Host old\n  HostName 10.0.0.1\n")
+	origFiles, origYes := loadedFiles, pruneYes
+	t.Cleanup(func() { loadedFiles, pruneYes = origFiles, origYes })
+	loadedFiles = []string{main, include}
+
+	assert.Equal(t, []string{"gone", "old"}, expiredHosts(time.Now()))
+	pruneYes = true
+	require.NoError(t, configPruneCmd.RunE(configPruneCmd, nil))
+
+	data, _ := os.ReadFile(include)
+	assert.Equal(t, "", string(data))
+	data, _ = os.ReadFile(main)
+	assert.Equal(t, "Host web-1\n\nHost new\n", string(data))
+	data, _ = os.ReadFile(gtPath)
+	assert.Equal(t, "hosts:\n  new:\n    expires: 2999-01-01T00:00:00Z\n  web-1:\n    groups: [web]\n", string(data))
+	assert.Empty(t, expiredHosts(time.Now()))
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/transport"
+)
+
+// globQuote quotes a remote path for sh, leaving its wildcards (*, ?
+// and [...] classes) live.
+func globQuote(p string) string {
+	var b strings.Builder
+	lit := func(s string) {
+		if s != "" {
+			b.WriteString("'" + strings.ReplaceAll(s, "'", `'\''`) + "'")
+		}
+	}
+	start := 0
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '*', '?', '[', ']':
+			lit(p[start:i])
+			b.WriteByte(p[i])
+			start = i + 1
+			if p[i] == '[' && i+1 < len(p) && (p[i+1] == '!' || p[i+1] == '^') {
+				i++
+				b.WriteByte(p[i])
+				start = i + 1
+			}
+		}
+	}
+	lit(p[start:])
+	return b.String()
+}
+
+// hasGlob reports whether p has a wildcard for the remote shell.
+func hasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// remoteMatch is a path on the host that a source matched.
+type remoteMatch struct {
+	path string
+	dir  bool
+}
+
+// globScript prints every existing match of each pattern as
+// "N\tT\tpath\0", N being the pattern's index and T "d" for a directory,
+// "f" for anything else. A pattern that matches nothing prints nothing,
+// rather than itself as the shell would.
+func globScript(patterns []string) string {
+	var b strings.Builder
+	for i, p := range patterns {
+		fmt.Fprintf(&b, "for f in %s; do if [ -d \"$f\" ]; then t=d; elif [ -e \"$f\" ] || [ -L \"$f\" ]; then t=f; else continue; fi; printf '%d\\t%%s\\t%%s\\0' \"$t\" \"$f\"; done\n", globQuote(p), i)
+	}
+	return shellScript(b.String())
+}
+
+// parseGlobMatches splits globScript's output into the matches of each
+// of n patterns.
+func parseGlobMatches(out []byte, n int) [][]remoteMatch {
+	matches := make([][]remoteMatch, n)
+	for _, rec := range bytes.Split(out, []byte{0}) {
+		f := strings.SplitN(string(rec), "\t", 3)
+		if len(f) != 3 {
+			continue
+		}
+		if i, err := strconv.Atoi(f[0]); err == nil && i >= 0 && i < n {
+			matches[i] = append(matches[i], remoteMatch{path: f[2], dir: f[1] == "d"})
+		}
+	}
+	return matches
+}
+
+// matchRemote finds what each pattern matches on alias, in one quick
+// connection. Expanding wildcards there, rather than handing them to
+// scp, keeps them from depending on scp's protocol and quoting.
+func matchRemote(alias string, patterns []string, opts remoteOpts) ([][]remoteMatch, error) {
+	out, err := remoteOutput(alias, "glob", opts, globScript(patterns))
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s on %s: %w", strings.Join(patterns, " "), alias, err)
+	}
+	return parseGlobMatches(out, len(patterns)), nil
+}
+
+var pullCmd = &cobra.Command{
+	Use:   "pull <alias> <:remote>... <local>",
+	Short: "Download files from a host",
+	Long: `Copy files from a host to this machine, as gt -s does for a download:
+the remote sources start with ':'.
+
+  gt pull web1 ':/var/log/app/*.log' ./logs/
+
+Wildcards in a remote source are expanded on the host before the copy, by
+its shell, so they match the same files whatever the engine; quote them so
+the local shell leaves them alone. A pattern that matches nothing fails.
+
+A remote directory is copied as itself, ":dir" landing as local/dir; with a
+trailing slash, ":dir/", what is in it is copied into local instead, as
+rsync does. local is created if a directory is copied into it.
+
+--dry-run lists the files that would be copied, with sizes and where they
+would land, from one read-only listing on the host, and copies nothing.`,
+	Args: cobra.MinimumNArgs(3),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeHosts(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		files := args[1:]
+		if err := transport.ValidateSCPPaths(files); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(files[0], ":") {
+			return errors.New("gt pull only downloads: the sources must be remote (':path') and the destination local")
+		}
+		cmd.SilenceUsage = true
+		if transferDryRun {
+			return dryRun(cmd.OutOrStdout(), alias, files)
+		}
+		return withHooks(hookPreTransfer, hookPostTransfer, hookEvent{Alias: alias, Files: files}, func() error {
+			return runSCP(alias, files)
+		})
+	},
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobQuote(t *testing.T) {
+	assert.Equal(t, `'/var/log/app/'*'.log'`, globQuote("/var/log/app/*.log"))
+	assert.Equal(t, `'my dir/'[!'.']*`, globQuote("my dir/[!.]*"))
+	assert.Equal(t, `'it'\''s'?`, globQuote("it's?"))
+	assert.Equal(t, `'$HOME/x'`, globQuote("$HOME/x"))
+}
+
+func TestGlobScriptRuns(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b c.log", "skip.txt"} {
+		require.NoError(t, os.WriteFile(dir+"/"+name, nil, 0o644))
+	}
+	require.NoError(t, os.Mkdir(dir+"/sub", 0o755))
+	script := globScript([]string{dir + "/*.log", dir + "/*.none", dir + "/[!ab]*", dir})
+	out, err := exec.Command("sh", "-c", script).Output()
+	require.NoError(t, err)
+	assert.Equal(t, [][]remoteMatch{
+		{{path: dir + "/a.log"}, {path: dir + "/b c.log"}},
+		nil,
+		{{path: dir + "/skip.txt"}, {path: dir + "/sub", dir: true}},
+		{{path: dir, dir: true}},
+	}, parseGlobMatches(out, 4))
+}
+
+func TestPullExpandsGlobsForSCP(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+
+	require.NoError(t, pullCmd.RunE(pullCmd, []string{"web-1", ":logs/*.log", "./out"}))
+	args := mockRun("scp")
+	assert.Equal(t, []string{"web-1:logs/a.log", "web-1:logs/b c.log", "./out"}, args[len(args)-3:])
+
+	assert.ErrorContains(t, pullCmd.RunE(pullCmd, []string{"web-1", "local", ":remote"}), "only downloads")
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/transport"
+)
+
+var pushParallel int
+
+// hostResult is the outcome of one host's part in a fan-out.
+type hostResult struct {
+	alias    string
+	err      error
+	duration time.Duration
+	// skipped hosts were never started, because the run was aborted.
+	skipped bool
+}
+
+// fanOut runs fn for every alias, at most parallel at a time, and calls
+// done (serialized) as each host finishes, for live progress. Results
+// keep the order of aliases.
+func fanOut(aliases []string, parallel int, fn func(alias string) error, done func(hostResult)) []hostResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]hostResult, len(aliases))
+	sem := make(chan struct{}, parallel)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, alias := range aliases {
+		wg.Add(1)
+		go func(i int, alias string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			start := time.Now()
+			err := fn(alias)
+			results[i] = hostResult{alias: alias, err: err, duration: time.Since(start)}
+			if done != nil {
+				mu.Lock()
+				done(results[i])
+				mu.Unlock()
+			}
+		}(i, alias)
+	}
+	wg.Wait()
+	return results
+}
+
+// pushOne uploads files to alias in BatchMode, with scp's output kept
+// for the error rather than interleaved with every other host's. It runs
+// the transfer hooks and is audit-logged like gt -s.
+func pushOne(alias string, files []string) error {
+	return withHooks(hookPreTransfer, hookPostTransfer, hookEvent{Alias: alias, Files: files}, func() error {
+		opts := remoteOpts{batch: true}.withFallback(alias)
+		if err := ensureSpace(alias, files, opts); err != nil {
+			return err
+		}
+		if transferEngine == "tar" {
+			return tarTransfer(alias, files, opts, func(cmd *exec.Cmd) error {
+				return runPush(alias, cmd)
+			})
+		}
+		files, cleanup, err := prefilter(files)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		cmd, err := transferCommand(alias, files, opts)
+		if err != nil {
+			return err
+		}
+		return runPush(alias, cmd)
+	})
+}
+
+// runPush runs one host's transfer command, with its output kept for the
+// error.
+func runPush(alias string, cmd *exec.Cmd) error {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	debugf(1, "exec: %s", quoteArgv(cmd.Args))
+	start := time.Now()
+	err := runTracked(cmd, false)
+	logConnection(alias, "push", start, err)
+	if err = classifyRun(alias, "scp", err, output.String()); err != nil {
+		if msg := lastLine(output.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return err
+}
+
+// lastLine is the last non-empty line of s: the one a failing tool ends
+// with, which says why.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// renderResults writes the summary table of a fan-out: every host with
+// its outcome and time, then the totals.
+func renderResults(w io.Writer, results []hostResult) {
+	hostWidth := len("HOST")
+	for _, r := range results {
+		if n := displayWidth(r.alias); n > hostWidth {
+			hostWidth = n
+		}
+	}
+	pad := func(s string, n int) string { return s + strings.Repeat(" ", n-displayWidth(s)+2) }
+
+	symbolColor.Fprintf(w, "%s%s%s%s\n", pad("HOST", hostWidth), pad("RESULT", 7), pad("TIME", 6), "ERROR")
+	var ok, failed, skipped int
+	for _, r := range results {
+		aliasColor.Fprint(w, pad(r.alias, hostWidth))
+		switch {
+		case r.skipped:
+			skipped++
+			warningColor.Fprintln(w, "skipped")
+			continue
+		case r.err != nil:
+			failed++
+			errorColor.Fprint(w, pad("failed", 7))
+		default:
+			ok++
+			userColor.Fprint(w, pad("ok", 7))
+		}
+		portColor.Fprint(w, pad(formatDuration(r.duration.Milliseconds()), 6))
+		if r.err != nil {
+			errorColor.Fprint(w, firstLine(r.err.Error()))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w)
+	summary := fmt.Sprintf("%d ok", ok)
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	c := userColor
+	if failed+skipped > 0 {
+		c = errorColor
+	}
+	c.Fprintln(w, summary)
+}
+
+// failedHosts is the error for a fan-out with failures, or nil. Skipped
+// hosts count as failed: the work was not done there.
+func failedHosts(results []hostResult, code int) error {
+	failed := 0
+	for _, r := range results {
+		if r.err != nil || r.skipped {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return withCode(code, fmt.Errorf("%d of %d hosts failed", failed, len(results)))
+}
+
+var pushCmd = &cobra.Command{
+	Use:   "push <@group|alias> <local>... <:remote>",
+	Short: "Upload the same files to every host in a group",
+	Long: `Copy local files to every host in a group at once, in gt -s's colon
+shorthand: the destination starts with ':'.
+
+  gt push @web app.conf :/etc/app/
+
+Up to --parallel hosts are copied to at a time, in BatchMode so a host that
+would prompt fails instead of stalling the rest. Each host is reported as
+it finishes, then a table lists every host's result. Transfers run the
+pre- and post-transfer hooks and are audit-logged per host. gt exits 74
+(transfer failed) if any host failed. --engine sftp copies with sftp
+instead of scp, --engine tar as one tar stream per host.
+
+A directory source is copied as itself, "dir" landing as DEST/dir; with a
+trailing slash, "dir/", what is in it is copied into DEST instead, as rsync
+does. DEST is created on each host if missing.
+
+--dry-run lists what each host would get, with sizes, and copies nothing;
+it reads only the local files.`,
+	Args: cobra.MinimumNArgs(3),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeTargets(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args[1:]
+		if err := transport.ValidateSCPPaths(files); err != nil {
+			return err
+		}
+		if strings.HasPrefix(files[0], ":") {
+			return errors.New("gt push only uploads: the destination must be remote (':path') and the sources local")
+		}
+		aliases, err := expandTarget(args[0])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		if transferDryRun {
+			for _, alias := range aliases {
+				if err := dryRun(cmd.OutOrStdout(), alias, files); err != nil {
+					return fmt.Errorf("%s: %w", alias, err)
+				}
+			}
+			return nil
+		}
+
+		statusf(symbolColor, "Pushing %d file(s) to %d host(s)\n", len(files)-1, len(aliases))
+		results := fanOut(aliases, pushParallel, func(alias string) error {
+			return pushOne(alias, files)
+		}, reportProgress(len(aliases)))
+		renderResults(cmd.OutOrStdout(), results)
+		return failedHosts(results, exitTransfer)
+	},
+}
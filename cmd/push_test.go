@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanOutBoundsParallelism(t *testing.T) {
+	var running, peak int32
+	aliases := []string{"a", "b", "c", "d", "e", "f"}
+	var finished []string
+	results := fanOut(aliases, 2, func(alias string) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if alias == "c" {
+			return errors.New("boom")
+		}
+		return nil
+	}, func(r hostResult) { finished = append(finished, r.alias) })
+
+	assert.LessOrEqual(t, peak, int32(2))
+	assert.Len(t, finished, len(aliases))
+	for i, r := range results {
+		assert.Equal(t, aliases[i], r.alias, "results keep the target order")
+	}
+	assert.Error(t, results[2].err)
+	assert.Equal(t, exitTransfer, ExitCode(failedHosts(results, exitTransfer)))
+	assert.NoError(t, failedHosts(results[:2], exitTransfer))
+}
+
+func TestPushToGroup(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+
+	var out bytes.Buffer
+	pushCmd.SetOut(&out)
+	t.Cleanup(func() { pushCmd.SetOut(nil) })
+	err := pushCmd.RunE(pushCmd, []string{"@web", "app.conf", ":/etc/app/"})
+	assert.Equal(t, exitTransfer, ExitCode(err))
+	assert.ErrorContains(t, err, "1 of 3 hosts failed")
+
+	var dests []string
+	for i, c := range mockCmd.commands {
+		if c == "scp" {
+			args := mockCmd.argLists[i]
+			assert.Contains(t, args, "BatchMode=yes")
+			dests = append(dests, args[len(args)-1])
+		}
+	}
+	assert.ElementsMatch(t, []string{"down:/etc/app/", "web-1:/etc/app/", "web-2:/etc/app/"}, dests)
+
+	table := out.String()
+	assert.Regexp(t, `down\s+failed\s+\S+\s+connection to down failed`, table)
+	assert.Contains(t, table, "Connection refused")
+	assert.Regexp(t, `web-1\s+ok`, table)
+	assert.Contains(t, table, "2 ok, 1 failed")
+}
+
+func TestPushRejectsDownloads(t *testing.T) {
+	useWebGroup(t)
+	err := pushCmd.RunE(pushCmd, []string{"@web", ":/etc/app.conf", "."})
+	assert.ErrorContains(t, err, "only uploads")
+}
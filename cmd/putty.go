@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"gt/pkg/plugin"
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
+)
+
+// Backends gt can hand a connection to. OpenSSH is the default and the
+// reason gt exists; PuTTY's plink/pscp are a fallback for Windows
+// machines without Win32-OpenSSH.
+const (
+	backendAuto    = "auto"
+	backendOpenSSH = "openssh"
+	backendPuTTY   = "putty"
+)
+
+// lookPath is exec.LookPath, swappable in tests.
+var lookPath = exec.LookPath
+
+// activeBackend resolves GT_BACKEND, then backend in gt's config. "auto"
+// (the default) means OpenSSH, except on Windows when ssh is not on PATH
+// but plink is.
+func activeBackend() (string, error) {
+	b := os.Getenv("GT_BACKEND")
+	if b == "" {
+		b = gtCfg.Backend
+	}
+	switch b {
+	case "", backendAuto:
+		if runtime.GOOS == "windows" {
+			if _, err := lookPath("ssh"); err != nil {
+				if _, err := lookPath("plink"); err == nil {
+					return backendPuTTY, nil
+				}
+			}
+		}
+		return backendOpenSSH, nil
+	case backendOpenSSH, backendPuTTY:
+		return b, nil
+	default:
+		if _, ok := plugin.LookupTransport(b); ok {
+			return b, nil
+		}
+		return "", fmt.Errorf("unknown backend %q (want auto, openssh or putty)", b)
+	}
+}
+
+// usePuTTY reports whether connections go through plink/pscp. An invalid
+// backend setting has already been reported by initConfig.
+func usePuTTY() bool {
+	b, _ := activeBackend()
+	return b == backendPuTTY
+}
+
+// puttyResolved reads user, hostname and port from gt's own parse of
+// the config. This is the PuTTY backend's stand-in for ssh -G: plink
+// knows nothing about ssh_config, so gt has to carry the values over. It
+// only sees what gt parses — no Match blocks — which is why it is a
+// fallback and not the default. The HostName is canonicalized as ssh
+// would, since plink connects to it as given. --jump replaces the
+// config's ProxyJump and ProxyCommand.
+func puttyResolved(alias string) sshconf.Resolved {
+	warnPuTTYOptions()
+	r := sshconf.FromConfig(cfg, alias, user)
+	if jumpHosts != "" {
+		r.ProxyJump, r.ProxyCommand = expandJumpShortcuts(jumpHosts), ""
+	}
+	r.ProxyJump = resolveJump(r.ProxyJump)
+	via := r.ProxyJump
+	if via == "none" {
+		via = ""
+	}
+	if via == "" {
+		via = r.ProxyCommand
+	}
+	r.Hostname = canonicalHostname(r.Hostname, configCanonicalization(alias, via))
+	return r
+}
+
+// resolveJump fills in a lone ProxyJump hop from the config, as ssh
+// would for a hop that is itself an alias: the jump plink reads no
+// ssh_config either. Several hops are left to PuTTYArgs to refuse.
+func resolveJump(hop string) string {
+	if hop == "" || hop == "none" || strings.Contains(hop, ",") {
+		return hop
+	}
+	u, h, p := transport.SplitJump(hop)
+	r := sshconf.FromConfig(cfg, h, u)
+	if p != "" {
+		r.Port = p
+	}
+	addr := net.JoinHostPort(r.Hostname, r.Port)
+	if r.User == "" {
+		return addr
+	}
+	return r.User + "@" + addr
+}
+
+// warnSkippedKey reports an IdentityFile PuTTY cannot use, since it only
+// reads .ppk keys.
+func warnSkippedKey(id string) {
+	if id != "" {
+		warningColor.Fprintf(os.Stderr, "Ignoring IdentityFile %s: PuTTY needs a .ppk key (convert it with puttygen)\n", id)
+	}
+}
+
+func runPlink(alias string, remoteCmd []string) error {
+	args, skipped, err := transport.PlinkArgs(puttyResolved(alias), transport.Options{Verbosity: verbosity, Env: envVars}, remoteCmd)
+	if err != nil {
+		return err
+	}
+	warnSkippedKey(skipped)
+	return runCommandLogged(execCommand("plink", args...), alias, "ssh")
+}
+
+func pscpCommand(alias string, files []string, opts remoteOpts) (*exec.Cmd, error) {
+	args, skipped, err := transport.PSCPArgs(puttyResolved(alias), opts.transport(alias, verbosity), files)
+	if err != nil {
+		return nil, err
+	}
+	warnSkippedKey(skipped)
+	return execCommand("pscp", args...), nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+
+	"gt/pkg/sshconf"
+)
+
+func usePuTTYBackend(t *testing.T, conf string) {
+	t.Helper()
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Setenv("GT_BACKEND", backendPuTTY)
+	useMockExec(t)
+	decoded, err := ssh_config.Decode(strings.NewReader(conf))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	cfg = decoded
+}
+
+func TestActiveBackend(t *testing.T) {
+	orig := gtCfg
+	defer func() { gtCfg = orig }()
+
+	t.Setenv("GT_BACKEND", "")
+	gtCfg = gtConfig{}
+	b, err := activeBackend()
+	assert.NoError(t, err)
+	assert.Equal(t, backendOpenSSH, b, "auto is OpenSSH off Windows")
+
+	gtCfg = gtConfig{Backend: "putty"}
+	b, _ = activeBackend()
+	assert.Equal(t, backendPuTTY, b)
+
+	t.Setenv("GT_BACKEND", "openssh")
+	b, _ = activeBackend()
+	assert.Equal(t, backendOpenSSH, b, "env beats config")
+
+	t.Setenv("GT_BACKEND", "telnet")
+	_, err = activeBackend()
+	assert.Error(t, err)
+}
+
+func TestRunPlink(t *testing.T) {
+	usePuTTYBackend(t, `Host web
+  HostName web.example.com
+  User deploy
+  Port 2200
+  IdentityFile ~/.ssh/web.ppk
+`)
+	home, _ := os.UserHomeDir()
+
+	assert.NoError(t, runSSH("web", nil))
+	assert.Equal(t, "plink", mockCmd.commands[0])
+	assert.Equal(t, []string{
+		"-P", "2200", "-l", "deploy",
+		"-i", filepath.Join(home, ".ssh", "web.ppk"),
+		"-t", "web.example.com",
+	}, mockCmd.argLists[0])
+
+	mockCmd.reset()
+	assert.NoError(t, runSSH("web", []string{"uptime"}))
+	assert.Equal(t, []string{
+		"-P", "2200", "-l", "deploy",
+		"-i", filepath.Join(home, ".ssh", "web.ppk"),
+		"web.example.com", "uptime",
+	}, mockCmd.argLists[0])
+	assert.Len(t, mockCmd.commands, 1, "no ssh -G: the audit address comes from the config")
+}
+
+func TestRunPSCPSkipsOpenSSHKeys(t *testing.T) {
+	usePuTTYBackend(t, `Host db
+  HostName db.example.com
+  User postgres
+  IdentityFile ~/.ssh/id_ed25519
+`)
+
+	assert.NoError(t, runSCP("db", []string{":dump.sql", "./"}))
+	assert.Equal(t, "pscp", mockCmd.commands[0])
+	assert.Equal(t, []string{
+		"-P", "22", "-l", "postgres", "-r", "-p",
+		"db.example.com:dump.sql", "./",
+	}, mockCmd.argLists[0])
+}
+
+func TestResolveHostFromConfigDefaults(t *testing.T) {
+	usePuTTYBackend(t, "Host bare\n  User me\n")
+	origUser := user
+	defer func() { user = origUser }()
+
+	assert.Equal(t, sshconf.Resolved{User: "me", Hostname: "bare", Port: "22"}, puttyResolved("bare"))
+	user = "admin"
+	assert.Equal(t, "admin", puttyResolved("bare").User, "-u overrides the config")
+}
+
+func TestResolveHostOptionsFromConfig(t *testing.T) {
+	usePuTTYBackend(t, "Host app\n  HostName app.internal\n  ForwardAgent yes\n")
+	r, opts, err := resolveHostOptions("app")
+	assert.NoError(t, err)
+	assert.Equal(t, "app.internal", r.Hostname)
+	assert.Equal(t, map[string][]string{"forwardagent": {"yes"}}, opts)
+	assert.Empty(t, mockCmd.commands, "no ssh -G")
+}
+
+func TestRunPSCPBracketsIPv6(t *testing.T) {
+	usePuTTYBackend(t, "Host v6\n  HostName 2001:db8::1\n  User me\n")
+	assert.NoError(t, runSCP("v6", []string{"a.txt", ":/tmp/"}))
+	assert.Equal(t, []string{"-P", "22", "-l", "me", "-p", "a.txt", "[2001:db8::1]:/tmp/"}, mockCmd.argLists[0])
+}
+
+func TestRunPSCPThroughJumpHost(t *testing.T) {
+	usePuTTYBackend(t, `Host db
+  HostName db.internal
+  User postgres
+  ProxyJump bastion
+Host bastion
+  HostName bastion.example.com
+  User ops
+  Port 2222
+`)
+	assert.NoError(t, runSCP("db", []string{"dump.sql", ":/tmp/"}))
+	assert.Equal(t, []string{
+		"-P", "22", "-l", "postgres",
+		"-proxycmd", "plink -P 2222 -l ops -nc %host:%port bastion.example.com",
+		"-p", "dump.sql", "db.internal:/tmp/",
+	}, mockCmd.argLists[0], "the jump alias is resolved from the config")
+
+	defer func() { jumpHosts = "" }()
+	jumpHosts = "admin@gw"
+	mockCmd.reset()
+	assert.NoError(t, runSCP("db", []string{"dump.sql", ":/tmp/"}))
+	assert.Contains(t, mockCmd.argLists[0], "plink -P 22 -l admin -nc %host:%port gw", "--jump replaces ProxyJump")
+}
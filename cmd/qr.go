@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/qr"
+	"gt/pkg/sshconf"
+)
+
+var qrInvert bool
+
+// qrQuietZone is the light border, in modules, readers need around a
+// code.
+const qrQuietZone = 4
+
+// sshURI is the ssh:// URI for a resolved host, as mobile clients open
+// it: ssh://user@host:port.
+func sshURI(r sshconf.Resolved) string {
+	port := r.Port
+	if port == "" {
+		port = "22"
+	}
+	u := url.URL{Scheme: "ssh", Host: net.JoinHostPort(r.Hostname, port)}
+	if r.User != "" {
+		u.User = url.User(r.User)
+	}
+	return u.String()
+}
+
+// renderQR draws c with half blocks, two rows of modules per line. It
+// draws the light modules, for the usual light-on-dark terminal; invert
+// draws the dark ones instead, for a dark-on-light one.
+func renderQR(w io.Writer, c *qr.Code, invert bool) {
+	ink := func(x, y int) bool {
+		x, y = x-qrQuietZone, y-qrQuietZone
+		dark := x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.Dark(x, y)
+		return dark == invert
+	}
+	n := c.Size + 2*qrQuietZone
+	var b bytes.Buffer
+	for y := 0; y < n; y += 2 {
+		for x := 0; x < n; x++ {
+			top, bottom := ink(x, y), y+1 < n && ink(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	w.Write(b.Bytes())
+}
+
+var qrCmd = &cobra.Command{
+	Use:   "qr <alias>",
+	Short: "Show a host's ssh:// URI as a QR code, for a phone's SSH client",
+	Long: `Draw a QR code of the host's ssh://user@host:port URI, resolved with
+ssh -G, for opening the same host in Termius, Blink or another mobile SSH
+client with the camera instead of typing. The URI is printed below it.
+
+The code is drawn for a light-on-dark terminal; if your phone cannot read
+it on a light background, pass --invert.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHosts,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		// A redacted code would leave nothing to scan.
+		if redactEnabled() {
+			return errors.New("gt qr shows the host's address, which redaction hides; unset GT_REDACT to use it")
+		}
+		cmd.SilenceUsage = true
+		r, err := resolveHost(alias)
+		if err != nil {
+			return err
+		}
+		uri := sshURI(r)
+		code, err := qr.Encode([]byte(uri))
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		renderQR(out, code, qrInvert)
+		fmt.Fprintln(out, uri)
+		return nil
+	},
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/qr"
+	"gt/pkg/sshconf"
+)
+
+func TestSSHURI(t *testing.T) {
+	assert.Equal(t, "ssh://deploy@web1.example.com:2222", sshURI(sshconf.Resolved{User: "deploy", Hostname: "web1.example.com", Port: "2222"}))
+	assert.Equal(t, "ssh://[2001:db8::1]:22", sshURI(sshconf.Resolved{Hostname: "2001:db8::1"}))
+}
+
+func TestRenderQR(t *testing.T) {
+	code, err := qr.Encode([]byte("ssh://testuser@test.example.com:2222"))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	renderQR(&out, code, false)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	n := code.Size + 2*qrQuietZone
+	assert.Len(t, lines, (n+1)/2)
+	for _, l := range lines {
+		assert.Equal(t, n, utf8.RuneCountInString(l))
+	}
+	assert.Equal(t, strings.Repeat("█", n), lines[0], "the quiet zone is light")
+	// The top-left finder: its dark top edge over the light ring.
+	assert.True(t, strings.HasPrefix(lines[2], strings.Repeat("█", qrQuietZone)+" ▄▄▄▄▄ █"), lines[2])
+
+	out.Reset()
+	renderQR(&out, code, true)
+	assert.True(t, strings.HasPrefix(out.String(), strings.Repeat(" ", n)+"\n"))
+}
+
+func TestQRCmd(t *testing.T) {
+	useMockExec(t)
+	useWebGroup(t)
+	var out bytes.Buffer
+	qrCmd.SetOut(&out)
+	t.Cleanup(func() { qrCmd.SetOut(nil) })
+
+	require.NoError(t, qrCmd.RunE(qrCmd, []string{"web-1"}))
+	assert.True(t, strings.HasSuffix(out.String(), "\nssh://testuser@test.example.com:2222\n"))
+
+	t.Setenv("GT_REDACT", "1")
+	assert.ErrorContains(t, qrCmd.RunE(qrCmd, []string{"web-1"}), "redaction")
+}
@@ -0,0 +1,447 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/transport"
+)
+
+var (
+	queueParallel int
+	queueRetries  int
+	queueClearAll bool
+)
+
+// queueSleep is time.Sleep, swappable in tests.
+var queueSleep = time.Sleep
+
+// queueMaxDelay caps the wait between retries of a job.
+const queueMaxDelay = time.Minute
+
+// Job statuses. A job is pending until a run takes it, running while it
+// copies, and done or failed after.
+const (
+	jobPending = "pending"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// queueJob is one transfer in the queue, in the colon shorthand with
+// local paths made absolute, so the queue runs the same from anywhere.
+type queueJob struct {
+	ID       int        `json:"id"`
+	Alias    string     `json:"alias"`
+	Files    []string   `json:"files"`
+	Added    time.Time  `json:"added"`
+	Status   string     `json:"status"`
+	Attempts int        `json:"attempts,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+type queueState struct {
+	NextID int        `json:"next_id"`
+	Jobs   []queueJob `json:"jobs"`
+}
+
+// queuePath is the queue's state file, next to the audit log.
+func queuePath() (string, error) {
+	return statePath("queue.json")
+}
+
+func readQueue(p string) (queueState, error) {
+	st := queueState{NextID: 1}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("%s: %w", p, err)
+	}
+	return st, nil
+}
+
+func writeQueue(p string, st queueState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStateFile(p, append(data, '\n'))
+}
+
+// updateQueue loads the queue, lets fn change it, and saves it, under a
+// lock: a `gt queue add` while a run is going is not lost.
+func updateQueue(fn func(*queueState) error) error {
+	p, err := queuePath()
+	if err != nil {
+		return err
+	}
+	release, err := lockState("queue.json")
+	if err != nil {
+		return err
+	}
+	defer release()
+	st, err := readQueue(p)
+	if err != nil {
+		return err
+	}
+	if err := fn(&st); err != nil {
+		return err
+	}
+	return writeQueue(p, st)
+}
+
+// absLocal makes the local paths among files absolute, keeping a
+// trailing slash that copies a directory's contents.
+func absLocal(files []string) ([]string, error) {
+	out := make([]string, len(files))
+	for i, f := range files {
+		if strings.HasPrefix(f, ":") {
+			out[i] = f
+			continue
+		}
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, err
+		}
+		if transport.CopiesContents(f) && !transport.CopiesContents(abs) {
+			abs += string(filepath.Separator)
+		}
+		out[i] = abs
+	}
+	return out, nil
+}
+
+// addJobs queues the transfer of files for each alias.
+func addJobs(aliases, files []string) ([]int, error) {
+	files, err := absLocal(files)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	err = updateQueue(func(st *queueState) error {
+		for _, alias := range aliases {
+			st.Jobs = append(st.Jobs, queueJob{ID: st.NextID, Alias: alias, Files: files, Added: time.Now(), Status: jobPending})
+			ids = append(ids, st.NextID)
+			st.NextID++
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// claimJob marks the first job not done, and not tried yet in this run,
+// as running and returns it.
+func claimJob(tried map[int]bool) (job queueJob, ok bool, err error) {
+	err = updateQueue(func(st *queueState) error {
+		for i := range st.Jobs {
+			j := &st.Jobs[i]
+			if j.Status == jobDone || j.Status == jobRunning || tried[j.ID] {
+				continue
+			}
+			j.Status = jobRunning
+			tried[j.ID] = true
+			job, ok = *j, true
+			return nil
+		}
+		return nil
+	})
+	return job, ok, err
+}
+
+// finishJob records how a job ended.
+func finishJob(id, attempts int, status string, jobErr error) error {
+	return updateQueue(func(st *queueState) error {
+		for i := range st.Jobs {
+			if j := &st.Jobs[i]; j.ID == id {
+				j.Status, j.Attempts = status, j.Attempts+attempts
+				j.Error = ""
+				if jobErr != nil {
+					j.Error = firstLine(jobErr.Error())
+				}
+				if status == jobDone || status == jobFailed {
+					now := time.Now()
+					j.Finished = &now
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// runJob copies one job, retrying a connection failure up to
+// queueRetries times with a growing wait: what a laptop waking from
+// sleep, or a flaky link, needs. attempts counts the tries.
+func runJob(job queueJob) (attempts int, err error) {
+	delay := time.Second
+	for {
+		attempts++
+		err = pushOne(job.Alias, job.Files)
+		if err == nil || attempts > queueRetries || ExitCode(err) != exitConnection || stopRequested() {
+			return attempts, err
+		}
+		if !quiet {
+			warningColor.Fprintf(os.Stderr, "#%d %s: %s; retrying in %s\n", job.ID, job.Alias, firstLine(err.Error()), delay)
+		}
+		queueSleep(delay)
+		if delay *= 2; delay > queueMaxDelay {
+			delay = queueMaxDelay
+		}
+	}
+}
+
+// runQueue works through every job not done yet, parallel at a time,
+// and returns how many it finished and how many failed. A job cut off by
+// Ctrl-C goes back to pending for the next run.
+func runQueue(parallel int) (done, failed int, err error) {
+	release, err := tryLockState("queue.run")
+	if errors.Is(err, errLocked) {
+		return 0, 0, errors.New("the queue is already running")
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer release()
+
+	// Only this run can be copying: anything still marked running was
+	// cut off, by a crash or a kill, and starts over.
+	if err := updateQueue(func(st *queueState) error {
+		for i := range st.Jobs {
+			if st.Jobs[i].Status == jobRunning {
+				st.Jobs[i].Status = jobPending
+			}
+		}
+		return nil
+	}); err != nil {
+		return 0, 0, err
+	}
+
+	if parallel < 1 {
+		parallel = 1
+	}
+	tried := map[int]bool{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stopRequested() {
+				mu.Lock()
+				job, ok, err := claimJob(tried)
+				mu.Unlock()
+				if err != nil || !ok {
+					if err != nil {
+						mu.Lock()
+						firstErr = err
+						mu.Unlock()
+					}
+					return
+				}
+				start := time.Now()
+				attempts, jobErr := runJob(job)
+				status := jobDone
+				switch {
+				case jobErr != nil && stopRequested():
+					status = jobPending
+				case jobErr != nil:
+					status = jobFailed
+				}
+				err = finishJob(job.ID, attempts, status, jobErr)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				switch status {
+				case jobDone:
+					done++
+				case jobFailed:
+					failed++
+				}
+				reportJob(job, jobErr, time.Since(start))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return done, failed, firstErr
+}
+
+// reportJob prints a finished job's line, as push reports a host.
+func reportJob(job queueJob, err error, d time.Duration) {
+	if quiet {
+		return
+	}
+	symbolColor.Fprintf(os.Stderr, "#%d ", job.ID)
+	aliasColor.Fprint(os.Stderr, job.Alias)
+	if err != nil {
+		errorColor.Fprintf(os.Stderr, " failed: %s\n", firstLine(err.Error()))
+		return
+	}
+	userColor.Fprintf(os.Stderr, " ok (%s)\n", formatDuration(d.Milliseconds()))
+}
+
+// renderQueue lists the jobs, oldest first.
+func renderQueue(w io.Writer, jobs []queueJob) {
+	if len(jobs) == 0 {
+		fmt.Fprintln(w, "The queue is empty")
+		return
+	}
+	idWidth, hostWidth := len("ID"), len("HOST")
+	for _, j := range jobs {
+		if n := len(strconv.Itoa(j.ID)); n > idWidth {
+			idWidth = n
+		}
+		if n := displayWidth(j.Alias); n > hostWidth {
+			hostWidth = n
+		}
+	}
+	pad := func(s string, n int) string { return s + strings.Repeat(" ", n-displayWidth(s)+2) }
+	symbolColor.Fprintf(w, "%s%s%s%s\n", pad("ID", idWidth), pad("HOST", hostWidth), pad("STATUS", 7), "FILES")
+	for _, j := range jobs {
+		fmt.Fprint(w, pad(strconv.Itoa(j.ID), idWidth))
+		aliasColor.Fprint(w, pad(j.Alias, hostWidth))
+		switch j.Status {
+		case jobDone:
+			userColor.Fprint(w, pad(j.Status, 7))
+		case jobFailed:
+			errorColor.Fprint(w, pad(j.Status, 7))
+		default:
+			fmt.Fprint(w, pad(j.Status, 7))
+		}
+		fmt.Fprint(w, quoteArgv(j.Files))
+		if j.Error != "" {
+			errorColor.Fprintf(w, "  (%s)", j.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Queue transfers and work through them, retrying on failure",
+	Long: `Queue transfers and copy them later, one after another or a few at a
+time. The queue lives in gt's state directory and survives a reboot, so a
+batch of large uploads and downloads can be added, run, interrupted, and run
+again: jobs already done are skipped and the rest picked up.
+
+  gt queue add db1 ./dumps/ :/var/backups/
+  gt queue add @web ':/var/log/app/*.log' ./logs/
+  gt queue run --parallel 2
+
+A job that fails to connect, as after the laptop slept, is retried with a
+growing wait, up to --retries times; any other failure is final for the run,
+and the next run tries it again. A job interrupted midway is copied again from
+the start. Transfers use the flags given to gt queue run, such as --engine.`,
+}
+
+var queueAddCmd = &cobra.Command{
+	Use:   "add <@group|alias> <files>...",
+	Short: "Add a transfer to the queue",
+	Long: `Add a transfer in gt -s's colon shorthand to the queue: one job per
+host, for a group. Local paths are stored absolute.`,
+	Args: cobra.MinimumNArgs(3),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeTargets(cmd, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args[1:]
+		if err := transport.ValidateSCPPaths(files); err != nil {
+			return err
+		}
+		aliases, err := expandTarget(args[0])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		ids, err := addJobs(aliases, files)
+		if err != nil {
+			return err
+		}
+		for i, id := range ids {
+			statusf(symbolColor, "Queued #%d for %s\n", id, aliases[i])
+		}
+		return nil
+	},
+}
+
+var queueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the queued transfers",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := queuePath()
+		if err != nil {
+			return err
+		}
+		st, err := readQueue(p)
+		if err != nil {
+			return err
+		}
+		renderQueue(cmd.OutOrStdout(), st.Jobs)
+		return nil
+	},
+}
+
+var queueRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Copy every queued transfer not done yet",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		done, failed, err := runQueue(queueParallel)
+		if err != nil {
+			return err
+		}
+		statusf(symbolColor, "%d done, %d failed\n", done, failed)
+		if failed > 0 {
+			return withCode(exitTransfer, fmt.Errorf("%d of %d jobs failed", failed, done+failed))
+		}
+		return nil
+	},
+}
+
+var queueClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove finished transfers from the queue",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed := 0
+		err := updateQueue(func(st *queueState) error {
+			kept := st.Jobs[:0]
+			for _, j := range st.Jobs {
+				if j.Status == jobDone || (queueClearAll && j.Status != jobRunning) {
+					removed++
+					continue
+				}
+				kept = append(kept, j)
+			}
+			st.Jobs = kept
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		statusf(symbolColor, "Removed %d job(s)\n", removed)
+		return nil
+	},
+}
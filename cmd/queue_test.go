@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useQueue points the queue at a fresh state directory and records
+// queueSleep's waits instead of sleeping.
+func useQueue(t *testing.T) *[]time.Duration {
+	t.Helper()
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	var waits []time.Duration
+	origSleep, origRetries := queueSleep, queueRetries
+	t.Cleanup(func() { queueSleep, queueRetries = origSleep, origRetries })
+	queueSleep = func(d time.Duration) { waits = append(waits, d) }
+	queueRetries = 3
+	return &waits
+}
+
+func loadQueue(t *testing.T) queueState {
+	t.Helper()
+	p, err := queuePath()
+	require.NoError(t, err)
+	st, err := readQueue(p)
+	require.NoError(t, err)
+	return st
+}
+
+func TestQueueAdd(t *testing.T) {
+	useQueue(t)
+	useWebGroup(t)
+	orig, _ := os.Getwd()
+	wd := t.TempDir()
+	require.NoError(t, os.Chdir(wd))
+	t.Cleanup(func() { os.Chdir(orig) })
+	wd, _ = os.Getwd()
+
+	require.NoError(t, queueAddCmd.RunE(queueAddCmd, []string{"web-1", "site/", ":/var/www/"}))
+	require.NoError(t, queueAddCmd.RunE(queueAddCmd, []string{"@web", ":/var/log/*.log", "logs"}))
+	st := loadQueue(t)
+	require.Len(t, st.Jobs, 4, "one job per host of a group")
+	assert.Equal(t, []string{filepath.Join(wd, "site") + string(filepath.Separator), ":/var/www/"}, st.Jobs[0].Files,
+		"absolute, still copying the contents")
+	assert.Equal(t, []string{":/var/log/*.log", filepath.Join(wd, "logs")}, st.Jobs[1].Files)
+	for i, j := range st.Jobs {
+		assert.Equal(t, i+1, j.ID)
+		assert.Equal(t, jobPending, j.Status)
+	}
+	assert.Equal(t, 5, st.NextID)
+
+	assert.Error(t, queueAddCmd.RunE(queueAddCmd, []string{"nosuch", "a", ":b"}))
+	assert.Error(t, queueAddCmd.RunE(queueAddCmd, []string{"web-1", "a", "b"}), "no remote side")
+	assert.Len(t, loadQueue(t).Jobs, 4)
+}
+
+func TestQueueRun(t *testing.T) {
+	waits := useQueue(t)
+	useMockExec(t)
+	useWebGroup(t)
+	useEngine(t, "scp", 0, 0)
+	plainOutput(t)
+	conf := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("x"), 0o644))
+	_, err := addJobs([]string{"web-1", "down", "web-2"}, []string{conf, ":/etc/app/"})
+	require.NoError(t, err)
+
+	err = queueRunCmd.RunE(queueRunCmd, nil)
+	assert.Equal(t, exitTransfer, ExitCode(err))
+	assert.EqualError(t, err, "1 of 3 jobs failed")
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, *waits, "a failed connection is retried")
+
+	st := loadQueue(t)
+	assert.Equal(t, jobDone, st.Jobs[0].Status)
+	assert.Equal(t, jobFailed, st.Jobs[1].Status)
+	assert.Equal(t, 4, st.Jobs[1].Attempts)
+	assert.Contains(t, st.Jobs[1].Error, "Connection refused")
+	assert.NotNil(t, st.Jobs[1].Finished)
+	assert.Equal(t, jobDone, st.Jobs[2].Status)
+
+	// The next run only retries what is not done.
+	mockCmd.reset()
+	queueRetries = 0
+	assert.Error(t, queueRunCmd.RunE(queueRunCmd, nil))
+	for i, c := range mockCmd.commands {
+		if c == "scp" {
+			args := mockCmd.argLists[i]
+			assert.Equal(t, "down:/etc/app/", args[len(args)-1])
+		}
+	}
+	assert.Equal(t, 5, loadQueue(t).Jobs[1].Attempts)
+}
+
+func TestQueueRunResumes(t *testing.T) {
+	useQueue(t)
+	useMockExec(t)
+	useWebGroup(t)
+	useEngine(t, "scp", 0, 0)
+	conf := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("x"), 0o644))
+	_, err := addJobs([]string{"web-1"}, []string{conf, ":/etc/app/"})
+	require.NoError(t, err)
+	// A run killed mid-copy left its job running.
+	require.NoError(t, updateQueue(func(st *queueState) error {
+		st.Jobs[0].Status = jobRunning
+		return nil
+	}))
+
+	require.NoError(t, queueRunCmd.RunE(queueRunCmd, nil))
+	assert.Equal(t, jobDone, loadQueue(t).Jobs[0].Status)
+	assert.NotNil(t, mockRun("scp"), "copied again")
+}
+
+func TestQueueRunLocked(t *testing.T) {
+	useQueue(t)
+	release, err := tryLockState("queue.run")
+	require.NoError(t, err)
+	defer release()
+	_, _, err = runQueue(1)
+	assert.EqualError(t, err, "the queue is already running")
+}
+
+func TestQueueListAndClear(t *testing.T) {
+	useQueue(t)
+	plainOutput(t)
+	_, err := addJobs([]string{"web-1", "web-2", "db1"}, []string{":/var/log/app.log", "/tmp/logs/"})
+	require.NoError(t, err)
+	require.NoError(t, finishJob(1, 1, jobDone, nil))
+	require.NoError(t, finishJob(2, 2, jobFailed, assert.AnError))
+
+	var b bytes.Buffer
+	queueListCmd.SetOut(&b)
+	defer queueListCmd.SetOut(nil)
+	require.NoError(t, queueListCmd.RunE(queueListCmd, nil))
+	assert.Equal(t, "ID  HOST   STATUS   FILES\n"+
+		"1   web-1  done     :/var/log/app.log /tmp/logs/\n"+
+		"2   web-2  failed   :/var/log/app.log /tmp/logs/  ("+assert.AnError.Error()+")\n"+
+		"3   db1    pending  :/var/log/app.log /tmp/logs/\n", b.String())
+
+	require.NoError(t, queueClearCmd.RunE(queueClearCmd, nil))
+	st := loadQueue(t)
+	require.Len(t, st.Jobs, 2)
+	assert.Equal(t, 2, st.Jobs[0].ID)
+
+	queueClearAll = true
+	defer func() { queueClearAll = false }()
+	require.NoError(t, queueClearCmd.RunE(queueClearCmd, nil))
+	b.Reset()
+	require.NoError(t, queueListCmd.RunE(queueListCmd, nil))
+	assert.Equal(t, "The queue is empty\n", b.String())
+	assert.Equal(t, 4, loadQueue(t).NextID, "ids are not reused")
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+)
+
+// redactMask replaces masked values. It is a fixed string rather than one
+// of matching length so the mask does not leak the hostname's shape.
+const redactMask = "***"
+
+var listRedact bool
+
+// redactEnabled reports whether addresses should be masked, either for
+// this invocation (--redact) or for the whole session via GT_REDACT, which
+// is the convenient form when screen-sharing a terminal. Any value
+// strconv.ParseBool accepts as true turns it on; so does a bare non-empty
+// value it cannot parse, since failing open would defeat the purpose.
+func redactEnabled() bool {
+	if listRedact {
+		return true
+	}
+	v := os.Getenv("GT_REDACT")
+	if v == "" {
+		return false
+	}
+	on, err := strconv.ParseBool(v)
+	return on || err != nil
+}
+
+// redactAddress masks the host part of a user@host audit address.
+func redactAddress(addr string) string {
+	for i := len(addr) - 1; i >= 0; i-- {
+		if addr[i] == '@' {
+			return addr[:i+1] + redactMask
+		}
+	}
+	return redactMask
+}
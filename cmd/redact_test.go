@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactEnabled(t *testing.T) {
+	orig := listRedact
+	defer func() { listRedact = orig }()
+
+	tests := []struct {
+		flag bool
+		env  string
+		want bool
+	}{
+		{false, "", false},
+		{true, "", true},
+		{false, "1", true},
+		{false, "true", true},
+		{false, "0", false},
+		{false, "false", false},
+		{false, "yes", true}, // unparseable but set: fail closed
+	}
+	for _, tt := range tests {
+		listRedact = tt.flag
+		t.Setenv("GT_REDACT", tt.env)
+		assert.Equal(t, tt.want, redactEnabled(), "flag=%v env=%q", tt.flag, tt.env)
+	}
+}
+
+func TestRedactAddress(t *testing.T) {
+	assert.Equal(t, "me@***", redactAddress("me@host.example.com"))
+	assert.Equal(t, "***", redactAddress("host.example.com"))
+	assert.Equal(t, "***", redactAddress("myalias"))
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gt/pkg/transport"
+)
+
+// remoteOpts tunes how remoteCommand and transferCommand connect.
+type remoteOpts struct {
+	// batch disables prompts, which is what running against several
+	// hosts at once needs: a password prompt per host cannot be
+	// answered sensibly.
+	batch bool
+	// sshOptions are extra ssh arguments such as ControlMaster settings.
+	// The PuTTY backend has no equivalent and ignores them.
+	sshOptions []string
+	// recursive lets a transfer copy directories (scp -r), as
+	// planTransfer finds it needs.
+	recursive bool
+	// showBanner keeps the server's Banner in the run's stderr whatever
+	// --quiet, --quiet-motd or the host say, for gt motd.
+	showBanner bool
+}
+
+// transport converts the options for pkg/transport, on top of gt's
+// base options as they apply to alias, with verbosity -v flags. These
+// are commands and transfers, so --quiet-motd applies.
+func (o remoteOpts) transport(alias string, verbosity int) transport.Options {
+	t := hostOptions(baseOptions(), alias)
+	t.Verbosity = verbosity
+	t.Batch = t.Batch || o.batch
+	t.Extra = o.sshOptions
+	t.Recursive = o.recursive
+	if !usePuTTY() && pluginTransport() == nil {
+		t = passwordOptions(t, alias)
+	}
+	if o.showBanner {
+		t.Quiet = false
+		t.Overrides = append(append([]string(nil), t.Overrides...), "LogLevel=INFO")
+		return t
+	}
+	return motdOptions(t, alias)
+}
+
+// remoteCommand builds the command that runs remoteCmd on alias through
+// the active backend, without a terminal.
+func remoteCommand(alias string, opts remoteOpts, remoteCmd ...string) (*exec.Cmd, error) {
+	if t := pluginTransport(); t != nil {
+		return transportCommand(t, alias, opts.transport(alias, 0), remoteCmd)
+	}
+	if usePuTTY() {
+		args, skipped, err := transport.PlinkArgs(puttyResolved(alias), opts.transport(alias, 0), remoteCmd)
+		if err != nil {
+			return nil, err
+		}
+		warnSkippedKey(skipped)
+		return execCommand("plink", args...), nil
+	}
+	t := opts.transport(alias, 0)
+	t.Extra = append(append([]string(nil), t.Extra...), "-T")
+	return withAskpass(sshCommand(transport.SSHArgs(t, alias, remoteCmd)...), alias), nil
+}
+
+// remoteOutput runs remoteCmd on alias and returns its stdout. The run
+// is audit-logged under mode; an empty mode skips the log, for callers
+// that poll and log the session once themselves. On failure the error
+// carries ssh's and the command's stderr, trimmed.
+func remoteOutput(alias, mode string, opts remoteOpts, remoteCmd ...string) ([]byte, error) {
+	cmd, err := remoteCommand(alias, opts, remoteCmd...)
+	if err != nil {
+		return nil, err
+	}
+	debugf(1, "exec: %s", quoteArgv(cmd.Args))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	start := time.Now()
+	out, err := outputTracked(cmd)
+	if mode != "" {
+		logConnection(alias, mode, start, err)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("%s: %w: %s", alias, err, msg)
+		}
+		return out, fmt.Errorf("%s: %w", alias, err)
+	}
+	return out, nil
+}
+
+// shellScript wraps a POSIX sh script as one remote command, so it runs
+// the same whatever the remote user's login shell is.
+func shellScript(script string) string {
+	return quoteArgv([]string{"sh", "-c", script})
+}
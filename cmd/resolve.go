@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
+)
+
+// lookupIPs is the resolver, swappable in tests.
+var lookupIPs = func(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// dialTimeout is net.DialTimeout, swappable in tests.
+var dialTimeout = net.DialTimeout
+
+// splitFamilies resolves host and sorts the answers into A and AAAA
+// records. An IP literal resolves to itself.
+func splitFamilies(host string) (v4, v6 []net.IP, err error) {
+	ips := []net.IP{transport.HostIP(host)}
+	if ips[0] == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if ips, err = lookupIPs(ctx, host); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	return v4, v6, nil
+}
+
+func printAddresses(label, host string) {
+	aliasColor.Printf("%s ", label)
+	domainColor.Println(host)
+	v4, v6, err := splitFamilies(host)
+	if err != nil {
+		errorColor.Printf("  %v\n", err)
+		return
+	}
+	for _, ip := range v4 {
+		symbolColor.Print("  A     ")
+		subdomainColor.Println(ip)
+	}
+	for _, ip := range v6 {
+		symbolColor.Print("  AAAA  ")
+		subdomainColor.Println(ip)
+	}
+	if len(v4)+len(v6) == 0 {
+		warningColor.Println("  (no addresses)")
+	}
+}
+
+var resolveCmd = &cobra.Command{
+	Use:               "resolve <alias>",
+	Short:             "Show the DNS records behind a host's HostName",
+	Long:              `Resolve the alias with ssh -G, then show every A and AAAA record for its HostName, followed by any fallback addresses configured for it in gt's config.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeHosts,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias, err := hostArg(args[0])
+		if err != nil {
+			return err
+		}
+		r, err := resolveHost(alias)
+		if err != nil {
+			return err
+		}
+		printAddresses("hostname", r.Hostname)
+		for _, fb := range hostMetaFor(alias).FallbackAddresses {
+			printAddresses("fallback", fb)
+		}
+		return nil
+	},
+}
+
+// pickAddress returns the HostName override to connect with, or "" to
+// leave the config's HostName alone. Without fallbacks there is nothing
+// to choose and no probe is made. Otherwise the primary, then each
+// fallback, gets a TCP probe on the resolved port; the first to accept
+// wins. If none does, gt still tries the primary so ssh reports the real
+// error. Probes are direct from this machine, so a host reached through
+// a ProxyJump or ProxyCommand, --jump included, is not probed.
+func pickAddress(alias string) string {
+	addr, _ := pickFallback(alias)
+	return addr
+}
+
+// pickFallback is pickAddress, with the name ssh should look the host
+// key up under when it connects to the fallback: the primary's, as
+// known_hosts has it, or "" when the host sets a HostKeyAlias of its own.
+func pickFallback(alias string) (addr, keyName string) {
+	meta := hostMetaFor(alias)
+	if len(meta.FallbackAddresses) == 0 {
+		return "", ""
+	}
+	r, opts, err := resolveHostOptions(alias)
+	if err != nil {
+		return "", ""
+	}
+	if proxied(r, opts) {
+		debugf(1, "%s is reached through a proxy; not probing its fallbacks", alias)
+		return "", ""
+	}
+	if a := opts["hostkeyalias"]; len(a) == 0 || a[0] == "" || strings.EqualFold(a[0], "none") {
+		keyName = knownHostsName(r.Hostname, r.Port)
+	}
+	port := r.Port
+	if port == "" {
+		port = "22"
+	}
+	timeout := meta.connectTimeout()
+	candidates := append([]string{r.Hostname}, meta.FallbackAddresses...)
+	for i, addr := range candidates {
+		conn, err := dialTimeout("tcp", net.JoinHostPort(addr, port), timeout)
+		if err != nil {
+			debugf(1, "probe %s port %s: %v", addr, port, err)
+			continue
+		}
+		conn.Close()
+		if i == 0 {
+			return "", ""
+		}
+		warningColor.Fprintf(os.Stderr, "%s unreachable, using fallback address %s\n", r.Hostname, addr)
+		return addr, keyName
+	}
+	return "", ""
+}
+
+// knownHostsName is how known_hosts names host on port, as ssh writes
+// it: bare on port 22, [host]:port on any other.
+func knownHostsName(host, port string) string {
+	if port == "" || port == "22" {
+		return host
+	}
+	return "[" + host + "]:" + port
+}
+
+// fallbackOptions are the -o options that connect to the address
+// pickAddress picks for alias, nil to leave the HostName alone. The
+// host key is still checked under the primary's name: it is the same
+// server at another address, and a prompt about an unknown key there
+// would only teach people to accept new keys.
+func fallbackOptions(alias string) []string {
+	addr, keyName := pickFallback(alias)
+	if addr == "" {
+		return nil
+	}
+	opts := []string{"-o", "HostName=" + addr}
+	if keyName != "" {
+		opts = append(opts, "-o", "HostKeyAlias="+keyName)
+	}
+	return opts
+}
+
+// proxied reports whether a host resolved to r and opts is reached
+// through a ProxyJump or ProxyCommand rather than directly.
+func proxied(r sshconf.Resolved, opts map[string][]string) bool {
+	if r.ProxyJump != "" || r.ProxyCommand != "" {
+		return true
+	}
+	for _, key := range []string{"proxyjump", "proxycommand"} {
+		if v := opts[key]; len(v) > 0 && !strings.EqualFold(v[0], "none") {
+			return true
+		}
+	}
+	return false
+}
+
+// withFallback is o connecting to the address pickAddress picks for
+// alias, for the OpenSSH tools; the PuTTY backend and plugin transports
+// get o as is.
+func (o remoteOpts) withFallback(alias string) remoteOpts {
+	if usePuTTY() || pluginTransport() != nil {
+		return o
+	}
+	if fb := fallbackOptions(alias); fb != nil {
+		o.sshOptions = append(append([]string(nil), o.sshOptions...), fb...)
+	}
+	return o
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/sshconf"
+)
+
+func TestSplitFamilies(t *testing.T) {
+	orig := lookupIPs
+	defer func() { lookupIPs = orig }()
+	lookupIPs = func(ctx context.Context, host string) ([]net.IP, error) {
+		if host == "missing.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.2")}, nil
+	}
+
+	v4, v6, err := splitFamilies("web.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}, v4)
+	assert.Equal(t, []net.IP{net.ParseIP("2001:db8::1")}, v6)
+
+	v4, v6, err = splitFamilies("2001:db8::7")
+	assert.NoError(t, err)
+	assert.Empty(t, v4)
+	assert.Equal(t, []net.IP{net.ParseIP("2001:db8::7")}, v6, "IP literals are not looked up")
+
+	_, _, err = splitFamilies("missing.example.com")
+	assert.Error(t, err)
+}
+
+type stubConn struct{ net.Conn }
+
+func (stubConn) Close() error { return nil }
+
+func TestPickAddress(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	origCfg, origDial := gtCfg, dialTimeout
+	defer func() { gtCfg, dialTimeout = origCfg, origDial }()
+
+	var probed []string
+	reachable := map[string]bool{}
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		probed = append(probed, addr)
+		assert.Equal(t, 3*time.Second, timeout)
+		if reachable[addr] {
+			return stubConn{}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{
+		"test": {FallbackAddresses: []string{"10.0.0.5", "2001:db8::5"}, ConnectTimeout: "3s"},
+	}}
+
+	assert.Equal(t, "", pickAddress("other"), "no fallbacks, no probe")
+	assert.Empty(t, probed)
+
+	reachable["test.example.com:2222"] = true
+	assert.Equal(t, "", pickAddress("test"), "primary reachable")
+	assert.Equal(t, []string{"test.example.com:2222"}, probed)
+
+	probed = nil
+	reachable = map[string]bool{"[2001:db8::5]:2222": true}
+	assert.Equal(t, "2001:db8::5", pickAddress("test"))
+	assert.Equal(t, []string{"test.example.com:2222", "10.0.0.5:2222", "[2001:db8::5]:2222"}, probed)
+
+	reachable = map[string]bool{}
+	assert.Equal(t, "", pickAddress("test"), "nothing reachable leaves the primary to ssh")
+
+	origJump := jumpHosts
+	defer func() { jumpHosts = origJump }()
+	jumpHosts = "bastion"
+	probed = nil
+	assert.Equal(t, "", pickAddress("test"), "behind --jump")
+	assert.Empty(t, probed, "a proxied host is not probed from here")
+}
+
+func TestProxied(t *testing.T) {
+	assert.False(t, proxied(sshconf.Resolved{}, map[string][]string{"proxycommand": {"none"}}))
+	assert.True(t, proxied(sshconf.Resolved{}, map[string][]string{"proxyjump": {"bastion"}}))
+	assert.True(t, proxied(sshconf.Resolved{}, map[string][]string{"proxycommand": {"nc %h %p"}}))
+	assert.True(t, proxied(sshconf.Resolved{ProxyJump: "bastion"}, nil), "as PuTTY resolves it")
+}
+
+func TestTransferUsesFallback(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	origDial := dialTimeout
+	defer func() { dialTimeout = origDial }()
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		if addr == "10.0.0.5:2222" {
+			return stubConn{}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+	gtCfg.Hosts["web-1"] = hostMeta{FallbackAddresses: []string{"10.0.0.5"}}
+
+	local := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(local, []byte("x"), 0o600))
+	assert.NoError(t, runSCP("web-1", []string{local, ":/tmp/"}))
+	args := mockRun("scp")
+	assert.True(t, contains(args, "HostName=10.0.0.5"), "%v", args)
+	assert.True(t, contains(args, "HostKeyAlias=[test.example.com]:2222"), "the key is checked as the primary's: %v", args)
+}
+
+func TestKnownHostsName(t *testing.T) {
+	assert.Equal(t, "db.example.com", knownHostsName("db.example.com", "22"))
+	assert.Equal(t, "[db.example.com]:2222", knownHostsName("db.example.com", "2222"))
+}
+
+func TestConnectTimeout(t *testing.T) {
+	assert.Equal(t, defaultConnectTimeout, hostMeta{}.connectTimeout())
+	assert.Equal(t, defaultConnectTimeout, hostMeta{ConnectTimeout: "soon"}.connectTimeout())
+	assert.Equal(t, 2*time.Second, hostMeta{ConnectTimeout: "2s"}.connectTimeout())
+}
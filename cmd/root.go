@@ -2,29 +2,39 @@ package cmd
 
 import (
 	"bufio"
-	"bytes"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
+	"runtime"
 	"strings"
-	"sync"
-	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/kevinburke/ssh_config"
 	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
 )
 
 var (
 	cfgFile     string
 	cfg         *ssh_config.Config
-	user        string
-	useScp      bool
-	noLog       bool
-	execCommand = exec.Command
+	loadedFiles []string // every config file merged into cfg, main first
+	// missingConfig is the SSH config path gt looked for and did not
+	// find, or "" when one was loaded; gt then runs with no hosts.
+	missingConfig string
+	user          string
+	sshOverrides  []string // -o options, passed to ssh as given
+	envVars       []string // --env values, KEY or KEY=value
+	jumpHosts     string   // -J hosts, for connections and transfers alike
+	verifyDNS     bool     // --verify-dns: check host keys against SSHFP records
+	useScp        bool
+	noLog         bool
+	execCommand   = exec.Command
 	// Color outputs using conventional terminal colors
 	aliasColor     = color.New(color.FgBlue, color.Bold) // for the host alias (like ls directories)
 	userColor      = color.New(color.FgGreen)            // for username (conventional user color)
@@ -37,176 +47,249 @@ var (
 )
 
 func init() {
-	// Respect NO_COLOR environment variable
-	if os.Getenv("NO_COLOR") != "" {
-		color.NoColor = true
-	}
-
-	cobra.OnInitialize(initConfig)
+	// Decide on color before flags are parsed, so even usage errors
+	// printed ahead of initConfig respect NO_COLOR/CLICOLOR and pipes.
+	applyColorMode("auto")
+	// Execute prints errors itself, so the output of a remote command
+	// that failed is not followed by a redundant "exit status" line.
+	rootCmd.SilenceErrors = true
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "SSH config file (default ~/.ssh/config)")
 	rootCmd.PersistentFlags().StringVarP(&user, "user", "u", "", "override SSH config user")
+	rootCmd.PersistentFlags().StringArrayVarP(&sshOverrides, "option", "o", nil, "pass `KEY=VALUE` to ssh as an ssh_config option (repeatable)")
+	rootCmd.RegisterFlagCompletionFunc("option", completeSSHOption)
+	rootCmd.PersistentFlags().StringVarP(&jumpHosts, "jump", "J", "", "connect and copy through the jump host(s) `HOSTS`, comma-separated, as ssh -J does")
+	rootCmd.PersistentFlags().BoolVar(&allowUntrusted, "allow-untrusted", false, "run root-needing operations (exec --sudo, svc, pkg, reboot, shutdown) on hosts marked trust: untrusted")
+	rootCmd.PersistentFlags().BoolVar(&verifyDNS, "verify-dns", false, "check the host key against the host's SSHFP records in DNS, trusting a DNSSEC-validated match (VerifyHostKeyDNS=yes)")
+	rootCmd.PersistentFlags().BoolVar(&quietMOTD, "quiet-motd", false, "leave the server's login banner out of commands and transfers (LogLevel=ERROR)")
+	rootCmd.PersistentFlags().BoolVar(&checkTZ, "check-tz", false, "on login, note where the host's timezone or locale differs from the local one")
+	rootCmd.PersistentFlags().BoolVar(&checkSessions, "check-sessions", false, "on login, warn when others are logged into the host")
+	rootCmd.PersistentFlags().BoolVar(&useGSSAPI, "gssapi", false, "log in with the Kerberos ticket and delegate it (GSSAPIAuthentication=yes, GSSAPIDelegateCredentials=yes)")
+	rootCmd.PersistentFlags().StringVar(&passwordCmd, "password-cmd", "", "log in with the password this command prints, e.g. 'op read op://infra/switch/password', for hosts that take no key")
+	rootCmd.RegisterFlagCompletionFunc("jump", completeHosts)
+	rootCmd.PersistentFlags().StringArrayVar(&envVars, "env", nil, "pass `KEY[=VALUE]` to the remote session, KEY alone taking its local value (repeatable)")
 	rootCmd.PersistentFlags().BoolVarP(&useScp, "scp", "s", false, "use SCP instead of SSH")
+	// Copy flags go on the commands that copy: gt -s, push, pull, the
+	// shell's put and get, queue run and the daemon's transfers.
+	for _, c := range []*cobra.Command{rootCmd, pushCmd, pullCmd, shellCmd, queueRunCmd, daemonCmd} {
+		c.Flags().StringVar(&transferEngine, "engine", "scp", "copy files with scp, sftp for high-latency links, or tar for trees of many small files")
+		c.Flags().IntVar(&sftpRequests, "streams", 0, "with --engine sftp, keep up to `N` requests in flight (sftp -R; default 64)")
+		c.Flags().BoolVar(&checkSpace, "check-space", false, "before an upload, check that the destination has room for it")
+		c.Flags().StringArrayVar(&transferInclude, "include", nil, "copy paths matching `PATTERN` even if an --exclude matches them (repeatable)")
+		c.Flags().StringArrayVar(&transferExclude, "exclude", nil, "leave paths matching `PATTERN`, and everything under them, out of a transfer (repeatable)")
+		c.Flags().StringArrayVar(&transferExcludeFrom, "exclude-from", nil, "read --exclude patterns from `FILE`, one per line (repeatable)")
+		c.Flags().IntVar(&sftpBufferSize, "chunk-size", 0, "with --engine sftp, read and write `BYTES` per request (sftp -B; default 32768)")
+		c.Flags().BoolVar(&followLinks, "follow-links", false, "copy what symlinks point to rather than the links")
+		c.Flags().BoolVar(&preserveLinks, "preserve-links", false, "with --engine tar, copy symlinks as symlinks, a source that is one included")
+		c.Flags().BoolVar(&noPerms, "no-perms", false, "leave the copies' permissions to the destination's defaults")
+		c.Flags().BoolVar(&noTimes, "no-times", false, "leave the copies' modification times at the time of the copy")
+		c.Flags().BoolVar(&copyXattrs, "xattrs", false, "with --engine tar, copy extended attributes (Linux)")
+	}
+	for _, c := range []*cobra.Command{rootCmd, pushCmd, pullCmd} {
+		c.Flags().BoolVar(&transferDryRun, "dry-run", false, "with -s, push or pull, list the files a transfer would copy, their sizes and destinations, and copy nothing")
+	}
+	rootCmd.Flags().BoolVar(&shipDotfiles, "dotfiles", false, "ship the dotfiles directory to ~/.gt on the host and source them for this session")
+	rootCmd.Flags().BoolVar(&copyStdin, "copy", false, "pipe stdin into the host's clipboard (pbcopy, wl-copy, xclip or xsel)")
 	rootCmd.PersistentFlags().BoolVar(&noLog, "no-log", false, "skip writing this connection to the audit log")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", "auto", "colorize output: always, never or auto")
+	rootCmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "do not pipe long output through $PAGER")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print only results and errors; pass -q to ssh and scp")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "never prompt or wait for input, for cron and CI (also GT_NONINTERACTIVE=1)")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "verbose: pass -v to ssh and print gt debug output (repeat up to 3 times)")
+
+	listCmd.Flags().BoolVar(&listRedact, "redact", false, "mask hostnames and ports (also GT_REDACT=1)")
+	listCmd.Flags().BoolVar(&listNoTruncate, "no-truncate", false, "never truncate lines to the terminal width")
+	listCmd.Flags().BoolVarP(&listLong, "long", "l", false, "show each host's jump host, keys, agent forwarding, multiplexing, groups and last connection")
+	listCmd.Flags().StringVar(&listSort, "sort", listSort, "order hosts by "+strings.Join(listSorts, ", "))
+	listCmd.Flags().BoolVar(&listReverse, "reverse", false, "reverse the --sort order")
+	listCmd.Flags().BoolVarP(&listNumbered, "numbered", "n", false, "number the hosts, for gt <number> to connect to one")
+	listCmd.Flags().StringVar(&listLauncher, "launcher", "", "print the hosts for a launcher's picker: "+strings.Join(launcherFormats, ", "))
+	listCmd.RegisterFlagCompletionFunc("sort", cobra.FixedCompletions(listSorts, cobra.ShellCompDirectiveNoFileComp))
+	listCmd.RegisterFlagCompletionFunc("launcher", cobra.FixedCompletions(launcherFormats, cobra.ShellCompDirectiveNoFileComp))
 
 	logCmd.Flags().IntVarP(&logLimit, "limit", "n", 20, "show at most N most-recent entries (0 = all)")
 
+	syncConfigCmd.PersistentFlags().StringVar(&syncRemote, "remote", "", "sync remote: git URL, s3://bucket/prefix, or WebDAV URL (default $GT_SYNC_REMOTE)")
+	syncConfigCmd.PersistentFlags().BoolVar(&syncForce, "force", false, "overwrite files changed on both sides since the last sync")
+	syncConfigCmd.AddCommand(syncPushCmd, syncPullCmd)
+	serveCmd.Flags().StringVar(&serveMetrics, "metrics", "", "export Prometheus metrics on `ADDR`, e.g. :9100")
+	serveCmd.Flags().DurationVar(&serveInterval, "interval", time.Minute, "time between probes and inventory rebuilds")
+	serveCmd.Flags().StringVar(&serveInventory, "inventory", "", "serve the hosts as a team inventory on `ADDR`, e.g. :9200")
+	serveCmd.Flags().StringVar(&serveTokenFile, "token-file", "", "read the inventory's bearer token from `FILE` (default $GT_SERVE_TOKEN, else a generated one)")
+	serveCmd.Flags().StringSliceVar(&inventoryRedact, "redact", nil, "leave `FIELDS` out of the inventory: "+strings.Join(inventoryFields, ", "))
+	serveCmd.RegisterFlagCompletionFunc("redact", cobra.FixedCompletions(inventoryFields, cobra.ShellCompDirectiveNoFileComp))
+	daemonCmd.Flags().StringVar(&daemonSocket, "socket", "", "listen on the unix socket at `PATH`")
+	benchCmd.Flags().IntVarP(&benchCount, "count", "n", 5, "number of connections to time")
+	topCmd.Flags().DurationVar(&topInterval, "interval", 2*time.Second, "time between polls")
+	topCmd.Flags().StringVar(&topSort, "sort", "load", "initial sort column: load, mem, disk or host")
+	initCmd.Flags().BoolVarP(&initYes, "yes", "y", false, "accept every default without asking")
+	benchCmd.Flags().BoolVar(&benchControl, "control", false, "also time connections over a ControlMaster")
+	diffCmd.Flags().IntVarP(&diffContext, "unified", "U", 3, "show `N` lines of context around each change")
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "exit 1 when the files differ, as diff(1) does")
+	driftCmd.Flags().IntVar(&driftParallel, "parallel", 8, "hash on at most `N` hosts at a time")
+	driftCmd.Flags().BoolVar(&driftDiff, "diff", false, "show how each deviating copy differs from the majority's")
+	portCmd.Flags().BoolVar(&portLocal, "local", false, "dial the ports from this machine instead of asking the host")
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 50, "start with the last `N` lines")
+	logsCmd.Flags().BoolVar(&logsNoFollow, "no-follow", false, "print the last lines and exit")
+	svcCmd.Flags().IntVar(&svcParallel, "parallel", 8, "on a group, run on at most `N` hosts at a time")
+	svcCmd.Flags().IntVar(&svcRolling, "rolling", 0, "on a group, work through the hosts `N` at a time, each batch finishing first")
+	svcCmd.Flags().IntVar(&svcMaxFailures, "max-failures", 0, "start no more hosts once more than `M` have failed (default: no limit)")
+	for _, c := range []*cobra.Command{rebootCmd, shutdownCmd} {
+		c.Flags().BoolVarP(&powerYes, "yes", "y", false, "go ahead without asking")
+	}
+	rebootCmd.Flags().BoolVar(&powerWait, "wait", false, "wait for the host to come back and report the downtime")
+	rebootCmd.Flags().DurationVar(&powerTimeout, "timeout", 10*time.Minute, "with --wait, give up after this long")
+	pkgCmd.Flags().IntVar(&pkgParallel, "parallel", 8, "on a group, run on at most `N` hosts at a time")
+	caCmd.PersistentFlags().StringVar(&caKeyFile, "key", "", "the CA public key `FILE` (default: ca_key in gt's config)")
+	caCmd.PersistentFlags().IntVar(&caParallel, "parallel", 8, "on a group, work on at most `N` hosts at a time")
+	motdCmd.Flags().BoolVar(&motdHush, "hush", false, "create ~/.hushlogin on the host, hiding the message of the day at login")
+	motdCmd.Flags().BoolVar(&motdUnhush, "unhush", false, "remove ~/.hushlogin from the host")
+	auditSSHCmd.Flags().IntVar(&auditParallel, "parallel", 8, "connect to at most `N` hosts at a time")
+	statusCmd.Flags().BoolVar(&statusAll, "all", false, "probe every host in the SSH config")
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "print a JSON report instead of the table")
+	statusCmd.Flags().IntVar(&statusParallel, "parallel", 16, "probe at most `N` hosts at a time")
+	mcpCmd.Flags().DurationVar(&mcpTimeout, "timeout", time.Minute, "stop a run_command that takes longer than `D`")
+	shellCmd.Flags().IntVar(&shellParallel, "parallel", 8, "run a group's commands on at most `N` hosts at a time")
+	statusCmd.Flags().DurationVar(&statusTimeout, "timeout", 0, "give each host this long to answer (default: its connect_timeout)")
+	caInstallCmd.Flags().StringArrayVar(&caPrincipals, "principal", nil, "also accept certificates for `NAME` as the login user (repeatable)")
+	qrCmd.Flags().BoolVar(&qrInvert, "invert", false, "draw the code for a terminal with a light background")
+	openCmd.Flags().BoolVar(&openTunnel, "tunnel", false, "forward a local port to the port on the host's localhost and open that")
+	openCmd.Flags().BoolVar(&openHTTPS, "https", false, "open https:// rather than http://")
+	escapeCmd.Flags().StringArrayVarP(&escapeLocal, "local", "L", nil, "add a local forward (ssh -L `SPEC`) to the live connection")
+	escapeCmd.Flags().StringArrayVarP(&escapeRemote, "remote", "R", nil, "add a remote forward (ssh -R `SPEC`) to the live connection")
+	escapeCmd.Flags().StringArrayVarP(&escapeDynamic, "dynamic", "D", nil, "add a SOCKS forward (ssh -D `PORT`) to the live connection")
+	escapeCmd.Flags().BoolVar(&escapeCancel, "cancel", false, "remove the given forwards instead of adding them")
+	queueRunCmd.Flags().IntVar(&queueParallel, "parallel", 1, "copy at most `N` jobs at a time")
+	queueRunCmd.Flags().IntVar(&queueRetries, "retries", 3, "retry a job that fails to connect up to `N` times")
+	queueClearCmd.Flags().BoolVar(&queueClearAll, "all", false, "remove every job not running, not only finished ones")
+	queueCmd.AddCommand(queueAddCmd, queueListCmd, queueRunCmd, queueClearCmd)
+	aliasCmd.AddCommand(aliasAddCmd, aliasListCmd, aliasRmCmd)
+	configCmd.AddCommand(configNewIncludeCmd, configOpenDirCmd, configSplitCmd, configFlattenCmd, configDiffCmd, configPruneCmd)
+	teamCmd.AddCommand(teamRefreshCmd)
+	for _, c := range []*cobra.Command{rootCmd, execCmd, pushCmd, driftCmd, infoCmd, pkgCmd, svcCmd, topCmd, serveCmd, queueAddCmd} {
+		c.Flags().StringSliceVar(&targetExclude, "exclude-hosts", nil, "leave out these hosts of a target: aliases, globs or @groups, comma-separated (repeatable)")
+		c.Flags().IntVar(&targetLimit, "limit", 0, "run on only the first `N` hosts of a target")
+		c.Flags().IntVar(&targetRandom, "random", 0, "run on `N` hosts of a target picked at random")
+	}
+	configSplitCmd.Flags().StringVar(&splitBy, "by", splitBy, "group hosts by `domain` of their HostName or by first gt group (tag)")
+	configSplitCmd.Flags().BoolVarP(&splitYes, "yes", "y", false, "write without asking")
+	configSplitCmd.RegisterFlagCompletionFunc("by", cobra.FixedCompletions([]string{"domain", "tag"}, cobra.ShellCompDirectiveNoFileComp))
+	configFlattenCmd.Flags().StringVar(&flattenOutput, "output", "", "write to `FILE` instead of stdout")
+	configPruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "write without asking")
+	configPruneCmd.Flags().BoolVar(&pruneUnreachable, "unreachable", false, "also offer hosts that do not resolve or accept connections")
+	configPruneCmd.Flags().StringVar(&pruneUnused, "unused", "", "also offer hosts not connected to in `AGE`, such as 180d")
+	configPruneCmd.Flags().IntVar(&pruneProbes, "probes", 3, "rounds of probes a host must fail for --unreachable")
+	keysFixPermsCmd.Flags().BoolVar(&fixPermsCheck, "check", false, "report what would change, change nothing, and exit 1 if anything would")
+	addCmd.Flags().DurationVar(&addTTL, "ttl", 0, "mark the host temporary, expiring after `DURATION`, e.g. 72h")
+	addCmd.Flags().StringVar(&addFileName, "file", "", "add the host to config.d/`NAME`.conf instead of the main config")
+	configDiffCmd.Flags().BoolVar(&configDiffExitCode, "exit-code", false, "exit 1 when the configs differ, as diff(1) does")
+	pushCmd.Flags().IntVar(&pushParallel, "parallel", 8, "copy to at most `N` hosts at a time")
+	execCmd.Flags().SetInterspersed(false) // flags after the target belong to the remote command
+	execCmd.Flags().IntVar(&execParallel, "parallel", 8, "run on at most `N` hosts at a time")
+	execCmd.Flags().IntVar(&execRolling, "rolling", 0, "work through the hosts `N` at a time, each batch finishing first")
+	execCmd.Flags().IntVar(&execCanary, "canary", 0, "run on the first `N` hosts alone, then ask before going on")
+	execCmd.Flags().IntVar(&execMaxFailures, "max-failures", 0, "start no more hosts once more than `M` have failed (default: no limit)")
+	execCmd.Flags().StringVar(&execOutputDir, "output-dir", "", "also write each host's output to `DIR`/<alias>.log, plus DIR/summary.json")
+	execCmd.Flags().BoolVarP(&execYes, "yes", "y", false, "continue past the canaries without asking")
+	execCmd.Flags().BoolVar(&execSudo, "sudo", false, "run the command as root, through sudo -n unless the login is root")
+	docsCmd.Flags().StringVar(&docsOut, "out", ".", "write the pages into `DIR`")
+	versionCmd.Flags().BoolVar(&versionCheck, "check", false, "ask GitHub whether a newer release exists")
+
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(logCmd)
+	rootCmd.AddCommand(syncConfigCmd)
+	rootCmd.AddCommand(resolveCmd)
+	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(topCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(pluginsCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(docsCmd)
+	rootCmd.AddCommand(pushCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(driftCmd)
+	rootCmd.AddCommand(portCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(svcCmd)
+	rootCmd.AddCommand(rebootCmd, shutdownCmd)
+	rootCmd.AddCommand(pkgCmd)
+	caCmd.AddCommand(caInstallCmd, caStatusCmd)
+	rootCmd.AddCommand(caCmd)
+	rootCmd.AddCommand(statusCmd)
+	auditCmd.AddCommand(auditSSHCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(motdCmd)
+	rootCmd.AddCommand(broadcastCmd)
+	rootCmd.AddCommand(shellCmd)
+	rootCmd.AddCommand(mcpCmd)
+	protocolCmd.AddCommand(protocolRegisterCmd)
+	rootCmd.AddCommand(protocolCmd)
+	whoisCmd.Flags().BoolVar(&whoisDNS, "dns", false, "also match hosts whose HostName resolves to the same address in DNS")
+	rootCmd.AddCommand(whoisCmd)
+	rootCmd.AddCommand(clipCmd)
+	rootCmd.AddCommand(qrCmd)
+	rootCmd.AddCommand(openCmd)
+	rootCmd.AddCommand(escapeCmd)
+	rootCmd.AddCommand(pullCmd)
+	rootCmd.AddCommand(queueCmd)
+	rootCmd.AddCommand(aliasCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(teamCmd)
+	rootCmd.AddCommand(addCmd)
+	keysCmd.AddCommand(keysFixPermsCmd)
+	keysKeychainCmd.AddCommand(keysKeychainAddCmd, keysKeychainRmCmd, keysKeychainListCmd)
+	keysCmd.AddCommand(keysKeychainCmd)
+	rootCmd.AddCommand(keysCmd)
+	rootCmd.AddCommand(askpassCmd)
+	rootCmd.AddCommand(doctorCmd)
+
+	completionInstallCmd.Flags().BoolVar(&completionNoRC, "no-rc", false, "do not edit shell startup files")
+	addCompletionInstall(rootCmd)
 }
 
 func getHosts() []string {
-	var hosts []string
-	seen := map[string]struct{}{}
-	for _, host := range cfg.Hosts {
-		// A single Host block can declare several aliases ("Host foo bar baz");
-		// emit each one and dedupe in case the same alias appears in multiple
-		// blocks across the merged config.
-		for _, p := range host.Patterns {
-			pattern := p.String()
-			if strings.ContainsAny(pattern, "*?") {
-				continue // skip wildcard match patterns
-			}
-			// Pattern.String() strips a leading "!", so exclusions are not
-			// detectable from the text. Ask the block instead: a negated
-			// pattern never matches its own alias, which filters entries
-			// like the "!backup" in "Host web !backup".
-			if !host.Matches(pattern) {
-				continue
-			}
-			if _, ok := seen[pattern]; ok {
-				continue
-			}
-			seen[pattern] = struct{}{}
-			hosts = append(hosts, pattern)
-		}
-	}
-	sort.Strings(hosts)
-	return hosts
-}
-
-// resolvedHost holds the values OpenSSH reports for an alias via ssh -G.
-type resolvedHost struct {
-	user     string
-	hostname string
-	port     string
+	return sshconf.Aliases(cfg)
 }
 
 // resolveHost asks OpenSSH what an alias resolves to instead of
-// reimplementing config resolution. ssh -G prints the fully resolved
-// client configuration as "key value" lines without connecting, so
-// Match blocks, canonicalization, and future options all behave exactly
-// as they would for a real connection.
-func resolveHost(alias string) (resolvedHost, error) {
-	args := append(sshBaseArgs(), "-G", "--", alias)
-	out, err := execCommand("ssh", args...).Output()
-	if err != nil {
-		return resolvedHost{}, fmt.Errorf("ssh -G %s: %w", alias, err)
-	}
-	var r resolvedHost
-	sc := bufio.NewScanner(bytes.NewReader(out))
-	for sc.Scan() {
-		key, value, _ := strings.Cut(sc.Text(), " ")
-		switch key {
-		case "user":
-			r.user = value
-		case "hostname":
-			r.hostname = value
-		case "port":
-			r.port = value
-		}
-	}
-	return r, nil
-}
-
-type listRow struct {
-	alias string
-	resolvedHost
-	err error
+// reimplementing config resolution: ssh -G prints the fully resolved
+// client configuration without connecting. The PuTTY backend, having no
+// ssh to ask, falls back to gt's own parse. Either way the HostName is
+// canonicalized, as ssh -G leaves to the connection, so it reads as the
+// name ssh connects to.
+func resolveHost(alias string) (sshconf.Resolved, error) {
+	r, _, err := resolveHostOptions(alias)
+	return r, err
 }
 
-// resolveListRows queries ssh -G for every alias. Each query is a
-// subprocess, so run a handful at a time rather than either one ssh per
-// host all at once or a serial crawl through a large config.
-func resolveListRows(hosts []string) []listRow {
-	rows := make([]listRow, len(hosts))
-	sem := make(chan struct{}, 8)
-	var wg sync.WaitGroup
-	for i, alias := range hosts {
-		wg.Add(1)
-		go func(i int, alias string) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-			resolved, err := resolveHost(alias)
-			rows[i] = listRow{alias: alias, resolvedHost: resolved, err: err}
-		}(i, alias)
-	}
-	wg.Wait()
-	return rows
-}
-
-var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all hosts from SSH config",
-	Long: `List all hosts defined in your SSH config file.
-Includes entries from included config files.
-Resolved values (user, hostname, port) come from ssh -G.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		hosts := getHosts()
-		if len(hosts) == 0 {
-			warningColor.Println("No SSH hosts found")
-			return nil
-		}
-
-		rows := resolveListRows(hosts)
-
-		aliasWidth := 0
-		for _, r := range rows {
-			if len(r.alias) > aliasWidth {
-				aliasWidth = len(r.alias)
+// resolveHostOptions is resolveHost along with every option the same
+// ssh -G resolved, keyed in lower case as ssh -G prints them. PuTTY has
+// no ssh -G; its options are read from the config as FromConfig reads
+// the rest.
+func resolveHostOptions(alias string) (sshconf.Resolved, map[string][]string, error) {
+	if usePuTTY() {
+		opts := map[string][]string{}
+		for _, key := range []string{"ForwardAgent", "ControlMaster"} {
+			if v, _ := cfg.Get(alias, key); v != "" {
+				opts[strings.ToLower(key)] = []string{v}
 			}
 		}
-		aliasWidth++ // single-space gutter after the longest alias
-
-		for _, r := range rows {
-			// Format: alias    user@host.subdomain.domain:port
-			aliasColor.Printf("%-*s", aliasWidth, r.alias)
-			if r.err != nil {
-				warningColor.Println("(could not resolve)")
-				continue
-			}
-			userColor.Print(r.user)
-			symbolColor.Print("@")
-
-			// Split hostname into parts and color each differently
-			parts := strings.Split(r.hostname, ".")
-			for i, part := range parts {
-				if i > 0 {
-					symbolColor.Print(".")
-				}
-				if i == len(parts)-1 {
-					// Last part is the top-level domain
-					domainColor.Print(part)
-				} else if i == len(parts)-2 && len(parts) > 2 {
-					// Second to last is usually the domain name
-					domainColor.Print(part)
-				} else {
-					// Earlier parts are subdomains
-					subdomainColor.Print(part)
-				}
-			}
-
-			// Add port if specified and not default
-			if r.port != "" && r.port != "22" {
-				symbolColor.Print(":")
-				portColor.Print(r.port)
-			}
-
-			fmt.Println() // New line
-		}
-		return nil
-	},
+		return puttyResolved(alias), opts, nil
+	}
+	args := transport.ResolveArgs(hostOptions(baseOptions(), alias), alias)
+	debugf(3, "resolving %s: ssh %s", alias, quoteArgv(args))
+	out, err := sshCommand(args...).Output()
+	if err != nil {
+		return sshconf.Resolved{}, nil, fmt.Errorf("ssh -G %s: %w", alias, err)
+	}
+	r, opts := sshconf.ParseResolved(alias, out), sshconf.ParseOptions(out)
+	r.Hostname = canonicalHostname(r.Hostname, opts)
+	return r, opts, nil
 }
 
 var rootCmd = &cobra.Command{
@@ -224,6 +307,11 @@ Examples:
   # Connect with a different user
   gt myserver -u admin
 
+  # Connect to a host without an alias, or from an ssh:// link; either
+  # goes to the alias with that HostName if there is one
+  gt admin@203.0.113.5:2222
+  gt ssh://admin@myserver.example.com:2222
+
   # Run a one-shot command on the remote host
   gt myserver uptime
 
@@ -231,408 +319,573 @@ Examples:
   gt myserver -s file1.txt file2.txt :remote/path/
 
   # Download files from remote host (remote paths must start with ':')
-  gt myserver -s :remote/file1.txt :remote/file2.txt local/path/`,
+  gt myserver -s :remote/file1.txt :remote/file2.txt local/path/
+
+  # Copy a directory (site lands as remote/path/site), or with a trailing
+  # slash what is in it, as rsync does
+  gt myserver -s ./site :remote/path/
+  gt myserver -s ./site/ :remote/path/
+
+  # Paste a file into the remote host's clipboard
+  gt myserver --copy < notes.txt
+
+  # Run a command on several hosts, or upload to each, as gt exec and
+  # gt push do; a list or a @group works too
+  gt web1 web2 db1 -- uptime
+  gt web1,web2 -s app.conf :/etc/app/`,
 	Args:              cobra.MinimumNArgs(1),
 	ValidArgsFunction: completeHosts,
+	PersistentPreRunE: setup,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		alias := args[0]
+		// From here on failures are about the host, not how gt was called.
+		cmd.SilenceUsage = true
 
-		if !knownHost(alias) {
-			return fmt.Errorf("host '%s' not found in SSH config", alias)
+		aliases, rest, batch, err := rootTargets(args, cmd.ArgsLenAtDash())
+		if err != nil {
+			return err
 		}
 		if user != "" {
-			if err := validateNoFlagPrefix("user", user); err != nil {
+			if err := transport.ValidateNoFlagPrefix("user", user); err != nil {
 				return err
 			}
 		}
+		if batch {
+			return runBatch(cmd, aliases, rest)
+		}
+		alias := aliases[0]
 
+		if copyStdin {
+			return runCopy(alias, args[1:])
+		}
+		if useScp && transferDryRun {
+			return dryRun(cmd.OutOrStdout(), alias, args[1:])
+		}
 		if useScp {
-			return runSCP(alias, args[1:])
+			return withHooks(hookPreTransfer, hookPostTransfer, hookEvent{Alias: alias, Files: args[1:]}, func() error {
+				return runSCP(alias, args[1:])
+			})
+		}
+		err = withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: args[1:]}, func() error {
+			return runSSH(alias, args[1:])
+		})
+		if err == nil && len(args) == 1 {
+			maybeSaveAdHocHost()
 		}
-		return runSSH(alias, args[1:])
+		return err
 	},
 }
 
-// knownHost reports whether alias is addressed by a Host block in the
-// config, so a typo fails with a clear error instead of a DNS lookup on
-// the raw alias. Blocks whose only patterns are the catch-all "*" are
-// ignored: those hold global defaults and would make every alias look
-// valid. Wildcard blocks like "Host web-*" still count, and OpenSSH
-// resolves the actual options at exec time.
-func knownHost(alias string) bool {
-	for _, host := range cfg.Hosts {
-		if hasSpecificPattern(host) && host.Matches(alias) {
-			return true
+// rootTargets is leadingTargets, but for an ssh:// URI first, or an
+// address that no Host block has (see addressTarget): that is the one
+// host, as urlTarget finds it. A URI is what a clicked link runs, so it
+// opens a login and nothing else, and one for a host no Host block has
+// is confirmed first (see confirmLinkHost).
+func rootTargets(args []string, dash int) (aliases, rest []string, batch bool, err error) {
+	var u sshURL
+	link := false
+	switch addr, ok := addressTarget(args[0]); {
+	case isSSHURL(args[0]):
+		if len(args) > 1 {
+			return nil, nil, false, errors.New("an ssh:// URI opens a login and takes no command or files after it")
 		}
-	}
-	return false
-}
-
-// hasSpecificPattern reports whether the block names anything beyond the
-// catch-all "*". Pattern.String() strips negation, so a non-"*" pattern
-// counts only if the block would actually apply to it — this keeps a pure
-// exclusion block like "Host * !secret" classified as a catch-all.
-func hasSpecificPattern(host *ssh_config.Host) bool {
-	for _, p := range host.Patterns {
-		if s := p.String(); s != "*" && host.Matches(s) {
-			return true
+		if u, err = parseSSHURL(args[0]); err != nil {
+			return nil, nil, false, err
 		}
+		link = true
+	case ok && !knownHost(expandShortcut(args[0])):
+		u = addr
+	default:
+		return leadingTargets(args, dash)
 	}
-	return false
-}
-
-// sshBaseArgs returns the flags shared by every ssh/scp/ssh -G
-// invocation gt makes: the alternate config file and the user override.
-// Everything else is deliberately left to OpenSSH, which resolves the
-// alias against the config itself.
-func sshBaseArgs() []string {
-	var args []string
-	if cfgFile != "" {
-		args = append(args, "-F", cfgFile)
+	target, err := urlTarget(u)
+	if err != nil {
+		return nil, nil, false, err
 	}
-	if user != "" {
-		args = append(args, "-o", "User="+user)
+	if link && adHocHost != nil {
+		p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+		if err := confirmLinkHost(p, *adHocHost); err != nil {
+			return nil, nil, false, err
+		}
 	}
-	return args
+	return []string{target}, args[1:], false, nil
 }
 
-func completeHosts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	if len(args) != 0 {
-		return nil, cobra.ShellCompDirectiveNoFileComp
+// leadingTargets reads which hosts the root command is for. Usually that
+// is the one alias first; a list ("web1,web2"), a @group, a glob, or several
+// known aliases in a row ("web1 web2 db1 -- uptime") make it a batch.
+// Before a "--" (dash, as cobra reports it), every argument is a target
+// and must be a host; without one the targets end at the first argument
+// that is not a known alias.
+func leadingTargets(args []string, dash int) (aliases, rest []string, batch bool, err error) {
+	n := 1
+	switch {
+	case dash > 1:
+		n = dash
+	case strings.Contains(args[0], ",") || isGroupTarget(args[0]) || hasGlob(args[0]):
+	default:
+		first := expandShortcut(args[0])
+		if !knownHost(first) {
+			alias, ok, err := numberedHost(args[0])
+			if err != nil {
+				return nil, nil, false, err
+			}
+			if !ok {
+				_, err := hostArg(args[0])
+				return nil, nil, false, err
+			}
+			return []string{alias}, args[1:], false, nil
+		}
+		for dash < 0 && n < len(args) && knownHost(expandShortcut(args[n])) {
+			n++
+		}
+		if n == 1 {
+			return []string{first}, args[1:], false, nil
+		}
 	}
-	return getHosts(), cobra.ShellCompDirectiveNoFileComp
+	aliases, err = expandTargets(args[:n])
+	return aliases, args[n:], true, err
 }
 
-func runSCP(alias string, files []string) error {
-	if err := validateSCPPaths(files); err != nil {
-		return err
-	}
+// runBatch is the root command for several hosts: the command is run on
+// each as by gt exec, or with -s the upload made to each as by gt push.
+// Neither can be interactive, so a shell or a download needs one host.
+func runBatch(cmd *cobra.Command, aliases, rest []string) error {
+	target := strings.Join(aliases, ",")
+	switch {
+	case copyStdin:
+		return errors.New("--copy pastes into one host's clipboard; name just one")
+	case useScp:
+		return pushCmd.RunE(cmd, append([]string{target}, rest...))
+	case len(rest) == 0:
+		return fmt.Errorf("%d hosts need a command to run, as in: gt %s -- uptime", len(aliases), strings.Join(aliases, " "))
+	}
+	return execCmd.RunE(cmd, append([]string{target}, rest...))
+}
 
-	// scp reads ssh_config itself, so passing alias:path leaves port,
-	// identity, ProxyJump, and everything else to OpenSSH.
-	args := sshBaseArgs()
-	args = append(args, "-p", "--") // -p preserves attributes; -- ends option parsing
+// knownHost reports whether alias is addressed by a Host block in the
+// config; see sshconf.Known.
+func knownHost(alias string) bool {
+	return sshconf.Known(cfg, alias)
+}
 
-	dest := files[len(files)-1]
-	if strings.HasPrefix(dest, ":") {
-		// Upload: Add all source files then the remote destination
-		args = append(args, files[:len(files)-1]...)
-		args = append(args, alias+dest)
-	} else {
-		// Download: Add remote sources then local destination
-		for _, src := range files[:len(files)-1] {
-			args = append(args, alias+src)
-		}
-		args = append(args, dest)
+// unknownHostError is the error for an alias no Host block matches. On a
+// machine without an SSH config it says so, rather than blaming the
+// alias.
+func unknownHostError(alias string) error {
+	if missingConfig != "" {
+		return withCode(exitHostNotFound, fmt.Errorf("host '%s' not found: there is no SSH config at %s (run 'gt init' to create one)", alias, missingConfig))
 	}
-
-	return runCommandLogged(execCommand("scp", args...), alias, "scp")
+	return withCode(exitHostNotFound, fmt.Errorf("host '%s' not found in SSH config", alias))
 }
 
-func runSSH(alias string, remoteCmd []string) error {
-	// After --, ssh treats the next arg as the destination and everything
-	// after as the remote command, forwarded to the remote shell verbatim.
-	// The alias goes through unresolved so ssh matches Host blocks against
-	// it, exactly as a plain `ssh alias` would.
-	sshArgs := sshBaseArgs()
-	sshArgs = append(sshArgs, "--", alias)
-	sshArgs = append(sshArgs, remoteCmd...)
-	return runCommandLogged(execCommand("ssh", sshArgs...), alias, "ssh")
+// baseOptions carries the settings shared by every ssh/scp/ssh -G
+// invocation gt makes: the alternate config file, the user override,
+// --env, and --quiet and --no-input.
+// A config with encrypted includes is swapped for its decrypted rewrite
+// so ssh sees the same hosts gt does.
+func baseOptions() transport.Options {
+	o := transport.Options{ConfigFile: cfgFile, User: user, Overrides: sshOverrides, Env: envVars, Batch: nonInteractive(), Quiet: quiet,
+		NoPreserve: noPerms && noTimes}
+	if jumpHosts != "" {
+		// As -o, so scp and sftp, which read no -J before OpenSSH 8.0,
+		// take it too. Appended to a copy of what o has so far, so no -o
+		// is lost and sshOverrides itself is never written to.
+		o.Overrides = append(append([]string(nil), o.Overrides...), "ProxyJump="+expandJumpShortcuts(jumpHosts))
+	}
+	if verifyDNS {
+		o.Overrides = append(append([]string(nil), o.Overrides...), "VerifyHostKeyDNS=yes")
+	}
+	if effectiveConfig != "" {
+		o.ConfigFile = effectiveConfig
+	}
+	return o
 }
 
-func runCommand(cmd *exec.Cmd) error {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
+// sshBaseArgs returns baseOptions as flags, for commands gt assembles
+// itself.
+func sshBaseArgs() []string {
+	return baseOptions().BaseArgs()
 }
 
-func validateNoFlagPrefix(name, value string) error {
-	if strings.HasPrefix(value, "-") {
-		return fmt.Errorf("%s must not start with '-' (got %q)", name, value)
+func completeHosts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
-	return nil
-}
-
-func validateSCPPaths(files []string) error {
-	if len(files) < 2 {
-		return fmt.Errorf("SCP requires at least a source and destination")
+	targets := append(describedHosts(), shortcutDescriptions()...)
+	for name := range externalPlugins() {
+		if !builtinCommand(cmd.Root(), name) {
+			targets = append(targets, name+"\tplugin")
+		}
 	}
+	return targets, cobra.ShellCompDirectiveNoFileComp
+}
 
-	// Determine if this is a download based on the first file
-	isDownload := strings.HasPrefix(files[0], ":")
-
-	if isDownload {
-		// For downloads, all source paths must start with :
-		for i := 0; i < len(files)-1; i++ {
-			if !strings.HasPrefix(files[i], ":") {
-				return fmt.Errorf("download paths must start with ':' (got %s)", files[i])
-			}
-		}
-		local := files[len(files)-1]
-		// The last path (destination) must not start with :
-		if strings.HasPrefix(local, ":") {
-			return fmt.Errorf("local destination path must not start with ':' (got %s)", local)
-		}
-		if strings.HasPrefix(local, "-") {
-			return fmt.Errorf("local path must not start with '-' (got %s); prefix it with './'", local)
-		}
-	} else {
-		// For uploads, all source paths must not start with :
-		for i := 0; i < len(files)-1; i++ {
-			src := files[i]
-			if strings.HasPrefix(src, ":") {
-				return fmt.Errorf("local source paths should not contain ':' (got %s)", src)
-			}
-			if strings.HasPrefix(src, "-") {
-				return fmt.Errorf("local path must not start with '-' (got %s); prefix it with './'", src)
-			}
+// describedHosts lists the aliases for completion, each described by its
+// HostName and gt groups for the shells that show descriptions (zsh,
+// fish, PowerShell). The values come from the parsed config, not ssh -G,
+// which would cost a subprocess per host on every Tab press.
+func describedHosts() []string {
+	var hosts []string
+	now := time.Now()
+	for _, alias := range getHosts() {
+		if hostMetaFor(alias).expired(now) {
+			continue // still usable, just not offered
 		}
-		// The last path (destination) must start with :
-		if !strings.HasPrefix(files[len(files)-1], ":") {
-			return fmt.Errorf("remote destination path must start with ':' (got %s)", files[len(files)-1])
+		if d := hostDescription(alias); d != "" {
+			alias += "\t" + d
 		}
+		hosts = append(hosts, alias)
 	}
-
-	return nil
+	return hosts
 }
 
-func Execute() error {
-	return rootCmd.Execute()
+func hostDescription(alias string) string {
+	var parts []string
+	if h, _ := cfg.Get(alias, "HostName"); h != "" && !redactEnabled() {
+		parts = append(parts, strings.ReplaceAll(h, "%h", alias))
+	}
+	for _, g := range hostMetaFor(alias).Groups {
+		parts = append(parts, "@"+g)
+	}
+	return strings.Join(parts, " ")
 }
 
-func initConfig() {
-	if cfgFile != "" {
-		loadConfig(cfgFile)
-		return
+func runSCP(alias string, files []string) error {
+	opts := remoteOpts{}.withFallback(alias)
+	if err := ensureSpace(alias, files, opts); err != nil {
+		return err
 	}
-
-	home, err := os.UserHomeDir()
+	if transferEngine == "tar" {
+		return tarTransfer(alias, files, opts, func(cmd *exec.Cmd) error {
+			return offerHostKeyFix(alias, runCommandLogged(cmd, alias, "scp"))
+		})
+	}
+	files, cleanup, err := prefilter(files)
 	if err != nil {
-		errorColor.Fprintf(os.Stderr, "Error getting home directory: %v\n", err)
-		os.Exit(1)
+		return err
 	}
-
-	loadConfig(filepath.Join(home, ".ssh", "config"))
+	defer cleanup()
+	cmd, err := transferCommand(alias, files, opts)
+	if err != nil {
+		return err
+	}
+	if err := prepareSecurityKey(alias); err != nil {
+		return err
+	}
+	return offerHostKeyFix(alias, runCommandLogged(cmd, alias, "scp"))
 }
 
-func loadConfig(path string) {
-	f, err := os.Open(path)
+// transferCommand builds the scp (or pscp, or with --engine sftp, sftp)
+// command that copies files to or from alias, using the colon shorthand:
+// a leading ":" marks the remote side.
+func transferCommand(alias string, files []string, opts remoteOpts) (*exec.Cmd, error) {
+	files, err := expandRemotePaths(alias, files)
 	if err != nil {
-		errorColor.Fprintf(os.Stderr, "Could not open SSH config at %s: %v\n", path, err)
-		os.Exit(1)
+		return nil, err
 	}
-	defer f.Close()
-
-	if err := validateOpenConfigPerms(path, f); err != nil {
-		errorColor.Fprintf(os.Stderr, "Refusing to load SSH config: %v\n", err)
-		os.Exit(1)
+	if files, opts.recursive, err = planTransfer(alias, files, opts); err != nil {
+		return nil, err
 	}
-
-	decoded, err := decodeConfig(f)
+	switch transferEngine {
+	case "sftp":
+		return sftpTransferCommand(alias, files, opts)
+	case "tar":
+		return nil, errors.New("--engine tar streams through gt itself, which only gt -s and gt push do")
+	}
+	if t := pluginTransport(); t != nil {
+		return transportTransfer(t, alias, opts.transport(alias, verbosity), files)
+	}
+	if usePuTTY() {
+		return pscpCommand(alias, files, opts)
+	}
+	args, err := transport.SCPArgs(opts.transport(alias, verbosity), alias, files)
 	if err != nil {
-		errorColor.Fprintf(os.Stderr, "Error parsing SSH config: %v\n", err)
-		os.Exit(1)
+		return nil, err
 	}
+	return withAskpass(scpCommand(args...), alias), nil
+}
 
-	seen := map[string]struct{}{}
-	if abs, err := filepath.Abs(path); err == nil {
-		seen[abs] = struct{}{}
+func runSSH(alias string, remoteCmd []string) error {
+	if t := pluginTransport(); t != nil {
+		o := hostOptions(baseOptions(), alias)
+		o.Verbosity = verbosity
+		cmd, err := transportCommand(t, alias, o, remoteCmd)
+		if err != nil {
+			return err
+		}
+		return runCommandLogged(cmd, alias, "ssh")
 	}
-	cfg = &ssh_config.Config{Hosts: resolveIncludes(decoded.Hosts, seen)}
+	if usePuTTY() {
+		return runPlink(alias, remoteCmd)
+	}
+	if err := prepareSecurityKey(alias); err != nil {
+		return err
+	}
+	opts := passwordOptions(hostOptions(baseOptions(), alias), alias)
+	opts.Verbosity = verbosity
+	if len(remoteCmd) > 0 {
+		opts = motdOptions(opts, alias)
+	}
+	opts.Extra = fallbackOptions(alias)
+	if len(remoteCmd) == 0 {
+		mux, err := preLoginChecks(alias, opts)
+		if err != nil {
+			return offerHostKeyFix(alias, err)
+		}
+		opts.Extra = append(opts.Extra, mux...)
+		login, err := loginCommand(alias)
+		if err != nil {
+			return err
+		}
+		if login != "" {
+			// A remote command gets no terminal unless asked for.
+			opts.Extra = append(opts.Extra, "-t")
+			remoteCmd = []string{login}
+		}
+	}
+	return offerHostKeyFix(alias, runCommandLogged(withAskpass(sshCommand(transport.SSHArgs(opts, alias, remoteCmd)...), alias), alias, "ssh"))
 }
 
-// decodeConfig parses an SSH config stream, first dropping Match blocks,
-// which the ssh_config library rejects outright ("Match directive parsing
-// is unsupported") even though OpenSSH accepts them. gt only needs Host
-// patterns for alias enumeration and a Match block cannot declare aliases,
-// so skipping the block is faithful. Its body — including any conditional
-// Includes, whose criteria gt could not evaluate anyway — is dropped;
-// OpenSSH still applies all of it at connection time.
-func decodeConfig(r io.Reader) (*ssh_config.Config, error) {
-	var filtered bytes.Buffer
-	sc := bufio.NewScanner(r)
-	skipping := false
-	for sc.Scan() {
-		line := sc.Text()
-		switch configKeyword(line) {
-		case "match":
-			skipping = true
-			continue
-		case "host":
-			skipping = false
+// loginCommand is the remote command that stands in for a plain login
+// when the host has a Dir, a Shell or a TmuxSession, or ships dotfiles;
+// it is "" when none applies. The shell (or tmux) must replace the
+// setup, not run under it.
+func loginCommand(alias string) (string, error) {
+	m := hostMetaFor(alias)
+	cd := ""
+	if m.Dir != "" {
+		expanded, err := expandForHost(alias, []string{m.Dir})
+		if err != nil {
+			return "", err
 		}
-		if skipping {
-			continue
+		cd = "cd " + quoteArgv(expanded)
+	}
+	dotfiles := dotfilesEnabled(alias)
+	if m.Shell == "" && m.TmuxSession == "" && !dotfiles {
+		if cd == "" {
+			return "", nil
 		}
-		filtered.WriteString(line)
-		filtered.WriteByte('\n')
+		return cd + ` && exec "$SHELL" -l`, nil
 	}
-	if err := sc.Err(); err != nil {
-		return nil, err
+
+	var lines []string
+	if cd != "" {
+		lines = append(lines, cd+" || exit")
+	}
+	if m.Shell != "" {
+		if err := transport.ValidateNoFlagPrefix("shell", m.Shell); err != nil {
+			return "", err
+		}
+		shell := quoteArgv([]string{m.Shell})
+		lines = append(lines, fmt.Sprintf(`SHELL=$(command -v %s) || { printf '%%s: no such shell on the host\n' %s >&2; exit 127; }; export SHELL`, shell, shell))
+	}
+	if dotfiles {
+		script, err := dotfilesScript()
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, strings.TrimSuffix(script, "\n"))
+	} else {
+		lines = append(lines, `set -- "$SHELL" -l`)
+	}
+	if m.TmuxSession != "" {
+		if err := transport.ValidateNoFlagPrefix("tmux_session", m.TmuxSession); err != nil {
+			return "", err
+		}
+		// An existing session is attached as is; a new one starts the
+		// shell above. Without tmux the shell starts on its own.
+		lines = append(lines, fmt.Sprintf(`command -v tmux >/dev/null 2>&1 && exec tmux new-session -A -s %s "$*"`, quoteArgv([]string{m.TmuxSession})),
+			`echo "tmux not found; starting a plain shell" >&2`)
 	}
-	return ssh_config.Decode(&filtered)
+	lines = append(lines, `exec "$@"`)
+	return shellScript(strings.Join(lines, "\n")), nil
 }
 
-// configKeyword returns the lowercased leading keyword of a config line,
-// or "" for blanks and comments. Keywords may be separated from their
-// arguments by whitespace or '='.
-func configKeyword(line string) string {
-	trimmed := strings.TrimSpace(line)
-	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-		return ""
+func runCommand(cmd *exec.Cmd) error {
+	debugf(1, "exec: %s", quoteArgv(cmd.Args))
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
 	}
-	if i := strings.IndexAny(trimmed, " \t="); i >= 0 {
-		trimmed = trimmed[:i]
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
 	}
-	return strings.ToLower(trimmed)
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	return runTracked(cmd, true)
 }
 
-// resolveIncludes walks the host list and replaces every Include node with
-// the hosts it resolves to, recursively. Includes inside included files are
-// expanded the same way, so chains like main -> ~/.ssh/config.d/* -> shared
-// load fully. An Include that sits inside a Host block is conditional in
-// OpenSSH — its content only applies when the enclosing block matches the
-// queried host — so hosts expanded from one are filtered through the
-// enclosing block's patterns rather than merged wholesale. seen holds
-// absolute paths of files already merged so a cycle terminates instead of
-// looping forever. Note: the underlying library has its own depth-5 guard
-// inside Decode, which catches absolute-path cycles before this layer ever
-// sees them; our seen set covers cycles it resolves differently than gt.
-func resolveIncludes(hosts []*ssh_config.Host, seen map[string]struct{}) []*ssh_config.Host {
-	out := make([]*ssh_config.Host, 0, len(hosts))
-	for _, host := range hosts {
-		out = append(out, host)
-		for _, node := range host.Nodes {
-			include, ok := node.(*ssh_config.Include)
-			if !ok {
-				continue
-			}
-			out = append(out, filterConditional(host, expandInclude(include, seen))...)
-		}
+func Execute() error {
+	defer handleSignals()()
+	defer onCleanup(removeRuntimeDir)()
+	var err error
+	if path, args, ok := pluginInvocation(rootCmd, os.Args[1:]); ok {
+		err = runPlugin(path, args)
+	} else {
+		err = rootCmd.Execute()
 	}
-	return out
+	// A plugin or remote command that exited non-zero has already said
+	// why; its status is passed on as gt's own.
+	if err != nil && !passthrough(err) {
+		errorColor.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	return err
 }
 
-// filterConditional applies OpenSSH's conditional-include semantics to
-// hosts expanded from an Include node found inside the enclosing block.
-// The catch-all block (the library's implicit top-of-file "Host *", or an
-// explicit one) matches every query, so its includes pass through intact.
-// Anything else only takes effect when the enclosing block matches, so an
-// alias is kept only if the enclosing block would match it too.
-func filterConditional(enclosing *ssh_config.Host, hosts []*ssh_config.Host) []*ssh_config.Host {
-	if len(enclosing.Patterns) == 1 && enclosing.Patterns[0].String() == "*" {
-		return hosts
-	}
-	var out []*ssh_config.Host
-	for _, h := range hosts {
-		var kept []*ssh_config.Pattern
-		for _, p := range h.Patterns {
-			if enclosing.Matches(p.String()) {
-				kept = append(kept, p)
-			}
-		}
-		if len(kept) > 0 {
-			out = append(out, &ssh_config.Host{Patterns: kept, Nodes: h.Nodes})
-		}
+// setup loads gt's own config and the SSH config before any command
+// runs. Shell completion is the exception to failing on a broken config:
+// an error there would be printed into the user's prompt, so completion
+// falls back to an empty config and simply offers no hosts.
+func setup(cmd *cobra.Command, args []string) error {
+	err := initConfig()
+	if err == nil {
+		return nil
+	}
+	if isCompletionRequest(cmd) {
+		debugf(1, "completion without config: %v", err)
+		cfg = &ssh_config.Config{}
+		return nil
 	}
-	return out
+	// The usage text says nothing about a broken config file.
+	cmd.SilenceUsage = true
+	return err
 }
 
-// includeDirectives extracts the path arguments from an Include node.
-// The node's String() renders the whole config line — leading indentation,
-// optional "=", trailing comment — so strip the decoration down to the
-// space-separated paths. An Include line may name several.
-func includeDirectives(include *ssh_config.Include) []string {
-	line := strings.TrimSpace(include.String())
-	if i := strings.Index(line, "#"); i >= 0 {
-		line = line[:i]
-	}
-	line = strings.TrimSpace(strings.TrimPrefix(line, "Include"))
-	line = strings.TrimPrefix(line, "=")
-	return strings.Fields(line)
+// isCompletionRequest reports whether cmd is cobra's hidden command that
+// shells call on every Tab press.
+func isCompletionRequest(cmd *cobra.Command) bool {
+	return cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd
 }
 
-func expandInclude(include *ssh_config.Include, seen map[string]struct{}) []*ssh_config.Host {
-	var matches []string
-	for _, directive := range includeDirectives(include) {
-		expanded, err := filepath.Glob(resolveIncludePath(directive))
-		if err != nil {
-			continue
-		}
-		matches = append(matches, expanded...)
+func initConfig() error {
+	if err := applyColorMode(colorMode); err != nil {
+		return err
 	}
-	var hosts []*ssh_config.Host
-	for _, match := range matches {
-		abs, err := filepath.Abs(match)
-		if err != nil {
-			abs = match
-		}
-		if _, dup := seen[abs]; dup {
-			continue // already loaded somewhere up the chain
-		}
-		f, err := os.Open(match)
-		if err != nil {
-			continue
-		}
-		if err := validateOpenConfigPerms(match, f); err != nil {
-			warningColor.Fprintf(os.Stderr, "Skipping include: %v\n", err)
-			f.Close()
-			continue
+	for _, opt := range sshOverrides {
+		if err := validateOverride(opt); err != nil {
+			return err
 		}
-		decoded, err := decodeConfig(f)
-		f.Close()
-		if err != nil {
-			continue
+	}
+	for _, e := range envVars {
+		if err := transport.ValidateEnv(e); err != nil {
+			return err
 		}
-		// Mark before recursing so a self-referential include terminates.
-		seen[abs] = struct{}{}
-		hosts = append(hosts, resolveIncludes(decoded.Hosts, seen)...)
 	}
-	return hosts
-}
+	if err := transport.ValidateNoFlagPrefix("--jump", jumpHosts); err != nil {
+		return err
+	}
+	if err := validateEngine(); err != nil {
+		return err
+	}
+	if err := loadGTSettings(); err != nil {
+		return withCode(exitConfig, err)
+	}
+	if _, err := activeBackend(); err != nil {
+		return withCode(exitConfig, fmt.Errorf("gt config: %w", err))
+	}
+	if verifyDNS && usePuTTY() {
+		return errors.New("--verify-dns needs the OpenSSH backend; PuTTY does not look up SSHFP records")
+	}
+	if useGSSAPI && usePuTTY() {
+		return errors.New("--gssapi needs the OpenSSH backend; PuTTY takes its GSSAPI settings from the saved session")
+	}
+	if passwordCmd != "" && (usePuTTY() || pluginTransport() != nil || runtime.GOOS == "windows") {
+		return errors.New("--password-cmd needs the OpenSSH backend, on a system other than Windows")
+	}
 
-// validateOpenConfigPerms refuses to parse a config file that another local
-// user could have tampered with. Mirrors OpenSSH's StrictModes-style check on
-// client config files: must be owned by the running user (or root) and must
-// not be group/world writable. Stat is taken from the open fd so the result
-// describes the same inode we will read, closing the TOCTOU window between
-// the check and the parse.
-func validateOpenConfigPerms(path string, f *os.File) error {
-	info, err := f.Stat()
+	path, err := sshConfigPath()
 	if err != nil {
-		return fmt.Errorf("%s: %w", path, err)
+		return err
 	}
-	stat, ok := info.Sys().(*syscall.Stat_t)
-	if !ok {
-		return nil // Non-unix filesystem; mode/uid semantics differ.
+	return withCode(exitConfig, loadConfig(path))
+}
+
+// sshConfigPath is the SSH config gt reads: --config, or ~/.ssh/config.
+func sshConfigPath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
 	}
-	return checkConfigOwnerAndMode(path, stat.Uid, info.Mode().Perm(), uint32(os.Getuid()))
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
 }
 
-func checkConfigOwnerAndMode(path string, fileUID uint32, mode os.FileMode, runningUID uint32) error {
-	if fileUID != runningUID && fileUID != 0 {
-		return fmt.Errorf("%s: bad ownership (uid %d; expected %d or root)", path, fileUID, runningUID)
+// loadGTSettings loads gt's own config and applies its theme. Unlike the
+// SSH config, a missing file is fine; a broken one is still an error so
+// a typo does not silently fall back to defaults.
+func loadGTSettings() error {
+	path, err := gtConfigPath()
+	if err != nil {
+		return fmt.Errorf("could not locate gt config: %w", err)
 	}
-	if mode&0o022 != 0 {
-		return fmt.Errorf("%s: bad permissions %#o (group/world writable)", path, mode)
+	if gtCfg, err = loadGTConfig(path); err != nil {
+		return fmt.Errorf("could not load gt config: %w", err)
+	}
+	p, err := shortcutsPath()
+	if err != nil {
+		return err
+	}
+	if shortcuts, err = loadShortcuts(p); err != nil {
+		return fmt.Errorf("could not load gt aliases: %w", err)
+	}
+	if err := applyTheme(gtCfg.Theme); err != nil {
+		return fmt.Errorf("gt config %s: %w", path, err)
 	}
 	return nil
 }
 
-// resolveIncludePath mirrors OpenSSH: "~" expands to the home directory,
-// and relative paths resolve against ~/.ssh — never against the directory
-// of the including file, no matter where that file lives.
-func resolveIncludePath(path string) string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return path
+func loadConfig(path string) error {
+	missingConfig = ""
+	effectiveConfig = ""
+	var mainBody []byte
+	encrypted := false
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		// A fresh machine has no config yet; that is not an error, just
+		// nothing to list.
+		debugf(1, "no SSH config at %s", path)
+		missingConfig = path
+		cfg, loadedFiles = &ssh_config.Config{}, nil
+	} else {
+		// Encrypted includes are only honored in the main config: it is
+		// the one file gt can hand to ssh in rewritten form.
+		decryptMarkers := func(body []byte) ([]byte, []string, error) {
+			expanded, sources, err := expandEncryptedIncludes(body)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error decrypting SSH config include: %w", err)
+			}
+			if len(sources) == 0 {
+				mainBody = body
+				return body, nil, nil
+			}
+			mainBody, encrypted = expanded, true
+			return expanded, sources, nil
+		}
+
+		loaded, err := sshconf.Load(path, sshconf.Options{
+			Preprocess: decryptMarkers,
+			Logf:       func(format string, args ...any) { debugf(2, format, args...) },
+			Warnf: func(format string, args ...any) {
+				warningColor.Fprintf(os.Stderr, format+"\n", args...)
+			},
+			Internal: isRuntimeFile,
+		})
+		if err != nil {
+			return err
+		}
+		cfg = loaded.Config
+		loadedFiles = loaded.Files
 	}
-	if strings.HasPrefix(path, "~") {
-		return filepath.Join(home, path[1:])
+
+	team, err := overlayTeam(cfg)
+	if err != nil {
+		return err
 	}
-	if !filepath.IsAbs(path) {
-		return filepath.Join(home, ".ssh", path)
+	if encrypted || team != "" {
+		if effectiveConfig, err = writeEffectiveConfig(mainBody, team); err != nil {
+			return fmt.Errorf("could not stage SSH config: %w", err)
+		}
 	}
-	return path
+	return nil
 }
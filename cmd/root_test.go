@@ -1,16 +1,24 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 
 	"github.com/kevinburke/ssh_config"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/sshconf"
 )
 
 // mockCommand replaces exec.Command for testing. It records every
@@ -52,6 +60,33 @@ func useMockExec(t *testing.T) {
 	mockCmd.reset()
 }
 
+// useWebGroup loads hosts web-1, web-2 and down, all in group @web, the
+// fleet most tests run against.
+func useWebGroup(t *testing.T) {
+	t.Helper()
+	origCfg, origGT := cfg, gtCfg
+	t.Cleanup(func() { cfg, gtCfg = origCfg, origGT })
+	decoded, err := ssh_config.Decode(strings.NewReader("Host web-1 web-2 down\n  User deploy\n"))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	cfg = decoded
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{
+		"web-1": {Groups: []string{"web"}},
+		"web-2": {Groups: []string{"web"}},
+		"down":  {Groups: []string{"web"}},
+	}}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // TestHelperProcess isn't a real test. It's used to mock exec.Command
 func TestHelperProcess(t *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
@@ -73,109 +108,240 @@ func TestHelperProcess(t *testing.T) {
 	// Mock different commands
 	switch args[0] {
 	case "ssh":
+		if len(args) == 2 && args[1] == "-V" {
+			fmt.Fprintln(os.Stderr, "OpenSSH_9.6p1, OpenSSL 3.0.13 30 Jan 2024")
+			os.Exit(0)
+		}
 		for _, a := range args[1:] {
+			if a == "-G" && contains(args, os.DevNull) {
+				// ssh -G with an empty config: OpenSSH's defaults.
+				fmt.Println("user testuser")
+				fmt.Println("port 22")
+				fmt.Println("identityfile ~/.ssh/id_ed25519")
+				fmt.Println("forwardagent no")
+				break
+			}
 			if a == "-G" {
 				// Emulate ssh -G's resolved key-value output.
 				fmt.Println("user testuser")
 				fmt.Println("hostname test.example.com")
 				fmt.Println("port 2222")
 				fmt.Println("identityfile ~/.ssh/test_key")
+				for _, o := range args {
+					if v, ok := strings.CutPrefix(o, "ProxyJump="); ok {
+						fmt.Println("proxyjump " + v)
+					}
+				}
+				break
+			}
+			if a == "-vv" && args[len(args)-2] != "down" {
+				// The handshake gt audit ssh reads: web-2 runs an old sshd.
+				fmt.Fprint(os.Stderr, mockHandshake(args[len(args)-2]))
+				break
+			}
+			if a == "BatchMode=yes" && contains(args, "-v") {
+				// Emulate the ssh -v phase markers gt bench times.
+				fmt.Fprintln(os.Stderr, "debug1: Connection established.")
+				fmt.Fprintln(os.Stderr, "debug1: SSH2_MSG_NEWKEYS received")
+				fmt.Fprintln(os.Stderr, "Authenticated to test.example.com ([192.0.2.1]:2222) using \"publickey\".")
+				break
+			}
+		}
+		// Emulate a remote "echo ..." so tests can see command output; a
+		// host named "down" is unreachable, one named "rekeyed" has a new
+		// host key.
+		for i, a := range args {
+			if a == "--" && i+1 < len(args) && args[i+1] == "down" {
+				fmt.Fprintln(os.Stderr, "ssh: connect to host down port 22: Connection refused")
+				os.Exit(255)
+			}
+			if a == "--" && i+1 < len(args) && args[i+1] == "rekeyed" {
+				f := "/nonexistent/known_hosts"
+				fmt.Fprintf(os.Stderr, changedKeyStderr, f, f, f)
+				os.Exit(255)
+			}
+			if a == "--" && i+2 < len(args) && strings.Contains(args[i+2], `df -Pk -- "$d"`) {
+				// Free space for gt's pre-upload check: 1 MiB.
+				fmt.Println("1048576")
+			}
+			if a == "--" && i+2 < len(args) && strings.Contains(args[i+2], "find -H") {
+				// Two files under logs for gt's dry-run listing.
+				fmt.Print("0\t     12\tlogs/a.log\x000\t2048\tlogs/b c.log\x00")
 				break
 			}
+			if a == "--" && i+2 < len(args) && strings.Contains(args[i+2], `\t%s\t%s\0`) {
+				fmt.Print(mockGlob(args[i+2]))
+			}
+			if a == "--" && i+2 < len(args) && strings.Contains(args[i+2], "trustedusercakeys") && !strings.Contains(args[i+2], "prepend") {
+				// gt ca status: web-1 trusts the CA, others do not.
+				if args[i+1] == "web-1" {
+					fmt.Println("yes /etc/ssh/gt_user_ca.pub")
+				} else {
+					fmt.Println("no /etc/ssh/gt_user_ca.pub")
+				}
+			}
+			if a == "--" && i+2 < len(args) && strings.Contains(args[i+2], "hushlogin=yes") {
+				// gt motd: a banner on stderr, then the message of the day.
+				fmt.Fprintln(os.Stderr, "Warning: Permanently added 'web-1' (ED25519) to the list of known hosts.")
+				fmt.Fprintln(os.Stderr, "Authorized use only.")
+				fmt.Println("hushlogin=yes")
+				fmt.Println("Welcome to web-1")
+			}
+			if a == "--" && i+2 < len(args) && strings.Contains(args[i+2], "offset=$(date +%z)") {
+				// The timezone check ahead of a login: a host in Tokyo.
+				fmt.Print("offset=+0900\nabbrev=JST\nzone=Asia/Tokyo\nlocale=ja_JP.UTF-8\n")
+			}
+			if a == "--" && i+2 < len(args) && strings.Contains(args[i+2], "LC_ALL=C who") && args[i+1] == "web-1" {
+				// The sessions check ahead of a login: two admins on web-1.
+				fmt.Print("alice    pts/0        2026-10-14 09:12 (10.0.0.5)\nbob      pts/1        2026-10-14 10:02 (10.0.0.7)\n")
+			}
+			if a == "--" && i+2 == len(args) && contains(args, "-T") {
+				// A shell reading its commands from stdin, as gt broadcast
+				// starts: it echoes each line, and "exit N" ends it.
+				scanner := bufio.NewScanner(os.Stdin)
+				for scanner.Scan() {
+					if code, ok := strings.CutPrefix(scanner.Text(), "exit "); ok {
+						n, _ := strconv.Atoi(code)
+						os.Exit(n)
+					}
+					fmt.Println(scanner.Text())
+				}
+				os.Exit(0)
+			}
+			if a == "--" && i+3 == len(args) && strings.HasPrefix(args[i+2], "echo ") {
+				// A command line as gt shell sends it, in one piece.
+				fmt.Println(strings.TrimPrefix(args[i+2], "echo "))
+			}
+			if a == "--" && i+3 == len(args) && strings.HasPrefix(args[i+2], "exit ") {
+				n, _ := strconv.Atoi(strings.TrimPrefix(args[i+2], "exit "))
+				os.Exit(n)
+			}
+			if a == "--" && i+2 < len(args) && args[i+2] == "echo" {
+				fmt.Println(strings.Join(args[i+3:], " "))
+			}
+		}
+		os.Exit(0)
+	case "ssh-keyscan":
+		host := args[len(args)-1]
+		fmt.Println("# " + host + ":22 SSH-2.0-OpenSSH_9.6")
+		fmt.Println(host + " ssh-rsa AAAAB3NzaC1yc2E")
+		fmt.Println(host + " ssh-ed25519 AAAAC3NzaC1lZDI1NTE5")
+		os.Exit(0)
+	case "klist":
+		// A ticket for alice; "klist-expired" has none.
+		if len(args) == 1 {
+			fmt.Println("Ticket cache: FILE:/tmp/krb5cc_1000")
+			fmt.Println("Default principal: alice@EXAMPLE.COM")
+		}
+		os.Exit(0)
+	case "klist-expired":
+		os.Exit(1)
+	case "secret-tool", "security":
+		// The keychain has a passphrase for keys named id_locked only.
+		key := args[len(args)-1]
+		if key == "-w" {
+			key = args[len(args)-2]
+		}
+		if !strings.HasSuffix(key, "id_locked") {
+			os.Exit(1)
+		}
+		fmt.Print("s3cret")
+		os.Exit(0)
+	case "ssh-keygen":
+		if len(args) == 5 && args[1] == "-F" && args[3] == "-f" {
+			// Look the host up in the known_hosts file, unhashed only.
+			data, _ := os.ReadFile(args[4])
+			found := false
+			for _, line := range strings.Split(string(data), "\n") {
+				if f := strings.Fields(line); len(f) > 0 && f[0] == args[2] {
+					fmt.Println(line)
+					found = true
+				}
+			}
+			if !found {
+				os.Exit(1)
+			}
+		}
+		os.Exit(0)
+	case "scp", "plink", "pscp":
+		// A host named "down" is unreachable.
+		for _, a := range args[1:] {
+			if strings.HasPrefix(a, "down:") {
+				fmt.Fprintln(os.Stderr, "ssh: connect to host down port 22: Connection refused")
+				os.Exit(1)
+			}
 		}
 		os.Exit(0)
-	case "scp":
-		// For SCP, we could validate the arguments if needed
+	case "sftp":
+		// Read the batch; a host named "down" is unreachable.
+		io.Copy(io.Discard, os.Stdin)
+		if args[len(args)-1] == "down" {
+			fmt.Fprintln(os.Stderr, "ssh: connect to host down port 22: Connection refused")
+			os.Exit(255)
+		}
+		os.Exit(0)
+	case "pbcopy", "clip", "wl-copy", "xclip", "xsel":
+		io.Copy(io.Discard, os.Stdin)
+		os.Exit(0)
+	case "xdg-open", "open", "rundll32", "xdg-mime":
+		os.Exit(0)
+	case "age", "gpg":
+		if contains(args, "--list-packets") {
+			fmt.Println(":pubkey enc packet: version 3, algo 18, keyid 0123456789ABCDEF")
+			os.Exit(0)
+		}
+		if contains(args, "--encrypt") {
+			// "Encrypt" by marking the plaintext.
+			data, _ := io.ReadAll(os.Stdin)
+			fmt.Print("ENC:" + string(data))
+			os.Exit(0)
+		}
+		// Emulate decrypting an encrypted include to stdout.
+		fmt.Println("Host secret")
+		fmt.Println("  Hostname secret.example.com")
 		os.Exit(0)
 	default:
 		os.Exit(1)
 	}
 }
 
-func TestValidateSCPPaths(t *testing.T) {
-	tests := []struct {
-		name    string
-		files   []string
-		wantErr bool
-		errMsg  string
-	}{
-		{
-			name:    "no files",
-			files:   []string{},
-			wantErr: true,
-			errMsg:  "SCP requires at least a source and destination",
-		},
-		{
-			name:    "single file",
-			files:   []string{"file.txt"},
-			wantErr: true,
-			errMsg:  "SCP requires at least a source and destination",
-		},
-		{
-			name:    "valid upload",
-			files:   []string{"local.txt", ":remote/path"},
-			wantErr: false,
-		},
-		{
-			name:    "valid download",
-			files:   []string{":remote.txt", "local/path"},
-			wantErr: false,
-		},
-		{
-			name:    "invalid upload - destination without colon",
-			files:   []string{"local.txt", "remote/path"},
-			wantErr: true,
-			errMsg:  "remote destination path must start with ':' (got remote/path)",
-		},
-		{
-			name:    "invalid download - source without colon",
-			files:   []string{"remote.txt", "local/path"},
-			wantErr: true,
-			errMsg:  "remote destination path must start with ':' (got local/path)",
-		},
-		{
-			name:    "multiple file upload",
-			files:   []string{"local1.txt", "local2.txt", ":remote/path"},
-			wantErr: false,
-		},
-		{
-			name:    "multiple file download",
-			files:   []string{":remote1.txt", ":remote2.txt", "local/path"},
-			wantErr: false,
-		},
-		{
-			name:    "mixed upload paths",
-			files:   []string{"local1.txt", ":remote1.txt", ":remote/path"},
-			wantErr: true,
-			errMsg:  "local source paths should not contain ':' (got :remote1.txt)",
-		},
-		{
-			name:    "upload local path starting with -",
-			files:   []string{"-oProxyCommand=evil", ":remote/path"},
-			wantErr: true,
-			errMsg:  "local path must not start with '-' (got -oProxyCommand=evil); prefix it with './'",
-		},
-		{
-			name:    "download local destination starting with -",
-			files:   []string{":remote.txt", "-oProxyCommand=evil"},
-			wantErr: true,
-			errMsg:  "local path must not start with '-' (got -oProxyCommand=evil); prefix it with './'",
-		},
+// mockGlob answers gt's remote lookup of paths, a loop per pattern, from
+// a fake tree: a directory logs holding a.log, "b c.log" and .env, an
+// empty directory empty and a file app.conf.
+func mockGlob(script string) string {
+	var b strings.Builder
+	match := func(n int, typ string, paths ...string) {
+		for _, p := range paths {
+			fmt.Fprintf(&b, "%d\t%s\t%s\x00", n, typ, p)
+		}
 	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateSCPPaths(tt.files)
-			if tt.wantErr {
-				assert.Error(t, err)
-				if tt.errMsg != "" {
-					assert.Equal(t, tt.errMsg, err.Error())
-				}
-			} else {
-				assert.NoError(t, err)
+	n := 0
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.Contains(line, "for f in") {
+			continue
+		}
+		pattern := line[:strings.Index(line, "; do")]
+		switch {
+		case strings.Contains(pattern, ".log") && strings.Contains(pattern, "*"):
+			match(n, "f", "logs/a.log", "logs/b c.log")
+		case strings.Contains(pattern, "[!") || strings.Contains(pattern, "?"):
+			if strings.Contains(pattern, "logs/") && strings.Contains(pattern, "[!") {
+				match(n, "f", "logs/.env")
 			}
-		})
+		case strings.Contains(pattern, "*"):
+			if strings.Contains(pattern, "logs/") {
+				match(n, "f", "logs/a.log", "logs/b c.log")
+			}
+		case strings.Contains(pattern, "logs"):
+			match(n, "d", "logs")
+		case strings.Contains(pattern, "empty"):
+			match(n, "d", "empty")
+		case strings.Contains(pattern, "app.conf"):
+			match(n, "f", "app.conf")
+		}
+		n++
 	}
+	return b.String()
 }
 
 func TestRunSCP(t *testing.T) {
@@ -235,12 +401,124 @@ func TestRunSCP(t *testing.T) {
 				return
 			}
 			assert.NoError(t, err)
-			assert.Equal(t, "scp", mockCmd.commands[0])
-			assert.Equal(t, tt.wantArgs, mockCmd.argLists[0])
+			assert.Equal(t, tt.wantArgs, mockRun("scp"))
 		})
 	}
 }
 
+func TestLeadingTargets(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useWebGroup(t)
+	tests := []struct {
+		args        []string
+		dash        int
+		wantAliases []string
+		wantRest    []string
+		wantBatch   bool
+		wantErr     string
+	}{
+		{[]string{"web-1", "uptime"}, -1, []string{"web-1"}, []string{"uptime"}, false, ""},
+		{[]string{"web-1", "--", "uptime"}, 1, []string{"web-1"}, []string{"--", "uptime"}, false, ""},
+		{[]string{"web-1", "web-2", "uptime"}, -1, []string{"web-1", "web-2"}, []string{"uptime"}, true, ""},
+		{[]string{"web-1", "web-2", "uptime"}, 2, []string{"web-1", "web-2"}, []string{"uptime"}, true, ""},
+		{[]string{"web-1", "web-2", "web-1"}, 1, []string{"web-1"}, []string{"web-2", "web-1"}, false, ""},
+		{[]string{"web-1,web-2", "file", ":/tmp/"}, -1, []string{"web-1", "web-2"}, []string{"file", ":/tmp/"}, true, ""},
+		{[]string{"@web", "uptime"}, -1, []string{"web-1", "web-2", "down"}, []string{"uptime"}, true, ""},
+		{[]string{"web-1", "nope", "uptime"}, 2, nil, nil, false, "host 'nope' not found"},
+		{[]string{"nope", "uptime"}, -1, nil, nil, false, "host 'nope' not found"},
+		{[]string{"3", "uptime"}, -1, []string{"web-2"}, []string{"uptime"}, false, ""},
+		{[]string{"9"}, -1, nil, nil, false, "no host 9"},
+	}
+	for _, tt := range tests {
+		aliases, rest, batch, err := leadingTargets(tt.args, tt.dash)
+		if tt.wantErr != "" {
+			assert.ErrorContains(t, err, tt.wantErr, tt.args)
+			continue
+		}
+		assert.NoError(t, err, tt.args)
+		assert.ElementsMatch(t, tt.wantAliases, aliases, tt.args)
+		assert.Equal(t, tt.wantRest, rest, tt.args)
+		assert.Equal(t, tt.wantBatch, batch, tt.args)
+	}
+}
+
+func TestRootBatch(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	plainOutput(t)
+	useWebGroup(t)
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	defer rootCmd.SetOut(nil)
+
+	require.NoError(t, runBatch(rootCmd, []string{"web-1", "web-2"}, []string{"uptime"}))
+	ran := 0
+	for i, c := range mockCmd.commands {
+		if c == "ssh" && contains(mockCmd.argLists[i], "uptime") {
+			ran++
+		}
+	}
+	assert.Equal(t, 2, ran)
+	assert.Contains(t, out.String(), "2 ok")
+
+	err := runBatch(rootCmd, []string{"web-1", "web-2"}, nil)
+	assert.EqualError(t, err, "2 hosts need a command to run, as in: gt web-1 web-2 -- uptime")
+
+	defer func() { useScp = false }()
+	useScp = true
+	mockCmd.reset()
+	conf := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("x"), 0o644))
+	require.NoError(t, runBatch(rootCmd, []string{"web-1", "web-2"}, []string{conf, ":/etc/app/"}))
+	var dests []string
+	for i, c := range mockCmd.commands {
+		if c == "scp" {
+			args := mockCmd.argLists[i]
+			dests = append(dests, args[len(args)-1])
+		}
+	}
+	assert.ElementsMatch(t, []string{"web-1:/etc/app/", "web-2:/etc/app/"}, dests)
+}
+
+func TestRunSCPMinimalConfig(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	origCfg := cfg
+	defer func() { cfg = origCfg }()
+	decoded, err := ssh_config.Decode(strings.NewReader("Host bare\n  HostName bare.example.com\n"))
+	assert.NoError(t, err)
+	cfg = decoded
+
+	// No Port, User or IdentityFile: scp gets the alias and its defaults,
+	// never an empty -P or -i.
+	assert.NoError(t, runSCP("bare", []string{"local.txt", ":remote/path"}))
+	assert.Equal(t, []string{"-p", "--", "local.txt", "bare:remote/path"}, mockRun("scp"))
+}
+
+func TestRunSCPWithJump(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	defer func() { jumpHosts = "" }()
+	jumpHosts = "bastion,ops@edge:2222"
+
+	assert.NoError(t, runSCP("testserver", []string{"local.txt", ":remote/path"}))
+	assert.Equal(t, []string{"-o", "ProxyJump=bastion,ops@edge:2222", "-p", "--", "local.txt", "testserver:remote/path"}, mockRun("scp"))
+	assert.Empty(t, sshOverrides, "-o is left alone")
+}
+
+func TestBaseOptionsJumpKeepsOverrides(t *testing.T) {
+	defer func() { sshOverrides, jumpHosts, verifyDNS = nil, "", false }()
+	sshOverrides = make([]string, 1, 4)
+	sshOverrides[0] = "ForwardAgent=no"
+	jumpHosts, verifyDNS = "bastion", true
+
+	o := baseOptions()
+	assert.Equal(t, []string{"ForwardAgent=no", "ProxyJump=bastion", "VerifyHostKeyDNS=yes"}, o.Overrides, "-o options come first, none dropped")
+	assert.Equal(t, []string{"ForwardAgent=no"}, sshOverrides)
+	assert.Equal(t, o.Overrides, baseOptions().Overrides, "a second call adds nothing twice")
+	assert.Empty(t, sshOverrides[:2][1], "-o's backing array is not written to")
+}
+
 func TestRunSCPWithOverrides(t *testing.T) {
 	t.Setenv("GT_LOG_DIR", t.TempDir())
 	useMockExec(t)
@@ -282,7 +560,9 @@ func TestLoadConfigResolvesNestedIncludes(t *testing.T) {
 	writeConfigFile(t, inc1, "Include "+inc2+"\n\nHost beta\n  Hostname beta.example.com\n")
 	writeConfigFile(t, inc2, "Host gamma\n  Hostname gamma.example.com\n")
 
-	loadConfig(main)
+	if err := loadConfig(main); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
 
 	got := getHosts()
 	assert.Equal(t, []string{"alpha", "beta", "gamma"}, got)
@@ -299,7 +579,9 @@ func TestConditionalIncludeFilteredByEnclosingBlock(t *testing.T) {
 	// block cannot match must not be enumerated or vouched for.
 	writeConfigFile(t, main, "Host gw-*\n  User gateway\n  Include "+inc+"\n")
 
-	loadConfig(main)
+	if err := loadConfig(main); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
 
 	assert.Equal(t, []string{"gw-1"}, getHosts())
 	assert.True(t, knownHost("gw-1"))
@@ -329,7 +611,9 @@ Match all
   ServerAliveInterval 60
 `)
 
-	loadConfig(main)
+	if err := loadConfig(main); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
 
 	assert.Equal(t, []string{"alpha", "beta"}, getHosts())
 	assert.False(t, knownHost("hidden"), "Include inside a Match block must not be expanded")
@@ -350,11 +634,45 @@ func TestRelativeIncludeResolvesAgainstSSHDir(t *testing.T) {
 	main := filepath.Join(home, "mainconfig")
 	writeConfigFile(t, main, "Include extra\n\nHost alpha\n  Hostname alpha.example.com\n")
 
-	loadConfig(main)
+	if err := loadConfig(main); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
 
 	assert.Equal(t, []string{"alpha", "relhost"}, getHosts())
 }
 
+// useBrokenConfig points gt at an SSH config it must refuse to load.
+func useBrokenConfig(t *testing.T) {
+	t.Helper()
+	t.Setenv("GT_CONFIG", filepath.Join(t.TempDir(), "none.yaml"))
+	origCfgFile, origCfg := cfgFile, cfg
+	t.Cleanup(func() { cfgFile, cfg = origCfgFile, origCfg })
+	cfgFile = filepath.Join(t.TempDir(), "config")
+	writeConfigFile(t, cfgFile, "Host alpha\n")
+	if err := os.Chmod(cfgFile, 0o666); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+}
+
+func TestSetupReturnsConfigErrors(t *testing.T) {
+	useBrokenConfig(t)
+
+	cmd := &cobra.Command{Use: "list"}
+	err := setup(cmd, nil)
+	assert.ErrorContains(t, err, "refusing to load SSH config")
+	assert.True(t, cmd.SilenceUsage, "a config error is not a usage error")
+}
+
+func TestSetupFallsBackForCompletion(t *testing.T) {
+	useBrokenConfig(t)
+
+	for _, name := range []string{cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd} {
+		cfg = nil
+		assert.NoError(t, setup(&cobra.Command{Use: name}, nil))
+		assert.Empty(t, getHosts(), "completion offers nothing rather than failing")
+	}
+}
+
 func TestGetHostsMultiPatternAndDedup(t *testing.T) {
 	mkPatterns := func(t *testing.T, names ...string) []*ssh_config.Pattern {
 		out := make([]*ssh_config.Pattern, 0, len(names))
@@ -382,41 +700,6 @@ func TestGetHostsMultiPatternAndDedup(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
-func TestCheckConfigOwnerAndMode(t *testing.T) {
-	const me uint32 = 1000
-	const other uint32 = 1234
-	const root uint32 = 0
-
-	tests := []struct {
-		name    string
-		uid     uint32
-		mode    os.FileMode
-		wantErr bool
-	}{
-		{"owner 0600", me, 0o600, false},
-		{"owner 0644 (group/world readable, not writable)", me, 0o644, false},
-		{"owner 0660 group writable", me, 0o660, true},
-		{"owner 0606 world writable", me, 0o606, true},
-		{"owner 0700", me, 0o700, false},
-		{"owner 0777", me, 0o777, true},
-		{"root-owned 0600", root, 0o600, false},
-		{"root-owned 0660 still rejected", root, 0o660, true},
-		{"other user owned, strict mode", other, 0o600, true},
-		{"other user owned, loose mode", other, 0o666, true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := checkConfigOwnerAndMode("/fake/path", tt.uid, tt.mode, me)
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
-		})
-	}
-}
-
 func TestRunSSH(t *testing.T) {
 	t.Setenv("GT_LOG_DIR", t.TempDir())
 	useMockExec(t)
@@ -490,6 +773,23 @@ func TestRunSSHWithOverrides(t *testing.T) {
 	}, mockCmd.argLists[1])
 }
 
+func TestRunSSHVerifyDNS(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	defer func() { verifyDNS, jumpHosts = false, "" }()
+	verifyDNS, jumpHosts = true, "bastion"
+
+	require.NoError(t, runSSH("testserver", nil))
+	assert.Equal(t, []string{"-o", "ProxyJump=bastion", "-o", "VerifyHostKeyDNS=yes", "--", "testserver"}, mockRun("ssh"))
+	assert.Empty(t, sshOverrides, "-o is left alone")
+
+	t.Setenv("GT_BACKEND", backendPuTTY)
+	useShortcuts(t, nil)
+	origCfg, origGT := cfg, gtCfg
+	defer func() { cfg, gtCfg = origCfg, origGT }()
+	assert.ErrorContains(t, initConfig(), "--verify-dns needs the OpenSSH backend")
+}
+
 func TestKnownHost(t *testing.T) {
 	decoded, err := ssh_config.Decode(strings.NewReader(`Host testserver
   Hostname test.example.com
@@ -525,10 +825,12 @@ func TestResolveHost(t *testing.T) {
 
 	got, err := resolveHost("testserver")
 	assert.NoError(t, err)
-	assert.Equal(t, resolvedHost{
-		user:     "testuser",
-		hostname: "test.example.com",
-		port:     "2222",
+	home, _ := os.UserHomeDir()
+	assert.Equal(t, sshconf.Resolved{
+		User:          "testuser",
+		Hostname:      "test.example.com",
+		Port:          "2222",
+		IdentityFiles: []string{filepath.Join(home, ".ssh", "test_key")},
 	}, got)
 	assert.Equal(t, []string{"-G", "--", "testserver"}, mockCmd.argLists[0])
 }
@@ -542,8 +844,95 @@ func TestResolveListRows(t *testing.T) {
 	assert.Equal(t, "beta", rows[1].alias)
 	for _, r := range rows {
 		assert.NoError(t, r.err)
-		assert.Equal(t, "test.example.com", r.hostname)
-		assert.Equal(t, "testuser", r.user)
-		assert.Equal(t, "2222", r.port)
+		assert.Equal(t, "test.example.com", r.Hostname)
+		assert.Equal(t, "testuser", r.User)
+		assert.Equal(t, "2222", r.Port)
+		assert.Equal(t, []string{"2222"}, r.opts["port"], "the options ssh -G gave come along")
+	}
+}
+
+func TestEnvReachesRemoteCommand(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	orig := envVars
+	t.Cleanup(func() { envVars = orig })
+	envVars = []string{"LANG=C.UTF-8"}
+
+	assert.NoError(t, runSSH("web-1", []string{"locale"}))
+	args := mockRun("ssh")
+	assert.True(t, contains(args, "SetEnv=LANG=C.UTF-8"), "%v", args)
+	assert.Equal(t, []string{"web-1", "export LANG='C.UTF-8';", "locale"}, args[len(args)-3:])
+}
+
+func TestLoginShellAndTmux(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	orig := gtCfg
+	t.Cleanup(func() { gtCfg = orig })
+	withTmux, plain := t.TempDir(), t.TempDir()
+	for dir, tools := range map[string][]string{withTmux: {"fish", "tmux"}, plain: {"fish"}} {
+		require.NoError(t, os.Symlink(sh, filepath.Join(dir, "sh")))
+		for _, tool := range tools {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, tool), []byte("#!"+sh+"\necho "+tool+" \"$@\"\n"), 0o755))
+		}
+	}
+	run := func(path string, meta hostMeta) (string, error) {
+		gtCfg = gtConfig{Hosts: map[string]hostMeta{"web": meta}}
+		login, err := loginCommand("web")
+		require.NoError(t, err)
+		c := exec.Command(sh, "-c", login)
+		c.Env = []string{"PATH=" + path, "HOME=" + t.TempDir(), "SHELL=/bin/false"}
+		out, err := c.CombinedOutput()
+		return string(out), err
+	}
+
+	out, err := run(withTmux, hostMeta{Shell: "fish", TmuxSession: "main"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tmux new-session -A -s main "+filepath.Join(withTmux, "fish")+" -l\n", out)
+
+	out, err = run(plain, hostMeta{Shell: "fish", TmuxSession: "main"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tmux not found; starting a plain shell\nfish -l\n", out)
+
+	out, err = run(withTmux, hostMeta{Shell: "nosuchsh"})
+	assert.Equal(t, 127, exitCodeOf(err))
+	assert.Contains(t, out, "nosuchsh: no such shell on the host")
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	out, err = run(withTmux, hostMeta{Shell: `x"; touch ` + marker + `; "$(touch ` + marker + `)`})
+	assert.Equal(t, 127, exitCodeOf(err))
+	assert.Contains(t, out, `x"; touch `+marker, "the name is printed, not run")
+	assert.NoFileExists(t, marker)
+
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"web": {Shell: "-c"}}}
+	_, err = loginCommand("web")
+	assert.Error(t, err)
+}
+
+// mockHandshake is ssh -vv's account of the key exchange with alias.
+func mockHandshake(alias string) string {
+	software, kex, ciphers, macs, picked := "OpenSSH_9.8p1 Debian-3", "curve25519-sha256,kex-strict-s-v00@openssh.com",
+		"chacha20-poly1305@openssh.com,aes256-gcm@openssh.com", "hmac-sha2-256-etm@openssh.com", "aes256-gcm@openssh.com MAC: <implicit>"
+	if alias == "web-2" {
+		software, kex, ciphers, macs, picked = "OpenSSH_7.4", "curve25519-sha256,diffie-hellman-group14-sha1",
+			"aes128-ctr,aes128-cbc", "hmac-sha2-256,hmac-sha1", "aes128-ctr MAC: hmac-sha1"
 	}
+	proposal := "debug2: KEX algorithms: " + kex + "\n" +
+		"debug2: host key algorithms: rsa-sha2-512,ssh-ed25519\n" +
+		"debug2: ciphers ctos: " + ciphers + "\n" +
+		"debug2: ciphers stoc: " + ciphers + "\n" +
+		"debug2: MACs ctos: " + macs + "\n" +
+		"debug2: MACs stoc: " + macs + "\n"
+	return "debug1: Remote protocol version 2.0, remote software version " + software + "\n" +
+		"debug2: local client KEXINIT proposal\n" +
+		"debug2: KEX algorithms: diffie-hellman-group1-sha1\n" +
+		"debug2: peer server KEXINIT proposal\n" + proposal +
+		"debug1: kex: algorithm: " + strings.Split(kex, ",")[0] + "\n" +
+		"debug1: kex: host key algorithm: ssh-ed25519\n" +
+		"debug1: kex: server->client cipher: " + picked + " compression: none\n" +
+		"debug1: kex: client->server cipher: " + picked + " compression: none\n" +
+		"Permission denied (publickey).\n"
 }
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gt/pkg/sshconf"
+)
+
+// skKeyTypes are the FIDO2 security key types, by the name in the key
+// file, with the name ssh prints in its prompts.
+var skKeyTypes = map[string]string{
+	"sk-ssh-ed25519@openssh.com":                  "ED25519-SK",
+	"sk-ecdsa-sha2-nistp256@openssh.com":          "ECDSA-SK",
+	"sk-ssh-ed25519-cert-v01@openssh.com":         "ED25519-SK-CERT",
+	"sk-ecdsa-sha2-nistp256-cert-v01@openssh.com": "ECDSA-SK-CERT",
+}
+
+// keyFileType is the type of the key at path, as its .pub names it, or
+// else its private key does: OpenSSH keeps the public part of a private
+// key unencrypted, passphrase or not. It is "" for a key that is not
+// there or not in either form.
+func keyFileType(path string) string {
+	if data, err := os.ReadFile(path + ".pub"); err == nil {
+		if f := strings.Fields(string(data)); len(f) > 0 {
+			return f[0]
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return privateKeyType(data)
+}
+
+// privateKeyType reads the key type from an OpenSSH private key: after the
+// openssh-key-v1 magic, the cipher, KDF and KDF options, and the key
+// count, comes the first public key, which starts with its type.
+func privateKeyType(data []byte) string {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return ""
+	}
+	b, ok := bytes.CutPrefix(block.Bytes, []byte("openssh-key-v1\x00"))
+	if !ok {
+		return ""
+	}
+	next := func() []byte {
+		if len(b) < 4 {
+			return nil
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint32(len(b)-4) < n {
+			b = nil
+			return nil
+		}
+		s := b[4 : 4+n]
+		b = b[4+n:]
+		return s
+	}
+	next() // cipher
+	next() // KDF
+	next() // KDF options
+	if len(b) < 4 {
+		return ""
+	}
+	b = b[4:] // key count
+	pub := next()
+	if len(pub) < 4 {
+		return ""
+	}
+	b = pub
+	return string(next())
+}
+
+// identityFiles are the keys ssh offers alias as the SSH config names
+// them, or when it names none, the security keys among ssh's defaults.
+func identityFiles(alias string) []string {
+	ids, _ := cfg.GetAll(alias, "IdentityFile")
+	if len(ids) == 0 {
+		return []string{"~/.ssh/id_ecdsa_sk", "~/.ssh/id_ed25519_sk"}
+	}
+	ctx := sshconf.TokenContext{Alias: alias}
+	ctx.Hostname, _ = cfg.Get(alias, "HostName")
+	ctx.User, _ = cfg.Get(alias, "User")
+	for i, id := range ids {
+		ids[i] = sshconf.ExpandTokens(id, ctx)
+	}
+	return ids
+}
+
+// securityKeys are those of ids that are FIDO2 security keys and exist.
+func securityKeys(ids []string) []string {
+	var keys []string
+	for _, id := range ids {
+		path := sshconf.ExpandTilde(id)
+		if _, sk := skKeyTypes[keyFileType(path)]; sk {
+			keys = append(keys, path)
+		}
+	}
+	return keys
+}
+
+// checkSKProvider makes sure the middleware ssh talks to security keys
+// through is there: "internal", ssh's built-in, or the library a
+// SecurityKeyProvider (or SSH_SK_PROVIDER) names.
+func checkSKProvider(provider string) error {
+	if provider == "" {
+		provider = os.Getenv("SSH_SK_PROVIDER")
+	}
+	if provider == "" || provider == "internal" {
+		return nil
+	}
+	path := sshconf.ExpandTilde(provider)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("SecurityKeyProvider %s is not there: install the FIDO middleware library, or set SecurityKeyProvider internal to use ssh's own", path)
+	}
+	return nil
+}
+
+// prepareSecurityKey looks at what alias signs in with before an
+// interactive connection, from the SSH config gt has loaded rather than
+// another ssh -G: for a security key it checks the middleware, then says
+// the key may need a touch, which ssh asks for in a line that is easy to
+// miss among a login's output.
+func prepareSecurityKey(alias string) error {
+	if cfg == nil || usePuTTY() || pluginTransport() != nil {
+		return nil
+	}
+	keys := securityKeys(identityFiles(alias))
+	if len(keys) == 0 {
+		return nil
+	}
+	provider, _ := cfg.Get(alias, "SecurityKeyProvider")
+	if err := checkSKProvider(provider); err != nil {
+		return withCode(exitConfig, err)
+	}
+	if !quiet {
+		// On stderr, where ssh's own prompt goes, out of the way of a
+		// remote command's output.
+		warningColor.Fprintf(os.Stderr, "%s may need your security key (%s, %s): touch it when it blinks\n", alias, filepath.Base(keys[0]), skKeyTypes[keyFileType(keys[0])])
+	}
+	return nil
+}
+
+// skFailures are how ssh reports a security key that is not plugged in,
+// or has no middleware to reach it through.
+var skFailures = []string{
+	"device not found",
+	"security key support not enabled",
+	"dlopen failed",
+	"signing failed for ED25519-SK",
+	"signing failed for ECDSA-SK",
+}
+
+// securityKeyError explains a run that failed for want of its security
+// key, its stderr matching one of skFailures.
+func securityKeyError(alias, stderr string, err error) error {
+	switch {
+	case strings.Contains(stderr, "security key support not enabled"):
+		return fmt.Errorf("this ssh was built without security key support, which %s needs: install an OpenSSH with FIDO2 support or set a SecurityKeyProvider: %w", alias, err)
+	case strings.Contains(stderr, "dlopen failed"):
+		return fmt.Errorf("ssh could not load the SecurityKeyProvider for the security key %s needs: %w", alias, err)
+	}
+	return fmt.Errorf("no security key answered for %s; plug it in, connect again and touch it when it blinks: %w", alias, err)
+}
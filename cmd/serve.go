@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveMetrics  string
+	serveInterval time.Duration
+)
+
+// probeResult is the latest probe of one host.
+type probeResult struct {
+	up      bool
+	latency time.Duration
+	// keyFingerprint identifies the host's key set as ssh-keyscan sees
+	// it; "" when the scan failed or ssh-keyscan is missing.
+	keyFingerprint string
+	at             time.Time
+}
+
+// exporter probes a fixed set of hosts and serves the results in the
+// Prometheus text format. Host-key changes are judged against the first
+// key set seen for each host since the exporter started.
+type exporter struct {
+	aliases []string
+
+	mu         sync.Mutex
+	results    map[string]probeResult
+	baseline   map[string]string
+	keyChanges map[string]int
+}
+
+func newExporter(aliases []string) *exporter {
+	return &exporter{
+		aliases:    aliases,
+		results:    map[string]probeResult{},
+		baseline:   map[string]string{},
+		keyChanges: map[string]int{},
+	}
+}
+
+// scanKeys lists the keys a host offers, as "type key", sorted so the
+// order keyscan happens to print them in does not matter; none when the
+// scan failed or ssh-keyscan is missing.
+func scanKeys(host, port string, timeout time.Duration) []string {
+	secs := int(timeout.Seconds())
+	if secs < 1 {
+		secs = 1
+	}
+	cmd := execCommand("ssh-keyscan", "-p", port, "-T", strconv.Itoa(secs), "--", host)
+	debugf(3, "exec: %s", quoteArgv(cmd.Args))
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 3 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		keys = append(keys, f[1]+" "+f[2]) // type and key, not the host column
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scanHostKeys fingerprints the keys a host offers, "" when there are
+// none to fingerprint.
+func scanHostKeys(host, port string, timeout time.Duration) string {
+	keys := scanKeys(host, port, timeout)
+	if len(keys) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// probe checks one host: a timed TCP connect to its resolved address,
+// then a key scan if the port answered.
+func probe(alias string) probeResult {
+	res := probeResult{at: time.Now()}
+	r, err := resolveHost(alias)
+	if err != nil {
+		return res
+	}
+	port := r.Port
+	if port == "" {
+		port = "22"
+	}
+	timeout := hostMetaFor(alias).connectTimeout()
+	start := time.Now()
+	conn, err := dialTimeout("tcp", net.JoinHostPort(r.Hostname, port), timeout)
+	if err != nil {
+		debugf(1, "probe %s: %v", alias, err)
+		return res
+	}
+	res.latency = time.Since(start)
+	conn.Close()
+	res.up = true
+	res.keyFingerprint = scanHostKeys(r.Hostname, port, timeout)
+	return res
+}
+
+// record stores a probe result, tracking key changes against the
+// baseline.
+func (e *exporter) record(alias string, res probeResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if fp := res.keyFingerprint; fp != "" {
+		if prev, ok := e.results[alias]; ok && prev.keyFingerprint != "" && prev.keyFingerprint != fp {
+			e.keyChanges[alias]++
+		}
+		if _, ok := e.baseline[alias]; !ok {
+			e.baseline[alias] = fp
+		}
+	}
+	e.results[alias] = res
+}
+
+// probeAll probes every host, a handful at a time.
+func (e *exporter) probeAll() {
+	sem := make(chan struct{}, 8)
+	var wg sync.WaitGroup
+	for _, alias := range e.aliases {
+		wg.Add(1)
+		go func(alias string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			e.record(alias, probe(alias))
+		}(alias)
+	}
+	wg.Wait()
+}
+
+// promLabel escapes a label value per the Prometheus text format.
+func promLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// writeMetrics renders the latest results. Hosts not yet probed are left
+// out rather than reported as down.
+func (e *exporter) writeMetrics(w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	type metric struct {
+		name, help, kind string
+		value            func(alias string, r probeResult) (float64, bool)
+	}
+	metrics := []metric{
+		{"gt_ssh_up", "Whether the host's SSH port accepted a TCP connection.", "gauge",
+			func(_ string, r probeResult) (float64, bool) { return boolFloat(r.up), true }},
+		{"gt_ssh_connect_seconds", "TCP connect time to the host's SSH port.", "gauge",
+			func(_ string, r probeResult) (float64, bool) { return r.latency.Seconds(), r.up }},
+		{"gt_ssh_host_key_changed", "Whether the host's keys differ from the first set seen.", "gauge",
+			func(a string, r probeResult) (float64, bool) {
+				base, ok := e.baseline[a]
+				return boolFloat(r.keyFingerprint != base), ok && r.keyFingerprint != ""
+			}},
+		{"gt_ssh_host_key_changes_total", "Host key changes observed between probes.", "counter",
+			func(a string, _ probeResult) (float64, bool) { return float64(e.keyChanges[a]), true }},
+		{"gt_probe_timestamp_seconds", "When the host was last probed.", "gauge",
+			func(_ string, r probeResult) (float64, bool) { return float64(r.at.Unix()), true }},
+	}
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, alias := range e.aliases {
+			r, ok := e.results[alias]
+			if !ok {
+				continue
+			}
+			if v, ok := m.value(alias, r); ok {
+				fmt.Fprintf(w, "%s{alias=\"%s\"} %s\n", m.name, promLabel(alias), strconv.FormatFloat(v, 'g', -1, 64))
+			}
+		}
+	}
+
+	// Group membership as an info-style series, to join on in queries:
+	//   gt_ssh_up * on(alias) group_left gt_host_group{group="web"}
+	fmt.Fprint(w, "# HELP gt_host_group Membership of a host in a gt group.\n# TYPE gt_host_group gauge\n")
+	for _, alias := range e.aliases {
+		for _, g := range hostMetaFor(alias).Groups {
+			fmt.Fprintf(w, "gt_host_group{alias=\"%s\",group=\"%s\"} 1\n", promLabel(alias), promLabel(g))
+		}
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	e.writeMetrics(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// serveTargets expands the command-line targets, defaulting to @all and
+// dropping duplicates when groups overlap.
+func serveTargets(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"@" + allGroup}
+	}
+	return expandTargets(args)
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [alias|@group...]",
+	Short: "Run gt as a long-lived service",
+	Long: `Run gt as a long-lived service.
+
+With --metrics ADDR, probe the SSH port of every target (default @all)
+each --interval and export the results for Prometheus at
+http://ADDR/metrics: reachability, TCP connect time, and whether the
+host keys offered (via ssh-keyscan) changed since gt serve started.
+Probes are direct from this machine, like the fallback-address checks.
+
+With --inventory ADDR, publish the targets at http://ADDR/inventory as a
+team inventory for other gt instances to read with gt team refresh:
+each host's hostname, its user and port where they are not OpenSSH's
+defaults, its ProxyJump, and its gt groups, description and other
+metadata, re-resolved each --interval. --redact leaves fields out.
+Hosts gt itself read from a team inventory are not passed on. Clients
+present a bearer token: GT_SERVE_TOKEN, the contents of --token-file, or
+one gt makes up and prints at start. The token travels in the clear over
+plain HTTP, so put a TLS proxy in front beyond a trusted network.
+
+Both can be served at once, on one address or two.`,
+	Example: `  gt serve --metrics :9100 @prod
+  gt serve --inventory :9200 --token-file ~/.config/gt/serve-token --redact vars`,
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if serveMetrics == "" && serveInventory == "" {
+			return errors.New("nothing to serve: pass --metrics ADDR or --inventory ADDR")
+		}
+		if serveInterval < time.Second {
+			return errors.New("--interval must be at least 1s")
+		}
+		redact, err := validateRedact(inventoryRedact)
+		if err != nil {
+			return err
+		}
+		aliases, err := serveTargets(args)
+		if err != nil {
+			return err
+		}
+		muxes := map[string]*http.ServeMux{}
+		mux := func(addr string) *http.ServeMux {
+			if muxes[addr] == nil {
+				muxes[addr] = http.NewServeMux()
+			}
+			return muxes[addr]
+		}
+
+		if serveMetrics != "" {
+			e := newExporter(aliases)
+			go func() {
+				for {
+					e.probeAll()
+					time.Sleep(serveInterval)
+				}
+			}()
+			mux(serveMetrics).Handle("/metrics", e)
+			statusf(symbolColor, "Serving metrics for %d hosts on http://%s/metrics\n", len(aliases), serveMetrics)
+		}
+		if serveInventory != "" {
+			if usePuTTY() {
+				return errors.New("gt serve --inventory asks OpenSSH's ssh -G for every host; the PuTTY backend has no equivalent")
+			}
+			token, generated, err := serveToken()
+			if err != nil {
+				return err
+			}
+			inv := &inventoryServer{token: token}
+			data, err := buildInventory(aliases, redact)
+			if err != nil {
+				return err
+			}
+			inv.set(data)
+			go func() {
+				for {
+					time.Sleep(serveInterval)
+					if data, err := buildInventory(aliases, redact); err != nil {
+						warningColor.Fprintf(os.Stderr, "Rebuilding the inventory: %v\n", err)
+					} else {
+						inv.set(data)
+					}
+				}
+			}()
+			mux(serveInventory).Handle("/inventory", inv)
+			statusf(symbolColor, "Serving the inventory of %d hosts on http://%s/inventory\n", len(aliases), serveInventory)
+			if generated {
+				// Even with --quiet: without it, no one can read the inventory.
+				warningColor.Fprintf(os.Stderr, "Inventory token (pass --token-file to keep one across restarts): %s\n", token)
+			}
+		}
+
+		errs := make(chan error, len(muxes))
+		for addr, m := range muxes {
+			go func(addr string, m *http.ServeMux) {
+				errs <- http.ListenAndServe(addr, m)
+			}(addr, m)
+		}
+		return <-errs
+	},
+}
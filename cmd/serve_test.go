@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanHostKeys(t *testing.T) {
+	useMockExec(t)
+	fp := scanHostKeys("a.example.com", "22", 3*time.Second)
+	assert.Len(t, fp, 64)
+	assert.Equal(t, fp, scanHostKeys("b.example.com", "22", time.Second), "the host column is not part of the fingerprint")
+	assert.Equal(t, []string{"-p", "22", "-T", "3", "--", "a.example.com"}, mockCmd.argLists[0])
+}
+
+func TestProbe(t *testing.T) {
+	useMockExec(t)
+	origDial := dialTimeout
+	defer func() { dialTimeout = origDial }()
+
+	var dialed string
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		dialed = addr
+		return stubConn{}, nil
+	}
+	res := probe("test")
+	assert.True(t, res.up)
+	assert.Equal(t, "test.example.com:2222", dialed)
+	assert.NotEmpty(t, res.keyFingerprint)
+
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	mockCmd.reset()
+	res = probe("test")
+	assert.False(t, res.up)
+	assert.Empty(t, res.keyFingerprint)
+	assert.NotContains(t, mockCmd.commands, "ssh-keyscan", "no key scan when the port is closed")
+}
+
+func TestExporterMetrics(t *testing.T) {
+	origGT := gtCfg
+	defer func() { gtCfg = origGT }()
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"web": {Groups: []string{"prod"}}}}
+
+	e := newExporter([]string{"web", "db", "new"})
+	at := time.Unix(1700000000, 0)
+	e.record("web", probeResult{up: true, latency: 25 * time.Millisecond, keyFingerprint: "aaa", at: at})
+	e.record("web", probeResult{up: true, latency: 20 * time.Millisecond, keyFingerprint: "bbb", at: at})
+	e.record("db", probeResult{at: at})
+
+	var out bytes.Buffer
+	e.writeMetrics(&out)
+	got := out.String()
+	for _, want := range []string{
+		"# TYPE gt_ssh_up gauge\ngt_ssh_up{alias=\"web\"} 1\ngt_ssh_up{alias=\"db\"} 0\n",
+		"gt_ssh_connect_seconds{alias=\"web\"} 0.02\n# HELP",
+		"gt_ssh_host_key_changed{alias=\"web\"} 1\n",
+		"gt_ssh_host_key_changes_total{alias=\"web\"} 1\ngt_ssh_host_key_changes_total{alias=\"db\"} 0\n",
+		"gt_probe_timestamp_seconds{alias=\"web\"} 1.7e+09\n",
+		"gt_host_group{alias=\"web\",group=\"prod\"} 1\n",
+	} {
+		assert.Contains(t, got, want)
+	}
+	assert.NotContains(t, got, `alias="new"`, "unprobed hosts are left out")
+	assert.NotContains(t, got, `gt_ssh_connect_seconds{alias="db"}`, "no latency for a host that is down")
+	assert.NotContains(t, got, `gt_ssh_host_key_changed{alias="db"}`)
+}
+
+func TestPromLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\n`, promLabel("a\"b\\c\n"))
+}
+
+func TestServeTargets(t *testing.T) {
+	origCfg, origGT := cfg, gtCfg
+	defer func() { cfg, gtCfg = origCfg, origGT }()
+	decoded, err := ssh_config.Decode(strings.NewReader("Host a b c\n"))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	cfg = decoded
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"b": {Groups: []string{"g"}}, "c": {Groups: []string{"g"}}}}
+
+	got, err := serveTargets(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, got)
+
+	got, err = serveTargets([]string{"c", "@g"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, got)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gt/pkg/transport"
+)
+
+// checkSessions is --check-sessions, as a host's check_sessions setting
+// is for that host.
+var checkSessions bool
+
+// checkSessionsEnabled reports whether a login to alias first lists who
+// is logged into it.
+func checkSessionsEnabled(alias string) bool {
+	return checkSessions || hostMetaFor(alias).CheckSessions
+}
+
+// sessionsScript lists the host's login sessions. The check's own
+// connection has no terminal, so it is not among them.
+const sessionsScript = `LC_ALL=C who 2>/dev/null
+exit 0
+`
+
+// loginSession is one line of who.
+type loginSession struct {
+	user, tty, since, from string
+}
+
+// parseSessions reads who's output, which GNU and the BSDs both print as
+// user, terminal, login time and, for remote logins, the origin in
+// parentheses.
+func parseSessions(out []byte) []loginSession {
+	var sessions []loginSession
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
+		}
+		s := loginSession{user: f[0], tty: f[1]}
+		rest := f[2:]
+		if n := len(rest); n > 0 && strings.HasPrefix(rest[n-1], "(") && strings.HasSuffix(rest[n-1], ")") {
+			s.from = strings.Trim(rest[n-1], "()")
+			rest = rest[:n-1]
+		}
+		s.since = strings.Join(rest, " ")
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// renderSessions writes the warning that sessions are open on alias.
+func renderSessions(w io.Writer, alias string, sessions []loginSession) {
+	warningColor.Fprintf(w, "%d session(s) open on %s:\n", len(sessions), alias)
+	userWidth, ttyWidth := 0, 0
+	for _, s := range sessions {
+		if n := displayWidth(s.user); n > userWidth {
+			userWidth = n
+		}
+		if n := displayWidth(s.tty); n > ttyWidth {
+			ttyWidth = n
+		}
+	}
+	for _, s := range sessions {
+		fmt.Fprint(w, "  ")
+		userColor.Fprint(w, s.user+strings.Repeat(" ", userWidth-displayWidth(s.user)+2))
+		line := s.tty + strings.Repeat(" ", ttyWidth-displayWidth(s.tty))
+		if s.since != "" {
+			line += "  since " + s.since
+		}
+		if s.from != "" {
+			line += "  from " + s.from
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// checkLoggedIn warns when anyone is logged into alias, reading who over
+// the ControlMaster that mux sets up, so that admins working an incident
+// know they are not alone on the host.
+func checkLoggedIn(alias string, opts transport.Options, mux []string) error {
+	out, err := preLoginRun(alias, opts, mux, sessionsScript)
+	if err != nil {
+		return err
+	}
+	if sessions := parseSessions(out); len(sessions) > 0 {
+		renderSessions(os.Stderr, alias, sessions)
+	}
+	return nil
+}
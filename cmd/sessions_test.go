@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSessions(t *testing.T) {
+	out := []byte(`alice    pts/0        2026-10-14 09:12 (10.0.0.5)
+root     tty1         2026-10-13 18:40
+bob      ttys001  Oct 14 10:02  (laptop.example.com)
+
+`)
+	assert.Equal(t, []loginSession{
+		{user: "alice", tty: "pts/0", since: "2026-10-14 09:12", from: "10.0.0.5"},
+		{user: "root", tty: "tty1", since: "2026-10-13 18:40"},
+		{user: "bob", tty: "ttys001", since: "Oct 14 10:02", from: "laptop.example.com"},
+	}, parseSessions(out))
+	assert.Empty(t, parseSessions(nil))
+}
+
+func TestRenderSessions(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	renderSessions(&out, "web1", []loginSession{
+		{user: "alice", tty: "pts/0", since: "2026-10-14 09:12", from: "10.0.0.5"},
+		{user: "root", tty: "tty1", since: "2026-10-13 18:40"},
+	})
+	assert.Equal(t, `2 session(s) open on web1:
+  alice  pts/0  since 2026-10-14 09:12  from 10.0.0.5
+  root   tty1   since 2026-10-13 18:40
+`, out.String())
+}
+
+func TestRunSSHCheckSessions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no ControlMaster on Windows")
+	}
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Cleanup(removeRuntimeDir)
+	useMockExec(t)
+	useWebGroup(t)
+	t.Cleanup(func() { checkSessions, checkTZ = false, false })
+	checkSessions, checkTZ = true, true
+
+	require.NoError(t, runSSH("web-1", nil))
+	var runs []string
+	for i, c := range mockCmd.commands {
+		if c == "ssh" && mockCmd.argLists[i][0] != "-G" {
+			runs = append(runs, strings.Join(mockCmd.argLists[i], " "))
+		}
+	}
+	require.Len(t, runs, 3, "both checks, then the login")
+	assert.Contains(t, runs[0], "offset=$(date +%z)")
+	assert.Contains(t, runs[1], "LC_ALL=C who")
+	for _, run := range runs {
+		assert.Contains(t, run, "-o ControlMaster=auto -o ControlPath=", "one connection for all three")
+	}
+}
@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+var shellParallel int
+
+// errShellExit is the exit command's, which ends gt shell cleanly.
+var errShellExit = errors.New("exit")
+
+// shellHelp is gt shell's help command.
+const shellHelp = `use <alias|@group>          send the commands that follow there
+hosts                       list the hosts in use
+run <command>               run a command, as the remote shell reads it
+put <local>... <remote>     upload files into a remote path
+get <remote>... <local>     download files from one host
+group <alias|@group> <run|put|get> ...
+                            one command somewhere else, leaving use alone
+help                        this list
+exit                        close the connections and leave (as does end of input)`
+
+// cutWord splits the first word off line as sh reads one: quotes group,
+// and a backslash outside single quotes takes the next character as it
+// is (inside double quotes, only '"' and '\'). rest is what follows, its
+// leading blanks trimmed.
+func cutWord(line string) (word, rest string, err error) {
+	line = strings.TrimLeft(line, " \t")
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == '\'':
+			b.WriteByte(c)
+		case c == '\\' && i+1 < len(line) && (quote == 0 || line[i+1] == '"' || line[i+1] == '\\'):
+			i++
+			b.WriteByte(line[i])
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && (c == ' ' || c == '\t'):
+			return b.String(), strings.TrimLeft(line[i:], " \t"), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if quote != 0 {
+		return "", "", fmt.Errorf("unterminated %c quote", quote)
+	}
+	return b.String(), "", nil
+}
+
+// splitWords splits line into words with cutWord.
+func splitWords(line string) ([]string, error) {
+	var words []string
+	for rest := strings.TrimSpace(line); rest != ""; {
+		var word string
+		var err error
+		if word, rest, err = cutWord(rest); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
+
+// shellTarget is where gt shell's commands go: one alias, or the hosts
+// a group or list stands for.
+type shellTarget struct {
+	name    string
+	aliases []string
+}
+
+// group reports whether the target runs like gt exec, in BatchMode with
+// each line led by the alias, rather than like gt <alias>.
+func (t shellTarget) group() bool {
+	return len(t.aliases) != 1 || isGroupTarget(t.name)
+}
+
+// replSession is gt shell's state: the target the commands go to, and
+// the ssh options that keep each host's connection open between them.
+type replSession struct {
+	target shellTarget
+	// mux is nil with the PuTTY backend and on Windows, which have no
+	// ControlMaster; every command then connects anew.
+	mux []string
+	// used are the hosts connected to, whose masters close at the end.
+	used        map[string]bool
+	out, errOut io.Writer
+}
+
+// shellMux is the options that keep one connection per host open for
+// the session. ControlPersist bounds how long a master outlives a gt
+// that was killed before it could close them.
+func shellMux() ([]string, error) {
+	if usePuTTY() || runtime.GOOS == "windows" {
+		return nil, nil
+	}
+	dir, err := ensureRuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(dir, "s-%C"),
+		"-o", "ControlPersist=300",
+	}, nil
+}
+
+// close ends the masters opened during the session.
+func (r *replSession) close() {
+	if r.mux == nil {
+		return
+	}
+	for alias := range r.used {
+		exit := append(sshBaseArgs(), r.mux...)
+		runQuiet(sshCommand(append(exit, "-O", "exit", "--", alias)...))
+	}
+}
+
+// resolveShellTarget is the target use or group names.
+func resolveShellTarget(name string) (shellTarget, error) {
+	aliases, err := expandTarget(name)
+	if err != nil {
+		return shellTarget{}, err
+	}
+	return shellTarget{name: name, aliases: aliases}, nil
+}
+
+// exec runs one line of input.
+func (r *replSession) exec(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	verb, rest, err := cutWord(line)
+	if err != nil {
+		return err
+	}
+	switch verb {
+	case "exit", "quit":
+		return errShellExit
+	case "help":
+		fmt.Fprintln(r.out, shellHelp)
+		return nil
+	case "use":
+		args, err := splitWords(rest)
+		if err != nil {
+			return err
+		}
+		if len(args) != 1 {
+			return errors.New("usage: use <alias|@group>")
+		}
+		t, err := resolveShellTarget(args[0])
+		if err != nil {
+			return err
+		}
+		r.target = t
+		return nil
+	case "hosts":
+		if r.target.name == "" {
+			return errors.New("no hosts in use yet: use <alias|@group>")
+		}
+		fmt.Fprintln(r.out, strings.Join(r.target.aliases, "\n"))
+		return nil
+	case "group":
+		name, rest, err := cutWord(rest)
+		if err != nil {
+			return err
+		}
+		sub, rest, err := cutWord(rest)
+		if err != nil {
+			return err
+		}
+		if name == "" || (sub != "run" && sub != "put" && sub != "get") {
+			return errors.New("usage: group <alias|@group> <run|put|get> ...")
+		}
+		t, err := resolveShellTarget(name)
+		if err != nil {
+			return err
+		}
+		return r.do(t, sub, rest)
+	case "run", "put", "get":
+		if r.target.name == "" {
+			return errors.New("no hosts in use yet: use <alias|@group>")
+		}
+		return r.do(r.target, verb, rest)
+	}
+	return fmt.Errorf("unknown command %q (try help)", verb)
+}
+
+// do runs run, put or get on t, with rest as typed after the verb.
+func (r *replSession) do(t shellTarget, verb, rest string) error {
+	if verb == "run" {
+		if rest == "" {
+			return errors.New("usage: run <command>")
+		}
+		// The command goes as typed, for the remote shell to read.
+		return r.each(t, func(alias string, opts remoteOpts) error {
+			return r.run(alias, opts, t.group(), rest)
+		})
+	}
+	files, err := splitWords(rest)
+	if err != nil {
+		return err
+	}
+	if len(files) < 2 {
+		return fmt.Errorf("usage: %s <from>... <to>", verb)
+	}
+	if verb == "put" {
+		last := len(files) - 1
+		if !strings.HasPrefix(files[last], ":") {
+			files[last] = ":" + files[last]
+		}
+	} else {
+		if t.group() {
+			return errors.New("get takes one host; gt pull fetches from a group")
+		}
+		for i := range files[:len(files)-1] {
+			if !strings.HasPrefix(files[i], ":") {
+				files[i] = ":" + files[i]
+			}
+		}
+	}
+	return r.each(t, func(alias string, opts remoteOpts) error {
+		return withHooks(hookPreTransfer, hookPostTransfer, hookEvent{Alias: alias, Files: files}, func() error {
+			cmd, err := transferCommand(alias, files, opts)
+			if err != nil {
+				return err
+			}
+			return runPush(alias, cmd)
+		})
+	})
+}
+
+// each runs fn for every host of t, over its persistent connection: one
+// host as gt <alias> would, a group in BatchMode and up to --parallel
+// hosts at a time, with a table of results after.
+func (r *replSession) each(t shellTarget, fn func(alias string, opts remoteOpts) error) error {
+	for _, alias := range t.aliases {
+		r.used[alias] = true
+	}
+	if !t.group() {
+		return fn(t.aliases[0], remoteOpts{batch: nonInteractive(), sshOptions: r.mux})
+	}
+	results := fanOut(t.aliases, shellParallel, func(alias string) error {
+		return fn(alias, remoteOpts{batch: true, sshOptions: r.mux})
+	}, nil)
+	renderResults(r.out, results)
+	return failedHosts(results, 1)
+}
+
+// run runs command on alias, with its output led by the alias when
+// prefixed. The command gets no stdin: gt shell's is its own input.
+func (r *replSession) run(alias string, opts remoteOpts, prefixed bool, command string) error {
+	expanded, err := expandForHost(alias, []string{command})
+	if err != nil {
+		return err
+	}
+	return withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: expanded}, func() error {
+		cmd, err := remoteCommand(alias, opts, expanded...)
+		if err != nil {
+			return err
+		}
+		var out, errOut io.Writer = r.out, r.errOut
+		if prefixed {
+			stdout := &prefixWriter{w: r.out, prefix: alias}
+			stderr := &prefixWriter{w: r.errOut, prefix: alias}
+			defer stdout.Flush()
+			defer stderr.Flush()
+			out, errOut = stdout, stderr
+		}
+		var tail tailBuffer
+		cmd.Stdout = out
+		cmd.Stderr = io.MultiWriter(errOut, &tail)
+		debugf(1, "exec: %s", quoteArgv(cmd.Args))
+		start := time.Now()
+		err = runTracked(cmd, !prefixed)
+		logConnection(alias, "shell", start, err)
+		return classifyRun(alias, "ssh", err, tail.String())
+	})
+}
+
+// prompt is the prompt for the next line: the target, or gt.
+func (r *replSession) prompt() string {
+	if r.target.name == "" {
+		return "gt> "
+	}
+	return r.target.name + "> "
+}
+
+var shellCmd = &cobra.Command{
+	Use:   "shell [alias|@group]",
+	Short: "Run gt commands one after another over open connections",
+	Long: `Read commands, from the terminal or a script on stdin, and run them on
+the host or group chosen with use, keeping one connection per host open
+(ControlMaster) for the session: after the first, a command costs no
+handshake. On a terminal a failed command is reported and the next one
+read; from a script, the first failure ends gt shell with its exit code
+and line number. Blank lines and lines starting with # are skipped.
+
+` + shellHelp + `
+
+run sends the rest of the line as typed, as gt <alias> <command> does,
+and groups run like gt exec: in BatchMode, each line led by the alias,
+up to --parallel hosts at a time. put's remote path and get's remote
+files may leave out gt -s's leading ':'. The PuTTY backend and Windows
+have no ControlMaster; commands then connect one by one.`,
+	Example: `  gt shell @web
+  printf 'use @db\nrun pg_isready\nput backup.sh /tmp/\n' | gt shell`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mux, err := shellMux()
+		if err != nil {
+			return err
+		}
+		r := &replSession{mux: mux, used: map[string]bool{}, out: cmd.OutOrStdout(), errOut: cmd.ErrOrStderr()}
+		if len(args) == 1 {
+			if r.target, err = resolveShellTarget(args[0]); err != nil {
+				return err
+			}
+		}
+		cmd.SilenceUsage = true
+		// Registered for signals too: the masters would otherwise linger
+		// for ControlPersist after gt is gone.
+		defer onCleanup(r.close)()
+
+		f, ok := cmd.InOrStdin().(*os.File)
+		interactive := ok && isatty.IsTerminal(f.Fd())
+		scanner := bufio.NewScanner(cmd.InOrStdin())
+		for n := 1; ; n++ {
+			if interactive {
+				symbolColor.Fprint(r.errOut, r.prompt())
+			}
+			if !scanner.Scan() {
+				if interactive {
+					fmt.Fprintln(r.errOut)
+				}
+				return scanner.Err()
+			}
+			err := r.exec(scanner.Text())
+			switch {
+			case errors.Is(err, errShellExit):
+				return nil
+			case err == nil:
+			case interactive:
+				errorColor.Fprintln(r.errOut, err)
+			default:
+				return withCode(ExitCode(err), fmt.Errorf("line %d: %w", n, err))
+			}
+		}
+	},
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitWords(t *testing.T) {
+	for line, want := range map[string][]string{
+		"":                           nil,
+		"  put a.txt  /tmp/ ":        {"put", "a.txt", "/tmp/"},
+		`put 'my file' "b \"c\""`:    {"put", "my file", `b "c"`},
+		`get it\'s\ here .`:          {"get", "it's here", "."},
+		`run 'a\b' "c\d"`:            {"run", `a\b`, `c\d`},
+		`group @db run 'pg_isready'`: {"group", "@db", "run", "pg_isready"},
+	} {
+		got, err := splitWords(line)
+		require.NoError(t, err, line)
+		assert.Equal(t, want, got, line)
+	}
+	_, err := splitWords(`put 'a /tmp`)
+	assert.EqualError(t, err, "unterminated ' quote")
+
+	word, rest, err := cutWord(`  run  uptime && 'df -h'`)
+	require.NoError(t, err)
+	assert.Equal(t, "run", word)
+	assert.Equal(t, `uptime && 'df -h'`, rest, "the rest is left as typed")
+}
+
+// useShell sets up a gt shell session over the mock, writing to out.
+func useShell(t *testing.T) (*replSession, *bytes.Buffer) {
+	t.Helper()
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Cleanup(removeRuntimeDir)
+	plainOutput(t)
+	useMockExec(t)
+	useWebGroup(t)
+	mux, err := shellMux()
+	require.NoError(t, err)
+	var out bytes.Buffer
+	return &replSession{mux: mux, used: map[string]bool{}, out: &out, errOut: &out}, &out
+}
+
+func TestShellRun(t *testing.T) {
+	r, out := useShell(t)
+	assert.EqualError(t, r.exec("run uptime"), "no hosts in use yet: use <alias|@group>")
+	require.NoError(t, r.exec("use web-1"))
+	assert.Equal(t, "web-1> ", r.prompt())
+	require.NoError(t, r.exec("run echo hello there"))
+	assert.Equal(t, "hello there\n", out.String())
+	args := mockRun("ssh")
+	assert.Equal(t, []string{"--", "web-1", "echo hello there"}, args[len(args)-3:], "the command goes as typed")
+	if runtime.GOOS != "windows" {
+		assert.Contains(t, strings.Join(args, " "), "-o ControlMaster=auto -o ControlPath=")
+	}
+
+	out.Reset()
+	err := r.exec("group @web run echo hi")
+	assert.Equal(t, 1, ExitCode(err))
+	assert.Contains(t, out.String(), "web-1 | hi\n")
+	assert.Contains(t, out.String(), "web-2 | hi\n")
+	assert.Regexp(t, `down\s+failed`, out.String())
+	assert.Equal(t, "web-1> ", r.prompt(), "group leaves use alone")
+
+	assert.EqualError(t, r.exec("group @web use web-2"), "usage: group <alias|@group> <run|put|get> ...")
+	assert.EqualError(t, r.exec("frobnicate"), `unknown command "frobnicate" (try help)`)
+	assert.NoError(t, r.exec("# a comment"))
+	assert.ErrorIs(t, r.exec("exit"), errShellExit)
+
+	if runtime.GOOS != "windows" {
+		mockCmd.reset()
+		r.close()
+		var exits []string
+		for i, c := range mockCmd.commands {
+			if c == "ssh" && contains(mockCmd.argLists[i], "-O") {
+				exits = append(exits, mockCmd.argLists[i][len(mockCmd.argLists[i])-1])
+			}
+		}
+		assert.ElementsMatch(t, []string{"web-1", "web-2", "down"}, exits, "every master the session opened is closed")
+	}
+}
+
+func TestShellTransfers(t *testing.T) {
+	r, _ := useShell(t)
+	local := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(local, []byte("x"), 0o600))
+	require.NoError(t, r.exec("use web-1"))
+
+	require.NoError(t, r.exec("put "+local+" /tmp/"))
+	args := mockRun("scp")
+	assert.Equal(t, []string{local, "web-1:/tmp/"}, args[len(args)-2:], "put's remote path needs no ':'")
+	if runtime.GOOS != "windows" {
+		assert.Contains(t, strings.Join(args, " "), "ControlPath=")
+	}
+
+	mockCmd.reset()
+	require.NoError(t, r.exec("get /etc/hostname "+t.TempDir()))
+	args = mockRun("scp")
+	assert.Equal(t, "web-1:/etc/hostname", args[len(args)-2])
+
+	assert.EqualError(t, r.exec("group @web get /etc/hostname ."), "get takes one host; gt pull fetches from a group")
+	assert.EqualError(t, r.exec("put only-one"), "usage: put <from>... <to>")
+}
+
+func TestShellScript(t *testing.T) {
+	useShell(t)
+	var out bytes.Buffer
+	shellCmd.SetOut(&out)
+	shellCmd.SetErr(&out)
+	shellCmd.SetIn(strings.NewReader("use web-1\nrun echo one\nrun exit 3\nrun echo never\n"))
+	t.Cleanup(func() {
+		shellCmd.SetOut(nil)
+		shellCmd.SetErr(nil)
+		shellCmd.SetIn(nil)
+	})
+
+	err := shellCmd.RunE(shellCmd, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3: ")
+	assert.Equal(t, 3, ExitCode(err))
+	assert.Equal(t, "one\n", out.String(), "a script stops at its first failure")
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// stopSignals are the signals gt handles itself rather than dying with
+// its children and cleanups abandoned. SIGHUP arrives when the terminal
+// goes away.
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// children are the processes gt is running. A foreground child owns the
+// terminal (ssh, scp, the pager, a plugin) and decides for itself what a
+// signal means; a background one (a poll, a hook, a helper) just
+// stops when gt does.
+var children = struct {
+	sync.Mutex
+	procs map[*os.Process]bool // process -> foreground
+}{procs: map[*os.Process]bool{}}
+
+// track records a started process until the returned func is called.
+func track(p *os.Process, foreground bool) (untrack func()) {
+	children.Lock()
+	children.procs[p] = foreground
+	children.Unlock()
+	return func() {
+		children.Lock()
+		delete(children.procs, p)
+		children.Unlock()
+	}
+}
+
+// runTracked is cmd.Run for a tracked child.
+func runTracked(cmd *exec.Cmd, foreground bool) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer track(cmd.Process, foreground)()
+	return cmd.Wait()
+}
+
+// outputTracked is cmd.Output for a background child. Unlike Output it
+// leaves stderr to the caller.
+func outputTracked(cmd *exec.Cmd) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := runTracked(cmd, false)
+	return stdout.Bytes(), err
+}
+
+// signalProcess passes sig on, or kills p where the platform cannot
+// deliver it (Windows only knows Kill).
+func signalProcess(p *os.Process, sig os.Signal) {
+	if err := p.Signal(sig); err != nil {
+		p.Kill()
+	}
+}
+
+// forwardSignal passes sig to every foreground child and reports
+// whether there were any.
+func forwardSignal(sig os.Signal) bool {
+	children.Lock()
+	defer children.Unlock()
+	forwarded := false
+	for p, fg := range children.procs {
+		if fg {
+			signalProcess(p, sig)
+			forwarded = true
+		}
+	}
+	return forwarded
+}
+
+// killChildren stops every background child.
+func killChildren() {
+	children.Lock()
+	defer children.Unlock()
+	for p, fg := range children.procs {
+		if !fg {
+			p.Kill()
+		}
+	}
+}
+
+// cleanups are undone state gt must not leave behind if a signal stops
+// it: the terminal's mode, ControlMaster connections, sockets and the
+// runtime directory of temporary files.
+var cleanups = struct {
+	sync.Mutex
+	fns []*cleanupFunc
+}{}
+
+type cleanupFunc struct {
+	once sync.Once
+	fn   func()
+}
+
+func (c *cleanupFunc) run() { c.once.Do(c.fn) }
+
+// onCleanup registers fn to run if a signal stops gt, and returns a func
+// that runs it now instead, for the normal path to defer. Either way fn
+// runs at most once.
+func onCleanup(fn func()) (release func()) {
+	c := &cleanupFunc{fn: fn}
+	cleanups.Lock()
+	cleanups.fns = append(cleanups.fns, c)
+	cleanups.Unlock()
+	return func() {
+		c.run()
+		cleanups.Lock()
+		defer cleanups.Unlock()
+		for i, f := range cleanups.fns {
+			if f == c {
+				cleanups.fns = append(cleanups.fns[:i], cleanups.fns[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// runCleanups runs every pending cleanup, newest first, as defers would.
+func runCleanups() {
+	cleanups.Lock()
+	fns := cleanups.fns
+	cleanups.fns = nil
+	cleanups.Unlock()
+	for i := len(fns) - 1; i >= 0; i-- {
+		fns[i].run()
+	}
+}
+
+// stopping is set once a stop signal has arrived, even one passed on to
+// a foreground child, so loops that would restart the child know not to.
+var stopping atomic.Bool
+
+// stopRequested reports whether the user has asked gt to stop.
+func stopRequested() bool { return stopping.Load() }
+
+// exitOnSignal is os.Exit, swappable in tests.
+var exitOnSignal = os.Exit
+
+// handleSignals takes over the stop signals until the returned func is
+// called. While a foreground child runs, a signal is passed on to it and
+// gt carries on: ssh tears its session down and the pager decides what
+// Ctrl-C means, and gt then returns through its normal path. Otherwise
+// gt stops its background children, runs the cleanups and exits with
+// 128 plus the signal number, as a shell reports a killed command.
+func handleSignals() (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, stopSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-ch:
+				debugf(1, "caught %v", sig)
+				stopping.Store(true)
+				if forwardSignal(sig) {
+					continue
+				}
+				killChildren()
+				runCleanups()
+				exitOnSignal(signalExitCode(sig))
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// signalExitCode is the status for being stopped by sig: 128+n.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
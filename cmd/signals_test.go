@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnCleanupRunsOnce(t *testing.T) {
+	var ran []string
+	release := onCleanup(func() { ran = append(ran, "released") })
+	onCleanup(func() { ran = append(ran, "first") })
+	onCleanup(func() { ran = append(ran, "second") })
+
+	release()
+	release()
+	runCleanups()
+	runCleanups()
+	assert.Equal(t, []string{"released", "second", "first"}, ran, "pending cleanups run newest first, each once")
+}
+
+// signalSelf starts handleSignals with exit stubbed out, sleeps a child
+// in the given role, and sends gt itself SIGTERM.
+func signalSelf(t *testing.T, foreground bool) (child *exec.Cmd, exited chan int) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs POSIX signals")
+	}
+	exited = make(chan int, 1)
+	origExit := exitOnSignal
+	exitOnSignal = func(code int) { exited <- code }
+	stop := handleSignals()
+	t.Cleanup(func() {
+		stop()
+		exitOnSignal = origExit
+		stopping.Store(false)
+	})
+
+	child = exec.Command("sleep", "30")
+	if err := child.Start(); err != nil {
+		t.Fatalf("start sleep: %v", err)
+	}
+	t.Cleanup(func() { child.Process.Kill() })
+	t.Cleanup(track(child.Process, foreground))
+
+	self, _ := os.FindProcess(os.Getpid())
+	if err := self.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	return child, exited
+}
+
+func TestSignalStopsBackgroundChildrenAndCleansUp(t *testing.T) {
+	cleaned := make(chan bool, 1)
+	onCleanup(func() { cleaned <- true })
+
+	child, exited := signalSelf(t, false)
+	select {
+	case code := <-exited:
+		assert.Equal(t, 128+int(syscall.SIGTERM), code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("gt did not exit on SIGTERM")
+	}
+	assert.True(t, <-cleaned)
+	assert.Error(t, child.Wait(), "the background child was killed")
+}
+
+func TestSignalIsForwardedToForegroundChild(t *testing.T) {
+	child, exited := signalSelf(t, true)
+	err := child.Wait()
+	assert.Equal(t, 128+int(syscall.SIGTERM), ExitCode(err), "the child got SIGTERM and gt reports it as a shell would")
+	select {
+	case <-exited:
+		t.Fatal("gt must wait for its foreground child rather than exit")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.True(t, stopRequested(), "a loop around the child must not restart it")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gt/pkg/transport"
+)
+
+var checkSpace bool
+
+// freeSpaceScript prints the bytes free to an unprivileged user on the
+// filesystem dest is on, or would be created on: that of its nearest
+// existing ancestor.
+func freeSpaceScript(dest string) string {
+	return shellScript(`d=` + quoteArgv([]string{dest}) + `
+while [ ! -e "$d" ]; do d=$(dirname -- "$d"); done
+df -Pk -- "$d" | awk 'NR==2{printf "%.0f\n", $4*1024}'`)
+}
+
+// remoteFreeSpace is the space free at dest on alias, in bytes.
+func remoteFreeSpace(alias, dest string, opts remoteOpts) (uint64, error) {
+	out, err := remoteOutput(alias, "space", opts, freeSpaceScript(dest))
+	if err != nil {
+		return 0, err
+	}
+	free, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: no free space in df's output", alias)
+	}
+	return free, nil
+}
+
+// ensureSpace is --check-space: before an upload to alias, it compares
+// the size of the local files with the space free at the destination,
+// so a transfer that cannot fit fails up front rather than halfway with
+// "No space left on device". Downloads pass unchecked.
+func ensureSpace(alias string, files []string, opts remoteOpts) error {
+	if !checkSpace {
+		return nil
+	}
+	if err := transport.ValidateSCPPaths(files); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(files[len(files)-1], ":") {
+		return nil
+	}
+	files, err := expandRemotePaths(alias, files)
+	if err != nil {
+		return err
+	}
+	_, need, err := collectUpload(files[:len(files)-1], currentFilter())
+	if err != nil {
+		return err
+	}
+	dest := colonPath(files[len(files)-1])
+	free, err := remoteFreeSpace(alias, dest, opts)
+	if err != nil {
+		return fmt.Errorf("checking free space on %s: %w", alias, err)
+	}
+	debugf(1, "space: %s needs %d bytes, %s:%s has %d free", alias, need, alias, dest, free)
+	if uint64(need) > free {
+		return withCode(exitTransfer, fmt.Errorf("not enough space on %s: the upload needs %s, %s has %s free",
+			alias, formatBytes(uint64(need)), dest, formatBytes(free)))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureSpace(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	checkSpace = true
+	t.Cleanup(func() { checkSpace = false })
+
+	// The mock host has 1 MiB free.
+	small := filepath.Join(t.TempDir(), "small")
+	require.NoError(t, os.WriteFile(small, make([]byte, 1000), 0o644))
+	big := filepath.Join(t.TempDir(), "big")
+	require.NoError(t, os.WriteFile(big, make([]byte, 2<<20), 0o644))
+
+	assert.NoError(t, ensureSpace("web-1", []string{small, ":/srv/new/dir"}, remoteOpts{}))
+	script := mockRun("ssh")
+	assert.Contains(t, script[len(script)-1], "d=/srv/new/dir")
+
+	err := ensureSpace("web-1", []string{small, big, ":"}, remoteOpts{})
+	assert.EqualError(t, err, "not enough space on web-1: the upload needs 2.0M, . has 1.0M free")
+	assert.Equal(t, exitTransfer, ExitCode(err))
+
+	mockCmd.reset()
+	assert.NoError(t, ensureSpace("web-1", []string{":big", t.TempDir()}, remoteOpts{}), "downloads are not checked")
+	assert.Empty(t, mockCmd.commands)
+
+	assert.ErrorContains(t, ensureSpace("down", []string{small, ":/srv"}, remoteOpts{}), "checking free space on down")
+
+	checkSpace = false
+	mockCmd.reset()
+	assert.NoError(t, ensureSpace("web-1", []string{big, ":/srv"}, remoteOpts{}))
+	assert.Empty(t, mockCmd.commands)
+}
@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
+)
+
+var (
+	splitBy  = "domain"
+	splitYes bool
+)
+
+// configBlock is a Host or Match block of a config as written: its lines
+// and the comment lines right above it, which describe it and move with
+// it.
+type configBlock struct {
+	text string
+	// patterns are a Host line's, nil for Match.
+	patterns []string
+	// hostname is the block's own HostName, "" if it sets none.
+	hostname string
+}
+
+// concrete reports whether b is a Host block naming only literal hosts.
+func (b configBlock) concrete() bool {
+	if len(b.patterns) == 0 {
+		return false
+	}
+	for _, p := range b.patterns {
+		if strings.ContainsAny(p, "*?!") {
+			return false
+		}
+	}
+	return true
+}
+
+// parseConfigBlocks cuts a config into what comes before its first Host
+// or Match line and the blocks from there on. Comments directly above a
+// block, with no blank line between, belong to it.
+func parseConfigBlocks(data []byte) (preamble string, blocks []configBlock) {
+	var cur *configBlock
+	var text, pending []string
+	flush := func() {
+		if cur == nil {
+			preamble = strings.Join(text, "")
+		} else {
+			cur.text = strings.Join(text, "")
+			blocks = append(blocks, *cur)
+		}
+	}
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		switch kw := sshconf.Keyword(trimmed); kw {
+		case "host", "match":
+			// Comments after the last blank line go with the new block.
+			cut := len(pending)
+			for cut > 0 && strings.TrimSpace(pending[cut-1]) != "" {
+				cut--
+			}
+			text = append(text, pending[:cut]...)
+			flush()
+			text, pending = append([]string(nil), pending[cut:]...), nil
+			cur = &configBlock{}
+			if kw == "host" {
+				cur.patterns = strings.Fields(configArgs(trimmed))
+			}
+			text = append(text, line)
+			continue
+		case "hostname":
+			if cur != nil && cur.hostname == "" {
+				cur.hostname = configArgs(trimmed)
+			}
+		case "":
+			pending = append(pending, line)
+			continue
+		}
+		text = append(text, pending...)
+		pending = nil
+		text = append(text, line)
+	}
+	text = append(text, pending...)
+	flush()
+	return preamble, blocks
+}
+
+// configArgs is what follows a config line's keyword, without a trailing
+// comment.
+func configArgs(trimmed string) string {
+	if i := strings.Index(trimmed, " #"); i >= 0 {
+		trimmed = trimmed[:i]
+	}
+	i := strings.IndexAny(trimmed, " \t=")
+	if i < 0 {
+		return ""
+	}
+	rest := strings.TrimLeft(trimmed[i:], " \t")
+	return strings.TrimSpace(strings.TrimPrefix(rest, "="))
+}
+
+// domainOf is the registrable-looking part of a hostname, its last two
+// labels: example.com for db.eu.example.com. An IP address or a single
+// label has none.
+func domainOf(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if transport.HostIP(host) != nil || strings.Contains(host, "%") {
+		return ""
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return ""
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+// splitGroup is the include a block moves to under --by, "" to leave it
+// in the main config.
+func splitGroup(b configBlock, by string) string {
+	switch by {
+	case "domain":
+		host := b.hostname
+		if host == "" {
+			host = b.patterns[0]
+		}
+		return domainOf(host)
+	case "tag":
+		for _, p := range b.patterns {
+			if groups := hostMetaFor(p).Groups; len(groups) > 0 {
+				return groups[0]
+			}
+		}
+	}
+	return ""
+}
+
+// splitFile is one file a split writes, with what it holds now.
+type splitFile struct {
+	edit *configEdit
+	data []byte
+}
+
+// configSplit is the outcome of planning a split: the files to write,
+// includes first and the main config last, and the hosts left where they
+// were and why.
+type configSplit struct {
+	files []splitFile
+	kept  []string
+}
+
+// planSplit works out what splitting the config at path by by writes.
+// Only Host blocks naming literal hosts move, and only those above the
+// first wildcard Host or Match block: ssh takes the first value it finds,
+// so moving a host up past a catch-all would change what it gets.
+func planSplit(path, home, by string) (*configSplit, error) {
+	main, err := readConfigEdit(path)
+	if err != nil {
+		return nil, err
+	}
+	if main.hash == "" {
+		return nil, withCode(exitConfig, fmt.Errorf("no SSH config at %s", path))
+	}
+	dir, dirInclude := configDInclude(path, home)
+	preamble, blocks := parseConfigBlocks(main.data)
+
+	plan := &configSplit{}
+	moved := map[string][]configBlock{}
+	var rest strings.Builder
+	barrier := ""
+	for _, b := range blocks {
+		group := ""
+		if b.concrete() && barrier == "" {
+			group = splitGroup(b, by)
+			if _, err := includeName(group); group != "" && err != nil {
+				plan.kept = append(plan.kept, fmt.Sprintf("%s: no file can be named after %q", b.patterns[0], group))
+				group = ""
+			}
+			// gt only decrypts includes marked in the main config.
+			if group != "" && holdsEncryptedInclude(b.text) {
+				plan.kept = append(plan.kept, strings.Join(b.patterns, " ")+": holds an encrypted include")
+				rest.WriteString(b.text)
+				continue
+			}
+		}
+		if group == "" {
+			if b.concrete() {
+				reason := "no " + by
+				if barrier != "" {
+					reason = "below " + barrier
+				}
+				plan.kept = append(plan.kept, strings.Join(b.patterns, " ")+": "+reason)
+			} else if barrier == "" {
+				barrier = blockHeader(b.text)
+			}
+			rest.WriteString(b.text)
+			continue
+		}
+		moved[group] = append(moved[group], b)
+	}
+	if len(moved) == 0 {
+		return plan, nil
+	}
+
+	groups := make([]string, 0, len(moved))
+	for g := range moved {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	var includes strings.Builder
+	patterns := topLevelIncludes(main.data)
+	for _, g := range groups {
+		base, _ := includeName(g)
+		file := filepath.Join(dir, base)
+		e, err := readConfigEdit(file)
+		if err != nil {
+			return nil, err
+		}
+		var body bytes.Buffer
+		if e.hash == "" {
+			fmt.Fprintf(&body, includeHeader, g, path)
+		} else {
+			body.Write(e.data)
+		}
+		for _, b := range moved[g] {
+			text := strings.TrimLeft(b.text, "\n")
+			if bytes.Contains(e.data, []byte(text)) {
+				continue // moved there by an earlier split that did not finish
+			}
+			if !bytes.HasSuffix(body.Bytes(), []byte("\n\n")) {
+				body.WriteString("\n")
+			}
+			body.WriteString(strings.TrimRight(text, "\n") + "\n")
+		}
+		plan.files = append(plan.files, splitFile{e, body.Bytes()})
+		if !includedBy(patterns, file) {
+			includes.WriteString(strings.TrimSuffix(dirInclude, "*") + base + "\n")
+		}
+	}
+	var out strings.Builder
+	if includes.Len() > 0 {
+		out.WriteString(includes.String() + "\n")
+	}
+	out.WriteString(preamble)
+	out.WriteString(rest.String())
+	plan.files = append(plan.files, splitFile{main, []byte(out.String())})
+	return plan, nil
+}
+
+// holdsEncryptedInclude reports whether text has an encrypted-include
+// marker line.
+func holdsEncryptedInclude(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if markerPath(line) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// blockHeader is the Host or Match line that opens a block's text.
+func blockHeader(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if kw := sshconf.Keyword(line); kw == "host" || kw == "match" {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+// preview writes the split as unified diffs, one per file.
+func (s *configSplit) preview(w io.Writer) error {
+	for _, f := range s.files {
+		from := f.edit.path
+		if f.edit.hash == "" {
+			from = "/dev/null"
+		}
+		diff, err := unifiedDiff(f.edit.data, f.data, from, f.edit.path, 3)
+		if err != nil {
+			return err
+		}
+		writeDiff(w, diff)
+	}
+	return nil
+}
+
+// write commits the includes, then the main config: if it stops halfway
+// a host is in two files for a while, which ssh tolerates (the first
+// wins), rather than in none.
+func (s *configSplit) write() error {
+	for _, f := range s.files {
+		if err := f.edit.commit(f.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var configSplitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Move Host blocks from the SSH config into include files",
+	Long: `Move the Host blocks of your SSH config into include files in
+config.d, one per domain of their HostName (--by domain, the default) or
+per first gt group (--by tag), and add an Include line for each to the top
+of the config. Blocks keep their comments; a file that already exists is
+added to.
+
+Only blocks naming literal hosts move, and only those above the first
+wildcard Host or Match block: ssh takes the first value it finds for an
+option, and an include at the top is read before everything in the
+config, so moving a host past a catch-all would change its settings.
+Hosts that stay are listed with the reason.
+
+The change is shown as a diff first and written once you agree to it;
+--yes writes without asking.`,
+	Example: `  gt config split
+  gt config split --by tag --yes`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if splitBy != "domain" && splitBy != "tag" {
+			return fmt.Errorf("unknown --by %q: use domain or tag", splitBy)
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		path, err := sshConfigPath()
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		plan, err := planSplit(path, home, splitBy)
+		if err != nil {
+			return err
+		}
+		for _, k := range plan.kept {
+			warningColor.Fprintf(os.Stderr, "Staying in %s: %s\n", path, k)
+		}
+		if len(plan.files) == 0 {
+			statusf(symbolColor, "Nothing to move\n")
+			return nil
+		}
+		if err := plan.preview(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+		if !splitYes {
+			if nonInteractive() {
+				return errors.New("gt config split asks before writing; pass --yes to write without asking")
+			}
+			p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+			if !p.confirm(fmt.Sprintf("Write %d files?", len(plan.files)), false) {
+				return withCode(1, errors.New("aborted"))
+			}
+		}
+		if err := plan.write(); err != nil {
+			return err
+		}
+		statusf(symbolColor, "Moved hosts into %d include file(s)\n", len(plan.files)-1)
+		return nil
+	},
+}
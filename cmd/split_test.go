@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const monolith = `# My hosts
+ServerAliveInterval 30
+
+# The EU web frontend
+Host web-eu
+  HostName web.eu.example.com
+
+Host db  # primary
+  HostName db.example.com
+  User postgres
+
+# Lab gear
+Host lab
+  HostName 192.0.2.7
+
+Host api
+  HostName api.example.org
+
+Host *.corp
+  User me
+
+Host late
+  HostName late.example.com
+
+Host *
+  AddKeysToAgent yes
+`
+
+func TestParseConfigBlocks(t *testing.T) {
+	preamble, blocks := parseConfigBlocks([]byte(monolith))
+	assert.Equal(t, "# My hosts\nServerAliveInterval 30\n\n", preamble)
+	require.Len(t, blocks, 7)
+	assert.Equal(t, "# The EU web frontend\nHost web-eu\n  HostName web.eu.example.com\n\n", blocks[0].text)
+	assert.Equal(t, []string{"db"}, blocks[1].patterns, "the trailing comment is not a pattern")
+	assert.Equal(t, "db.example.com", blocks[1].hostname)
+	assert.False(t, blocks[4].concrete())
+	var all strings.Builder
+	all.WriteString(preamble)
+	for _, b := range blocks {
+		all.WriteString(b.text)
+	}
+	assert.Equal(t, monolith, all.String(), "nothing is lost")
+}
+
+func TestDomainOf(t *testing.T) {
+	assert.Equal(t, "example.com", domainOf("db.eu.Example.com."))
+	assert.Equal(t, "example.com", domainOf("example.com"))
+	assert.Equal(t, "", domainOf("localhost"))
+	assert.Equal(t, "", domainOf("192.0.2.7"))
+	assert.Equal(t, "", domainOf("2001:db8::1"))
+}
+
+func TestPlanSplitByDomain(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".ssh", "config")
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh", "config.d"), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(monolith), 0o600))
+	org := filepath.Join(home, ".ssh", "config.d", "example.org.conf")
+	require.NoError(t, os.WriteFile(org, []byte("Host old\n  HostName old.example.org\n"), 0o600))
+
+	plan, err := planSplit(path, home, "domain")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lab: no domain", "late: below Host *.corp"}, plan.kept)
+	require.Len(t, plan.files, 3)
+
+	var preview bytes.Buffer
+	plainOutput(t)
+	require.NoError(t, plan.preview(&preview))
+	assert.Contains(t, preview.String(), "--- /dev/null\n+++ "+filepath.Join(home, ".ssh", "config.d", "example.com.conf"))
+	assert.Contains(t, preview.String(), "-Host web-eu\n")
+
+	require.NoError(t, plan.write())
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, `Include config.d/example.com.conf
+Include config.d/example.org.conf
+
+# My hosts
+ServerAliveInterval 30
+
+# Lab gear
+Host lab
+  HostName 192.0.2.7
+
+Host *.corp
+  User me
+
+Host late
+  HostName late.example.com
+
+Host *
+  AddKeysToAgent yes
+`, string(data))
+	com, _ := os.ReadFile(filepath.Join(home, ".ssh", "config.d", "example.com.conf"))
+	assert.Equal(t, "# example.com hosts, included from "+path+`.
+# Each Host block defines an alias, as in the main config.
+
+# The EU web frontend
+Host web-eu
+  HostName web.eu.example.com
+
+Host db  # primary
+  HostName db.example.com
+  User postgres
+`, string(com))
+	data, _ = os.ReadFile(org)
+	assert.Equal(t, "Host old\n  HostName old.example.org\n\nHost api\n  HostName api.example.org\n", string(data), "an existing include is added to")
+
+	plan, err = planSplit(path, home, "domain")
+	require.NoError(t, err)
+	assert.Empty(t, plan.files, "nothing left to move")
+}
+
+func TestPlanSplitByTag(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	orig := gtCfg
+	defer func() { gtCfg = orig }()
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"db": {Groups: []string{"prod", "db"}}, "lab": {Groups: []string{"../x"}}}}
+	path := filepath.Join(home, ".ssh", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte("Include config.d/*\n\n"+monolith), 0o600))
+
+	plan, err := planSplit(path, home, "tag")
+	require.NoError(t, err)
+	require.Len(t, plan.files, 2)
+	assert.Equal(t, filepath.Join(home, ".ssh", "config.d", "prod.conf"), plan.files[0].edit.path)
+	assert.Contains(t, plan.kept, `lab: no file can be named after "../x"`)
+	assert.True(t, strings.HasPrefix(string(plan.files[1].data), "Include config.d/*\n\n# My hosts"), "config.d/* already covers it")
+}
+
+func TestPlanSplitKeepsEncryptedIncludes(t *testing.T) {
+	home := t.TempDir()
+	path := filepath.Join(home, ".ssh", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte("Host web\n  HostName web.example.com\n  # gt:include-encrypted web.age\n\nHost db\n  HostName db.example.com\n"), 0o600))
+
+	plan, err := planSplit(path, home, "domain")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web: holds an encrypted include"}, plan.kept, "the marker only works in the main config")
+	require.Len(t, plan.files, 2)
+	assert.Contains(t, string(plan.files[1].data), "# gt:include-encrypted web.age")
+}
@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+var auditParallel int
+
+// weakAlgorithms are, per kind, the algorithms gt audit ssh flags, by
+// name or name prefix: SHA-1 key exchange and signatures, DSA, CBC and
+// RC4 ciphers, and MD5, SHA-1 and 64-bit-tag MACs.
+var weakAlgorithms = map[string][]string{
+	"kex":     {"diffie-hellman-group1-sha1", "diffie-hellman-group14-sha1", "diffie-hellman-group-exchange-sha1", "gss-gex-sha1-", "gss-group1-sha1-", "gss-group14-sha1-"},
+	"hostkey": {"ssh-rsa", "ssh-dss"},
+	"cipher":  {"3des-cbc", "aes128-cbc", "aes192-cbc", "aes256-cbc", "blowfish-cbc", "cast128-cbc", "arcfour", "rijndael-cbc@lysator.liu.se"},
+	"mac":     {"hmac-md5", "hmac-sha1", "umac-64", "hmac-ripemd160"},
+}
+
+// weakAlgorithm reports whether name, of kind, is among weakAlgorithms.
+func weakAlgorithm(kind, name string) bool {
+	for _, w := range weakAlgorithms[kind] {
+		if strings.HasPrefix(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// opensshVersion is an OpenSSH release: 9.6p1 is {9, 6, 1}.
+type opensshVersion struct{ major, minor, patch int }
+
+func (v opensshVersion) less(o opensshVersion) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	return v.patch < o.patch
+}
+
+var opensshVersionRe = regexp.MustCompile(`^OpenSSH_(\d+)\.(\d+)(?:p(\d+))?`)
+
+// parseOpenSSHVersion reads the release from a server's software
+// version, such as "OpenSSH_8.9p1 Ubuntu-3ubuntu0.10"; ok is false for
+// other servers.
+func parseOpenSSHVersion(software string) (v opensshVersion, ok bool) {
+	m := opensshVersionRe.FindStringSubmatch(software)
+	if m == nil {
+		return v, false
+	}
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	v.patch, _ = strconv.Atoi(m[3])
+	return v, true
+}
+
+// outdatedOpenSSH is the first release gt audit ssh does not call
+// outdated: 8.0, of April 2019.
+var outdatedOpenSSH = opensshVersion{8, 0, 0}
+
+// opensshAdvisories are the sshd vulnerabilities gt audit ssh knows by
+// version range, from (inclusive) to (exclusive).
+var opensshAdvisories = []struct {
+	from, to opensshVersion
+	text     string
+}{
+	{opensshVersion{0, 0, 0}, opensshVersion{4, 4, 1}, "CVE-2006-5051: signal handler race in sshd"},
+	{opensshVersion{0, 0, 0}, opensshVersion{7, 7, 0}, "CVE-2018-15473: user names can be enumerated"},
+	{opensshVersion{6, 2, 0}, opensshVersion{8, 8, 0}, "CVE-2021-41617: AuthorizedKeysCommand runs with sshd's supplementary groups"},
+	{opensshVersion{8, 5, 1}, opensshVersion{9, 8, 1}, "CVE-2024-6387 (regreSSHion): unauthenticated remote code execution in sshd"},
+}
+
+// sshFinding is one thing gt audit ssh flags; severe ones fail the host.
+type sshFinding struct {
+	severe bool
+	text   string
+}
+
+// sshAudit is what one host's handshake showed.
+type sshAudit struct {
+	alias    string
+	software string
+	// The negotiated algorithms. mac is "<implicit>" for AEAD ciphers.
+	kex, hostKey, cipher, mac string
+	// offered are the server's KEXINIT lists, by kind.
+	offered  map[string][]string
+	findings []sshFinding
+	err      error
+}
+
+// proposalKinds maps the lines of a KEXINIT proposal in ssh -vv output
+// to kinds of weakAlgorithms; the stoc lists repeat the ctos ones.
+var proposalKinds = map[string]string{
+	"KEX algorithms":      "kex",
+	"host key algorithms": "hostkey",
+	"ciphers ctos":        "cipher",
+	"MACs ctos":           "mac",
+}
+
+// parseHandshake reads the ssh -vv output of a connection to alias: the
+// server's software version, the server's KEXINIT proposal and the
+// algorithms ssh picked.
+func parseHandshake(alias string, r io.Reader) sshAudit {
+	a := sshAudit{alias: alias, offered: map[string][]string{}}
+	peer := false
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		_, msg, ok := strings.Cut(line, ": ")
+		if !ok || !strings.HasPrefix(line, "debug") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(msg, "Remote protocol version"):
+			if _, v, ok := strings.Cut(msg, "remote software version "); ok {
+				a.software = v
+			}
+		case msg == "peer server KEXINIT proposal":
+			peer = true
+		case msg == "local client KEXINIT proposal":
+			peer = false
+		case strings.HasPrefix(msg, "kex: algorithm: "):
+			a.kex = strings.TrimPrefix(msg, "kex: algorithm: ")
+		case strings.HasPrefix(msg, "kex: host key algorithm: "):
+			a.hostKey = strings.TrimPrefix(msg, "kex: host key algorithm: ")
+		case strings.HasPrefix(msg, "kex: server->client cipher: "):
+			// "cipher: X MAC: Y compression: Z"
+			f := strings.Fields(msg)
+			if len(f) >= 6 {
+				a.cipher, a.mac = f[3], f[5]
+			}
+		default:
+			if !peer {
+				continue
+			}
+			name, list, ok := strings.Cut(msg, ": ")
+			if kind, known := proposalKinds[name]; known && ok {
+				a.offered[kind] = strings.Split(list, ",")
+			}
+		}
+	}
+	return a
+}
+
+// judge fills in a's findings: its OpenSSH version, weak algorithms it
+// negotiated (severe) or still offers, and the Terrapin attack
+// (CVE-2023-48795), which servers without strict key exchange are open
+// to when they offer ChaCha20-Poly1305 or an EtM MAC.
+func (a *sshAudit) judge() {
+	if v, ok := parseOpenSSHVersion(a.software); ok {
+		if v.less(outdatedOpenSSH) {
+			a.findings = append(a.findings, sshFinding{text: fmt.Sprintf("%s is outdated: from 2018 or earlier", strings.Fields(a.software)[0])})
+		}
+		for _, adv := range opensshAdvisories {
+			if !v.less(adv.from) && v.less(adv.to) {
+				a.findings = append(a.findings, sshFinding{severe: true, text: adv.text})
+			}
+		}
+	}
+	for _, n := range []struct{ kind, name string }{{"kex", a.kex}, {"hostkey", a.hostKey}, {"cipher", a.cipher}, {"mac", a.mac}} {
+		if weakAlgorithm(n.kind, n.name) {
+			a.findings = append(a.findings, sshFinding{severe: true, text: fmt.Sprintf("negotiated weak %s %s", n.kind, n.name)})
+		}
+	}
+	for _, kind := range []string{"kex", "hostkey", "cipher", "mac"} {
+		var weak []string
+		for _, name := range a.offered[kind] {
+			if weakAlgorithm(kind, name) && name != a.kex && name != a.hostKey && name != a.cipher && name != a.mac {
+				weak = append(weak, name)
+			}
+		}
+		if len(weak) > 0 {
+			a.findings = append(a.findings, sshFinding{text: fmt.Sprintf("offers weak %s %s", kind, strings.Join(weak, ", "))})
+		}
+	}
+	strict, terrapin := false, false
+	for _, k := range a.offered["kex"] {
+		strict = strict || k == "kex-strict-s-v00@openssh.com"
+	}
+	for _, c := range a.offered["cipher"] {
+		terrapin = terrapin || c == "chacha20-poly1305@openssh.com"
+	}
+	for _, m := range a.offered["mac"] {
+		terrapin = terrapin || strings.HasSuffix(m, "-etm@openssh.com")
+	}
+	if terrapin && !strict && len(a.offered["kex"]) > 0 {
+		a.findings = append(a.findings, sshFinding{severe: true, text: "CVE-2023-48795 (Terrapin): ChaCha20-Poly1305 or EtM offered without strict key exchange"})
+	}
+}
+
+// result is the host's verdict: fail with a severe finding, warn with
+// any other, else ok.
+func (a sshAudit) result() string {
+	switch {
+	case a.err != nil:
+		return "error"
+	case len(a.findings) == 0:
+		return "ok"
+	}
+	for _, f := range a.findings {
+		if f.severe {
+			return "fail"
+		}
+	}
+	return "warn"
+}
+
+// auditHandshake connects to alias with ssh -vv, in BatchMode and
+// without a ControlMaster so there is a handshake to read. The login
+// itself need not succeed: the algorithms are agreed before it. It runs
+// the connect hooks; one that refuses the host makes it an error.
+func auditHandshake(alias string) sshAudit {
+	args := hostOptions(baseOptions(), alias).BaseArgs()
+	args = append(args, "-vv", "-o", "BatchMode=yes", "-o", "ControlMaster=no", "-o", "ControlPath=none", "--", alias, "true")
+	var stderr bytes.Buffer
+	ran := false
+	err := withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: alias, Command: []string{"true"}}, func() error {
+		ran = true
+		cmd := sshCommand(args...)
+		debugf(1, "exec: %s", quoteArgv(cmd.Args))
+		cmd.Stderr = &stderr
+		return cmd.Run()
+	})
+	if !ran {
+		return sshAudit{alias: alias, err: err}
+	}
+	a := parseHandshake(alias, bytes.NewReader(stderr.Bytes()))
+	if a.kex == "" {
+		if err == nil {
+			err = errors.New("no key exchange in ssh -vv output")
+		}
+		var tail string
+		for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+			if !strings.HasPrefix(line, "debug") {
+				tail = line
+			}
+		}
+		if tail != "" {
+			err = fmt.Errorf("%w: %s", err, tail)
+		}
+		a.err = err
+		return a
+	}
+	a.judge()
+	return a
+}
+
+// renderSSHAudit writes a table of each host's version and negotiated
+// algorithms, weak ones in the error color, with its findings beneath.
+func renderSSHAudit(w io.Writer, hosts []sshAudit) {
+	head := []string{"HOST", "VERSION", "KEX", "CIPHER", "MAC", "RESULT"}
+	cells := func(a sshAudit) []string {
+		version := "-"
+		if f := strings.Fields(a.software); len(f) > 0 {
+			version = f[0]
+		}
+		return []string{a.alias, version, a.kex, a.cipher, a.mac, a.result()}
+	}
+	widths := make([]int, len(head))
+	for i, h := range head {
+		widths[i] = len(h)
+	}
+	for _, a := range hosts {
+		if a.err != nil {
+			continue
+		}
+		for i, c := range cells(a) {
+			if n := displayWidth(c); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	pad := func(s string, i int) string { return s + strings.Repeat(" ", widths[i]-displayWidth(s)+2) }
+	for i, h := range head[:len(head)-1] {
+		symbolColor.Fprint(w, pad(h, i))
+	}
+	symbolColor.Fprintln(w, head[len(head)-1])
+
+	kinds := []string{"", "", "kex", "cipher", "mac"}
+	for _, a := range hosts {
+		aliasColor.Fprint(w, pad(a.alias, 0))
+		if a.err != nil {
+			errorColor.Fprintln(w, firstLine(a.err.Error()))
+			continue
+		}
+		c := cells(a)
+		fmt.Fprint(w, pad(c[1], 1))
+		for i := 2; i < 5; i++ {
+			if weakAlgorithm(kinds[i], c[i]) {
+				errorColor.Fprint(w, pad(c[i], i))
+			} else {
+				fmt.Fprint(w, pad(c[i], i))
+			}
+		}
+		switch a.result() {
+		case "ok":
+			userColor.Fprintln(w, "ok")
+		case "warn":
+			warningColor.Fprintln(w, "warn")
+		default:
+			errorColor.Fprintln(w, a.result())
+		}
+		for _, f := range a.findings {
+			if f.severe {
+				errorColor.Fprintf(w, "  %s\n", f.text)
+			} else {
+				warningColor.Fprintf(w, "  %s\n", f.text)
+			}
+		}
+	}
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit hosts' SSH servers",
+}
+
+var auditSSHCmd = &cobra.Command{
+	Use:   "ssh <alias|@group>",
+	Short: "Check servers' OpenSSH version and algorithms",
+	Long: `Connect to each host with ssh -vv and read the handshake: the server's
+software version, the algorithms it offers, and the key exchange, cipher
+and MAC ssh agreed on. Flagged are OpenSSH releases before 8.0, versions
+with known sshd vulnerabilities (CVE-2018-15473, CVE-2021-41617,
+CVE-2024-6387 and others), the Terrapin attack (CVE-2023-48795) on
+servers without strict key exchange, and weak algorithms: SHA-1 key
+exchange, ssh-rsa and DSA host keys, CBC and RC4 ciphers, and MD5, SHA-1
+and 64-bit MACs.
+
+A weak algorithm in use, or a known vulnerability, fails the host; an
+outdated server or a weak algorithm only offered is a warning.
+Distributions backport fixes without changing the version, so treat
+version findings as leads. Connections run in BatchMode, up to --parallel
+at a time, and need not log in. gt exits 1 if any host failed or could
+not be audited.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if usePuTTY() || pluginTransport() != nil {
+			return errors.New("gt audit ssh reads ssh -vv output; it needs the OpenSSH backend")
+		}
+		aliases, err := expandTarget(args[0])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		var mu sync.Mutex
+		audits := make(map[string]sshAudit, len(aliases))
+		results := fanOut(aliases, auditParallel, func(alias string) error {
+			a := auditHandshake(alias)
+			mu.Lock()
+			audits[alias] = a
+			mu.Unlock()
+			if r := a.result(); r == "fail" || r == "error" {
+				return errors.New(r)
+			}
+			return nil
+		}, nil)
+		ordered := make([]sshAudit, len(aliases))
+		for i, alias := range aliases {
+			ordered[i] = audits[alias]
+		}
+		var out bytes.Buffer
+		renderSSHAudit(&out, ordered)
+		if _, err := cmd.OutOrStdout().Write(out.Bytes()); err != nil {
+			return err
+		}
+		return failedHosts(results, 1)
+	},
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOpenSSHVersion(t *testing.T) {
+	v, ok := parseOpenSSHVersion("OpenSSH_8.9p1 Ubuntu-3ubuntu0.10")
+	require.True(t, ok)
+	assert.Equal(t, opensshVersion{8, 9, 1}, v)
+	v, ok = parseOpenSSHVersion("OpenSSH_7.4")
+	require.True(t, ok)
+	assert.Equal(t, opensshVersion{7, 4, 0}, v)
+	_, ok = parseOpenSSHVersion("dropbear_2022.83")
+	assert.False(t, ok)
+
+	assert.True(t, opensshVersion{9, 7, 1}.less(opensshVersion{9, 8, 1}))
+	assert.False(t, opensshVersion{9, 8, 1}.less(opensshVersion{9, 8, 1}))
+	assert.True(t, opensshVersion{8, 9, 9}.less(opensshVersion{9, 0, 0}))
+}
+
+func TestWeakAlgorithm(t *testing.T) {
+	assert.True(t, weakAlgorithm("mac", "hmac-sha1-etm@openssh.com"))
+	assert.True(t, weakAlgorithm("hostkey", "ssh-rsa"))
+	assert.False(t, weakAlgorithm("hostkey", "rsa-sha2-512"))
+	assert.True(t, weakAlgorithm("cipher", "arcfour256"))
+	assert.False(t, weakAlgorithm("cipher", "aes128-ctr"))
+	assert.False(t, weakAlgorithm("mac", "<implicit>"))
+}
+
+func TestParseHandshake(t *testing.T) {
+	a := parseHandshake("web-2", strings.NewReader(mockHandshake("web-2")))
+	assert.Equal(t, "OpenSSH_7.4", a.software)
+	assert.Equal(t, "curve25519-sha256", a.kex)
+	assert.Equal(t, "ssh-ed25519", a.hostKey)
+	assert.Equal(t, "aes128-ctr", a.cipher)
+	assert.Equal(t, "hmac-sha1", a.mac)
+	assert.Equal(t, []string{"curve25519-sha256", "diffie-hellman-group14-sha1"}, a.offered["kex"], "the server's proposal, not the client's")
+	assert.Equal(t, []string{"aes128-ctr", "aes128-cbc"}, a.offered["cipher"])
+}
+
+func TestJudgeHandshake(t *testing.T) {
+	a := parseHandshake("web-2", strings.NewReader(mockHandshake("web-2")))
+	a.judge()
+	var texts []string
+	for _, f := range a.findings {
+		texts = append(texts, f.text)
+	}
+	assert.Equal(t, []string{
+		"OpenSSH_7.4 is outdated: from 2018 or earlier",
+		"CVE-2018-15473: user names can be enumerated",
+		"CVE-2021-41617: AuthorizedKeysCommand runs with sshd's supplementary groups",
+		"negotiated weak mac hmac-sha1",
+		"offers weak kex diffie-hellman-group14-sha1",
+		"offers weak cipher aes128-cbc",
+	}, texts)
+	assert.Equal(t, "fail", a.result())
+
+	a = parseHandshake("web-1", strings.NewReader(mockHandshake("web-1")))
+	a.judge()
+	assert.Empty(t, a.findings, "9.8p1 is past regreSSHion, and strict kex rules out Terrapin")
+	assert.Equal(t, "ok", a.result())
+
+	terrapin := sshAudit{software: "OpenSSH_9.5p1", kex: "curve25519-sha256", offered: map[string][]string{
+		"kex":    {"curve25519-sha256"},
+		"cipher": {"chacha20-poly1305@openssh.com"},
+	}}
+	terrapin.judge()
+	require.Len(t, terrapin.findings, 2)
+	assert.Contains(t, terrapin.findings[0].text, "CVE-2024-6387")
+	assert.Contains(t, terrapin.findings[1].text, "CVE-2023-48795")
+
+	old := sshAudit{software: "OpenSSH_7.9p1", kex: "curve25519-sha256"}
+	old.judge()
+	assert.Equal(t, "fail", old.result())
+	warn := sshAudit{software: "dropbear_2022.83", kex: "curve25519-sha256", offered: map[string][]string{"mac": {"hmac-sha1"}}}
+	warn.judge()
+	assert.Equal(t, "warn", warn.result())
+}
+
+func TestAuditSSHOnGroup(t *testing.T) {
+	useMockExec(t)
+	plainOutput(t)
+	useWebGroup(t)
+
+	var out bytes.Buffer
+	auditSSHCmd.SetOut(&out)
+	t.Cleanup(func() { auditSSHCmd.SetOut(nil) })
+	err := auditSSHCmd.RunE(auditSSHCmd, []string{"@web"})
+	assert.Equal(t, 1, ExitCode(err))
+	assert.Contains(t, err.Error(), "2 of 3 hosts failed")
+
+	lines := strings.Split(out.String(), "\n")
+	assert.Regexp(t, `^HOST\s+VERSION\s+KEX\s+CIPHER\s+MAC\s+RESULT$`, lines[0])
+	assert.Regexp(t, `^down\s+exit status 255: ssh: connect to host down port 22: Connection refused$`, lines[1])
+	assert.Regexp(t, `^web-1\s+OpenSSH_9\.8p1\s+curve25519-sha256\s+aes256-gcm@openssh\.com\s+<implicit>\s+ok$`, lines[2])
+	assert.Regexp(t, `^web-2\s+OpenSSH_7\.4\s+curve25519-sha256\s+aes128-ctr\s+hmac-sha1\s+fail$`, lines[3])
+	assert.Equal(t, "  negotiated weak mac hmac-sha1", lines[7])
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	yesNo = []string{"yes", "no"}
+
+	// sshOptionValues lists the ssh_config(5) client options that can be
+	// given with -o, with the fixed values each accepts. nil means the
+	// value is free-form: a path, a number, or a list.
+	sshOptionValues = map[string][]string{
+		"AddKeysToAgent":                   {"yes", "no", "ask", "confirm"},
+		"AddressFamily":                    {"any", "inet", "inet6"},
+		"BatchMode":                        yesNo,
+		"BindAddress":                      nil,
+		"BindInterface":                    nil,
+		"CanonicalDomains":                 nil,
+		"CanonicalizeFallbackLocal":        yesNo,
+		"CanonicalizeHostname":             {"yes", "no", "always", "none"},
+		"CanonicalizeMaxDots":              nil,
+		"CanonicalizePermittedCNAMEs":      nil,
+		"CASignatureAlgorithms":            nil,
+		"CertificateFile":                  nil,
+		"ChannelTimeout":                   nil,
+		"CheckHostIP":                      yesNo,
+		"Ciphers":                          nil,
+		"ClearAllForwardings":              yesNo,
+		"Compression":                      yesNo,
+		"ConnectionAttempts":               nil,
+		"ConnectTimeout":                   nil,
+		"ControlMaster":                    {"yes", "no", "ask", "auto", "autoask"},
+		"ControlPath":                      {"none"},
+		"ControlPersist":                   {"yes", "no"},
+		"DynamicForward":                   nil,
+		"EnableEscapeCommandline":          yesNo,
+		"EnableSSHKeysign":                 yesNo,
+		"EscapeChar":                       {"none"},
+		"ExitOnForwardFailure":             yesNo,
+		"FingerprintHash":                  {"md5", "sha256"},
+		"ForkAfterAuthentication":          yesNo,
+		"ForwardAgent":                     yesNo,
+		"ForwardX11":                       yesNo,
+		"ForwardX11Timeout":                nil,
+		"ForwardX11Trusted":                yesNo,
+		"GatewayPorts":                     yesNo,
+		"GlobalKnownHostsFile":             nil,
+		"GSSAPIAuthentication":             yesNo,
+		"GSSAPIDelegateCredentials":        yesNo,
+		"HashKnownHosts":                   yesNo,
+		"HostbasedAcceptedAlgorithms":      nil,
+		"HostbasedAuthentication":          yesNo,
+		"HostKeyAlgorithms":                nil,
+		"HostKeyAlias":                     nil,
+		"HostName":                         nil,
+		"IdentitiesOnly":                   yesNo,
+		"IdentityAgent":                    {"none", "SSH_AUTH_SOCK"},
+		"IdentityFile":                     nil,
+		"IgnoreUnknown":                    nil,
+		"IPQoS":                            {"lowdelay", "throughput", "reliability", "none", "af11", "af21", "af31", "af41", "cs0", "cs1", "ef"},
+		"KbdInteractiveAuthentication":     yesNo,
+		"KbdInteractiveDevices":            nil,
+		"KexAlgorithms":                    nil,
+		"KnownHostsCommand":                nil,
+		"LocalCommand":                     nil,
+		"LocalForward":                     nil,
+		"LogLevel":                         {"QUIET", "FATAL", "ERROR", "INFO", "VERBOSE", "DEBUG", "DEBUG1", "DEBUG2", "DEBUG3"},
+		"LogVerbose":                       nil,
+		"MACs":                             nil,
+		"NoHostAuthenticationForLocalhost": yesNo,
+		"NumberOfPasswordPrompts":          nil,
+		"ObscureKeystrokeTiming":           yesNo,
+		"PasswordAuthentication":           yesNo,
+		"PermitLocalCommand":               yesNo,
+		"PermitRemoteOpen":                 {"any", "none"},
+		"PKCS11Provider":                   {"none"},
+		"Port":                             nil,
+		"PreferredAuthentications":         {"publickey", "keyboard-interactive", "password", "hostbased", "gssapi-with-mic"},
+		"ProxyCommand":                     {"none"},
+		"ProxyJump":                        {"none"},
+		"ProxyUseFdpass":                   yesNo,
+		"PubkeyAcceptedAlgorithms":         nil,
+		"PubkeyAuthentication":             {"yes", "no", "unbound", "host-bound"},
+		"RekeyLimit":                       nil,
+		"RemoteCommand":                    {"none"},
+		"RemoteForward":                    nil,
+		"RequestTTY":                       {"yes", "no", "force", "auto"},
+		"RequiredRSASize":                  nil,
+		"RevokedHostKeys":                  nil,
+		"SecurityKeyProvider":              nil,
+		"SendEnv":                          nil,
+		"ServerAliveCountMax":              nil,
+		"ServerAliveInterval":              nil,
+		"SessionType":                      {"none", "subsystem", "default"},
+		"SetEnv":                           nil,
+		"StdinNull":                        yesNo,
+		"StreamLocalBindMask":              nil,
+		"StreamLocalBindUnlink":            yesNo,
+		"StrictHostKeyChecking":            {"yes", "no", "ask", "accept-new", "off"},
+		"SyslogFacility":                   {"DAEMON", "USER", "AUTH", "LOCAL0", "LOCAL1", "LOCAL2", "LOCAL3", "LOCAL4", "LOCAL5", "LOCAL6", "LOCAL7"},
+		"TCPKeepAlive":                     yesNo,
+		"Tag":                              nil,
+		"Tunnel":                           {"yes", "no", "point-to-point", "ethernet"},
+		"TunnelDevice":                     nil,
+		"UpdateHostKeys":                   {"yes", "no", "ask"},
+		"User":                             nil,
+		"UserKnownHostsFile":               {"none"},
+		"VerifyHostKeyDNS":                 {"yes", "no", "ask"},
+		"VisualHostKey":                    yesNo,
+		"XAuthLocation":                    nil,
+	}
+
+	// sshOptionQueries maps algorithm-list options to the ssh -Q query
+	// that lists what the local ssh supports, which changes between
+	// OpenSSH builds too often to hard-code.
+	sshOptionQueries = map[string]string{
+		"Ciphers":                     "cipher",
+		"MACs":                        "mac",
+		"KexAlgorithms":               "kex",
+		"HostKeyAlgorithms":           "key-sig",
+		"PubkeyAcceptedAlgorithms":    "key-sig",
+		"HostbasedAcceptedAlgorithms": "key-sig",
+		"CASignatureAlgorithms":       "sig",
+	}
+)
+
+// canonicalOption finds the ssh_config spelling of an option name.
+// ssh matches names case-insensitively.
+func canonicalOption(name string) (string, bool) {
+	for opt := range sshOptionValues {
+		if strings.EqualFold(opt, name) {
+			return opt, true
+		}
+	}
+	return "", false
+}
+
+// optionValues lists what opt accepts: its fixed values, or what ssh -Q
+// reports for an algorithm list.
+func optionValues(opt string) []string {
+	if q, ok := sshOptionQueries[opt]; ok {
+		out, err := sshCommand("-Q", q).Output()
+		if err != nil {
+			debugf(1, "ssh -Q %s: %v", q, err)
+			return nil
+		}
+		return strings.Fields(string(out))
+	}
+	return sshOptionValues[opt]
+}
+
+// completeSSHOption completes -o: option names up to the "=", then the
+// option's values after it.
+func completeSSHOption(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	name, value, hasValue := strings.Cut(toComplete, "=")
+	if !hasValue {
+		var names []string
+		for opt := range sshOptionValues {
+			if len(name) <= len(opt) && strings.EqualFold(opt[:len(name)], name) {
+				names = append(names, opt+"=")
+			}
+		}
+		sort.Strings(names)
+		return names, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+	}
+	opt, ok := canonicalOption(name)
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var values []string
+	for _, v := range optionValues(opt) {
+		if strings.HasPrefix(strings.ToLower(v), strings.ToLower(value)) {
+			values = append(values, name+"="+v)
+		}
+	}
+	return values, cobra.ShellCompDirectiveNoFileComp
+}
+
+// cutOverride splits a -o value as ssh reads a config line: the key ends
+// at the first space, tab or '=', and one '=' with spaces around it may
+// separate it from the value.
+func cutOverride(opt string) (key, value string, ok bool) {
+	opt = strings.TrimLeft(opt, " \t")
+	i := strings.IndexAny(opt, " \t=")
+	if i < 0 {
+		return opt, "", false
+	}
+	value = strings.TrimLeft(opt[i:], " \t")
+	if strings.HasPrefix(value, "=") {
+		value = strings.TrimLeft(value[1:], " \t")
+	}
+	return opt[:i], value, true
+}
+
+// validateOverride checks a -o value has the KEY=VALUE shape ssh wants.
+// The key itself is left to ssh, which knows options newer than this
+// list.
+func validateOverride(opt string) error {
+	name, _, ok := cutOverride(opt)
+	if !ok || name == "" || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid -o %q: want KEY=VALUE, e.g. -o ServerAliveInterval=30", opt)
+	}
+	return nil
+}
+
+var puttyOptionsWarning sync.Once
+
+// warnPuTTYOptions notes once that -o has no PuTTY equivalent.
+func warnPuTTYOptions() {
+	if len(sshOverrides) == 0 {
+		return
+	}
+	puttyOptionsWarning.Do(func() {
+		warningColor.Fprintln(os.Stderr, "Ignoring -o: PuTTY does not take ssh_config options")
+	})
+}
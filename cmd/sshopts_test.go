@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompleteSSHOptionNames(t *testing.T) {
+	got, directive := completeSSHOption(nil, nil, "stricth")
+	assert.Equal(t, []string{"StrictHostKeyChecking="}, got)
+	assert.NotZero(t, directive&cobra.ShellCompDirectiveNoSpace, "no space after the =")
+
+	got, _ = completeSSHOption(nil, nil, "Forward")
+	assert.Equal(t, []string{"ForwardAgent=", "ForwardX11=", "ForwardX11Timeout=", "ForwardX11Trusted="}, got)
+
+	all, _ := completeSSHOption(nil, nil, "")
+	assert.Len(t, all, len(sshOptionValues))
+}
+
+func TestCompleteSSHOptionValues(t *testing.T) {
+	got, _ := completeSSHOption(nil, nil, "StrictHostKeyChecking=a")
+	assert.Equal(t, []string{"StrictHostKeyChecking=ask", "StrictHostKeyChecking=accept-new"}, got)
+
+	got, _ = completeSSHOption(nil, nil, "batchmode=")
+	assert.Equal(t, []string{"batchmode=yes", "batchmode=no"}, got, "the typed spelling is kept")
+
+	got, _ = completeSSHOption(nil, nil, "ServerAliveInterval=")
+	assert.Empty(t, got, "free-form values have nothing to offer")
+	got, _ = completeSSHOption(nil, nil, "NoSuchOption=")
+	assert.Empty(t, got)
+}
+
+func TestCompleteSSHOptionQueriesSSH(t *testing.T) {
+	useMockExec(t)
+	completeSSHOption(nil, nil, "Ciphers=")
+	assert.Equal(t, []string{"-Q", "cipher"}, mockCmd.argLists[0])
+}
+
+func TestValidateOverride(t *testing.T) {
+	for _, ok := range []string{"ServerAliveInterval=30", "ProxyJump none", "SetEnv=A=b"} {
+		assert.NoError(t, validateOverride(ok), ok)
+	}
+	for _, bad := range []string{"ServerAliveInterval", "=30", "-oProxyCommand=x"} {
+		assert.Error(t, validateOverride(bad), bad)
+	}
+}
+
+func TestOverridesReachSSH(t *testing.T) {
+	orig := sshOverrides
+	t.Cleanup(func() { sshOverrides = orig })
+	sshOverrides = []string{"ServerAliveInterval=30"}
+	assert.Equal(t, []string{"-o", "ServerAliveInterval=30"}, sshBaseArgs())
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
+)
+
+// sshURL is a parsed ssh://[user@]host[:port] URI. port is "" when the
+// URI leaves it out.
+type sshURL struct {
+	user, host, port string
+}
+
+// isSSHURL reports whether arg is meant as an ssh:// URI rather than an
+// alias.
+func isSSHURL(arg string) bool {
+	return len(arg) > len("ssh://") && strings.EqualFold(arg[:len("ssh://")], "ssh://")
+}
+
+// parseSSHURL reads an ssh:// URI as wikis and dashboards link hosts:
+// ssh://user@host:port, where only the host is required. Connection
+// parameters after a ';' in the user part (the URI draft's fingerprint)
+// are dropped, and a path, if any, must be just "/": gt opens logins,
+// not files.
+func parseSSHURL(arg string) (sshURL, error) {
+	u, err := url.Parse(arg)
+	if err != nil {
+		return sshURL{}, fmt.Errorf("invalid ssh:// URI: %w", err)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return sshURL{}, fmt.Errorf("invalid ssh:// URI %q: no host", arg)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return sshURL{}, fmt.Errorf("invalid ssh:// URI %q: gt connects to the host and takes no path or query", arg)
+	}
+	s := sshURL{host: u.Hostname(), port: u.Port()}
+	if u.User != nil {
+		s.user, _, _ = strings.Cut(u.User.Username(), ";")
+	}
+	if s.host == "" {
+		return sshURL{}, fmt.Errorf("invalid ssh:// URI %q: no host", arg)
+	}
+	if err := transport.ValidateNoFlagPrefix("host", s.host); err != nil {
+		return sshURL{}, err
+	}
+	if err := transport.ValidateNoFlagPrefix("user", s.user); err != nil {
+		return sshURL{}, err
+	}
+	return s, nil
+}
+
+// urlAlias is the alias in the SSH config that u points at: one whose
+// HostName (or name, or HostName with one of its CanonicalDomains) is
+// u's host and whose port is u's, 22 for either when unset. Of several, one whose User is u's user wins. The parsed
+// config is read, as for completion, rather than running ssh -G for
+// every host, so Match blocks are not seen.
+func urlAlias(u sshURL) (alias string, ok bool) {
+	port := u.port
+	if port == "" {
+		port = "22"
+	}
+	for _, a := range getHosts() {
+		r := sshconf.FromConfig(cfg, a, "")
+		if r.Port != port {
+			continue
+		}
+		short := sshconf.ParseCanonicalization(configCanonicalization(a, "")).ShortFor(r.Hostname, u.host)
+		if !strings.EqualFold(r.Hostname, u.host) && !strings.EqualFold(a, u.host) && !short {
+			continue
+		}
+		configUser, _ := cfg.Get(a, "User")
+		if u.user == "" || configUser == u.user {
+			return a, true
+		}
+		if !ok {
+			alias, ok = a, true
+		}
+	}
+	return alias, ok
+}
+
+// urlTarget is what gt connects to for u: the alias it matches, or, for
+// a host the config does not have, the host itself, reached as ssh
+// would reach it from the command line (Host * and pattern blocks still
+// apply). The URI's user and port are applied as -u and -o Port would
+// be, an explicit -u winning. A host taken as is is left in adHocHost.
+func urlTarget(u sshURL) (string, error) {
+	if user == "" {
+		user = u.user
+	}
+	if alias, ok := urlAlias(u); ok {
+		debugf(1, "%s matches %s", u.host, alias)
+		if configUser, _ := cfg.Get(alias, "User"); user == configUser {
+			user = ""
+		}
+		return alias, nil
+	}
+	if u.port != "" {
+		if usePuTTY() {
+			return "", errors.New("the PuTTY backend reads ports from the SSH config only; add the host with gt add to connect to it")
+		}
+		sshOverrides = append(sshOverrides, "Port="+u.port)
+	}
+	debugf(1, "%s is in no Host block; connecting to it as is", u.host)
+	adHocHost = &sshURL{user: user, host: u.host, port: u.port}
+	return u.host, nil
+}
+
+// confirmLinkHost asks before logging in to u, the host of a link that
+// no Host block has: a link comes from a page, not from the user, so
+// its destination is shown in full, user and port too, for a lookalike
+// name to stand out. With no one to ask, the link is refused; the same
+// address typed as gt [user@]host[:port] connects without the question.
+func confirmLinkHost(p *prompter, u sshURL) error {
+	dest := u.host
+	if u.port != "" {
+		dest = net.JoinHostPort(u.host, u.port)
+	}
+	if u.user != "" {
+		dest = u.user + "@" + dest
+	}
+	if nonInteractive() {
+		return fmt.Errorf("%s is in no Host block and gt asks before following a link to it; run gt %s to connect without asking", dest, dest)
+	}
+	if !p.confirm(fmt.Sprintf("The link is for %s, which is in no Host block. Connect?", dest), false) {
+		return withCode(1, errors.New("aborted"))
+	}
+	return nil
+}
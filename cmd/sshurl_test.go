@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSSHURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want sshURL
+	}{
+		{"ssh://web1.example.com", sshURL{host: "web1.example.com"}},
+		{"ssh://deploy@web1.example.com:2222", sshURL{user: "deploy", host: "web1.example.com", port: "2222"}},
+		{"SSH://deploy@web1.example.com/", sshURL{user: "deploy", host: "web1.example.com"}},
+		{"ssh://deploy;fingerprint=ssh-ed25519-abc@[2001:db8::1]:22", sshURL{user: "deploy", host: "2001:db8::1", port: "22"}},
+	}
+	for _, tt := range tests {
+		assert.True(t, isSSHURL(tt.in), tt.in)
+		got, err := parseSSHURL(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, bad := range []string{"ssh://", "ssh://host/etc/passwd", "ssh://host?x=1", "ssh://-oProxyCommand=x", "ssh://-l@host"} {
+		_, err := parseSSHURL(bad)
+		assert.Error(t, err, bad)
+	}
+	assert.False(t, isSSHURL("web1"))
+	assert.False(t, isSSHURL("sftp://web1"))
+}
+
+func useURLConfig(t *testing.T) {
+	t.Helper()
+	origCfg, origUser, origOverrides, origAdHoc := cfg, user, sshOverrides, adHocHost
+	t.Cleanup(func() { cfg, user, sshOverrides, adHocHost = origCfg, origUser, origOverrides, origAdHoc })
+	decoded, err := ssh_config.Decode(strings.NewReader(`Host web1
+  HostName web1.example.com
+  User deploy
+Host web1-root
+  HostName web1.example.com
+  User root
+Host web1-alt
+  HostName web1.example.com
+  Port 2222
+Host db
+Host db3
+  CanonicalDomains corp.example.com
+`))
+	require.NoError(t, err)
+	cfg = decoded
+	user, sshOverrides, adHocHost = "", nil, nil
+}
+
+func TestURLTarget(t *testing.T) {
+	useURLConfig(t)
+	tests := []struct {
+		url, alias, user string
+	}{
+		{"ssh://web1.example.com", "web1", ""},
+		{"ssh://deploy@WEB1.example.com:22", "web1", ""},
+		{"ssh://root@web1.example.com", "web1-root", ""},
+		{"ssh://admin@web1.example.com", "web1", "admin"},
+		{"ssh://web1.example.com:2222", "web1-alt", ""},
+		{"ssh://ops@db", "db", "ops"},
+		{"ssh://db3.corp.example.com", "db3", ""},
+	}
+	for _, tt := range tests {
+		user = ""
+		u, err := parseSSHURL(tt.url)
+		require.NoError(t, err)
+		got, err := urlTarget(u)
+		require.NoError(t, err, tt.url)
+		assert.Equal(t, tt.alias, got, tt.url)
+		assert.Equal(t, tt.user, user, tt.url)
+		assert.Empty(t, sshOverrides, tt.url)
+		assert.Nil(t, adHocHost, tt.url)
+	}
+}
+
+func TestURLTargetAdHoc(t *testing.T) {
+	useURLConfig(t)
+	user = "override"
+	got, err := urlTarget(sshURL{user: "deploy", host: "new.example.com", port: "2200"})
+	require.NoError(t, err)
+	assert.Equal(t, "new.example.com", got, "a host the config lacks is connected to as is")
+	assert.Equal(t, "override", user, "-u wins over the URI's user")
+	assert.Equal(t, []string{"Port=2200"}, sshOverrides)
+	assert.Equal(t, &sshURL{user: "override", host: "new.example.com", port: "2200"}, adHocHost)
+}
+
+func TestRootTargetsURL(t *testing.T) {
+	useURLConfig(t)
+	aliases, rest, batch, err := rootTargets([]string{"ssh://root@web1.example.com"}, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web1-root"}, aliases)
+	assert.Empty(t, rest)
+	assert.False(t, batch)
+
+	_, _, _, err = rootTargets([]string{"ssh://root@web1.example.com", "rm", "-rf", "/"}, -1)
+	assert.ErrorContains(t, err, "takes no command", "a link opens a login and runs nothing")
+
+	noInput = true
+	t.Cleanup(func() { noInput = false })
+	_, _, _, err = rootTargets([]string{"ssh://deploy@new.example.com:2200"}, -1)
+	assert.ErrorContains(t, err, "deploy@new.example.com:2200 is in no Host block", "a link to an unknown host is not followed unasked")
+	adHocHost = nil
+	aliases, _, _, err = rootTargets([]string{"deploy@new.example.com:2200"}, -1)
+	require.NoError(t, err, "a typed address needs no confirmation")
+	assert.Equal(t, []string{"new.example.com"}, aliases)
+
+	_, _, _, err = rootTargets([]string{"ssh://host/path"}, -1)
+	assert.ErrorContains(t, err, "no path")
+}
+
+func TestConfirmLinkHost(t *testing.T) {
+	var out strings.Builder
+	p := &prompter{in: bufio.NewReader(strings.NewReader("y\n")), out: &out}
+	require.NoError(t, confirmLinkHost(p, sshURL{user: "root", host: "2001:db8::1", port: "22"}))
+	assert.Contains(t, out.String(), "root@[2001:db8::1]:22, which is in no Host block")
+
+	p = &prompter{in: bufio.NewReader(strings.NewReader("\n")), out: &out}
+	err := confirmLinkHost(p, sshURL{host: "new.example.com"})
+	assert.ErrorContains(t, err, "aborted", "the default is no")
+	assert.Equal(t, 1, ExitCode(err))
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	pruneUnreachable bool
+	pruneUnused      string
+	pruneProbes      int
+)
+
+// probeWait is the pause between one round of --unreachable probes and
+// the next, so a host that is briefly down gets a second chance.
+var probeWait = 5 * time.Second
+
+// parseAge reads an age such as --unused takes: a Go duration, or a
+// whole number of days such as 180d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q: want a number of days such as 180d, or a duration such as 72h", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q: want a number of days such as 180d, or a duration such as 72h", s)
+	}
+	return d, nil
+}
+
+// staleHost is a host gt config prune offers to remove, and why.
+type staleHost struct {
+	alias  string
+	reason string
+	// expired hosts go without asking one by one; their time was set.
+	expired bool
+}
+
+// ownHosts are the hosts of the SSH config itself, leaving out those of
+// a team inventory, which are the team's to remove.
+func ownHosts() []string {
+	var own []string
+	for _, alias := range getHosts() {
+		if _, team := teamMeta[alias]; !team {
+			own = append(own, alias)
+		}
+	}
+	return own
+}
+
+// unusedHosts are the aliases with no connection in gt's log since
+// cutoff, a host never connected to included.
+func unusedHosts(aliases []string, last map[string]time.Time, cutoff time.Time) []staleHost {
+	var unused []staleHost
+	for _, alias := range aliases {
+		t, ok := last[alias]
+		switch {
+		case !ok:
+			unused = append(unused, staleHost{alias: alias, reason: "never connected to"})
+		case t.Before(cutoff):
+			unused = append(unused, staleHost{alias: alias, reason: "last connected " + t.Local().Format("2006-01-02")})
+		}
+	}
+	return unused
+}
+
+// unreachable probes a resolved host and its fallback addresses up to
+// probes times, probeWait apart, and says why none of them is reachable;
+// "" as soon as one accepts a TCP connection on the host's port. Hosts
+// reached through a ProxyJump or ProxyCommand are not probed: this
+// machine cannot tell whether the jump host can reach them.
+func unreachable(r listRow, probes int) string {
+	if r.ProxyJump != "" || r.ProxyCommand != "" {
+		return ""
+	}
+	for _, key := range []string{"proxyjump", "proxycommand"} {
+		if v := r.opts[key]; len(v) > 0 && v[0] != "none" {
+			return ""
+		}
+	}
+	port := r.Port
+	if port == "" {
+		port = "22"
+	}
+	meta := hostMetaFor(r.alias)
+	timeout := meta.connectTimeout()
+	var resolving []string
+	for _, addr := range append([]string{r.Hostname}, meta.FallbackAddresses...) {
+		if _, _, err := splitFamilies(addr); err != nil {
+			debugf(1, "probe %s: %v", addr, err)
+			continue
+		}
+		resolving = append(resolving, addr)
+	}
+	if len(resolving) == 0 {
+		return "does not resolve in DNS"
+	}
+	for i := 0; i < probes; i++ {
+		if i > 0 {
+			time.Sleep(probeWait)
+		}
+		for _, addr := range resolving {
+			conn, err := dialTimeout("tcp", net.JoinHostPort(addr, port), timeout)
+			if err != nil {
+				debugf(1, "probe %s port %s: %v", addr, port, err)
+				continue
+			}
+			conn.Close()
+			return ""
+		}
+	}
+	return fmt.Sprintf("no answer on port %s in %d probes", port, probes)
+}
+
+// unreachableHosts resolves and probes aliases, a handful at a time, and
+// returns those unreachable in alias order. Hosts ssh -G cannot resolve
+// are left alone; gt list shows what is wrong with them.
+func unreachableHosts(aliases []string, probes int) []staleHost {
+	rows := resolveListRows(aliases)
+	reasons := make([]string, len(rows))
+	sem := make(chan struct{}, 8)
+	var wg sync.WaitGroup
+	for i, r := range rows {
+		if r.err != nil {
+			debugf(1, "not probing %s: %v", r.alias, r.err)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, r listRow) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			reasons[i] = unreachable(r, probes)
+		}(i, r)
+	}
+	wg.Wait()
+	var stale []staleHost
+	for i, reason := range reasons {
+		if reason != "" {
+			stale = append(stale, staleHost{alias: rows[i].alias, reason: reason})
+		}
+	}
+	return stale
+}
+
+// staleHosts gathers what gt config prune offers to remove: the expired
+// hosts, then with --unused and --unreachable those that qualify, sorted,
+// each once, with the first reason found.
+func staleHosts(now time.Time) ([]staleHost, error) {
+	var stale []staleHost
+	seen := map[string]bool{}
+	add := func(hosts []staleHost) {
+		for _, h := range hosts {
+			if !seen[h.alias] {
+				seen[h.alias] = true
+				stale = append(stale, h)
+			}
+		}
+	}
+	for _, alias := range expiredHosts(now) {
+		add([]staleHost{{alias: alias, reason: "expired", expired: true}})
+	}
+	var rest []string
+	for _, alias := range ownHosts() {
+		if !seen[alias] {
+			rest = append(rest, alias)
+		}
+	}
+	if pruneUnused != "" {
+		age, err := parseAge(pruneUnused)
+		if err != nil {
+			return nil, err
+		}
+		add(unusedHosts(rest, lastConnections(), now.Add(-age)))
+	}
+	if pruneUnreachable {
+		var probe []string
+		for _, alias := range rest {
+			if !seen[alias] {
+				probe = append(probe, alias)
+			}
+		}
+		add(unreachableHosts(probe, pruneProbes))
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].alias < stale[j].alias })
+	return stale, nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/sshconf"
+)
+
+func TestParseAge(t *testing.T) {
+	d, err := parseAge("180d")
+	require.NoError(t, err)
+	assert.Equal(t, 180*24*time.Hour, d)
+	d, err = parseAge("36h")
+	require.NoError(t, err)
+	assert.Equal(t, 36*time.Hour, d)
+	for _, bad := range []string{"", "d", "-3d", "1.5d", "0s", "soon"} {
+		_, err := parseAge(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestUnusedHosts(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.Local)
+	last := map[string]time.Time{
+		"recent": now.AddDate(0, 0, -10),
+		"old":    time.Date(2025, 3, 4, 12, 0, 0, 0, time.Local),
+	}
+	assert.Equal(t, []staleHost{
+		{alias: "never", reason: "never connected to"},
+		{alias: "old", reason: "last connected 2025-03-04"},
+	}, unusedHosts([]string{"never", "old", "recent"}, last, now.AddDate(0, 0, -180)))
+}
+
+func TestUnreachable(t *testing.T) {
+	origLookup, origDial, origWait := lookupIPs, dialTimeout, probeWait
+	t.Cleanup(func() { lookupIPs, dialTimeout, probeWait = origLookup, origDial, origWait })
+	probeWait = 0
+	useWebGroup(t)
+	gtCfg.Hosts["fb"] = hostMeta{FallbackAddresses: []string{"10.0.0.9"}}
+
+	lookupIPs = func(ctx context.Context, host string) ([]net.IP, error) {
+		if host == "gone.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []net.IP{net.ParseIP("192.0.2.1")}, nil
+	}
+	var dials []string
+	up := map[string]bool{}
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		dials = append(dials, addr)
+		if up[addr] {
+			return stubConn{}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+	row := func(alias, hostname string) listRow {
+		return listRow{alias: alias, Resolved: sshconf.Resolved{Hostname: hostname, Port: "2222"}}
+	}
+
+	assert.Equal(t, "does not resolve in DNS", unreachable(row("a", "gone.example.com"), 3))
+	assert.Empty(t, dials)
+
+	assert.Equal(t, "no answer on port 2222 in 3 probes", unreachable(row("a", "down.example.com"), 3))
+	assert.Len(t, dials, 3)
+
+	dials = nil
+	up["10.0.0.9:2222"] = true
+	assert.Equal(t, "", unreachable(row("fb", "gone.example.com"), 3), "a fallback that answers will do")
+	assert.Equal(t, []string{"10.0.0.9:2222"}, dials)
+
+	jumped := row("a", "gone.example.com")
+	jumped.opts = map[string][]string{"proxyjump": {"bastion"}}
+	assert.Equal(t, "", unreachable(jumped, 3), "not probed from here")
+}
+
+func TestConfigPruneUnused(t *testing.T) {
+	useWebGroup(t)
+	plainOutput(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	dir := t.TempDir()
+	gtPath := filepath.Join(dir, "config.yaml")
+	t.Setenv("GT_CONFIG", gtPath)
+	writeConfigFile(t, gtPath, "hosts:\n  down:\n    groups: [web]\n")
+	var err error
+	gtCfg, err = loadGTConfig(gtPath)
+	require.NoError(t, err)
+	main := filepath.Join(dir, "ssh_config")
+	writeConfigFile(t, main, "Host web-1 web-2 down\n  User deploy\n")
+	origFiles, origYes, origUnused := loadedFiles, pruneYes, pruneUnused
+	t.Cleanup(func() { loadedFiles, pruneYes, pruneUnused = origFiles, origYes, origUnused })
+	loadedFiles = []string{main}
+	now := time.Now()
+	require.NoError(t, appendAuditEntry(auditEntry{Start: now.AddDate(0, 0, -1), Alias: "web-1"}))
+	require.NoError(t, appendAuditEntry(auditEntry{Start: now.AddDate(-1, 0, 0), Alias: "web-2"}))
+
+	pruneUnused = "bogus"
+	assert.ErrorContains(t, configPruneCmd.RunE(configPruneCmd, nil), "--unused: invalid age")
+
+	pruneUnused, pruneYes = "180d", true
+	require.NoError(t, configPruneCmd.RunE(configPruneCmd, nil))
+	data, _ := os.ReadFile(main)
+	assert.Equal(t, "Host web-1\n  User deploy\n", string(data))
+	data, _ = os.ReadFile(gtPath)
+	assert.Equal(t, "hosts: {}\n", string(data))
+}
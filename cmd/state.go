@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gt/pkg/sshconf"
+)
+
+// stateVersion is the layout of the state directory this gt reads and
+// writes, recorded in its VERSION file. A directory from before VERSION
+// existed is version 1.
+const stateVersion = 1
+
+// stateMigrations[i] moves a state directory from version i+1 to i+2.
+// A gt finding an older layout runs the ones it is missing; one finding
+// a newer layout refuses to touch it rather than corrupt what a newer gt
+// wrote.
+var stateMigrations []func(dir string) error
+
+// errLocked is tryLockState's error for a lock another gt holds.
+var errLocked = errors.New("locked by another gt")
+
+var (
+	openedMu    sync.Mutex
+	openedState = map[string]bool{}
+)
+
+// privateDir creates dir 0700 if need be and checks that it is a
+// directory of the running user's that no one else can enter, so a
+// socket in it is not reachable, even for the moment before its own mode
+// is set. Off unix, where ACLs guard files, only the creation is done.
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	uid, ok := sshconf.FileOwner(info)
+	switch {
+	case !ok:
+		return nil
+	case !info.IsDir():
+		return fmt.Errorf("%s is not a directory", dir)
+	case uid != uint32(os.Getuid()):
+		return fmt.Errorf("%s belongs to uid %d, not you; refusing to put a socket there", dir, uid)
+	case info.Mode().Perm()&0o077 != 0:
+		return fmt.Errorf("%s is open to other users (mode %#o); refusing to put a socket there, chmod 700 it or pick another directory", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// openState is stateDir, created 0700 and checked, or migrated, to
+// stateVersion, once per directory and process.
+func openState() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	openedMu.Lock()
+	defer openedMu.Unlock()
+	if openedState[dir] {
+		return dir, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	release, err := lockPath(filepath.Join(dir, "VERSION.lock"), true)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	version, recorded, err := readStateVersion(dir)
+	if err != nil {
+		return "", err
+	}
+	if version > stateVersion {
+		return "", fmt.Errorf("%s was written by a newer gt (state version %d, this gt knows %d); upgrade gt", dir, version, stateVersion)
+	}
+	if err := migrateState(dir, version, stateVersion); err != nil {
+		return "", err
+	}
+	if !recorded || version != stateVersion {
+		if err := writeStateFile(filepath.Join(dir, "VERSION"), []byte(strconv.Itoa(stateVersion)+"\n")); err != nil {
+			return "", err
+		}
+	}
+	openedState[dir] = true
+	return dir, nil
+}
+
+// migrateState runs the migrations taking dir from version from to to.
+func migrateState(dir string, from, to int) error {
+	for v := from; v < to; v++ {
+		if err := stateMigrations[v-1](dir); err != nil {
+			return fmt.Errorf("migrating %s to state version %d: %w", dir, v+1, err)
+		}
+	}
+	return nil
+}
+
+// readStateVersion is dir's state version; recorded is false for a
+// directory without VERSION.
+func readStateVersion(dir string) (version int, recorded bool, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, "VERSION"))
+	if errors.Is(err, os.ErrNotExist) {
+		return 1, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || v < 1 {
+		return 0, false, fmt.Errorf("%s: not a state version: %q", filepath.Join(dir, "VERSION"), strings.TrimSpace(string(data)))
+	}
+	return v, true, nil
+}
+
+// statePath is the file name in the state directory.
+func statePath(name string) (string, error) {
+	dir, err := openState()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// lockState takes the lock guarding the state file name, waiting for
+// another gt to let go of it, and returns its release. Locks are the
+// operating system's (flock, LockFileEx), so a gt that dies frees them.
+func lockState(name string) (release func(), err error) {
+	p, err := statePath(name + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	return lockPath(p, true)
+}
+
+// tryLockState is lockState without the wait: errLocked if another gt
+// holds it.
+func tryLockState(name string) (release func(), err error) {
+	p, err := statePath(name + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	return lockPath(p, false)
+}
+
+func lockPath(p string, wait bool) (release func(), err error) {
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, wait); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Closing the file drops the lock. The file stays: removing it would
+	// let a gt waiting on the old one and a newcomer both hold "the" lock.
+	return func() { f.Close() }, nil
+}
+
+// writeStateFile replaces p in one rename, so a reader, or a gt killed
+// halfway, sees the old contents or the new, never half of them.
+func writeStateFile(p string, data []byte) error {
+	return replaceFile(p, data, 0o600)
+}
+
+// replaceFile is writeStateFile for any file, leaving it with mode.
+func replaceFile(p string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenStateRecordsVersion(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	t.Setenv("GT_LOG_DIR", dir)
+
+	p, err := statePath("queue.json")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "queue.json"), p)
+	data, err := os.ReadFile(filepath.Join(dir, "VERSION"))
+	require.NoError(t, err)
+	assert.Equal(t, "1\n", string(data))
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	}
+}
+
+func TestOpenStateRefusesNewerLayout(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GT_LOG_DIR", dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "VERSION"), []byte("7\n"), 0o600))
+	_, err := statePath("connections.jsonl")
+	assert.ErrorContains(t, err, "written by a newer gt (state version 7, this gt knows 1)")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "VERSION"), []byte("x"), 0o600))
+	_, err = statePath("connections.jsonl")
+	assert.ErrorContains(t, err, "not a state version")
+}
+
+func TestMigrateState(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.json"), nil, 0o600))
+	origMigrations := stateMigrations
+	defer func() { stateMigrations = origMigrations }()
+	var ran []int
+	stateMigrations = []func(string) error{
+		func(dir string) error {
+			ran = append(ran, 2)
+			return os.Rename(filepath.Join(dir, "old.json"), filepath.Join(dir, "new.json"))
+		},
+		func(string) error { ran = append(ran, 3); return os.ErrPermission },
+	}
+
+	require.NoError(t, migrateState(dir, 1, 2))
+	assert.FileExists(t, filepath.Join(dir, "new.json"))
+	assert.NoError(t, migrateState(dir, 2, 2), "nothing to do")
+	assert.ErrorContains(t, migrateState(dir, 2, 3), "to state version 3")
+	assert.Equal(t, []int{2, 3}, ran)
+}
+
+func TestTryLockState(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	release, err := tryLockState("queue.run")
+	require.NoError(t, err)
+	_, err = tryLockState("queue.run")
+	assert.ErrorIs(t, err, errLocked)
+
+	release()
+	release, err = tryLockState("queue.run")
+	require.NoError(t, err, "free once released")
+	release()
+}
+
+func TestLockStateSerializesUpdates(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, updateQueue(func(st *queueState) error {
+				st.Jobs = append(st.Jobs, queueJob{ID: st.NextID})
+				st.NextID++
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+	st := loadQueue(t)
+	assert.Len(t, st.Jobs, 20, "no update lost")
+	assert.Equal(t, 21, st.NextID)
+}
+
+func TestWriteStateFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "sync.json")
+	require.NoError(t, os.WriteFile(p, []byte("old"), 0o644))
+	require.NoError(t, writeStateFile(p, []byte("new")))
+	data, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	entries, _ := os.ReadDir(filepath.Dir(p))
+	assert.Len(t, entries, 1, "no temp file left behind")
+	if os.PathSeparator == '/' {
+		info, _ := os.Stat(p)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+}
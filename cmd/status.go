@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var (
+	statusAll      bool
+	statusJSON     bool
+	statusParallel int
+	statusTimeout  time.Duration
+)
+
+// Host key states in gt status: the key offered is one known_hosts has,
+// known_hosts has others for the host and not it, or has none at all.
+const (
+	hostKeyMatch    = "match"
+	hostKeyMismatch = "mismatch"
+	hostKeyUnknown  = "unknown"
+)
+
+// hostStatus is one host's line in gt status, and its entry in the JSON
+// report.
+type hostStatus struct {
+	Alias     string  `json:"alias"`
+	Hostname  string  `json:"hostname,omitempty"`
+	Port      string  `json:"port,omitempty"`
+	Up        bool    `json:"up"`
+	LatencyMS float64 `json:"latency_ms"`
+	// Banner is the identification string the server sent, such as
+	// SSH-2.0-OpenSSH_9.6.
+	Banner string `json:"banner,omitempty"`
+	// HostKey is match, mismatch or unknown; empty when the keys could
+	// not be scanned.
+	HostKey string `json:"host_key,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// statusReport is gt status --json.
+type statusReport struct {
+	Time  time.Time    `json:"time"`
+	Hosts []hostStatus `json:"hosts"`
+}
+
+// readBanner reads the server's identification string, which comes
+// first on an SSH connection, after any lines the server sends before it.
+func readBanner(conn net.Conn, timeout time.Duration) string {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return ""
+	}
+	r := bufio.NewReader(io.LimitReader(conn, 8192))
+	for {
+		line, err := r.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); strings.HasPrefix(line, "SSH-") {
+			return line
+		}
+		if err != nil {
+			return ""
+		}
+	}
+}
+
+// knownHostsFiles are the known_hosts files ssh -G resolved for a host,
+// OpenSSH's defaults when it names none. "none" turns a list off.
+func knownHostsFiles(opts map[string][]string) []string {
+	var files []string
+	for _, key := range []string{"userknownhostsfile", "globalknownhostsfile"} {
+		for _, v := range opts[key] {
+			for _, f := range strings.Fields(v) {
+				if f != "none" {
+					files = append(files, sshconf.ExpandTilde(f))
+				}
+			}
+		}
+	}
+	if len(opts["userknownhostsfile"]) == 0 {
+		files = append(files, sshconf.ExpandTilde("~/.ssh/known_hosts"))
+	}
+	return files
+}
+
+// knownHostKeys lists the keys, as "type key", that known_hosts files
+// have for name. ssh-keygen -F does the matching, so hashed entries count.
+func knownHostKeys(name string, files []string) []string {
+	var keys []string
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		out, err := execCommand("ssh-keygen", "-F", name, "-f", file).Output()
+		if err != nil {
+			continue // exit 1: not in this file
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			f := strings.Fields(line)
+			if len(f) < 3 || strings.HasPrefix(f[0], "#") || strings.HasPrefix(f[0], "@") {
+				continue
+			}
+			keys = append(keys, f[1]+" "+f[2])
+		}
+	}
+	return keys
+}
+
+// compareHostKeys judges the keys a host offers against those known_hosts
+// has for it.
+func compareHostKeys(offered, known []string) string {
+	switch {
+	case len(offered) == 0:
+		return ""
+	case len(known) == 0:
+		return hostKeyUnknown
+	}
+	for _, k := range offered {
+		for _, kk := range known {
+			if k == kk {
+				return hostKeyMatch
+			}
+		}
+	}
+	return hostKeyMismatch
+}
+
+// checkStatus probes one host as gt serve --metrics does, with a timed
+// TCP connect, and then reads its banner and checks the keys it offers
+// against known_hosts. timeout 0 takes the host's connect timeout.
+func checkStatus(alias string, timeout time.Duration) hostStatus {
+	s := hostStatus{Alias: alias}
+	r, opts, err := resolveHostOptions(alias)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	s.Hostname, s.Port = r.Hostname, r.Port
+	if s.Port == "" {
+		s.Port = "22"
+	}
+	if timeout == 0 {
+		timeout = hostMetaFor(alias).connectTimeout()
+	}
+	start := time.Now()
+	conn, err := dialTimeout("tcp", net.JoinHostPort(s.Hostname, s.Port), timeout)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	s.Up = true
+	s.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	s.Banner = readBanner(conn, timeout)
+	conn.Close()
+	if usePuTTY() {
+		return s // PuTTY keeps its host keys in the registry
+	}
+	name := s.Hostname
+	if a := opts["hostkeyalias"]; len(a) > 0 && a[0] != "" {
+		name = a[0]
+	}
+	if s.Port != "22" {
+		name = "[" + name + "]:" + s.Port
+	}
+	s.HostKey = compareHostKeys(scanKeys(s.Hostname, s.Port, timeout), knownHostKeys(name, knownHostsFiles(opts)))
+	return s
+}
+
+// renderStatus writes the hosts as a table.
+func renderStatus(w io.Writer, hosts []hostStatus) {
+	hostWidth, bannerWidth := len("HOST"), len("BANNER")
+	for _, h := range hosts {
+		if n := displayWidth(h.Alias); n > hostWidth {
+			hostWidth = n
+		}
+		if n := displayWidth(h.Banner); n > bannerWidth {
+			bannerWidth = n
+		}
+	}
+	symbolColor.Fprintf(w, "%-*s  %-4s  %8s  %-*s  %s\n", hostWidth, "HOST", "UP", "LATENCY", bannerWidth, "BANNER", "HOST KEY")
+	for _, h := range hosts {
+		aliasColor.Fprint(w, h.Alias+strings.Repeat(" ", hostWidth-displayWidth(h.Alias)+2))
+		if !h.Up {
+			errorColor.Fprintf(w, "%-4s  ", "no")
+			fmt.Fprintln(w, firstLine(h.Error))
+			continue
+		}
+		userColor.Fprintf(w, "%-4s  ", "yes")
+		fmt.Fprintf(w, "%6.1fms  %-*s  ", h.LatencyMS, bannerWidth, h.Banner)
+		switch h.HostKey {
+		case hostKeyMatch:
+			userColor.Fprintln(w, h.HostKey)
+		case hostKeyMismatch:
+			errorColor.Fprintln(w, h.HostKey)
+		case "":
+			fmt.Fprintln(w, "-")
+		default:
+			warningColor.Fprintln(w, h.HostKey)
+		}
+	}
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status [alias|@group...]",
+	Short: "Probe hosts for reachability, latency, SSH version and host key",
+	Long: `Probe each host directly from this machine, without logging in: a
+timed TCP connect to its resolved HostName and port, the SSH banner it
+sends, and whether the keys it offers (via ssh-keyscan) are the ones
+known_hosts has for it: match, mismatch, or unknown when known_hosts has
+none. --all probes every host, --parallel so many at a time, each for at
+most --timeout (default: the host's connect_timeout).
+
+--json prints one report for scripts: the time and, per host, alias,
+hostname, port, up, latency_ms, banner, host_key and any error. gt exits
+1 if any host is down or offers a key that does not match.`,
+	Example: `  gt status --all --json > /var/lib/monitoring/ssh.json
+  gt status @web --timeout 2s`,
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statusAll {
+			if len(args) > 0 {
+				return errors.New("--all takes no hosts")
+			}
+			args = []string{"@" + allGroup}
+		}
+		if len(args) == 0 {
+			return errors.New("name hosts to probe, or pass --all")
+		}
+		if statusTimeout < 0 {
+			return errors.New("--timeout must not be negative")
+		}
+		aliases, err := expandTargets(args)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		report := statusReport{Time: time.Now().UTC(), Hosts: make([]hostStatus, len(aliases))}
+		index := make(map[string]int, len(aliases))
+		for i, alias := range aliases {
+			index[alias] = i
+		}
+		results := fanOut(aliases, statusParallel, func(alias string) error {
+			s := checkStatus(alias, statusTimeout)
+			report.Hosts[index[alias]] = s
+			switch {
+			case !s.Up:
+				return errors.New(s.Error)
+			case s.HostKey == hostKeyMismatch:
+				return errors.New("host key mismatch")
+			}
+			return nil
+		}, nil)
+
+		var out bytes.Buffer
+		if statusJSON {
+			enc := json.NewEncoder(&out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			renderStatus(&out, report.Hosts)
+		}
+		if _, err := cmd.OutOrStdout().Write(out.Bytes()); err != nil {
+			return err
+		}
+		return failedHosts(results, 1)
+	},
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bannerConn is a connection whose server has sent banner.
+func bannerConn(t *testing.T, banner string) net.Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	go func() {
+		server.Write([]byte(banner))
+		server.Close()
+	}()
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestReadBanner(t *testing.T) {
+	assert.Equal(t, "SSH-2.0-OpenSSH_9.6", readBanner(bannerConn(t, "SSH-2.0-OpenSSH_9.6\r\n"), time.Second))
+	assert.Equal(t, "SSH-2.0-dropbear", readBanner(bannerConn(t, "Welcome\r\nSSH-2.0-dropbear\r\n"), time.Second), "lines ahead of the banner are skipped")
+	assert.Equal(t, "", readBanner(bannerConn(t, "HTTP/1.1 400 Bad Request\r\n"), time.Second))
+}
+
+func TestCompareHostKeys(t *testing.T) {
+	offered := []string{"ssh-ed25519 AAAA", "ssh-rsa BBBB"}
+	assert.Equal(t, hostKeyMatch, compareHostKeys(offered, []string{"ssh-rsa BBBB"}))
+	assert.Equal(t, hostKeyMismatch, compareHostKeys(offered, []string{"ssh-ed25519 CCCC"}))
+	assert.Equal(t, hostKeyUnknown, compareHostKeys(offered, nil))
+	assert.Equal(t, "", compareHostKeys(nil, []string{"ssh-rsa BBBB"}))
+}
+
+func TestKnownHostsFiles(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(home, ".ssh", "known_hosts")}, knownHostsFiles(nil))
+	assert.Equal(t, []string{"/etc/a", "/etc/b", "/etc/ssh/ssh_known_hosts"}, knownHostsFiles(map[string][]string{
+		"userknownhostsfile":   {"/etc/a /etc/b"},
+		"globalknownhostsfile": {"/etc/ssh/ssh_known_hosts"},
+	}))
+	assert.Empty(t, knownHostsFiles(map[string][]string{"userknownhostsfile": {"none"}}))
+}
+
+func TestCheckStatus(t *testing.T) {
+	useMockExec(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".ssh"), 0o700))
+	origDial := dialTimeout
+	t.Cleanup(func() { dialTimeout = origDial })
+
+	var dialed string
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		dialed = addr
+		return bannerConn(t, "SSH-2.0-OpenSSH_9.6\r\n"), nil
+	}
+	s := checkStatus("test", time.Second)
+	assert.Equal(t, "test.example.com:2222", dialed)
+	assert.True(t, s.Up)
+	assert.Equal(t, "SSH-2.0-OpenSSH_9.6", s.Banner)
+	assert.Equal(t, hostKeyUnknown, s.HostKey, "no known_hosts yet")
+
+	known := filepath.Join(home, ".ssh", "known_hosts")
+	writeConfigFile(t, known, "[test.example.com]:2222 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5\n")
+	assert.Equal(t, hostKeyMatch, checkStatus("test", time.Second).HostKey)
+	writeConfigFile(t, known, "[test.example.com]:2222 ssh-ed25519 AAAAC3Nzaother\n")
+	assert.Equal(t, hostKeyMismatch, checkStatus("test", time.Second).HostKey)
+
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	mockCmd.reset()
+	s = checkStatus("test", time.Second)
+	assert.False(t, s.Up)
+	assert.Equal(t, "connection refused", s.Error)
+	assert.NotContains(t, mockCmd.commands, "ssh-keyscan", "no key scan when the port is closed")
+}
+
+func TestStatusJSON(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("HOME", t.TempDir())
+	useWebGroup(t)
+	origDial, origJSON := dialTimeout, statusJSON
+	t.Cleanup(func() { dialTimeout, statusJSON = origDial, origJSON })
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		return bannerConn(t, "SSH-2.0-OpenSSH_9.6\r\n"), nil
+	}
+	statusJSON = true
+
+	var out bytes.Buffer
+	statusCmd.SetOut(&out)
+	t.Cleanup(func() { statusCmd.SetOut(nil) })
+	require.NoError(t, statusCmd.RunE(statusCmd, []string{"web-1", "web-2"}))
+
+	var report statusReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	require.Len(t, report.Hosts, 2)
+	assert.False(t, report.Time.IsZero())
+	assert.Equal(t, []string{"web-1", "web-2"}, []string{report.Hosts[0].Alias, report.Hosts[1].Alias})
+	h := report.Hosts[0]
+	assert.True(t, h.Up)
+	assert.Equal(t, "test.example.com", h.Hostname)
+	assert.Equal(t, "2222", h.Port)
+	assert.Equal(t, "SSH-2.0-OpenSSH_9.6", h.Banner)
+	assert.Equal(t, hostKeyUnknown, h.HostKey)
+
+	dialTimeout = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	out.Reset()
+	err := statusCmd.RunE(statusCmd, []string{"web-1"})
+	assert.Equal(t, 1, ExitCode(err), "a host that is down fails the run")
+	report = statusReport{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, []hostStatus{{Alias: "web-1", Hostname: "test.example.com", Port: "2222", Error: "connection refused"}}, report.Hosts)
+}
+
+func TestRenderStatus(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	renderStatus(&out, []hostStatus{
+		{Alias: "web-1", Up: true, LatencyMS: 12.34, Banner: "SSH-2.0-OpenSSH_9.6", HostKey: hostKeyMatch},
+		{Alias: "web-2", Up: true, LatencyMS: 3, Banner: "SSH-2.0-OpenSSH_8.9", HostKey: hostKeyMismatch},
+		{Alias: "down", Error: "connection refused"},
+	})
+	lines := strings.Split(out.String(), "\n")
+	assert.Regexp(t, `^HOST\s+UP\s+LATENCY\s+BANNER\s+HOST KEY$`, lines[0])
+	assert.Regexp(t, `^web-1\s+yes\s+12\.3ms\s+SSH-2\.0-OpenSSH_9\.6\s+match$`, lines[1])
+	assert.Regexp(t, `^web-2\s+yes\s+3\.0ms\s+SSH-2\.0-OpenSSH_8\.9\s+mismatch$`, lines[2])
+	assert.Regexp(t, `^down\s+no\s+connection refused$`, lines[3])
+}
+
+func TestStatusRejectsBadArgs(t *testing.T) {
+	origAll := statusAll
+	t.Cleanup(func() { statusAll = origAll })
+	assert.ErrorContains(t, statusCmd.RunE(statusCmd, nil), "pass --all")
+	statusAll = true
+	assert.ErrorContains(t, statusCmd.RunE(statusCmd, []string{"web-1"}), "--all takes no hosts")
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/transport"
+)
+
+var (
+	svcParallel    int
+	svcRolling     int
+	svcMaxFailures int
+)
+
+// svcActions are the systemctl verbs gt svc runs. Only status works
+// without root.
+var svcActions = []string{"status", "start", "stop", "restart", "reload"}
+
+// sudoPrefix is the start of a sh script whose commands need root: it
+// sets $sudo to nothing when the login is root already, and to sudo
+// otherwise. Batch runs use sudo -n, failing instead of waiting for a
+// password no one can type.
+func sudoPrefix(batch bool) string {
+	sudo := "sudo"
+	if batch {
+		sudo = "sudo -n"
+	}
+	return fmt.Sprintf(`if [ "$(id -u)" -eq 0 ]; then sudo=; else sudo='%s'; fi; `, sudo)
+}
+
+// svcCommand is the remote command for action on unit. Anything but
+// status needs root.
+func svcCommand(action, unit string, batch bool) string {
+	systemctl := quoteArgv([]string{"systemctl", "--no-pager", action, "--", unit})
+	if action == "status" {
+		return systemctl
+	}
+	return shellScript(sudoPrefix(batch) + "$sudo " + systemctl)
+}
+
+// runWithTerminal runs remoteCmd on alias with a terminal, so sudo can
+// ask for a password and systemctl colors its output. Other backends run
+// it as gt <alias> <command> would.
+func runWithTerminal(alias string, remoteCmd []string) error {
+	if pluginTransport() != nil || usePuTTY() {
+		return runSSH(alias, remoteCmd)
+	}
+	opts := passwordOptions(hostOptions(baseOptions(), alias), alias)
+	opts.Verbosity = verbosity
+	opts = motdOptions(opts, alias)
+	opts.Extra = []string{"-t"}
+	return runCommandLogged(withAskpass(sshCommand(transport.SSHArgs(opts, alias, remoteCmd)...), alias), alias, "ssh")
+}
+
+var svcCmd = &cobra.Command{
+	Use:   "svc <alias|@group> <" + strings.Join(svcActions, "|") + "> <unit>",
+	Short: "Show, start, stop, restart or reload a systemd service",
+	Long: `Run systemctl on a host or a group, with sudo where needed:
+
+  gt svc web1 status nginx
+  gt svc web1 restart nginx
+  gt svc @web restart nginx --rolling 2
+
+On a single host the command gets a terminal, so sudo can ask for a
+password. A group runs like gt exec: in BatchMode with sudo -n (so a host
+that would prompt fails rather than stalls), output led by each host's
+alias, then a table of results. --rolling works through the group N
+hosts at a time, and --max-failures stops starting more once more than M
+have failed. gt exits 1 if any host failed.`,
+	Args: cobra.ExactArgs(3),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeTargets(cmd, args, toComplete)
+		case 1:
+			return svcActions, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, action, unit := args[0], args[1], args[2]
+		known := false
+		for _, a := range svcActions {
+			known = known || a == action
+		}
+		if !known {
+			return fmt.Errorf("unknown action %q: want one of %s", action, strings.Join(svcActions, ", "))
+		}
+		if err := transport.ValidateNoFlagPrefix("unit", unit); err != nil {
+			return err
+		}
+		aliases, err := expandTarget(target)
+		if err != nil {
+			return err
+		}
+		if action != "status" {
+			if err := refuseUntrusted(aliases, action+" "+unit); err != nil {
+				return err
+			}
+		}
+		if svcRolling < 0 {
+			return errors.New("--rolling must not be negative")
+		}
+		r := rollout{parallel: svcParallel, rolling: svcRolling, maxFailures: -1}
+		if cmd.Flags().Changed("max-failures") {
+			if svcMaxFailures < 0 {
+				return errors.New("--max-failures must not be negative")
+			}
+			r.maxFailures = svcMaxFailures
+		}
+		cmd.SilenceUsage = true
+
+		if !isGroupTarget(target) {
+			remoteCmd := []string{svcCommand(action, unit, nonInteractive())}
+			return withHooks(hookPreConnect, hookPostConnect, hookEvent{Alias: target, Command: remoteCmd}, func() error {
+				if nonInteractive() {
+					return runSSH(target, remoteCmd)
+				}
+				return runWithTerminal(target, remoteCmd)
+			})
+		}
+
+		statusf(symbolColor, "%s %s on %d host(s)\n", action, unit, len(aliases))
+		results, stopped := r.run(aliases, func(alias string) error {
+			return execOne(alias, []string{svcCommand(action, unit, true)}, "")
+		}, reportProgress(len(aliases)))
+		renderResults(cmd.OutOrStdout(), results)
+		if stopped != nil {
+			return withCode(1, stopped)
+		}
+		return failedHosts(results, 1)
+	},
+}
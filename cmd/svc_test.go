@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSvcCommand(t *testing.T) {
+	assert.Equal(t, "systemctl --no-pager status -- nginx", svcCommand("status", "nginx", true), "status needs no root")
+
+	restart := svcCommand("restart", "nginx", false)
+	assert.Contains(t, restart, `$sudo systemctl --no-pager restart -- nginx`)
+	assert.Contains(t, restart, `id -u`, "root needs no sudo")
+	assert.Contains(t, svcCommand("restart", "nginx", true), `sudo='\''sudo -n'\''`, "a batch run must not wait on a password")
+}
+
+func TestSvcRejectsBadArgs(t *testing.T) {
+	useWebGroup(t)
+	assert.ErrorContains(t, svcCmd.RunE(svcCmd, []string{"web-1", "enable", "nginx"}), "unknown action")
+	assert.Error(t, svcCmd.RunE(svcCmd, []string{"web-1", "restart", "--now"}))
+}
+
+func TestSvcSingleHostGetsTerminal(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useWebGroup(t)
+
+	assert.NoError(t, svcCmd.RunE(svcCmd, []string{"web-1", "restart", "nginx"}))
+	args := mockRun("ssh")
+	assert.Contains(t, args, "-t", "sudo may ask for a password")
+	assert.Contains(t, args[len(args)-1], "$sudo systemctl --no-pager restart -- nginx")
+}
+
+func TestSvcGroupRolling(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+	svcRolling = 1
+	t.Cleanup(func() { svcRolling = 0 })
+
+	var out bytes.Buffer
+	svcCmd.SetOut(&out)
+	t.Cleanup(func() { svcCmd.SetOut(nil) })
+	err := svcCmd.RunE(svcCmd, []string{"@web", "restart", "nginx"})
+	assert.Equal(t, 1, ExitCode(err), "down fails")
+
+	ran := 0
+	for i, c := range mockCmd.commands {
+		args := mockCmd.argLists[i]
+		if c == "ssh" && !contains(args, "-G") {
+			ran++
+			assert.Contains(t, args, "BatchMode=yes")
+			assert.Contains(t, args[len(args)-1], "sudo -n")
+		}
+	}
+	assert.Equal(t, 3, ran)
+	assert.Contains(t, out.String(), "2 ok, 1 failed")
+}
@@ -0,0 +1,671 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+var (
+	syncRemote string
+	syncForce  bool
+)
+
+// syncState records, per synced file, the content hash both sides agreed
+// on at the last successful push or pull. A side whose hash has moved
+// away from it has changed since; if both have, that is a conflict.
+type syncState struct {
+	Remote string            `json:"remote"`
+	Files  map[string]string `json:"files"` // home-relative slash path -> sha256
+}
+
+// syncBackend moves a snapshot directory to and from a remote. Snapshots
+// lay files out by their path relative to the home directory, so the
+// same tree restores onto a machine with a different home.
+type syncBackend interface {
+	// fetch populates dir with the current remote snapshot. An empty or
+	// not yet created remote leaves dir empty.
+	fetch(dir string) error
+	// publish makes dir the new remote snapshot.
+	publish(dir, message string) error
+}
+
+// newSyncBackend picks a backend from the remote's form: s3:// goes
+// through the aws CLI, http(s):// is WebDAV, and anything else is handed
+// to git, which understands its own URL and scp-style forms.
+func newSyncBackend(remote string) (syncBackend, error) {
+	switch {
+	case strings.HasPrefix(remote, "s3://"):
+		return s3Backend{url: strings.TrimSuffix(remote, "/")}, nil
+	case strings.HasPrefix(remote, "http://"), strings.HasPrefix(remote, "https://"):
+		password, err := syncPassword()
+		if err != nil {
+			return nil, err
+		}
+		return webdavBackend{url: strings.TrimSuffix(remote, "/"), password: password}, nil
+	default:
+		return gitBackend{url: remote}, nil
+	}
+}
+
+// redactRemote is remote without the userinfo of a URL, for sync.json
+// and messages: a WebDAV password in --remote or GT_SYNC_REMOTE stays in
+// the request it authenticates.
+func redactRemote(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil {
+		return remote
+	}
+	u.User = nil
+	return u.String()
+}
+
+// syncPassword is the WebDAV password from GT_SYNC_PASSWORD, or else
+// what gt config's sync_password_command prints; "" leaves it to the
+// remote URL.
+func syncPassword() (string, error) {
+	if pw := os.Getenv("GT_SYNC_PASSWORD"); pw != "" {
+		return pw, nil
+	}
+	if gtCfg.SyncPasswordCommand == "" {
+		return "", nil
+	}
+	pw, err := fetchPassword(gtCfg.SyncPasswordCommand, os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("sync_password_command: %w", err)
+	}
+	return pw, nil
+}
+
+type gitBackend struct{ url string }
+
+func (b gitBackend) fetch(dir string) error {
+	// Cloning an empty repository succeeds with a warning, which is
+	// exactly the "no snapshot yet" case.
+	return runQuiet(batchEnv(execCommand("git", "clone", "--quiet", "--depth", "1", "--", b.url, dir)))
+}
+
+func (b gitBackend) publish(dir, message string) error {
+	if err := runQuiet(execCommand("git", "-C", dir, "add", "-A")); err != nil {
+		return err
+	}
+	// diff --cached --quiet exits 1 when something is staged.
+	if execCommand("git", "-C", dir, "diff", "--cached", "--quiet").Run() == nil {
+		return nil
+	}
+	if err := runQuiet(execCommand("git", "-C", dir, "commit", "--quiet", "-m", message)); err != nil {
+		return err
+	}
+	// A concurrent push from another machine makes this non-fast-forward,
+	// which git rejects — the remote-side half of conflict detection.
+	return runQuiet(batchEnv(execCommand("git", "-C", dir, "push", "--quiet", "origin", "HEAD")))
+}
+
+type s3Backend struct{ url string }
+
+func (b s3Backend) fetch(dir string) error {
+	return runQuiet(execCommand("aws", "s3", "sync", "--only-show-errors", b.url, dir))
+}
+
+func (b s3Backend) publish(dir, _ string) error {
+	return runQuiet(execCommand("aws", "s3", "sync", "--only-show-errors", "--delete", dir, b.url))
+}
+
+// webdavBackend stores files under the collection URL plus a manifest
+// listing them, since WebDAV has no cheap recursive listing. The user
+// comes from the URL's userinfo, and the password from syncPassword or,
+// failing that, the URL.
+type webdavBackend struct{ url, password string }
+
+const webdavManifest = "gt-sync-manifest.json"
+
+func (b webdavBackend) request(method, rel string, body []byte) (*http.Response, error) {
+	u, err := url.Parse(b.url)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, rel)
+	var password string
+	var username string
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+		u.User = nil
+	}
+	if b.password != "" {
+		password = b.password
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func (b webdavBackend) get(rel string) ([]byte, bool, error) {
+	resp, err := b.request(http.MethodGet, rel, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, false, fmt.Errorf("GET %s: %s", rel, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, true, err
+}
+
+func (b webdavBackend) put(rel string, data []byte) error {
+	resp, err := b.request(http.MethodPut, rel, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PUT %s: %s", rel, resp.Status)
+	}
+	return nil
+}
+
+func (b webdavBackend) fetch(dir string) error {
+	data, ok, err := b.get(webdavManifest)
+	if err != nil || !ok {
+		return err
+	}
+	var files []string
+	if err := json.Unmarshal(data, &files); err != nil {
+		return fmt.Errorf("%s: %w", webdavManifest, err)
+	}
+	for _, rel := range files {
+		// The manifest comes from the server; never let it name a file
+		// outside the snapshot (and so, on pull, outside ~).
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return fmt.Errorf("%s: refusing non-local path %q", webdavManifest, rel)
+		}
+		data, ok, err := b.get(rel)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := writeSyncedFile(filepath.Join(dir, filepath.FromSlash(rel)), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b webdavBackend) publish(dir, _ string) error {
+	files, err := snapshotFiles(dir)
+	if err != nil {
+		return err
+	}
+	made := map[string]bool{}
+	for _, rel := range files {
+		// MKCOL each missing parent collection; servers answer 405 for
+		// one that already exists, which is fine to ignore.
+		for parent := path.Dir(rel); parent != "." && !made[parent]; parent = path.Dir(parent) {
+			made[parent] = true
+			if resp, err := b.request("MKCOL", parent, nil); err == nil {
+				resp.Body.Close()
+			}
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		if err := b.put(rel, data); err != nil {
+			return err
+		}
+	}
+	manifest, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	return b.put(webdavManifest, manifest)
+}
+
+// runQuiet runs a helper tool with its output suppressed unless it fails.
+func runQuiet(c *exec.Cmd) error {
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+	err := runTracked(c, false)
+	if err != nil {
+		return fmt.Errorf("%s: %w\n%s", c.String(), err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// snapshotFiles lists the home-relative slash paths in a snapshot,
+// ignoring backend bookkeeping. Whatever backend fetched it, anything in
+// it but a regular file under dir — a symlink git clone restored, say —
+// is refused, since pull would write through it.
+func snapshotFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("refusing non-local path %q in the snapshot", rel)
+		}
+		rel = filepath.ToSlash(rel)
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("refusing %s in the snapshot: not a regular file", rel)
+		}
+		if rel != webdavManifest {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// localSyncFiles maps the config files gt loaded, plus gt's own config
+// if there is one, to home-relative slash paths. Files outside the home
+// directory are machine-specific by definition (e.g. /etc) and are left
+// alone.
+func localSyncFiles(home string) map[string]string {
+	files := append([]string(nil), loadedFiles...)
+	if p, err := gtConfigPath(); err == nil {
+		if _, err := os.Stat(p); err == nil {
+			files = append(files, p)
+		}
+	}
+	if p, err := shortcutsPath(); err == nil {
+		if _, err := os.Stat(p); err == nil {
+			files = append(files, p)
+		}
+	}
+	out := map[string]string{}
+	for _, p := range files {
+		rel, err := filepath.Rel(home, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			warningColor.Fprintf(os.Stderr, "Not syncing %s: outside the home directory\n", p)
+			continue
+		}
+		out[filepath.ToSlash(rel)] = p
+	}
+	return out
+}
+
+func hashFile(p string) (string, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// syncConflicts returns the files changed on both sides since the last
+// sync: the source differs from the target, and the target has moved
+// away from the recorded base, so overwriting it would lose an edit.
+// Files the target does not have yet never conflict.
+func syncConflicts(source, target, base map[string]string) []string {
+	var conflicts []string
+	for rel, src := range source {
+		dst, ok := target[rel]
+		if !ok || dst == "" || dst == src {
+			continue
+		}
+		if dst != base[rel] {
+			conflicts = append(conflicts, rel)
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+func syncStatePath() (string, error) {
+	return statePath("sync.json")
+}
+
+func readSyncState(remote string) (syncState, error) {
+	st := syncState{Remote: remote, Files: map[string]string{}}
+	p, err := syncStatePath()
+	if err != nil {
+		return st, err
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	var saved syncState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return st, fmt.Errorf("%s: %w", p, err)
+	}
+	// A base recorded against another remote says nothing about this one.
+	// One saved with its URL's password, before gt left it out, is still
+	// this remote's.
+	if redactRemote(saved.Remote) == remote && saved.Files != nil {
+		st.Files = saved.Files
+	}
+	return st, nil
+}
+
+func writeSyncState(st syncState) error {
+	p, err := syncStatePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStateFile(p, append(data, '\n'))
+}
+
+// writeSyncedFile writes a config file with the permissions
+// sshconf.ValidateOpen demands, creating parents like ~/.ssh 0700.
+func writeSyncedFile(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o600)
+}
+
+func conflictError(action string, conflicts []string) error {
+	return fmt.Errorf("%s would overwrite changes made since the last sync: %s (rerun with --force to overwrite)",
+		action, strings.Join(conflicts, ", "))
+}
+
+func resolveSyncRemote() (string, error) {
+	if syncRemote != "" {
+		return syncRemote, nil
+	}
+	if r := os.Getenv("GT_SYNC_REMOTE"); r != "" {
+		return r, nil
+	}
+	return "", fmt.Errorf("no sync remote: pass --remote or set GT_SYNC_REMOTE")
+}
+
+// syncSession is one push or pull: the remote snapshot fetched into a
+// scratch directory, plus the base state recorded by the previous sync.
+type syncSession struct {
+	remote  string
+	backend syncBackend
+	dir     string
+	home    string
+	state   syncState
+}
+
+// openSync fetches the remote snapshot. The caller must run cleanup. The
+// sync state stays locked until then, so two syncs cannot both record a
+// base.
+func openSync() (s *syncSession, cleanup func(), err error) {
+	remote, err := resolveSyncRemote()
+	if err != nil {
+		return nil, nil, err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	release, err := tryLockState("sync.json")
+	if errors.Is(err, errLocked) {
+		return nil, nil, errors.New("another gt sync-config is running")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := readSyncState(redactRemote(remote))
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	backend, err := newSyncBackend(remote)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	scratch, err := os.MkdirTemp("", "gt-sync-")
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	cleanup = func() { os.RemoveAll(scratch); release() }
+	s = &syncSession{
+		remote:  redactRemote(remote),
+		backend: backend,
+		dir:     filepath.Join(scratch, "snapshot"),
+		home:    home,
+		state:   st,
+	}
+	if err := os.Mkdir(s.dir, 0o700); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := s.backend.fetch(s.dir); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("fetching %s: %w", s.remote, err)
+	}
+	return s, cleanup, nil
+}
+
+// snapshotPath maps a home-relative slash path into the snapshot.
+func (s *syncSession) snapshotPath(rel string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(rel))
+}
+
+// homeRel is p as a home-relative slash path; ok is false for a path
+// outside the home directory.
+func homeRel(home, p string) (rel string, ok bool) {
+	rel, err := filepath.Rel(home, p)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// pullableFiles is the part of files, the snapshot's, that pull may
+// write: what localSyncFiles could have pushed. That is the SSH config,
+// the files Include lines in the snapshot's copies of it name, and gt's
+// config and aliases. Anything else a remote holds, a .bashrc or an
+// authorized_keys, is skipped with a warning.
+func (s *syncSession) pullableFiles(files []string) []string {
+	allowed := map[string]bool{}
+	var configs []string
+	if p, err := sshConfigPath(); err == nil {
+		if rel, ok := homeRel(s.home, p); ok {
+			configs = append(configs, rel)
+		}
+	}
+	for _, p := range loadedFiles {
+		if rel, ok := homeRel(s.home, p); ok {
+			configs = append(configs, rel)
+		}
+	}
+	for _, path := range []func() (string, error){gtConfigPath, shortcutsPath} {
+		if p, err := path(); err == nil {
+			if rel, ok := homeRel(s.home, p); ok {
+				allowed[rel] = true
+			}
+		}
+	}
+	// Follow Include lines as ssh would, but through the snapshot, so an
+	// include new to this machine comes along with the config naming it.
+	for len(configs) > 0 {
+		rel := configs[0]
+		configs = configs[1:]
+		if allowed[rel] {
+			continue
+		}
+		allowed[rel] = true
+		data, err := os.ReadFile(s.snapshotPath(rel))
+		if err != nil {
+			continue
+		}
+		var patterns []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if sshconf.Keyword(line) == "include" {
+				patterns = append(patterns, includePatterns(line)...)
+			}
+		}
+		for _, f := range files {
+			if !allowed[f] && includedBy(patterns, filepath.Join(s.home, filepath.FromSlash(f))) {
+				configs = append(configs, f)
+			}
+		}
+	}
+	var out []string
+	for _, f := range files {
+		if allowed[f] {
+			out = append(out, f)
+			continue
+		}
+		warningColor.Fprintf(os.Stderr, "Not pulling %s: not part of the SSH or gt config\n", f)
+	}
+	return out
+}
+
+var syncConfigCmd = &cobra.Command{
+	Use:   "sync-config",
+	Short: "Sync the SSH config and its includes across machines",
+	Long: `Sync ~/.ssh/config, every file it includes, and gt's own config through
+a remote: a git repository, an s3:// prefix (via the aws CLI), or an
+http(s):// WebDAV collection. Files are stored by their path relative to your
+home directory. gt remembers what both sides looked like after the last sync
+and refuses to overwrite a file that changed on both since, unless --force.`,
+}
+
+var syncPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Upload the local config to the sync remote",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, cleanup, err := openSync()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		local := localSyncFiles(s.home)
+		localHashes := map[string]string{}
+		remoteHashes := map[string]string{}
+		for rel, p := range local {
+			if localHashes[rel], err = hashFile(p); err != nil {
+				return err
+			}
+			if remoteHashes[rel], err = hashFile(s.snapshotPath(rel)); err != nil {
+				return err
+			}
+		}
+		if conflicts := syncConflicts(localHashes, remoteHashes, s.state.Files); len(conflicts) > 0 && !syncForce {
+			return conflictError("push", conflicts)
+		}
+
+		for rel, p := range local {
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if err := writeSyncedFile(s.snapshotPath(rel), data); err != nil {
+				return err
+			}
+		}
+		hostname, _ := os.Hostname()
+		if err := s.backend.publish(s.dir, "gt sync-config push from "+hostname); err != nil {
+			return fmt.Errorf("publishing to %s: %w", s.remote, err)
+		}
+		s.state.Files = localHashes
+		if err := writeSyncState(s.state); err != nil {
+			return err
+		}
+		statusf(userColor, "Pushed %d file(s) to %s\n", len(local), s.remote)
+		return nil
+	},
+}
+
+var syncPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Download the config from the sync remote",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, cleanup, err := openSync()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		files, err := snapshotFiles(s.dir)
+		if err != nil {
+			return err
+		}
+		files = s.pullableFiles(files)
+		remoteHashes := map[string]string{}
+		localHashes := map[string]string{}
+		for _, rel := range files {
+			if remoteHashes[rel], err = hashFile(s.snapshotPath(rel)); err != nil {
+				return err
+			}
+			if localHashes[rel], err = hashFile(filepath.Join(s.home, filepath.FromSlash(rel))); err != nil {
+				return err
+			}
+		}
+		if conflicts := syncConflicts(remoteHashes, localHashes, s.state.Files); len(conflicts) > 0 && !syncForce {
+			return conflictError("pull", conflicts)
+		}
+
+		for _, rel := range files {
+			data, err := os.ReadFile(s.snapshotPath(rel))
+			if err != nil {
+				return err
+			}
+			// Written only if still as hashed above: an edit made since
+			// would otherwise be lost without a conflict.
+			e := &configEdit{path: filepath.Join(s.home, filepath.FromSlash(rel)), hash: localHashes[rel]}
+			if err := e.commit(data); err != nil {
+				return err
+			}
+		}
+		s.state.Files = remoteHashes
+		if err := writeSyncState(s.state); err != nil {
+			return err
+		}
+		statusf(userColor, "Pulled %d file(s) from %s\n", len(files), s.remote)
+		return nil
+	},
+}
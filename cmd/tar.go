@@ -0,0 +1,442 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+
+	"gt/pkg/transport"
+)
+
+// tarProgressEvery is how often the upload progress line is redrawn.
+const tarProgressEvery = 200 * time.Millisecond
+
+// errTransferDone stops the local end of a tar stream once ssh is gone.
+var errTransferDone = errors.New("transfer finished")
+
+// tarEntry is one local path an upload sends, under its archive name.
+type tarEntry struct {
+	local string
+	name  string
+	info  fs.FileInfo
+}
+
+// collectUpload lists what uploading sources sends, each as its base
+// name and everything under it, and totals the size of the files.
+// Symlinks inside a directory are sent as links, and followed with
+// --follow-links; a source that is one is followed, as scp does, unless
+// --preserve-links.
+func collectUpload(sources []string, f transferFilter) ([]tarEntry, int64, error) {
+	c := uploadCollector{f: f}
+	for _, src := range sources {
+		stat := os.Stat
+		if preserveLinks {
+			stat = os.Lstat
+		}
+		info, err := stat(src)
+		if err != nil {
+			return nil, 0, err
+		}
+		name := filepath.Base(filepath.Clean(src))
+		if f.skip(name) {
+			continue
+		}
+		if err := c.add(src, name, info, nil); err != nil {
+			return nil, 0, err
+		}
+	}
+	return c.entries, c.total, nil
+}
+
+type uploadCollector struct {
+	f       transferFilter
+	entries []tarEntry
+	total   int64
+}
+
+// add lists local, sent as name, and what is under it if it is a
+// directory. seen holds the directories being walked, by their real
+// path, to stop a followed symlink from looping.
+func (c *uploadCollector) add(local, name string, info fs.FileInfo, seen map[string]bool) error {
+	c.entries = append(c.entries, tarEntry{local: local, name: name, info: info})
+	if info.Mode().IsRegular() {
+		c.total += info.Size()
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	if followLinks {
+		real, err := filepath.EvalSymlinks(local)
+		if err != nil {
+			return err
+		}
+		if seen[real] {
+			return fmt.Errorf("%s: symlink loop", local)
+		}
+		seen = copySet(seen)
+		seen[real] = true
+	}
+	children, err := os.ReadDir(local)
+	if err != nil {
+		return err
+	}
+	for _, d := range children {
+		p, arcName := filepath.Join(local, d.Name()), path.Join(name, d.Name())
+		if c.f.skip(arcName) {
+			continue
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if followLinks && fi.Mode()&fs.ModeSymlink != 0 {
+			if target, err := os.Stat(p); err == nil {
+				fi = target
+			}
+		}
+		if err := c.add(p, arcName, fi, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copySet(m map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// tarProgress draws "42% 12.3M of 29.1M" on stderr as an upload reads
+// its files; the estimate is the share of the local bytes sent so far.
+type tarProgress struct {
+	total, done int64
+	last        time.Time
+	drawn       bool
+}
+
+func (p *tarProgress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if time.Since(p.last) >= tarProgressEvery || p.done == p.total {
+		p.last = time.Now()
+		pct := int64(100)
+		if p.total > 0 {
+			pct = p.done * 100 / p.total
+		}
+		symbolColor.Fprintf(os.Stderr, "\r%3d%% %s of %s", pct, formatBytes(uint64(p.done)), formatBytes(uint64(p.total)))
+		p.drawn = true
+	}
+	return len(b), nil
+}
+
+func (p *tarProgress) end() {
+	if p.drawn {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// writeTar streams entries to w as a gzipped tar. progress, if not nil,
+// sees the file contents as they are read. A file that cannot be opened
+// is reported and left out, as tar does, and counted in the error.
+func writeTar(w io.Writer, entries []tarEntry, progress io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	unreadable := 0
+	for _, e := range entries {
+		mode := e.info.Mode()
+		link := ""
+		switch {
+		case mode&fs.ModeSymlink != 0:
+			l, err := os.Readlink(e.local)
+			if err != nil {
+				return err
+			}
+			link = l
+		case !mode.IsDir() && !mode.IsRegular():
+			// Sockets, devices and pipes have nothing to copy.
+			continue
+		}
+		var f *os.File
+		if mode.IsRegular() {
+			var err error
+			if f, err = os.Open(e.local); err != nil {
+				warningColor.Fprintf(os.Stderr, "gt: skipping %v\n", err)
+				unreadable++
+				continue
+			}
+		}
+		hdr, err := tar.FileInfoHeader(e.info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = e.name
+		if noPerms {
+			hdr.Mode = int64(plainMode(mode))
+		}
+		if copyXattrs {
+			if hdr.PAXRecords, err = tarRecords(hdr.PAXRecords, e.local); err != nil {
+				return err
+			}
+			hdr.Format = tar.FormatPAX
+		}
+		if mode.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if f != nil {
+			var r io.Reader = f
+			if progress != nil {
+				r = io.TeeReader(f, progress)
+			}
+			_, err = io.Copy(tw, r)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if unreadable > 0 {
+		return fmt.Errorf("%d file(s) could not be read and were not sent", unreadable)
+	}
+	return nil
+}
+
+// archiveName is name from a tar header as a path under the destination,
+// or false if it would land outside it.
+func archiveName(name string) (string, bool) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return clean, true
+}
+
+// extractTar unpacks a gzipped tar from r into dest, leaving out what f
+// skips. It refuses entries that would land outside dest, directly or
+// through a symlink unpacked earlier.
+func extractTar(r io.Reader, dest string, f transferFilter) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("the host sent no archive")
+		}
+		return fmt.Errorf("reading the archive: %w", err)
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	links := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading the archive: %w", err)
+		}
+		name, ok := archiveName(hdr.Name)
+		if !ok {
+			return fmt.Errorf("refusing %q from the archive: it points outside %s", hdr.Name, dest)
+		}
+		if name == "." || f.skip(name) {
+			continue
+		}
+		for p := path.Dir(name); p != "."; p = path.Dir(p) {
+			if links[p] {
+				return fmt.Errorf("refusing %q from the archive: it goes through the symlink %s", hdr.Name, p)
+			}
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		mode := hdr.FileInfo().Mode()
+		if noPerms {
+			mode = plainMode(mode)
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode.Perm()|0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			os.Remove(target) // never write through a symlink already there
+			out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			if !noTimes {
+				os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+			}
+		case tar.TypeSymlink:
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+			links[name] = true
+		case tar.TypeLink:
+			linked, ok := archiveName(hdr.Linkname)
+			if !ok {
+				return fmt.Errorf("refusing %q from the archive: it links outside %s", hdr.Name, dest)
+			}
+			os.Remove(target)
+			if err := os.Link(filepath.Join(dest, filepath.FromSlash(linked)), target); err != nil {
+				return err
+			}
+		}
+		if copyXattrs {
+			if err := applyRecords(target, hdr.PAXRecords); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// colonPath is a remote path from the colon shorthand; a bare ":" is the
+// remote home directory, where a command starts.
+func colonPath(p string) string {
+	if p = strings.TrimPrefix(p, ":"); p == "" {
+		return "."
+	}
+	return p
+}
+
+// tarUploadScript unpacks the stream from stdin into dest, creating it.
+// --no-times keeps tar from restoring modification times (-m).
+func tarUploadScript(dest string) string {
+	d := quoteArgv([]string{colonPath(dest)})
+	argv := []string{"tar"}
+	if noTimes {
+		argv = append(argv, "-m")
+	}
+	if copyXattrs {
+		argv = append(argv, "--xattrs")
+	}
+	return shellScript("mkdir -p -- " + d + " && " + quoteArgv(append(argv, "-xzf", "-")) + " -C " + d)
+}
+
+// tarDownloadScript packs every source, each under its base name, to
+// stdout; tar follows symlinks (-h) with --follow-links.
+func tarDownloadScript(sources []string) string {
+	argv := []string{"tar"}
+	if followLinks {
+		argv = append(argv, "-h")
+	}
+	if copyXattrs {
+		argv = append(argv, "--xattrs")
+	}
+	argv = append(argv, "-czf", "-")
+	for _, src := range sources {
+		p := colonPath(src)
+		base := path.Base(p)
+		if strings.HasPrefix(base, "-") {
+			base = "./" + base
+		}
+		argv = append(argv, "-C", path.Dir(p), base)
+	}
+	return shellScript(quoteArgv(argv))
+}
+
+// tarTransfer runs a transfer in gt's colon shorthand as one tar stream
+// over ssh: gt packs an upload itself and the host unpacks it with tar,
+// or the other way round. run runs the ssh command, whose stdin or
+// stdout gt has taken.
+func tarTransfer(alias string, files []string, opts remoteOpts, run func(*exec.Cmd) error) error {
+	if err := transport.ValidateSCPPaths(files); err != nil {
+		return err
+	}
+	files, err := expandRemotePaths(alias, files)
+	if err != nil {
+		return err
+	}
+	if files, _, err = planTransfer(alias, files, opts); err != nil {
+		return err
+	}
+	f := currentFilter()
+	dest := files[len(files)-1]
+	sources := files[:len(files)-1]
+
+	if !strings.HasPrefix(dest, ":") {
+		cmd, err := remoteCommand(alias, opts, tarDownloadScript(sources))
+		if err != nil {
+			return err
+		}
+		pr, pw := io.Pipe()
+		cmd.Stdout = pw
+		done := make(chan error, 1)
+		go func() {
+			err := extractTar(pr, dest, f)
+			pr.CloseWithError(err)
+			done <- err
+		}()
+		runErr := run(cmd)
+		pw.Close()
+		if err := <-done; err != nil && runErr == nil {
+			return withCode(exitTransfer, fmt.Errorf("unpacking from %s: %w", alias, err))
+		}
+		return runErr
+	}
+
+	entries, total, err := collectUpload(sources, f)
+	if err != nil {
+		return err
+	}
+	cmd, err := remoteCommand(alias, opts, tarUploadScript(dest))
+	if err != nil {
+		return err
+	}
+	var progress io.Writer
+	var bar *tarProgress
+	if !quiet && !opts.batch && isatty.IsTerminal(os.Stderr.Fd()) {
+		bar = &tarProgress{total: total}
+		progress = bar
+	}
+	pr, pw := io.Pipe()
+	cmd.Stdin = pr
+	done := make(chan error, 1)
+	go func() {
+		err := writeTar(pw, entries, progress)
+		if bar != nil {
+			bar.end()
+		}
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	runErr := run(cmd)
+	pr.CloseWithError(errTransferDone)
+	if err := <-done; err != nil && runErr == nil && !errors.Is(err, errTransferDone) {
+		return withCode(exitTransfer, fmt.Errorf("packing for %s: %w", alias, err))
+	}
+	return runErr
+}
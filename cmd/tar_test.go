@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "app")
+	for name, body := range map[string]string{
+		"main.go":                  "package main\n",
+		"bin/run":                  "#!/bin/sh\n",
+		"node_modules/left/pad.js": "module.exports = 1\n",
+		"web/node_modules/x/y.js":  "y\n",
+		"logs/debug.log":           "noise\n",
+		"logs/keep.log":            "signal\n",
+	} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(body), 0o644))
+	}
+	require.NoError(t, os.Chmod(filepath.Join(src, "bin/run"), 0o755))
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Symlink("main.go", filepath.Join(src, "current")))
+	}
+
+	f := transferFilter{exclude: []string{"node_modules", "*.log"}, include: []string{"keep.log"}}
+	entries, total, err := collectUpload([]string{src}, f)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("package main\n#!/bin/sh\nsignal\n")), total)
+
+	var archive, progress bytes.Buffer
+	require.NoError(t, writeTar(&archive, entries, &progress))
+	assert.Equal(t, int(total), progress.Len(), "progress sees every byte")
+
+	dest := t.TempDir()
+	require.NoError(t, extractTar(&archive, dest, transferFilter{}))
+	got, err := os.ReadFile(filepath.Join(dest, "app/main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(got))
+	assert.FileExists(t, filepath.Join(dest, "app/logs/keep.log"))
+	assert.NoFileExists(t, filepath.Join(dest, "app/logs/debug.log"))
+	assert.NoDirExists(t, filepath.Join(dest, "app/node_modules"))
+	assert.NoDirExists(t, filepath.Join(dest, "app/web/node_modules"))
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(filepath.Join(dest, "app/bin/run"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o755), fi.Mode().Perm())
+		link, err := os.Readlink(filepath.Join(dest, "app/current"))
+		require.NoError(t, err)
+		assert.Equal(t, "main.go", link)
+	}
+}
+
+func tarOf(t *testing.T, hdrs ...*tar.Header) *bytes.Buffer {
+	t.Helper()
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	for _, h := range hdrs {
+		require.NoError(t, tw.WriteHeader(h))
+		if h.Size > 0 {
+			_, err := tw.Write(bytes.Repeat([]byte("x"), int(h.Size)))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return &b
+}
+
+func TestExtractTarRefusesEscapes(t *testing.T) {
+	dest := t.TempDir()
+	err := extractTar(tarOf(t, &tar.Header{Name: "../evil", Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}), dest, transferFilter{})
+	assert.ErrorContains(t, err, "points outside")
+	err = extractTar(tarOf(t, &tar.Header{Name: "/etc/evil", Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}), dest, transferFilter{})
+	assert.ErrorContains(t, err, "points outside")
+
+	if runtime.GOOS != "windows" {
+		err = extractTar(tarOf(t,
+			&tar.Header{Name: "out", Linkname: "/tmp", Typeflag: tar.TypeSymlink},
+			&tar.Header{Name: "out/evil", Mode: 0o644, Size: 1, Typeflag: tar.TypeReg},
+		), dest, transferFilter{})
+		assert.ErrorContains(t, err, "through the symlink out")
+		assert.NoFileExists(t, "/tmp/evil")
+	}
+
+	assert.ErrorContains(t, extractTar(&bytes.Buffer{}, dest, transferFilter{}), "sent no archive")
+}
+
+func TestTarTransfer(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	useEngine(t, "tar", 0, 0)
+	src := filepath.Join(t.TempDir(), "site")
+	require.NoError(t, os.MkdirAll(src, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "index.html"), []byte("<h1>hi</h1>"), 0o644))
+
+	require.NoError(t, pushOne("web-1", []string{src, ":/srv/www"}))
+	args := mockRun("ssh")
+	assert.Equal(t, `sh -c 'mkdir -p -- /srv/www && tar -xzf - -C /srv/www'`, args[len(args)-1])
+
+	mockCmd.reset()
+	err := tarTransfer("web-1", []string{":/var/log/app", ":-odd", t.TempDir()}, remoteOpts{}, func(c *exec.Cmd) error {
+		return runCommandLogged(c, "web-1", "scp")
+	})
+	assert.ErrorContains(t, err, "unpacking from web-1: the host sent no archive")
+	args = mockRun("ssh")
+	assert.Equal(t, `sh -c 'tar -czf - -C /var/log app -C . ./-odd'`, args[len(args)-1])
+
+	_, err = transferCommand("web-1", []string{src, ":/srv/www"}, remoteOpts{})
+	assert.ErrorContains(t, err, "only gt -s and gt push")
+}
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"gt/pkg/plugin"
+	"gt/pkg/sshconf"
+)
+
+// teamCache is the state file holding the team inventory as last fetched.
+const teamCache = "team.yaml"
+
+// teamInventory is a team's hosts file: the hosts section of gt's config,
+// with each host's connection settings alongside its metadata.
+type teamInventory struct {
+	Hosts map[string]teamHost `yaml:"hosts"`
+}
+
+// teamHost is one host of a team inventory: the fields gt serve
+// --inventory publishes (servedHost), and no others. How to run sessions
+// (dir, shell, dotfiles, gssapi, trust, the login checks) is for each
+// user's own gt config to say, not for a file fetched from elsewhere.
+type teamHost struct {
+	HostName string `yaml:"hostname"`
+	User     string `yaml:"user"`
+	Port     string `yaml:"port"`
+	// Options are further ssh_config options, by keyword.
+	Options           map[string]string `yaml:"options"`
+	Groups            []string          `yaml:"groups"`
+	Description       string            `yaml:"description"`
+	FallbackAddresses []string          `yaml:"fallback_addresses"`
+	ConnectTimeout    string            `yaml:"connect_timeout"`
+	Vars              map[string]string `yaml:"vars"`
+	Logs              map[string]string `yaml:"logs"`
+}
+
+// meta is the metadata h gives its host.
+func (h teamHost) meta() hostMeta {
+	return hostMeta{
+		Groups:            h.Groups,
+		Description:       h.Description,
+		FallbackAddresses: h.FallbackAddresses,
+		ConnectTimeout:    h.ConnectTimeout,
+		Vars:              h.Vars,
+		Logs:              h.Logs,
+	}
+}
+
+// refusedTeamField is a key of a host in data that gt's own config takes
+// but a team inventory may not, such as otp_command or gssapi: "" when
+// there is none. Keys neither takes are left to the decoder to report.
+func refusedTeamField(data []byte) (alias, key string) {
+	var raw struct {
+		Hosts map[string]map[string]yaml.Node `yaml:"hosts"`
+	}
+	if yaml.Unmarshal(data, &raw) != nil {
+		return "", ""
+	}
+	// Every key of gt's host settings: with no omitempty, marshalling
+	// the zero value writes them all.
+	var own, team map[string]any
+	out, _ := yaml.Marshal(hostMeta{})
+	_ = yaml.Unmarshal(out, &own)
+	out, _ = yaml.Marshal(teamHost{})
+	_ = yaml.Unmarshal(out, &team)
+	aliases := make([]string, 0, len(raw.Hosts))
+	for a := range raw.Hosts {
+		aliases = append(aliases, a)
+	}
+	sort.Strings(aliases)
+	for _, a := range aliases {
+		keys := make([]string, 0, len(raw.Hosts[a]))
+		for k := range raw.Hosts[a] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			_, isOwn := own[k]
+			if _, isTeam := team[k]; isOwn && !isTeam {
+				return a, k
+			}
+		}
+	}
+	return "", ""
+}
+
+// teamAllowed are the ssh_config keywords a team inventory may set:
+// where and as whom to connect, and how to keep the connection up. Any
+// other, one that runs a command or loads a library here (ProxyCommand,
+// PKCS11Provider), forwards something (ForwardAgent, RemoteForward,
+// SendEnv) or weakens host-key checks (StrictHostKeyChecking,
+// UserKnownHostsFile), is not for a file fetched from elsewhere to set.
+var teamAllowed = map[string]bool{
+	"hostname":                     true,
+	"user":                         true,
+	"port":                         true,
+	"proxyjump":                    true,
+	"identityfile":                 true,
+	"identitiesonly":               true,
+	"certificatefile":              true,
+	"preferredauthentications":     true,
+	"pubkeyauthentication":         true,
+	"passwordauthentication":       true,
+	"kbdinteractiveauthentication": true,
+	"hostkeyalias":                 true,
+	"addressfamily":                true,
+	"connecttimeout":               true,
+	"connectionattempts":           true,
+	"serveraliveinterval":          true,
+	"serveralivecountmax":          true,
+	"tcpkeepalive":                 true,
+	"compression":                  true,
+	"requesttty":                   true,
+	"loglevel":                     true,
+}
+
+// teamMeta is the metadata of the team hosts in effect, those no host of
+// the SSH config shadows.
+var teamMeta map[string]hostMeta
+
+// parseTeamInventory decodes and checks a team inventory.
+func parseTeamInventory(data []byte) (teamInventory, error) {
+	var inv teamInventory
+	if alias, key := refusedTeamField(data); key != "" {
+		return inv, fmt.Errorf("host %s: a team inventory may not set %s; put it in your own gt config", alias, key)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&inv); err != nil && err != io.EOF {
+		return inv, err
+	}
+	for alias, h := range inv.Hosts {
+		if err := validateImported(h.plugin(alias)); err != nil {
+			return inv, err
+		}
+		for key := range h.Options {
+			if strings.ContainsAny(key, " \t=") {
+				return inv, fmt.Errorf("host %s: invalid option %q", alias, key)
+			}
+			if !teamAllowed[strings.ToLower(key)] {
+				return inv, fmt.Errorf("host %s: a team inventory may not set %s; put it in your own SSH config", alias, key)
+			}
+		}
+	}
+	return inv, nil
+}
+
+// plugin is h as an imported host, to share gt import's checks and
+// rendering.
+func (h teamHost) plugin(alias string) plugin.Host {
+	return plugin.Host{Alias: alias, HostName: h.HostName, User: h.User, Port: h.Port, Options: h.Options}
+}
+
+// fetchTeamInventory reads the inventory at source: an http(s) URL, asked
+// with teamToken if there is one, or a path.
+func fetchTeamInventory(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+		return os.ReadFile(sshconf.ExpandTilde(source))
+	}
+	token, err := teamToken()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", source, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// teamToken is the token gt team refresh presents to an http(s)
+// inventory: GT_TEAM_TOKEN, then the contents of team_token_file; "" for
+// none.
+func teamToken() (string, error) {
+	if t := os.Getenv("GT_TEAM_TOKEN"); t != "" {
+		return t, nil
+	}
+	if gtCfg.TeamTokenFile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(sshconf.ExpandTilde(gtCfg.TeamTokenFile))
+	if err != nil {
+		return "", fmt.Errorf("team_token_file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// loadTeamInventory reads the cached team inventory; ok is false when gt
+// has none configured or it was never fetched.
+func loadTeamInventory() (inv teamInventory, ok bool, err error) {
+	if gtCfg.TeamInventory == "" {
+		return inv, false, nil
+	}
+	p, err := statePath(teamCache)
+	if err != nil {
+		return inv, false, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		debugf(1, "team inventory %s not fetched yet; run 'gt team refresh'", gtCfg.TeamInventory)
+		return inv, false, nil
+	} else if err != nil {
+		return inv, false, err
+	}
+	if inv, err = parseTeamInventory(data); err != nil {
+		return inv, false, fmt.Errorf("team inventory %s: %w", p, err)
+	}
+	return inv, true, nil
+}
+
+// overlayTeam adds the cached team hosts to c beneath its own and
+// returns the config file holding them, for the effective config to
+// include after the main one; "" when there are none. A team host named
+// like a host of c is left out whole, metadata and all.
+func overlayTeam(c *ssh_config.Config) (string, error) {
+	teamMeta = nil
+	inv, ok, err := loadTeamInventory()
+	if err != nil || !ok {
+		return "", err
+	}
+	own := map[string]bool{}
+	for _, alias := range sshconf.Aliases(c) {
+		own[alias] = true
+	}
+	aliases := make([]string, 0, len(inv.Hosts))
+	for alias := range inv.Hosts {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	var body strings.Builder
+	teamMeta = map[string]hostMeta{}
+	for _, alias := range aliases {
+		if own[alias] {
+			continue
+		}
+		h := inv.Hosts[alias]
+		teamMeta[alias] = h.meta()
+		if body.Len() > 0 {
+			body.WriteString("\n")
+		}
+		body.WriteString(formatHostBlock(h.plugin(alias)))
+	}
+	if len(teamMeta) == 0 {
+		return "", nil
+	}
+	decoded, err := sshconf.Decode(strings.NewReader(body.String()))
+	if err != nil {
+		return "", fmt.Errorf("team inventory: %w", err)
+	}
+	c.Hosts = append(c.Hosts, decoded.Hosts...)
+	return writeRuntimeFile("team-*.conf", []byte(body.String()))
+}
+
+var teamCmd = &cobra.Command{
+	Use:   "team",
+	Short: "Use a hosts file your team maintains",
+	Long: `Read the hosts of a team inventory beneath your own SSH config. The
+inventory is a YAML file at a URL or path, named by team_inventory in gt's
+config, with a hosts section like that of gt's config plus each host's
+hostname, user, port and further ssh_config options.
+
+Your config wins: a host of your SSH config shadows the team host of the
+same name entirely, and your gt config's entry for a team host replaces
+the team's metadata for it. The team hosts are read after your config, so
+its Host * and Match blocks apply to them first.`,
+}
+
+var teamRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Fetch the team inventory",
+	Long: `Fetch the team inventory from team_inventory in gt's config and keep a
+copy in gt's state directory, which gt reads from then on: hosts stay
+available offline, and change only when you refresh. An inventory that
+does not parse, or sets an option that runs local commands, such as
+ProxyCommand, is refused and the old copy kept.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source := gtCfg.TeamInventory
+		if source == "" {
+			p, _ := gtConfigPath()
+			return withCode(exitConfig, fmt.Errorf("no team inventory configured; set team_inventory in %s", p))
+		}
+		cmd.SilenceUsage = true
+		data, err := fetchTeamInventory(source)
+		if err != nil {
+			return fmt.Errorf("fetching team inventory: %w", err)
+		}
+		inv, err := parseTeamInventory(data)
+		if err != nil {
+			return withCode(exitConfig, fmt.Errorf("team inventory %s: %w", source, err))
+		}
+		p, err := statePath(teamCache)
+		if err != nil {
+			return err
+		}
+		if err := writeStateFile(p, data); err != nil {
+			return err
+		}
+		var shadowed []string
+		for alias := range inv.Hosts {
+			if _, team := teamMeta[alias]; knownHost(alias) && !team {
+				shadowed = append(shadowed, alias)
+			}
+		}
+		sort.Strings(shadowed)
+		statusf(symbolColor, "Fetched %d hosts from %s\n", len(inv.Hosts), source)
+		if len(shadowed) > 0 {
+			warningColor.Fprintf(os.Stderr, "Shadowed by your SSH config: %s\n", strings.Join(shadowed, ", "))
+		}
+		return nil
+	},
+}
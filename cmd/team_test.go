@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const teamYAML = `hosts:
+  web:
+    hostname: web.team.example.com
+    groups: [team]
+  db:
+    hostname: db.team.example.com
+    user: postgres
+    port: 5432
+    options:
+      ProxyJump: bastion
+    groups: [team, data]
+    description: The primary
+`
+
+func TestParseTeamInventory(t *testing.T) {
+	inv, err := parseTeamInventory([]byte(teamYAML))
+	require.NoError(t, err)
+	db := inv.Hosts["db"]
+	assert.Equal(t, "5432", db.Port)
+	assert.Equal(t, map[string]string{"ProxyJump": "bastion"}, db.Options)
+	assert.Equal(t, []string{"team", "data"}, db.Groups)
+	assert.Equal(t, "The primary", db.Description)
+
+	for yaml, want := range map[string]string{
+		"hosts:\n  a:\n    options:\n      ProxyCommand: nc %h %p\n":            "may not set ProxyCommand",
+		"hosts:\n  a:\n    options:\n      include: x\n":                        "may not set include",
+		"hosts:\n  a:\n    options:\n      \"User x\": y\n":                     "invalid option",
+		"hosts:\n  \"a b\":\n    user: x\n":                                     "invalid alias",
+		"hosts:\n  a:\n    hostnme: x\n":                                        "field hostnme not found",
+		"hosts:\n  a:\n    otp_command: pass otp work\n":                        "may not set otp_command",
+		"hosts:\n  a:\n    password_command: op read x\n":                       "may not set password_command",
+		"hosts:\n  a:\n    gssapi: true\n":                                      "may not set gssapi",
+		"hosts:\n  a:\n    dotfiles: true\n":                                    "may not set dotfiles",
+		"hosts:\n  a:\n    shell: fish\n":                                       "may not set shell",
+		"hosts:\n  a:\n    trust: trusted\n":                                    "may not set trust",
+		"hosts:\n  a:\n    check_sessions: true\n":                              "may not set check_sessions",
+		"hosts:\n  a:\n    options:\n      PKCS11Provider: /tmp/evil.so\n":      "may not set PKCS11Provider",
+		"hosts:\n  a:\n    options:\n      SecurityKeyProvider: /tmp/evil.so\n": "may not set SecurityKeyProvider",
+		"hosts:\n  a:\n    options:\n      ForwardAgent: \"yes\"\n":             "may not set ForwardAgent",
+		"hosts:\n  a:\n    options:\n      RemoteForward: 9000 localhost:22\n":  "may not set RemoteForward",
+		"hosts:\n  a:\n    options:\n      LocalForward: 8080 db:5432\n":        "may not set LocalForward",
+		"hosts:\n  a:\n    options:\n      SendEnv: AWS_*\n":                    "may not set SendEnv",
+		"hosts:\n  a:\n    options:\n      StrictHostKeyChecking: \"no\"\n":     "may not set StrictHostKeyChecking",
+		"hosts:\n  a:\n    options:\n      UserKnownHostsFile: /dev/null\n":     "may not set UserKnownHostsFile",
+	} {
+		_, err := parseTeamInventory([]byte(yaml))
+		assert.ErrorContains(t, err, want, yaml)
+	}
+}
+
+func TestTeamOverlay(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Cleanup(removeRuntimeDir)
+	useWebGroup(t)
+	t.Cleanup(func() { teamMeta = nil })
+	origCfgFile, origEffective := cfgFile, effectiveConfig
+	t.Cleanup(func() { cfgFile, effectiveConfig = origCfgFile, origEffective })
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "team.yaml")
+	writeConfigFile(t, source, teamYAML)
+	cfgFile = filepath.Join(dir, "config")
+	writeConfigFile(t, cfgFile, "Host web\n  HostName web.mine.example.com\n")
+	gtCfg.TeamInventory = source
+	gtCfg.Hosts = map[string]hostMeta{"db": {Groups: []string{"mine"}}}
+
+	require.NoError(t, loadConfig(cfgFile))
+	assert.Equal(t, []string{"web"}, getHosts(), "nothing until gt team refresh")
+	assert.Equal(t, []string{"-F", cfgFile}, sshBaseArgs())
+
+	require.NoError(t, teamRefreshCmd.RunE(teamRefreshCmd, nil))
+	require.NoError(t, loadConfig(cfgFile))
+	assert.Equal(t, []string{"db", "web"}, getHosts())
+	assert.Equal(t, []string{"mine"}, hostMetaFor("db").Groups, "gt's config wins over the team's metadata")
+	_, err := groupMembers("team")
+	assert.Error(t, err, "the team's web is shadowed, its db has metadata of its own")
+
+	args := sshBaseArgs()
+	require.Equal(t, "-F", args[0])
+	data, err := os.ReadFile(args[1])
+	require.NoError(t, err)
+	body := string(data)
+	assert.True(t, strings.HasPrefix(body, "Host web\n  HostName web.mine.example.com\n\nMatch all\n  Include "), body)
+	team := strings.Fields(body[strings.Index(body, "Include "):])[1]
+	included, err := os.ReadFile(team)
+	require.NoError(t, err)
+	assert.Equal(t, "Host db\n  HostName db.team.example.com\n  User postgres\n  Port 5432\n  ProxyJump bastion\n", string(included))
+
+	// A bad inventory is refused and the copy gt has kept.
+	writeConfigFile(t, source, "hosts:\n  x:\n    options:\n      LocalCommand: id\n")
+	assert.Error(t, teamRefreshCmd.RunE(teamRefreshCmd, nil))
+	require.NoError(t, loadConfig(cfgFile))
+	assert.Equal(t, []string{"db", "web"}, getHosts())
+}
+
+func TestTeamRefreshUnconfigured(t *testing.T) {
+	useWebGroup(t)
+	err := teamRefreshCmd.RunE(teamRefreshCmd, nil)
+	assert.ErrorContains(t, err, "set team_inventory in")
+	assert.Equal(t, exitConfig, ExitCode(err))
+}
+
+func TestFetchTeamInventoryToken(t *testing.T) {
+	useWebGroup(t)
+	srv := httptest.NewServer(&inventoryServer{token: "sekrit", data: []byte(teamYAML)})
+	defer srv.Close()
+
+	t.Setenv("GT_TEAM_TOKEN", "")
+	_, err := fetchTeamInventory(srv.URL + "/inventory")
+	assert.ErrorContains(t, err, "401")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	writeConfigFile(t, tokenFile, "sekrit\n")
+	gtCfg.TeamTokenFile = tokenFile
+	data, err := fetchTeamInventory(srv.URL + "/inventory")
+	require.NoError(t, err)
+	assert.Equal(t, teamYAML, string(data))
+
+	t.Setenv("GT_TEAM_TOKEN", "wrong")
+	_, err = fetchTeamInventory(srv.URL + "/inventory")
+	assert.ErrorContains(t, err, "401 Unauthorized", "GT_TEAM_TOKEN wins over the file")
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"strings"
+	"text/template"
+)
+
+// hostVars is what a templated command sees for each host: {{.Alias}},
+// {{.Hostname}}, {{.User}}, {{.Port}} as ssh -G resolves them, and
+// {{.Groups}}, the host's groups from gt's config, which serve as its
+// tags, and {{.Vars.name}}, its vars from there.
+type hostVars struct {
+	Alias    string
+	Hostname string
+	User     string
+	Port     string
+	Groups   []string
+	Vars     map[string]string
+}
+
+// templateFuncs are the helpers a command template can call beyond
+// text/template's own.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	// quote makes a value one word for the remote shell.
+	"quote": func(s string) string { return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'" },
+}
+
+// commandTemplate is a remote command with each argument parsed as a Go
+// template; arguments without "{{" stay literal and are nil here.
+type commandTemplate struct {
+	args  []string
+	tmpls []*template.Template
+}
+
+// parseCommandTemplate parses the arguments of a remote command, so a
+// malformed template fails before any host is touched.
+func parseCommandTemplate(args []string) (commandTemplate, error) {
+	t := commandTemplate{args: args, tmpls: make([]*template.Template, len(args))}
+	for i, a := range args {
+		if !strings.Contains(a, "{{") {
+			continue
+		}
+		tmpl, err := template.New("command").Funcs(templateFuncs).Option("missingkey=error").Parse(a)
+		if err != nil {
+			return t, err
+		}
+		t.tmpls[i] = tmpl
+	}
+	return t, nil
+}
+
+// templated reports whether any argument needs expanding.
+func (t commandTemplate) templated() bool {
+	for _, tmpl := range t.tmpls {
+		if tmpl != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// expand renders the command for one host.
+func (t commandTemplate) expand(v hostVars) ([]string, error) {
+	out := make([]string, len(t.args))
+	for i, a := range t.args {
+		if t.tmpls[i] == nil {
+			out[i] = a
+			continue
+		}
+		var b strings.Builder
+		if err := t.tmpls[i].Execute(&b, v); err != nil {
+			return nil, err
+		}
+		out[i] = b.String()
+	}
+	return out, nil
+}
+
+// hostVarsFor resolves alias into the variables its commands see.
+func hostVarsFor(alias string) (hostVars, error) {
+	r, err := resolveHost(alias)
+	if err != nil {
+		return hostVars{}, err
+	}
+	meta := hostMetaFor(alias)
+	return hostVars{
+		Alias:    alias,
+		Hostname: r.Hostname,
+		User:     r.User,
+		Port:     r.Port,
+		Groups:   meta.Groups,
+		Vars:     meta.Vars,
+	}, nil
+}
+
+// expandForHost expands the templates among args for alias. The host is
+// only resolved when there is something to expand.
+func expandForHost(alias string, args []string) ([]string, error) {
+	t, err := parseCommandTemplate(args)
+	if err != nil || !t.templated() {
+		return args, err
+	}
+	v, err := hostVarsFor(alias)
+	if err != nil {
+		return nil, err
+	}
+	return t.expand(v)
+}
+
+// expandRemotePaths expands the remote (":"-prefixed) side of a transfer
+// for alias; local paths are the local shell's business.
+func expandRemotePaths(alias string, files []string) ([]string, error) {
+	var remote []string
+	for _, f := range files {
+		if strings.HasPrefix(f, ":") {
+			remote = append(remote, f)
+		}
+	}
+	expanded, err := expandForHost(alias, remote)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]string(nil), files...)
+	for i, f := range out {
+		if strings.HasPrefix(f, ":") {
+			out[i], expanded = expanded[0], expanded[1:]
+		}
+	}
+	return out, nil
+}
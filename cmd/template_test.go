@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandTemplate(t *testing.T) {
+	v := hostVars{Alias: "web-1", Hostname: "10.0.0.1", User: "deploy", Port: "22", Groups: []string{"web", "prod"}}
+
+	tmpl, err := parseCommandTemplate([]string{"curl", "-H", "Host: {{.Alias}}", "http://{{.Hostname}}:{{.Port}}/"})
+	assert.NoError(t, err)
+	assert.True(t, tmpl.templated())
+	argv, err := tmpl.expand(v)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"curl", "-H", "Host: web-1", "http://10.0.0.1:22/"}, argv)
+
+	tmpl, err = parseCommandTemplate([]string{"echo", `{{join .Groups ","}}`, "{{quote .User}}"})
+	assert.NoError(t, err)
+	argv, err = tmpl.expand(v)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"echo", "web,prod", "'deploy'"}, argv)
+
+	tmpl, err = parseCommandTemplate([]string{"uptime"})
+	assert.NoError(t, err)
+	assert.False(t, tmpl.templated())
+
+	_, err = parseCommandTemplate([]string{"echo {{.Alias"})
+	assert.Error(t, err, "malformed template")
+
+	tmpl, err = parseCommandTemplate([]string{"echo {{.Nope}}"})
+	assert.NoError(t, err)
+	_, err = tmpl.expand(v)
+	assert.Error(t, err, "unknown field")
+}
+
+func TestExecTemplated(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+
+	execCmd.SetOut(io.Discard)
+	t.Cleanup(func() { execCmd.SetOut(nil) })
+	execCmd.RunE(execCmd, []string{"@web", "--", "echo {{.Alias}}@{{.Hostname}}"})
+
+	var ran []string
+	for i, c := range mockCmd.commands {
+		args := mockCmd.argLists[i]
+		if c == "ssh" && len(args) > 0 && !contains(args, "-G") {
+			ran = append(ran, args[len(args)-1])
+			assert.NotContains(t, args[len(args)-2:], "--", "gt's -- is not passed on as the command")
+		}
+	}
+	// The mock's "down" fails already at ssh -G, so never gets a command.
+	assert.ElementsMatch(t, []string{"echo web-1@test.example.com", "echo web-2@test.example.com"}, ran)
+}
+
+// useHostVars gives web an app_dir var and a session directory built
+// from it.
+func useHostVars(t *testing.T) {
+	t.Helper()
+	orig := gtCfg
+	t.Cleanup(func() { gtCfg = orig })
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{
+		"web": {Vars: map[string]string{"app_dir": "/srv/my app"}, Dir: "{{.Vars.app_dir}}/current"},
+	}}
+}
+
+func TestHostVarsInTransferPaths(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useHostVars(t)
+
+	assert.NoError(t, runSCP("web", []string{"app.conf", ":{{.Vars.app_dir}}/etc/"}))
+	args := mockRun("scp")
+	assert.Equal(t, "web:/srv/my app/etc/", args[len(args)-1])
+	assert.Equal(t, "app.conf", args[len(args)-2])
+
+	err := runSCP("web", []string{"app.conf", ":{{.Vars.nope}}/"})
+	assert.ErrorContains(t, err, "nope", "a var the host does not have is an error, not an empty path")
+}
+
+func TestExpandForHostLeavesPlainArgs(t *testing.T) {
+	useMockExec(t)
+	args, err := expandForHost("web", []string{"uptime"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"uptime"}, args)
+	assert.Empty(t, mockCmd.commands, "nothing to expand, nothing resolved")
+}
+
+func TestSessionStartsInDir(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useHostVars(t)
+
+	assert.NoError(t, runSSH("web", nil))
+	args := mockRun("ssh")
+	assert.Contains(t, args, "-t")
+	assert.Equal(t, `cd '/srv/my app/current' && exec "$SHELL" -l`, args[len(args)-1])
+
+	mockCmd.reset()
+	assert.NoError(t, runSSH("web", []string{"uptime"}))
+	args = mockRun("ssh")
+	assert.Equal(t, "uptime", args[len(args)-1], "a remote command is run as given")
+}
+
+// mockRun is the argv of the first mocked run of name, skipping ssh -G.
+func mockRun(name string) []string {
+	for i, c := range mockCmd.commands {
+		if c == name && !contains(mockCmd.argLists[i], "-G") {
+			return mockCmd.argLists[i]
+		}
+	}
+	return nil
+}
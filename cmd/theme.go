@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// themeConfig is the theme section of gt's config: a built-in base theme
+// plus optional per-role overrides. Each role takes a space-separated
+// color spec such as "blue bold", "hi-cyan", "208" (256-color) or
+// "#bd93f9" (truecolor).
+type themeConfig struct {
+	Name      string `yaml:"name"`
+	Alias     string `yaml:"alias"`
+	User      string `yaml:"user"`
+	Domain    string `yaml:"domain"`
+	Subdomain string `yaml:"subdomain"`
+	Port      string `yaml:"port"`
+	Error     string `yaml:"error"`
+	Warning   string `yaml:"warning"`
+	Symbol    string `yaml:"symbol"`
+}
+
+// builtinThemes are the named bases a theme section can start from.
+// "default" reproduces gt's original palette.
+var builtinThemes = map[string]themeConfig{
+	"default": {
+		Alias:     "blue bold",
+		User:      "green",
+		Domain:    "yellow",
+		Subdomain: "cyan",
+		Port:      "magenta",
+		Error:     "red",
+		Warning:   "yellow",
+		Symbol:    "white",
+	},
+	// mono keeps structure through weight alone, for monochrome terminals
+	// and for readers who cannot rely on hue.
+	"mono": {
+		Alias:   "bold",
+		Error:   "bold underline",
+		Warning: "bold",
+		Symbol:  "faint",
+	},
+	"solarized": {
+		Alias:     "#268bd2 bold",
+		User:      "#859900",
+		Domain:    "#b58900",
+		Subdomain: "#2aa198",
+		Port:      "#d33682",
+		Error:     "#dc322f",
+		Warning:   "#cb4b16",
+		Symbol:    "#93a1a1",
+	},
+	"dracula": {
+		Alias:     "#bd93f9 bold",
+		User:      "#50fa7b",
+		Domain:    "#f1fa8c",
+		Subdomain: "#8be9fd",
+		Port:      "#ff79c6",
+		Error:     "#ff5555",
+		Warning:   "#ffb86c",
+		Symbol:    "#f8f8f2",
+	},
+}
+
+var colorNames = map[string]color.Attribute{
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+}
+
+var styleNames = map[string]color.Attribute{
+	"bold":      color.Bold,
+	"faint":     color.Faint,
+	"italic":    color.Italic,
+	"underline": color.Underline,
+}
+
+// parseColorSpec turns a color spec into a *color.Color. An empty spec is
+// valid and means "no styling".
+func parseColorSpec(spec string) (*color.Color, error) {
+	c := color.New()
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		if a, ok := styleNames[word]; ok {
+			c.Add(a)
+			continue
+		}
+		if a, ok := colorNames[word]; ok {
+			c.Add(a)
+			continue
+		}
+		if name, ok := strings.CutPrefix(word, "hi-"); ok {
+			if a, ok := colorNames[name]; ok {
+				c.Add(a + (color.FgHiBlack - color.FgBlack))
+				continue
+			}
+		}
+		if hex, ok := strings.CutPrefix(word, "#"); ok && len(hex) == 6 {
+			if v, err := strconv.ParseUint(hex, 16, 32); err == nil {
+				c.AddRGB(int(v>>16), int(v>>8&0xff), int(v&0xff))
+				continue
+			}
+		}
+		if n, err := strconv.Atoi(word); err == nil && n >= 0 && n <= 255 {
+			c.Add(38, 5, color.Attribute(n))
+			continue
+		}
+		return nil, fmt.Errorf("unknown color %q in %q", word, spec)
+	}
+	return c, nil
+}
+
+// applyTheme resolves a theme section against its base and installs the
+// result into the package-level role colors.
+func applyTheme(t themeConfig) error {
+	name := t.Name
+	if name == "" {
+		name = "default"
+	}
+	base, ok := builtinThemes[name]
+	if !ok {
+		var names []string
+		for n := range builtinThemes {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown theme %q (built-in themes: %s)", name, strings.Join(names, ", "))
+	}
+
+	roles := []struct {
+		dst       **color.Color
+		base, set string
+	}{
+		{&aliasColor, base.Alias, t.Alias},
+		{&userColor, base.User, t.User},
+		{&domainColor, base.Domain, t.Domain},
+		{&subdomainColor, base.Subdomain, t.Subdomain},
+		{&portColor, base.Port, t.Port},
+		{&errorColor, base.Error, t.Error},
+		{&warningColor, base.Warning, t.Warning},
+		{&symbolColor, base.Symbol, t.Symbol},
+	}
+	for _, r := range roles {
+		spec := r.base
+		if r.set != "" {
+			spec = r.set
+		}
+		c, err := parseColorSpec(spec)
+		if err != nil {
+			return fmt.Errorf("theme: %w", err)
+		}
+		*r.dst = c
+	}
+	return nil
+}
+
+var colorMode string
+
+// autoColorEnabled decides whether "auto" means color, following the
+// NO_COLOR and CLICOLOR conventions in this order: NO_COLOR disables;
+// CLICOLOR_FORCE (any value but "0") enables even when piped; CLICOLOR=0
+// disables; otherwise color is on only for a terminal that is not dumb.
+func autoColorEnabled(getenv func(string) string, stdoutTTY bool) bool {
+	if getenv("NO_COLOR") != "" {
+		return false
+	}
+	if f := getenv("CLICOLOR_FORCE"); f != "" && f != "0" {
+		return true
+	}
+	if getenv("CLICOLOR") == "0" || getenv("TERM") == "dumb" {
+		return false
+	}
+	return stdoutTTY
+}
+
+func stdoutIsTerminal() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// applyColorMode implements --color. "auto" follows autoColorEnabled;
+// the other two override the environment either way.
+func applyColorMode(mode string) error {
+	switch mode {
+	case "", "auto":
+		color.NoColor = nonInteractive() || !autoColorEnabled(os.Getenv, stdoutIsTerminal())
+	case "always":
+		color.NoColor = false
+	case "never":
+		color.NoColor = true
+	default:
+		return fmt.Errorf("invalid --color %q (want always, never or auto)", mode)
+	}
+	return nil
+}
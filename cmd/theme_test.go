@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColorSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want *color.Color
+	}{
+		{"", color.New()},
+		{"blue bold", color.New(color.FgBlue, color.Bold)},
+		{"hi-cyan", color.New(color.FgHiCyan)},
+		{"Underline RED", color.New(color.Underline, color.FgRed)},
+		{"208", color.New(38, 5, 208)},
+		{"#bd93f9", color.New().AddRGB(0xbd, 0x93, 0xf9)},
+	}
+	for _, tt := range tests {
+		got, err := parseColorSpec(tt.spec)
+		assert.NoError(t, err, "spec=%q", tt.spec)
+		assert.True(t, tt.want.Equals(got), "spec=%q", tt.spec)
+	}
+
+	for _, bad := range []string{"purple", "#12345", "256", "hi-bold"} {
+		_, err := parseColorSpec(bad)
+		assert.Error(t, err, "spec=%q", bad)
+	}
+}
+
+// saveColors restores the package role colors after a test swaps them.
+func saveColors(t *testing.T) {
+	t.Helper()
+	saved := []*color.Color{aliasColor, userColor, domainColor, subdomainColor, portColor, errorColor, warningColor, symbolColor}
+	t.Cleanup(func() {
+		aliasColor, userColor, domainColor, subdomainColor = saved[0], saved[1], saved[2], saved[3]
+		portColor, errorColor, warningColor, symbolColor = saved[4], saved[5], saved[6], saved[7]
+	})
+}
+
+func TestApplyTheme(t *testing.T) {
+	saveColors(t)
+
+	assert.NoError(t, applyTheme(themeConfig{Name: "mono", Port: "underline"}))
+	assert.True(t, color.New(color.Bold).Equals(aliasColor), "base theme role")
+	assert.True(t, color.New(color.Underline).Equals(portColor), "override wins over base")
+	assert.True(t, color.New().Equals(userColor), "mono leaves users unstyled")
+
+	assert.NoError(t, applyTheme(themeConfig{}))
+	assert.True(t, color.New(color.FgBlue, color.Bold).Equals(aliasColor), "empty section is the default theme")
+
+	assert.ErrorContains(t, applyTheme(themeConfig{Name: "neon"}), "unknown theme")
+	assert.ErrorContains(t, applyTheme(themeConfig{Alias: "purple"}), "unknown color")
+}
+
+func TestApplyColorMode(t *testing.T) {
+	orig := color.NoColor
+	defer func() { color.NoColor = orig }()
+
+	assert.NoError(t, applyColorMode("always"))
+	assert.False(t, color.NoColor)
+	assert.NoError(t, applyColorMode("never"))
+	assert.True(t, color.NoColor)
+	t.Setenv("CLICOLOR_FORCE", "1")
+	assert.NoError(t, applyColorMode("auto"))
+	assert.False(t, color.NoColor, "auto honors CLICOLOR_FORCE even under go test's pipes")
+	assert.Error(t, applyColorMode("sometimes"))
+}
+
+func TestAutoColorEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		tty  bool
+		want bool
+	}{
+		{"terminal", nil, true, true},
+		{"pipe", nil, false, false},
+		{"NO_COLOR on a terminal", map[string]string{"NO_COLOR": "1"}, true, false},
+		{"CLICOLOR_FORCE in a pipe", map[string]string{"CLICOLOR_FORCE": "1"}, false, true},
+		{"CLICOLOR_FORCE=0 is not forcing", map[string]string{"CLICOLOR_FORCE": "0"}, false, false},
+		{"NO_COLOR beats CLICOLOR_FORCE", map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, false, false},
+		{"CLICOLOR=0 on a terminal", map[string]string{"CLICOLOR": "0"}, true, false},
+		{"CLICOLOR=1 still needs a terminal", map[string]string{"CLICOLOR": "1"}, false, false},
+		{"dumb terminal", map[string]string{"TERM": "dumb"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			assert.Equal(t, tt.want, autoColorEnabled(getenv, tt.tty))
+		})
+	}
+}
+
+func TestLoadGTConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	got, err := loadGTConfig(filepath.Join(dir, "missing.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, gtConfig{}, got)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeConfigFile(t, path, "theme:\n  name: dracula\n  port: hi-red\n")
+	got, err = loadGTConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, themeConfig{Name: "dracula", Port: "hi-red"}, got.Theme)
+
+	writeConfigFile(t, path, "theme:\n  nmae: dracula\n")
+	_, err = loadGTConfig(path)
+	assert.Error(t, err, "unknown keys are rejected so typos surface")
+
+	empty := filepath.Join(dir, "empty.yaml")
+	writeConfigFile(t, empty, "")
+	_, err = loadGTConfig(empty)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.Chmod(path, 0o666))
+	_, err = loadGTConfig(path)
+	assert.Error(t, err, "group/world writable config is refused")
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	topInterval time.Duration
+	topSort     string
+)
+
+// topSortKeys are the columns gt top can sort by, in the order the s key
+// cycles through them.
+var topSortKeys = []string{"load", "mem", "disk", "host"}
+
+// loadRatio is the 1-minute load average per CPU, or -1 when unknown.
+func (h hostInfo) loadRatio() float64 {
+	f := strings.Fields(h.load)
+	if len(f) == 0 {
+		return -1
+	}
+	load, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return -1
+	}
+	cpus := h.cpus
+	if cpus < 1 {
+		cpus = 1
+	}
+	return load / float64(cpus)
+}
+
+// usageRatio is used/total, or -1 when unknown.
+func usageRatio(used, total uint64) float64 {
+	if total == 0 {
+		return -1
+	}
+	return float64(used) / float64(total)
+}
+
+// sortInfos orders hosts by key, busiest first (or by name for "host").
+// Hosts that failed to answer always go last, so the table does not
+// reshuffle around them.
+func sortInfos(infos []hostInfo, key string, reverse bool) {
+	metric := func(h hostInfo) float64 {
+		switch key {
+		case "mem":
+			return usageRatio(h.memUsed, h.memTotal)
+		case "disk":
+			return usageRatio(h.diskUsed, h.diskTotal)
+		}
+		return h.loadRatio()
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if (a.err == nil) != (b.err == nil) {
+			return a.err == nil
+		}
+		less := a.alias < b.alias
+		if key != "host" {
+			if ma, mb := metric(a), metric(b); ma != mb {
+				less = ma > mb
+			}
+		}
+		if reverse {
+			return !less
+		}
+		return less
+	})
+}
+
+// thresholdColor flags a ratio: error color from 90%, warning from 75%.
+func thresholdColor(ratio float64) *color.Color {
+	switch {
+	case ratio >= 0.9:
+		return errorColor
+	case ratio >= 0.75:
+		return warningColor
+	}
+	return color.New()
+}
+
+// usageBar renders a ratio as a 10-cell bar followed by its percentage.
+func usageBar(ratio float64) string {
+	if ratio < 0 {
+		return strings.Repeat(" ", 16)
+	}
+	filled := int(ratio*10 + 0.5)
+	if filled > 10 {
+		filled = 10
+	}
+	return fmt.Sprintf("%s%s %4.0f%%", strings.Repeat("█", filled), strings.Repeat("░", 10-filled), ratio*100)
+}
+
+// renderTop draws one frame of the dashboard.
+func renderTop(w io.Writer, title string, infos []hostInfo, key string, reverse bool, updated time.Time) {
+	order := "desc"
+	if reverse {
+		order = "asc"
+	}
+	aliasColor.Fprint(w, title)
+	symbolColor.Fprintf(w, "  sorted by %s (%s), updated %s\n\n", key, order, updated.Format("15:04:05"))
+
+	hostWidth := len("HOST")
+	for _, h := range infos {
+		if n := displayWidth(h.alias); n > hostWidth {
+			hostWidth = n
+		}
+	}
+	pad := func(s string, n int) string { return s + strings.Repeat(" ", n-displayWidth(s)) }
+
+	symbolColor.Fprintf(w, "%s  %-16s  %-16s  %-16s  %s\n", pad("HOST", hostWidth), "LOAD", "MEMORY", "DISK /", "UPTIME")
+	for _, h := range infos {
+		aliasColor.Fprint(w, pad(h.alias, hostWidth))
+		fmt.Fprint(w, "  ")
+		if h.err != nil {
+			errorColor.Fprintln(w, firstLine(h.err.Error()))
+			continue
+		}
+		thresholdColor(h.loadRatio()).Fprintf(w, "%-16s", h.load)
+		fmt.Fprint(w, "  ")
+		mem := usageRatio(h.memUsed, h.memTotal)
+		thresholdColor(mem).Fprint(w, usageBar(mem))
+		fmt.Fprint(w, "  ")
+		disk := usageRatio(h.diskUsed, h.diskTotal)
+		thresholdColor(disk).Fprint(w, usageBar(disk))
+		fmt.Fprint(w, "  ")
+		portColor.Fprintln(w, formatUptime(h.uptime))
+	}
+	symbolColor.Fprint(w, "\ns sort · r reverse · q quit\n")
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// topMux is the ssh options that keep one connection per host open for
+// the whole session, so each poll is a cheap multiplexed channel rather
+// than a new handshake. ControlPersist bounds how long a master outlives
+// a gt that was killed before it could close them.
+func topMux() ([]string, error) {
+	dir, err := ensureRuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(dir, "t-%C"),
+		"-o", "ControlPersist=60",
+	}, nil
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top <@group|alias>",
+	Short: "Live load, memory and disk dashboard for a group of hosts",
+	Long: `Poll every host in the group and redraw a table of load, memory and
+root filesystem usage until you press q. Each host keeps one persistent
+(ControlMaster) connection for the session, so polling is cheap; hosts
+that cannot connect without a prompt show their error instead.
+
+Values turn the warning color from 75% and the error color from 90%;
+load is measured per CPU. Press s to cycle the sort column (load, mem,
+disk, host) and r to reverse it.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTargets,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		aliases, err := expandTarget(args[0])
+		if err != nil {
+			return err
+		}
+		key := -1
+		for i, k := range topSortKeys {
+			if k == topSort {
+				key = i
+			}
+		}
+		if key < 0 {
+			return fmt.Errorf("invalid --sort %q (want %s)", topSort, strings.Join(topSortKeys, ", "))
+		}
+		if topInterval < time.Second {
+			return errors.New("--interval must be at least 1s")
+		}
+		fd := int(os.Stdin.Fd())
+		if nonInteractive() || !term.IsTerminal(fd) || !stdoutIsTerminal() {
+			return errors.New("gt top needs a terminal")
+		}
+
+		// One session holds every host for as long as gt top runs, so
+		// each host's connect hooks run once, around all of it.
+		return withEachHooks(hookPreConnect, hookPostConnect, aliases, func() error {
+			return runTop(args[0], aliases, key)
+		})
+	},
+}
+
+// runTop draws gt top for aliases, the hosts of target, sorted by
+// topSortKeys[key], until the user quits.
+func runTop(target string, aliases []string, key int) error {
+	fd := int(os.Stdin.Fd())
+	var err error
+	opts := remoteOpts{batch: true}
+	if !usePuTTY() {
+		if opts.sshOptions, err = topMux(); err != nil {
+			return err
+		}
+		// Registered for signals too: the masters would otherwise
+		// linger for ControlPersist after gt is gone.
+		defer onCleanup(func() {
+			for _, alias := range aliases {
+				exit := append(sshBaseArgs(), opts.sshOptions...)
+				runQuiet(sshCommand(append(exit, "-O", "exit", "--", alias)...))
+			}
+		})()
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+	defer onCleanup(func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(fd, state)
+	})()
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+				close(keys)
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+
+	results := make(chan []hostInfo, 1)
+	poll := func() { results <- gatherInfo(aliases, "", opts) }
+	start := time.Now()
+	defer func() {
+		for _, alias := range aliases {
+			logConnection(alias, "top", start, nil)
+		}
+	}()
+
+	go poll()
+	var infos []hostInfo
+	var updated time.Time
+	reverse := false
+	ticker := time.NewTicker(topInterval)
+	defer ticker.Stop()
+	draw := func() {
+		var frame bytes.Buffer
+		if infos == nil {
+			symbolColor.Fprintf(&frame, "Connecting to %d hosts…\n", len(aliases))
+		} else {
+			sortInfos(infos, topSortKeys[key], reverse)
+			renderTop(&frame, target, infos, topSortKeys[key], reverse, updated)
+		}
+		// Raw mode turns off newline translation.
+		os.Stdout.WriteString("\x1b[H\x1b[2J" + strings.ReplaceAll(frame.String(), "\n", "\r\n"))
+	}
+	draw()
+	polling := true
+	for {
+		select {
+		case b, ok := <-keys:
+			switch {
+			case !ok, b == 'q', b == 3, b == 4: // q, Ctrl-C, Ctrl-D
+				return nil
+			case b == 's':
+				key = (key + 1) % len(topSortKeys)
+			case b == 'r':
+				reverse = !reverse
+			}
+			draw()
+		case infos = <-results:
+			updated = time.Now()
+			polling = false
+			draw()
+		case <-ticker.C:
+			// Skip a tick rather than pile up polls behind a slow host.
+			if !polling {
+				polling = true
+				go poll()
+			}
+		}
+	}
+}
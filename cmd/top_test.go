@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRatio(t *testing.T) {
+	assert.Equal(t, 0.5, hostInfo{load: "2.00 1.00 0.50", cpus: 4}.loadRatio())
+	assert.Equal(t, 2.0, hostInfo{load: "2.00 1.00 0.50"}.loadRatio(), "unknown CPU count counts as one")
+	assert.Equal(t, -1.0, hostInfo{}.loadRatio())
+}
+
+func TestSortInfos(t *testing.T) {
+	infos := func() []hostInfo {
+		return []hostInfo{
+			{alias: "a", load: "0.10", cpus: 1, memUsed: 9, memTotal: 10},
+			{alias: "down", err: errors.New("timeout")},
+			{alias: "c", load: "3.00", cpus: 4, memUsed: 1, memTotal: 10},
+			{alias: "b", load: "0.90", cpus: 1},
+		}
+	}
+	aliases := func(hs []hostInfo) []string {
+		var out []string
+		for _, h := range hs {
+			out = append(out, h.alias)
+		}
+		return out
+	}
+
+	tests := []struct {
+		key     string
+		reverse bool
+		want    []string
+	}{
+		{"load", false, []string{"b", "c", "a", "down"}},
+		{"load", true, []string{"a", "c", "b", "down"}},
+		{"mem", false, []string{"a", "c", "b", "down"}},
+		{"host", false, []string{"a", "b", "c", "down"}},
+		{"host", true, []string{"c", "b", "a", "down"}},
+	}
+	for _, tt := range tests {
+		hs := infos()
+		sortInfos(hs, tt.key, tt.reverse)
+		assert.Equal(t, tt.want, aliases(hs), "%s reverse=%v", tt.key, tt.reverse)
+	}
+}
+
+func TestThresholdColor(t *testing.T) {
+	assert.Same(t, errorColor, thresholdColor(0.95))
+	assert.Same(t, warningColor, thresholdColor(0.8))
+	assert.NotSame(t, warningColor, thresholdColor(0.5))
+}
+
+func TestUsageBar(t *testing.T) {
+	assert.Equal(t, "█████░░░░░   50%", usageBar(0.5))
+	assert.Equal(t, "██████████  120%", usageBar(1.2), "the bar caps, the figure does not")
+	assert.Equal(t, "                ", usageBar(-1))
+}
+
+func TestRenderTop(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	renderTop(&out, "@web", []hostInfo{
+		{alias: "web-1", load: "0.50 0.40 0.30", cpus: 2, memUsed: 1, memTotal: 4, uptime: 3 * time.Hour},
+		{alias: "web-22", err: errors.New("web-22: exit status 255: Permission denied\nmore")},
+	}, "load", false, time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC))
+
+	assert.Equal(t, `@web  sorted by load (desc), updated 09:30:00
+
+HOST    LOAD              MEMORY            DISK /            UPTIME
+web-1   0.50 0.40 0.30    ███░░░░░░░   25%                    3h 0m
+web-22  web-22: exit status 255: Permission denied
+
+s sort · r reverse · q quit
+`, out.String())
+}
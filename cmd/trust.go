@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gt/pkg/sshconf"
+	"gt/pkg/transport"
+)
+
+// Trust levels a host can carry in gt's config. normal is the default
+// and gates nothing; trusted says so for people reading gt list.
+const (
+	trustTrusted   = "trusted"
+	trustNormal    = "normal"
+	trustUntrusted = "untrusted"
+)
+
+// allowUntrusted lets root-needing operations run on untrusted hosts.
+var allowUntrusted bool
+
+// untrustedOverrides go ahead of every other -o for an untrusted host.
+// ssh keeps the first value it gets for an option, so they win over the
+// command line and the SSH config alike.
+var untrustedOverrides = []string{"ForwardAgent=no", "ForwardX11=no", "ForwardX11Trusted=no", "GSSAPIDelegateCredentials=no"}
+
+// validateTrust checks a host's trust level.
+func validateTrust(alias, trust string) error {
+	switch trust {
+	case "", trustTrusted, trustNormal, trustUntrusted:
+		return nil
+	}
+	return fmt.Errorf("host %s: unknown trust %q (want trusted, normal or untrusted)", alias, trust)
+}
+
+// untrusted reports whether alias is marked untrusted.
+func untrusted(alias string) bool {
+	return hostMetaFor(alias).Trust == trustUntrusted
+}
+
+// riskyOverride reports whether a -o KEY=VALUE, or KEY VALUE, hands an
+// untrusted host something it must not get: the agent, the X display, a
+// Kerberos ticket, or a connection that ignores its host key.
+func riskyOverride(opt string) bool {
+	key, value, _ := cutOverride(opt)
+	if fields := sshconf.Fields(value); len(fields) > 0 {
+		value = strings.ToLower(fields[0])
+	}
+	switch strings.ToLower(key) {
+	case "forwardagent", "forwardx11", "forwardx11trusted", "gssapidelegatecredentials":
+		return value != "no"
+	case "stricthostkeychecking":
+		return value == "no" || value == "off"
+	case "userknownhostsfile", "globalknownhostsfile":
+		return value == "/dev/null" || strings.EqualFold(value, "nul")
+	}
+	return false
+}
+
+// trustWarned keeps the warning about dropped -o options to once per host,
+// however many ssh runs a command makes.
+var trustWarned sync.Map
+
+// trustOptions adjusts o for connecting to alias: for an untrusted host,
+// the forwarding is turned off ahead of everything else, and -o options
+// that would turn it back on or skip the host key check are dropped.
+func trustOptions(o transport.Options, alias string) transport.Options {
+	if !untrusted(alias) {
+		return o
+	}
+	overrides := append([]string(nil), untrustedOverrides...)
+	for _, opt := range o.Overrides {
+		if riskyOverride(opt) {
+			if _, warned := trustWarned.LoadOrStore(alias+"\x00"+opt, true); !warned {
+				warningColor.Fprintf(os.Stderr, "%s is untrusted: ignoring -o %s\n", alias, opt)
+			}
+			continue
+		}
+		overrides = append(overrides, opt)
+	}
+	o.Overrides = overrides
+	return o
+}
+
+// refuseUntrusted stops what, an operation run as root, on any untrusted
+// host among aliases, unless --allow-untrusted says otherwise.
+func refuseUntrusted(aliases []string, what string) error {
+	if allowUntrusted {
+		return nil
+	}
+	var refused []string
+	for _, alias := range aliases {
+		if untrusted(alias) {
+			refused = append(refused, alias)
+		}
+	}
+	if len(refused) == 0 {
+		return nil
+	}
+	return fmt.Errorf("refusing to %s on untrusted host(s) %s; pass --allow-untrusted to anyway", what, strings.Join(refused, ", "))
+}
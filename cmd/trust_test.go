@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gt/pkg/transport"
+)
+
+func TestRiskyOverride(t *testing.T) {
+	for opt, want := range map[string]bool{
+		"ForwardAgent=yes":                 true,
+		"forwardagent=~/.ssh/agent.sock":   true,
+		"ForwardAgent=no":                  false,
+		"ForwardX11=yes":                   true,
+		"GSSAPIDelegateCredentials=yes":    true,
+		"StrictHostKeyChecking=no":         true,
+		"StrictHostKeyChecking=accept-new": false,
+		"UserKnownHostsFile=/dev/null":     true,
+		"UserKnownHostsFile=~/.ssh/lab":    false,
+		"ServerAliveInterval=30":           false,
+		"StrictHostKeyChecking no":         true,
+		"UserKnownHostsFile /dev/null":     true,
+		"UserKnownHostsFile \"/dev/null\"": true,
+		"ForwardAgent\tyes":                true,
+		"ForwardAgent = yes":               true,
+		"  ForwardX11 yes":                 true,
+		"ForwardAgent no":                  false,
+		"StrictHostKeyChecking yes":        false,
+	} {
+		assert.Equal(t, want, riskyOverride(opt), opt)
+	}
+}
+
+func TestTrustOptions(t *testing.T) {
+	useWebGroup(t)
+	plainOutput(t)
+	gtCfg.Hosts["down"] = hostMeta{Trust: trustUntrusted}
+	o := transport.Options{Overrides: []string{"ForwardAgent=yes", "StrictHostKeyChecking=no", "ServerAliveInterval=30"}}
+
+	assert.Equal(t, o, trustOptions(o, "web-1"))
+	got := trustOptions(o, "down")
+	assert.Equal(t, []string{"ForwardAgent=no", "ForwardX11=no", "ForwardX11Trusted=no", "GSSAPIDelegateCredentials=no", "ServerAliveInterval=30"}, got.Overrides)
+	assert.Len(t, o.Overrides, 3, "the caller's options are left alone")
+}
+
+func TestRunSSHUntrusted(t *testing.T) {
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	useMockExec(t)
+	useWebGroup(t)
+	gtCfg.Hosts["testserver"] = hostMeta{Trust: trustUntrusted}
+
+	require.NoError(t, runSSH("testserver", nil))
+	assert.Equal(t, []string{"-o", "ForwardAgent=no", "-o", "ForwardX11=no", "-o", "ForwardX11Trusted=no", "-o", "GSSAPIDelegateCredentials=no", "--", "testserver"}, mockRun("ssh"))
+}
+
+func TestRefuseUntrusted(t *testing.T) {
+	useMockExec(t)
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	plainOutput(t)
+	useWebGroup(t)
+	gtCfg.Hosts["down"] = hostMeta{Groups: []string{"web"}, Trust: trustUntrusted}
+	t.Cleanup(func() { execSudo, allowUntrusted = false, false })
+	var out bytes.Buffer
+	execCmd.SetOut(&out)
+	t.Cleanup(func() { execCmd.SetOut(nil) })
+
+	execSudo = true
+	err := execCmd.RunE(execCmd, []string{"@web", "apt-get", "update"})
+	assert.ErrorContains(t, err, "untrusted host(s) down; pass --allow-untrusted")
+	assert.Empty(t, mockCmd.commands)
+	assert.ErrorContains(t, svcCmd.RunE(svcCmd, []string{"down", "restart", "nginx"}), "refusing to restart nginx")
+	assert.ErrorContains(t, pkgCmd.RunE(pkgCmd, []string{"@web", "upgrade"}), "refusing to run the package manager")
+	assert.ErrorContains(t, rebootCmd.RunE(rebootCmd, []string{"down"}), "refusing to reboot")
+
+	allowUntrusted = true
+	err = execCmd.RunE(execCmd, []string{"web-1", "apt-get", "update"})
+	require.NoError(t, err)
+	args := mockRun("ssh")
+	assert.Contains(t, args[len(args)-1], "sudo -n")
+	assert.Contains(t, args[len(args)-1], "$sudo apt-get update")
+}
+
+func TestValidateTrust(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, "hosts:\n  web:\n    trust: untrusted\n")
+	c, err := loadGTConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, trustUntrusted, c.Hosts["web"].Trust)
+
+	writeConfigFile(t, path, "hosts:\n  web:\n    trust: sketchy\n")
+	_, err = loadGTConfig(path)
+	assert.ErrorContains(t, err, `host web: unknown trust "sketchy"`)
+
+	_, err = parseTeamInventory([]byte("hosts:\n  db:\n    hostname: db\n    trust: trusted\n"))
+	assert.ErrorContains(t, err, "may not set trust", "a team inventory cannot vouch for its hosts")
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"gt/pkg/transport"
+)
+
+// checkTZ is --check-tz, as a host's check_tz setting is for that host.
+var checkTZ bool
+
+// hintColor is for lines that are by the way, such as a timezone that is
+// not the local one.
+var hintColor = color.New(color.Faint)
+
+// checkTZEnabled reports whether a login to alias compares its timezone
+// and locale with the local ones.
+func checkTZEnabled(alias string) bool {
+	return checkTZ || hostMetaFor(alias).CheckTZ
+}
+
+// clockLocaleScript prints the host's UTC offset, zone abbreviation and
+// zone name, and the system locale: what cron jobs and daemons get, not
+// the one ssh may have passed along with SendEnv.
+const clockLocaleScript = `echo "offset=$(date +%z)"
+echo "abbrev=$(date +%Z)"
+z=$(readlink /etc/localtime 2>/dev/null); echo "zone=${z##*zoneinfo/}"
+l=
+for f in /etc/locale.conf /etc/default/locale; do
+	[ -r "$f" ] && l=$(sed -n 's/^LANG=//p' "$f" | tr -d '"') && [ -n "$l" ] && break
+done
+echo "locale=${l:-$LANG}"
+`
+
+// clockLocale is a machine's timezone and locale.
+type clockLocale struct {
+	offset, abbrev, zone, locale string
+}
+
+// parseClockLocale reads clockLocaleScript's output.
+func parseClockLocale(out []byte) clockLocale {
+	var c clockLocale
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "offset":
+			c.offset = value
+		case "abbrev":
+			c.abbrev = value
+		case "zone":
+			c.zone = value
+		case "locale":
+			c.locale = value
+		}
+	}
+	return c
+}
+
+// localClockLocale is this machine's timezone and locale, as now and the
+// environment give them.
+func localClockLocale(now time.Time) clockLocale {
+	c := clockLocale{offset: now.Format("-0700"), zone: os.Getenv("TZ")}
+	c.abbrev, _ = now.Zone()
+	if c.zone == "" {
+		if z, err := os.Readlink("/etc/localtime"); err == nil {
+			if _, name, ok := strings.Cut(z, "zoneinfo/"); ok {
+				c.zone = name
+			}
+		}
+	}
+	for _, key := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			c.locale = v
+			break
+		}
+	}
+	return c
+}
+
+// describeZone names a timezone with its offset, e.g. "Europe/Berlin
+// (+0200)".
+func (c clockLocale) describeZone() string {
+	name := c.zone
+	if name == "" {
+		name = c.abbrev
+	}
+	if name == "" {
+		return c.offset
+	}
+	return name + " (" + c.offset + ")"
+}
+
+// sameLocale compares locales the way glibc matches them, ignoring the
+// case and punctuation of the codeset: en_US.UTF-8 is en_US.utf8.
+func sameLocale(a, b string) bool {
+	norm := func(s string) string {
+		return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(s))
+	}
+	return norm(a) == norm(b)
+}
+
+// clockLocaleHint is the line that tells where alias's timezone or
+// locale is not the local one, or "" when both agree. Zones are compared
+// by offset, so UTC and Etc/UTC agree; a side with no locale is not
+// compared.
+func clockLocaleHint(alias string, here, there clockLocale) string {
+	var parts []string
+	if there.offset != "" && here.offset != there.offset {
+		parts = append(parts, fmt.Sprintf("timezone %s, here %s", there.describeZone(), here.describeZone()))
+	}
+	if here.locale != "" && there.locale != "" && !sameLocale(here.locale, there.locale) {
+		parts = append(parts, fmt.Sprintf("locale %s, here %s", there.locale, here.locale))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return alias + ": " + strings.Join(parts, "; ")
+}
+
+// checkClockLocale prints the hint, if any, for alias, reading the host's
+// side over the ControlMaster that mux sets up.
+func checkClockLocale(alias string, opts transport.Options, mux []string) error {
+	out, err := preLoginRun(alias, opts, mux, clockLocaleScript)
+	if err != nil {
+		return err
+	}
+	if hint := clockLocaleHint(alias, localClockLocale(time.Now()), parseClockLocale(out)); hint != "" {
+		hintColor.Fprintln(os.Stderr, hint)
+	}
+	return nil
+}
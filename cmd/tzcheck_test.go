@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClockLocale(t *testing.T) {
+	c := parseClockLocale([]byte("offset=+0900\nabbrev=JST\nzone=Asia/Tokyo\nlocale=ja_JP.UTF-8\n"))
+	assert.Equal(t, clockLocale{offset: "+0900", abbrev: "JST", zone: "Asia/Tokyo", locale: "ja_JP.UTF-8"}, c)
+	assert.Equal(t, "Asia/Tokyo (+0900)", c.describeZone())
+	assert.Equal(t, "UTC (+0000)", clockLocale{offset: "+0000", abbrev: "UTC"}.describeZone())
+}
+
+func TestLocalClockLocale(t *testing.T) {
+	t.Setenv("TZ", "Europe/Berlin")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_TIME", "")
+	t.Setenv("LANG", "de_DE.UTF-8")
+	berlin := time.FixedZone("CEST", 2*60*60)
+	c := localClockLocale(time.Date(2026, 7, 1, 12, 0, 0, 0, berlin))
+	assert.Equal(t, clockLocale{offset: "+0200", abbrev: "CEST", zone: "Europe/Berlin", locale: "de_DE.UTF-8"}, c)
+}
+
+func TestClockLocaleHint(t *testing.T) {
+	here := clockLocale{offset: "+0200", abbrev: "CEST", zone: "Europe/Berlin", locale: "de_DE.UTF-8"}
+	assert.Equal(t, "", clockLocaleHint("web1", here, here))
+	assert.Equal(t, "", clockLocaleHint("web1", here, clockLocale{offset: "+0200", abbrev: "CEST", locale: "de_DE.utf8"}),
+		"the same offset under another name, and the same locale spelled another way")
+	assert.Equal(t, "web1: timezone Etc/UTC (+0000), here Europe/Berlin (+0200)",
+		clockLocaleHint("web1", here, clockLocale{offset: "+0000", abbrev: "UTC", zone: "Etc/UTC", locale: "de_DE.UTF-8"}))
+	assert.Equal(t, "web1: timezone Asia/Tokyo (+0900), here Europe/Berlin (+0200); locale C.UTF-8, here de_DE.UTF-8",
+		clockLocaleHint("web1", here, clockLocale{offset: "+0900", zone: "Asia/Tokyo", locale: "C.UTF-8"}))
+	assert.Equal(t, "", clockLocaleHint("web1", clockLocale{offset: "+0000"}, clockLocale{offset: "+0000", locale: "C.UTF-8"}),
+		"no local locale, nothing to compare")
+}
+
+func TestRunSSHCheckTZ(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no ControlMaster on Windows")
+	}
+	t.Setenv("GT_LOG_DIR", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Cleanup(removeRuntimeDir)
+	useMockExec(t)
+	useWebGroup(t)
+	t.Cleanup(func() { checkTZ = false })
+	gtCfg.Hosts["web-1"] = hostMeta{CheckTZ: true}
+
+	sshRuns := func() [][]string {
+		var runs [][]string
+		for i, c := range mockCmd.commands {
+			if c == "ssh" && mockCmd.argLists[i][0] != "-G" {
+				runs = append(runs, mockCmd.argLists[i])
+			}
+		}
+		return runs
+	}
+
+	require.NoError(t, runSSH("web-1", nil))
+	runs := sshRuns()
+	require.Len(t, runs, 2, "the check, then the login")
+	check, login := strings.Join(runs[0], " "), strings.Join(runs[1], " ")
+	assert.Contains(t, check, "-T -- web-1 ")
+	assert.Contains(t, check, "offset=$(date +%z)")
+	assert.Contains(t, login, "-o ControlMaster=auto -o ControlPath=")
+	assert.True(t, strings.HasSuffix(login, "-o ControlPersist=10 -- web-1"), "the login rides the check's connection")
+
+	mockCmd.reset()
+	require.NoError(t, runSSH("web-1", []string{"uptime"}))
+	assert.Equal(t, []string{"--", "web-1", "uptime"}, mockRun("ssh"), "only logins are checked")
+
+	mockCmd.reset()
+	checkTZ = true
+	err := runSSH("down", nil)
+	assert.Equal(t, exitConnection, ExitCode(err))
+	assert.Len(t, sshRuns(), 1, "no login after the check failed to connect")
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set by the release build:
+//
+//	go build -ldflags "-X gt/cmd.version=v1.2.3 -X gt/cmd.commit=$(git rev-parse HEAD) -X gt/cmd.buildDate=$(date -u +%FT%TZ)"
+//
+// Builds without them fall back to what the Go toolchain records (the
+// module version for go install, VCS details for a checkout).
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+var versionCheck bool
+
+// releasesURL is the GitHub API endpoint for gt's latest release,
+// swappable in tests.
+var releasesURL = "https://api.github.com/repos/pders01/gt/releases/latest"
+
+// buildInfo is the version metadata of the running binary.
+type buildInfo struct {
+	Version   string
+	Commit    string
+	BuildDate string
+	GoVersion string
+	Dirty     bool
+}
+
+// currentBuild combines the ldflags values with the toolchain's build
+// info; ldflags win where both are set.
+func currentBuild() buildInfo {
+	b := buildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if b.Version == "" && info.Main.Version != "(devel)" {
+			b.Version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if b.Commit == "" {
+					b.Commit = s.Value
+				}
+			case "vcs.time":
+				if b.BuildDate == "" {
+					b.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				b.Dirty = s.Value == "true"
+			}
+		}
+	}
+	if b.Version == "" {
+		b.Version = "dev"
+	}
+	return b
+}
+
+func (b buildInfo) write(w io.Writer) {
+	fmt.Fprintf(w, "gt %s\n", b.Version)
+	if b.Commit != "" {
+		c := b.Commit
+		if b.Dirty {
+			c += " (modified)"
+		}
+		fmt.Fprintf(w, "  commit:  %s\n", c)
+	}
+	if b.BuildDate != "" {
+		fmt.Fprintf(w, "  built:   %s\n", b.BuildDate)
+	}
+	fmt.Fprintf(w, "  go:      %s %s/%s\n", b.GoVersion, runtime.GOOS, runtime.GOARCH)
+}
+
+// release is the part of GitHub's release object gt uses.
+type release struct {
+	Tag string `json:"tag_name"`
+	URL string `json:"html_url"`
+}
+
+// latestRelease asks GitHub for gt's newest release.
+func latestRelease() (release, error) {
+	var r release
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest(http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return r, fmt.Errorf("checking for updates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("checking for updates: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return r, fmt.Errorf("checking for updates: %w", err)
+	}
+	if r.Tag == "" {
+		return r, errors.New("checking for updates: release has no tag")
+	}
+	return r, nil
+}
+
+// parseVersion reads vMAJOR.MINOR.PATCH, ignoring any pre-release or
+// build suffix. ok is false for anything else, such as "dev".
+func parseVersion(v string) (parts [3]int, ok bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// newerVersion reports whether latest is a later release than current.
+// ok is false when either cannot be compared.
+func newerVersion(current, latest string) (newer, ok bool) {
+	c, okC := parseVersion(current)
+	l, okL := parseVersion(latest)
+	if !okC || !okL {
+		return false, false
+	}
+	for i := range c {
+		if l[i] != c[i] {
+			return l[i] > c[i], true
+		}
+	}
+	return false, true
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show gt's version and build details",
+	Long: `Show gt's version, the commit and date it was built from, and the Go
+version and platform. With --check, also ask GitHub whether a newer
+release exists; nothing is sent but the request itself.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b := currentBuild()
+		b.write(cmd.OutOrStdout())
+		if !versionCheck {
+			return nil
+		}
+		r, err := latestRelease()
+		if err != nil {
+			return err
+		}
+		newer, ok := newerVersion(b.Version, r.Tag)
+		switch {
+		case !ok:
+			warningColor.Fprintf(cmd.OutOrStdout(), "Latest release is %s; cannot compare with %s\n", r.Tag, b.Version)
+		case newer:
+			warningColor.Fprintf(cmd.OutOrStdout(), "A newer gt is available: %s\n", r.Tag)
+			if r.URL != "" {
+				symbolColor.Fprintf(cmd.OutOrStdout(), "  %s\n", r.URL)
+			}
+		default:
+			userColor.Fprintln(cmd.OutOrStdout(), "gt is up to date")
+		}
+		return nil
+	},
+}
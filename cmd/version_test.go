@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		newer, ok       bool
+	}{
+		{"v1.2.3", "v1.2.4", true, true},
+		{"v1.2.3", "v1.10.0", true, true},
+		{"v1.2.3", "v1.2.3", false, true},
+		{"v2.0.0", "v1.9.9", false, true},
+		{"1.2.3", "v1.3.0", true, true},
+		{"v1.2.3-rc1", "v1.2.3", false, true},
+		{"dev", "v1.0.0", false, false},
+		{"v1.2", "v1.3.0", false, false},
+	}
+	for _, tt := range tests {
+		newer, ok := newerVersion(tt.current, tt.latest)
+		assert.Equal(t, tt.newer, newer, "%s -> %s", tt.current, tt.latest)
+		assert.Equal(t, tt.ok, ok, "%s -> %s", tt.current, tt.latest)
+	}
+}
+
+func TestCurrentBuildPrefersLdflags(t *testing.T) {
+	origV, origC, origD := version, commit, buildDate
+	t.Cleanup(func() { version, commit, buildDate = origV, origC, origD })
+	version, commit, buildDate = "v1.4.0", "abc123", "2026-01-02T03:04:05Z"
+
+	b := currentBuild()
+	assert.Equal(t, "v1.4.0", b.Version)
+	assert.Equal(t, "abc123", b.Commit)
+	assert.Equal(t, "2026-01-02T03:04:05Z", b.BuildDate)
+
+	var out bytes.Buffer
+	b.write(&out)
+	assert.Contains(t, out.String(), "gt v1.4.0\n")
+	assert.Contains(t, out.String(), "commit:  abc123")
+	assert.Contains(t, out.String(), "go:      go")
+}
+
+func TestVersionCheck(t *testing.T) {
+	plainOutput(t)
+	origV, origURL, origCheck := version, releasesURL, versionCheck
+	t.Cleanup(func() { version, releasesURL, versionCheck = origV, origURL, origCheck })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v1.5.0", "html_url": "https://github.com/pders01/gt/releases/tag/v1.5.0"}`))
+	}))
+	defer srv.Close()
+	releasesURL = srv.URL
+	versionCheck = true
+
+	run := func() string {
+		var out bytes.Buffer
+		versionCmd.SetOut(&out)
+		t.Cleanup(func() { versionCmd.SetOut(nil) })
+		assert.NoError(t, versionCmd.RunE(versionCmd, nil))
+		return out.String()
+	}
+
+	version = "v1.4.0"
+	out := run()
+	assert.Contains(t, out, "A newer gt is available: v1.5.0")
+	assert.Contains(t, out, "releases/tag/v1.5.0")
+
+	version = "v1.5.0"
+	assert.Contains(t, run(), "gt is up to date")
+
+	version = "dev"
+	assert.Contains(t, run(), "cannot compare with dev")
+}
+
+func TestVersionCheckReportsHTTPErrors(t *testing.T) {
+	origURL := releasesURL
+	t.Cleanup(func() { releasesURL = origURL })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	releasesURL = srv.URL
+
+	_, err := latestRelease()
+	assert.ErrorContains(t, err, "403")
+}
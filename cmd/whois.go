@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gt/pkg/sshconf"
+)
+
+// whoisDNS is gt whois --dns.
+var whoisDNS bool
+
+// whoisMatch is an alias that an address gt whois looks up is.
+type whoisMatch struct {
+	alias, hostname string
+	// via is how it matched: "hostname", "fallback", or, with --dns,
+	// "dns <ip>" for an address both resolve to.
+	via string
+	// source is the file and line of the alias's Host line, "" when no
+	// file gt can read has one (an encrypted include, say).
+	source string
+}
+
+// normalizeAddress is an address as whois compares it: lower case, no
+// trailing dot, no brackets around an IPv6 address, and an IP address
+// in its canonical form.
+func normalizeAddress(s string) string {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+	return s
+}
+
+// addressIPs is what host resolves to, itself for an IP address.
+func addressIPs(host string) []string {
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ips, err := lookupIPs(ctx, host)
+	if err != nil {
+		debugf(1, "resolving %s: %v", host, err)
+		return nil
+	}
+	out := make([]string, len(ips))
+	for i, ip := range ips {
+		out[i] = ip.String()
+	}
+	return out
+}
+
+// whoisMatches finds the hosts of rows that query is: by resolved
+// HostName, or that name short of one of its CanonicalDomains, or by
+// fallback address, and with dns also by any address the query and a
+// host's HostName or fallbacks resolve to in common. The lookups run a
+// handful at a time, as resolveListRows's do.
+func whoisMatches(query string, rows []listRow, dns bool) []whoisMatch {
+	q := normalizeAddress(query)
+	matches := make([]*whoisMatch, len(rows))
+	for i, r := range rows {
+		if r.err != nil {
+			continue
+		}
+		if normalizeAddress(r.Hostname) == q || sshconf.ParseCanonicalization(r.opts).ShortFor(q, r.Hostname) {
+			matches[i] = &whoisMatch{alias: r.alias, hostname: r.Hostname, via: "hostname"}
+			continue
+		}
+		for _, fb := range hostMetaFor(r.alias).FallbackAddresses {
+			if normalizeAddress(fb) == q {
+				matches[i] = &whoisMatch{alias: r.alias, hostname: r.Hostname, via: "fallback"}
+				break
+			}
+		}
+	}
+	if dns {
+		wanted := map[string]bool{}
+		for _, ip := range addressIPs(q) {
+			wanted[ip] = true
+		}
+		sem := make(chan struct{}, 8)
+		var wg sync.WaitGroup
+		for i, r := range rows {
+			if r.err != nil || matches[i] != nil || len(wanted) == 0 {
+				continue
+			}
+			wg.Add(1)
+			go func(i int, r listRow) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				for _, host := range append([]string{r.Hostname}, hostMetaFor(r.alias).FallbackAddresses...) {
+					for _, ip := range addressIPs(normalizeAddress(host)) {
+						if wanted[ip] {
+							matches[i] = &whoisMatch{alias: r.alias, hostname: r.Hostname, via: "dns " + ip}
+							return
+						}
+					}
+				}
+			}(i, r)
+		}
+		wg.Wait()
+	}
+	var out []whoisMatch
+	for _, m := range matches {
+		if m != nil {
+			out = append(out, *m)
+		}
+	}
+	return out
+}
+
+// hostSources maps each alias to the file and line of the first Host
+// line that names it, reading files in the order ssh does.
+func hostSources(files []string) map[string]string {
+	sources := map[string]string{}
+	for _, file := range files {
+		e, err := readConfigEdit(file)
+		if err != nil {
+			debugf(1, "reading %s: %v", file, err)
+			continue
+		}
+		preamble, blocks := parseConfigBlocks(e.data)
+		line := 1 + strings.Count(preamble, "\n")
+		for _, b := range blocks {
+			hostLine := line
+			for _, l := range strings.SplitAfter(b.text, "\n") {
+				if sshconf.Keyword(l) == "host" {
+					break
+				}
+				hostLine++
+			}
+			for _, p := range b.patterns {
+				if _, ok := sources[p]; !ok && !strings.ContainsAny(p, "*?!") {
+					sources[p] = fmt.Sprintf("%s:%d", file, hostLine)
+				}
+			}
+			line += strings.Count(b.text, "\n")
+		}
+	}
+	return sources
+}
+
+// renderWhois writes matches as a table.
+func renderWhois(w io.Writer, matches []whoisMatch) {
+	widths := []int{len("ALIAS"), len("HOSTNAME"), len("MATCH")}
+	for _, m := range matches {
+		for i, s := range []string{m.alias, m.hostname, m.via} {
+			if n := displayWidth(s); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	pad := func(s string, n int) string { return s + strings.Repeat(" ", n-displayWidth(s)+2) }
+	symbolColor.Fprintf(w, "%s%s%s%s\n", pad("ALIAS", widths[0]), pad("HOSTNAME", widths[1]), pad("MATCH", widths[2]), "FILE")
+	for _, m := range matches {
+		aliasColor.Fprint(w, pad(m.alias, widths[0]))
+		domainColor.Fprint(w, pad(m.hostname, widths[1]))
+		if m.source == "" {
+			fmt.Fprintln(w, m.via)
+			continue
+		}
+		fmt.Fprint(w, pad(m.via, widths[2]))
+		fmt.Fprintln(w, m.source)
+	}
+}
+
+var whoisCmd = &cobra.Command{
+	Use:   "whois <hostname|IP>",
+	Short: "Find the alias for a hostname or IP address",
+	Long: `Find which alias an address from a log or an alert is: every host's
+HostName is resolved with ssh -G and compared with it, as are the
+fallback addresses in gt's config, and each match is listed with the
+file and line of its Host block.
+
+With --dns, names are looked up too, so an IP address finds the alias
+whose HostName resolves to it, and a hostname the alias whose HostName
+is another name for the same address. gt exits 68 when nothing matches.`,
+	Example: `  gt whois 203.0.113.5
+  gt whois app.example.com --dns`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		matches := whoisMatches(args[0], resolveListRows(getHosts()), whoisDNS)
+		if len(matches) == 0 {
+			hint := ""
+			if !whoisDNS {
+				hint = "; --dns also compares what names resolve to"
+			}
+			return withCode(exitHostNotFound, fmt.Errorf("no host in the SSH config is %s%s", args[0], hint))
+		}
+		sources := hostSources(loadedFiles)
+		for i := range matches {
+			matches[i].source = sources[matches[i].alias]
+		}
+		renderWhois(cmd.OutOrStdout(), matches)
+		return nil
+	},
+}
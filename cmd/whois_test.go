@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gt/pkg/sshconf"
+)
+
+func whoisRows(t *testing.T) []listRow {
+	t.Helper()
+	origGT, origLookup := gtCfg, lookupIPs
+	t.Cleanup(func() { gtCfg, lookupIPs = origGT, origLookup })
+	gtCfg = gtConfig{Hosts: map[string]hostMeta{"db": {FallbackAddresses: []string{"198.51.100.7"}}}}
+	lookupIPs = func(ctx context.Context, host string) ([]net.IP, error) {
+		switch host {
+		case "app.example.com", "www.example.com":
+			return []net.IP{net.ParseIP("203.0.113.5")}, nil
+		case "db.example.com":
+			return []net.IP{net.ParseIP("203.0.113.9")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	return []listRow{
+		{alias: "app", Resolved: sshconf.Resolved{Hostname: "app.example.com"}},
+		{alias: "db", Resolved: sshconf.Resolved{Hostname: "db.example.com"}},
+		{alias: "v6", Resolved: sshconf.Resolved{Hostname: "2001:DB8::1"}},
+		{alias: "down", err: errors.New("ssh -G down: exit status 255")},
+	}
+}
+
+func TestWhoisMatches(t *testing.T) {
+	rows := whoisRows(t)
+	assert.Equal(t, []whoisMatch{{alias: "app", hostname: "app.example.com", via: "hostname"}}, whoisMatches("APP.example.com.", rows, false))
+	assert.Equal(t, []whoisMatch{{alias: "db", hostname: "db.example.com", via: "fallback"}}, whoisMatches("198.51.100.7", rows, false))
+	assert.Equal(t, []whoisMatch{{alias: "v6", hostname: "2001:DB8::1", via: "hostname"}}, whoisMatches("[2001:db8:0::1]", rows, false))
+	assert.Empty(t, whoisMatches("203.0.113.5", rows, false), "addresses are only compared with --dns")
+
+	rows[1].opts = map[string][]string{"canonicaldomains": {"example.com"}}
+	assert.Equal(t, []whoisMatch{{alias: "db", hostname: "db.example.com", via: "hostname"}}, whoisMatches("db", rows, false),
+		"the name short of a CanonicalDomain")
+
+	assert.Equal(t, []whoisMatch{{alias: "app", hostname: "app.example.com", via: "dns 203.0.113.5"}}, whoisMatches("203.0.113.5", rows, true))
+	assert.Equal(t, []whoisMatch{{alias: "app", hostname: "app.example.com", via: "dns 203.0.113.5"}}, whoisMatches("www.example.com", rows, true),
+		"another name for the same address")
+	assert.Empty(t, whoisMatches("nowhere.example.com", rows, true))
+}
+
+func TestHostSources(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config")
+	include := filepath.Join(dir, "work.conf")
+	writeConfigFile(t, main, "Include work.conf\n\n# the web tier\nHost web1 web2\n  HostName web.example.com\n\nHost *\n  User me\n")
+	writeConfigFile(t, include, "Host db !web1\n  HostName db.example.com\nHost web1\n  User other\n")
+
+	assert.Equal(t, map[string]string{
+		"web1": main + ":4",
+		"web2": main + ":4",
+		"db":   include + ":1",
+	}, hostSources([]string{main, include, filepath.Join(dir, "missing")}))
+}
+
+func TestRenderWhois(t *testing.T) {
+	plainOutput(t)
+	var out bytes.Buffer
+	renderWhois(&out, []whoisMatch{
+		{alias: "app", hostname: "app.example.com", via: "dns 203.0.113.5", source: "/home/me/.ssh/config:12"},
+		{alias: "database", hostname: "db", via: "hostname"},
+	})
+	assert.Equal(t, "ALIAS     HOSTNAME         MATCH            FILE\n"+
+		"app       app.example.com  dns 203.0.113.5  /home/me/.ssh/config:12\n"+
+		"database  db               hostname\n", out.String())
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"unicode"
+)
+
+// wideRanges are the East Asian Wide and Fullwidth blocks (plus emoji
+// presentation blocks) that terminals render two columns wide. This is
+// the coarse block-level table, not the full UAX #11 data, which is
+// plenty for host aliases and hostnames.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x2E80, 0x303E},   // CJK radicals, Kangxi, CJK symbols and punctuation
+	{0x3041, 0x33FF},   // Hiragana, Katakana, Bopomofo, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK Unified Ideographs Extension A
+	{0x4E00, 0x9FFF},   // CJK Unified Ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F300, 0x1F64F}, // Misc symbols and pictographs, emoticons
+	{0x1F900, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x20000, 0x3FFFD}, // CJK Unified Ideographs Extensions B and beyond
+}
+
+// runeWidth returns how many terminal columns r occupies: 0 for
+// combining marks and format characters, 2 for wide ones, 1 otherwise.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, rg := range wideRanges {
+		if r < rg[0] {
+			break
+		}
+		if r <= rg[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// displayWidth measures s in terminal columns rather than bytes.
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// truncateWidth returns the longest prefix of s that fits in width
+// columns, never splitting a rune.
+func truncateWidth(s string, width int) string {
+	n := 0
+	for i, r := range s {
+		if n+runeWidth(r) > width {
+			return s[:i]
+		}
+		n += runeWidth(r)
+	}
+	return s
+}
+
+// terminalWidth returns stdout's width in columns, falling back to
+// $COLUMNS, or 0 when neither is known.
+func terminalWidth() int {
+	if w, _ := ttySize(os.Stdout); w > 0 {
+		return w
+	}
+	return envSize("COLUMNS")
+}
+
+// terminalHeight returns stdout's height in rows, falling back to
+// $LINES, or 0 when neither is known.
+func terminalHeight() int {
+	if _, h := ttySize(os.Stdout); h > 0 {
+		return h
+	}
+	return envSize("LINES")
+}
+
+func envSize(name string) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package cmd
+
+import "os"
+
+// ttySize has no portable implementation off unix; terminalWidth and
+// terminalHeight fall back to $COLUMNS and $LINES.
+func ttySize(f *os.File) (cols, rows int) { return 0, 0 }
@@ -0,0 +1,45 @@
+//go:build linux
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXattrs is path's extended attributes, not following a symlink.
+func readXattrs(path string) (map[string]string, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil || size == 0 {
+		if errors.Is(err, unix.ENOTSUP) {
+			err = nil
+		}
+		return nil, err
+	}
+	names := make([]byte, size)
+	if size, err = unix.Llistxattr(path, names); err != nil {
+		return nil, err
+	}
+	attrs := map[string]string{}
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		n, err := unix.Lgetxattr(path, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, n)
+		if n, err = unix.Lgetxattr(path, string(name), value); err != nil {
+			return nil, err
+		}
+		attrs[string(name)] = string(value[:n])
+	}
+	return attrs, nil
+}
+
+func writeXattr(path, name, value string) error {
+	return unix.Lsetxattr(path, name, []byte(value), 0)
+}
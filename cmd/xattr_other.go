@@ -0,0 +1,13 @@
+//go:build !linux
+
+package cmd
+
+import "errors"
+
+var errNoXattrs = errors.New("extended attributes are only supported on Linux")
+
+// readXattrs and writeXattr have no portable implementation;
+// validateAttrs turns --xattrs down off Linux before they are reached.
+func readXattrs(path string) (map[string]string, error) { return nil, errNoXattrs }
+
+func writeXattr(path, name, value string) error { return errNoXattrs }
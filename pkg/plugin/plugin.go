@@ -0,0 +1,123 @@
+// Package plugin is how Go code extends gt without forking it. A
+// package registers its transports and importers from init, and a
+// custom build of gt imports it for the side effect:
+//
+//	package main
+//
+//	import (
+//		"os"
+//
+//		"gt/cmd"
+//		_ "example.com/gt-bastion" // calls plugin.RegisterTransport
+//	)
+//
+//	func main() {
+//		if err := cmd.Execute(); err != nil {
+//			os.Exit(1)
+//		}
+//	}
+//
+// Registration is not safe for concurrent use; do it from init.
+// Commands that are just programs need none of this: gt runs any
+// gt-<name> executable on PATH as "gt <name>".
+package plugin
+
+import (
+	"fmt"
+	"sort"
+
+	"gt/pkg/transport"
+)
+
+// Transport connects to hosts in place of ssh, e.g. through a
+// proprietary bastion. It is selected with backend: <name> in gt's
+// config or GT_BACKEND.
+type Transport interface {
+	// Name is what backend: selects.
+	Name() string
+	// Command returns the argv that connects to alias and runs remoteCmd
+	// there (an interactive session when it is empty). o carries gt's
+	// flags; a transport may ignore what it cannot honor.
+	Command(alias string, o transport.Options, remoteCmd []string) ([]string, error)
+}
+
+// Transferer is implemented by transports that can also copy files, for
+// gt -s. files use gt's colon shorthand; see transport.ValidateSCPPaths.
+type Transferer interface {
+	TransferCommand(alias string, o transport.Options, files []string) ([]string, error)
+}
+
+// Host is one host produced by an Importer.
+type Host struct {
+	Alias    string
+	HostName string
+	User     string
+	Port     string
+	// Options are further ssh_config options, by keyword.
+	Options map[string]string
+}
+
+// Importer reads hosts from an external inventory (a CMDB, a cloud API)
+// for gt import, which prints them as ssh_config Host blocks.
+type Importer interface {
+	Name() string
+	// Import fetches the hosts. args are the command-line arguments
+	// after the importer's name.
+	Import(args []string) ([]Host, error)
+}
+
+var (
+	transports = map[string]Transport{}
+	importers  = map[string]Importer{}
+)
+
+// RegisterTransport makes t available as a backend. It panics if the
+// name is taken, like a duplicate flag would: that is a build mistake.
+func RegisterTransport(t Transport) {
+	name := t.Name()
+	if _, dup := transports[name]; dup || name == "" || name == "auto" || name == "openssh" || name == "putty" {
+		panic(fmt.Sprintf("plugin: transport %q already registered or reserved", name))
+	}
+	transports[name] = t
+}
+
+// LookupTransport returns the transport registered as name.
+func LookupTransport(name string) (Transport, bool) {
+	t, ok := transports[name]
+	return t, ok
+}
+
+// Transports lists the registered transport names, sorted.
+func Transports() []string {
+	return sortedKeys(transports)
+}
+
+// RegisterImporter makes i available to gt import. It panics if the
+// name is taken.
+func RegisterImporter(i Importer) {
+	name := i.Name()
+	if _, dup := importers[name]; dup || name == "" {
+		panic(fmt.Sprintf("plugin: importer %q already registered", name))
+	}
+	importers[name] = i
+}
+
+// LookupImporter returns the importer registered as name.
+func LookupImporter(name string) (Importer, bool) {
+	i, ok := importers[name]
+	return i, ok
+}
+
+// Importers lists the registered importer names, sorted.
+func Importers() []string {
+	return sortedKeys(importers)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
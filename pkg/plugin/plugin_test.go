@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gt/pkg/transport"
+)
+
+type namedTransport string
+
+func (n namedTransport) Name() string { return string(n) }
+func (n namedTransport) Command(alias string, o transport.Options, remoteCmd []string) ([]string, error) {
+	return append([]string{"connect", alias}, remoteCmd...), nil
+}
+
+type namedImporter string
+
+func (n namedImporter) Name() string                         { return string(n) }
+func (n namedImporter) Import(args []string) ([]Host, error) { return nil, nil }
+
+func TestRegisterTransport(t *testing.T) {
+	RegisterTransport(namedTransport("zeta"))
+	RegisterTransport(namedTransport("alpha"))
+
+	got, ok := LookupTransport("zeta")
+	assert.True(t, ok)
+	assert.Equal(t, "zeta", got.Name())
+	_, ok = LookupTransport("nope")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"alpha", "zeta"}, Transports())
+
+	assert.Panics(t, func() { RegisterTransport(namedTransport("zeta")) }, "duplicate")
+	assert.Panics(t, func() { RegisterTransport(namedTransport("openssh")) }, "built-in backends are reserved")
+}
+
+func TestRegisterImporter(t *testing.T) {
+	RegisterImporter(namedImporter("cmdb"))
+	_, ok := LookupImporter("cmdb")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"cmdb"}, Importers())
+	assert.Panics(t, func() { RegisterImporter(namedImporter("cmdb")) })
+}
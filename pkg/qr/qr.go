@@ -0,0 +1,487 @@
+// Package qr encodes short text as a QR code (ISO/IEC 18004), for gt qr
+// to draw in a terminal. It covers what a connection URI needs and no
+// more: byte mode, error correction level M, versions 1 to 10 (up to 213
+// bytes).
+package qr
+
+import (
+	"errors"
+)
+
+// Code is an encoded QR symbol: Size×Size modules, without the quiet
+// zone readers need around it.
+type Code struct {
+	Size    int
+	modules [][]bool
+	// function marks the modules that are part of the fixed patterns
+	// rather than data.
+	function [][]bool
+}
+
+// Dark reports whether the module at column x, row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// ErrTooLong is returned for text that does not fit version 10.
+var ErrTooLong = errors.New("qr: text too long (at most 213 bytes)")
+
+// blockSpec is one version's error correction at level M: how many
+// blocks of each data length, and the EC codewords per block.
+type blockSpec struct {
+	ecPerBlock int
+	groups     [][2]int // {block count, data codewords per block}
+}
+
+// levelM lists versions 1 to 10 at error correction level M.
+var levelM = []blockSpec{
+	{10, [][2]int{{1, 16}}},
+	{16, [][2]int{{1, 28}}},
+	{26, [][2]int{{1, 44}}},
+	{18, [][2]int{{2, 32}}},
+	{24, [][2]int{{2, 43}}},
+	{16, [][2]int{{4, 27}}},
+	{18, [][2]int{{4, 31}}},
+	{22, [][2]int{{2, 38}, {2, 39}}},
+	{22, [][2]int{{3, 36}, {2, 37}}},
+	{26, [][2]int{{4, 43}, {1, 44}}},
+}
+
+// alignment lists each version's alignment pattern centers.
+var alignment = [][]int{
+	nil,
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+}
+
+func (b blockSpec) dataCodewords() int {
+	n := 0
+	for _, g := range b.groups {
+		n += g[0] * g[1]
+	}
+	return n
+}
+
+// Encode encodes data at error correction level M in the smallest
+// version it fits.
+func Encode(data []byte) (*Code, error) {
+	for i, spec := range levelM {
+		version := i + 1
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) > 8*spec.dataCodewords() {
+			continue
+		}
+		var bits bitBuffer
+		bits.append(0b0100, 4) // byte mode
+		bits.append(len(data), countBits)
+		for _, b := range data {
+			bits.append(int(b), 8)
+		}
+		return build(version, spec, bits.codewords(spec.dataCodewords())), nil
+	}
+	return nil, ErrTooLong
+}
+
+// bitBuffer accumulates the data bit stream, most significant bit first.
+type bitBuffer []bool
+
+func (b *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 == 1)
+	}
+}
+
+// codewords terminates the stream and pads it to n codewords.
+func (b bitBuffer) codewords(n int) []byte {
+	capacity := 8 * n
+	for i := 0; i < 4 && len(b) < capacity; i++ {
+		b = append(b, false)
+	}
+	for len(b)%8 != 0 {
+		b = append(b, false)
+	}
+	out := make([]byte, 0, n)
+	for i := 0; i < len(b); i += 8 {
+		var c byte
+		for _, bit := range b[i : i+8] {
+			c <<= 1
+			if bit {
+				c |= 1
+			}
+		}
+		out = append(out, c)
+	}
+	for pad := byte(0xEC); len(out) < n; pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// interleave splits data into spec's blocks, adds each block's error
+// correction, and interleaves the lot into the final codeword sequence.
+func interleave(spec blockSpec, data []byte) []byte {
+	var blocks [][]byte
+	for _, g := range spec.groups {
+		for i := 0; i < g[0]; i++ {
+			blocks = append(blocks, data[:g[1]])
+			data = data[g[1]:]
+		}
+	}
+	divisor := rsDivisor(spec.ecPerBlock)
+	ec := make([][]byte, len(blocks))
+	longest := 0
+	for i, b := range blocks {
+		ec[i] = rsRemainder(b, divisor)
+		if len(b) > longest {
+			longest = len(b)
+		}
+	}
+	var out []byte
+	for i := 0; i < longest; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ecPerBlock; i++ {
+		for _, e := range ec {
+			out = append(out, e[i])
+		}
+	}
+	return out
+}
+
+// build lays out the symbol for the codewords and applies the mask that
+// scores best.
+func build(version int, spec blockSpec, data []byte) *Code {
+	size := 17 + 4*version
+	c := &Code{Size: size, modules: grid(size), function: grid(size)}
+	c.drawFunctionPatterns(version)
+	c.drawCodewords(interleave(spec, data))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // masking twice undoes it
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c
+}
+
+func grid(size int) [][]bool {
+	g := make([][]bool, size)
+	for i := range g {
+		g[i] = make([]bool, size)
+	}
+	return g
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns(version int) {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	pos := alignment[version-1]
+	last := len(pos) - 1
+	for i, y := range pos {
+		for j, x := range pos {
+			// The corners that overlap a finder pattern get none.
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format areas for now; build fills them in per mask.
+	c.drawFormatBits(0)
+	if version >= 7 {
+		c.drawVersion(version)
+	}
+}
+
+// drawFinder draws a finder pattern centered on x, y, with its light
+// separator.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.Size || yy < 0 || yy >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatBits is the 15-bit format information for level M and mask:
+// a BCH(15,5) code, XORed so it is never all light.
+func formatBits(mask int) int {
+	data := 0b00<<3 | mask // level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	// Around the top-left finder.
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	// Split between the other two.
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true) // the dark module
+}
+
+// versionBits is the 18-bit version information: a BCH(18,6) code.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+func (c *Code) drawVersion(version int) {
+	bits := versionBits(version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 == 1
+		a, b := c.Size-11+i%3, i/3
+		c.set(a, b, dark)
+		c.set(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in the two-module-wide zigzag that
+// runs up and down from the bottom right corner, skipping the vertical
+// timing pattern. Remainder modules stay light.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] || i >= 8*len(data) {
+					continue
+				}
+				c.modules[y][x] = data[i>>3]>>(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask XORs mask's pattern over the data modules.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !c.function[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol by the standard's four rules; lower reads
+// more reliably.
+func (c *Code) penalty() int {
+	n := c.Size
+	p := 0
+	// Runs of five or more, and finder-like 1:1:3:1:1 patterns, in both
+	// directions.
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, transpose := range []bool{false, true} {
+		at := func(i, j int) bool {
+			if transpose {
+				return c.modules[j][i]
+			}
+			return c.modules[i][j]
+		}
+		for i := 0; i < n; i++ {
+			run := 1
+			for j := 1; j <= n; j++ {
+				if j < n && at(i, j) == at(i, j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += 3 + run - 5
+				}
+				run = 1
+			}
+			for j := 0; j+7 <= n; j++ {
+				match := true
+				for k, v := range finder {
+					if at(i, j+k) != v {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				light := func(from int) bool {
+					for k := from; k < from+4; k++ {
+						if k >= 0 && k < n && at(i, k) {
+							return false
+						}
+					}
+					return true
+				}
+				if light(j-4) || light(j+7) {
+					p += 40
+				}
+			}
+		}
+	}
+	// 2×2 blocks of one color.
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				v := c.modules[y][x]
+				if c.modules[y][x+1] == v && c.modules[y+1][x] == v && c.modules[y+1][x+1] == v {
+					p += 3
+				}
+			}
+		}
+	}
+	// How far the dark share strays from half, in steps of 5%.
+	p += abs(dark*20-n*n*10) / (n * n) * 10
+	return p
+}
+
+// rsDivisor is the Reed-Solomon generator polynomial of the given
+// degree, highest coefficient (always 1) omitted.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder is data's error correction codewords.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,190 @@
+package qr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// The 1-M "HELLO WORLD" example from the standard's annex.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, rsRemainder(data, rsDivisor(10)))
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	want := []int{
+		0b101010000010010, 0b101000100100101, 0b101111001111100, 0b101101101001011,
+		0b100010111111001, 0b100000011001110, 0b100111110010111, 0b100101010100000,
+	}
+	for mask, w := range want {
+		assert.Equal(t, w, formatBits(mask), "mask %d", mask)
+	}
+	assert.Equal(t, 0b000111110010010100, versionBits(7))
+	assert.Equal(t, 0b001010010011010011, versionBits(10))
+}
+
+func TestEncodePicksSmallestVersion(t *testing.T) {
+	for _, tc := range []struct{ n, size int }{{14, 21}, {15, 25}, {84, 37}, {122, 45}, {213, 57}} {
+		c, err := Encode([]byte(strings.Repeat("a", tc.n)))
+		require.NoError(t, err)
+		assert.Equal(t, tc.size, c.Size, "%d bytes", tc.n)
+	}
+	_, err := Encode([]byte(strings.Repeat("a", 214)))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+// readBack decodes c the way a reader would once it has located the
+// symbol: format bits, unmasking, the zigzag, deinterleaving, and a
+// Reed-Solomon check of every block.
+func readBack(t *testing.T, c *Code) []byte {
+	t.Helper()
+	version := (c.Size - 17) / 4
+	format := 0
+	for i := 14; i >= 9; i-- {
+		format = format<<1 | bit(c.Dark(14-i, 8))
+	}
+	format = format<<1 | bit(c.Dark(7, 8))
+	format = format<<1 | bit(c.Dark(8, 8))
+	format = format<<1 | bit(c.Dark(8, 7))
+	for i := 5; i >= 0; i-- {
+		format = format<<1 | bit(c.Dark(8, i))
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	require.NotEqual(t, -1, mask, "format bits %015b", format)
+
+	// Only data modules are masked, and drawCodewords skips the rest.
+	r := &Code{Size: c.Size, modules: grid(c.Size), function: c.function}
+	for y := range c.modules {
+		copy(r.modules[y], c.modules[y])
+	}
+	r.applyMask(mask)
+	spec := levelM[version-1]
+	total := spec.dataCodewords()
+	blocks := 0
+	for _, g := range spec.groups {
+		blocks += g[0]
+	}
+	total += blocks * spec.ecPerBlock
+	raw := make([]byte, total)
+	i := 0
+	for right := r.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < r.Size; vert++ {
+			y := vert
+			if upward {
+				y = r.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if r.function[y][x] || i >= 8*total {
+					continue
+				}
+				if r.modules[y][x] {
+					raw[i>>3] |= 0x80 >> (i & 7)
+				}
+				i++
+			}
+		}
+	}
+
+	var lengths []int
+	for _, g := range spec.groups {
+		for k := 0; k < g[0]; k++ {
+			lengths = append(lengths, g[1])
+		}
+	}
+	data := make([][]byte, blocks)
+	pos := 0
+	for k := 0; k < lengths[len(lengths)-1]; k++ {
+		for b, n := range lengths {
+			if k < n {
+				data[b] = append(data[b], raw[pos])
+				pos++
+			}
+		}
+	}
+	ec := make([][]byte, blocks)
+	for k := 0; k < spec.ecPerBlock; k++ {
+		for b := range ec {
+			ec[b] = append(ec[b], raw[pos])
+			pos++
+		}
+	}
+	var stream []byte
+	for b := range data {
+		assert.Equal(t, rsRemainder(data[b], rsDivisor(spec.ecPerBlock)), ec[b], "block %d", b)
+		stream = append(stream, data[b]...)
+	}
+
+	// Byte mode: 0100, the count, the bytes.
+	var bits bitBuffer
+	for _, b := range stream {
+		bits.append(int(b), 8)
+	}
+	read := func(n int) int {
+		v := 0
+		for _, b := range bits[:n] {
+			v = v<<1 | bit(b)
+		}
+		bits = bits[n:]
+		return v
+	}
+	require.Equal(t, 0b0100, read(4))
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	out := make([]byte, read(countBits))
+	for k := range out {
+		out[k] = byte(read(8))
+	}
+	return out
+}
+
+func bit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestEncodeReadsBack(t *testing.T) {
+	for _, s := range []string{
+		"ssh://deploy@web1.example.com:22",
+		"ssh://root@[2001:db8::1]:2222",
+		strings.Repeat("ssh://u@h:1/", 10),
+		strings.Repeat("x", 213),
+	} {
+		c, err := Encode([]byte(s))
+		require.NoError(t, err)
+		assert.Equal(t, s, string(readBack(t, c)))
+	}
+}
+
+func TestEncodeDrawsFixedPatterns(t *testing.T) {
+	c, err := Encode([]byte("ssh://deploy@web1.example.com:22"))
+	require.NoError(t, err)
+	n := c.Size
+	// Finder rings at three corners, timing between them.
+	for _, corner := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		x, y := corner[0], corner[1]
+		assert.True(t, c.Dark(x, y) && c.Dark(x+6, y+6) && c.Dark(x+3, y+3))
+		assert.False(t, c.Dark(x+1, y+1) || c.Dark(x+5, y+1))
+	}
+	for i := 8; i < n-8; i++ {
+		assert.Equal(t, i%2 == 0, c.Dark(i, 6))
+		assert.Equal(t, i%2 == 0, c.Dark(6, i))
+	}
+	assert.True(t, c.Dark(8, n-8), "dark module")
+}
@@ -0,0 +1,95 @@
+package sshconf
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Canonicalization is what a host's CanonicalizeHostname,
+// CanonicalDomains and CanonicalizeMaxDots ask of its name. ssh applies
+// them when it connects, but ssh -G reports the name as configured, so
+// gt applies them itself to show the name ssh will use.
+type Canonicalization struct {
+	// Mode is "yes" or "always"; "" leaves names alone.
+	Mode string
+	// Domains are tried in order, as suffixes of the name.
+	Domains []string
+	// MaxDots is how many dots a name may have and still be
+	// canonicalized; default 1.
+	MaxDots int
+	// Direct is set when the host has no ProxyJump or ProxyCommand:
+	// "yes" only canonicalizes names ssh connects to itself.
+	Direct bool
+}
+
+// ParseCanonicalization reads the canonicalization options out of opts,
+// keyed in lower case as ParseOptions keys them.
+func ParseCanonicalization(opts map[string][]string) Canonicalization {
+	first := func(key string) string {
+		if v := opts[key]; len(v) > 0 {
+			return strings.ToLower(strings.TrimSpace(v[0]))
+		}
+		return ""
+	}
+	c := Canonicalization{MaxDots: 1, Direct: true}
+	switch first("canonicalizehostname") {
+	case "yes", "true":
+		c.Mode = "yes"
+	case "always":
+		c.Mode = "always"
+	}
+	for _, v := range opts["canonicaldomains"] {
+		for _, d := range strings.Fields(v) {
+			if d != "none" {
+				c.Domains = append(c.Domains, strings.Trim(d, "."))
+			}
+		}
+	}
+	if n, err := strconv.Atoi(first("canonicalizemaxdots")); err == nil && n >= 0 {
+		c.MaxDots = n
+	}
+	for _, key := range []string{"proxyjump", "proxycommand"} {
+		if v := first(key); v != "" && v != "none" {
+			c.Direct = false
+		}
+	}
+	return c
+}
+
+// Canonicalize is host as ssh canonicalizes it: host with the first of
+// the Domains appended for which resolves reports a name that resolves.
+// A name with a trailing dot is canonical already, and loses the dot.
+// An IP address, a name with more than MaxDots dots, and one no domain
+// makes resolve are left as they are (ssh then falls back to them, as
+// CanonicalizeFallbackLocal allows). CNAME rewriting
+// (CanonicalizePermittedCNAMEs) is not applied.
+func (c Canonicalization) Canonicalize(host string, resolves func(name string) bool) string {
+	if c.Mode == "" || (c.Mode == "yes" && !c.Direct) || host == "" {
+		return host
+	}
+	if strings.HasSuffix(host, ".") {
+		return strings.TrimSuffix(host, ".")
+	}
+	if net.ParseIP(host) != nil || strings.Count(host, ".") > c.MaxDots {
+		return host
+	}
+	for _, d := range c.Domains {
+		if name := host + "." + d; resolves(name) {
+			return name
+		}
+	}
+	return host
+}
+
+// ShortFor reports whether short is name with one of the Domains left
+// off, as a canonicalized name is often written: db3 for
+// db3.corp.example.com.
+func (c Canonicalization) ShortFor(short, name string) bool {
+	for _, d := range c.Domains {
+		if strings.EqualFold(short+"."+d, name) {
+			return true
+		}
+	}
+	return false
+}
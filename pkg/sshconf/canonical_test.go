@@ -0,0 +1,55 @@
+package sshconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCanonicalization(t *testing.T) {
+	assert.Equal(t, Canonicalization{MaxDots: 1, Direct: true}, ParseCanonicalization(nil))
+
+	c := ParseCanonicalization(map[string][]string{
+		"canonicalizehostname": {"true"},
+		"canonicaldomains":     {"corp.example.com example.com."},
+		"canonicalizemaxdots":  {"0"},
+		"proxyjump":            {"none"},
+	})
+	assert.Equal(t, Canonicalization{Mode: "yes", Domains: []string{"corp.example.com", "example.com"}, MaxDots: 0, Direct: true}, c)
+
+	c = ParseCanonicalization(map[string][]string{
+		"canonicalizehostname": {"always"},
+		"canonicaldomains":     {"none"},
+		"proxycommand":         {"nc %h %p"},
+	})
+	assert.Equal(t, Canonicalization{Mode: "always", MaxDots: 1}, c)
+	assert.Equal(t, "", ParseCanonicalization(map[string][]string{"canonicalizehostname": {"none"}}).Mode)
+}
+
+func TestCanonicalize(t *testing.T) {
+	known := map[string]bool{"db3.example.com": true, "web.prod.example.com": true}
+	resolves := func(name string) bool { return known[name] }
+	c := Canonicalization{Mode: "yes", Domains: []string{"corp.example.com", "example.com"}, MaxDots: 1, Direct: true}
+
+	assert.Equal(t, "db3.example.com", c.Canonicalize("db3", resolves), "the first domain that resolves")
+	assert.Equal(t, "web.prod.example.com", c.Canonicalize("web.prod", resolves))
+	assert.Equal(t, "a.b.c", c.Canonicalize("a.b.c", resolves), "more dots than MaxDots")
+	assert.Equal(t, "db9", c.Canonicalize("db9", resolves), "falls back to the name as is")
+	assert.Equal(t, "db3", c.Canonicalize("db3.", resolves), "a trailing dot is canonical")
+	assert.Equal(t, "10.0.0.3", c.Canonicalize("10.0.0.3", resolves))
+
+	proxied := c
+	proxied.Direct = false
+	assert.Equal(t, "db3", proxied.Canonicalize("db3", resolves), "yes leaves proxied hosts to the proxy")
+	proxied.Mode = "always"
+	assert.Equal(t, "db3.example.com", proxied.Canonicalize("db3", resolves))
+
+	assert.Equal(t, "db3", Canonicalization{MaxDots: 1}.Canonicalize("db3", resolves), "off by default")
+}
+
+func TestShortFor(t *testing.T) {
+	c := Canonicalization{Domains: []string{"corp.example.com"}}
+	assert.True(t, c.ShortFor("db3", "DB3.corp.example.com"))
+	assert.False(t, c.ShortFor("db3", "db3.example.com"))
+	assert.False(t, c.ShortFor("db", "db3.corp.example.com"))
+}
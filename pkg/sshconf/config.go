@@ -0,0 +1,381 @@
+// Package sshconf loads OpenSSH client configs the way gt reads them:
+// the main file plus every Include, merged with OpenSSH's semantics, for
+// enumerating and validating Host aliases. It deliberately stops short of
+// resolving options itself; ParseResolved reads what "ssh -G" reports
+// instead, so Match blocks and canonicalization stay OpenSSH's job.
+package sshconf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// Config is a loaded SSH config with every Include merged in.
+type Config struct {
+	*ssh_config.Config
+	// Files lists the absolute path of every file merged into the
+	// config, main first.
+	Files []string
+}
+
+// Options tunes Load. The zero value loads a plain config silently.
+type Options struct {
+	// Preprocess rewrites the main file's body before it is parsed and
+	// returns any extra source files the rewrite drew on, which are
+	// recorded in Files after the main file. gt uses it to inline
+	// encrypted includes.
+	Preprocess func(body []byte) ([]byte, []string, error)
+	// Logf receives debug detail: each file loaded, skipped or missing.
+	Logf func(format string, args ...any)
+	// Warnf receives problems that skip an include without failing the
+	// load, such as unsafe permissions.
+	Warnf func(format string, args ...any)
+	// Internal reports files to merge but leave out of Files, such as
+	// temporary plaintext copies the caller wrote itself.
+	Internal func(path string) bool
+}
+
+type loader struct {
+	opts  Options
+	files []string
+}
+
+func (l *loader) logf(format string, args ...any) {
+	if l.opts.Logf != nil {
+		l.opts.Logf(format, args...)
+	}
+}
+
+func (l *loader) warnf(format string, args ...any) {
+	if l.opts.Warnf != nil {
+		l.opts.Warnf(format, args...)
+	}
+}
+
+// Load reads the config at path and merges its includes. The main file
+// must pass ValidateOpen; an include that fails it is skipped with a
+// warning, like one that is missing or does not parse.
+func Load(path string, opts Options) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open SSH config at %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := ValidateOpen(path, f); err != nil {
+		return nil, fmt.Errorf("refusing to load SSH config: %w", err)
+	}
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read SSH config at %s: %w", path, err)
+	}
+
+	abs := path
+	if a, err := filepath.Abs(path); err == nil {
+		abs = a
+	}
+	l := &loader{opts: opts, files: []string{abs}}
+	l.logf("loaded SSH config %s", abs)
+
+	if opts.Preprocess != nil {
+		var sources []string
+		if body, sources, err = opts.Preprocess(body); err != nil {
+			return nil, err
+		}
+		l.files = append(l.files, sources...)
+	}
+
+	decoded, err := Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing SSH config: %w", err)
+	}
+
+	seen := map[string]struct{}{abs: {}}
+	hosts := l.resolveIncludes(decoded.Hosts, seen)
+	return &Config{Config: &ssh_config.Config{Hosts: hosts}, Files: l.files}, nil
+}
+
+// Decode parses an SSH config stream, first dropping Match blocks,
+// which the ssh_config library rejects outright ("Match directive parsing
+// is unsupported") even though OpenSSH accepts them. Only Host patterns
+// matter for alias enumeration and a Match block cannot declare aliases,
+// so skipping the block is faithful. Its body — including any conditional
+// Includes, whose criteria could not be evaluated here anyway — is
+// dropped; OpenSSH still applies all of it at connection time.
+func Decode(r io.Reader) (*ssh_config.Config, error) {
+	var filtered bytes.Buffer
+	sc := bufio.NewScanner(r)
+	skipping := false
+	for sc.Scan() {
+		line := sc.Text()
+		switch Keyword(line) {
+		case "match":
+			skipping = true
+			continue
+		case "host":
+			skipping = false
+		}
+		if skipping {
+			continue
+		}
+		filtered.WriteString(line)
+		filtered.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return ssh_config.Decode(&filtered)
+}
+
+// Keyword returns the lowercased leading keyword of a config line, or ""
+// for blanks and comments. Keywords may be separated from their
+// arguments by whitespace or '='.
+func Keyword(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return ""
+	}
+	if i := strings.IndexAny(trimmed, " \t="); i >= 0 {
+		trimmed = trimmed[:i]
+	}
+	return strings.ToLower(trimmed)
+}
+
+// resolveIncludes walks the host list and replaces every Include node with
+// the hosts it resolves to, recursively. Includes inside included files are
+// expanded the same way, so chains like main -> ~/.ssh/config.d/* -> shared
+// load fully. An Include that sits inside a Host block is conditional in
+// OpenSSH — its content only applies when the enclosing block matches the
+// queried host — so hosts expanded from one are filtered through the
+// enclosing block's patterns rather than merged wholesale. seen holds
+// absolute paths of files already merged so a cycle terminates instead of
+// looping forever. Note: the underlying library has its own depth-5 guard
+// inside Decode, which catches absolute-path cycles before this layer ever
+// sees them; our seen set covers cycles it resolves differently.
+func (l *loader) resolveIncludes(hosts []*ssh_config.Host, seen map[string]struct{}) []*ssh_config.Host {
+	out := make([]*ssh_config.Host, 0, len(hosts))
+	for _, host := range hosts {
+		out = append(out, host)
+		for _, node := range host.Nodes {
+			include, ok := node.(*ssh_config.Include)
+			if !ok {
+				continue
+			}
+			out = append(out, filterConditional(host, l.expandInclude(include, seen))...)
+		}
+	}
+	return out
+}
+
+// filterConditional applies OpenSSH's conditional-include semantics to
+// hosts expanded from an Include node found inside the enclosing block.
+// The catch-all block (the library's implicit top-of-file "Host *", or an
+// explicit one) matches every query, so its includes pass through intact.
+// Anything else only takes effect when the enclosing block matches, so an
+// alias is kept only if the enclosing block would match it too.
+func filterConditional(enclosing *ssh_config.Host, hosts []*ssh_config.Host) []*ssh_config.Host {
+	if len(enclosing.Patterns) == 1 && enclosing.Patterns[0].String() == "*" {
+		return hosts
+	}
+	var out []*ssh_config.Host
+	for _, h := range hosts {
+		var kept []*ssh_config.Pattern
+		for _, p := range h.Patterns {
+			if enclosing.Matches(p.String()) {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) > 0 {
+			out = append(out, &ssh_config.Host{Patterns: kept, Nodes: h.Nodes})
+		}
+	}
+	return out
+}
+
+// includeDirectives extracts the path arguments from an Include node.
+// The node's String() renders the whole config line — leading indentation,
+// optional "=", trailing comment — so strip the decoration down to the
+// space-separated paths. An Include line may name several.
+func includeDirectives(include *ssh_config.Include) []string {
+	line := strings.TrimSpace(include.String())
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(strings.TrimPrefix(line, "Include"))
+	line = strings.TrimPrefix(line, "=")
+	return Fields(line)
+}
+
+// Fields splits a config line's arguments as ssh does: at spaces and
+// tabs, except inside double or single quotes, which are dropped. A
+// backslash only escapes a quote, a backslash or, unquoted, a space, so
+// a Windows path keeps its backslashes.
+func Fields(s string) []string {
+	var fields []string
+	var b strings.Builder
+	var quote byte
+	inField := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\'' || s[i+1] == '\\' || (quote == 0 && s[i+1] == ' ')):
+			i++
+			b.WriteByte(s[i])
+			inField = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+			inField = true
+		case quote == 0 && (c == ' ' || c == '\t'):
+			if inField {
+				fields = append(fields, b.String())
+				b.Reset()
+				inField = false
+			}
+		default:
+			b.WriteByte(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, b.String())
+	}
+	return fields
+}
+
+func (l *loader) expandInclude(include *ssh_config.Include, seen map[string]struct{}) []*ssh_config.Host {
+	var matches []string
+	for _, directive := range includeDirectives(include) {
+		expanded, err := filepath.Glob(IncludePath(directive))
+		if err != nil {
+			l.logf("include %s: %v", directive, err)
+			continue
+		}
+		if len(expanded) == 0 {
+			l.logf("include %s: no matching files", directive)
+		}
+		matches = append(matches, expanded...)
+	}
+	var hosts []*ssh_config.Host
+	for _, match := range matches {
+		abs, err := filepath.Abs(match)
+		if err != nil {
+			abs = match
+		}
+		if _, dup := seen[abs]; dup {
+			l.logf("include %s: already loaded, skipping", abs)
+			continue // already loaded somewhere up the chain
+		}
+		f, err := os.Open(match)
+		if err != nil {
+			l.logf("include %s: %v", match, err)
+			continue
+		}
+		if err := ValidateOpen(match, f); err != nil {
+			l.warnf("Skipping include: %v", err)
+			f.Close()
+			continue
+		}
+		decoded, err := Decode(f)
+		f.Close()
+		if err != nil {
+			l.logf("include %s: parse error, skipping: %v", match, err)
+			continue
+		}
+		l.logf("loaded include %s", abs)
+		// Mark before recursing so a self-referential include terminates.
+		seen[abs] = struct{}{}
+		if l.opts.Internal == nil || !l.opts.Internal(abs) {
+			l.files = append(l.files, abs)
+		}
+		hosts = append(hosts, l.resolveIncludes(decoded.Hosts, seen)...)
+	}
+	return hosts
+}
+
+// IncludePath mirrors OpenSSH: "~" expands to the home directory, and
+// relative paths resolve against ~/.ssh — never against the directory of
+// the including file, no matter where that file lives.
+func IncludePath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(path, "~") {
+		return filepath.Join(home, path[1:])
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Join(home, ".ssh", path)
+	}
+	return path
+}
+
+// Aliases lists the concrete Host aliases in c, sorted and deduplicated.
+// Wildcard patterns and negations are not aliases.
+func Aliases(c *ssh_config.Config) []string {
+	var hosts []string
+	seen := map[string]struct{}{}
+	for _, host := range c.Hosts {
+		// A single Host block can declare several aliases ("Host foo bar baz");
+		// emit each one and dedupe in case the same alias appears in multiple
+		// blocks across the merged config.
+		for _, p := range host.Patterns {
+			pattern := p.String()
+			if strings.ContainsAny(pattern, "*?") {
+				continue // skip wildcard match patterns
+			}
+			// Pattern.String() strips a leading "!", so exclusions are not
+			// detectable from the text. Ask the block instead: a negated
+			// pattern never matches its own alias, which filters entries
+			// like the "!backup" in "Host web !backup".
+			if !host.Matches(pattern) {
+				continue
+			}
+			if _, ok := seen[pattern]; ok {
+				continue
+			}
+			seen[pattern] = struct{}{}
+			hosts = append(hosts, pattern)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Known reports whether alias is addressed by a Host block in c, so a
+// typo fails with a clear error instead of a DNS lookup on the raw alias.
+// Blocks whose only patterns are the catch-all "*" are ignored: those
+// hold global defaults and would make every alias look valid. Wildcard
+// blocks like "Host web-*" still count, and OpenSSH resolves the actual
+// options at exec time.
+func Known(c *ssh_config.Config, alias string) bool {
+	for _, host := range c.Hosts {
+		if hasSpecificPattern(host) && host.Matches(alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSpecificPattern reports whether the block names anything beyond the
+// catch-all "*". Pattern.String() strips negation, so a non-"*" pattern
+// counts only if the block would actually apply to it — this keeps a pure
+// exclusion block like "Host * !secret" classified as a catch-all.
+func hasSpecificPattern(host *ssh_config.Host) bool {
+	for _, p := range host.Patterns {
+		if s := p.String(); s != "*" && host.Matches(s) {
+			return true
+		}
+	}
+	return false
+}
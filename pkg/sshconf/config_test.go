@@ -0,0 +1,91 @@
+package sshconf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, body string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+}
+
+func TestLoadMergesIncludesAndRecordsFiles(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config")
+	inc := filepath.Join(dir, "work.conf")
+	writeFile(t, main, "Include "+inc+"\n\nHost home\n  HostName home.example.com\n")
+	writeFile(t, inc, "Host work\n  HostName work.example.com\n")
+
+	var logs []string
+	c, err := Load(main, Options{Logf: func(format string, args ...any) { logs = append(logs, format) }})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"home", "work"}, Aliases(c.Config))
+	assert.Equal(t, []string{main, inc}, c.Files)
+	assert.NotEmpty(t, logs)
+}
+
+func TestLoadPreprocessAndInternal(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config")
+	hidden := filepath.Join(dir, "hidden.conf")
+	writeFile(t, main, "MARKER\n")
+	writeFile(t, hidden, "Host hidden\n")
+
+	c, err := Load(main, Options{
+		Preprocess: func(body []byte) ([]byte, []string, error) {
+			return []byte(strings.Replace(string(body), "MARKER", "Include "+hidden+"\nHost pre", 1)), []string{"/secret.age"}, nil
+		},
+		Internal: func(path string) bool { return path == hidden },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hidden", "pre"}, Aliases(c.Config))
+	assert.Equal(t, []string{main, "/secret.age"}, c.Files)
+}
+
+func TestLoadQuotedInclude(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "with space")
+	main := filepath.Join(dir, "config")
+	inc := filepath.Join(dir, "work.conf")
+	writeFile(t, main, `Include "`+inc+`"`+"\n")
+	writeFile(t, inc, "Host work\n")
+
+	c, err := Load(main, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work"}, Aliases(c.Config))
+	assert.Equal(t, []string{main, inc}, c.Files)
+}
+
+func TestFields(t *testing.T) {
+	tests := map[string][]string{
+		"a b\tc":                         {"a", "b", "c"},
+		`"/tmp/with space/x.conf" other`: {"/tmp/with space/x.conf", "other"},
+		`'single quoted' ""`:             {"single quoted", ""},
+		`C:\ProgramData\ssh\ssh_config`:  {`C:\ProgramData\ssh\ssh_config`},
+		`"C:\Users\A \"B\"\\x"`:          {`C:\Users\A "B"\x`},
+		`with\ space`:                    {"with space"},
+		"  ":                             nil,
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, Fields(in), in)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing"), Options{})
+	assert.ErrorContains(t, err, "could not open SSH config")
+}
+
+func TestKnown(t *testing.T) {
+	c, err := Decode(strings.NewReader("Host web db\n\nHost batch-*\n\nHost * !secret\n  User x\n"))
+	require.NoError(t, err)
+	assert.True(t, Known(c, "web"))
+	assert.True(t, Known(c, "batch-7"))
+	assert.False(t, Known(c, "typo"), "catch-all blocks do not make an alias known")
+}
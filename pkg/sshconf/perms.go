@@ -0,0 +1,36 @@
+package sshconf
+
+import (
+	"fmt"
+	"os"
+)
+
+// ValidateOpen refuses a config file that another local user could have
+// tampered with. It mirrors OpenSSH's StrictModes-style check on client
+// config files: must be owned by the running user (or root) and must not
+// be group/world writable. Stat is taken from the open fd so the result
+// describes the same inode the caller will read, closing the TOCTOU
+// window between the check and the parse.
+func ValidateOpen(path string, f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	uid, ok := FileOwner(info)
+	if !ok {
+		return nil // Non-unix filesystem; mode/uid semantics differ.
+	}
+	return CheckOwnerAndMode(path, uid, info.Mode().Perm(), uint32(os.Getuid()))
+}
+
+// CheckOwnerAndMode is ValidateOpen's rule on its own, for callers that
+// already have the owner and mode.
+func CheckOwnerAndMode(path string, fileUID uint32, mode os.FileMode, runningUID uint32) error {
+	if fileUID != runningUID && fileUID != 0 {
+		return fmt.Errorf("%s: bad ownership (uid %d; expected %d or root)", path, fileUID, runningUID)
+	}
+	if mode&0o022 != 0 {
+		return fmt.Errorf("%s: bad permissions %#o (group/world writable)", path, mode)
+	}
+	return nil
+}
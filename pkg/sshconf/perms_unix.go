@@ -0,0 +1,18 @@
+//go:build unix
+
+package sshconf
+
+import (
+	"os"
+	"syscall"
+)
+
+// FileOwner returns the uid owning a file, and false where files have
+// no uid.
+func FileOwner(info os.FileInfo) (uint32, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return stat.Uid, true
+}
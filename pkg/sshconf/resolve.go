@@ -0,0 +1,112 @@
+package sshconf
+
+import (
+	"bufio"
+	"bytes"
+	osuser "os/user"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// Resolved holds the values OpenSSH reports for an alias via ssh -G.
+// IdentityFiles are token- and tilde-expanded, since ssh -G reports them
+// as written in the config.
+type Resolved struct {
+	User          string
+	Hostname      string
+	Port          string
+	IdentityFiles []string
+	// ProxyJump and ProxyCommand are only filled in by FromConfig: with
+	// OpenSSH, ssh applies them itself. ProxyCommand is token-expanded.
+	ProxyJump    string
+	ProxyCommand string
+}
+
+// ParseResolved reads the output of "ssh -G alias". Asking OpenSSH
+// instead of reimplementing config resolution means Match blocks,
+// canonicalization, and future options all behave exactly as they would
+// for a real connection.
+func ParseResolved(alias string, out []byte) Resolved {
+	var r Resolved
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		key, value, _ := strings.Cut(sc.Text(), " ")
+		switch key {
+		case "user":
+			r.User = value
+		case "hostname":
+			r.Hostname = value
+		case "port":
+			r.Port = value
+		case "identityfile":
+			r.IdentityFiles = append(r.IdentityFiles, value)
+		}
+	}
+	ctx := TokenContext{Alias: alias, Hostname: r.Hostname, User: r.User, Port: r.Port}
+	for i, id := range r.IdentityFiles {
+		r.IdentityFiles[i] = ExpandTokens(id, ctx)
+	}
+	return r
+}
+
+// ParseOptions reads the output of "ssh -G" into every option it
+// reports, keyed by ssh's lowercase name. Multi-valued options such as
+// identityfile keep every value in order.
+func ParseOptions(out []byte) map[string][]string {
+	opts := map[string][]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if ok {
+			opts[key] = append(opts[key], value)
+		}
+	}
+	return opts
+}
+
+// FromConfig reads user, hostname, port and how to get there (ProxyJump,
+// ProxyCommand) from the parsed config, for
+// tools that cannot read ssh_config themselves (PuTTY's plink and pscp).
+// It only sees what this package parses — no Match blocks, no
+// canonicalization — which is why ParseResolved over ssh -G is
+// preferred wherever OpenSSH is available. user, when set, overrides
+// the config; with neither, the local user is assumed, as ssh does.
+func FromConfig(c *ssh_config.Config, alias, user string) Resolved {
+	get := func(key string) string {
+		v, _ := c.Get(alias, key)
+		return v
+	}
+	r := Resolved{
+		User:     get("User"),
+		Hostname: get("HostName"),
+		Port:     get("Port"),
+	}
+	if r.Hostname == "" {
+		r.Hostname = alias
+	}
+	r.Hostname = strings.ReplaceAll(r.Hostname, "%h", alias)
+	if r.Port == "" {
+		r.Port = "22"
+	}
+	if user != "" {
+		r.User = user
+	} else if r.User == "" {
+		if u, err := osuser.Current(); err == nil {
+			r.User = u.Username
+			// Windows reports DOMAIN\user; the remote wants just the user.
+			if i := strings.LastIndex(r.User, `\`); i >= 0 {
+				r.User = r.User[i+1:]
+			}
+		}
+	}
+	ids, _ := c.GetAll(alias, "IdentityFile")
+	ctx := TokenContext{Alias: alias, Hostname: r.Hostname, User: r.User, Port: r.Port}
+	for _, id := range ids {
+		r.IdentityFiles = append(r.IdentityFiles, ExpandTokens(id, ctx))
+	}
+	r.ProxyJump = get("ProxyJump")
+	if pc := get("ProxyCommand"); pc != "" {
+		r.ProxyCommand = ExpandTokens(pc, ctx)
+	}
+	return r
+}
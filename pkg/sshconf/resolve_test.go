@@ -0,0 +1,44 @@
+package sshconf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResolved(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	out := "user deploy\nhostname web.example.com\nport 2222\nidentityfile ~/.ssh/%n\nidentityfile /keys/b\nforwardagent no\n"
+	assert.Equal(t, Resolved{
+		User:          "deploy",
+		Hostname:      "web.example.com",
+		Port:          "2222",
+		IdentityFiles: []string{filepath.Join(home, ".ssh", "web"), "/keys/b"},
+	}, ParseResolved("web", []byte(out)))
+}
+
+func TestParseOptions(t *testing.T) {
+	opts := ParseOptions([]byte("user deploy\nidentityfile /a\nidentityfile /b\n\n"))
+	assert.Equal(t, map[string][]string{"user": {"deploy"}, "identityfile": {"/a", "/b"}}, opts)
+}
+
+func TestFromConfig(t *testing.T) {
+	c, err := Decode(strings.NewReader("Host web\n  HostName %h.example.com\n  User deploy\n  Port 2200\n  IdentityFile /keys/%h.ppk\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Resolved{
+		User:          "deploy",
+		Hostname:      "web.example.com",
+		Port:          "2200",
+		IdentityFiles: []string{"/keys/web.example.com.ppk"},
+	}, FromConfig(c, "web", ""))
+	assert.Equal(t, "admin", FromConfig(c, "web", "admin").User)
+
+	c, err = Decode(strings.NewReader("Host db\n  ProxyJump ops@bastion:2222\nHost legacy\n  ProxyCommand ncat --proxy gw %h %p\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "ops@bastion:2222", FromConfig(c, "db", "").ProxyJump)
+	assert.Equal(t, "ncat --proxy gw legacy 22", FromConfig(c, "legacy", "").ProxyCommand)
+}
@@ -0,0 +1,96 @@
+package sshconf
+
+import (
+	"os"
+	osuser "os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TokenContext carries the values ssh_config(5) TOKENS expand to for one
+// connection.
+type TokenContext struct {
+	Alias    string // %n: the name given on the command line
+	Hostname string // %h
+	User     string // %r: the remote user
+	Port     string // %p
+}
+
+// ExpandTokens applies OpenSSH's percent-token and tilde expansion to a
+// path-valued option such as IdentityFile or ControlPath, the way ssh does
+// just before using it. Callers need this wherever they touch those files
+// themselves — existence checks, backends that cannot read ssh_config —
+// since ssh -G reports them unexpanded. Tokens that cannot be computed
+// here (%C, %k, %j, ...) are left as-is rather than guessed.
+func ExpandTokens(s string, ctx TokenContext) string {
+	s = ExpandTilde(s)
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if v, ok := tokenValue(s[i], ctx); ok {
+			b.WriteString(v)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func tokenValue(token byte, ctx TokenContext) (string, bool) {
+	switch token {
+	case '%':
+		return "%", true
+	case 'd':
+		home, err := os.UserHomeDir()
+		return home, err == nil
+	case 'h':
+		return ctx.Hostname, true
+	case 'n':
+		return ctx.Alias, true
+	case 'p':
+		return ctx.Port, true
+	case 'r':
+		return ctx.User, true
+	case 'u':
+		u, err := osuser.Current()
+		if err != nil {
+			return "", false
+		}
+		return u.Username, true
+	case 'i':
+		return strconv.Itoa(os.Getuid()), true
+	case 'L':
+		h, err := os.Hostname()
+		if err != nil {
+			return "", false
+		}
+		short, _, _ := strings.Cut(h, ".")
+		return short, true
+	case 'l':
+		h, err := os.Hostname()
+		return h, err == nil
+	}
+	return "", false
+}
+
+// ExpandTilde expands a leading "~" to the home directory. "~/" works on
+// every platform, as in ssh_config; Windows also gets its native "~\".
+func ExpandTilde(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(os.PathSeparator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
@@ -0,0 +1,42 @@
+package sshconf
+
+import (
+	"os"
+	osuser "os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTokens(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	me, _ := osuser.Current()
+	ctx := TokenContext{Alias: "web", Hostname: "web.example.com", User: "deploy", Port: "2222"}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"~/.ssh/id_ed25519", filepath.Join(home, ".ssh", "id_ed25519")},
+		{"%d/.ssh/%h.key", home + "/.ssh/web.example.com.key"},
+		{"~/.ssh/cm-%r@%h:%p", filepath.Join(home, ".ssh", "cm-deploy@web.example.com:2222")},
+		{"/keys/%n-%u", "/keys/web-" + me.Username},
+		{"/keys/uid%i", "/keys/uid" + strconv.Itoa(os.Getuid())},
+		{"100%%", "100%"},
+		{"/tmp/%C", "/tmp/%C"}, // not computable by gt: left for ssh
+		{"trailing%", "trailing%"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ExpandTokens(tt.in, ctx), "in=%q", tt.in)
+	}
+}
+
+func TestExpandTilde(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	assert.Equal(t, filepath.Join(home, ".ssh", "k"), ExpandTilde("~/.ssh/k"))
+	assert.Equal(t, "/abs/k", ExpandTilde("/abs/k"))
+	assert.Equal(t, "~other/k", ExpandTilde("~other/k"))
+}
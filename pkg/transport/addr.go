@@ -0,0 +1,34 @@
+package transport
+
+import (
+	"net"
+	"strings"
+)
+
+// HostIP parses a hostname that is an IP literal, tolerating the
+// brackets and the %zone suffix an IPv6 address may carry in ssh_config.
+// It returns nil for names.
+func HostIP(hostname string) net.IP {
+	h := strings.TrimSuffix(strings.TrimPrefix(hostname, "["), "]")
+	if i := strings.IndexByte(h, '%'); i >= 0 {
+		h = h[:i]
+	}
+	return net.ParseIP(h)
+}
+
+// IsIPv6Literal reports whether hostname is an IPv6 address rather than
+// a name or an IPv4 address. Written with colons is what counts: an
+// IPv4-mapped ::ffff:192.0.2.1 is one too, though Go reads it as IPv4.
+func IsIPv6Literal(hostname string) bool {
+	return HostIP(hostname) != nil && strings.Contains(hostname, ":")
+}
+
+// BracketHost wraps an IPv6 literal in brackets so it can be followed by
+// ":port" or scp's ":path" without the colons running together, as scp
+// and URIs require. Names and IPv4 addresses pass through unchanged.
+func BracketHost(hostname string) string {
+	if IsIPv6Literal(hostname) && !strings.HasPrefix(hostname, "[") {
+		return "[" + hostname + "]"
+	}
+	return hostname
+}
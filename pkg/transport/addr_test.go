@@ -0,0 +1,23 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBracketHost(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"2001:db8::1", "[2001:db8::1]"},
+		{"[2001:db8::1]", "[2001:db8::1]"},
+		{"fe80::1%eth0", "[fe80::1%eth0]"},
+		{"192.0.2.10", "192.0.2.10"},
+		{"host.example.com", "host.example.com"},
+		{"::ffff:192.0.2.1", "[::ffff:192.0.2.1]"}, // IPv4-mapped has colons all the same
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, BracketHost(tt.in), "in=%q", tt.in)
+	}
+}
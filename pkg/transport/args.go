@@ -0,0 +1,224 @@
+// Package transport builds the command lines gt hands to OpenSSH (ssh,
+// scp) and to PuTTY (plink, pscp). It only builds argv; running the
+// commands, and choosing which tool to run, is left to the caller.
+//
+// For OpenSSH the alias is passed through unresolved, so ssh matches
+// Host blocks against it exactly as a plain "ssh alias" would.
+package transport
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Options are the settings an ssh or scp invocation is built from.
+type Options struct {
+	// ConfigFile is passed as -F when set.
+	ConfigFile string
+	// User overrides the config's User.
+	User string
+	// Overrides are ssh_config options in KEY=VALUE form, passed as -o.
+	// On the command line they win over the config files.
+	Overrides []string
+	// Verbosity adds that many -v flags.
+	Verbosity int
+	// Batch disables prompts: BatchMode=yes for OpenSSH, -batch for
+	// PuTTY. Runs against several hosts at once need it, since a
+	// password prompt per host cannot be answered sensibly.
+	Batch bool
+	// Quiet suppresses the tool's warnings, banners and progress meter:
+	// -q for ssh, scp and pscp.
+	Quiet bool
+	// Recursive lets a transfer copy directories: -r for scp and pscp.
+	Recursive bool
+	// NoPreserve leaves the copies' modes and times to the destination,
+	// dropping the -p that scp and pscp otherwise get.
+	NoPreserve bool
+	// Env are variables for the remote session, as KEY (this process's
+	// value) or KEY=value. ssh passes them with SendEnv and SetEnv, which
+	// the server's AcceptEnv may drop; a remote command is also prefixed
+	// with exports of them (see WithEnv).
+	Env []string
+	// Extra holds further ssh options, such as ControlMaster settings or
+	// -T. PuTTY has no equivalent and ignores them.
+	Extra []string
+}
+
+// BaseArgs returns the flags shared by every ssh, scp and ssh -G
+// invocation: the alternate config file, the user override and any
+// other option overrides, so ssh -G reports what a connection would use.
+// Everything else is deliberately left to OpenSSH, which resolves the
+// alias against the config itself.
+func (o Options) BaseArgs() []string {
+	var args []string
+	if o.ConfigFile != "" {
+		args = append(args, "-F", o.ConfigFile)
+	}
+	if o.User != "" {
+		args = append(args, "-o", "User="+o.User)
+	}
+	for _, opt := range o.Overrides {
+		args = append(args, "-o", opt)
+	}
+	return args
+}
+
+// connArgs is BaseArgs plus everything that only applies to a real
+// connection.
+func (o Options) connArgs() []string {
+	args := o.BaseArgs()
+	for i := 0; i < o.Verbosity; i++ {
+		args = append(args, "-v")
+	}
+	if o.Quiet {
+		args = append(args, "-q")
+	}
+	if o.Batch {
+		args = append(args, "-o", "BatchMode=yes")
+	}
+	return append(args, o.Extra...)
+}
+
+// ResolveArgs is the argv (after "ssh") that makes OpenSSH print the
+// resolved configuration for alias; see sshconf.ParseResolved.
+func ResolveArgs(o Options, alias string) []string {
+	return append(o.BaseArgs(), "-G", "--", alias)
+}
+
+// SSHArgs is the argv (after "ssh") that connects to alias, running
+// remoteCmd if given. After --, ssh treats the next arg as the
+// destination and everything after as the remote command, forwarded to
+// the remote shell verbatim.
+func SSHArgs(o Options, alias string, remoteCmd []string) []string {
+	args := append(o.connArgs(), envArgs(o.Env)...)
+	args = append(args, "--", alias)
+	return append(args, WithEnv(o.Env, remoteCmd)...)
+}
+
+// SCPArgs is the argv (after "scp") for a transfer in gt's colon
+// shorthand: a leading ":" marks the remote side, and either every
+// source or the destination must be remote. scp reads ssh_config
+// itself, so passing alias:path leaves port, identity, ProxyJump, and
+// everything else to OpenSSH.
+func SCPArgs(o Options, alias string, files []string) ([]string, error) {
+	if err := ValidateSCPPaths(files); err != nil {
+		return nil, err
+	}
+	args := o.connArgs()
+	if o.Recursive {
+		args = append(args, "-r")
+	}
+	if !o.NoPreserve {
+		args = append(args, "-p") // preserve modes and times
+	}
+	args = append(args, "--") // end option parsing
+	return append(args, remotePaths(alias, files)...), nil
+}
+
+// remotePaths prefixes the remote side of a validated file list with
+// host.
+func remotePaths(host string, files []string) []string {
+	var args []string
+	dest := files[len(files)-1]
+	if strings.HasPrefix(dest, ":") {
+		// Upload: Add all source files then the remote destination
+		args = append(args, files[:len(files)-1]...)
+		args = append(args, host+dest)
+	} else {
+		// Download: Add remote sources then local destination
+		for _, src := range files[:len(files)-1] {
+			args = append(args, host+src)
+		}
+		args = append(args, dest)
+	}
+	return args
+}
+
+// ValidateNoFlagPrefix rejects a value that the tool would parse as an
+// option.
+func ValidateNoFlagPrefix(name, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("%s must not start with '-' (got %q)", name, value)
+	}
+	return nil
+}
+
+// CopiesContents reports whether a source in the colon shorthand copies
+// what is in a directory rather than the directory itself, as rsync
+// reads a trailing slash: "dir/" or "dir/." does, "dir" does not. A bare
+// ":" is the remote home directory's contents.
+func CopiesContents(src string) bool {
+	p, remote := strings.CutPrefix(src, ":")
+	if !remote {
+		p = filepath.ToSlash(p)
+	}
+	return p == "" || p == "." || strings.HasSuffix(p, "/") || strings.HasSuffix(p, "/.")
+}
+
+// copiedName is the name a source that copies itself lands under in the
+// destination.
+func copiedName(src string) string {
+	if p, ok := strings.CutPrefix(src, ":"); ok {
+		return path.Base(p)
+	}
+	return filepath.Base(src)
+}
+
+// ValidateSCPPaths checks a file list in the colon shorthand. Besides
+// the direction, it rejects two sources that would land under the same
+// name in the destination, the second overwriting the first.
+func ValidateSCPPaths(files []string) error {
+	if len(files) < 2 {
+		return fmt.Errorf("SCP requires at least a source and destination")
+	}
+
+	// Determine if this is a download based on the first file
+	isDownload := strings.HasPrefix(files[0], ":")
+
+	if isDownload {
+		// For downloads, all source paths must start with :
+		for i := 0; i < len(files)-1; i++ {
+			if !strings.HasPrefix(files[i], ":") {
+				return fmt.Errorf("download paths must start with ':' (got %s)", files[i])
+			}
+		}
+		local := files[len(files)-1]
+		// The last path (destination) must not start with :
+		if strings.HasPrefix(local, ":") {
+			return fmt.Errorf("local destination path must not start with ':' (got %s)", local)
+		}
+		if strings.HasPrefix(local, "-") {
+			return fmt.Errorf("local path must not start with '-' (got %s); prefix it with './'", local)
+		}
+	} else {
+		// For uploads, all source paths must not start with :
+		for i := 0; i < len(files)-1; i++ {
+			src := files[i]
+			if strings.HasPrefix(src, ":") {
+				return fmt.Errorf("local source paths should not contain ':' (got %s)", src)
+			}
+			if strings.HasPrefix(src, "-") {
+				return fmt.Errorf("local path must not start with '-' (got %s); prefix it with './'", src)
+			}
+		}
+		// The last path (destination) must start with :
+		if !strings.HasPrefix(files[len(files)-1], ":") {
+			return fmt.Errorf("remote destination path must start with ':' (got %s)", files[len(files)-1])
+		}
+	}
+
+	seen := map[string]string{}
+	for _, src := range files[:len(files)-1] {
+		if CopiesContents(src) || strings.ContainsAny(src, "*?[") {
+			continue
+		}
+		name := copiedName(src)
+		if prev, ok := seen[name]; ok {
+			return fmt.Errorf("%s and %s would both be copied as %s", prev, src, name)
+		}
+		seen[name] = src
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSCPPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name:    "no files",
+			files:   []string{},
+			wantErr: true,
+			errMsg:  "SCP requires at least a source and destination",
+		},
+		{
+			name:    "single file",
+			files:   []string{"file.txt"},
+			wantErr: true,
+			errMsg:  "SCP requires at least a source and destination",
+		},
+		{
+			name:    "valid upload",
+			files:   []string{"local.txt", ":remote/path"},
+			wantErr: false,
+		},
+		{
+			name:    "valid download",
+			files:   []string{":remote.txt", "local/path"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid upload - destination without colon",
+			files:   []string{"local.txt", "remote/path"},
+			wantErr: true,
+			errMsg:  "remote destination path must start with ':' (got remote/path)",
+		},
+		{
+			name:    "invalid download - source without colon",
+			files:   []string{"remote.txt", "local/path"},
+			wantErr: true,
+			errMsg:  "remote destination path must start with ':' (got local/path)",
+		},
+		{
+			name:    "multiple file upload",
+			files:   []string{"local1.txt", "local2.txt", ":remote/path"},
+			wantErr: false,
+		},
+		{
+			name:    "multiple file download",
+			files:   []string{":remote1.txt", ":remote2.txt", "local/path"},
+			wantErr: false,
+		},
+		{
+			name:    "mixed upload paths",
+			files:   []string{"local1.txt", ":remote1.txt", ":remote/path"},
+			wantErr: true,
+			errMsg:  "local source paths should not contain ':' (got :remote1.txt)",
+		},
+		{
+			name:    "upload local path starting with -",
+			files:   []string{"-oProxyCommand=evil", ":remote/path"},
+			wantErr: true,
+			errMsg:  "local path must not start with '-' (got -oProxyCommand=evil); prefix it with './'",
+		},
+		{
+			name:    "download local destination starting with -",
+			files:   []string{":remote.txt", "-oProxyCommand=evil"},
+			wantErr: true,
+			errMsg:  "local path must not start with '-' (got -oProxyCommand=evil); prefix it with './'",
+		},
+		{
+			name:    "two sources copied under one name",
+			files:   []string{"a/site", "b/site", ":/var/www"},
+			wantErr: true,
+			errMsg:  "a/site and b/site would both be copied as site",
+		},
+		{
+			name:    "contents of two directories",
+			files:   []string{"a/site/", "b/site/", ":/var/www"},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSCPPaths(tt.files)
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errMsg != "" {
+					assert.Equal(t, tt.errMsg, err.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSSHArgs(t *testing.T) {
+	o := Options{ConfigFile: "/tmp/cfg", User: "admin", Verbosity: 2, Batch: true, Extra: []string{"-T"}}
+	assert.Equal(t, []string{
+		"-F", "/tmp/cfg", "-o", "User=admin", "-v", "-v", "-o", "BatchMode=yes", "-T",
+		"--", "web", "uptime",
+	}, SSHArgs(o, "web", []string{"uptime"}))
+	assert.Equal(t, []string{"--", "web"}, SSHArgs(Options{}, "web", nil))
+}
+
+func TestOverridesApplyToEveryInvocation(t *testing.T) {
+	o := Options{Overrides: []string{"ProxyJump=bastion", "Port=2200"}}
+	want := []string{"-o", "ProxyJump=bastion", "-o", "Port=2200"}
+	assert.Equal(t, append(want, "-G", "--", "web"), ResolveArgs(o, "web"))
+	assert.Equal(t, append(want, "--", "web"), SSHArgs(o, "web", nil))
+}
+
+func TestResolveArgsIgnoreConnectionOptions(t *testing.T) {
+	o := Options{User: "admin", Verbosity: 1, Batch: true, Quiet: true, Extra: []string{"-T"}}
+	assert.Equal(t, []string{"-o", "User=admin", "-G", "--", "web"}, ResolveArgs(o, "web"))
+}
+
+func TestSCPArgs(t *testing.T) {
+	args, err := SCPArgs(Options{}, "web", []string{"a.txt", "b.txt", ":/tmp/"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-p", "--", "a.txt", "b.txt", "web:/tmp/"}, args)
+
+	args, err = SCPArgs(Options{Batch: true}, "web", []string{":/etc/hosts", "."})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-p", "--", "web:/etc/hosts", "."}, args)
+
+	args, err = SCPArgs(Options{Quiet: true}, "web", []string{"a.txt", ":/tmp/"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-q", "-p", "--", "a.txt", "web:/tmp/"}, args)
+
+	args, err = SCPArgs(Options{Recursive: true}, "web", []string{"site", ":/var/www"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-r", "-p", "--", "site", "web:/var/www"}, args)
+
+	args, err = SCPArgs(Options{NoPreserve: true}, "web", []string{"a.txt", ":/tmp/"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--", "a.txt", "web:/tmp/"}, args)
+
+	_, err = SCPArgs(Options{}, "web", []string{"a.txt"})
+	assert.Error(t, err)
+}
+
+func TestCopiesContents(t *testing.T) {
+	for src, want := range map[string]bool{
+		"site": false, "site/": true, "site/.": true, ".": true,
+		":": true, ":logs": false, ":logs/": true, ":/": true, ":.env": false,
+	} {
+		assert.Equal(t, want, CopiesContents(src), src)
+	}
+}
+
+func TestSSHArgsPassEnv(t *testing.T) {
+	t.Setenv("GT_TEST_LANG", "de_DE.UTF-8")
+	o := Options{Env: []string{"GT_TEST_LANG", "TOKEN=a b", "UNSET_HERE"}}
+	assert.Equal(t, []string{
+		"-o", "SendEnv=GT_TEST_LANG", "-o", `SetEnv="TOKEN=a b"`, "-o", "SendEnv=UNSET_HERE",
+		"--", "web", "export GT_TEST_LANG='de_DE.UTF-8' TOKEN='a b';", "uptime",
+	}, SSHArgs(o, "web", []string{"uptime"}))
+	assert.Equal(t, []string{"-o", "SendEnv=GT_TEST_LANG", "-o", `SetEnv="TOKEN=a b"`, "-o", "SendEnv=UNSET_HERE", "--", "web"}, SSHArgs(o, "web", nil))
+	assert.Equal(t, []string{"-G", "--", "web"}, ResolveArgs(o, "web"), "ssh -G needs no environment")
+}
@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envName is what a shell accepts as a variable name.
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnv checks an Env entry: KEY, or KEY=value.
+func ValidateEnv(spec string) error {
+	name, _, _ := strings.Cut(spec, "=")
+	if !envName.MatchString(name) {
+		return fmt.Errorf("invalid environment variable %q: want KEY or KEY=value", spec)
+	}
+	return nil
+}
+
+// envArgs asks ssh to pass Env on: a bare KEY with SendEnv, from ssh's
+// own environment, and KEY=value with SetEnv.
+func envArgs(env []string) []string {
+	var args []string
+	for _, e := range env {
+		if !strings.Contains(e, "=") {
+			args = append(args, "-o", "SendEnv="+e)
+			continue
+		}
+		if strings.ContainsAny(e, " \t\"'\\") {
+			// ssh splits option values on whitespace unless quoted.
+			e = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(e) + `"`
+		}
+		args = append(args, "-o", "SetEnv="+e)
+	}
+	return args
+}
+
+// WithEnv prefixes remoteCmd with exports of env, so the command sees
+// the variables even where the server's AcceptEnv drops them. A bare KEY
+// takes its value from this process's environment, as SendEnv would;
+// one that is unset here is left out. A session without a command is
+// returned as is.
+func WithEnv(env, remoteCmd []string) []string {
+	if len(remoteCmd) == 0 {
+		return remoteCmd
+	}
+	var exports []string
+	for _, e := range env {
+		name, value, ok := strings.Cut(e, "=")
+		if !ok {
+			if value, ok = os.LookupEnv(name); !ok {
+				continue
+			}
+		}
+		exports = append(exports, name+"="+shellQuote(value))
+	}
+	if len(exports) == 0 {
+		return remoteCmd
+	}
+	prefix := "export " + strings.Join(exports, " ") + ";"
+	return append([]string{prefix}, remoteCmd...)
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,27 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEnv(t *testing.T) {
+	for _, ok := range []string{"LANG", "TOKEN=", "TOKEN=a=b", "_X1=y"} {
+		assert.NoError(t, ValidateEnv(ok), ok)
+	}
+	for _, bad := range []string{"", "=x", "1X=y", "A-B=c", "-oProxyCommand=x", "A B"} {
+		assert.Error(t, ValidateEnv(bad), bad)
+	}
+}
+
+func TestEnvArgsQuoteSetEnv(t *testing.T) {
+	assert.Equal(t, []string{"-o", `SetEnv="MSG=say \"hi\" \\o/"`}, envArgs([]string{`MSG=say "hi" \o/`}))
+	assert.Equal(t, []string{"-o", "SetEnv=EMPTY="}, envArgs([]string{"EMPTY="}))
+}
+
+func TestWithEnvQuotesForTheShell(t *testing.T) {
+	assert.Equal(t, []string{`export A='it'\''s';`, "echo", "$A"}, WithEnv([]string{"A=it's"}, []string{"echo", "$A"}))
+	assert.Equal(t, []string{"uptime"}, WithEnv(nil, []string{"uptime"}))
+	assert.Nil(t, WithEnv([]string{"A=b"}, nil))
+}
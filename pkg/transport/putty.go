@@ -0,0 +1,141 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"gt/pkg/sshconf"
+)
+
+// PuTTYArgs builds the connection options plink and pscp share, from
+// values resolved out of the config (see sshconf.FromConfig), since
+// PuTTY cannot read ssh_config. -P is the port for both. A port, user
+// or key left empty is not passed at all, so PuTTY's own default applies
+// rather than a "-P" with no value. An IdentityFile is only passed when
+// it is a PuTTY .ppk key, since PuTTY cannot read OpenSSH private keys
+// directly; a key skipped for that reason is returned so the caller can
+// say so.
+func PuTTYArgs(r sshconf.Resolved, o Options) (args []string, skippedKey string, err error) {
+	for _, v := range []struct{ name, value string }{{"hostname", r.Hostname}, {"user", r.User}} {
+		if err := ValidateNoFlagPrefix(v.name, v.value); err != nil {
+			return nil, "", err
+		}
+	}
+	if r.Port != "" {
+		args = append(args, "-P", r.Port)
+	}
+	if r.User != "" {
+		args = append(args, "-l", r.User)
+	}
+	if o.Verbosity > 0 {
+		args = append(args, "-v")
+	}
+	if len(r.IdentityFiles) > 0 && r.IdentityFiles[0] != "" {
+		if id := r.IdentityFiles[0]; strings.HasSuffix(strings.ToLower(id), ".ppk") {
+			args = append(args, "-i", id)
+		} else {
+			skippedKey = id
+		}
+	}
+	if o.Batch {
+		args = append(args, "-batch")
+	}
+	proxy, err := puttyProxy(r, o)
+	if err != nil {
+		return nil, "", err
+	}
+	if proxy != "" {
+		args = append(args, "-proxycmd", proxy)
+	}
+	return args, skippedKey, nil
+}
+
+// SplitJump cuts a ProxyJump hop, [ssh://][user@]host[:port], into its
+// parts; user and port are empty when not given.
+func SplitJump(hop string) (user, host, port string) {
+	hop = strings.TrimPrefix(hop, "ssh://")
+	if i := strings.LastIndex(hop, "@"); i >= 0 {
+		user, hop = hop[:i], hop[i+1:]
+	}
+	if h, p, err := net.SplitHostPort(hop); err == nil {
+		return user, h, p
+	}
+	return user, strings.Trim(hop, "[]"), ""
+}
+
+// puttyProxy is the -proxycmd that reaches r the way ssh would: its
+// ProxyCommand, or for a ProxyJump a second plink that forwards to the
+// host (-nc) from the jump host. PuTTY runs the command itself, reading
+// backslashes and percent signs as its own escapes, so those are doubled.
+// It has no way to chain jumps, so a ProxyJump of several hosts is
+// refused rather than half honored.
+func puttyProxy(r sshconf.Resolved, o Options) (string, error) {
+	if pc := r.ProxyCommand; pc != "" && pc != "none" {
+		return strings.NewReplacer(`\`, `\\`, "%", "%%").Replace(pc), nil
+	}
+	if r.ProxyJump == "" || r.ProxyJump == "none" {
+		return "", nil
+	}
+	if strings.Contains(r.ProxyJump, ",") {
+		return "", fmt.Errorf("ProxyJump %s: PuTTY can only jump through one host", r.ProxyJump)
+	}
+	user, host, port := SplitJump(r.ProxyJump)
+	for _, v := range []struct{ name, value string }{{"jump host", host}, {"jump user", user}} {
+		if err := ValidateNoFlagPrefix(v.name, v.value); err != nil {
+			return "", err
+		}
+		if strings.ContainsAny(v.value, " \t\"'%\\") {
+			return "", fmt.Errorf("invalid %s %q", v.name, v.value)
+		}
+	}
+	cmd := []string{"plink"}
+	if o.Batch {
+		cmd = append(cmd, "-batch")
+	}
+	if port != "" {
+		cmd = append(cmd, "-P", port)
+	}
+	if user != "" {
+		cmd = append(cmd, "-l", user)
+	}
+	return strings.Join(append(cmd, "-nc", "%host:%port", host), " "), nil
+}
+
+// PlinkArgs is the argv (after "plink") that connects to the resolved
+// host, asking for a terminal when there is no remote command. plink
+// cannot send variables, so Env only reaches a remote command.
+func PlinkArgs(r sshconf.Resolved, o Options, remoteCmd []string) ([]string, string, error) {
+	args, skipped, err := PuTTYArgs(r, o)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(remoteCmd) == 0 {
+		args = append(args, "-t")
+	}
+	args = append(args, r.Hostname)
+	return append(args, WithEnv(o.Env, remoteCmd)...), skipped, nil
+}
+
+// PSCPArgs is the argv (after "pscp") for a transfer in the colon
+// shorthand, addressed to the resolved hostname with IPv6 literals
+// bracketed.
+func PSCPArgs(r sshconf.Resolved, o Options, files []string) ([]string, string, error) {
+	if err := ValidateSCPPaths(files); err != nil {
+		return nil, "", err
+	}
+	args, skipped, err := PuTTYArgs(r, o)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.Recursive {
+		args = append(args, "-r")
+	}
+	if !o.NoPreserve {
+		args = append(args, "-p")
+	}
+	if o.Quiet {
+		args = append(args, "-q") // plink has no quiet mode; pscp's hides the progress meter
+	}
+	return append(args, remotePaths(BracketHost(r.Hostname), files)...), skipped, nil
+}
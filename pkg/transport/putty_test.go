@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gt/pkg/sshconf"
+)
+
+func TestPlinkArgs(t *testing.T) {
+	r := sshconf.Resolved{User: "me", Hostname: "web.example.com", Port: "2222", IdentityFiles: []string{"/keys/web.ppk"}}
+
+	args, skipped, err := PlinkArgs(r, Options{Verbosity: 1}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, skipped)
+	assert.Equal(t, []string{"-P", "2222", "-l", "me", "-v", "-i", "/keys/web.ppk", "-t", "web.example.com"}, args)
+
+	args, _, err = PlinkArgs(r, Options{Batch: true}, []string{"uptime"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-P", "2222", "-l", "me", "-i", "/keys/web.ppk", "-batch", "web.example.com", "uptime"}, args)
+}
+
+func TestPuTTYArgsSkipsOpenSSHKeys(t *testing.T) {
+	r := sshconf.Resolved{User: "me", Hostname: "web", Port: "22", IdentityFiles: []string{"/keys/id_ed25519"}}
+	args, skipped, err := PuTTYArgs(r, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/keys/id_ed25519", skipped)
+	assert.Equal(t, []string{"-P", "22", "-l", "me"}, args)
+}
+
+func TestPuTTYArgsMinimalConfig(t *testing.T) {
+	args, skipped, err := PuTTYArgs(sshconf.Resolved{Hostname: "web"}, Options{})
+	assert.NoError(t, err)
+	assert.Empty(t, skipped)
+	assert.Empty(t, args, "no empty -P, -l or -i")
+
+	args, _, err = PSCPArgs(sshconf.Resolved{Hostname: "web", IdentityFiles: []string{""}}, Options{}, []string{"a.txt", ":/tmp/"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-p", "a.txt", "web:/tmp/"}, args)
+
+	args, _, err = PlinkArgs(sshconf.Resolved{Hostname: "web", User: "me"}, Options{}, []string{"uptime"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-l", "me", "web", "uptime"}, args)
+}
+
+func TestPuTTYArgsRejectFlagValues(t *testing.T) {
+	_, _, err := PuTTYArgs(sshconf.Resolved{User: "me", Hostname: "-oProxyCommand=evil", Port: "22"}, Options{})
+	assert.Error(t, err)
+}
+
+func TestPSCPArgs(t *testing.T) {
+	r := sshconf.Resolved{User: "me", Hostname: "2001:db8::1", Port: "22"}
+	args, _, err := PSCPArgs(r, Options{}, []string{"a.txt", ":/tmp/"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-P", "22", "-l", "me", "-p", "a.txt", "[2001:db8::1]:/tmp/"}, args)
+
+	args, _, err = PSCPArgs(r, Options{Quiet: true}, []string{":/etc/hosts", "."})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-P", "22", "-l", "me", "-p", "-q", "[2001:db8::1]:/etc/hosts", "."}, args)
+
+	args, _, err = PSCPArgs(r, Options{Recursive: true}, []string{"site", ":/var/www"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-P", "22", "-l", "me", "-r", "-p", "site", "[2001:db8::1]:/var/www"}, args)
+
+	args, _, err = PSCPArgs(r, Options{NoPreserve: true}, []string{"a.txt", ":/tmp/"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-P", "22", "-l", "me", "a.txt", "[2001:db8::1]:/tmp/"}, args)
+}
+
+func TestSplitJump(t *testing.T) {
+	for hop, want := range map[string][3]string{
+		"bastion":                {"", "bastion", ""},
+		"ops@bastion:2222":       {"ops", "bastion", "2222"},
+		"ssh://ops@bastion:2222": {"ops", "bastion", "2222"},
+		"[2001:db8::1]:22":       {"", "2001:db8::1", "22"},
+		"2001:db8::1":            {"", "2001:db8::1", ""},
+	} {
+		user, host, port := SplitJump(hop)
+		assert.Equal(t, want, [3]string{user, host, port}, hop)
+	}
+}
+
+func TestPuTTYArgsProxy(t *testing.T) {
+	r := sshconf.Resolved{User: "me", Hostname: "db.internal", Port: "22", ProxyJump: "ops@bastion.example.com:2222"}
+	args, _, err := PuTTYArgs(r, Options{Batch: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-P", "22", "-l", "me", "-batch",
+		"-proxycmd", "plink -batch -P 2222 -l ops -nc %host:%port bastion.example.com"}, args)
+
+	r.ProxyCommand = `C:\tools\ncat.exe --proxy gw:8080 db.internal 22 # 100%`
+	args, _, err = PuTTYArgs(r, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, `C:\\tools\\ncat.exe --proxy gw:8080 db.internal 22 # 100%%`, args[len(args)-1], "ProxyCommand wins, escaped for PuTTY")
+
+	r.ProxyCommand, r.ProxyJump = "none", "none"
+	args, _, err = PuTTYArgs(r, Options{})
+	assert.NoError(t, err)
+	assert.NotContains(t, args, "-proxycmd")
+
+	r.ProxyJump = "a,b"
+	_, _, err = PuTTYArgs(r, Options{})
+	assert.EqualError(t, err, "ProxyJump a,b: PuTTY can only jump through one host")
+
+	r.ProxyJump = "-oevil"
+	_, _, err = PuTTYArgs(r, Options{})
+	assert.Error(t, err)
+}
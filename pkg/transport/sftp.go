@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SFTP tunes a transfer over sftp instead of scp. On a link with high
+// latency, many requests in flight keep the pipe full where scp waits on
+// each round trip.
+type SFTP struct {
+	// Requests is how many read or write requests may be outstanding at
+	// once (sftp -R); 0 keeps sftp's default of 64.
+	Requests int
+	// BufferSize is the size in bytes of each request (sftp -B); 0
+	// keeps sftp's default of 32768.
+	BufferSize int
+	// Progress turns on the progress meter, which sftp leaves off when
+	// it reads its commands from a batch.
+	Progress bool
+	// NoPreserve leaves the copies' modes and times to the destination
+	// (put and get without -p).
+	NoPreserve bool
+}
+
+// SFTPArgs is the argv (after "sftp") for a transfer with alias. sftp
+// reads its commands from stdin (-b -), as SFTPBatch writes them; a
+// batch never prompts, so the host needs key or agent authentication.
+func SFTPArgs(o Options, s SFTP, alias string) []string {
+	args := o.connArgs()
+	if s.Requests > 0 {
+		args = append(args, "-R", strconv.Itoa(s.Requests))
+	}
+	if s.BufferSize > 0 {
+		args = append(args, "-B", strconv.Itoa(s.BufferSize))
+	}
+	return append(args, "-b", "-", "--", alias)
+}
+
+// SFTPBatch is the sftp batch for a file list in gt's colon shorthand:
+// one put or get per source, recursive and, unless s.NoPreserve,
+// keeping modes and times like scp -p. Remote wildcards expand as scp's would; local paths are taken
+// literally, the shell having expanded them already.
+func SFTPBatch(s SFTP, files []string) (string, error) {
+	if err := ValidateSCPPaths(files); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if s.Progress {
+		b.WriteString("progress\n")
+	}
+	dest := files[len(files)-1]
+	flags := "-pR"
+	if s.NoPreserve {
+		flags = "-R"
+	}
+	for _, src := range files[:len(files)-1] {
+		if strings.HasPrefix(dest, ":") {
+			fmt.Fprintf(&b, "put %s %s %s\n", flags, sftpQuote(src, true), sftpQuote(remotePath(dest), false))
+		} else {
+			fmt.Fprintf(&b, "get %s %s %s\n", flags, sftpQuote(remotePath(src), false), sftpQuote(dest, false))
+		}
+	}
+	return b.String(), nil
+}
+
+// SFTPPut is one path of an upload sent entry by entry, as a filtered
+// tree is: a directory to create or a file to copy to Remote.
+type SFTPPut struct {
+	Local, Remote string
+	Dir           bool
+}
+
+// SFTPPutBatch is the sftp batch for an upload listed path by path, each
+// directory before what is in it. A directory that exists already is not
+// an error.
+func SFTPPutBatch(s SFTP, puts []SFTPPut) string {
+	var b strings.Builder
+	if s.Progress {
+		b.WriteString("progress\n")
+	}
+	put := "put -p"
+	if s.NoPreserve {
+		put = "put"
+	}
+	for _, p := range puts {
+		remote := sftpQuote(remotePath(":"+p.Remote), true)
+		if p.Dir {
+			fmt.Fprintf(&b, "-mkdir %s\n", remote)
+		} else {
+			fmt.Fprintf(&b, "%s %s %s\n", put, sftpQuote(p.Local, true), remote)
+		}
+	}
+	return b.String()
+}
+
+// remotePath is the path after the colon; a bare ":" is the remote
+// home directory, where sftp starts. One starting with '-' gets a "./",
+// or put and get would take it for a flag.
+func remotePath(p string) string {
+	p = strings.TrimPrefix(p, ":")
+	switch {
+	case p == "":
+		return "."
+	case strings.HasPrefix(p, "-"):
+		return "./" + p
+	}
+	return p
+}
+
+// sftpQuote escapes p for sftp's command parser, which splits on
+// whitespace and strips quotes and backslashes. put and get expand
+// wildcards in their source unless escaped; literal escapes them, for a
+// path the shell has already expanded.
+func sftpQuote(p string, literal bool) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c >= 0x80, c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			strings.IndexByte("/._-+,:@%=~", c) >= 0:
+		case !literal && strings.IndexByte("*?[]", c) >= 0:
+		default:
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSFTPArgs(t *testing.T) {
+	o := Options{ConfigFile: "/tmp/cfg", Quiet: true}
+	assert.Equal(t, []string{"-F", "/tmp/cfg", "-q", "-b", "-", "--", "web1"}, SFTPArgs(o, SFTP{}, "web1"))
+	assert.Equal(t, []string{"-R", "128", "-B", "65536", "-b", "-", "--", "web1"},
+		SFTPArgs(Options{}, SFTP{Requests: 128, BufferSize: 65536}, "web1"))
+}
+
+func TestSFTPBatch(t *testing.T) {
+	batch, err := SFTPBatch(SFTP{Progress: true}, []string{"app.conf", "my dir", ":/etc/app/"})
+	require.NoError(t, err)
+	assert.Equal(t, "progress\nput -pR app.conf /etc/app/\nput -pR my\\ dir /etc/app/\n", batch)
+
+	batch, err = SFTPBatch(SFTP{}, []string{":logs/*.log", ":", "out"})
+	require.NoError(t, err)
+	assert.Equal(t, "get -pR logs/*.log out\nget -pR . out\n", batch)
+
+	batch, err = SFTPBatch(SFTP{NoPreserve: true}, []string{"app.conf", ":"})
+	require.NoError(t, err)
+	assert.Equal(t, "put -R app.conf .\n", batch)
+
+	_, err = SFTPBatch(SFTP{}, []string{"a", "b"})
+	assert.Error(t, err)
+}
+
+func TestSFTPPutBatch(t *testing.T) {
+	assert.Equal(t, "-mkdir /srv/app\nput -p app/main.go /srv/app/main.go\nput -p my\\ file /srv/-x\n",
+		SFTPPutBatch(SFTP{}, []SFTPPut{
+			{Local: "app", Remote: "/srv/app", Dir: true},
+			{Local: "app/main.go", Remote: "/srv/app/main.go"},
+			{Local: "my file", Remote: "/srv/-x"},
+		}))
+	assert.Equal(t, "progress\n-mkdir ./-x\n", SFTPPutBatch(SFTP{Progress: true}, []SFTPPut{{Remote: "-x", Dir: true}}))
+	assert.Equal(t, "put a /srv/a\n", SFTPPutBatch(SFTP{NoPreserve: true}, []SFTPPut{{Local: "a", Remote: "/srv/a"}}))
+}
+
+func TestSFTPQuote(t *testing.T) {
+	assert.Equal(t, `it\'s\ \"here\"`, sftpQuote(`it's "here"`, false))
+	assert.Equal(t, `a\\b`, sftpQuote(`a\b`, false))
+	assert.Equal(t, `report\[1\]\*`, sftpQuote("report[1]*", true))
+	assert.Equal(t, "*.log", sftpQuote("*.log", false))
+	assert.Equal(t, "./-rf", remotePath(":-rf"))
+	assert.Equal(t, "日本/x", sftpQuote("日本/x", true))
+}
@@ -0,0 +1,17 @@
+package transport
+
+// Tool is how to invoke an OpenSSH-compatible program: the argv prefix
+// naming it (a binary, or a wrapper plus its subcommand such as
+// "gcloud compute ssh") and default args that go ahead of everything
+// else, where a wrapper expects its own options.
+type Tool struct {
+	Argv []string
+	Args []string
+}
+
+// Command returns the full argv for running the tool with args.
+func (t Tool) Command(args ...string) []string {
+	argv := append([]string(nil), t.Argv...)
+	argv = append(argv, t.Args...)
+	return append(argv, args...)
+}